@@ -19,6 +19,7 @@ import (
 	"chain/core"
 	"chain/core/accesstoken"
 	"chain/core/config"
+	"chain/core/query"
 	"chain/core/rpc"
 	"chain/crypto/ed25519"
 	"chain/env"
@@ -59,6 +60,8 @@ var commands = map[string]*command{
 	"create-token":         {createToken},
 	"config":               {configNongenerator},
 	"reset":                {reset},
+	"reindex":              {reindex},
+	"recover":              {recoverCore},
 	"grant":                {grant},
 	"revoke":               {revoke},
 	"join":                 {joinCluster},
@@ -290,6 +293,113 @@ func reset(client *rpc.Client, args []string) {
 	dieOnRPCError(err)
 }
 
+// reindex starts replaying stored blocks through the core's
+// transaction indexer in the background. With -status, it prints
+// the progress of the most recent reindex instead.
+func reindex(client *rpc.Client, args []string) {
+	const usage = "usage: corectl reindex [-status] [from-height [to-height]]"
+	var flags flag.FlagSet
+	flagStatus := flags.Bool("status", false, "print the progress of the most recent reindex")
+	flags.Usage = func() {
+		fmt.Println(usage)
+		flags.PrintDefaults()
+		os.Exit(1)
+	}
+	flags.Parse(args)
+	args = flags.Args()
+
+	var progress *query.BackfillProgress
+	if *flagStatus {
+		if len(args) != 0 {
+			fatalln(usage)
+		}
+		err := client.Call(context.Background(), "/reindex-progress", nil, &progress)
+		dieOnRPCError(err)
+		if progress == nil {
+			fmt.Println("no reindex has run")
+			return
+		}
+		printReindexProgress(progress)
+		return
+	}
+
+	if len(args) > 2 {
+		fatalln(usage)
+	}
+	var heights [2]uint64
+	for i, arg := range args {
+		h, err := strconv.ParseUint(arg, 10, 64)
+		if err != nil {
+			fatalln(usage)
+		}
+		heights[i] = h
+	}
+	req := map[string]uint64{
+		"from_height": heights[0],
+		"to_height":   heights[1],
+	}
+	err := client.Call(context.Background(), "/reindex", req, &progress)
+	dieOnRPCError(err)
+	printReindexProgress(progress)
+	fmt.Println("reindexing in the background; check progress with corectl reindex -status")
+}
+
+func printReindexProgress(p *query.BackfillProgress) {
+	state := "in progress"
+	if p.Done() {
+		state = "done"
+	} else if p.Error != "" {
+		state = "failed: " + p.Error
+	}
+	fmt.Printf("blocks %d-%d: %s, next height %d (updated %s)\n",
+		p.FromHeight, p.ToHeight, state, p.NextHeight, p.UpdatedAt.Format(time.RFC3339))
+}
+
+// recoverCore rebuilds the core's state snapshot, account UTXOs,
+// and transaction index from the stored blocks. With -resume, it
+// continues an interrupted recovery instead of starting over.
+func recoverCore(client *rpc.Client, args []string) {
+	const usage = "usage: corectl recover [-resume]"
+	var flags flag.FlagSet
+	flagResume := flags.Bool("resume", false, "continue an interrupted recovery")
+	flags.Usage = func() {
+		fmt.Println(usage)
+		flags.PrintDefaults()
+		os.Exit(1)
+	}
+	flags.Parse(args)
+	if len(flags.Args()) != 0 {
+		fatalln(usage)
+	}
+
+	req := map[string]bool{"resume": *flagResume}
+	var progress struct {
+		Stage       string `json:"stage"`
+		BlockHeight uint64 `json:"block_height"`
+		Done        bool   `json:"done"`
+		Error       string `json:"error"`
+	}
+	err := client.Call(context.Background(), "/recover", req, &progress)
+	dieOnRPCError(err)
+
+	// The recovery runs in the background; report each stage
+	// until it's done.
+	var stage string
+	for !progress.Done {
+		if progress.Stage != stage {
+			stage = progress.Stage
+			fmt.Printf("recovering: %s\n", stage)
+		}
+		time.Sleep(time.Second)
+		err = client.Call(context.Background(), "/recover-progress", nil, &progress)
+		dieOnRPCError(err)
+	}
+	if progress.Error != "" {
+		fatalln("recovery failed in stage", progress.Stage+":", progress.Error)
+	}
+	fmt.Printf("recovered through block %d\n", progress.BlockHeight)
+}
+
 func grant(client *rpc.Client, args []string) {
 	editAuthz(client, args, "grant")
 }
@@ -67,6 +67,17 @@ var (
 	rpsToken      = env.Int("RATELIMIT_TOKEN", 0)       // reqs/sec
 	rpsRemoteAddr = env.Int("RATELIMIT_REMOTE_ADDR", 0) // reqs/sec
 	indexTxs      = env.Bool("INDEX_TRANSACTIONS", true)
+	textSearch    = env.Bool("TEXT_SEARCH", false)
+	minTxTTL      = env.Duration("MIN_TX_TTL", 0)                     // 0 means the default
+	maxTxTTL      = env.Duration("MAX_TX_TTL", 0)                     // 0 means the default
+	maxIssueSkew  = env.Duration("MAX_ISSUANCE_SKEW", 0)              // 0 means the default
+	poolMaxTxs    = env.Int("POOL_MAX_TXS", 0)                        // 0 means no limit
+	poolMaxBytes  = env.Int("POOL_MAX_BYTES", 0)                      // 0 means no limit
+	poolEvict     = env.Bool("POOL_EVICT_OLDEST", false)              // reject new txs when full if false
+	emptyInterval = env.Duration("EMPTY_BLOCK_INTERVAL", 0)           // 0 means empty blocks are never made
+	assetCacheTTL = env.Duration("ASSET_CACHE_TTL", 0)                // 0 means cached assets don't expire
+	maxUnusedAge  = env.Duration("MAX_UNUSED_CONTROL_PROGRAM_AGE", 0) // 0 means unused programs are never swept
+	sizeLimit     = env.Bool("BLOCK_SIZE_LIMIT", false)               // make blocks whose size signers limit
 	home          = config.HomeDirFromEnvironment()
 
 	version string // initialized in init()
@@ -342,10 +353,20 @@ func launchConfiguredCore(ctx context.Context, confOpts *config.Options, sdb *si
 	var localSigner *blocksigner.BlockSigner
 
 	opts = append(opts, core.IndexTransactions(*indexTxs))
+	opts = append(opts, core.TextSearch(*textSearch))
+	opts = append(opts, core.TxTTLBounds(*minTxTTL, *maxTxTTL))
+	opts = append(opts, core.AssetCacheTTL(*assetCacheTTL))
+	opts = append(opts, core.MaxUnusedControlProgramAge(*maxUnusedAge))
 	opts = append(opts, enableMockHSM(db)...)
 	// Add any configured API request rate limits.
-	if *rpsToken > 0 {
-		opts = append(opts, core.RateLimit(limit.AuthUserID, 2*(*rpsToken), *rpsToken))
+	// Per-token limits set through the API need the
+	// token limiter even if no default is configured.
+	hasTokenLimits, err := (&accesstoken.CredentialStore{DB: db}).HasLimits(ctx)
+	if err != nil {
+		chainlog.Fatalkv(ctx, chainlog.KeyError, err)
+	}
+	if *rpsToken > 0 || hasTokenLimits {
+		opts = append(opts, core.TokenRateLimit(2*(*rpsToken), *rpsToken))
 	}
 	if *rpsRemoteAddr > 0 {
 		opts = append(opts, core.RateLimit(limit.RemoteAddrID, 2*(*rpsRemoteAddr), *rpsRemoteAddr))
@@ -372,8 +393,16 @@ func launchConfiguredCore(ctx context.Context, confOpts *config.Options, sdb *si
 			signers = append(signers, signer)
 		}
 		c.MaxIssuanceWindow = bc.MillisDuration(conf.MaxIssuanceWindowMs)
+		c.MaxIssuanceSkew = *maxIssueSkew
+		c.BlockSizeLimit = *sizeLimit
 
 		gen := generator.New(c, signers, db)
+		poolLimits := generator.PoolLimits{MaxTxs: *poolMaxTxs, MaxBytes: *poolMaxBytes}
+		if *poolEvict {
+			poolLimits.Policy = generator.EvictOldest
+		}
+		gen.SetPoolLimits(poolLimits)
+		gen.SetEmptyBlockInterval(*emptyInterval)
 		opts = append(opts, core.GeneratorLocal(gen))
 	} else {
 		opts = append(opts, core.GeneratorRemote(&rpc.Client{
@@ -71,10 +71,7 @@ func main() {
 			fatalf("error decoding: %s", err)
 		}
 
-		// The struct doesn't have the hash, so calculate it and print it
-		// before pretty printing the header.
-		fmt.Printf("Block Hash: %x\n", bh.Hash().Bytes())
-		prettyPrint(bh)
+		prettyPrint((*legacy.JSONBlockHeader)(&bh))
 	case "block":
 		b := make([]byte, len(data)/2)
 		_, err := hex.Decode(b, data)
@@ -88,10 +85,7 @@ func main() {
 			fatalf("error decoding: %s", err)
 		}
 
-		// The struct doesn't have the hash, so calculate it and print it
-		// before pretty printing the block
-		fmt.Printf("Block Hash: %x\n", block.Hash().Bytes())
-		prettyPrint(block)
+		prettyPrint((*legacy.JSONBlock)(&block))
 	case "script":
 		b := make([]byte, len(data)/2)
 		_, err := hex.Decode(b, data)
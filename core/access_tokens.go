@@ -13,8 +13,12 @@ import (
 
 var errCurrentToken = errors.New("token cannot delete itself")
 
-func (a *API) createAccessToken(ctx context.Context, x struct{ ID, Type string }) (*accesstoken.Token, error) {
-	token, err := a.accessTokens.Create(ctx, x.ID, x.Type)
+func (a *API) createAccessToken(ctx context.Context, x struct {
+	ID     string
+	Type   string
+	Scopes []string
+}) (*accesstoken.Token, error) {
+	token, err := a.accessTokens.Create(ctx, x.ID, x.Type, x.Scopes)
 	if err != nil {
 		return nil, errors.Wrap(err)
 	}
@@ -37,10 +41,14 @@ func (a *API) createAccessToken(ctx context.Context, x struct{ ID, Type string }
 	// Type field will create a grant associated with this new token.
 	switch x.Type {
 	case "client":
+		policy := "client-readwrite"
+		if token.HasScope(accesstoken.ScopeReadOnly) {
+			policy = "client-readonly"
+		}
 		grant = &authz.Grant{
 			GuardType: "access_token",
 			GuardData: guardData,
-			Policy:    "client-readwrite",
+			Policy:    policy,
 		}
 	case "network":
 		grant = &authz.Grant{
@@ -83,6 +91,27 @@ func (a *API) listAccessTokens(ctx context.Context, x requestQuery) (*page, erro
 	}, nil
 }
 
+func (a *API) updateAccessTokenLimit(ctx context.Context, x struct {
+	ID        string `json:"id"`
+	PerSecond int    `json:"requests_per_second"`
+	Burst     int    `json:"burst"`
+}) error {
+	err := a.accessTokens.SetLimit(ctx, x.ID, x.PerSecond, x.Burst)
+	if err != nil {
+		return errors.Wrap(err)
+	}
+	if a.tokenLimiter == nil {
+		// cored installs the token limiter at startup
+		// only if there are limits to apply.
+		log.Printkv(ctx, "at", "access token limit saved; it applies once cored restarts", "token", x.ID)
+		return nil
+	}
+	// Other Core processes pick up the new
+	// limit when their cached limit expires.
+	a.tokenLimiter.Forget(x.ID)
+	return nil
+}
+
 func (a *API) deleteAccessToken(ctx context.Context, x struct{ ID string }) error {
 	currentID, _, _ := httpjson.Request(ctx).BasicAuth()
 	if currentID == x.ID {
@@ -12,6 +12,8 @@ import (
 	"regexp"
 	"time"
 
+	"github.com/lib/pq"
+
 	"chain/crypto/sha3pool"
 	"chain/database/pg"
 	"chain/errors"
@@ -20,6 +22,10 @@ import (
 const (
 	tokenSize    = 32
 	defaultLimit = 100
+
+	// ScopeReadOnly restricts an access token to routes
+	// that do not modify the state of the Core.
+	ScopeReadOnly = "read-only"
 )
 
 var (
@@ -29,6 +35,10 @@ var (
 	ErrDuplicateID = errors.New("duplicate access token ID")
 	// ErrBadType is returned when Create is called with a bad type.
 	ErrBadType = errors.New("type must be client or network")
+	// ErrBadScope is returned when Create is called with an unknown scope.
+	ErrBadScope = errors.New("invalid access token scope")
+	// ErrBadLimit is returned when SetLimit is called with a negative limit.
+	ErrBadLimit = errors.New("invalid access token rate limit")
 
 	// validIDRegexp checks that all characters are alphumeric, _ or -.
 	// It also must have a length of at least 1.
@@ -39,19 +49,43 @@ type Token struct {
 	ID      string    `json:"id"`
 	Token   string    `json:"token,omitempty"`
 	Type    string    `json:"type,omitempty"` // deprecated in 1.2
+	Scopes  []string  `json:"scopes,omitempty"`
+	Limit   *Limit    `json:"rate_limit,omitempty"`
 	Created time.Time `json:"created_at"`
 	sortID  string
 }
 
+// Limit is a request rate limit set on an individual access token.
+type Limit struct {
+	PerSecond int `json:"requests_per_second"`
+	Burst     int `json:"burst"`
+}
+
+// HasScope returns whether the token carries the given scope.
+func (t *Token) HasScope(scope string) bool {
+	for _, s := range t.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
 type CredentialStore struct {
 	DB pg.DB
 }
 
 // Create generates a new access token with the given ID.
-func (cs *CredentialStore) Create(ctx context.Context, id, typ string) (*Token, error) {
+// The token may optionally be restricted by one or more scopes.
+func (cs *CredentialStore) Create(ctx context.Context, id, typ string, scopes []string) (*Token, error) {
 	if !validIDRegexp.MatchString(id) {
 		return nil, errors.WithDetailf(ErrBadID, "invalid id %q", id)
 	}
+	for _, scope := range scopes {
+		if scope != ScopeReadOnly {
+			return nil, errors.WithDetailf(ErrBadScope, "unknown scope %q", scope)
+		}
+	}
 
 	var secret [tokenSize]byte
 	_, err := rand.Read(secret[:])
@@ -62,8 +96,8 @@ func (cs *CredentialStore) Create(ctx context.Context, id, typ string) (*Token,
 	sha3pool.Sum256(hashedSecret[:], secret[:])
 
 	const q = `
-		INSERT INTO access_tokens (id, type, hashed_secret)
-		VALUES($1, $2, $3)
+		INSERT INTO access_tokens (id, type, hashed_secret, scopes)
+		VALUES($1, $2, $3, COALESCE($4::text[], '{}'))
 		RETURNING created, sort_id
 	`
 	var (
@@ -71,7 +105,7 @@ func (cs *CredentialStore) Create(ctx context.Context, id, typ string) (*Token,
 		sortID    string
 		maybeType = sql.NullString{String: typ, Valid: typ != ""}
 	)
-	err = cs.DB.QueryRowContext(ctx, q, id, maybeType, hashedSecret[:]).Scan(&created, &sortID)
+	err = cs.DB.QueryRowContext(ctx, q, id, maybeType, hashedSecret[:], pq.StringArray(scopes)).Scan(&created, &sortID)
 	if pg.IsUniqueViolation(err) {
 		return nil, errors.WithDetailf(ErrDuplicateID, "id %q already in use", id)
 	}
@@ -83,6 +117,7 @@ func (cs *CredentialStore) Create(ctx context.Context, id, typ string) (*Token,
 		ID:      id,
 		Token:   fmt.Sprintf("%s:%x", id, secret),
 		Type:    typ,
+		Scopes:  scopes,
 		Created: created,
 		sortID:  sortID,
 	}, nil
@@ -90,6 +125,16 @@ func (cs *CredentialStore) Create(ctx context.Context, id, typ string) (*Token,
 
 // Check returns whether or not an id-secret pair is a valid access token.
 func (cs *CredentialStore) Check(ctx context.Context, id string, secret []byte) (bool, error) {
+	token, err := cs.Lookup(ctx, id, secret)
+	if err != nil {
+		return false, err
+	}
+	return token != nil, nil
+}
+
+// Lookup returns the access token for an id-secret pair, without
+// its secret. It returns nil if the pair is not a valid access token.
+func (cs *CredentialStore) Lookup(ctx context.Context, id string, secret []byte) (*Token, error) {
 	var (
 		toHash [tokenSize]byte
 		hashed [32]byte
@@ -97,14 +142,25 @@ func (cs *CredentialStore) Check(ctx context.Context, id string, secret []byte)
 	copy(toHash[:], secret)
 	sha3pool.Sum256(hashed[:], toHash[:])
 
-	const q = `SELECT EXISTS(SELECT 1 FROM access_tokens WHERE id=$1 AND hashed_secret=$2)`
-	var valid bool
-	err := cs.DB.QueryRowContext(ctx, q, id, hashed[:]).Scan(&valid)
+	const q = `
+		SELECT type, scopes, sort_id, created FROM access_tokens
+		WHERE id=$1 AND hashed_secret=$2
+	`
+	var (
+		maybeType sql.NullString
+		scopes    pq.StringArray
+		token     = Token{ID: id}
+	)
+	err := cs.DB.QueryRowContext(ctx, q, id, hashed[:]).Scan(&maybeType, &scopes, &token.sortID, &token.Created)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
 	if err != nil {
-		return false, err
+		return nil, err
 	}
-
-	return valid, nil
+	token.Type = maybeType.String
+	token.Scopes = scopes
+	return &token, nil
 }
 
 // Exists returns whether an id is part of a valid access token. It does not validate a secret.
@@ -124,19 +180,23 @@ func (cs *CredentialStore) List(ctx context.Context, typ, after string, limit in
 		limit = defaultLimit
 	}
 	const q = `
-		SELECT id, type, sort_id, created FROM access_tokens
+		SELECT id, type, scopes, rate_limit, rate_burst, sort_id, created FROM access_tokens
 		WHERE ($1='' OR type=$1::access_token_type) AND ($2='' OR sort_id<$2)
 		ORDER BY sort_id DESC
 		LIMIT $3
 	`
 	var tokens []*Token
-	err := pg.ForQueryRows(ctx, cs.DB, q, typ, after, limit, func(id string, maybeType sql.NullString, sortID string, created time.Time) {
+	err := pg.ForQueryRows(ctx, cs.DB, q, typ, after, limit, func(id string, maybeType sql.NullString, scopes pq.StringArray, rateLimit, rateBurst sql.NullInt64, sortID string, created time.Time) {
 		t := Token{
 			ID:      id,
 			Created: created,
 			Type:    maybeType.String,
+			Scopes:  scopes,
 			sortID:  sortID,
 		}
+		if rateLimit.Valid {
+			t.Limit = &Limit{PerSecond: int(rateLimit.Int64), Burst: int(rateBurst.Int64)}
+		}
 		tokens = append(tokens, &t)
 	})
 	if err != nil {
@@ -151,6 +211,62 @@ func (cs *CredentialStore) List(ctx context.Context, typ, after string, limit in
 	return tokens, next, nil
 }
 
+// SetLimit sets the request rate limit for the access token
+// with the given id, overriding the Core's default limit.
+// If burst is 0, it defaults to twice perSecond.
+// A perSecond of 0 removes the override.
+func (cs *CredentialStore) SetLimit(ctx context.Context, id string, perSecond, burst int) error {
+	if perSecond < 0 || burst < 0 {
+		return errors.WithDetailf(ErrBadLimit, "requests per second and burst must not be negative")
+	}
+	var rateLimit, rateBurst sql.NullInt64
+	if perSecond > 0 {
+		if burst == 0 {
+			burst = 2 * perSecond
+		}
+		rateLimit = sql.NullInt64{Int64: int64(perSecond), Valid: true}
+		rateBurst = sql.NullInt64{Int64: int64(burst), Valid: true}
+	}
+
+	const q = `UPDATE access_tokens SET rate_limit=$2, rate_burst=$3 WHERE id=$1`
+	res, err := cs.DB.ExecContext(ctx, q, id, rateLimit, rateBurst)
+	if err != nil {
+		return errors.Wrap(err)
+	}
+	updated, err := res.RowsAffected()
+	if err != nil {
+		return errors.Wrap(err)
+	}
+	if updated == 0 {
+		return errors.WithDetailf(pg.ErrUserInputNotFound, "access token id %s", id)
+	}
+	return nil
+}
+
+// GetLimit returns the request rate limit set for the access
+// token with the given id, or nil if it has none.
+func (cs *CredentialStore) GetLimit(ctx context.Context, id string) (*Limit, error) {
+	const q = `SELECT rate_limit, rate_burst FROM access_tokens WHERE id=$1`
+	var rateLimit, rateBurst sql.NullInt64
+	err := cs.DB.QueryRowContext(ctx, q, id).Scan(&rateLimit, &rateBurst)
+	if err == sql.ErrNoRows || (err == nil && !rateLimit.Valid) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err)
+	}
+	return &Limit{PerSecond: int(rateLimit.Int64), Burst: int(rateBurst.Int64)}, nil
+}
+
+// HasLimits reports whether any access token
+// has a request rate limit set.
+func (cs *CredentialStore) HasLimits(ctx context.Context) (bool, error) {
+	const q = `SELECT EXISTS(SELECT 1 FROM access_tokens WHERE rate_limit IS NOT NULL)`
+	var ok bool
+	err := cs.DB.QueryRowContext(ctx, q).Scan(&ok)
+	return ok, errors.Wrap(err)
+}
+
 // Delete deletes an access token by id.
 func (cs *CredentialStore) Delete(ctx context.Context, id string) error {
 	const q = `DELETE FROM access_tokens WHERE id=$1`
@@ -29,7 +29,7 @@ func TestCreate(t *testing.T) {
 	}
 
 	for _, c := range cases {
-		_, err := cs.Create(ctx, c.id, c.net)
+		_, err := cs.Create(ctx, c.id, c.net, nil)
 		if errors.Root(err) != c.want {
 			t.Errorf("Create(%s, %s) error = %s want %s", c.id, c.net, err, c.want)
 		}
@@ -142,8 +142,66 @@ func TestDelete(t *testing.T) {
 	}
 }
 
+func TestSetLimit(t *testing.T) {
+	ctx := context.Background()
+	cs := &CredentialStore{DB: pgtest.NewTx(t)}
+	mustCreateToken(t, ctx, cs, "x", "client")
+
+	got, err := cs.GetLimit(ctx, "x")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != nil {
+		t.Errorf("GetLimit(x) = %+v want nil", got)
+	}
+
+	err = cs.SetLimit(ctx, "x", 5, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err = cs.GetLimit(ctx, "x")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &Limit{PerSecond: 5, Burst: 10}
+	if !testutil.DeepEqual(got, want) {
+		t.Errorf("GetLimit(x) = %+v want %+v", got, want)
+	}
+	hasLimits, err := cs.HasLimits(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !hasLimits {
+		t.Error("HasLimits() = false want true")
+	}
+
+	err = cs.SetLimit(ctx, "x", 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err = cs.GetLimit(ctx, "x")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != nil {
+		t.Errorf("GetLimit(x) after removing = %+v want nil", got)
+	}
+	hasLimits, err = cs.HasLimits(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hasLimits {
+		t.Error("HasLimits() after removing = true want false")
+	}
+
+	err = cs.SetLimit(ctx, "x", -1, 0)
+	if errors.Root(err) != ErrBadLimit {
+		t.Errorf("SetLimit(x, -1) error = %v want %v", err, ErrBadLimit)
+	}
+}
+
 func mustCreateToken(t *testing.T, ctx context.Context, cs *CredentialStore, id, typ string) *Token {
-	token, err := cs.Create(ctx, id, typ)
+	token, err := cs.Create(ctx, id, typ, nil)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -2,9 +2,11 @@
 package account
 
 import (
+	"bytes"
 	"context"
 	stdsql "database/sql"
 	"encoding/json"
+	"sort"
 	"sync"
 	"time"
 
@@ -19,6 +21,8 @@ import (
 	"chain/errors"
 	"chain/log"
 	"chain/protocol"
+	"chain/protocol/bc"
+	"chain/protocol/bc/legacy"
 	"chain/protocol/vm/vmutil"
 )
 
@@ -27,6 +31,7 @@ const maxAccountCache = 1000
 var (
 	ErrDuplicateAlias = errors.New("duplicate account alias")
 	ErrBadIdentifier  = errors.New("either ID or alias must be specified, and not both")
+	ErrNonzeroBalance = errors.New("account has a nonzero balance")
 )
 
 func NewManager(db pg.DB, chain *protocol.Chain, pinStore *pin.Store) *Manager {
@@ -36,8 +41,8 @@ func NewManager(db pg.DB, chain *protocol.Chain, pinStore *pin.Store) *Manager {
 		utxoDB:      newReserver(db, chain, pinStore),
 		pinStore:    pinStore,
 		cache:       lru.New(maxAccountCache),
-		aliasCache:  lru.New(maxAccountCache),
 		delayedACPs: make(map[*txbuilder.TemplateBuilder][]*controlProgram),
+		now:         time.Now,
 	}
 }
 
@@ -49,9 +54,8 @@ type Manager struct {
 	indexer  Saver
 	pinStore *pin.Store
 
-	cacheMu    sync.Mutex
-	cache      *lru.Cache
-	aliasCache *lru.Cache
+	cacheMu sync.Mutex
+	cache   *lru.Cache // account ID -> *signers.Signer
 
 	delayedACPsMu sync.Mutex
 	delayedACPs   map[*txbuilder.TemplateBuilder][]*controlProgram
@@ -59,6 +63,9 @@ type Manager struct {
 	acpMu        sync.Mutex
 	acpIndexNext uint64 // next acp index in our block
 	acpIndexCap  uint64 // points to end of block
+
+	maxUnusedAge time.Duration    // see SetMaxUnusedAge
+	now          func() time.Time // for testing
 }
 
 func (m *Manager) IndexAccounts(indexer Saver) {
@@ -83,6 +90,71 @@ func (m *Manager) ExpireReservations(ctx context.Context, period time.Duration)
 	}
 }
 
+// CancelReservation releases the outputs held by the reservation
+// with the provided ID, and reports whether there was such a
+// reservation. Canceling a reservation that has already expired
+// or doesn't exist does nothing.
+func (m *Manager) CancelReservation(ctx context.Context, rid uint64) bool {
+	// Cancel only fails when there's no such reservation.
+	return m.utxoDB.Cancel(ctx, rid) == nil
+}
+
+// Reservation describes a reservation of account outputs.
+type Reservation struct {
+	ID        uint64     `json:"id"`
+	AccountID string     `json:"account_id"`
+	AssetID   bc.AssetID `json:"asset_id"`
+	Amount    uint64     `json:"amount"`
+	Outputs   int        `json:"output_count"`
+	Expiry    time.Time  `json:"expiry"`
+}
+
+// ListReservations describes the reservations currently held by
+// this process, ordered by ID. Amount is the total of the reserved
+// outputs, including any part that will come back as change.
+func (m *Manager) ListReservations() []*Reservation {
+	var list []*Reservation
+	for _, res := range m.utxoDB.list() {
+		r := &Reservation{
+			ID:        res.ID,
+			AccountID: res.Source.AccountID,
+			AssetID:   res.Source.AssetID,
+			Outputs:   len(res.UTXOs),
+			Expiry:    res.Expiry,
+		}
+		for _, u := range res.UTXOs {
+			r.Amount += u.Amount
+		}
+		list = append(list, r)
+	}
+	return list
+}
+
+// CancelTemplateReservations releases the outputs reserved while
+// building tpl, using the reservation IDs in its signing
+// instructions, and returns the number of reservations canceled.
+// A reservation that no longer holds the output spent by its input
+// has already expired, and is left alone.
+func (m *Manager) CancelTemplateReservations(ctx context.Context, tpl *txbuilder.Template) int {
+	if tpl.Transaction == nil {
+		return 0
+	}
+	var n int
+	for _, sigInst := range tpl.SigningInstructions {
+		if sigInst.ReservationID == 0 || int(sigInst.Position) >= len(tpl.Transaction.Inputs) {
+			continue
+		}
+		outputID, err := tpl.Transaction.Inputs[sigInst.Position].SpentOutputID()
+		if err != nil {
+			continue // not a spend
+		}
+		if m.utxoDB.CancelHolding(ctx, sigInst.ReservationID, outputID) {
+			n++
+		}
+	}
+	return n
+}
+
 type Account struct {
 	*signers.Signer
 	Alias string
@@ -191,27 +263,220 @@ func (m *Manager) UpdateTags(ctx context.Context, id, alias *string, tags map[st
 	}), "update account index")
 }
 
-// FindByAlias retrieves an account's Signer record by its alias
-func (m *Manager) FindByAlias(ctx context.Context, alias string) (*signers.Signer, error) {
-	var accountID string
+// UpdateAlias changes the alias of the specified account to newAlias,
+// or removes it if newAlias is empty. The account may be identified
+// either by ID or Alias, but not both.
+func (m *Manager) UpdateAlias(ctx context.Context, id, alias *string, newAlias string) error {
+	if (id == nil) == (alias == nil) {
+		return errors.Wrap(ErrBadIdentifier)
+	}
+
+	var (
+		signer *signers.Signer
+		err    error
+	)
+	if id != nil {
+		signer, err = m.findByID(ctx, *id)
+		if err != nil {
+			return errors.Wrap(err, "get account by ID")
+		}
+	} else {
+		signer, err = m.FindByAlias(ctx, *alias)
+		if err != nil {
+			return errors.Wrap(err, "get account by alias")
+		}
+	}
+
+	aliasSQL := stdsql.NullString{
+		String: newAlias,
+		Valid:  newAlias != "",
+	}
+
+	const q = `
+		UPDATE accounts SET alias = $1 WHERE account_id = $2
+		RETURNING tags
+	`
+	var tagsBlob []byte
+	err = m.db.QueryRowContext(ctx, q, aliasSQL, signer.ID).Scan(&tagsBlob)
+	if pg.IsUniqueViolation(err) {
+		return errors.WithDetail(ErrDuplicateAlias, "an account with the provided alias already exists")
+	} else if err != nil {
+		return errors.Wrap(err, "update entry in accounts table")
+	}
+
+	var tags map[string]interface{}
+	if len(tagsBlob) > 0 {
+		err = json.Unmarshal(tagsBlob, &tags)
+		if err != nil {
+			return errors.Wrap(err, "unmarshal tags")
+		}
+	}
+	return errors.Wrap(m.indexAnnotatedAccount(ctx, &Account{
+		Signer: signer,
+		Alias:  newAlias,
+		Tags:   tags,
+	}), "update account index")
+}
+
+// assetBalance is an amount of an asset held by an account.
+type assetBalance struct {
+	AssetID bc.AssetID `json:"asset_id"`
+	Amount  uint64     `json:"amount"`
+}
+
+// Delete removes the account with the provided ID or alias. It
+// fails with ErrReserved if a reservation holds any of the
+// account's outputs, and with ErrNonzeroBalance if the account has
+// confirmed outputs or is paid by any transaction in pool, listing
+// the nonzero balances in the error's "balances" data.
+//
+// The account's signer, alias and tags are archived in
+// deleted_accounts. Its control programs are kept, so a payment the
+// balance check couldn't see, such as one submitted after pool was
+// taken, is still indexed under the account's ID and shows up in
+// its balances. Account IDs are never issued twice, so the ID can't
+// come back. Transactions annotated with the account keep their
+// annotations.
+func (m *Manager) Delete(ctx context.Context, id, alias *string, pool []*legacy.Tx) error {
+	if (id == nil) == (alias == nil) {
+		return errors.Wrap(ErrBadIdentifier)
+	}
+
+	var (
+		signer *signers.Signer
+		err    error
+	)
+	if id != nil {
+		signer, err = m.findByID(ctx, *id)
+		if err != nil {
+			return errors.Wrap(err, "get account by ID")
+		}
+	} else {
+		signer, err = m.FindByAlias(ctx, *alias)
+		if err != nil {
+			return errors.Wrap(err, "get account by alias")
+		}
+	}
+
+	if m.utxoDB.holdsAccount(signer.ID) {
+		return errors.WithDetail(ErrReserved, "the account has reserved outputs")
+	}
+	balances, err := m.balances(ctx, signer.ID, pool)
+	if err != nil {
+		return err
+	}
+	if len(balances) > 0 {
+		return errors.WithData(ErrNonzeroBalance, "balances", balances)
+	}
+
+	// Archiving is conditioned on the account still having no
+	// outputs, in case one was indexed since the check above.
+	const q = `
+		WITH archived AS (
+			INSERT INTO deleted_accounts (account_id, alias, tags, xpubs, quorum, key_index)
+			SELECT s.id, a.alias, a.tags, s.xpubs, s.quorum, s.key_index
+			FROM signers s JOIN accounts a ON a.account_id = s.id
+			WHERE s.id = $1 AND NOT EXISTS (SELECT 1 FROM account_utxos WHERE account_id = $1)
+			RETURNING account_id
+		), accts AS (
+			DELETE FROM accounts WHERE account_id IN (SELECT account_id FROM archived)
+		), signer AS (
+			DELETE FROM signers WHERE id IN (SELECT account_id FROM archived)
+		)
+		SELECT account_id FROM archived
+	`
+	var archivedID string
+	err = m.db.QueryRowContext(ctx, q, signer.ID).Scan(&archivedID)
+	if err == stdsql.ErrNoRows {
+		balances, err = m.balances(ctx, signer.ID, pool)
+		if err != nil {
+			return err
+		}
+		if len(balances) > 0 {
+			return errors.WithData(ErrNonzeroBalance, "balances", balances)
+		}
+		return errors.Wrap(pg.ErrUserInputNotFound)
+	} else if err != nil {
+		return errors.Wrap(err, "delete account")
+	}
 
 	m.cacheMu.Lock()
-	cachedID, ok := m.aliasCache.Get(alias)
+	m.cache.Remove(signer.ID)
 	m.cacheMu.Unlock()
-	if ok {
-		accountID = cachedID.(string)
-	} else {
-		const q = `SELECT account_id FROM accounts WHERE alias=$1`
-		err := m.db.QueryRowContext(ctx, q, alias).Scan(&accountID)
-		if err == stdsql.ErrNoRows {
-			return nil, errors.WithDetailf(pg.ErrUserInputNotFound, "alias: %s", alias)
+
+	if m.indexer == nil {
+		return nil
+	}
+	return errors.Wrap(m.indexer.DeleteAnnotatedAccount(ctx, signer.ID), "update account index")
+}
+
+// balances returns the account's nonzero balances, counting its
+// confirmed outputs and the outputs paying it in pool, ordered by
+// asset ID.
+func (m *Manager) balances(ctx context.Context, accountID string, pool []*legacy.Tx) ([]assetBalance, error) {
+	sums := make(map[bc.AssetID]uint64)
+	const q = `
+		SELECT asset_id, SUM(amount) FROM account_utxos
+		WHERE account_id = $1 GROUP BY asset_id
+	`
+	err := pg.ForQueryRows(ctx, m.db, q, accountID, func(assetID bc.AssetID, amount uint64) {
+		sums[assetID] += amount
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "summing account utxos")
+	}
+
+	var programs pq.ByteaArray
+	for _, tx := range pool {
+		for _, out := range tx.Outputs {
+			programs = append(programs, out.ControlProgram)
 		}
+	}
+	if len(programs) > 0 {
+		ours := make(map[string]bool)
+		const progQ = `
+			SELECT control_program FROM account_control_programs
+			WHERE signer_id = $1 AND control_program = ANY($2::bytea[])
+		`
+		err = pg.ForQueryRows(ctx, m.db, progQ, accountID, programs, func(program []byte) {
+			ours[string(program)] = true
+		})
 		if err != nil {
-			return nil, errors.Wrap(err)
+			return nil, errors.Wrap(err, "matching pool outputs")
+		}
+		for _, tx := range pool {
+			for _, out := range tx.Outputs {
+				if ours[string(out.ControlProgram)] {
+					sums[*out.AssetId] += out.Amount
+				}
+			}
 		}
-		m.cacheMu.Lock()
-		m.aliasCache.Add(alias, accountID)
-		m.cacheMu.Unlock()
+	}
+
+	var balances []assetBalance
+	for assetID, amount := range sums {
+		if amount > 0 {
+			balances = append(balances, assetBalance{AssetID: assetID, Amount: amount})
+		}
+	}
+	sort.Slice(balances, func(i, j int) bool {
+		return bytes.Compare(balances[i].AssetID.Bytes(), balances[j].AssetID.Bytes()) < 0
+	})
+	return balances, nil
+}
+
+// FindByAlias retrieves an account's Signer record by its alias.
+// Aliases can be changed by any Core process, so the alias is always
+// looked up in the database.
+func (m *Manager) FindByAlias(ctx context.Context, alias string) (*signers.Signer, error) {
+	var accountID string
+	const q = `SELECT account_id FROM accounts WHERE alias=$1`
+	err := m.db.QueryRowContext(ctx, q, alias).Scan(&accountID)
+	if err == stdsql.ErrNoRows {
+		return nil, errors.WithDetailf(pg.ErrUserInputNotFound, "alias: %s", alias)
+	}
+	if err != nil {
+		return nil, errors.Wrap(err)
 	}
 	return m.findByID(ctx, accountID)
 }
@@ -285,9 +550,9 @@ func (m *Manager) CreateControlProgram(ctx context.Context, accountID string, ch
 
 func (m *Manager) insertAccountControlProgram(ctx context.Context, progs ...*controlProgram) error {
 	const q = `
-		INSERT INTO account_control_programs (signer_id, key_index, control_program, change, expires_at)
+		INSERT INTO account_control_programs (signer_id, key_index, control_program, change, expires_at, created_at)
 		SELECT unnest($1::text[]), unnest($2::bigint[]), unnest($3::bytea[]), unnest($4::boolean[]),
-			unnest($5::timestamp with time zone[])
+			unnest($5::timestamp with time zone[]), $6::timestamp with time zone
 	`
 	var (
 		accountIDs   pq.StringArray
@@ -307,7 +572,7 @@ func (m *Manager) insertAccountControlProgram(ctx context.Context, progs ...*con
 		})
 	}
 
-	_, err := m.db.ExecContext(ctx, q, accountIDs, keyIndexes, controlProgs, change, pq.Array(expirations))
+	_, err := m.db.ExecContext(ctx, q, accountIDs, keyIndexes, controlProgs, change, pq.Array(expirations), m.now())
 	return errors.Wrap(err)
 }
 
@@ -7,10 +7,13 @@ import (
 	"testing"
 	"time"
 
+	"chain/core/query"
 	"chain/crypto/ed25519/chainkd"
+	"chain/database/pg"
 	"chain/database/pg/pgtest"
 	"chain/errors"
 	"chain/protocol/bc"
+	"chain/protocol/bc/legacy"
 	"chain/protocol/prottest"
 	"chain/protocol/vm"
 	"chain/testutil"
@@ -180,3 +183,176 @@ func TestFindByAlias(t *testing.T) {
 		t.Errorf("expected found account to be %v, instead found %v", account, found)
 	}
 }
+
+func TestUpdateAlias(t *testing.T) {
+	db := pgtest.NewTx(t)
+	c := prottest.NewChain(t)
+	m := NewManager(db, c, nil)
+	m.IndexAccounts(query.NewIndexer(db, c, nil))
+	ctx := context.Background()
+	account := m.createTestAccount(ctx, t, "old-alias", nil)
+	m.createTestAccount(ctx, t, "taken-alias", nil)
+
+	// Look the account up by its old alias in this process
+	// and rename it in another one.
+	_, err := m.FindByAlias(ctx, "old-alias")
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+
+	other := NewManager(db, c, nil)
+	other.IndexAccounts(query.NewIndexer(db, c, nil))
+	oldAlias := "old-alias"
+	err = other.UpdateAlias(ctx, nil, &oldAlias, "new-alias")
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	found, err := m.FindByAlias(ctx, "new-alias")
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	if found.ID != account.ID {
+		t.Errorf("FindByAlias(new-alias) = %s want %s", found.ID, account.ID)
+	}
+	var indexed string
+	err = db.QueryRowContext(ctx, `SELECT alias FROM annotated_accounts WHERE id=$1`, account.ID).Scan(&indexed)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	if indexed != "new-alias" {
+		t.Errorf("indexed alias = %q want %q", indexed, "new-alias")
+	}
+	_, err = m.FindByAlias(ctx, "old-alias")
+	if errors.Root(err) != pg.ErrUserInputNotFound {
+		t.Errorf("FindByAlias(old-alias) error = %v want %v", err, pg.ErrUserInputNotFound)
+	}
+
+	err = m.UpdateAlias(ctx, &account.ID, nil, "taken-alias")
+	if errors.Root(err) != ErrDuplicateAlias {
+		t.Errorf("renaming to a taken alias: got error %v, want %v", err, ErrDuplicateAlias)
+	}
+
+	err = m.UpdateAlias(ctx, &account.ID, &oldAlias, "other-alias")
+	if errors.Root(err) != ErrBadIdentifier {
+		t.Errorf("renaming by ID and alias: got error %v, want %v", err, ErrBadIdentifier)
+	}
+}
+
+func TestDeleteAccount(t *testing.T) {
+	db := pgtest.NewTx(t)
+	c := prottest.NewChain(t)
+	m := NewManager(db, c, nil)
+	m.IndexAccounts(query.NewIndexer(db, c, nil))
+	ctx := context.Background()
+
+	empty := m.createTestAccount(ctx, t, "empty", nil)
+	emptyProgram := m.createTestControlProgram(ctx, t, empty.ID).controlProgram
+	_, err := m.FindByAlias(ctx, "empty") // cache the alias
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+
+	alias := "empty"
+	err = m.Delete(ctx, nil, &alias, nil)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	_, err = m.findByID(ctx, empty.ID)
+	if errors.Root(err) != pg.ErrUserInputNotFound {
+		t.Errorf("findByID after delete: got error %v, want %v", err, pg.ErrUserInputNotFound)
+	}
+	_, err = m.FindByAlias(ctx, "empty")
+	if errors.Root(err) != pg.ErrUserInputNotFound {
+		t.Errorf("FindByAlias after delete: got error %v, want %v", err, pg.ErrUserInputNotFound)
+	}
+	var archived, programs, indexed int
+	const countQ = `
+		SELECT (SELECT COUNT(*) FROM deleted_accounts WHERE account_id = $1 AND alias = 'empty'),
+			(SELECT COUNT(*) FROM account_control_programs WHERE signer_id = $1),
+			(SELECT COUNT(*) FROM annotated_accounts WHERE id = $1)
+	`
+	err = db.QueryRowContext(ctx, countQ, empty.ID).Scan(&archived, &programs, &indexed)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	if archived != 1 || programs != 1 || indexed != 0 {
+		t.Errorf("after delete: %d archived, %d control programs, %d indexed; want 1, 1, 0", archived, programs, indexed)
+	}
+
+	// A payment made after the deletion, such as one submitted
+	// after the pool was checked, is still recorded for the account.
+	late := legacy.NewTx(legacy.TxData{
+		Outputs: []*legacy.TxOutput{legacy.NewTxOutput(bc.AssetID{}, 3, emptyProgram, nil)},
+	})
+	err = m.indexAccountUTXOs(ctx, &legacy.Block{Transactions: []*legacy.Tx{late}})
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	var paidTo string
+	err = db.QueryRowContext(ctx, `SELECT account_id FROM account_utxos WHERE output_id = $1`, late.OutputID(0)).Scan(&paidTo)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	if paidTo != empty.ID {
+		t.Errorf("late payment recorded for account %q want %q", paidTo, empty.ID)
+	}
+	err = m.Delete(ctx, &empty.ID, nil, nil)
+	if errors.Root(err) != pg.ErrUserInputNotFound {
+		t.Errorf("deleting twice: got error %v, want %v", err, pg.ErrUserInputNotFound)
+	}
+
+	// The alias is free again, and the new account gets a new ID.
+	again := m.createTestAccount(ctx, t, "empty", nil)
+	if again.ID == empty.ID {
+		t.Errorf("recreated account reused deleted ID %s", empty.ID)
+	}
+
+	// A funded account can't be deleted; the error lists its
+	// confirmed and pending balances.
+	funded := m.createTestAccount(ctx, t, "", nil)
+	m.createTestUTXO(ctx, t, funded.ID)
+	var confirmedAsset bc.AssetID
+	err = db.QueryRowContext(ctx, `SELECT asset_id FROM account_utxos WHERE account_id = $1`, funded.ID).Scan(&confirmedAsset)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	pendingAsset := bc.AssetID(randHash())
+	pendingProgram := m.createTestControlProgram(ctx, t, funded.ID).controlProgram
+	pool := []*legacy.Tx{legacy.NewTx(legacy.TxData{
+		Outputs: []*legacy.TxOutput{
+			legacy.NewTxOutput(pendingAsset, 7, pendingProgram, nil),
+			legacy.NewTxOutput(pendingAsset, 5, []byte("elsewhere"), nil),
+		},
+	})}
+
+	err = m.Delete(ctx, &funded.ID, nil, pool)
+	if errors.Root(err) != ErrNonzeroBalance {
+		t.Fatalf("deleting funded account: got error %v, want %v", err, ErrNonzeroBalance)
+	}
+	want := []assetBalance{
+		{AssetID: confirmedAsset, Amount: 100},
+		{AssetID: pendingAsset, Amount: 7},
+	}
+	if bytes.Compare(want[0].AssetID.Bytes(), want[1].AssetID.Bytes()) > 0 {
+		want[0], want[1] = want[1], want[0]
+	}
+	got := errors.Data(err)["balances"]
+	if !testutil.DeepEqual(got, want) {
+		t.Errorf("balances = %+v want %+v", got, want)
+	}
+	if _, err := m.findByID(ctx, funded.ID); err != nil {
+		t.Errorf("funded account missing after failed delete: %v", err)
+	}
+
+	// Nor can an account with reserved outputs.
+	reserved := m.createTestAccount(ctx, t, "", nil)
+	m.utxoDB.reservations[1] = &reservation{
+		ID:     1,
+		Source: source{AccountID: reserved.ID},
+		Expiry: time.Now().Add(time.Minute),
+	}
+	err = m.Delete(ctx, &reserved.ID, nil, nil)
+	if errors.Root(err) != ErrReserved {
+		t.Errorf("deleting account with reservations: got error %v, want %v", err, ErrReserved)
+	}
+}
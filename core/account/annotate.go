@@ -4,6 +4,7 @@ import (
 	"context"
 	"database/sql"
 	"encoding/json"
+	"time"
 
 	"github.com/lib/pq"
 
@@ -83,5 +84,49 @@ func (m *Manager) AnnotateTxs(ctx context.Context, txs []*query.AnnotatedTx) err
 				}
 			}
 		})
-	return errors.Wrap(err, "annotating with account data")
+	if err != nil {
+		return errors.Wrap(err, "annotating with account data")
+	}
+	return errors.Wrap(m.annotateExpired(ctx, txs), "annotating expired outputs")
+}
+
+// annotateExpired marks outputs that pay an account control program
+// after its expiration. Such outputs are not accepted by the account,
+// so they carry no account annotations.
+func (m *Manager) annotateExpired(ctx context.Context, txs []*query.AnnotatedTx) error {
+	type candidate struct {
+		out  *query.AnnotatedOutput
+		when time.Time
+	}
+	var (
+		programs   pq.ByteaArray
+		candidates = make(map[string][]candidate)
+	)
+	for _, tx := range txs {
+		for _, out := range tx.Outputs {
+			if out.Type == "retire" || out.AccountID != "" {
+				continue
+			}
+			prog := string(out.ControlProgram)
+			if _, ok := candidates[prog]; !ok {
+				programs = append(programs, out.ControlProgram)
+			}
+			candidates[prog] = append(candidates[prog], candidate{out, tx.Timestamp})
+		}
+	}
+	if len(programs) == 0 {
+		return nil
+	}
+
+	const q = `
+		SELECT control_program, expires_at FROM account_control_programs
+		WHERE control_program = ANY($1::bytea[]) AND expires_at IS NOT NULL
+	`
+	return pg.ForQueryRows(ctx, m.db, q, programs, func(program []byte, expiresAt time.Time) {
+		for _, c := range candidates[string(program)] {
+			if c.when.After(expiresAt) {
+				c.out.Purpose = "expired"
+			}
+		}
+	})
 }
@@ -3,14 +3,17 @@ package account
 import (
 	"context"
 	"encoding/json"
+	"math"
 
 	"chain/core/signers"
 	"chain/core/txbuilder"
+	"chain/crypto/ed25519/chainkd"
 	chainjson "chain/encoding/json"
 	"chain/errors"
 	"chain/log"
 	"chain/protocol/bc"
 	"chain/protocol/bc/legacy"
+	"chain/protocol/vm/vmutil"
 )
 
 func (m *Manager) NewSpendAction(amt bc.AssetAmount, accountID string, refData chainjson.Map, clientToken *string) txbuilder.Action {
@@ -35,6 +38,11 @@ type spendAction struct {
 	AccountID     string        `json:"account_id"`
 	ReferenceData chainjson.Map `json:"reference_data"`
 	ClientToken   *string       `json:"client_token"`
+
+	// Selection is the strategy for choosing which UTXOs to
+	// spend: one of the Select constants, or empty for no
+	// particular order.
+	Selection string `json:"selection"`
 }
 
 func (a *spendAction) Build(ctx context.Context, b *txbuilder.TemplateBuilder) error {
@@ -58,7 +66,7 @@ func (a *spendAction) Build(ctx context.Context, b *txbuilder.TemplateBuilder) e
 		AssetID:   *a.AssetId,
 		AccountID: a.AccountID,
 	}
-	res, err := a.accounts.utxoDB.Reserve(ctx, src, a.Amount, a.ClientToken, b.MaxTime())
+	res, err := a.accounts.utxoDB.Reserve(ctx, src, a.Amount, a.Selection, a.ClientToken, b.MaxTime())
 	if err != nil {
 		return errors.Wrap(err, "reserving utxos")
 	}
@@ -71,6 +79,7 @@ func (a *spendAction) Build(ctx context.Context, b *txbuilder.TemplateBuilder) e
 		if err != nil {
 			return errors.Wrap(err, "creating inputs")
 		}
+		sigInst.ReservationID = res.ID
 		err = b.AddInput(txInput, sigInst)
 		if err != nil {
 			return errors.Wrap(err, "adding inputs")
@@ -134,6 +143,7 @@ func (a *spendUTXOAction) Build(ctx context.Context, b *txbuilder.TemplateBuilde
 	if err != nil {
 		return err
 	}
+	sigInst.ReservationID = res.ID
 	return b.AddInput(txInput, sigInst)
 }
 
@@ -234,3 +244,119 @@ func (m *Manager) insertControlProgramDelayed(ctx context.Context, b *txbuilder.
 		return m.insertAccountControlProgram(ctx, acps...)
 	})
 }
+
+const (
+	// defaultConsolidateUTXOs is how many UTXOs a consolidation
+	// combines when it doesn't say.
+	defaultConsolidateUTXOs = 100
+
+	// maxConsolidateTxSize bounds the estimated size of a signed
+	// consolidation transaction. It limits how many UTXOs one
+	// consolidation combines, whatever it asks for.
+	maxConsolidateTxSize = 100 << 10
+)
+
+func (m *Manager) NewConsolidateAction(assetID bc.AssetID, accountID string, maxUTXOs int, clientToken *string) txbuilder.Action {
+	return &consolidateAction{
+		accounts:    m,
+		AssetID:     &assetID,
+		AccountID:   accountID,
+		MaxUTXOs:    maxUTXOs,
+		ClientToken: clientToken,
+	}
+}
+
+func (m *Manager) DecodeConsolidateAction(data []byte) (txbuilder.Action, error) {
+	a := &consolidateAction{accounts: m}
+	err := json.Unmarshal(data, a)
+	return a, err
+}
+
+// consolidateAction spends up to MaxUTXOs of the account's
+// smallest unreserved UTXOs of an asset and pays their total to
+// a new control program of the same account.
+type consolidateAction struct {
+	accounts    *Manager
+	AssetID     *bc.AssetID `json:"asset_id"`
+	AccountID   string      `json:"account_id"`
+	MaxUTXOs    int         `json:"max_utxos"`
+	ClientToken *string     `json:"client_token"`
+}
+
+func (a *consolidateAction) Build(ctx context.Context, b *txbuilder.TemplateBuilder) error {
+	var missing []string
+	if a.AccountID == "" {
+		missing = append(missing, "account_id")
+	}
+	if a.AssetID == nil || a.AssetID.IsZero() {
+		missing = append(missing, "asset_id")
+	}
+	if len(missing) > 0 {
+		return txbuilder.MissingFieldsError(missing...)
+	}
+
+	acct, err := a.accounts.findByID(ctx, a.AccountID)
+	if err != nil {
+		return errors.Wrap(err, "get account info")
+	}
+
+	n := a.MaxUTXOs
+	if n <= 0 {
+		n = defaultConsolidateUTXOs
+	}
+	inputSize, err := estimateInputSize(acct)
+	if err != nil {
+		return err
+	}
+	if max := maxConsolidateTxSize / inputSize; n > max {
+		n = max
+	}
+
+	src := source{
+		AssetID:   *a.AssetID,
+		AccountID: a.AccountID,
+	}
+	res, err := a.accounts.utxoDB.ReserveSmallest(ctx, src, n, a.ClientToken, b.MaxTime())
+	if err != nil {
+		return errors.Wrap(err, "reserving utxos")
+	}
+	b.OnRollback(canceler(ctx, a.accounts, res.ID))
+
+	var total uint64
+	for _, r := range res.UTXOs {
+		txInput, sigInst, err := utxoToInputs(ctx, acct, r, nil)
+		if err != nil {
+			return errors.Wrap(err, "creating inputs")
+		}
+		sigInst.ReservationID = res.ID
+		err = b.AddInput(txInput, sigInst)
+		if err != nil {
+			return errors.Wrap(err, "adding inputs")
+		}
+		total += r.Amount
+	}
+
+	acp, err := a.accounts.createControlProgram(ctx, a.AccountID, true, b.MaxTime())
+	if err != nil {
+		return errors.Wrap(err, "creating control program")
+	}
+	a.accounts.insertControlProgramDelayed(ctx, b, acp)
+	return b.AddOutput(legacy.NewTxOutput(*a.AssetID, total, acp.controlProgram, nil))
+}
+
+// estimateInputSize estimates how many bytes one signed input
+// spending a UTXO of the account adds to a transaction.
+func estimateInputSize(acct *signers.Signer) (int, error) {
+	prog, err := vmutil.P2SPMultiSigProgram(chainkd.XPubKeys(acct.XPubs), acct.Quorum)
+	if err != nil {
+		return 0, err
+	}
+	in := legacy.NewSpendInput(nil, bc.Hash{}, bc.AssetID{}, math.MaxUint64, math.MaxUint64, prog, bc.Hash{}, nil)
+	with := legacy.TxData{Version: 1, Inputs: []*legacy.TxInput{in}}
+	without := legacy.TxData{Version: 1}
+
+	// The witness holds a signature per quorum member, plus a
+	// program checking the signatures. Allow 72 bytes for each,
+	// length prefixes included.
+	return with.SerializedSize() - without.SerializedSize() + (acct.Quorum+1)*72, nil
+}
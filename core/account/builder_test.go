@@ -1,6 +1,7 @@
 package account_test
 
 import (
+	"bytes"
 	"context"
 	"database/sql"
 	"testing"
@@ -12,9 +13,12 @@ import (
 	"chain/core/generator"
 	"chain/core/pin"
 	"chain/core/query"
+	"chain/core/signers"
 	"chain/core/txbuilder"
+	"chain/crypto/ed25519/chainkd"
 	"chain/database/pg"
 	"chain/database/pg/pgtest"
+	"chain/errors"
 	"chain/protocol/bc"
 	"chain/protocol/bc/legacy"
 	"chain/protocol/prottest"
@@ -191,6 +195,82 @@ func TestAccountSourceReserveIdempotency(t *testing.T) {
 	}
 }
 
+func TestSpendDerivationPath(t *testing.T) {
+	var (
+		_, db    = pgtest.NewDB(t, pgtest.SchemaPath)
+		ctx      = context.Background()
+		c        = prottest.NewChain(t)
+		g        = generator.New(c, nil, db)
+		pinStore = pin.NewStore(db)
+		accounts = account.NewManager(db, c, pinStore)
+		assets   = asset.NewRegistry(db, c, pinStore)
+		indexer  = query.NewIndexer(db, c, pinStore)
+	)
+	coretest.CreatePins(ctx, t, pinStore)
+	assets.IndexAssets(indexer)
+	accounts.IndexAccounts(indexer)
+	go accounts.ProcessBlocks(ctx)
+
+	_, xpub2, err := chainkd.NewXKeys(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	acct, err := accounts.Create(ctx, []chainkd.XPub{testutil.TestXPub, xpub2}, 1, "", nil, "")
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	assetID := coretest.CreateAsset(ctx, t, assets, nil, "", nil)
+	txOut, _, _ := coretest.IssueAssets(ctx, t, c, g, assets, accounts, assetID, 1, acct.ID)
+	prottest.MakeBlock(t, c, g.PendingTxs())
+	<-pinStore.PinWaiter(account.PinName, c.Height())
+
+	builder := txbuilder.NewBuilder(time.Now().Add(5 * time.Minute))
+	err = accounts.NewSpendAction(bc.AssetAmount{AssetId: &assetID, Amount: 1}, acct.ID, nil, nil).Build(ctx, builder)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	tpl, _, err := builder.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Spending keys are derived from the signer's index in the
+	// account key space, then the control program's index.
+	var cpIndex uint64
+	const q = `SELECT key_index FROM account_control_programs WHERE control_program=$1`
+	err = db.QueryRowContext(ctx, q, txOut.ControlProgram).Scan(&cpIndex)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	wantPath := signers.Path(acct.Signer, signers.AccountKeySpace, cpIndex)
+	keys := tpl.SigningInstructions[0].SignatureWitnesses[0].Keys
+	if len(keys) != len(acct.XPubs) {
+		t.Fatalf("got %d keys, want %d", len(keys), len(acct.XPubs))
+	}
+	for i, k := range keys {
+		if k.XPub != acct.XPubs[i] {
+			t.Errorf("key %d xpub = %x want %x", i, k.XPub.Bytes(), acct.XPubs[i].Bytes())
+		}
+		if fp := k.XPub.Fingerprint(); !bytes.Equal(k.Fingerprint, fp[:]) {
+			t.Errorf("key %d fingerprint = %x want %x", i, k.Fingerprint, fp[:])
+		}
+		var path [][]byte
+		for _, p := range k.DerivationPath {
+			path = append(path, p)
+		}
+		if !testutil.DeepEqual(path, wantPath) {
+			t.Errorf("key %d derivation path = %x want %x", i, path, wantPath)
+		}
+
+		// The path alone is enough to find the key
+		// in the spent control program.
+		pub := k.XPub.Derive(path).PublicKey()
+		if !bytes.Contains(txOut.ControlProgram, pub) {
+			t.Errorf("key %d derived with %x isn't in the control program", i, path)
+		}
+	}
+}
+
 func programInAccount(ctx context.Context, t testing.TB, db pg.DB, program []byte, account string) bool {
 	const q = `SELECT signer_id=$1 FROM account_control_programs WHERE control_program=$2`
 	var in bool
@@ -203,3 +283,111 @@ func programInAccount(ctx context.Context, t testing.TB, db pg.DB, program []byt
 	}
 	return in
 }
+
+func TestConsolidate(t *testing.T) {
+	var (
+		_, db    = pgtest.NewDB(t, pgtest.SchemaPath)
+		ctx      = context.Background()
+		c        = prottest.NewChain(t)
+		g        = generator.New(c, nil, db)
+		pinStore = pin.NewStore(db)
+		accounts = account.NewManager(db, c, pinStore)
+		assets   = asset.NewRegistry(db, c, pinStore)
+		indexer  = query.NewIndexer(db, c, pinStore)
+
+		accID   = coretest.CreateAccount(ctx, t, accounts, "", nil)
+		assetID = coretest.CreateAsset(ctx, t, assets, nil, "", nil)
+	)
+
+	coretest.CreatePins(ctx, t, pinStore)
+	assets.IndexAssets(indexer)
+	accounts.IndexAccounts(indexer)
+	go accounts.ProcessBlocks(ctx)
+	go indexer.ProcessBlocks(ctx)
+	makeBlock := func() {
+		prottest.MakeBlock(t, c, g.PendingTxs())
+		<-pinStore.PinWaiter(account.DeleteSpentsPinName, c.Height())
+	}
+
+	// Seed the account with 500 UTXOs of one unit each.
+	const seeded = 500
+	actions := []txbuilder.Action{
+		assets.NewIssueAction(bc.AssetAmount{AssetId: &assetID, Amount: seeded}, nil),
+	}
+	for i := 0; i < seeded; i++ {
+		actions = append(actions, accounts.NewControlAction(bc.AssetAmount{AssetId: &assetID, Amount: 1}, accID, nil))
+	}
+	coretest.Transfer(ctx, t, c, g, actions)
+	makeBlock()
+
+	// Hold a reservation on one UTXO; consolidating must skip it.
+	held, err := txbuilder.Build(ctx, nil, []txbuilder.Action{
+		accounts.NewSpendAction(bc.AssetAmount{AssetId: &assetID, Amount: 1}, accID, nil, nil),
+	}, time.Now().Add(time.Hour))
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	heldInput, err := held.Transaction.Inputs[0].SpentOutputID()
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+
+	consolidate := func(maxUTXOs int) (*legacy.Tx, error) {
+		tpl, err := txbuilder.Build(ctx, nil, []txbuilder.Action{
+			accounts.NewConsolidateAction(assetID, accID, maxUTXOs, nil),
+		}, time.Now().Add(time.Hour))
+		if err != nil {
+			return nil, err
+		}
+		coretest.SignTxTemplate(t, ctx, tpl, &testutil.TestXPrv)
+		tx := legacy.NewTx(tpl.Transaction.TxData)
+		err = txbuilder.FinalizeTx(ctx, c, g, tx)
+		if err != nil {
+			testutil.FatalErr(t, err)
+		}
+		makeBlock()
+		return tx, nil
+	}
+
+	// Asking for more UTXOs than fit gets a transaction
+	// within the size cap.
+	tx, err := consolidate(seeded)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	if n := len(tx.Inputs); n < 2 || n >= seeded-1 {
+		t.Errorf("consolidated %d utxos, want fewer than %d", n, seeded-1)
+	}
+	if size := tx.SerializedSize(); size > 100<<10 {
+		t.Errorf("consolidation tx is %d bytes, want at most %d", size, 100<<10)
+	}
+	for _, in := range tx.Inputs {
+		if id, _ := in.SpentOutputID(); id == heldInput {
+			t.Errorf("consolidation spent reserved output %x", id.Bytes())
+		}
+	}
+
+	accounts.CancelTemplateReservations(ctx, held)
+	for {
+		_, err := consolidate(100)
+		if errors.Root(err) == account.ErrTooFewUTXOs {
+			break
+		}
+		if err != nil {
+			testutil.FatalErr(t, err)
+		}
+	}
+
+	var count, balance uint64
+	const q = `SELECT COUNT(*), SUM(amount) FROM account_utxos WHERE account_id=$1 AND asset_id=$2`
+	err = db.QueryRowContext(ctx, q, accID, assetID).Scan(&count, &balance)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	if count != 1 {
+		t.Errorf("got %d utxos after consolidating, want 1", count)
+	}
+	if balance != seeded {
+		t.Errorf("got balance %d after consolidating, want %d", balance, seeded)
+	}
+}
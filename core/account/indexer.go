@@ -3,6 +3,7 @@ package account
 import (
 	"context"
 	"encoding/json"
+	"time"
 
 	"github.com/lib/pq"
 
@@ -11,6 +12,7 @@ import (
 	"chain/database/pg"
 	chainjson "chain/encoding/json"
 	"chain/errors"
+	"chain/log"
 	"chain/protocol/bc"
 	"chain/protocol/bc/legacy"
 )
@@ -19,9 +21,6 @@ const (
 	// PinName is used to identify the pin associated with
 	// the account indexer block processor.
 	PinName = "account"
-	// ExpirePinName is used to identify the pin associated
-	// with the account control program expiration processor.
-	ExpirePinName = "expire-control-programs"
 	// DeleteSpentsPinName is used to identify the pin associated
 	// with the processor that deletes spent account UTXOs.
 	DeleteSpentsPinName = "delete-account-spents"
@@ -32,9 +31,10 @@ var emptyJSONObject = json.RawMessage(`{}`)
 // A Saver is responsible for saving an annotated account object.
 // for indexing and retrieval.
 // If the Core is configured not to provide search services,
-// SaveAnnotatedAccount can be a no-op.
+// SaveAnnotatedAccount and DeleteAnnotatedAccount can be no-ops.
 type Saver interface {
 	SaveAnnotatedAccount(context.Context, *query.AnnotatedAccount) error
+	DeleteAnnotatedAccount(ctx context.Context, id string) error
 }
 
 func Annotated(a *Account) (*query.AnnotatedAccount, error) {
@@ -102,10 +102,6 @@ func (m *Manager) ProcessBlocks(ctx context.Context) {
 	if m.pinStore == nil {
 		return
 	}
-	go m.pinStore.ProcessBlocks(ctx, m.chain, ExpirePinName, func(ctx context.Context, b *legacy.Block) error {
-		<-m.pinStore.PinWaiter(PinName, b.Height)
-		return m.expireControlPrograms(ctx, b)
-	})
 	go m.pinStore.ProcessBlocks(ctx, m.chain, DeleteSpentsPinName, func(ctx context.Context, b *legacy.Block) error {
 		<-m.pinStore.PinWaiter(PinName, b.Height)
 		<-m.pinStore.PinWaiter(query.TxPinName, b.Height)
@@ -114,13 +110,6 @@ func (m *Manager) ProcessBlocks(ctx context.Context) {
 	m.pinStore.ProcessBlocks(ctx, m.chain, PinName, m.indexAccountUTXOs)
 }
 
-func (m *Manager) expireControlPrograms(ctx context.Context, b *legacy.Block) error {
-	// Delete expired account control programs.
-	const deleteQ = `DELETE FROM account_control_programs WHERE expires_at IS NOT NULL AND expires_at < $1`
-	_, err := m.db.ExecContext(ctx, deleteQ, b.Time())
-	return err
-}
-
 func (m *Manager) deleteSpentOutputs(ctx context.Context, b *legacy.Block) error {
 	// Delete consumed account UTXOs.
 	delOutputIDs := prevoutDBKeys(b.Transactions...)
@@ -157,13 +146,72 @@ func (m *Manager) indexAccountUTXOs(ctx context.Context, b *legacy.Block) error
 			outs = append(outs, out)
 		}
 	}
-	accOuts, err := m.loadAccountInfo(ctx, outs)
+	accOuts, err := m.loadAccountInfo(ctx, outs, b.Time())
 	if err != nil {
 		return errors.Wrap(err, "loading account info from control programs")
 	}
 
 	err = m.upsertConfirmedAccountOutputs(ctx, accOuts, blockPositions, b)
-	return errors.Wrap(err, "upserting confirmed account utxos")
+	if err != nil {
+		return errors.Wrap(err, "upserting confirmed account utxos")
+	}
+
+	err = m.markControlProgramsUsed(ctx, accOuts)
+	return errors.Wrap(err, "marking used account control programs")
+}
+
+// RebuildUTXOs deletes every account UTXO and replays the blocks
+// from the initial block through height to record them again, for
+// recovering from a corrupt account_utxos table. It logs its
+// progress every 10000 blocks.
+//
+// It works in a single database transaction, so readers such as
+// the reserver never see the table partly rebuilt. The account
+// pins must be paused while it runs; see pin.Store.Pause.
+func (m *Manager) RebuildUTXOs(ctx context.Context, height uint64) error {
+	return pg.Atomically(ctx, m.db, func(db pg.DB) error {
+		// tm writes through the transaction.
+		tm := &Manager{db: db, chain: m.chain}
+
+		_, err := db.ExecContext(ctx, `DELETE FROM account_utxos`)
+		if err != nil {
+			return errors.Wrap(err, "deleting account utxos")
+		}
+		for h := uint64(1); h <= height; h++ {
+			b, err := m.chain.GetBlock(ctx, h)
+			if err != nil {
+				return errors.Wrapf(err, "getting block %d", h)
+			}
+			err = tm.indexAccountUTXOs(ctx, b)
+			if err != nil {
+				return errors.Wrapf(err, "indexing block %d", h)
+			}
+			err = tm.deleteSpentOutputs(ctx, b)
+			if err != nil {
+				return errors.Wrapf(err, "indexing block %d", h)
+			}
+			if h%10000 == 0 || h == height {
+				log.Printkv(ctx, "rebuilding account utxos", h, "height", height)
+			}
+		}
+		return nil
+	})
+}
+
+// markControlProgramsUsed records that the control programs of
+// the provided outputs have received funds, which exempts them
+// from the inactive sweep.
+func (m *Manager) markControlProgramsUsed(ctx context.Context, outs []*accountOutput) error {
+	var programs pq.ByteaArray
+	for _, out := range outs {
+		programs = append(programs, out.ControlProgram)
+	}
+	const q = `
+		UPDATE account_control_programs SET used = true, inactive = false
+		WHERE control_program IN (SELECT unnest($1::bytea[])) AND NOT used
+	`
+	_, err := m.db.ExecContext(ctx, q, programs)
+	return err
 }
 
 func prevoutDBKeys(txs ...*legacy.Tx) (outputIDs pq.ByteaArray) {
@@ -179,8 +227,9 @@ func prevoutDBKeys(txs ...*legacy.Tx) (outputIDs pq.ByteaArray) {
 
 // loadAccountInfo turns a set of output IDs into a set of
 // outputs by adding account annotations.  Outputs that can't be
-// annotated are excluded from the result.
-func (m *Manager) loadAccountInfo(ctx context.Context, outs []*rawOutput) ([]*accountOutput, error) {
+// annotated are excluded from the result, as are outputs paying
+// control programs that expired before blockTime.
+func (m *Manager) loadAccountInfo(ctx context.Context, outs []*rawOutput, blockTime time.Time) ([]*accountOutput, error) {
 	outsByScript := make(map[string][]*rawOutput, len(outs))
 	for _, out := range outs {
 		scriptStr := string(out.ControlProgram)
@@ -198,8 +247,9 @@ func (m *Manager) loadAccountInfo(ctx context.Context, outs []*rawOutput) ([]*ac
 		SELECT signer_id, key_index, control_program, change
 		FROM account_control_programs
 		WHERE control_program IN (SELECT unnest($1::bytea[]))
+			AND (expires_at IS NULL OR expires_at >= $2)
 	`
-	err := pg.ForQueryRows(ctx, m.db, q, scripts, func(accountID string, keyIndex uint64, program []byte, change bool) {
+	err := pg.ForQueryRows(ctx, m.db, q, scripts, blockTime, func(accountID string, keyIndex uint64, program []byte, change bool) {
 		for _, out := range outsByScript[string(program)] {
 			newOut := &accountOutput{
 				rawOutput: *out,
@@ -3,11 +3,15 @@ package account
 import (
 	"context"
 	"testing"
+	"time"
 
+	"chain/core/query"
+	"chain/database/pg"
 	"chain/database/pg/pgtest"
 	"chain/protocol/bc"
 	"chain/protocol/bc/legacy"
 	"chain/protocol/prottest"
+	"chain/protocol/prottest/memstore"
 	"chain/testutil"
 )
 
@@ -30,7 +34,7 @@ func TestLoadAccountInfo(t *testing.T) {
 		ControlProgram: to2.ControlProgram,
 	}}
 
-	got, err := m.loadAccountInfo(ctx, outs)
+	got, err := m.loadAccountInfo(ctx, outs, time.Now())
 	if err != nil {
 		testutil.FatalErr(t, err)
 	}
@@ -89,3 +93,140 @@ func TestDeleteUTXOs(t *testing.T) {
 		t.Errorf("count(account_utxos) = %d want 0", n)
 	}
 }
+
+func TestRebuildUTXOs(t *testing.T) {
+	db := pgtest.NewTx(t)
+	store := memstore.New()
+	m := NewManager(db, prottest.NewChain(t, prottest.WithStore(store)), nil)
+	ctx := context.Background()
+
+	assetID := bc.AssetID{}
+	acp := m.createTestControlProgram(ctx, t, "").controlProgram
+	tx1 := legacy.NewTx(legacy.TxData{
+		Outputs: []*legacy.TxOutput{
+			legacy.NewTxOutput(assetID, 1, acp, nil),
+			legacy.NewTxOutput(assetID, 2, acp, nil),
+		},
+	})
+	out0 := tx1.Entries[*tx1.ResultIds[0]].(*bc.Output)
+	tx2 := legacy.NewTx(legacy.TxData{
+		Inputs: []*legacy.TxInput{
+			legacy.NewSpendInput(nil, *out0.Source.Ref, assetID, 1, out0.Source.Position, acp, *out0.Data, nil),
+		},
+	})
+	for i, tx := range []*legacy.Tx{tx1, tx2} {
+		b := &legacy.Block{
+			BlockHeader:  legacy.BlockHeader{Height: uint64(i + 2), TimestampMS: bc.Millis(time.Now())},
+			Transactions: []*legacy.Tx{tx},
+		}
+		err := store.SaveBlock(ctx, b)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Corrupt the table with an output that doesn't exist.
+	_, err := db.ExecContext(ctx, `
+		INSERT INTO account_utxos (output_id, asset_id, amount, account_id, control_program_index,
+			control_program, confirmed_in, source_id, source_pos, ref_data_hash, change)
+		VALUES ($1, $1, 5, 'bogus', 0, $1, 1, $1, 0, $1, false)
+	`, []byte{0})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = m.RebuildUTXOs(ctx, 3)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+
+	var got []uint64
+	err = pg.ForQueryRows(ctx, db, `SELECT amount FROM account_utxos`, func(amount uint64) {
+		got = append(got, amount)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []uint64{2}; !testutil.DeepEqual(got, want) {
+		t.Errorf("account utxo amounts = %v want %v", got, want)
+	}
+}
+
+func TestExpiredControlProgram(t *testing.T) {
+	db := pgtest.NewTx(t)
+	m := NewManager(db, prottest.NewChain(t), nil)
+	ctx := context.Background()
+
+	expiresAt := time.Now().Add(time.Hour).Truncate(time.Second)
+	acc := m.createTestAccount(ctx, t, "", nil)
+	acp, err := m.CreateControlProgram(ctx, acc.ID, false, expiresAt)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	unused, err := m.CreateControlProgram(ctx, acc.ID, false, expiresAt)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+
+	assetID := bc.AssetID{}
+	beforeTx := legacy.NewTx(legacy.TxData{
+		Outputs: []*legacy.TxOutput{legacy.NewTxOutput(assetID, 1, acp, nil)},
+	})
+	afterTx := legacy.NewTx(legacy.TxData{
+		Outputs: []*legacy.TxOutput{
+			legacy.NewTxOutput(assetID, 2, acp, nil),
+			legacy.NewTxOutput(assetID, 3, unused, nil),
+		},
+	})
+	before := &legacy.Block{
+		BlockHeader:  legacy.BlockHeader{Height: 2, TimestampMS: bc.Millis(expiresAt.Add(-time.Minute))},
+		Transactions: []*legacy.Tx{beforeTx},
+	}
+	after := &legacy.Block{
+		BlockHeader:  legacy.BlockHeader{Height: 3, TimestampMS: bc.Millis(expiresAt.Add(time.Minute))},
+		Transactions: []*legacy.Tx{afterTx},
+	}
+
+	// Paying the program before it expires is accepted.
+	err = m.indexAccountUTXOs(ctx, before)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	// Paying either program after it expires is refused, whether
+	// or not it received funds before.
+	err = m.indexAccountUTXOs(ctx, after)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+
+	var got []uint64
+	err = pg.ForQueryRows(ctx, db, `SELECT amount FROM account_utxos`, func(amount uint64) {
+		got = append(got, amount)
+	})
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	if !testutil.DeepEqual(got, []uint64{1}) {
+		t.Errorf("account utxo amounts = %v want [1]", got)
+	}
+
+	txs := []*query.AnnotatedTx{
+		{Timestamp: before.Time(), Outputs: []*query.AnnotatedOutput{{OutputID: *beforeTx.OutputID(0), ControlProgram: acp}}},
+		{Timestamp: after.Time(), Outputs: []*query.AnnotatedOutput{
+			{OutputID: *afterTx.OutputID(0), ControlProgram: acp},
+			{OutputID: *afterTx.OutputID(1), ControlProgram: unused},
+		}},
+	}
+	err = m.AnnotateTxs(ctx, txs)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	if p := txs[0].Outputs[0].Purpose; p != "receive" || txs[0].Outputs[0].AccountID != acc.ID {
+		t.Errorf("output paid before expiry: purpose = %q account = %q, want receive %s", p, txs[0].Outputs[0].AccountID, acc.ID)
+	}
+	for i, out := range txs[1].Outputs {
+		if out.Purpose != "expired" || out.AccountID != "" {
+			t.Errorf("output %d paid after expiry: purpose = %q account = %q, want expired and no account", i, out.Purpose, out.AccountID)
+		}
+	}
+}
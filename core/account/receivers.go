@@ -4,7 +4,10 @@ import (
 	"context"
 	"time"
 
+	"github.com/lib/pq"
+
 	"chain/core/txbuilder"
+	"chain/database/pg"
 	"chain/errors"
 )
 
@@ -16,7 +19,7 @@ const defaultReceiverExpiry = 30 * 24 * time.Hour // 30 days
 // used.
 func (m *Manager) CreateReceiver(ctx context.Context, accID, accAlias string, expiresAt time.Time) (*txbuilder.Receiver, error) {
 	if expiresAt.IsZero() {
-		expiresAt = time.Now().Add(defaultReceiverExpiry)
+		expiresAt = m.now().Add(defaultReceiverExpiry)
 	}
 
 	if accAlias != "" {
@@ -36,3 +39,34 @@ func (m *Manager) CreateReceiver(ctx context.Context, accID, accAlias string, ex
 		ExpiresAt:      expiresAt,
 	}, nil
 }
+
+// ListReceivers returns the account's receivers that can still be
+// handed out: those that are not change programs, haven't expired
+// and haven't been marked inactive by the sweeper.
+func (m *Manager) ListReceivers(ctx context.Context, accID, accAlias string) ([]*txbuilder.Receiver, error) {
+	if accAlias != "" {
+		s, err := m.FindByAlias(ctx, accAlias)
+		if err != nil {
+			return nil, err
+		}
+		accID = s.ID
+	}
+
+	const q = `
+		SELECT control_program, expires_at FROM account_control_programs
+		WHERE signer_id = $1 AND NOT change AND NOT inactive
+			AND (expires_at IS NULL OR expires_at >= $2)
+		ORDER BY key_index
+	`
+	var receivers []*txbuilder.Receiver
+	err := pg.ForQueryRows(ctx, m.db, q, accID, m.now(), func(program []byte, expiresAt pq.NullTime) {
+		receivers = append(receivers, &txbuilder.Receiver{
+			ControlProgram: program,
+			ExpiresAt:      expiresAt.Time,
+		})
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "listing receivers")
+	}
+	return receivers, nil
+}
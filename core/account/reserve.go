@@ -1,9 +1,12 @@
 package account
 
 import (
+	"bytes"
 	"context"
 	"database/sql"
+	"expvar"
 	"fmt"
+	"sort"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -30,8 +33,52 @@ var (
 	// new change outputs will be created
 	// in sufficient amounts to satisfy the request.
 	ErrReserved = errors.New("reservation found outputs already reserved")
+
+	// ErrBadSelection indicates that a spend asked for an unknown
+	// UTXO selection strategy.
+	ErrBadSelection = errors.New("invalid utxo selection strategy")
+
+	// ErrTooFewUTXOs indicates that a consolidation found fewer
+	// than two unreserved outputs to combine.
+	ErrTooFewUTXOs = errors.New("too few unreserved outputs to consolidate")
+)
+
+// Reservation metrics. The reserved amounts are keyed
+// by hex asset ID.
+var (
+	reservationsCreated  = expvar.NewInt("account_reservations_created")
+	reservationsExpired  = expvar.NewInt("account_reservations_expired")
+	reservationsCanceled = expvar.NewInt("account_reservations_canceled")
+	reservedUTXOs        = expvar.NewInt("account_reserved_utxos")
+	reservedAmounts      = expvar.NewMap("account_reserved_amounts")
+)
+
+// Strategies for choosing which of an account's UTXOs
+// to reserve. The zero value chooses them in no
+// particular order.
+const (
+	// SelectOldestFirst reserves the UTXOs confirmed
+	// earliest, to keep old outputs from accumulating.
+	SelectOldestFirst = "oldest-first"
+
+	// SelectLargestFirst reserves the largest UTXOs,
+	// using as few inputs as possible.
+	SelectLargestFirst = "largest-first"
+
+	// SelectMinimizeChange reserves the UTXOs whose total
+	// exceeds the amount by as little as it can find,
+	// keeping change outputs small.
+	SelectMinimizeChange = "minimize-change"
 )
 
+func validSelection(sel string) bool {
+	switch sel {
+	case "", SelectOldestFirst, SelectLargestFirst, SelectMinimizeChange:
+		return true
+	}
+	return false
+}
+
 // utxo describes an individual account utxo.
 type utxo struct {
 	OutputID bc.Hash
@@ -48,6 +95,7 @@ type utxo struct {
 
 	AccountID           string
 	ControlProgramIndex uint64
+	ConfirmedIn         uint64
 }
 
 func (u *utxo) source() source {
@@ -106,24 +154,28 @@ type reserver struct {
 }
 
 // Reserve selects and reserves UTXOs according to the criteria provided
-// in source. The resulting reservation expires at exp.
-func (re *reserver) Reserve(ctx context.Context, src source, amount uint64, clientToken *string, exp time.Time) (*reservation, error) {
+// in source, choosing among them with the selection strategy sel.
+// The resulting reservation expires at exp.
+func (re *reserver) Reserve(ctx context.Context, src source, amount uint64, sel string, clientToken *string, exp time.Time) (*reservation, error) {
+	if !validSelection(sel) {
+		return nil, errors.WithDetailf(ErrBadSelection, "unknown selection %q", sel)
+	}
 	if clientToken == nil {
-		return re.reserve(ctx, src, amount, clientToken, exp)
+		return re.reserve(ctx, src, amount, sel, clientToken, exp)
 	}
 
 	untypedRes, err := re.idempotency.Once(*clientToken, func() (interface{}, error) {
-		return re.reserve(ctx, src, amount, clientToken, exp)
+		return re.reserve(ctx, src, amount, sel, clientToken, exp)
 	})
 	return untypedRes.(*reservation), err
 }
 
-func (re *reserver) reserve(ctx context.Context, src source, amount uint64, clientToken *string, exp time.Time) (res *reservation, err error) {
+func (re *reserver) reserve(ctx context.Context, src source, amount uint64, sel string, clientToken *string, exp time.Time) (res *reservation, err error) {
 	sourceReserver := re.source(src)
 
 	// Try to reserve the right amount.
 	rid := atomic.AddUint64(&re.nextReservationID, 1)
-	reserved, total, err := sourceReserver.reserve(ctx, rid, amount)
+	reserved, total, err := sourceReserver.reserve(ctx, rid, amount, sel)
 	if err != nil {
 		return nil, err
 	}
@@ -140,6 +192,7 @@ func (re *reserver) reserve(ctx context.Context, src source, amount uint64, clie
 	re.reservationsMu.Lock()
 	defer re.reservationsMu.Unlock()
 	re.reservations[rid] = res
+	countReservation(res, 1)
 
 	// Make change if necessary
 	if total > amount {
@@ -186,9 +239,92 @@ func (re *reserver) reserveUTXO(ctx context.Context, out bc.Hash, exp time.Time,
 	re.reservationsMu.Lock()
 	re.reservations[rid] = res
 	re.reservationsMu.Unlock()
+	countReservation(res, 1)
+	return res, nil
+}
+
+// ReserveSmallest reserves up to n of the smallest unreserved
+// UTXOs matching src, for combining them into one. It reserves
+// at least two or none at all. The resulting reservation expires
+// at exp.
+func (re *reserver) ReserveSmallest(ctx context.Context, src source, n int, clientToken *string, exp time.Time) (*reservation, error) {
+	if clientToken == nil {
+		return re.reserveSmallest(ctx, src, n, clientToken, exp)
+	}
+
+	untypedRes, err := re.idempotency.Once(*clientToken, func() (interface{}, error) {
+		return re.reserveSmallest(ctx, src, n, clientToken, exp)
+	})
+	return untypedRes.(*reservation), err
+}
+
+func (re *reserver) reserveSmallest(ctx context.Context, src source, n int, clientToken *string, exp time.Time) (*reservation, error) {
+	sourceReserver := re.source(src)
+	err := sourceReserver.refillCache(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	rid := atomic.AddUint64(&re.nextReservationID, 1)
+	reserved, err := sourceReserver.reserveSmallest(rid, n)
+	if err != nil {
+		return nil, err
+	}
+
+	res := &reservation{
+		ID:          rid,
+		Source:      src,
+		UTXOs:       reserved,
+		Expiry:      exp,
+		ClientToken: clientToken,
+	}
+	re.reservationsMu.Lock()
+	re.reservations[rid] = res
+	re.reservationsMu.Unlock()
+	countReservation(res, 1)
 	return res, nil
 }
 
+// list returns the current reservations, ordered by ID.
+func (re *reserver) list() []*reservation {
+	re.reservationsMu.Lock()
+	list := make([]*reservation, 0, len(re.reservations))
+	for _, res := range re.reservations {
+		list = append(list, res)
+	}
+	re.reservationsMu.Unlock()
+	sort.Slice(list, func(i, j int) bool { return list[i].ID < list[j].ID })
+	return list
+}
+
+// countReservation adds res to the reservation metrics when
+// sign is 1, and removes it when sign is -1.
+func countReservation(res *reservation, sign int64) {
+	var amount uint64
+	for _, u := range res.UTXOs {
+		amount += u.Amount
+	}
+	if sign > 0 {
+		reservationsCreated.Add(1)
+	}
+	reservedUTXOs.Add(sign * int64(len(res.UTXOs)))
+	reservedAmounts.Add(fmt.Sprintf("%x", res.Source.AssetID.Bytes()), sign*int64(amount))
+}
+
+// holdsAccount reports whether any unexpired reservation holds
+// outputs of the account with the provided ID.
+func (re *reserver) holdsAccount(accountID string) bool {
+	now := time.Now()
+	re.reservationsMu.Lock()
+	defer re.reservationsMu.Unlock()
+	for _, res := range re.reservations {
+		if res.Source.AccountID == accountID && res.Expiry.After(now) {
+			return true
+		}
+	}
+	return false
+}
+
 // Cancel makes a best-effort attempt at canceling the reservation with
 // the provided ID.
 func (re *reserver) Cancel(ctx context.Context, rid uint64) error {
@@ -199,6 +335,8 @@ func (re *reserver) Cancel(ctx context.Context, rid uint64) error {
 	if !ok {
 		return fmt.Errorf("couldn't find reservation %d", rid)
 	}
+	reservationsCanceled.Add(1)
+	countReservation(res, -1)
 	re.source(res.Source).cancel(res)
 	if res.ClientToken != nil {
 		re.idempotency.Forget(*res.ClientToken)
@@ -206,6 +344,37 @@ func (re *reserver) Cancel(ctx context.Context, rid uint64) error {
 	return nil
 }
 
+// CancelHolding cancels the reservation with the provided ID if it
+// holds the output with the provided ID. It reports whether the
+// reservation was canceled.
+func (re *reserver) CancelHolding(ctx context.Context, rid uint64, outputID bc.Hash) bool {
+	re.reservationsMu.Lock()
+	res, ok := re.reservations[rid]
+	if ok {
+		ok = false
+		for _, u := range res.UTXOs {
+			if u.OutputID == outputID {
+				ok = true
+				break
+			}
+		}
+	}
+	if ok {
+		delete(re.reservations, rid)
+	}
+	re.reservationsMu.Unlock()
+	if !ok {
+		return false
+	}
+	reservationsCanceled.Add(1)
+	countReservation(res, -1)
+	re.source(res.Source).cancel(res)
+	if res.ClientToken != nil {
+		re.idempotency.Forget(*res.ClientToken)
+	}
+	return true
+}
+
 // ExpireReservations cleans up all reservations that have expired,
 // making their UTXOs available for reservation again.
 func (re *reserver) ExpireReservations(ctx context.Context) error {
@@ -224,6 +393,8 @@ func (re *reserver) ExpireReservations(ctx context.Context) error {
 	// If we removed any expired reservations, update the corresponding
 	// source reservers.
 	for _, res := range canceled {
+		reservationsExpired.Add(1)
+		countReservation(res, -1)
 		re.source(res.Source).cancel(res)
 		if res.ClientToken != nil {
 			re.idempotency.Forget(*res.ClientToken)
@@ -276,8 +447,8 @@ type sourceReserver struct {
 	lastHeight uint64
 }
 
-func (sr *sourceReserver) reserve(ctx context.Context, rid uint64, amount uint64) ([]*utxo, uint64, error) {
-	reservedUTXOs, reservedAmount, err := sr.reserveFromCache(rid, amount)
+func (sr *sourceReserver) reserve(ctx context.Context, rid uint64, amount uint64, sel string) ([]*utxo, uint64, error) {
+	reservedUTXOs, reservedAmount, err := sr.reserveFromCache(rid, amount, sel)
 	if err == nil {
 		return reservedUTXOs, reservedAmount, nil
 	}
@@ -288,13 +459,14 @@ func (sr *sourceReserver) reserve(ctx context.Context, rid uint64, amount uint64
 		return nil, 0, err
 	}
 
-	return sr.reserveFromCache(rid, amount)
+	return sr.reserveFromCache(rid, amount, sel)
 }
 
-func (sr *sourceReserver) reserveFromCache(rid uint64, amount uint64) ([]*utxo, uint64, error) {
+func (sr *sourceReserver) reserveFromCache(rid uint64, amount uint64, sel string) ([]*utxo, uint64, error) {
 	var (
 		reserved, unavailable uint64
 		reservedUTXOs         []*utxo
+		available             []*utxo
 	)
 	sr.mu.Lock()
 	defer sr.mu.Unlock()
@@ -313,12 +485,19 @@ func (sr *sourceReserver) reserveFromCache(rid uint64, amount uint64) ([]*utxo,
 			continue
 		}
 
+		if sel != "" {
+			available = append(available, u)
+			continue
+		}
 		reserved += u.Amount
 		reservedUTXOs = append(reservedUTXOs, u)
 		if reserved >= amount {
 			break
 		}
 	}
+	if sel != "" {
+		reservedUTXOs, reserved = selectUTXOs(available, amount, sel)
+	}
 	if reserved+unavailable < amount {
 		// Even if everything was available, this account wouldn't have
 		// enough to satisfy the request.
@@ -338,6 +517,97 @@ func (sr *sourceReserver) reserveFromCache(rid uint64, amount uint64) ([]*utxo,
 	return reservedUTXOs, reserved, nil
 }
 
+func (sr *sourceReserver) reserveSmallest(rid uint64, n int) ([]*utxo, error) {
+	sr.mu.Lock()
+	defer sr.mu.Unlock()
+
+	var available []*utxo
+	for o, u := range sr.cached {
+		if _, ok := sr.reserved[u.OutputID]; ok {
+			continue
+		}
+		if !sr.validFn(u) {
+			delete(sr.cached, o)
+			continue
+		}
+		available = append(available, u)
+	}
+	sortUTXOs(available, SelectMinimizeChange)
+	if n < len(available) {
+		available = available[:n]
+	}
+	if len(available) < 2 {
+		return nil, ErrTooFewUTXOs
+	}
+
+	for _, u := range available {
+		sr.reserved[u.OutputID] = rid
+	}
+	return available, nil
+}
+
+// sortUTXOs sorts available in the order the selection strategy
+// sel prefers them. Ties are broken by output ID, so the order is
+// deterministic.
+func sortUTXOs(available []*utxo, sel string) {
+	var less func(a, b *utxo) bool
+	switch sel {
+	case SelectOldestFirst:
+		less = func(a, b *utxo) bool { return a.ConfirmedIn < b.ConfirmedIn }
+	case SelectLargestFirst:
+		less = func(a, b *utxo) bool { return a.Amount > b.Amount }
+	case SelectMinimizeChange:
+		less = func(a, b *utxo) bool { return a.Amount < b.Amount }
+	}
+	sort.Slice(available, func(i, j int) bool {
+		a, b := available[i], available[j]
+		if less(a, b) {
+			return true
+		}
+		if less(b, a) {
+			return false
+		}
+		return bytes.Compare(a.OutputID.Bytes(), b.OutputID.Bytes()) < 0
+	})
+}
+
+// selectUTXOs chooses UTXOs from available totaling at least
+// amount, if it can, using the selection strategy sel. It
+// returns the chosen UTXOs and their total.
+func selectUTXOs(available []*utxo, amount uint64, sel string) ([]*utxo, uint64) {
+	sortUTXOs(available, sel)
+
+	var (
+		selected []*utxo
+		total    uint64
+	)
+	if sel != SelectMinimizeChange {
+		for _, u := range available {
+			if total >= amount {
+				break
+			}
+			selected = append(selected, u)
+			total += u.Amount
+		}
+		return selected, total
+	}
+
+	// With available sorted smallest first, take the smallest
+	// UTXO that covers what's left to reserve. If none does,
+	// take the largest and try again with the remainder.
+	for total < amount && len(available) > 0 {
+		need := amount - total
+		i := sort.Search(len(available), func(i int) bool { return available[i].Amount >= need })
+		if i == len(available) {
+			i = len(available) - 1
+		}
+		selected = append(selected, available[i])
+		total += available[i].Amount
+		available = append(available[:i:i], available[i+1:]...)
+	}
+	return selected, total
+}
+
 func (sr *sourceReserver) reserveUTXO(rid uint64, utxo *utxo) error {
 	sr.mu.Lock()
 	defer sr.mu.Unlock()
@@ -389,13 +659,13 @@ func (sr *sourceReserver) refillCache(ctx context.Context) error {
 func findMatchingUTXOs(ctx context.Context, db pg.DB, src source, height uint64) ([]*utxo, error) {
 	const q = `
 		SELECT output_id, amount, control_program_index, control_program,
-			source_id, source_pos, ref_data_hash
+			source_id, source_pos, ref_data_hash, confirmed_in
 		FROM account_utxos
 		WHERE account_id = $1 AND asset_id = $2 AND confirmed_in > $3
 	`
 	var utxos []*utxo
 	err := pg.ForQueryRows(ctx, db, q, src.AccountID, src.AssetID, height,
-		func(oid bc.Hash, amount uint64, cpIndex uint64, controlProg []byte, sourceID bc.Hash, sourcePos uint64, refData bc.Hash) {
+		func(oid bc.Hash, amount uint64, cpIndex uint64, controlProg []byte, sourceID bc.Hash, sourcePos uint64, refData bc.Hash, confirmedIn uint64) {
 			utxos = append(utxos, &utxo{
 				OutputID:            oid,
 				SourceID:            sourceID,
@@ -406,6 +676,7 @@ func findMatchingUTXOs(ctx context.Context, db pg.DB, src source, height uint64)
 				RefDataHash:         refData,
 				AccountID:           src.AccountID,
 				ControlProgramIndex: cpIndex,
+				ConfirmedIn:         confirmedIn,
 			})
 		})
 	if err != nil {
@@ -417,7 +688,7 @@ func findMatchingUTXOs(ctx context.Context, db pg.DB, src source, height uint64)
 func findSpecificUTXO(ctx context.Context, db pg.DB, out bc.Hash) (*utxo, error) {
 	const q = `
 		SELECT account_id, asset_id, amount, control_program_index, control_program,
-			source_id, source_pos, ref_data_hash
+			source_id, source_pos, ref_data_hash, confirmed_in
 		FROM account_utxos
 		WHERE output_id = $1
 	`
@@ -432,6 +703,7 @@ func findSpecificUTXO(ctx context.Context, db pg.DB, out bc.Hash) (*utxo, error)
 		&u.SourceID,
 		&u.SourcePos,
 		&u.RefDataHash,
+		&u.ConfirmedIn,
 	)
 	if err == sql.ErrNoRows {
 		return nil, pg.ErrUserInputNotFound
@@ -2,12 +2,17 @@ package account
 
 import (
 	"context"
+	"expvar"
+	"fmt"
 	"testing"
+	"testing/quick"
 	"time"
 
+	"chain/core/txbuilder"
 	"chain/database/pg/pgtest"
 	"chain/protocol/bc"
 	"chain/protocol/prottest"
+	"chain/testutil"
 )
 
 const sampleAccountUTXOs = `
@@ -62,3 +67,214 @@ func TestCancelReservation(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+func TestReserveSelection(t *testing.T) {
+	// UTXO i has amount amounts[i] and was confirmed at height
+	// heights[i].
+	amounts := []uint64{5, 40, 10, 25, 7}
+	heights := []uint64{3, 5, 1, 2, 4}
+
+	cases := []struct {
+		sel    string
+		amount uint64
+		want   []int // indexes of the reserved utxos, in order
+	}{
+		{SelectOldestFirst, 20, []int{2, 3}},
+		{SelectOldestFirst, 10, []int{2}},
+		{SelectOldestFirst, 41, []int{2, 3, 0, 4}},
+		{SelectLargestFirst, 20, []int{1}},
+		{SelectLargestFirst, 50, []int{1, 3}},
+		{SelectMinimizeChange, 20, []int{3}},
+		{SelectMinimizeChange, 7, []int{4}},
+		{SelectMinimizeChange, 8, []int{2}},
+		{SelectMinimizeChange, 50, []int{1, 2}},
+		{SelectMinimizeChange, 80, []int{1, 3, 2, 0}},
+	}
+	for _, c := range cases {
+		utxos := make([]*utxo, len(amounts))
+		sr := newTestSourceReserver()
+		for i := range amounts {
+			utxos[i] = &utxo{
+				OutputID:    bc.NewHash([32]byte{byte(i)}),
+				Amount:      amounts[i],
+				ConfirmedIn: heights[i],
+			}
+			sr.cached[utxos[i].OutputID] = utxos[i]
+		}
+
+		got, _, err := sr.reserveFromCache(1, c.amount, c.sel)
+		if err != nil {
+			t.Errorf("reserve %d %s: %s", c.amount, c.sel, err)
+			continue
+		}
+		var want []*utxo
+		for _, i := range c.want {
+			want = append(want, utxos[i])
+		}
+		if !testutil.DeepEqual(got, want) {
+			t.Errorf("reserve %d %s = %v want indexes %v", c.amount, c.sel, amountsOf(got), c.want)
+		}
+	}
+}
+
+func TestReserveSelectionCoversAmount(t *testing.T) {
+	f := func(amounts []uint32, heights []uint8, amount uint32) bool {
+		for _, sel := range []string{"", SelectOldestFirst, SelectLargestFirst, SelectMinimizeChange} {
+			sr := newTestSourceReserver()
+			var sum uint64
+			for i, a := range amounts {
+				u := &utxo{OutputID: bc.NewHash([32]byte{byte(i), byte(i >> 8)}), Amount: uint64(a)}
+				if i < len(heights) {
+					u.ConfirmedIn = uint64(heights[i])
+				}
+				sr.cached[u.OutputID] = u
+				sum += uint64(a)
+			}
+
+			got, total, err := sr.reserveFromCache(1, uint64(amount), sel)
+			if uint64(amount) > sum {
+				if err != ErrInsufficient {
+					t.Logf("%s: reserving %d of %d: err = %v want %v", sel, amount, sum, err, ErrInsufficient)
+					return false
+				}
+				continue
+			}
+			if err != nil {
+				t.Logf("%s: reserving %d of %d: %s", sel, amount, sum, err)
+				return false
+			}
+			var gotSum uint64
+			for _, u := range got {
+				gotSum += u.Amount
+			}
+			if gotSum != total || total < uint64(amount) {
+				t.Logf("%s: reserved %d (reported %d) for %d", sel, gotSum, total, amount)
+				return false
+			}
+		}
+		return true
+	}
+	err := quick.Check(f, nil)
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func newTestSourceReserver() *sourceReserver {
+	return &sourceReserver{
+		validFn:  func(*utxo) bool { return true },
+		cached:   make(map[bc.Hash]*utxo),
+		reserved: make(map[bc.Hash]uint64),
+	}
+}
+
+func amountsOf(utxos []*utxo) []uint64 {
+	var amounts []uint64
+	for _, u := range utxos {
+		amounts = append(amounts, u.Amount)
+	}
+	return amounts
+}
+
+func TestReservationMetrics(t *testing.T) {
+	ctx := context.Background()
+	db := pgtest.NewTx(t)
+	outIDs := []bc.Hash{randHash(), randHash(), randHash()}
+	m := NewManager(db, prottest.NewChain(t, prottest.WithOutputIDs(outIDs...)), nil)
+	acc := m.createTestAccount(ctx, t, "", nil)
+	assetID := bc.AssetID(randHash())
+
+	// Give the account three UTXOs of 10, 20 and 30 units.
+	for i, outID := range outIDs {
+		cp := m.createTestControlProgram(ctx, t, acc.ID)
+		const q = `
+			INSERT INTO account_utxos (asset_id, amount, account_id,
+			control_program_index, control_program, confirmed_in,
+			output_id, source_id, source_pos, ref_data_hash, change)
+			VALUES($1, $2, $3, $4, $5, 1, $6, $7, 0, $8, false)
+		`
+		_, err := db.ExecContext(ctx, q, assetID, 10*(i+1), acc.ID,
+			cp.keyIndex, cp.controlProgram, outID, randHash(), randHash())
+		if err != nil {
+			testutil.FatalErr(t, err)
+		}
+	}
+
+	created, expired, canceled := reservationsCreated.Value(), reservationsExpired.Value(), reservationsCanceled.Value()
+	utxos := reservedUTXOs.Value()
+	build := func(i int, maxTime time.Time) *txbuilder.Template {
+		tpl, err := txbuilder.Build(ctx, nil, []txbuilder.Action{
+			m.NewSpendUTXOAction(outIDs[i]),
+			m.NewControlAction(bc.AssetAmount{AssetId: &assetID, Amount: uint64(10 * (i + 1))}, acc.ID, nil),
+		}, maxTime)
+		if err != nil {
+			testutil.FatalErr(t, err)
+		}
+		return tpl
+	}
+	later := time.Now().Add(time.Hour).Truncate(time.Millisecond)
+	build(0, later)
+	tpl := build(1, later)
+	// The third reservation is already past its expiry,
+	// as if its ttl had gone by.
+	past := time.Now().Add(-time.Second).Truncate(time.Millisecond)
+	build(2, past)
+
+	check := func(when string, wantCreated, wantExpired, wantCanceled, wantUTXOs, wantAmount int64) {
+		if got := reservationsCreated.Value() - created; got != wantCreated {
+			t.Errorf("%s: %d reservations created, want %d", when, got, wantCreated)
+		}
+		if got := reservationsExpired.Value() - expired; got != wantExpired {
+			t.Errorf("%s: %d reservations expired, want %d", when, got, wantExpired)
+		}
+		if got := reservationsCanceled.Value() - canceled; got != wantCanceled {
+			t.Errorf("%s: %d reservations canceled, want %d", when, got, wantCanceled)
+		}
+		if got := reservedUTXOs.Value() - utxos; got != wantUTXOs {
+			t.Errorf("%s: %d utxos reserved, want %d", when, got, wantUTXOs)
+		}
+		var amount int64
+		if v, ok := reservedAmounts.Get(fmt.Sprintf("%x", assetID.Bytes())).(*expvar.Int); ok {
+			amount = v.Value()
+		}
+		if amount != wantAmount {
+			t.Errorf("%s: %d units reserved, want %d", when, amount, wantAmount)
+		}
+	}
+	listAmounts := func() []uint64 {
+		var amounts []uint64
+		for _, r := range m.ListReservations() {
+			if r.AccountID != acc.ID || r.AssetID != assetID || r.Outputs != 1 {
+				t.Errorf("listed reservation %+v, want one output of asset %x in account %s", r, assetID.Bytes(), acc.ID)
+			}
+			amounts = append(amounts, r.Amount)
+		}
+		return amounts
+	}
+
+	check("after building", 3, 0, 0, 3, 60)
+	list := m.ListReservations()
+	if len(list) != 3 || !list[0].Expiry.Equal(later) || !list[2].Expiry.Equal(past) {
+		t.Errorf("listed reservations %+v, want 3 with expiries %s, %s, %s", list, later, later, past)
+	}
+	if got, want := listAmounts(), []uint64{10, 20, 30}; !testutil.DeepEqual(got, want) {
+		t.Errorf("listed amounts %v, want %v", got, want)
+	}
+
+	err := m.utxoDB.ExpireReservations(ctx)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	check("after expiring", 3, 1, 0, 2, 30)
+	if got, want := listAmounts(), []uint64{10, 20}; !testutil.DeepEqual(got, want) {
+		t.Errorf("listed amounts after expiring %v, want %v", got, want)
+	}
+
+	if n := m.CancelTemplateReservations(ctx, tpl); n != 1 {
+		t.Fatalf("canceled %d reservations, want 1", n)
+	}
+	check("after canceling", 3, 1, 1, 1, 10)
+	if got, want := listAmounts(), []uint64{10}; !testutil.DeepEqual(got, want) {
+		t.Errorf("listed amounts after canceling %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,89 @@
+package account
+
+import (
+	"context"
+	"expvar"
+	"time"
+
+	"chain/errors"
+	"chain/log"
+)
+
+var (
+	// controlProgramSweeps counts the runs of the control program
+	// sweeper, and controlProgramsSwept the programs they marked
+	// inactive.
+	controlProgramSweeps = expvar.NewInt("account_control_program_sweeps")
+	controlProgramsSwept = expvar.NewInt("account_control_programs_swept")
+)
+
+// SetMaxUnusedAge sets how long a control program without an
+// expiration may go without receiving funds before the sweeper
+// marks it inactive. Zero, the default, means such programs are
+// never swept.
+//
+// A program's age is measured from its created_at column. Programs
+// created before that column was added got the time of the
+// migration, so their age counts from the upgrade, not from when
+// they were handed out.
+func (m *Manager) SetMaxUnusedAge(d time.Duration) {
+	m.maxUnusedAge = d
+}
+
+// SweepControlPrograms periodically marks inactive the control
+// programs that never received funds and either have expired or
+// are older than the maximum unused age. Inactive programs are left
+// out of receiver listings, but payments to them are still
+// recognized: before expiration they credit the account, and after
+// it they are annotated as expired. The sweeper is the only process
+// that acts on expiration; expired programs are never deleted.
+// It blocks until the context is canceled.
+//
+// It must only be run by the leader.
+func (m *Manager) SweepControlPrograms(ctx context.Context, period time.Duration) {
+	ticks := time.Tick(period)
+	for {
+		select {
+		case <-ctx.Done():
+			log.Printf(ctx, "Deposed, SweepControlPrograms exiting")
+			return
+		case <-ticks:
+			_, err := m.sweepControlPrograms(ctx)
+			if err != nil {
+				log.Error(ctx, err)
+			}
+		}
+	}
+}
+
+// sweepControlPrograms marks eligible control programs inactive
+// and returns how many it marked.
+func (m *Manager) sweepControlPrograms(ctx context.Context) (int64, error) {
+	now := m.now()
+	var unusedBefore *time.Time
+	if m.maxUnusedAge > 0 {
+		t := now.Add(-m.maxUnusedAge)
+		unusedBefore = &t
+	}
+
+	// Programs that have received funds are never swept, no matter
+	// their age or expiration.
+	const q = `
+		UPDATE account_control_programs SET inactive = true
+		WHERE NOT inactive AND NOT used AND (
+			(expires_at IS NOT NULL AND expires_at < $1)
+			OR (expires_at IS NULL AND created_at < $2)
+		)
+	`
+	res, err := m.db.ExecContext(ctx, q, now, unusedBefore)
+	if err != nil {
+		return 0, errors.Wrap(err, "sweeping control programs")
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, errors.Wrap(err, "sweeping control programs")
+	}
+	controlProgramSweeps.Add(1)
+	controlProgramsSwept.Add(n)
+	return n, nil
+}
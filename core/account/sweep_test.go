@@ -0,0 +1,157 @@
+package account
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"chain/core/query"
+	"chain/database/pg"
+	"chain/database/pg/pgtest"
+	"chain/protocol/bc"
+	"chain/protocol/bc/legacy"
+	"chain/protocol/prottest"
+	"chain/testutil"
+)
+
+func TestSweepControlPrograms(t *testing.T) {
+	db := pgtest.NewTx(t)
+	m := NewManager(db, prottest.NewChain(t), nil)
+	ctx := context.Background()
+
+	now := time.Now().Truncate(time.Second)
+	m.now = func() time.Time { return now }
+	m.SetMaxUnusedAge(time.Hour)
+
+	acc := m.createTestAccount(ctx, t, "", nil)
+	create := func(expiresAt time.Time) string {
+		cp, err := m.CreateControlProgram(ctx, acc.ID, false, expiresAt)
+		if err != nil {
+			testutil.FatalErr(t, err)
+		}
+		return string(cp)
+	}
+	expiring := create(now.Add(time.Hour))
+	expiringUsed := create(now.Add(time.Hour))
+	later := create(now.Add(24 * time.Hour))
+	old := create(time.Time{})
+	oldUsed := create(time.Time{})
+
+	err := m.markControlProgramsUsed(ctx, []*accountOutput{
+		{rawOutput: rawOutput{ControlProgram: []byte(expiringUsed)}},
+		{rawOutput: rawOutput{ControlProgram: []byte(oldUsed)}},
+	})
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+
+	// Nothing has expired or gone unused for an hour yet.
+	n, err := m.sweepControlPrograms(ctx)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	if n != 0 {
+		t.Errorf("swept %d programs before advancing the clock, want 0", n)
+	}
+
+	now = now.Add(90 * time.Minute)
+	recent := create(time.Time{})
+	now = now.Add(30 * time.Minute)
+
+	sweeps, swept := controlProgramSweeps.Value(), controlProgramsSwept.Value()
+	n, err = m.sweepControlPrograms(ctx)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	if n != 2 {
+		t.Errorf("swept %d programs, want 2", n)
+	}
+	if got := controlProgramSweeps.Value() - sweeps; got != 1 {
+		t.Errorf("sweeps metric increased by %d, want 1", got)
+	}
+	if got := controlProgramsSwept.Value() - swept; got != 2 {
+		t.Errorf("swept metric increased by %d, want 2", got)
+	}
+
+	inactive := make(map[string]bool)
+	const q = `SELECT control_program, inactive FROM account_control_programs WHERE signer_id = $1`
+	err = pg.ForQueryRows(ctx, db, q, acc.ID, func(program []byte, isInactive bool) {
+		inactive[string(program)] = isInactive
+	})
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	want := map[string]bool{
+		expiring:     true,
+		expiringUsed: false,
+		later:        false,
+		old:          true,
+		oldUsed:      false,
+		recent:       false,
+	}
+	if !testutil.DeepEqual(inactive, want) {
+		t.Errorf("inactive programs = %v want %v", inactive, want)
+	}
+
+	receivers, err := m.ListReceivers(ctx, acc.ID, "")
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	var listed []string
+	for _, r := range receivers {
+		listed = append(listed, string(r.ControlProgram))
+	}
+	wantListed := []string{later, oldUsed, recent}
+	if !testutil.DeepEqual(listed, wantListed) {
+		t.Errorf("listed receivers = %x want %x", listed, wantListed)
+	}
+
+	// A later payment to a swept program reactivates it.
+	err = m.markControlProgramsUsed(ctx, []*accountOutput{
+		{rawOutput: rawOutput{ControlProgram: []byte(old)}},
+	})
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	var isInactive bool
+	err = db.QueryRowContext(ctx, `SELECT inactive FROM account_control_programs WHERE control_program = $1`, []byte(old)).Scan(&isInactive)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	if isInactive {
+		t.Error("used program still inactive after receiving funds")
+	}
+
+	// A payment to a swept program after it expired is refused, and
+	// annotated as expired.
+	paidTx := legacy.NewTx(legacy.TxData{
+		Outputs: []*legacy.TxOutput{legacy.NewTxOutput(bc.AssetID{}, 1, []byte(expiring), nil)},
+	})
+	paid := &legacy.Block{
+		BlockHeader:  legacy.BlockHeader{Height: 2, TimestampMS: bc.Millis(now)},
+		Transactions: []*legacy.Tx{paidTx},
+	}
+	err = m.indexAccountUTXOs(ctx, paid)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	var n64 int64
+	err = db.QueryRowContext(ctx, `SELECT count(*) FROM account_utxos WHERE control_program = $1`, []byte(expiring)).Scan(&n64)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	if n64 != 0 {
+		t.Errorf("indexed %d utxos paying an expired program, want 0", n64)
+	}
+	txs := []*query.AnnotatedTx{{
+		Timestamp: paid.Time(),
+		Outputs:   []*query.AnnotatedOutput{{OutputID: *paidTx.OutputID(0), ControlProgram: []byte(expiring)}},
+	}}
+	err = m.AnnotateTxs(ctx, txs)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	if out := txs[0].Outputs[0]; out.Purpose != "expired" || out.AccountID != "" {
+		t.Errorf("output paying expired program: purpose = %q account = %q, want expired and no account", out.Purpose, out.AccountID)
+	}
+}
@@ -5,9 +5,11 @@ import (
 	"sync"
 
 	"chain/core/account"
+	"chain/core/leader"
 	"chain/crypto/ed25519/chainkd"
 	"chain/net/http/httpjson"
 	"chain/net/http/reqid"
+	"chain/protocol/bc/legacy"
 )
 
 // POST /create-account
@@ -79,3 +81,82 @@ func (a *API) updateAccountTags(ctx context.Context, ins []struct {
 	wg.Wait()
 	return responses
 }
+
+// POST /update-account-alias
+//
+// Renaming an account doesn't change the account alias recorded in
+// transactions and outputs already indexed for queries.
+func (a *API) updateAccountAlias(ctx context.Context, ins []struct {
+	ID       *string
+	Alias    *string
+	NewAlias string `json:"new_alias"`
+}) interface{} {
+	responses := make([]interface{}, len(ins))
+	var wg sync.WaitGroup
+	wg.Add(len(responses))
+
+	for i := range responses {
+		go func(i int) {
+			subctx := reqid.NewSubContext(ctx, reqid.New())
+			defer wg.Done()
+			defer batchRecover(subctx, &responses[i])
+
+			err := a.accounts.UpdateAlias(subctx, ins[i].ID, ins[i].Alias, ins[i].NewAlias)
+			if err != nil {
+				responses[i] = err
+			} else {
+				responses[i] = httpjson.DefaultResponse
+			}
+		}(i)
+	}
+
+	wg.Wait()
+	return responses
+}
+
+// POST /delete-account
+//
+// An account can only be deleted once it has no balance in any
+// asset and no reserved outputs.
+func (a *API) deleteAccount(ctx context.Context, ins []struct {
+	ID    *string
+	Alias *string
+}) (interface{}, error) {
+	// Reservations and the pending tx pool are held in memory by
+	// the leader.
+	if a.leader.State() != leader.Leading {
+		var resp interface{}
+		err := a.forwardToLeader(ctx, "/delete-account", ins, &resp)
+		return resp, err
+	}
+
+	// A core that isn't the generator can't see the pool; only
+	// confirmed outputs count toward its balances. Payments it
+	// misses are still indexed under the deleted account's ID.
+	var pool []*legacy.Tx
+	if a.generator != nil {
+		pool = a.generator.PendingTxs()
+	}
+
+	responses := make([]interface{}, len(ins))
+	var wg sync.WaitGroup
+	wg.Add(len(responses))
+
+	for i := range responses {
+		go func(i int) {
+			subctx := reqid.NewSubContext(ctx, reqid.New())
+			defer wg.Done()
+			defer batchRecover(subctx, &responses[i])
+
+			err := a.accounts.Delete(subctx, ins[i].ID, ins[i].Alias, pool)
+			if err != nil {
+				responses[i] = err
+			} else {
+				responses[i] = httpjson.DefaultResponse
+			}
+		}(i)
+	}
+
+	wg.Wait()
+	return responses, nil
+}
@@ -8,8 +8,11 @@ import (
 	"crypto/x509/pkix"
 	"expvar"
 	"fmt"
+	"math"
+	"net"
 	"net/http"
 	"net/http/pprof"
+	"net/url"
 	"sync"
 	"time"
 
@@ -77,16 +80,29 @@ type API struct {
 	addr            string
 	signer          func(context.Context, *legacy.Block) ([]byte, error)
 	requestLimits   []requestLimit
+	tokenLimiter    *limit.BucketLimiter
 	generator       *generator.Generator
 	replicator      *fetch.Replicator
 	remoteGenerator *rpc.Client
 	indexTxs        bool
+	minTxTTL        time.Duration
+	maxTxTTL        time.Duration
 	internalSubj    pkix.Name
 	httpClient      *http.Client
+	useTLS          bool
+	devHSM          config.DevHSM
 
 	downloadingSnapshotMu sync.Mutex
 	downloadingSnapshot   *fetch.SnapshotProgress
 
+	// leadCtx is the context of this process's current term as
+	// leader. Background jobs, such as /recover, run in it.
+	leadCtxMu sync.Mutex
+	leadCtx   context.Context
+
+	recoveryMu sync.Mutex
+	recovery   *recoveryProgress // see /recover-progress
+
 	healthMu     sync.Mutex
 	healthErrors map[string]string
 }
@@ -101,9 +117,8 @@ type leaderProcess interface {
 }
 
 type requestLimit struct {
-	key       func(*http.Request) string
-	burst     int
-	perSecond int
+	key     func(*http.Request) string
+	limiter *limit.BucketLimiter
 }
 
 func maxBytes(h http.Handler) http.Handler {
@@ -141,12 +156,20 @@ func (a *API) buildHandler() {
 
 	m.Handle("/create-account", needConfig(a.createAccount))
 	m.Handle("/create-asset", needConfig(a.createAsset))
+	m.Handle("/create-assets", needConfig(a.createAssets))
 	m.Handle("/update-account-tags", needConfig(a.updateAccountTags))
+	m.Handle("/update-account-alias", needConfig(a.updateAccountAlias))
+	m.Handle("/delete-account", needConfig(a.deleteAccount))
 	m.Handle("/update-asset-tags", needConfig(a.updateAssetTags))
 	m.Handle("/build-transaction", needConfig(a.build))
 	m.Handle("/submit-transaction", needConfig(a.submit))
+	m.Handle("/cancel-reservation", needConfig(a.cancelReservation))
+	m.Handle("/list-reservations", needConfig(a.listReservations))
+	m.Handle("/merge-transaction-templates", needConfig(a.mergeTemplates))
+	m.Handle("/validate-transaction-template", needConfig(a.validateTemplate))
 	m.Handle("/create-control-program", needConfig(a.createControlProgram)) // DEPRECATED
 	m.Handle("/create-account-receiver", needConfig(a.createAccountReceiver))
+	m.Handle("/list-account-receivers", needConfig(a.listAccountReceivers))
 	m.Handle("/create-transaction-feed", needConfig(a.createTxFeed))
 	m.Handle("/get-transaction-feed", needConfig(a.getTxFeed))
 	m.Handle("/update-transaction-feed", needConfig(a.updateTxFeed))
@@ -156,9 +179,15 @@ func (a *API) buildHandler() {
 	m.Handle("/list-assets", needConfig(a.listAssets))
 	m.Handle("/list-transaction-feeds", needConfig(a.listTxFeeds))
 	m.Handle("/list-transactions", needConfig(a.listTransactions))
+	m.Handle("/aggregate-transactions", needConfig(a.aggregateTransactions))
 	m.Handle("/list-balances", needConfig(a.listBalances))
 	m.Handle("/list-unspent-outputs", needConfig(a.listUnspentOutputs))
+	m.Handle("/list-pool-transactions", needConfig(a.listPoolTxs))
 	m.Handle("/reset", resetAllowed(needConfig(a.reset)))
+	m.Handle("/reindex", needConfig(a.reindex))
+	m.Handle("/reindex-progress", needConfig(a.reindexProgress))
+	m.Handle("/recover", needConfig(a.recoverState))
+	m.Handle("/recover-progress", needConfig(a.recoverProgress))
 
 	m.Handle(crosscoreRPCPrefix+"submit", needConfig(func(ctx context.Context, tx *legacy.Tx) error {
 		return a.submitter.Submit(ctx, tx)
@@ -180,11 +209,13 @@ func (a *API) buildHandler() {
 	m.Handle("/create-access-token", jsonHandler(a.createAccessToken))
 	m.Handle("/list-access-tokens", jsonHandler(a.listAccessTokens))
 	m.Handle("/delete-access-token", jsonHandler(a.deleteAccessToken))
+	m.Handle("/update-access-token-limit", jsonHandler(a.updateAccessTokenLimit))
 	m.Handle("/add-allowed-member", jsonHandler(a.addAllowedMember))
 	m.Handle("/init-cluster", jsonHandler(a.initCluster))
 	m.Handle("/join-cluster", jsonHandler(a.joinCluster))
 	m.Handle("/evict", jsonHandler(a.evict))
 	m.Handle("/configure", jsonHandler(a.configure))
+	m.Handle("/update-generator-config", needConfig(a.updateGeneratorConfig))
 	m.Handle("/config", jsonHandler(a.retrieveConfig))
 	m.Handle("/info", jsonHandler(a.info))
 
@@ -205,7 +236,7 @@ func (a *API) buildHandler() {
 	handler = webAssetsHandler(handler)
 	handler = healthHandler(handler)
 	for _, l := range a.requestLimits {
-		handler = limit.Handler(handler, alwaysError(errRateLimited), l.perSecond, l.burst, l.key)
+		handler = limit.LimiterHandler(handler, http.HandlerFunc(rateLimited), l.limiter, l.key)
 	}
 	handler = gzip.Handler{Handler: handler}
 	handler = coreCounter(handler)
@@ -238,6 +269,10 @@ type requestQuery struct {
 	StartTimeMS uint64 `json:"start_time,omitempty"`
 	EndTimeMS   uint64 `json:"end_time,omitempty"`
 
+	// Text is used for text searches by /list-transactions
+	// and /list-assets.
+	Text string `json:"text,omitempty"`
+
 	// This is used for point-in-time queries like /list-balances
 	// TODO(bobg): Different request structs for endpoints with different needs
 	TimestampMS uint64 `json:"timestamp,omitempty"`
@@ -272,13 +307,19 @@ func AuthHandler(handler http.Handler, sdb *sinkdb.DB, accessTokens *accesstoken
 	authorizer := authz.NewAuthorizer(
 		grantStore(sdb, extraGrants, subj),
 		policyByRoute,
+		policiesByScope,
 	)
 	authenticator := authn.NewAPI(accessTokens, crosscoreRPCPrefix, rootCAs)
 
 	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
 		// TODO(tessr): check that this path exists; return early if this path isn't legit
 		req, err := authenticator.Authenticate(req)
-		if err != nil {
+		if errors.Root(err) == authn.ErrTokenType {
+			// The request was authenticated, but with the wrong
+			// kind of access token for this route.
+			errorFormatter.Write(req.Context(), rw, err)
+			return
+		} else if err != nil {
 			err = errors.Sub(errNotAuthenticated, err)
 			errorFormatter.Write(req.Context(), rw, err)
 			return
@@ -375,26 +416,75 @@ func (a *API) leaderSignHandler(f func(context.Context, *legacy.Block) ([]byte,
 // process. It relies on a.httpClient's TLS configuration for authenticating
 // with the leader cored. The internal policy must be authorized for the
 // provided path.
+//
+// Right after an election, the leader's address may be stale
+// or the new leader may not be ready yet, so forwardToLeader
+// retries a few times before returning leader.ErrNoLeader.
 func (a *API) forwardToLeader(ctx context.Context, path string, body interface{}, resp interface{}) error {
+	var err error
+	for attempt := uint(0); attempt < forwardAttempts; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-ctx.Done():
+				return errors.Wrap(ctx.Err())
+			case <-time.After(forwardBackoff << (attempt - 1)):
+			}
+		}
+		err = a.callLeader(ctx, path, body, resp)
+		if !isLeaderElection(err) {
+			return err
+		}
+	}
+	log.Printkv(ctx, log.KeyError, err, "at", "forwarding request to leader", "path", path)
+	return leader.ErrNoLeader
+}
+
+const (
+	forwardAttempts = 4
+	forwardBackoff  = 100 * time.Millisecond
+)
+
+var errLeaderIsSelf = errors.New("leader address is our own address")
+
+func (a *API) callLeader(ctx context.Context, path string, body interface{}, resp interface{}) error {
 	addr, err := a.leader.Address(ctx)
 	if err != nil {
 		return errors.Wrap(err)
 	}
 
 	// Don't infinite loop if the leader's address is our own address.
-	// This is possible if we just became the leader. The client should
-	// just retry.
+	// This is possible if we just became the leader.
 	if addr == a.addr {
-		return leader.ErrNoLeader
+		return errLeaderIsSelf
 	}
 
+	scheme := "http://"
+	if a.useTLS {
+		scheme = "https://"
+	}
 	l := &rpc.Client{
-		BaseURL: "https://" + addr,
+		BaseURL: scheme + addr,
 		Client:  a.httpClient,
 	}
 	return l.Call(ctx, path, body, resp)
 }
 
+// isLeaderElection returns whether err indicates that
+// the leader could not be reached because of an election
+// in progress, and that the request never reached it.
+func isLeaderElection(err error) bool {
+	switch root := errors.Root(err).(type) {
+	case nil:
+		return false
+	case rpc.ErrStatusCode:
+		return root.ErrorData != nil && root.ErrorData.ChainCode == errorFormatter.Errors[leader.ErrNoLeader].ChainCode
+	case *url.Error:
+		opErr, ok := root.Err.(*net.OpError)
+		return ok && opErr.Op == "dial"
+	}
+	return errors.Root(err) == errLeaderIsSelf || errors.Root(err) == leader.ErrNoLeader
+}
+
 func healthHandler(handler http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
 		if req.URL.Path == "/health" {
@@ -416,6 +506,16 @@ func alwaysError(err error) http.Handler {
 	return jsonHandler(func() error { return err })
 }
 
+// rateLimited responds with errRateLimited, including
+// a hint of how many seconds to wait before retrying.
+func rateLimited(w http.ResponseWriter, req *http.Request) {
+	err := errRateLimited
+	if wait, ok := limit.RetryAfter(req.Context()); ok {
+		err = errors.WithData(err, "retry_after", math.Ceil(wait.Seconds()))
+	}
+	errorFormatter.Write(req.Context(), w, err)
+}
+
 func batchRecover(ctx context.Context, v *interface{}) {
 	if r := recover(); r != nil {
 		var err error
@@ -2,9 +2,12 @@ package core
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"net/http/httptest"
 	"testing"
 	"time"
 
@@ -373,3 +376,77 @@ func (al alwaysLeader) Address(context.Context) (string, error) {
 func (al alwaysLeader) State() leader.ProcessState {
 	return leader.Leading
 }
+
+type fixedLeader string
+
+func (l fixedLeader) Address(context.Context) (string, error) {
+	return string(l), nil
+}
+
+func (l fixedLeader) State() leader.ProcessState {
+	return leader.Following
+}
+
+func TestForwardToLeaderRetry(t *testing.T) {
+	ctx := context.Background()
+	var calls, failures int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		calls++
+		if calls <= failures {
+			// Not ready to lead yet.
+			errorFormatter.Write(req.Context(), w, leader.ErrNoLeader)
+			return
+		}
+		w.Write([]byte(`"ok"`))
+	}))
+	defer srv.Close()
+	a := &API{leader: fixedLeader(srv.Listener.Addr().String()), addr: "self"}
+
+	failures = 1
+	var resp string
+	err := a.forwardToLeader(ctx, "/info", nil, &resp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp != "ok" || calls != 2 {
+		t.Errorf("forwardToLeader = %q after %d calls, want %q after 2 calls", resp, calls, "ok")
+	}
+
+	calls, failures = 0, forwardAttempts
+	err = a.forwardToLeader(ctx, "/info", nil, &resp)
+	if errors.Root(err) != leader.ErrNoLeader {
+		t.Errorf("forwardToLeader error = %v want %v", err, leader.ErrNoLeader)
+	}
+	if calls != forwardAttempts {
+		t.Errorf("forwardToLeader made %d calls, want %d", calls, forwardAttempts)
+	}
+
+	// A leader that never accepts connections.
+	a.leader = fixedLeader("127.0.0.1:1")
+	err = a.forwardToLeader(ctx, "/info", nil, &resp)
+	if errors.Root(err) != leader.ErrNoLeader {
+		t.Errorf("forwardToLeader error = %v want %v", err, leader.ErrNoLeader)
+	}
+}
+
+func TestForwardToLeaderTLS(t *testing.T) {
+	ctx := context.Background()
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Write([]byte(`"ok"`))
+	}))
+	defer srv.Close()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(srv.Certificate())
+	a := &API{leader: fixedLeader(srv.Listener.Addr().String()), addr: "self"}
+	UseTLS(&tls.Config{Certificates: srv.TLS.Certificates, RootCAs: roots})(a)
+
+	var resp string
+	err := a.forwardToLeader(ctx, "/info", nil, &resp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp != "ok" {
+		t.Errorf("forwardToLeader = %q want %q", resp, "ok")
+	}
+}
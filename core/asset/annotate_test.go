@@ -25,14 +25,14 @@ func TestAnnotateTxs(t *testing.T) {
 	rawdef1 := json.RawMessage(`{
   "baz": "bar"
 }`)
-	asset1, err := reg.Define(ctx, []chainkd.XPub{testutil.TestXPub}, 1, def1, "", tags1, "")
+	asset1, err := reg.Define(ctx, []chainkd.XPub{testutil.TestXPub}, 1, def1, "", tags1, 0, "")
 	if err != nil {
 		t.Fatal(err)
 	}
 
 	tags2 := map[string]interface{}{"foo": "baz"}
 	rawtags2 := json.RawMessage(`{"foo": "baz"}`)
-	asset2, err := reg.Define(ctx, []chainkd.XPub{testutil.TestXPub}, 1, nil, "", tags2, "")
+	asset2, err := reg.Define(ctx, []chainkd.XPub{testutil.TestXPub}, 1, nil, "", tags2, 0, "")
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -75,3 +75,39 @@ func TestAnnotateTxs(t *testing.T) {
 		t.Errorf("got:\n%s\nwant:\n%s", spew.Sdump(txs), spew.Sdump(want))
 	}
 }
+
+// TestAnnotateTxsTagsUpdatedElsewhere checks that annotation
+// doesn't use tags cached before another process updated them.
+func TestAnnotateTxsTagsUpdatedElsewhere(t *testing.T) {
+	db := pgtest.NewTx(t)
+	c := prottest.NewChain(t)
+	reg := NewRegistry(db, c, nil)
+	other := NewRegistry(db, c, nil)
+	ctx := context.Background()
+
+	asset, err := reg.Define(ctx, []chainkd.XPub{testutil.TestXPub}, 1, nil, "", map[string]interface{}{"v": "1"}, 0, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = reg.findByID(ctx, asset.AssetID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	id := asset.AssetID.String()
+	err = other.UpdateTags(ctx, &id, nil, map[string]interface{}{"v": "2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	txs := []*query.AnnotatedTx{{Outputs: []*query.AnnotatedOutput{{AssetID: asset.AssetID}}}}
+	err = reg.AnnotateTxs(ctx, txs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := string(*txs[0].Outputs[0].AssetTags)
+	want := `{"v": "2"}`
+	if got != want {
+		t.Errorf("asset tags = %s want %s", got, want)
+	}
+}
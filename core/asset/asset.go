@@ -7,7 +7,10 @@ import (
 	"database/sql"
 	"encoding/json"
 	"fmt"
+	"math"
+	"strings"
 	"sync"
+	"time"
 
 	"golang.org/x/crypto/sha3"
 
@@ -17,6 +20,7 @@ import (
 
 	"chain/core/pin"
 	"chain/core/signers"
+	"chain/core/txbuilder"
 	"chain/crypto/ed25519"
 	"chain/crypto/ed25519/chainkd"
 	"chain/database/pg"
@@ -56,14 +60,72 @@ type Registry struct {
 	aliasGroup singleflight.Group
 
 	cacheMu    sync.Mutex
-	cache      *lru.Cache
-	aliasCache *lru.Cache
+	cache      *lru.Cache // asset ID -> cachedAsset
+	aliasCache *lru.Cache // lowercase alias -> asset ID
+	cacheTTL   time.Duration
 }
 
 func (reg *Registry) IndexAssets(indexer Saver) {
 	reg.indexer = indexer
 }
 
+// cachedAsset is an entry in the registry's asset cache.
+type cachedAsset struct {
+	asset   *Asset
+	expires time.Time // zero if it doesn't expire
+}
+
+// SetCacheTTL makes cached asset records expire d after they're
+// cached, so that changes made by other processes, such as updated
+// tags, eventually show up in this one. Zero, the default, means
+// they don't expire. Transaction annotation doesn't use the cache,
+// so it always sees current tags.
+func (reg *Registry) SetCacheTTL(d time.Duration) {
+	reg.cacheMu.Lock()
+	reg.cacheTTL = d
+	reg.cacheMu.Unlock()
+}
+
+// Flush empties the registry's cache of asset records.
+func (reg *Registry) Flush() {
+	reg.cacheMu.Lock()
+	reg.cache = lru.New(maxAssetCache)
+	reg.aliasCache = lru.New(maxAssetCache)
+	reg.cacheMu.Unlock()
+}
+
+// cached returns the cached record of the asset with the given ID,
+// if there is one and it hasn't expired.
+func (reg *Registry) cached(id bc.AssetID) (*Asset, bool) {
+	reg.cacheMu.Lock()
+	defer reg.cacheMu.Unlock()
+	v, ok := reg.cache.Get(id)
+	if !ok {
+		return nil, false
+	}
+	entry := v.(cachedAsset)
+	if !entry.expires.IsZero() && time.Now().After(entry.expires) {
+		reg.cache.Remove(id)
+		return nil, false
+	}
+	return entry.asset, true
+}
+
+// addToCache caches a, replacing any cached record of the same asset.
+// If alias is not empty, it also caches the asset's ID by alias.
+func (reg *Registry) addToCache(a *Asset, alias string) {
+	reg.cacheMu.Lock()
+	defer reg.cacheMu.Unlock()
+	entry := cachedAsset{asset: a}
+	if reg.cacheTTL > 0 {
+		entry.expires = time.Now().Add(reg.cacheTTL)
+	}
+	reg.cache.Add(a.AssetID, entry)
+	if alias != "" {
+		reg.aliasCache.Add(strings.ToLower(alias), a.AssetID)
+	}
+}
+
 type Asset struct {
 	AssetID          bc.AssetID
 	Alias            *string
@@ -72,6 +134,7 @@ type Asset struct {
 	InitialBlockHash bc.Hash
 	Signer           *signers.Signer
 	Tags             map[string]interface{}
+	MaxIssuance      uint64 // 0 means no maximum
 	rawDefinition    []byte
 	definition       map[string]interface{}
 	sortID           string
@@ -101,9 +164,80 @@ func (asset *Asset) SetDefinition(def map[string]interface{}) error {
 	return nil
 }
 
-// Define defines a new Asset.
-func (reg *Registry) Define(ctx context.Context, xpubs []chainkd.XPub, quorum int, definition map[string]interface{}, alias string, tags map[string]interface{}, clientToken string) (*Asset, error) {
-	assetSigner, err := signers.Create(ctx, reg.db, "asset", xpubs, quorum, clientToken)
+// Define defines a new Asset. If maxIssuance is nonzero, this core
+// won't build or submit issuances that would bring the total issued
+// units of the asset above it.
+func (reg *Registry) Define(ctx context.Context, xpubs []chainkd.XPub, quorum int, definition map[string]interface{}, alias string, tags map[string]interface{}, maxIssuance uint64, clientToken string) (*Asset, error) {
+	asset, err := reg.define(ctx, reg.db, xpubs, quorum, definition, alias, tags, maxIssuance, clientToken)
+	if err != nil {
+		return nil, err
+	}
+
+	err = reg.indexAnnotatedAsset(ctx, asset)
+	if err != nil {
+		return nil, errors.Wrap(err, "indexing annotated asset")
+	}
+
+	return asset, nil
+}
+
+// A Definition describes an asset for DefineBatch. Its fields are
+// the arguments to Define.
+type Definition struct {
+	XPubs       []chainkd.XPub
+	Quorum      int
+	Definition  map[string]interface{}
+	Alias       string
+	Tags        map[string]interface{}
+	MaxIssuance uint64
+	ClientToken string
+}
+
+// DefineBatch defines the assets in defs in one database transaction.
+// Either all of them are defined or, if one fails, none are, and the
+// error's "index" data says which one failed. The new assets' signers
+// get contiguous key indexes in the order of defs. As with Define, a
+// definition with a client token that was used before returns the
+// existing asset, so a batch can be safely replayed.
+func (reg *Registry) DefineBatch(ctx context.Context, defs []Definition) ([]*Asset, error) {
+	assets := make([]*Asset, len(defs))
+	err := pg.Atomically(ctx, reg.db, func(db pg.DB) error {
+		// Keep other transactions from allocating signer
+		// key indexes until this one is done.
+		_, err := db.ExecContext(ctx, `LOCK TABLE signers IN EXCLUSIVE MODE`)
+		if err != nil {
+			return errors.Wrap(err, "locking signers")
+		}
+		for i, d := range defs {
+			assets[i], err = reg.define(ctx, db, d.XPubs, d.Quorum, d.Definition, d.Alias, d.Tags, d.MaxIssuance, d.ClientToken)
+			if err != nil {
+				return errors.WithData(err, "index", i)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// The query indexer has its own database handle, so index
+	// the assets once they're committed.
+	for _, a := range assets {
+		err = reg.indexAnnotatedAsset(ctx, a)
+		if err != nil {
+			return nil, errors.Wrap(err, "indexing annotated asset")
+		}
+	}
+	return assets, nil
+}
+
+// define stores a new asset in db, without indexing it.
+func (reg *Registry) define(ctx context.Context, db pg.DB, xpubs []chainkd.XPub, quorum int, definition map[string]interface{}, alias string, tags map[string]interface{}, maxIssuance uint64, clientToken string) (*Asset, error) {
+	if maxIssuance > math.MaxInt64 {
+		return nil, errors.WithDetail(txbuilder.ErrBadAmount, "max issuance is too large")
+	}
+
+	assetSigner, err := signers.Create(ctx, db, "asset", xpubs, quorum, clientToken)
 	if err != nil {
 		return nil, err
 	}
@@ -131,26 +265,22 @@ func (reg *Registry) Define(ctx context.Context, xpubs []chainkd.XPub, quorum in
 		AssetID:          bc.ComputeAssetID(issuanceProgram, &reg.initialBlockHash, vmver, &defhash),
 		Signer:           assetSigner,
 		Tags:             tags,
+		MaxIssuance:      maxIssuance,
 	}
 	if alias != "" {
 		asset.Alias = &alias
 	}
 
-	asset, err = reg.insertAsset(ctx, asset, clientToken)
+	asset, err = insertAsset(ctx, db, asset, clientToken)
 	if err != nil {
 		return nil, errors.Wrap(err, "inserting asset")
 	}
 
-	err = insertAssetTags(ctx, reg.db, asset.AssetID, tags)
+	err = insertAssetTags(ctx, db, asset.AssetID, tags)
 	if err != nil {
 		return nil, errors.Wrap(err, "inserting asset tags")
 	}
 
-	err = reg.indexAnnotatedAsset(ctx, asset)
-	if err != nil {
-		return nil, errors.Wrap(err, "indexing annotated asset")
-	}
-
 	return asset, nil
 }
 
@@ -186,9 +316,12 @@ func (reg *Registry) UpdateTags(ctx context.Context, id, alias *string, tags map
 		}
 	}
 
-	// Revise tags in-memory
+	// Revise tags in-memory. The found record may be shared
+	// through the cache, so change a copy.
 
-	asset.Tags = tags
+	updated := *asset
+	updated.Tags = tags
+	asset = &updated
 
 	// Perform persistent updates
 
@@ -204,20 +337,15 @@ func (reg *Registry) UpdateTags(ctx context.Context, id, alias *string, tags map
 
 	// Revise cache
 
-	reg.cacheMu.Lock()
-	reg.cache.Add(asset.AssetID, asset)
-	reg.cacheMu.Unlock()
+	reg.addToCache(asset, "")
 
 	return nil
 }
 
 // findByID retrieves an Asset record along with its signer, given an assetID.
 func (reg *Registry) findByID(ctx context.Context, id bc.AssetID) (*Asset, error) {
-	reg.cacheMu.Lock()
-	cached, ok := reg.cache.Get(id)
-	reg.cacheMu.Unlock()
-	if ok {
-		return cached.(*Asset), nil
+	if cached, ok := reg.cached(id); ok {
+		return cached, nil
 	}
 
 	untypedAsset, err := reg.idGroup.Do(id.String(), func() (interface{}, error) {
@@ -228,24 +356,23 @@ func (reg *Registry) findByID(ctx context.Context, id bc.AssetID) (*Asset, error
 	}
 
 	asset := untypedAsset.(*Asset)
-	reg.cacheMu.Lock()
-	reg.cache.Add(id, asset)
-	reg.cacheMu.Unlock()
+	reg.addToCache(asset, "")
 	return asset, nil
 }
 
 // FindByAlias retrieves an Asset record along with its signer,
-// given an asset alias.
+// given an asset alias. Aliases are compared case-insensitively.
 func (reg *Registry) FindByAlias(ctx context.Context, alias string) (*Asset, error) {
+	key := strings.ToLower(alias)
 	reg.cacheMu.Lock()
-	cachedID, ok := reg.aliasCache.Get(alias)
+	cachedID, ok := reg.aliasCache.Get(key)
 	reg.cacheMu.Unlock()
 	if ok {
 		return reg.findByID(ctx, cachedID.(bc.AssetID))
 	}
 
-	untypedAsset, err := reg.aliasGroup.Do(alias, func() (interface{}, error) {
-		asset, err := assetQuery(ctx, reg.db, "assets.alias=$1", alias)
+	untypedAsset, err := reg.aliasGroup.Do(key, func() (interface{}, error) {
+		asset, err := assetQuery(ctx, reg.db, "lower(assets.alias)=lower($1)", alias)
 		return asset, err
 	})
 	if err != nil {
@@ -253,10 +380,7 @@ func (reg *Registry) FindByAlias(ctx context.Context, alias string) (*Asset, err
 	}
 
 	a := untypedAsset.(*Asset)
-	reg.cacheMu.Lock()
-	reg.aliasCache.Add(alias, a.AssetID)
-	reg.cache.Add(a.AssetID, a)
-	reg.cacheMu.Unlock()
+	reg.addToCache(a, alias)
 	return a, nil
 
 }
@@ -264,11 +388,11 @@ func (reg *Registry) FindByAlias(ctx context.Context, alias string) (*Asset, err
 // insertAsset adds the asset to the database. If the asset has a client token,
 // and there already exists an asset with that client token, insertAsset will
 // lookup and return the existing asset instead.
-func (reg *Registry) insertAsset(ctx context.Context, asset *Asset, clientToken string) (*Asset, error) {
+func insertAsset(ctx context.Context, db pg.DB, asset *Asset, clientToken string) (*Asset, error) {
 	const q = `
 		INSERT INTO assets
-			(id, alias, signer_id, initial_block_hash, vm_version, issuance_program, definition, client_token)
-		VALUES($1::bytea, $2, $3, $4, $5, $6, $7, $8)
+			(id, alias, signer_id, initial_block_hash, vm_version, issuance_program, definition, client_token, max_issuance)
+		VALUES($1::bytea, $2, $3, $4, $5, $6, $7, $8, $9)
 		ON CONFLICT (client_token) DO NOTHING
 		RETURNING sort_id
   `
@@ -281,12 +405,16 @@ func (reg *Registry) insertAsset(ctx context.Context, asset *Asset, clientToken
 		String: clientToken,
 		Valid:  clientToken != "",
 	}
+	maxIssuance := sql.NullInt64{
+		Int64: int64(asset.MaxIssuance),
+		Valid: asset.MaxIssuance > 0,
+	}
 
-	err := reg.db.QueryRowContext(
+	err := db.QueryRowContext(
 		ctx, q,
 		asset.AssetID, asset.Alias, signerID,
 		asset.InitialBlockHash, asset.VMVersion, asset.IssuanceProgram,
-		asset.rawDefinition, nullToken,
+		asset.rawDefinition, nullToken, maxIssuance,
 	).Scan(&asset.sortID)
 
 	if pg.IsUniqueViolation(err) {
@@ -294,7 +422,7 @@ func (reg *Registry) insertAsset(ctx context.Context, asset *Asset, clientToken
 	} else if err == sql.ErrNoRows && clientToken != "" {
 		// There is already an asset with the provided client
 		// token. We should return the existing asset.
-		asset, err = assetByClientToken(ctx, reg.db, clientToken)
+		asset, err = assetByClientToken(ctx, db, clientToken)
 		if err != nil {
 			return nil, errors.Wrap(err, "retrieving existing asset")
 		}
@@ -332,7 +460,7 @@ func assetByClientToken(ctx context.Context, db pg.DB, clientToken string) (*Ass
 func assetQuery(ctx context.Context, db pg.DB, pred string, args ...interface{}) (*Asset, error) {
 	const baseQ = `
 		SELECT assets.id, assets.alias, assets.vm_version, assets.issuance_program, assets.definition,
-			assets.initial_block_hash, assets.sort_id,
+			assets.initial_block_hash, assets.sort_id, COALESCE(assets.max_issuance, 0),
 			signers.id, COALESCE(signers.type, ''), COALESCE(signers.xpubs, '{}'),
 			COALESCE(signers.quorum, 0), COALESCE(signers.key_index, 0),
 			asset_tags.tags
@@ -360,6 +488,7 @@ func assetQuery(ctx context.Context, db pg.DB, pred string, args ...interface{})
 		&a.rawDefinition,
 		&a.InitialBlockHash,
 		&a.sortID,
+		&a.MaxIssuance,
 		&signerID,
 		&signerType,
 		(*pq.ByteaArray)(&xpubs),
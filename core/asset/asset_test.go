@@ -2,12 +2,19 @@ package asset
 
 import (
 	"context"
+	"database/sql"
+	"fmt"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/davecgh/go-spew/spew"
 
+	"chain/core/txbuilder"
 	"chain/crypto/ed25519/chainkd"
+	"chain/database/pg"
 	"chain/database/pg/pgtest"
+	"chain/errors"
 	"chain/protocol/bc"
 	"chain/protocol/prottest"
 	"chain/testutil"
@@ -18,7 +25,7 @@ func TestDefineAsset(t *testing.T) {
 	ctx := context.Background()
 
 	keys := []chainkd.XPub{testutil.TestXPub}
-	asset, err := r.Define(ctx, keys, 1, nil, "", nil, "")
+	asset, err := r.Define(ctx, keys, 1, nil, "", nil, 0, "")
 	if err != nil {
 		testutil.FatalErr(t, err)
 	}
@@ -43,11 +50,11 @@ func TestDefineAssetIdempotency(t *testing.T) {
 	ctx := context.Background()
 	token := "test_token"
 	keys := []chainkd.XPub{testutil.TestXPub}
-	asset0, err := r.Define(ctx, keys, 1, nil, "alias", nil, token)
+	asset0, err := r.Define(ctx, keys, 1, nil, "alias", nil, 0, token)
 	if err != nil {
 		testutil.FatalErr(t, err)
 	}
-	asset1, err := r.Define(ctx, keys, 1, nil, "alias", nil, token)
+	asset1, err := r.Define(ctx, keys, 1, nil, "alias", nil, 0, token)
 	if err != nil {
 		testutil.FatalErr(t, err)
 	}
@@ -62,7 +69,7 @@ func TestFindAssetByID(t *testing.T) {
 	r := NewRegistry(pgtest.NewTx(t), prottest.NewChain(t), nil)
 	ctx := context.Background()
 	keys := []chainkd.XPub{testutil.TestXPub}
-	asset, err := r.Define(ctx, keys, 1, nil, "", nil, "")
+	asset, err := r.Define(ctx, keys, 1, nil, "", nil, 0, "")
 	if err != nil {
 		testutil.FatalErr(t, err)
 	}
@@ -82,7 +89,7 @@ func TestAssetByClientToken(t *testing.T) {
 	keys := []chainkd.XPub{testutil.TestXPub}
 	token := "test_token"
 
-	asset, err := r.Define(ctx, keys, 1, nil, "", nil, token)
+	asset, err := r.Define(ctx, keys, 1, nil, "", nil, 0, token)
 	if err != nil {
 		testutil.FatalErr(t, err)
 	}
@@ -95,3 +102,196 @@ func TestAssetByClientToken(t *testing.T) {
 		t.Fatalf("assetByClientToken(\"test_token\")=%x, want %x", found.AssetID.Bytes(), asset.AssetID.Bytes())
 	}
 }
+
+func TestFindAssetByAlias(t *testing.T) {
+	r := NewRegistry(pgtest.NewTx(t), prottest.NewChain(t), nil)
+	ctx := context.Background()
+	keys := []chainkd.XPub{testutil.TestXPub}
+	asset, err := r.Define(ctx, keys, 1, nil, "gold", nil, 0, "")
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+
+	for _, alias := range []string{"gold", "GOLD", "Gold"} {
+		found, err := r.FindByAlias(ctx, alias)
+		if err != nil {
+			testutil.FatalErr(t, err)
+		}
+		if found.AssetID != asset.AssetID {
+			t.Errorf("FindByAlias(%q) = %x, want %x", alias, found.AssetID.Bytes(), asset.AssetID.Bytes())
+		}
+	}
+
+	_, err = r.FindByAlias(ctx, "silver")
+	if errors.Root(err) != pg.ErrUserInputNotFound {
+		t.Errorf("FindByAlias(\"silver\") error = %v, want %v", err, pg.ErrUserInputNotFound)
+	}
+}
+
+func TestDefineAssetDuplicateAlias(t *testing.T) {
+	r := NewRegistry(pgtest.NewTx(t), prottest.NewChain(t), nil)
+	ctx := context.Background()
+	keys := []chainkd.XPub{testutil.TestXPub}
+	_, err := r.Define(ctx, keys, 1, nil, "gold", nil, 0, "")
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+
+	// Aliases are unique regardless of case.
+	for _, alias := range []string{"gold", "Gold"} {
+		_, err = r.Define(ctx, keys, 1, nil, alias, nil, 0, "")
+		if errors.Root(err) != ErrDuplicateAlias {
+			t.Errorf("Define(%q) error = %v, want %v", alias, err, ErrDuplicateAlias)
+		}
+	}
+}
+
+func TestIssueByAlias(t *testing.T) {
+	r := NewRegistry(pgtest.NewTx(t), prottest.NewChain(t), nil)
+	ctx := context.Background()
+	keys := []chainkd.XPub{testutil.TestXPub}
+	asset, err := r.Define(ctx, keys, 1, nil, "gold", nil, 0, "")
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+
+	found, err := r.FindByAlias(ctx, "gold")
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	builder := txbuilder.NewBuilder(time.Now().Add(time.Minute))
+	err = r.NewIssueAction(bc.AssetAmount{AssetId: &found.AssetID, Amount: 1}, nil).Build(ctx, builder)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	tpl, _, err := builder.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := tpl.Transaction.Inputs[0].AssetID(); got != asset.AssetID {
+		t.Errorf("issued asset %x, want %x", got.Bytes(), asset.AssetID.Bytes())
+	}
+}
+
+func TestAssetCacheInvalidation(t *testing.T) {
+	r := NewRegistry(pgtest.NewTx(t), prottest.NewChain(t), nil)
+	ctx := context.Background()
+	keys := []chainkd.XPub{testutil.TestXPub}
+	asset, err := r.Define(ctx, keys, 1, nil, "", map[string]interface{}{"v": "1"}, 0, "")
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+
+	checkTag := func(want string) {
+		t.Helper()
+		found, err := r.findByID(ctx, asset.AssetID)
+		if err != nil {
+			testutil.FatalErr(t, err)
+		}
+		if got := found.Tags["v"]; got != want {
+			t.Errorf("tag v = %v want %s", got, want)
+		}
+	}
+	checkTag("1")
+
+	// Updating the tags through the registry updates the cache.
+	id := asset.AssetID.String()
+	err = r.UpdateTags(ctx, &id, nil, map[string]interface{}{"v": "2"})
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	checkTag("2")
+
+	// Updates made elsewhere show up after a flush...
+	err = insertAssetTags(ctx, r.db, asset.AssetID, map[string]interface{}{"v": "3"})
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	checkTag("2")
+	r.Flush()
+	checkTag("3")
+
+	// ...or once the cached record expires.
+	r.SetCacheTTL(time.Millisecond)
+	r.Flush()
+	checkTag("3")
+	err = insertAssetTags(ctx, r.db, asset.AssetID, map[string]interface{}{"v": "4"})
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	time.Sleep(2 * time.Millisecond)
+	checkTag("4")
+
+	// Records already handed out keep the tags they had.
+	prev, err := r.findByID(ctx, asset.AssetID)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	err = r.UpdateTags(ctx, &id, nil, map[string]interface{}{"v": "5"})
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	if got := prev.Tags["v"]; got != "4" {
+		t.Errorf("earlier record's tag v = %v want 4", got)
+	}
+	checkTag("5")
+}
+
+// countingDB counts the queries made through it.
+type countingDB struct {
+	pg.DB
+	n int64
+}
+
+func (db *countingDB) QueryContext(ctx context.Context, q string, args ...interface{}) (*sql.Rows, error) {
+	atomic.AddInt64(&db.n, 1)
+	return db.DB.QueryContext(ctx, q, args...)
+}
+
+func (db *countingDB) QueryRowContext(ctx context.Context, q string, args ...interface{}) *sql.Row {
+	atomic.AddInt64(&db.n, 1)
+	return db.DB.QueryRowContext(ctx, q, args...)
+}
+
+func (db *countingDB) ExecContext(ctx context.Context, q string, args ...interface{}) (sql.Result, error) {
+	atomic.AddInt64(&db.n, 1)
+	return db.DB.ExecContext(ctx, q, args...)
+}
+
+// BenchmarkFindByID looks up ten local assets, as issue actions
+// do, with the asset cache warm and with it flushed before every
+// call. It reports the queries each call makes.
+func BenchmarkFindByID(b *testing.B) {
+	for _, flush := range []bool{false, true} {
+		b.Run(fmt.Sprintf("flush=%v", flush), func(b *testing.B) {
+			db := &countingDB{DB: pgtest.NewTx(b)}
+			reg := NewRegistry(db, prottest.NewChain(b), nil)
+			ctx := context.Background()
+
+			var assetIDs []bc.AssetID
+			for i := 0; i < 10; i++ {
+				tags := map[string]interface{}{"n": i}
+				a, err := reg.Define(ctx, []chainkd.XPub{testutil.TestXPub}, 1, nil, "", tags, 0, "")
+				if err != nil {
+					b.Fatal(err)
+				}
+				assetIDs = append(assetIDs, a.AssetID)
+			}
+
+			atomic.StoreInt64(&db.n, 0)
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if flush {
+					reg.Flush()
+				}
+				for _, assetID := range assetIDs {
+					_, err := reg.findByID(ctx, assetID)
+					if err != nil {
+						b.Fatal(err)
+					}
+				}
+			}
+			b.ReportMetric(float64(atomic.LoadInt64(&db.n))/float64(b.N), "queries/op")
+		})
+	}
+}
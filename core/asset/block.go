@@ -104,7 +104,14 @@ func (reg *Registry) ProcessBlocks(ctx context.Context) {
 }
 
 // indexAssets is run on every block and indexes all non-local assets.
+// It also records the block's issuances of assets with a maximum
+// issuance.
 func (reg *Registry) indexAssets(ctx context.Context, b *legacy.Block) error {
+	err := reg.recordIssuances(ctx, b)
+	if err != nil {
+		return err
+	}
+
 	var (
 		assetIDs         pq.ByteaArray
 		definitions      pq.ByteaArray
@@ -155,7 +162,7 @@ func (reg *Registry) indexAssets(ctx context.Context, b *legacy.Block) error {
 		SELECT id FROM assets WHERE first_block_height = $7
 	`
 	var newAssetIDs []bc.AssetID
-	err := pg.ForQueryRows(ctx, reg.db, q, assetIDs, vmVersions, issuancePrograms, definitions, b.Time(), reg.initialBlockHash, b.Height,
+	err = pg.ForQueryRows(ctx, reg.db, q, assetIDs, vmVersions, issuancePrograms, definitions, b.Time(), reg.initialBlockHash, b.Height,
 		func(assetID bc.AssetID) { newAssetIDs = append(newAssetIDs, assetID) })
 	if err != nil {
 		return errors.Wrap(err, "error indexing non-local assets")
@@ -56,6 +56,12 @@ func (a *issueAction) Build(ctx context.Context, builder *txbuilder.TemplateBuil
 
 	assetdef := asset.RawDefinition()
 
+	err = a.assets.reserveIssuances(ctx, asset, map[string]uint64{string(nonce[:]): a.Amount}, bc.Millis(builder.MaxTime()))
+	if err != nil {
+		return err
+	}
+	builder.OnRollback(func() { a.assets.releaseIssuance(ctx, asset.AssetID, nonce[:]) })
+
 	txin := legacy.NewIssuanceInput(nonce[:], a.Amount, a.ReferenceData, asset.InitialBlockHash, asset.IssuanceProgram, nil, assetdef)
 
 	tplIn := &txbuilder.SigningInstruction{}
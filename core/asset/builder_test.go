@@ -0,0 +1,71 @@
+package asset
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"chain/core/signers"
+	"chain/core/txbuilder"
+	"chain/crypto/ed25519/chainkd"
+	"chain/database/pg/pgtest"
+	"chain/protocol/bc"
+	"chain/protocol/prottest"
+	"chain/testutil"
+)
+
+func TestIssueDerivationPath(t *testing.T) {
+	r := NewRegistry(pgtest.NewTx(t), prottest.NewChain(t), nil)
+	ctx := context.Background()
+
+	_, xpub2, err := chainkd.NewXKeys(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	xpubs := []chainkd.XPub{testutil.TestXPub, xpub2}
+	asset, err := r.Define(ctx, xpubs, 1, nil, "", nil, 0, "")
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+
+	builder := txbuilder.NewBuilder(time.Now().Add(time.Minute))
+	err = r.NewIssueAction(bc.AssetAmount{AssetId: &asset.AssetID, Amount: 1}, nil).Build(ctx, builder)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	tpl, _, err := builder.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Issuance keys are derived from the signer's
+	// index in the asset key space.
+	wantPath := signers.Path(asset.Signer, signers.AssetKeySpace)
+	keys := tpl.SigningInstructions[0].SignatureWitnesses[0].Keys
+	if len(keys) != len(asset.Signer.XPubs) {
+		t.Fatalf("got %d keys, want %d", len(keys), len(asset.Signer.XPubs))
+	}
+	for i, k := range keys {
+		if k.XPub != asset.Signer.XPubs[i] {
+			t.Errorf("key %d xpub = %x want %x", i, k.XPub.Bytes(), asset.Signer.XPubs[i].Bytes())
+		}
+		if fp := k.XPub.Fingerprint(); !bytes.Equal(k.Fingerprint, fp[:]) {
+			t.Errorf("key %d fingerprint = %x want %x", i, k.Fingerprint, fp[:])
+		}
+		var path [][]byte
+		for _, p := range k.DerivationPath {
+			path = append(path, p)
+		}
+		if !testutil.DeepEqual(path, wantPath) {
+			t.Errorf("key %d derivation path = %x want %x", i, path, wantPath)
+		}
+
+		// The path alone is enough to find the key
+		// in the issuance program.
+		pub := k.XPub.Derive(path).PublicKey()
+		if !bytes.Contains(asset.IssuanceProgram, pub) {
+			t.Errorf("key %d derived with %x isn't in the issuance program", i, path)
+		}
+	}
+}
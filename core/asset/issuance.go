@@ -0,0 +1,246 @@
+package asset
+
+import (
+	"context"
+	"math"
+	"time"
+
+	"github.com/lib/pq"
+
+	"chain/database/pg"
+	"chain/errors"
+	"chain/protocol/bc"
+	"chain/protocol/bc/legacy"
+)
+
+// ErrMaxIssuance is returned when issuing an asset would bring
+// its total issued units above the maximum set when it was defined.
+var ErrMaxIssuance = errors.New("asset maximum issuance exceeded")
+
+// AdmitIssuances checks that the issuances in tx of assets with a
+// maximum issuance, together with the issuances already confirmed
+// and the ones pending, stay within each asset's maximum. It
+// records the issuances as pending so that later calls count them
+// too. Issuances already pending, such as ones built by this core
+// or submitted before, are not counted twice.
+//
+// Pending issuances are kept in the database, so the check holds
+// across all of the core's processes: concurrent builds or submits
+// on different processes that together exceed the maximum can't
+// all be admitted.
+func (reg *Registry) AdmitIssuances(ctx context.Context, tx *legacy.Tx) error {
+	amounts := make(map[bc.AssetID]map[string]uint64)
+	for _, in := range tx.Inputs {
+		ii, ok := in.TypedInput.(*legacy.IssuanceInput)
+		if !ok {
+			continue
+		}
+		assetID := in.AssetID()
+		if amounts[assetID] == nil {
+			amounts[assetID] = make(map[string]uint64)
+		}
+		nonce := string(ii.Nonce)
+		amounts[assetID][nonce] = addAmounts(amounts[assetID][nonce], ii.Amount)
+	}
+	for assetID, nonces := range amounts {
+		a, err := reg.findByID(ctx, assetID)
+		if errors.Root(err) == pg.ErrUserInputNotFound {
+			continue // not an asset this core defined
+		} else if err != nil {
+			return errors.Wrap(err, "looking up issued asset")
+		}
+		err = reg.reserveIssuances(ctx, a, nonces, tx.MaxTime)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// reserveIssuances records the issuances of a, given as amounts by
+// nonce, as pending until expiryMS. If a has a maximum issuance and
+// the issuances not already pending would exceed it, it records
+// nothing and returns ErrMaxIssuance.
+func (reg *Registry) reserveIssuances(ctx context.Context, a *Asset, nonces map[string]uint64, expiryMS uint64) error {
+	if a.MaxIssuance == 0 {
+		return nil
+	}
+
+	return pg.Atomically(ctx, reg.db, func(db pg.DB) error {
+		// Lock the asset so that this check can't interleave with
+		// another admission or with recordIssuances moving a
+		// block's issuances from pending to issued.
+		err := lockAssets(ctx, db, pq.ByteaArray{a.AssetID.Bytes()})
+		if err != nil {
+			return err
+		}
+
+		const expireQ = `DELETE FROM pending_issuances WHERE asset_id=$1 AND expires_at < $2`
+		_, err = db.ExecContext(ctx, expireQ, a.AssetID, time.Now())
+		if err != nil {
+			return errors.Wrap(err, "deleting expired pending issuances")
+		}
+
+		var issued uint64
+		const issuedQ = `
+			SELECT LEAST(COALESCE(SUM(amount), 0), 9223372036854775807)::bigint
+			FROM asset_issuances WHERE asset_id=$1
+		`
+		err = db.QueryRowContext(ctx, issuedQ, a.AssetID).Scan(&issued)
+		if err != nil {
+			return errors.Wrap(err, "reading issued total")
+		}
+
+		var pending uint64
+		pendingNonces := make(map[string]bool)
+		const pendingQ = `SELECT nonce, amount FROM pending_issuances WHERE asset_id=$1`
+		err = pg.ForQueryRows(ctx, db, pendingQ, a.AssetID, func(nonce []byte, n uint64) {
+			pendingNonces[string(nonce)] = true
+			pending = addAmounts(pending, n)
+		})
+		if err != nil {
+			return errors.Wrap(err, "reading pending issuances")
+		}
+
+		var (
+			amount     uint64
+			newNonces  pq.ByteaArray
+			newAmounts pq.Int64Array
+		)
+		for nonce, n := range nonces {
+			if pendingNonces[nonce] {
+				continue
+			}
+			amount = addAmounts(amount, n)
+			newNonces = append(newNonces, []byte(nonce))
+			newAmounts = append(newAmounts, int64(n))
+		}
+
+		var headroom uint64
+		if used := addAmounts(issued, pending); used < a.MaxIssuance {
+			headroom = a.MaxIssuance - used
+		}
+		if amount > headroom {
+			return errors.WithDetailf(ErrMaxIssuance, "issuing %d units of asset %x would exceed its maximum issuance of %d; %d units remain", amount, a.AssetID.Bytes(), a.MaxIssuance, headroom)
+		}
+		if len(newNonces) == 0 {
+			return nil
+		}
+
+		// Each new amount is at most the maximum issuance,
+		// so it fits in a bigint.
+		const insertQ = `
+			INSERT INTO pending_issuances (asset_id, nonce, amount, expires_at)
+			SELECT $1, unnest($2::bytea[]), unnest($3::bigint[]), $4
+			ON CONFLICT (asset_id, nonce) DO NOTHING
+		`
+		_, err = db.ExecContext(ctx, insertQ, a.AssetID, newNonces, newAmounts, millisTime(expiryMS))
+		return errors.Wrap(err, "recording pending issuances")
+	})
+}
+
+// releaseIssuance forgets a pending issuance.
+func (reg *Registry) releaseIssuance(ctx context.Context, assetID bc.AssetID, nonce []byte) {
+	const q = `DELETE FROM pending_issuances WHERE asset_id=$1 AND nonce=$2`
+	reg.db.ExecContext(ctx, q, assetID, nonce)
+}
+
+// recordIssuances records the issuances in b of assets with a
+// maximum issuance, and forgets them as pending. Issuances are
+// recorded by asset and block height, so blocks may be recorded
+// in any order, and recording a block again has no effect.
+func (reg *Registry) recordIssuances(ctx context.Context, b *legacy.Block) error {
+	var (
+		totals  = make(map[bc.AssetID]uint64)
+		nonces  pq.ByteaArray
+		ofAsset pq.ByteaArray
+	)
+	for _, tx := range b.Transactions {
+		for _, in := range tx.Inputs {
+			ii, ok := in.TypedInput.(*legacy.IssuanceInput)
+			if !ok {
+				continue
+			}
+			assetID := in.AssetID()
+			totals[assetID] = addAmounts(totals[assetID], ii.Amount)
+			nonces = append(nonces, ii.Nonce)
+			ofAsset = append(ofAsset, assetID.Bytes())
+		}
+	}
+	if len(totals) == 0 {
+		return nil
+	}
+
+	var (
+		assetIDs pq.ByteaArray
+		amounts  pq.Int64Array
+	)
+	for assetID, n := range totals {
+		if n > math.MaxInt64 {
+			n = math.MaxInt64 // more than any maximum issuance
+		}
+		assetIDs = append(assetIDs, assetID.Bytes())
+		amounts = append(amounts, int64(n))
+	}
+
+	return pg.Atomically(ctx, reg.db, func(db pg.DB) error {
+		err := lockAssets(ctx, db, assetIDs)
+		if err != nil {
+			return err
+		}
+
+		const insertQ = `
+			INSERT INTO asset_issuances (asset_id, block_height, amount)
+			SELECT t.id, $3, t.amount
+			FROM (SELECT unnest($1::bytea[]) AS id, unnest($2::bigint[]) AS amount) AS t
+			JOIN assets ON assets.id = t.id
+			WHERE assets.max_issuance IS NOT NULL
+			ON CONFLICT (asset_id, block_height) DO NOTHING
+		`
+		_, err = db.ExecContext(ctx, insertQ, assetIDs, amounts, b.Height)
+		if err != nil {
+			return errors.Wrap(err, "recording issuances")
+		}
+
+		const deleteQ = `
+			DELETE FROM pending_issuances
+			WHERE (asset_id, nonce) IN (SELECT unnest($1::bytea[]), unnest($2::bytea[]))
+		`
+		_, err = db.ExecContext(ctx, deleteQ, ofAsset, nonces)
+		return errors.Wrap(err, "deleting confirmed pending issuances")
+	})
+}
+
+// lockAssets locks the rows of the assets with a maximum issuance
+// among assetIDs until the end of db's transaction. It locks them
+// in a fixed order, so that concurrent callers can't deadlock.
+func lockAssets(ctx context.Context, db pg.DB, assetIDs pq.ByteaArray) error {
+	const q = `
+		SELECT 1 FROM assets
+		WHERE id = ANY($1::bytea[]) AND max_issuance IS NOT NULL
+		ORDER BY id FOR UPDATE
+	`
+	_, err := db.ExecContext(ctx, q, assetIDs)
+	return errors.Wrap(err, "locking assets")
+}
+
+// maxPendingExpiry is the latest expiration recorded for a
+// pending issuance, well within the range of a database timestamp.
+var maxPendingExpiry = time.Date(9999, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// millisTime converts ms, in milliseconds since the Unix epoch,
+// to a time no later than maxPendingExpiry.
+func millisTime(ms uint64) time.Time {
+	if ms >= bc.Millis(maxPendingExpiry) {
+		return maxPendingExpiry
+	}
+	return time.Unix(int64(ms/1000), int64(ms%1000)*int64(time.Millisecond))
+}
+
+// addAmounts returns a+b, or math.MaxUint64 if the sum overflows.
+func addAmounts(a, b uint64) uint64 {
+	if a > math.MaxUint64-b {
+		return math.MaxUint64
+	}
+	return a + b
+}
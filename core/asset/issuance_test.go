@@ -0,0 +1,184 @@
+package asset
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+
+	"chain/core/txbuilder"
+	"chain/crypto/ed25519/chainkd"
+	"chain/database/pg/pgtest"
+	"chain/errors"
+	"chain/protocol/bc"
+	"chain/protocol/bc/legacy"
+	"chain/protocol/prottest"
+	"chain/testutil"
+)
+
+func TestMaxIssuance(t *testing.T) {
+	r := NewRegistry(pgtest.NewTx(t), prottest.NewChain(t), nil)
+	ctx := context.Background()
+	asset, err := r.Define(ctx, []chainkd.XPub{testutil.TestXPub}, 1, nil, "", nil, 10, "")
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+
+	// Issue up to the maximum and confirm it.
+	tx, err := buildIssuance(ctx, r, asset.AssetID, 10)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	b := &legacy.Block{
+		BlockHeader:  legacy.BlockHeader{Height: 2},
+		Transactions: []*legacy.Tx{tx},
+	}
+	err = r.indexAssets(ctx, b)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	// Processing the block again mustn't count it twice.
+	err = r.indexAssets(ctx, b)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+
+	if issued, pending := issuanceTotals(ctx, t, r, asset.AssetID); issued != 10 || pending != 0 {
+		t.Errorf("issued, pending = %d, %d want 10, 0", issued, pending)
+	}
+
+	_, err = buildIssuance(ctx, r, asset.AssetID, 1)
+	if errors.Root(err) != ErrMaxIssuance {
+		t.Errorf("issuing past the maximum: got error %v, want %v", err, ErrMaxIssuance)
+	}
+}
+
+func TestMaxIssuanceOutOfOrder(t *testing.T) {
+	r := NewRegistry(pgtest.NewTx(t), prottest.NewChain(t), nil)
+	ctx := context.Background()
+	asset, err := r.Define(ctx, []chainkd.XPub{testutil.TestXPub}, 1, nil, "", nil, 10, "")
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+
+	// The pin store may process a later block before an
+	// earlier one. Both must count.
+	var blocks []*legacy.Block
+	for i, amount := range []uint64{3, 4} {
+		tx, err := buildIssuance(ctx, r, asset.AssetID, amount)
+		if err != nil {
+			testutil.FatalErr(t, err)
+		}
+		blocks = append(blocks, &legacy.Block{
+			BlockHeader:  legacy.BlockHeader{Height: uint64(i + 2)},
+			Transactions: []*legacy.Tx{tx},
+		})
+	}
+	for _, b := range []*legacy.Block{blocks[1], blocks[0], blocks[1]} {
+		err = r.indexAssets(ctx, b)
+		if err != nil {
+			testutil.FatalErr(t, err)
+		}
+	}
+	if issued, pending := issuanceTotals(ctx, t, r, asset.AssetID); issued != 7 || pending != 0 {
+		t.Errorf("issued, pending = %d, %d want 7, 0", issued, pending)
+	}
+}
+
+func TestMaxIssuanceOverflow(t *testing.T) {
+	r := NewRegistry(pgtest.NewTx(t), prottest.NewChain(t), nil)
+	ctx := context.Background()
+	asset, err := r.Define(ctx, []chainkd.XPub{testutil.TestXPub}, 1, nil, "", nil, 10, "")
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+
+	// Amounts whose sum wraps around to 1.
+	in := func(nonce byte, amount uint64) *legacy.TxInput {
+		return legacy.NewIssuanceInput([]byte{nonce}, amount, nil, asset.InitialBlockHash, asset.IssuanceProgram, nil, asset.RawDefinition())
+	}
+	tx := legacy.NewTx(legacy.TxData{
+		MaxTime: bc.Millis(time.Now().Add(time.Minute)),
+		Inputs:  []*legacy.TxInput{in(1, math.MaxUint64), in(2, 2)},
+	})
+	err = r.AdmitIssuances(ctx, tx)
+	if errors.Root(err) != ErrMaxIssuance {
+		t.Errorf("admitting overflowing issuances: got error %v, want %v", err, ErrMaxIssuance)
+	}
+}
+
+func TestMaxIssuanceProcesses(t *testing.T) {
+	db := pgtest.NewTx(t)
+	c := prottest.NewChain(t)
+	ctx := context.Background()
+
+	// Two registries on the same database stand in for two
+	// processes of one core.
+	leader := NewRegistry(db, c, nil)
+	follower := NewRegistry(db, c, nil)
+	asset, err := leader.Define(ctx, []chainkd.XPub{testutil.TestXPub}, 1, nil, "", nil, 10, "")
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+
+	// Each process sees the other's pending issuances.
+	tx1, err := buildIssuance(ctx, leader, asset.AssetID, 6)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	_, err = buildIssuance(ctx, follower, asset.AssetID, 6)
+	if errors.Root(err) != ErrMaxIssuance {
+		t.Errorf("build on second process: got error %v, want %v", err, ErrMaxIssuance)
+	}
+
+	// Admitting a tx whose issuances are already pending, on
+	// either process, doesn't count them twice.
+	for _, r := range []*Registry{leader, follower} {
+		err = r.AdmitIssuances(ctx, tx1)
+		if err != nil {
+			testutil.FatalErr(t, err)
+		}
+	}
+
+	// Once the leader records the block, no process counts the
+	// issuance as both issued and pending.
+	err = leader.indexAssets(ctx, &legacy.Block{
+		BlockHeader:  legacy.BlockHeader{Height: 2},
+		Transactions: []*legacy.Tx{tx1},
+	})
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	_, err = buildIssuance(ctx, follower, asset.AssetID, 4)
+	if err != nil {
+		t.Errorf("build of remaining headroom on second process: %s", err)
+	}
+}
+
+// issuanceTotals returns the confirmed and pending
+// issued amounts of assetID.
+func issuanceTotals(ctx context.Context, t *testing.T, r *Registry, assetID bc.AssetID) (issued, pending uint64) {
+	const q = `
+		SELECT
+			(SELECT COALESCE(SUM(amount), 0) FROM asset_issuances WHERE asset_id=$1),
+			(SELECT COALESCE(SUM(amount), 0) FROM pending_issuances WHERE asset_id=$1)
+	`
+	err := r.db.QueryRowContext(ctx, q, assetID).Scan(&issued, &pending)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	return issued, pending
+}
+
+func buildIssuance(ctx context.Context, r *Registry, assetID bc.AssetID, amount uint64) (*legacy.Tx, error) {
+	builder := txbuilder.NewBuilder(time.Now().Add(time.Minute))
+	err := r.NewIssueAction(bc.AssetAmount{AssetId: &assetID, Amount: amount}, nil).Build(ctx, builder)
+	if err != nil {
+		return nil, err
+	}
+	tpl, _, err := builder.Build()
+	if err != nil {
+		return nil, err
+	}
+	return tpl.Transaction, nil
+}
@@ -5,25 +5,32 @@ import (
 	"sync"
 
 	"chain/core/asset"
+	"chain/core/query"
 	"chain/crypto/ed25519/chainkd"
 	"chain/net/http/httpjson"
 	"chain/net/http/reqid"
 )
 
-// POST /create-asset
-func (a *API) createAsset(ctx context.Context, ins []struct {
+type createAssetRequest struct {
 	Alias      string
 	RootXPubs  []chainkd.XPub `json:"root_xpubs"`
 	Quorum     int
 	Definition map[string]interface{}
 	Tags       map[string]interface{}
 
+	// MaxIssuance, if nonzero, is the most units of the asset
+	// this core will issue in total.
+	MaxIssuance uint64 `json:"max_issuance"`
+
 	// ClientToken is the application's unique token for the asset. Every asset
 	// should have a unique client token. The client token is used to ensure
 	// idempotency of create asset requests. Duplicate create asset requests
 	// with the same client_token will only create one asset.
 	ClientToken string `json:"client_token"`
-}) ([]interface{}, error) {
+}
+
+// POST /create-asset
+func (a *API) createAsset(ctx context.Context, ins []createAssetRequest) ([]interface{}, error) {
 	responses := make([]interface{}, len(ins))
 	var wg sync.WaitGroup
 	wg.Add(len(responses))
@@ -41,6 +48,7 @@ func (a *API) createAsset(ctx context.Context, ins []struct {
 				ins[i].Definition,
 				ins[i].Alias,
 				ins[i].Tags,
+				ins[i].MaxIssuance,
 				ins[i].ClientToken,
 			)
 			if err != nil {
@@ -60,6 +68,38 @@ func (a *API) createAsset(ctx context.Context, ins []struct {
 	return responses, nil
 }
 
+// POST /create-assets
+//
+// Unlike create-asset, create-assets defines its assets all
+// together or not at all. If one fails, it returns that error, with
+// the position of the failed asset in its "index" data.
+func (a *API) createAssets(ctx context.Context, ins []createAssetRequest) ([]*query.AnnotatedAsset, error) {
+	defs := make([]asset.Definition, len(ins))
+	for i, in := range ins {
+		defs[i] = asset.Definition{
+			XPubs:       in.RootXPubs,
+			Quorum:      in.Quorum,
+			Definition:  in.Definition,
+			Alias:       in.Alias,
+			Tags:        in.Tags,
+			MaxIssuance: in.MaxIssuance,
+			ClientToken: in.ClientToken,
+		}
+	}
+	assets, err := a.assets.DefineBatch(ctx, defs)
+	if err != nil {
+		return nil, err
+	}
+	responses := make([]*query.AnnotatedAsset, len(assets))
+	for i, as := range assets {
+		responses[i], err = asset.Annotated(as)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return responses, nil
+}
+
 // POST /update-asset-tags
 func (a *API) updateAssetTags(ctx context.Context, ins []struct {
 	ID    *string
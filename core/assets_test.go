@@ -3,6 +3,7 @@ package core
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 	"reflect"
 	"testing"
 
@@ -10,7 +11,10 @@ import (
 	"chain/core/coretest"
 	"chain/core/pin"
 	"chain/core/query"
+	"chain/crypto/ed25519/chainkd"
+	"chain/database/pg"
 	"chain/database/pg/pgtest"
+	"chain/errors"
 	"chain/protocol/prottest"
 	"chain/testutil"
 )
@@ -122,3 +126,77 @@ func TestUpdateAssetTags(t *testing.T) {
 		t.Fatalf("id:\ngot:  %v\nwant: %v", items[0].ID.String(), id)
 	}
 }
+
+func TestCreateAssets(t *testing.T) {
+	_, db := pgtest.NewDB(t, pgtest.SchemaPath)
+	ctx := context.Background()
+	c := prottest.NewChain(t)
+	pinStore := pin.NewStore(db)
+	indexer := query.NewIndexer(db, c, pinStore)
+	assets := asset.NewRegistry(db, c, pinStore)
+	assets.IndexAssets(indexer)
+	api := &API{db: db, chain: c, assets: assets, indexer: indexer}
+
+	var reqs []createAssetRequest
+	for i := 0; i < 50; i++ {
+		reqs = append(reqs, createAssetRequest{
+			Alias:       fmt.Sprintf("asset-%d", i),
+			RootXPubs:   []chainkd.XPub{testutil.TestXPub},
+			Quorum:      1,
+			ClientToken: fmt.Sprintf("token-%d", i),
+		})
+	}
+	got, err := api.createAssets(ctx, reqs)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	if len(got) != len(reqs) {
+		t.Fatalf("got %d assets, want %d", len(got), len(reqs))
+	}
+	// The assets come back in order, with contiguous key indexes.
+	first, err := assets.FindByAlias(ctx, reqs[0].Alias)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	for i, aa := range got {
+		if aa.Alias != reqs[i].Alias {
+			t.Errorf("asset %d alias = %q want %q", i, aa.Alias, reqs[i].Alias)
+		}
+		a, err := assets.FindByAlias(ctx, reqs[i].Alias)
+		if err != nil {
+			testutil.FatalErr(t, err)
+		}
+		if want := first.Signer.KeyIndex + uint64(i); a.Signer.KeyIndex != want {
+			t.Errorf("asset %d key index = %d want %d", i, a.Signer.KeyIndex, want)
+		}
+	}
+
+	// Replaying the request with the same client tokens
+	// returns the same assets.
+	replay, err := api.createAssets(ctx, reqs)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	for i := range replay {
+		if replay[i].ID != got[i].ID {
+			t.Errorf("replayed asset %d = %x want %x", i, replay[i].ID.Bytes(), got[i].ID.Bytes())
+		}
+	}
+
+	// A batch with a failing definition defines nothing.
+	bad := []createAssetRequest{
+		{Alias: "new-asset", RootXPubs: []chainkd.XPub{testutil.TestXPub}, Quorum: 1},
+		{Alias: "asset-0", RootXPubs: []chainkd.XPub{testutil.TestXPub}, Quorum: 1},
+	}
+	_, err = api.createAssets(ctx, bad)
+	if errors.Root(err) != asset.ErrDuplicateAlias {
+		t.Errorf("got error %v, want %v", err, asset.ErrDuplicateAlias)
+	}
+	if index := errors.Data(err)["index"]; index != 1 {
+		t.Errorf("error index = %v want 1", index)
+	}
+	_, err = assets.FindByAlias(ctx, "new-asset")
+	if errors.Root(err) != pg.ErrUserInputNotFound {
+		t.Errorf("after failed batch, got error %v looking up its first asset, want %v", err, pg.ErrUserInputNotFound)
+	}
+}
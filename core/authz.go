@@ -1,5 +1,7 @@
 package core
 
+import "chain/core/accesstoken"
+
 const GrantPrefix = "/core/grant/"
 
 var Policies = []string{
@@ -13,32 +15,50 @@ var Policies = []string{
 }
 
 var policyByRoute = map[string][]string{
-	"/create-account":           {"client-readwrite"},
-	"/create-asset":             {"client-readwrite"},
-	"/update-account-tags":      {"client-readwrite"},
-	"/update-asset-tags":        {"client-readwrite"},
-	"/build-transaction":        {"client-readwrite", "internal"},
-	"/submit-transaction":       {"client-readwrite", "internal"},
-	"/create-control-program":   {"client-readwrite"},
-	"/create-account-receiver":  {"client-readwrite"},
-	"/create-transaction-feed":  {"client-readwrite"},
-	"/get-transaction-feed":     {"client-readwrite", "client-readonly"},
-	"/update-transaction-feed":  {"client-readwrite"},
-	"/delete-transaction-feed":  {"client-readwrite"},
-	"/mockhsm":                  {"client-readwrite"},
-	"/mockhsm/create-block-key": {"internal"},
-	"/mockhsm/create-key":       {"client-readwrite"},
-	"/mockhsm/list-keys":        {"client-readwrite", "client-readonly"},
-	"/mockhsm/delkey":           {"client-readwrite"},
-	"/mockhsm/sign-transaction": {"client-readwrite"},
+	"/create-account":                {"client-readwrite"},
+	"/create-asset":                  {"client-readwrite"},
+	"/create-assets":                 {"client-readwrite"},
+	"/update-account-tags":           {"client-readwrite"},
+	"/update-account-alias":          {"client-readwrite"},
+	"/delete-account":                {"client-readwrite"},
+	"/update-asset-tags":             {"client-readwrite"},
+	"/build-transaction":             {"client-readwrite", "internal"},
+	"/submit-transaction":            {"client-readwrite", "internal"},
+	"/cancel-reservation":            {"client-readwrite", "internal"},
+	"/list-reservations":             {"client-readwrite", "client-readonly"},
+	"/merge-transaction-templates":   {"client-readwrite", "internal"},
+	"/validate-transaction-template": {"client-readwrite", "client-readonly", "internal"},
+	"/create-control-program":        {"client-readwrite"},
+	"/create-account-receiver":       {"client-readwrite"},
+	"/list-account-receivers":        {"client-readwrite", "client-readonly"},
+	"/create-transaction-feed":       {"client-readwrite"},
+	"/get-transaction-feed":          {"client-readwrite", "client-readonly"},
+	"/update-transaction-feed":       {"client-readwrite"},
+	"/delete-transaction-feed":       {"client-readwrite"},
+	"/mockhsm":                       {"client-readwrite"},
+	"/mockhsm/create-block-key":      {"internal"},
+	"/mockhsm/create-key":            {"client-readwrite"},
+	"/mockhsm/list-keys":             {"client-readwrite", "client-readonly"},
+	"/mockhsm/delkey":                {"client-readwrite"},
+	"/mockhsm/export-key":            {"client-readwrite"},
+	"/mockhsm/import-key":            {"client-readwrite"},
+	"/mockhsm/rename-key":            {"client-readwrite"},
+	"/mockhsm/sign-transaction":      {"client-readwrite"},
+	"/mockhsm/sign-hash":             {"client-readwrite"},
 
 	"/list-accounts":          {"client-readwrite", "client-readonly"},
 	"/list-assets":            {"client-readwrite", "client-readonly"},
 	"/list-transaction-feeds": {"client-readwrite", "client-readonly"},
 	"/list-transactions":      {"client-readwrite", "client-readonly"},
+	"/aggregate-transactions": {"client-readwrite", "client-readonly"},
 	"/list-balances":          {"client-readwrite", "client-readonly"},
 	"/list-unspent-outputs":   {"client-readwrite", "client-readonly"},
+	"/list-pool-transactions": {"client-readwrite", "client-readonly"},
 	"/reset":                  {"client-readwrite", "internal"},
+	"/reindex":                {"client-readwrite", "internal"},
+	"/reindex-progress":       {"client-readwrite", "client-readonly", "internal"},
+	"/recover":                {"client-readwrite", "internal"},
+	"/recover-progress":       {"client-readwrite", "client-readonly", "internal"},
 
 	crosscoreRPCPrefix + "submit":            {"crosscore", "crosscore-signblock"},
 	crosscoreRPCPrefix + "get-block":         {"crosscore", "crosscore-signblock"},
@@ -53,11 +73,13 @@ var policyByRoute = map[string][]string{
 	"/create-access-token":        {"client-readwrite", "internal"},
 	"/list-access-tokens":         {"client-readwrite", "client-readonly"},
 	"/delete-access-token":        {"client-readwrite"},
+	"/update-access-token-limit":  {"client-readwrite", "internal"},
 	"/add-allowed-member":         {"internal"},
 	"/init-cluster":               {"internal"},
 	"/join-cluster":               {"internal"},
 	"/evict":                      {"internal"},
 	"/configure":                  {"client-readwrite", "internal"},
+	"/update-generator-config":    {"client-readwrite", "internal"},
 	"/config":                     {"client-readwrite", "client-readonly", "monitoring", "internal"},
 	"/info":                       {"client-readwrite", "client-readonly", "crosscore", "crosscore-signblock", "monitoring", "internal"},
 
@@ -68,3 +90,9 @@ var policyByRoute = map[string][]string{
 	"/dashboard":  {"public"},
 	"/dashboard/": {"public"},
 }
+
+// policiesByScope restricts requests made with scoped access
+// tokens to routes that permit at least one of the listed policies.
+var policiesByScope = map[string][]string{
+	accesstoken.ScopeReadOnly: {"client-readonly", "public"},
+}
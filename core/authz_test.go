@@ -49,7 +49,7 @@ func TestAuthz(t *testing.T) {
 	}
 	tokens := make(map[string]*accesstoken.Token)
 	for i := 0; i < len(testPolicies); i++ {
-		token, err := accessTokens.Create(ctx, fmt.Sprintf("token%d", i), "")
+		token, err := accessTokens.Create(ctx, fmt.Sprintf("token%d", i), "", nil)
 		if err != nil {
 			t.Fatal(err)
 		}
@@ -177,3 +177,67 @@ func tryRPC(t testing.TB, baseURL, path string, token *accesstoken.Token) bool {
 
 	return resp.StatusCode != http.StatusForbidden
 }
+
+func TestTokenTypesAndScopes(t *testing.T) {
+	ctx := context.Background()
+	_, db := pgtest.NewDB(t, pgtest.SchemaPath)
+	accessTokens := &accesstoken.CredentialStore{DB: db}
+	sdb := sinkdbtest.NewDB(t)
+
+	api := &API{
+		mux:          http.NewServeMux(),
+		sdb:          sdb,
+		accessTokens: accessTokens,
+		grants:       authz.NewStore(sdb, GrantPrefix),
+	}
+	api.buildHandler()
+	server := httptest.NewServer(AuthHandler(api, sdb, accessTokens, nil, nil))
+	defer server.Close()
+
+	type tokenReq struct {
+		ID     string
+		Type   string
+		Scopes []string
+	}
+	client, err := api.createAccessToken(ctx, tokenReq{ID: "client", Type: "client"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	network, err := api.createAccessToken(ctx, tokenReq{ID: "network", Type: "network"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	readonly, err := api.createAccessToken(ctx, tokenReq{ID: "readonly", Scopes: []string{accesstoken.ScopeReadOnly}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Grant the read-only token full client access, so that
+	// only its scope keeps it from mutating endpoints.
+	_, err = api.createGrant(ctx, apiGrant{
+		GuardType: "access_token",
+		GuardData: map[string]interface{}{"id": readonly.ID},
+		Policy:    "client-readwrite",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		token *accesstoken.Token
+		path  string
+		want  bool
+	}{
+		{client, "/list-accounts", true},
+		{client, crosscoreRPCPrefix + "get-block", false},
+		{network, "/list-accounts", false},
+		{network, crosscoreRPCPrefix + "get-block", true},
+		{readonly, "/list-accounts", true},
+		{readonly, "/build-transaction", false},
+	}
+	for _, c := range cases {
+		got := tryRPC(t, server.URL, c.path, c.token)
+		if got != c.want {
+			t.Errorf("auth(%s, %s) = %t want %t", c.path, c.token.ID, got, c.want)
+		}
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"net/url"
 	"path"
 	"strings"
+	"time"
 
 	"chain/core/config"
 	"chain/database/pg"
@@ -14,6 +15,15 @@ import (
 	"chain/net/raft"
 )
 
+// Bounds on the block interval accepted by
+// /update-generator-config.
+const (
+	minBlockInterval = 200 * time.Millisecond
+	maxBlockInterval = 10 * time.Minute
+)
+
+var errNotGenerator = errors.New("core is not the generator")
+
 // Config provides access to Chain Core configuration options
 // and their values.
 //
@@ -43,6 +53,10 @@ func Config(ctx context.Context, db pg.DB, sdb *sinkdb.DB) (*config.Options, err
 	// the URL, not the access token.
 	opts.DefineSet("enclave", 2, cleanEnclaveTuple, equalFirst)
 
+	// block_interval is the time the generator waits between
+	// blocks, as a Go duration string.
+	opts.DefineSingle("block_interval", 1, cleanBlockInterval)
+
 	// migrate any old-style existing configuration options
 	monolith, err := config.Load(ctx, db, sdb)
 	if errors.Root(err) == raft.ErrUninitialized {
@@ -65,6 +79,48 @@ func Config(ctx context.Context, db pg.DB, sdb *sinkdb.DB) (*config.Options, err
 	return opts, nil
 }
 
+// cleanBlockInterval checks that tup holds a block interval
+// within bounds and canonicalizes it.
+func cleanBlockInterval(tup []string) error {
+	d, err := time.ParseDuration(tup[0])
+	if err != nil {
+		return errors.WithDetailf(config.ErrConfigOp, "Block interval %q is not a valid duration.", tup[0])
+	}
+	if d < minBlockInterval || d > maxBlockInterval {
+		return errors.WithDetailf(config.ErrConfigOp, "Block interval must be between %s and %s.", minBlockInterval, maxBlockInterval)
+	}
+	tup[0] = d.String()
+	return nil
+}
+
+// POST /update-generator-config
+func (a *API) updateGeneratorConfig(ctx context.Context, x struct {
+	BlockInterval string `json:"block_interval"`
+}) error {
+	if !a.config.IsGenerator {
+		return errNotGenerator
+	}
+	return a.sdb.Exec(ctx, a.options.Set("block_interval", []string{x.BlockInterval}))
+}
+
+// blockInterval returns a function reporting the generator's
+// current block interval. It falls back to blockPeriod when
+// the option is unset.
+func (a *API) blockInterval() func() time.Duration {
+	get := a.options.GetFunc("block_interval")
+	return func() time.Duration {
+		tup := get()
+		if len(tup) == 0 {
+			return blockPeriod
+		}
+		d, err := time.ParseDuration(tup[0])
+		if err != nil {
+			return blockPeriod
+		}
+		return d
+	}
+}
+
 // normalizeURL performs some low-hanging best-effort normalization
 // of the provided URL. See RFC3986, Section 6.
 func normalizeURL(urlstr string) (*url.URL, error) {
@@ -31,8 +31,7 @@ import (
 )
 
 const (
-	autoBlockKeyAlias = "_CHAIN_CORE_AUTO_BLOCK_KEY"
-	GrantPrefix       = "/core/grant/" // this is also hardcoded in core/authz.go. meh.
+	GrantPrefix = "/core/grant/" // this is also hardcoded in core/authz.go. meh.
 )
 
 var (
@@ -57,6 +56,12 @@ var (
 	}
 )
 
+// DevHSM is the part of a mock HSM that Configure uses to
+// make the block-signing key for a development Core.
+type DevHSM interface {
+	GetOrCreateBlockKey(context.Context) (pub ed25519.PublicKey, created bool, err error)
+}
+
 // Load loads the stored configuration, if any, from the database.
 // It will first try to load the config from sinkdb; if that fails,
 // it will try Postgres next. If it finds a config in Postgres but not in sinkdb
@@ -198,15 +203,15 @@ func CheckConfigExists(ctx context.Context, sdb *sinkdb.DB) (*Config, error) {
 // the caller must ensure that the new configuration is properly reloaded,
 // for example by restarting the process.
 //
-// When running a mockhsm enabled server, if c.IsSigner is true and c.BlockPub is empty,
-// Configure generates a new mockhsm keypair
-// for signing blocks, and assigns it to c.BlockPub.
+// If hsm is non-nil, c.IsSigner is true, and c.BlockPub is empty,
+// Configure gets or generates a block-signing keypair
+// in hsm, and assigns it to c.BlockPub.
 //
 // If c.IsGenerator is true, Configure creates an initial block,
 // saves it, and assigns its hash to c.BlockchainId
 // Otherwise, c.IsGenerator is false, and Configure makes a test request
 // to GeneratorUrl to detect simple configuration mistakes.
-func Configure(ctx context.Context, db pg.DB, sdb *sinkdb.DB, httpClient *http.Client, c *Config) error {
+func Configure(ctx context.Context, db pg.DB, sdb *sinkdb.DB, httpClient *http.Client, hsm DevHSM, c *Config) error {
 	var err error
 	if !c.IsGenerator {
 		blockchainID, err := c.BlockchainId.MarshalText()
@@ -226,7 +231,7 @@ func Configure(ctx context.Context, db pg.DB, sdb *sinkdb.DB, httpClient *http.C
 	if c.IsSigner {
 		var blockPub ed25519.PublicKey
 		if len(c.BlockPub) == 0 {
-			blockPub, err = getOrCreateDevKey(ctx, db, c)
+			blockPub, err = getOrCreateDevKey(ctx, hsm, c)
 			if err != nil {
 				return err
 			}
@@ -307,6 +312,24 @@ func Configure(ctx context.Context, db pg.DB, sdb *sinkdb.DB, httpClient *http.C
 	)
 }
 
+func getOrCreateDevKey(ctx context.Context, hsm DevHSM, c *Config) (blockPub ed25519.PublicKey, err error) {
+	if hsm == nil {
+		return nil, ErrNoBlockPub
+	}
+	blockPub, created, err := hsm.GetOrCreateBlockKey(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if created {
+		log.Printf(ctx, "Generated new block-signing key %x", blockPub)
+	} else {
+		log.Printf(ctx, "Using block-signing key %x", blockPub)
+	}
+	c.BlockPub = blockPub
+
+	return blockPub, nil
+}
+
 func tryGenerator(ctx context.Context, url, accessToken, blockchainID string, httpClient *http.Client) error {
 	client := &rpc.Client{
 		BaseURL:      url,
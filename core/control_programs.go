@@ -52,14 +52,19 @@ func (a *API) createControlProgram(ctx context.Context, ins []struct {
 
 func (a *API) createAccountControlProgram(ctx context.Context, input []byte) (interface{}, error) {
 	var parsed struct {
-		AccountAlias string `json:"account_alias"`
-		AccountID    string `json:"account_id"`
+		AccountAlias string    `json:"account_alias"`
+		AccountID    string    `json:"account_id"`
+		ExpiresAt    time.Time `json:"expires_at"`
 	}
 	err := stdjson.Unmarshal(input, &parsed)
 	if err != nil {
 		return nil, errors.WithDetailf(httpjson.ErrBadRequest, "bad parameters for account control program")
 	}
 
+	if !parsed.ExpiresAt.IsZero() && parsed.ExpiresAt.Before(time.Now()) {
+		return nil, errors.WithDetail(httpjson.ErrBadRequest, "expires_at is in the past")
+	}
+
 	accountID := parsed.AccountID
 	if accountID == "" {
 		acc, err := a.accounts.FindByAlias(ctx, parsed.AccountAlias)
@@ -69,7 +74,7 @@ func (a *API) createAccountControlProgram(ctx context.Context, input []byte) (in
 		accountID = acc.ID
 	}
 
-	controlProgram, err := a.accounts.CreateControlProgram(ctx, accountID, false, time.Time{})
+	controlProgram, err := a.accounts.CreateControlProgram(ctx, accountID, false, parsed.ExpiresAt)
 	if err != nil {
 		return nil, err
 	}
@@ -77,5 +82,8 @@ func (a *API) createAccountControlProgram(ctx context.Context, input []byte) (in
 	ret := map[string]interface{}{
 		"control_program": json.HexBytes(controlProgram),
 	}
+	if !parsed.ExpiresAt.IsZero() {
+		ret["expires_at"] = parsed.ExpiresAt
+	}
 	return ret, nil
 }
@@ -208,7 +208,7 @@ func (a *API) configure(ctx context.Context, req configureRequest) error {
 	if req.Config.IsGenerator && req.Config.MaxIssuanceWindowMs == 0 {
 		req.Config.MaxIssuanceWindowMs = bc.DurationMillis(24 * time.Hour)
 	}
-	err = config.Configure(ctx, a.db, a.sdb, a.httpClient, &req.Config)
+	err = config.Configure(ctx, a.db, a.sdb, a.httpClient, a.devHSM, &req.Config)
 	if err != nil {
 		return err
 	}
@@ -8,6 +8,7 @@ import (
 
 	"chain/core/account"
 	"chain/core/asset"
+	"chain/core/mockhsm"
 	"chain/core/pin"
 	"chain/core/query"
 	"chain/core/txbuilder"
@@ -21,7 +22,6 @@ import (
 func CreatePins(ctx context.Context, t testing.TB, s *pin.Store) {
 	pins := []string{
 		account.PinName,
-		account.ExpirePinName,
 		account.DeleteSpentsPinName,
 		asset.PinName,
 		query.TxPinName,
@@ -43,9 +43,19 @@ func CreateAccount(ctx context.Context, t testing.TB, accounts *account.Manager,
 	return acc.ID
 }
 
+// CreateSeededKey creates a key in hsm derived from seed, so that
+// fixtures using it are the same in every run.
+func CreateSeededKey(ctx context.Context, t testing.TB, hsm *mockhsm.HSM, alias, seed string) chainkd.XPub {
+	xpub, err := hsm.CreateKeyFromSeed(ctx, alias, []byte(seed))
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	return xpub.XPub
+}
+
 func CreateAsset(ctx context.Context, t testing.TB, assets *asset.Registry, def map[string]interface{}, alias string, tags map[string]interface{}) bc.AssetID {
 	keys := []chainkd.XPub{testutil.TestXPub}
-	asset, err := assets.Define(ctx, keys, 1, def, alias, tags, "")
+	asset, err := assets.Define(ctx, keys, 1, def, alias, tags, 0, "")
 	if err != nil {
 		testutil.FatalErr(t, err)
 	}
@@ -8,6 +8,7 @@ import (
 	"chain/core/asset"
 	"chain/core/blocksigner"
 	"chain/core/config"
+	"chain/core/generator"
 	"chain/core/leader"
 	"chain/core/query"
 	"chain/core/query/filter"
@@ -18,11 +19,14 @@ import (
 	"chain/database/pg"
 	"chain/database/sinkdb"
 	"chain/errors"
+	"chain/net/http/authn"
 	"chain/net/http/authz"
 	"chain/net/http/httperror"
 	"chain/net/http/httpjson"
 	"chain/net/raft"
 	"chain/protocol"
+	"chain/protocol/bc/legacy"
+	"chain/protocol/validation"
 )
 
 func isTemporary(info httperror.Info, err error) bool {
@@ -31,6 +35,8 @@ func isTemporary(info httperror.Info, err error) bool {
 		return true
 	case "CH001": // request timed out
 		return true
+	case "CH750": // pending transaction pool full
+		return true
 	case "CH761": // outputs currently reserved
 		return true
 	case "CH706": // 1 or more action errors
@@ -66,11 +72,13 @@ var errorFormatter = httperror.Formatter{
 		txbuilder.ErrMissingFields: {400, "CH010", "One or more fields are missing"},
 		authz.ErrNotAuthorized:     {403, "CH011", "Request is unauthorized"},
 		sinkdb.ErrConflict:         {409, "CH012", "Conflict processing request"},
+		errNoBlock:                 {404, "CH013", "Block not found"},
 		asset.ErrDuplicateAlias:    {400, "CH050", "Alias already exists"},
 		account.ErrDuplicateAlias:  {400, "CH050", "Alias already exists"},
 		txfeed.ErrDuplicateAlias:   {400, "CH050", "Alias already exists"},
 		account.ErrBadIdentifier:   {400, "CH051", "Either an ID or alias must be provided, but not both"},
 		asset.ErrBadIdentifier:     {400, "CH051", "Either an ID or alias must be provided, but not both"},
+		account.ErrNonzeroBalance:  {400, "CH052", "Account has a nonzero balance and can't be deleted"},
 
 		// Core error namespace
 		errUnconfigured:                {400, "CH100", "This core still needs to be configured"},
@@ -85,6 +93,7 @@ var errorFormatter = httperror.Formatter{
 		config.ErrNoBlockPub:           {400, "CH109", "Block Pub cannot be empty when configuring a mockhsm disabled signer"},
 		errNoMockHSM:                   {400, "CH110", "This endpoint is disabled for this server's configuration"},
 		errNoReset:                     {400, "CH110", "This endpoint is disabled for this server's configuration"},
+		errNotGenerator:                {400, "CH110", "This endpoint is disabled for this server's configuration"},
 		config.ErrNoBlockHSMURL:        {400, "CH111", "Block HSM URL cannot be empty when configuring a non mockhsm signer"},
 		errNoClientTokens:              {400, "CH120", "Cannot enable client authentication with no client tokens"},
 		blocksigner.ErrConsensusChange: {400, "CH150", "Refuse to sign block with consensus change"},
@@ -96,6 +105,7 @@ var errorFormatter = httperror.Formatter{
 		raft.ErrPeerUninitialized:      {400, "CH165", "Peer node is uninitialized"},
 		raft.ErrUnknownPeer:            {400, "CH166", "Unknown peer"},
 		config.ErrConfigOp:             {400, "CH170", "Invalid configuration operation"},
+		errRecoveryRunning:             {400, "CH171", "A recovery is already running"},
 
 		// Signers error namespace (2xx)
 		signers.ErrBadQuorum: {400, "CH200", "Quorum must be greater than 1 and less than or equal to the length of xpubs"},
@@ -109,14 +119,23 @@ var errorFormatter = httperror.Formatter{
 		accesstoken.ErrBadType:     {400, "CH301", "Access tokens must be type client or network"},
 		accesstoken.ErrDuplicateID: {400, "CH302", "Access token id is already in use"},
 		errMissingTokenID:          {400, "CH303", "Access token id does not exist"},
+		accesstoken.ErrBadScope:    {400, "CH304", "Access token scope is invalid"},
+		authn.ErrTokenType:         {403, "CH305", "Access token type is not permitted for this route"},
+		accesstoken.ErrBadLimit:    {400, "CH306", "Access token rate limit is invalid"},
 		errCurrentToken:            {400, "CH310", "The access token used to authenticate this request cannot be deleted"},
 		errProtectedGrant:          {400, "CH320", "Protected grants cannot be manually deleted"},
 		errCreateProtectedGrant:    {400, "CH321", "Protected grants cannot be manually created"},
 
 		// Query error namespace (6xx)
-		query.ErrBadAfter:               {400, "CH600", "Malformed pagination parameter `after`"},
-		query.ErrParameterCountMismatch: {400, "CH601", "Incorrect number of parameters to filter"},
-		filter.ErrBadFilter:             {400, "CH602", "Malformed query filter"},
+		query.ErrBadAfter:                    {400, "CH600", "Malformed pagination parameter `after`"},
+		query.ErrParameterCountMismatch:      {400, "CH601", "Incorrect number of parameters to filter"},
+		filter.ErrBadFilter:                  {400, "CH602", "Malformed query filter"},
+		query.ErrTimestampBeforeInitialBlock: {400, "CH603", "Requested timestamp is before the initial block"},
+		query.ErrTimestampInFuture:           {400, "CH604", "Requested timestamp is in the future"},
+		query.ErrBadBackfillRange:            {400, "CH605", "Invalid range of blocks to reindex"},
+		query.ErrBadAggregate:                {400, "CH606", "Invalid transaction aggregation"},
+		query.ErrTextSearchDisabled:          {400, "CH607", "Text search is disabled"},
+		query.ErrBackfillRunning:             {400, "CH608", "A reindex is already running"},
 
 		// Transaction error namespace (7xx)
 		// Build error namespace (70x)
@@ -127,20 +146,40 @@ var errorFormatter = httperror.Formatter{
 		txbuilder.ErrBadAmount:  {400, "CH704", "Invalid asset amount"},
 		txbuilder.ErrBlankCheck: {400, "CH705", "Unsafe transaction: leaves assets to be taken without requiring payment"},
 		txbuilder.ErrAction:     {400, "CH706", "One or more actions had an error: see attached data"},
+		errBadTTL:               {400, "CH707", "Transaction TTL is out of range"},
+		errBadTimeRange:         {400, "CH708", "Invalid transaction time range"},
 
 		// Submit error namespace (73x)
 		txbuilder.ErrMissingRawTx:          {400, "CH730", "Missing raw transaction"},
 		txbuilder.ErrBadInstructionCount:   {400, "CH731", "Too many signing instructions in template for transaction"},
 		txbuilder.ErrBadTxInputIdx:         {400, "CH732", "Invalid transaction input index"},
 		txbuilder.ErrBadWitnessComponent:   {400, "CH733", "Invalid witness component"},
+		txbuilder.ErrEmptyProgram:          {400, "CH734", "Empty signature program"},
 		txbuilder.ErrRejected:              {400, "CH735", "Transaction rejected"},
 		txbuilder.ErrNoTxSighashCommitment: {400, "CH736", "Transaction is not final, additional actions still allowed"},
 		txbuilder.ErrTxSignatureFailure:    {400, "CH737", "Transaction signature missing, client may be missing signature key"},
 		txbuilder.ErrNoTxSighashAttempt:    {400, "CH738", "Transaction signature was not attempted"},
+		txbuilder.ErrReservationExpired:    {400, "CH739", "Transaction reservations expired; build the transaction again"},
+		txbuilder.ErrTemplateVersion:       {400, "CH740", "Unsupported transaction template version"},
+		txbuilder.ErrTemplateMismatch:      {400, "CH741", "Templates are not copies of the same transaction"},
+		txbuilder.ErrSignatureConflict:     {400, "CH742", "Templates have conflicting signatures"},
+		legacy.ErrTxLimit:                  {400, "CH743", "Transaction exceeds size limits"},
+		validation.ErrProgramFailure:       {400, "CH744", "Transaction program failed"},
+		validation.ErrBadTimestamp:         {400, "CH745", "Timestamp outside the allowed range"},
+		validation.ErrDoubleSpend:          {400, "CH746", "Transaction spends an output that is already spent or doesn't exist"},
+		validation.ErrBadTxRoot:            {400, "CH747", "Block transactions merkle root doesn't match its transactions"},
+		generator.ErrDuplicateIssuance:     {400, "CH748", "Issuance duplicates one in another submitted transaction"},
+		protocol.ErrIssuanceWindow:         {400, "CH749", "Issuance time range is too long or starts too far in the future"},
+		generator.ErrPoolFull:              {503, "CH750", "Pending transaction pool is full; try again after the next block"},
 
 		// account action error namespace (76x)
 		account.ErrInsufficient: {400, "CH760", "Insufficient funds for tx"},
 		account.ErrReserved:     {400, "CH761", "Some outputs are reserved; try again"},
+		account.ErrBadSelection: {400, "CH762", "Invalid UTXO selection strategy"},
+		account.ErrTooFewUTXOs:  {400, "CH763", "Too few unreserved outputs to consolidate"},
+
+		// asset action error namespace (77x)
+		asset.ErrMaxIssuance: {400, "CH770", "Issuance would exceed the asset's maximum issuance"},
 
 		// Mock HSM error namespace (80x)
 	},
@@ -6,8 +6,19 @@ import (
 	"strings"
 	"testing"
 
+	"chain/core/accesstoken"
+	"chain/core/account"
+	"chain/core/asset"
+	"chain/core/config"
+	"chain/core/generator"
+	"chain/core/leader"
+	"chain/core/query"
+	"chain/core/txbuilder"
 	"chain/database/pg"
 	"chain/errors"
+	"chain/net/http/httpjson"
+	"chain/protocol"
+	"chain/protocol/validation"
 )
 
 func TestErrorMapping(t *testing.T) {
@@ -16,11 +27,15 @@ func TestErrorMapping(t *testing.T) {
 		json string
 		code int
 	}{
-		{nil, `{"code":"CH000","message":"Chain API Error","temporary":true}`, 500},
-		{pg.ErrUserInputNotFound, `{"code":"CH002","message":"Not found","temporary":false}`, 400},
-		{errors.Wrap(pg.ErrUserInputNotFound, "foo"), `{"code":"CH002","message":"Not found","temporary":false}`, 400},
-		{errors.WithDetail(pg.ErrUserInputNotFound, "foo"), `{"code":"CH002","message":"Not found","detail":"foo","temporary":false}`, 400},
-		{context.DeadlineExceeded, `{"code":"CH001","message":"Request timed out","temporary":true}`, 408},
+		{nil, `{"code":"CH000","message":"Chain API Error","temporary":true,"retriable":false}`, 500},
+		{pg.ErrUserInputNotFound, `{"code":"CH002","message":"Not found","temporary":false,"retriable":false}`, 400},
+		{errors.Wrap(pg.ErrUserInputNotFound, "foo"), `{"code":"CH002","message":"Not found","temporary":false,"retriable":false}`, 400},
+		{errors.WithDetail(pg.ErrUserInputNotFound, "foo"), `{"code":"CH002","message":"Not found","detail":"foo","temporary":false,"retriable":false}`, 400},
+		{context.DeadlineExceeded, `{"code":"CH001","message":"Request timed out","temporary":true,"retriable":true}`, 408},
+		{errors.WithDetail(errors.New("secret internal failure"), "db password is hunter2"), `{"code":"CH000","message":"Chain API Error","temporary":true,"retriable":false}`, 500},
+		{errRateLimited, `{"code":"CH007","message":"Request limit exceeded","temporary":false,"retriable":true}`, 429},
+		{leader.ErrNoLeader, `{"code":"CH008","message":"Electing a new leader for the core; try again soon","temporary":false,"retriable":true}`, 503},
+		{generator.ErrPoolFull, `{"code":"CH750","message":"Pending transaction pool is full; try again after the next block","temporary":true,"retriable":true}`, 503},
 	}
 
 	for _, test := range cases {
@@ -35,3 +50,53 @@ func TestErrorMapping(t *testing.T) {
 		}
 	}
 }
+
+func TestErrorCodes(t *testing.T) {
+	cases := []struct {
+		err    error
+		code   string
+		status int
+	}{
+		{errors.New("unknown"), "CH000", 500},
+		{context.DeadlineExceeded, "CH001", 408},
+		{pg.ErrUserInputNotFound, "CH002", 400},
+		{httpjson.ErrBadRequest, "CH003", 400},
+		{errNotFound, "CH006", 404},
+		{errRateLimited, "CH007", 429},
+		{errNotAuthenticated, "CH009", 401},
+		{errNoBlock, "CH013", 404},
+		{asset.ErrDuplicateAlias, "CH050", 400},
+		{account.ErrDuplicateAlias, "CH050", 400},
+		{account.ErrBadIdentifier, "CH051", 400},
+		{errUnconfigured, "CH100", 400},
+		{errAlreadyConfigured, "CH101", 400},
+		{config.ErrBadGenerator, "CH102", 400},
+		{accesstoken.ErrBadType, "CH301", 400},
+		{query.ErrBadAfter, "CH600", 400},
+		{txbuilder.ErrBadAmount, "CH704", 400},
+		{txbuilder.ErrBlankCheck, "CH705", 400},
+		{txbuilder.ErrRejected, "CH735", 400},
+		{validation.ErrProgramFailure, "CH744", 400},
+		{validation.ErrBadTimestamp, "CH745", 400},
+		{validation.ErrDoubleSpend, "CH746", 400},
+		{validation.ErrBadTxRoot, "CH747", 400},
+		{generator.ErrDuplicateIssuance, "CH748", 400},
+		{protocol.ErrIssuanceWindow, "CH749", 400},
+		{generator.ErrPoolFull, "CH750", 503},
+		{account.ErrInsufficient, "CH760", 400},
+		{account.ErrReserved, "CH761", 400},
+	}
+
+	for _, c := range cases {
+		// Wrapping and adding detail must not change the code.
+		for _, err := range []error{c.err, errors.Wrap(c.err, "wrapped"), errors.WithDetail(c.err, "detail")} {
+			resp := errorFormatter.Format(err)
+			if resp.ChainCode != c.code {
+				t.Errorf("Format(%q).ChainCode = %s want %s", err, resp.ChainCode, c.code)
+			}
+			if resp.HTTPStatus != c.status {
+				t.Errorf("Format(%q).HTTPStatus = %d want %d", err, resp.HTTPStatus, c.status)
+			}
+		}
+	}
+}
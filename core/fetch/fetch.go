@@ -53,7 +53,9 @@ func (rep *Replicator) PeerHeight() (uint64, time.Time) {
 //
 // It returns when its context is canceled.
 // After each attempt to fetch and apply a block, it calls health
-// to report either an error or nil to indicate success.
+// to report either an error or nil to indicate success. If the peer
+// sends a block that forks the blockchain, Fetch reports the
+// protocol.ForkError and stops fetching.
 func (rep *Replicator) Fetch(ctx context.Context, c *protocol.Chain, health func(error)) {
 	blockch, errch := DownloadBlocks(ctx, rep.peer, c.Height()+1)
 
@@ -71,7 +73,15 @@ func (rep *Replicator) Fetch(ctx context.Context, c *protocol.Chain, health func
 			prevBlock, prevSnapshot := c.State()
 			for {
 				err = applyBlock(ctx, c, prevSnapshot, prevBlock, b)
-				if err == protocol.ErrBadBlock {
+				if _, ok := errors.Root(err).(*protocol.ForkError); ok {
+					// The generator has equivocated. No block the peer
+					// sends can extend our chain, so stop fetching and
+					// leave the fork in the health status.
+					health(err)
+					log.Error(ctx, err)
+					<-ctx.Done()
+					return
+				} else if err == protocol.ErrBadBlock {
 					log.Fatalkv(ctx, log.KeyError, err)
 				} else if err != nil {
 					// This is a serious I/O error.
@@ -65,16 +65,18 @@ func (g *Generator) makeBlock(ctx context.Context) (err error) {
 		}
 	} else {
 		g.mu.Lock()
-		txs := g.pool
+		txs, added := g.pool, g.poolAdded
 		g.pool = nil
-		g.poolHashes = make(map[bc.Hash]bool)
+		g.poolAdded = make(map[bc.Hash]time.Time)
+		g.poolBytes = 0
 		g.mu.Unlock()
 
-		b, s, err = g.chain.GenerateBlock(ctx, latestBlock, latestSnapshot, time.Now(), txs)
+		b, s, err = g.chain.GenerateBlock(ctx, latestBlock, latestSnapshot, g.now(), txs)
 		if err != nil {
 			return errors.Wrap(err, "generate")
 		}
-		if len(b.Transactions) == 0 {
+		g.requeue(b, txs, added)
+		if len(b.Transactions) == 0 && !g.heartbeatDue(latestBlock) {
 			return nil // don't bother making an empty block
 		}
 		err = savePendingBlock(ctx, g.db, b)
@@ -85,6 +87,15 @@ func (g *Generator) makeBlock(ctx context.Context) (err error) {
 	return g.commitBlock(ctx, b, s, latestBlock)
 }
 
+// heartbeatDue reports whether enough time has passed since
+// latestBlock that g should make a block even if it's empty.
+func (g *Generator) heartbeatDue(latestBlock *legacy.Block) bool {
+	if g.emptyInterval <= 0 || latestBlock == nil {
+		return false
+	}
+	return g.now().Sub(latestBlock.Time()) >= g.emptyInterval
+}
+
 func (g *Generator) commitBlock(ctx context.Context, b *legacy.Block, s *state.Snapshot, prevBlock *legacy.Block) error {
 	err := g.getAndAddBlockSignatures(ctx, b, prevBlock)
 	if err != nil {
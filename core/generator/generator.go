@@ -6,16 +6,52 @@ package generator
 
 import (
 	"context"
+	"expvar"
+	"sort"
 	"sync"
 	"time"
 
 	"chain/database/pg"
+	"chain/errors"
 	"chain/log"
 	"chain/protocol"
 	"chain/protocol/bc"
 	"chain/protocol/bc/legacy"
 )
 
+// ErrDuplicateIssuance is returned by Submit for a tx with an
+// issuance nonce already used by a different submitted tx.
+var ErrDuplicateIssuance = errors.New("duplicate issuance")
+
+// ErrPoolFull is returned by Submit when the pending tx pool
+// is at its limits and its policy is RejectWhenFull.
+var ErrPoolFull = errors.New("pending transaction pool is full")
+
+// poolEvictions counts the txs evicted from the pending tx pool
+// to make room for newer ones.
+var poolEvictions = expvar.NewInt("generator_pool_evictions")
+
+// A PoolPolicy says what Submit does with a tx that doesn't
+// fit in the pending tx pool.
+type PoolPolicy int
+
+const (
+	// RejectWhenFull rejects the new tx with ErrPoolFull.
+	RejectWhenFull PoolPolicy = iota
+
+	// EvictOldest evicts the oldest txs from the pool
+	// until the new one fits.
+	EvictOldest
+)
+
+// PoolLimits bounds the pending tx pool. A zero limit means
+// no limit.
+type PoolLimits struct {
+	MaxTxs   int
+	MaxBytes int // total of the txs' serialized sizes
+	Policy   PoolPolicy
+}
+
 // A BlockSigner signs blocks.
 type BlockSigner interface {
 	// SignBlock returns an ed25519 signature over the block's sighash.
@@ -28,13 +64,32 @@ type BlockSigner interface {
 // an interval.
 type Generator struct {
 	// config
-	db      pg.DB
-	chain   *protocol.Chain
-	signers []BlockSigner
+	db        pg.DB
+	chain     *protocol.Chain
+	signers   []BlockSigner
+	now       func() time.Time                               // for testing
+	newTicker func(time.Duration) (<-chan time.Time, func()) // for testing
+
+	mu        sync.Mutex
+	pool      []*legacy.Tx // in topological order
+	poolAdded map[bc.Hash]time.Time
+	poolBytes int // total serialized size of pool
+	limits    PoolLimits
+
+	// emptyInterval is how long the generator may go without
+	// making a block before it makes an empty one. Zero means
+	// empty blocks are never made.
+	emptyInterval time.Duration
+
+	// nonces holds the issuance nonces of submitted txs until
+	// they expire, including txs already taken from the pool,
+	// since a nonce in a block is as used as one in the pool.
+	nonces map[bc.Hash]submittedNonce
+}
 
-	mu         sync.Mutex
-	pool       []*legacy.Tx // in topological order
-	poolHashes map[bc.Hash]bool
+type submittedNonce struct {
+	txID     bc.Hash
+	expiryMS uint64
 }
 
 // New creates and initializes a new Generator.
@@ -44,11 +99,69 @@ func New(
 	db pg.DB,
 ) *Generator {
 	return &Generator{
-		db:         db,
-		chain:      c,
-		signers:    s,
-		poolHashes: make(map[bc.Hash]bool),
+		db:        db,
+		chain:     c,
+		signers:   s,
+		now:       time.Now,
+		newTicker: newTicker,
+		poolAdded: make(map[bc.Hash]time.Time),
+		nonces:    make(map[bc.Hash]submittedNonce),
+	}
+}
+
+// PoolTxInfo describes a tx in the pending tx pool.
+type PoolTxInfo struct {
+	ID       bc.Hash   `json:"id"`
+	Size     int       `json:"size"`
+	AddedAt  time.Time `json:"added_at"`
+	Issuance bool      `json:"issuance"`
+}
+
+// Dump returns a description of each tx in the pending
+// tx pool, in the order they were added.
+func (g *Generator) Dump(ctx context.Context) ([]PoolTxInfo, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	infos := make([]PoolTxInfo, 0, len(g.pool))
+	for _, tx := range g.pool {
+		infos = append(infos, PoolTxInfo{
+			ID:       tx.ID,
+			Size:     tx.SerializedSize(),
+			AddedAt:  g.poolAdded[tx.ID],
+			Issuance: tx.HasIssuance(),
+		})
 	}
+	sort.SliceStable(infos, func(i, j int) bool {
+		return infos[i].AddedAt.Before(infos[j].AddedAt)
+	})
+	return infos, nil
+}
+
+// Contains reports whether the tx with the given
+// ID is in the pending tx pool.
+func (g *Generator) Contains(id bc.Hash) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	_, ok := g.poolAdded[id]
+	return ok
+}
+
+// SetPoolLimits sets the limits on the pending tx pool.
+// It should be called before g starts accepting txs.
+func (g *Generator) SetPoolLimits(lim PoolLimits) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.limits = lim
+}
+
+// SetEmptyBlockInterval sets how long g may go without making a
+// block before it makes an empty one anyway, so block height can
+// serve as a liveness signal. Zero, the default, means g never
+// makes empty blocks.
+// It should be called before g starts generating blocks.
+func (g *Generator) SetEmptyBlockInterval(d time.Duration) {
+	g.emptyInterval = d
 }
 
 // PendingTxs returns all of the pendings txs that will be
@@ -63,31 +176,271 @@ func (g *Generator) PendingTxs() []*legacy.Tx {
 }
 
 // Submit adds a new pending tx to the pending tx pool.
+// It returns an error if tx exceeds the transaction size limits,
+// including protocol.MaxTxSizeBytes, or ErrDuplicateIssuance if
+// one of its issuances has the nonce of an unexpired issuance in
+// a different tx. If the pool is at
+// its limits, it either returns ErrPoolFull or evicts the oldest
+// txs, according to the pool's policy. Each evicted tx takes the
+// pool txs that spend its outputs with it, since they can't be
+// valid without it.
 func (g *Generator) Submit(ctx context.Context, tx *legacy.Tx) error {
+	err := tx.CheckLimits()
+	if err != nil {
+		return err
+	}
+	err = protocol.CheckTxSize(tx)
+	if err != nil {
+		return err
+	}
+	err = g.chain.CheckIssuanceTime(tx.Tx, g.now())
+	if err != nil {
+		return err
+	}
+
 	g.mu.Lock()
 	defer g.mu.Unlock()
 
-	if g.poolHashes[tx.ID] {
+	if _, ok := g.poolAdded[tx.ID]; ok {
 		return nil
 	}
 
-	g.poolHashes[tx.ID] = true
+	size := tx.SerializedSize()
+	evicted, err := g.makeRoom(tx)
+	if err != nil {
+		return err
+	}
+
+	err = g.addNonces(tx.Tx)
+	if err != nil {
+		return err
+	}
+
+	g.evict(evicted)
+	g.poolAdded[tx.ID] = g.now()
 	g.pool = append(g.pool, tx)
+	g.poolBytes += size
 	return nil
 }
 
+// makeRoom returns which txs in the pool to evict for tx to
+// fit, or ErrPoolFull if it can't fit. g.mu must be held.
+func (g *Generator) makeRoom(tx *legacy.Tx) ([]bool, error) {
+	size := tx.SerializedSize()
+	if g.limits.Policy != EvictOldest && g.overLimits(len(g.pool)+1, g.poolBytes+size) {
+		return nil, errors.WithDetailf(ErrPoolFull, "pool holds %d txs totaling %d bytes", len(g.pool), g.poolBytes)
+	}
+	evicted, spent, ok := g.chooseEvictions(1, size)
+	if !ok {
+		return nil, errors.WithDetailf(ErrPoolFull, "pool holds %d txs totaling %d bytes", len(g.pool), g.poolBytes)
+	}
+	for _, id := range tx.Tx.SpentOutputIDs {
+		if spent[id] {
+			return nil, errors.WithDetailf(ErrPoolFull, "tx spends output %x of a tx that would be evicted", id.Bytes())
+		}
+	}
+	return evicted, nil
+}
+
+// overLimits reports whether a pool of n txs
+// totaling bytes would exceed g's limits.
+func (g *Generator) overLimits(n, bytes int) bool {
+	lim := g.limits
+	return (lim.MaxTxs > 0 && n > lim.MaxTxs) || (lim.MaxBytes > 0 && bytes > lim.MaxBytes)
+}
+
+// chooseEvictions marks the oldest txs in the pool for eviction,
+// each with the txs in the pool that depend on it, until the
+// rest of the pool and n more txs totaling size more bytes are
+// within g's limits. It returns the marks, indexed like the
+// pool, and the outputs of the marked txs. It reports false if
+// evicting the whole pool isn't enough. g.mu must be held.
+func (g *Generator) chooseEvictions(n, size int) (evicted []bool, outputs map[bc.Hash]bool, ok bool) {
+	n += len(g.pool)
+	bytes := g.poolBytes + size
+	evicted = make([]bool, len(g.pool))
+	outputs = make(map[bc.Hash]bool)
+	mark := func(i int) {
+		tx := g.pool[i]
+		evicted[i] = true
+		n--
+		bytes -= tx.SerializedSize()
+		for _, id := range tx.ResultIds {
+			outputs[*id] = true
+		}
+	}
+
+	oldest := 0
+	for g.overLimits(n, bytes) {
+		for oldest < len(g.pool) && evicted[oldest] {
+			oldest++
+		}
+		if oldest == len(g.pool) {
+			return nil, nil, false
+		}
+		mark(oldest)
+
+		// The pool is in topological order, so one pass
+		// finds every tx that depends on the evicted ones.
+		for i := oldest + 1; i < len(g.pool); i++ {
+			if evicted[i] {
+				continue
+			}
+			for _, id := range g.pool[i].Tx.SpentOutputIDs {
+				if outputs[id] {
+					mark(i)
+					break
+				}
+			}
+		}
+	}
+	return evicted, outputs, true
+}
+
+// evict removes the marked txs from the pool, along with
+// their issuance nonces, so they can be submitted again.
+// g.mu must be held.
+func (g *Generator) evict(evicted []bool) {
+	var n int
+	pool := g.pool[:0]
+	for i, tx := range g.pool {
+		if !evicted[i] {
+			pool = append(pool, tx)
+			continue
+		}
+		delete(g.poolAdded, tx.ID)
+		for _, id := range tx.Tx.NonceIDs {
+			if g.nonces[id].txID == tx.ID {
+				delete(g.nonces, id)
+			}
+		}
+		g.poolBytes -= tx.SerializedSize()
+		n++
+	}
+	for i := len(pool); i < len(g.pool); i++ {
+		g.pool[i] = nil // release evicted txs
+	}
+	g.pool = pool
+	poolEvictions.Add(int64(n))
+}
+
+// addNonces records the issuance nonces of tx, after pruning
+// expired ones. It returns ErrDuplicateIssuance, recording
+// nothing, if any is already recorded for a different tx.
+// g.mu must be held.
+func (g *Generator) addNonces(tx *bc.Tx) error {
+	nowMS := bc.Millis(g.now())
+	for id, n := range g.nonces {
+		if n.expiryMS < nowMS {
+			delete(g.nonces, id)
+		}
+	}
+
+	expiries := make([]uint64, 0, len(tx.NonceIDs))
+	for _, id := range tx.NonceIDs {
+		if n, ok := g.nonces[id]; ok && n.txID != tx.ID {
+			return errors.WithDetailf(ErrDuplicateIssuance, "nonce %x is used by tx %x", id.Bytes(), n.txID.Bytes())
+		}
+		nonce, err := tx.Nonce(id)
+		if err != nil {
+			return errors.Wrap(err, "checking nonce")
+		}
+		tr, err := tx.TimeRange(*nonce.TimeRangeId)
+		if err != nil {
+			return errors.Wrap(err, "checking nonce")
+		}
+		expiries = append(expiries, tr.MaxTimeMs)
+	}
+	for i, id := range tx.NonceIDs {
+		g.nonces[id] = submittedNonce{txID: tx.ID, expiryMS: expiries[i]}
+	}
+	return nil
+}
+
+// requeue returns to the pending tx pool the txs in tried that
+// weren't included in b but may be in later blocks: those that
+// aren't valid until after its timestamp, and those GenerateBlock
+// never got to because b was full. Txs too big for any block are
+// dropped. The rest go ahead of txs submitted
+// since tried was taken from the pool, which keeps the pool in
+// topological order. They keep the times they were first added,
+// from added. If that puts the pool over its limits, the oldest
+// txs are evicted, with the txs that depend on them, whatever
+// the pool's policy.
+func (g *Generator) requeue(b *legacy.Block, tried []*legacy.Tx, added map[bc.Hash]time.Time) {
+	included := make(map[bc.Hash]bool, len(b.Transactions))
+	for _, tx := range b.Transactions {
+		included[tx.ID] = true
+	}
+
+	// GenerateBlock stops at the first tx that doesn't fit in b,
+	// which comes after every tx it included. It skips txs that
+	// don't fit in any block.
+	untried := len(tried)
+	size := b.SerializedSize()
+	for i := len(tried) - 1; i >= 0 && !included[tried[i].ID]; i-- {
+		txSize := tried[i].SerializedSize()
+		if txSize <= protocol.MaxTxSizeBytes && !protocol.HasRoom(size, len(b.Transactions), txSize) {
+			untried = i
+		}
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	var requeued []*legacy.Tx
+	for i, tx := range tried {
+		if _, ok := g.poolAdded[tx.ID]; ok || included[tx.ID] {
+			continue
+		}
+		if i < untried && tx.MinTime <= b.TimestampMS {
+			continue
+		}
+		if tx.SerializedSize() > protocol.MaxTxSizeBytes {
+			continue
+		}
+		g.poolAdded[tx.ID] = added[tx.ID]
+		g.poolBytes += tx.SerializedSize()
+		requeued = append(requeued, tx)
+	}
+	g.pool = append(requeued, g.pool...)
+
+	evicted, _, _ := g.chooseEvictions(0, 0)
+	g.evict(evicted)
+}
+
+// newTicker returns the channel of a new time.Ticker
+// with the given period and a function that stops it.
+func newTicker(d time.Duration) (<-chan time.Time, func()) {
+	t := time.NewTicker(d)
+	return t.C, t.Stop
+}
+
 // Generate runs in a loop, making one new block
 // every block period. It returns when its context
 // is canceled.
+// It calls period before each wait for the next block
+// and restarts its ticker when the period has changed,
+// so a change takes effect after at most one more block.
 // After each attempt to make a block, it calls health
 // to report either an error or nil to indicate success.
 func (g *Generator) Generate(
 	ctx context.Context,
-	period time.Duration,
+	period func() time.Duration,
 	health func(error),
 ) {
-	ticks := time.Tick(period)
+	var (
+		cur   time.Duration
+		ticks <-chan time.Time
+		stop  = func() {}
+	)
+	defer func() { stop() }()
 	for {
+		if p := period(); p != cur {
+			stop()
+			cur = p
+			ticks, stop = g.newTicker(p)
+		}
 		select {
 		case <-ctx.Done():
 			log.Printf(ctx, "Deposed, Generate exiting")
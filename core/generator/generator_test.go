@@ -9,10 +9,14 @@ import (
 
 	"chain/crypto/ed25519"
 	"chain/database/pg/pgtest"
+	"chain/errors"
 	"chain/protocol"
+	"chain/protocol/bc"
 	"chain/protocol/bc/bctest"
 	"chain/protocol/bc/legacy"
 	"chain/protocol/prottest"
+	"chain/protocol/prottest/memstore"
+	"chain/protocol/state"
 	"chain/testutil"
 )
 
@@ -37,7 +41,7 @@ func TestGeneratorRecovery(t *testing.T) {
 	ctx, cancel := context.WithCancel(ctx)
 	defer cancel()
 
-	go New(c, nil, dbtx).Generate(ctx, 50*time.Millisecond, func(error) {})
+	go New(c, nil, dbtx).Generate(ctx, every(50*time.Millisecond), func(error) {})
 
 	// Wait for the block to land, and then make sure it's the same block
 	// that was pending before we ran Generate.
@@ -76,7 +80,7 @@ func TestGeneratorSignatureFailures(t *testing.T) {
 	height := c.Height()
 	ctx, cancel := context.WithCancel(ctx)
 	defer cancel()
-	go g.Generate(ctx, 50*time.Millisecond, func(err error) { t.Logf("%s\n", err) })
+	go g.Generate(ctx, every(50*time.Millisecond), func(err error) { t.Logf("%s\n", err) })
 
 	<-c.BlockWaiter(height + 1)
 	block, err := c.GetBlock(ctx, height+1)
@@ -88,6 +92,608 @@ func TestGeneratorSignatureFailures(t *testing.T) {
 	}
 }
 
+func TestGeneratorPeriodUpdate(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	c := prottest.NewChain(t)
+	initial := prottest.Initial(t, c).Hash()
+
+	// Replace the ticker with one that records each requested
+	// period and fires only when the test ticks it.
+	periods := make(chan time.Duration, 10)
+	ticks := make(chan time.Time)
+	g := New(c, nil, pgtest.NewTx(t))
+	g.newTicker = func(d time.Duration) (<-chan time.Time, func()) {
+		periods <- d
+		return ticks, func() {}
+	}
+
+	period := int64(time.Second)
+	made := make(chan error)
+	go g.Generate(ctx, func() time.Duration {
+		return time.Duration(atomic.LoadInt64(&period))
+	}, func(err error) { made <- err })
+
+	// tick submits a tx, so that there's something to put in
+	// a block, and fires the ticker to make a block.
+	tick := func() {
+		err := g.Submit(ctx, bctest.NewIssuanceTx(t, initial))
+		if err != nil {
+			testutil.FatalErr(t, err)
+		}
+		ticks <- time.Now()
+		if err := <-made; err != nil {
+			testutil.FatalErr(t, err)
+		}
+	}
+
+	height := c.Height()
+	tick()
+	atomic.StoreInt64(&period, int64(500*time.Millisecond))
+	tick()
+	tick()
+	for _, want := range []time.Duration{time.Second, 500 * time.Millisecond} {
+		if got := <-periods; got != want {
+			t.Errorf("ticker period = %s want %s", got, want)
+		}
+	}
+	if len(periods) > 0 {
+		t.Errorf("ticker restarted %d more times, want none", len(periods))
+	}
+	if got := c.Height(); got != height+3 {
+		t.Errorf("height = %d want %d", got, height+3)
+	}
+}
+
+// every returns a period function for Generate
+// that always reports d.
+func every(d time.Duration) func() time.Duration {
+	return func() time.Duration { return d }
+}
+
+func TestGeneratorEmptyBlocks(t *testing.T) {
+	ctx := context.Background()
+	c := prottest.NewChain(t)
+	initial := prottest.Initial(t, c)
+	g := New(c, nil, pgtest.NewTx(t))
+
+	start := initial.Time()
+	now := start
+	g.now = func() time.Time { return now }
+
+	// makeBlock sets the clock to start+d, makes a block, and
+	// checks the chain's resulting height.
+	makeBlock := func(d time.Duration, wantHeight uint64) {
+		now = start.Add(d)
+		err := g.makeBlock(ctx)
+		if err != nil {
+			testutil.FatalErr(t, err)
+		}
+		if got := c.Height(); got != wantHeight {
+			t.Fatalf("at %s, height = %d want %d", d, got, wantHeight)
+		}
+	}
+
+	// By default, empty blocks are never made.
+	makeBlock(time.Hour, 1)
+	start = now
+
+	g.SetEmptyBlockInterval(time.Minute)
+	makeBlock(30*time.Second, 1)
+	makeBlock(time.Minute, 2)
+
+	// A real block resets the heartbeat.
+	g.pool = append(g.pool, bctest.NewIssuanceTx(t, initial.Hash()))
+	makeBlock(90*time.Second, 3)
+	makeBlock(2*time.Minute, 3)
+	makeBlock(150*time.Second, 4)
+
+	wantTxs := []int{0, 1, 0}
+	for i, want := range wantTxs {
+		b, err := c.GetBlock(ctx, uint64(i+2))
+		if err != nil {
+			testutil.FatalErr(t, err)
+		}
+		if got := len(b.Transactions); got != want {
+			t.Errorf("block %d has %d txs, want %d", b.Height, got, want)
+		}
+	}
+
+	// A follower applies the empty blocks like any other.
+	follower, err := protocol.NewChain(ctx, initial.Hash(), memstore.New(), nil)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	err = follower.CommitAppliedBlock(ctx, initial, state.Empty())
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	for height := uint64(2); height <= c.Height(); height++ {
+		b, err := c.GetBlock(ctx, height)
+		if err != nil {
+			testutil.FatalErr(t, err)
+		}
+		prev, _ := follower.State()
+		err = follower.ValidateBlock(b, prev)
+		if err != nil {
+			testutil.FatalErr(t, err)
+		}
+		err = follower.CommitBlock(ctx, b)
+		if err != nil {
+			testutil.FatalErr(t, err)
+		}
+	}
+	got, _ := follower.State()
+	want, _ := c.State()
+	if got.Hash() != want.Hash() {
+		t.Errorf("follower at block %x, want %x", got.Hash().Bytes(), want.Hash().Bytes())
+	}
+}
+
+func TestGeneratorHoldsEarlyTxs(t *testing.T) {
+	ctx := context.Background()
+	c := prottest.NewChain(t)
+	g := New(c, nil, pgtest.NewTx(t))
+
+	// The generator's clock only moves when the test moves it.
+	now := time.Now()
+	g.now = func() time.Time { return now }
+
+	tx := bctest.NewIssuanceTx(t, prottest.Initial(t, c).Hash(), func(tx *legacy.Tx) {
+		tx.MinTime = bc.Millis(now.Add(time.Hour))
+		tx.MaxTime = bc.Millis(now.Add(2 * time.Hour))
+		*tx = *legacy.NewTx(tx.TxData)
+	})
+	err := g.Submit(ctx, tx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	height := c.Height()
+	err = g.makeBlock(ctx)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	if c.Height() != height {
+		t.Errorf("height = %d, want %d: made a block before the tx's min time", c.Height(), height)
+	}
+	if pending := g.PendingTxs(); len(pending) != 1 || pending[0].ID != tx.ID {
+		t.Fatalf("pending txs before min time = %v, want only %x", pending, tx.ID.Bytes())
+	}
+
+	now = now.Add(90 * time.Minute)
+	err = g.makeBlock(ctx)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	block, err := c.GetBlock(ctx, height+1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(block.Transactions) != 1 || block.Transactions[0].ID != tx.ID {
+		t.Errorf("block transactions = %v, want only %x", block.Transactions, tx.ID.Bytes())
+	}
+	if pending := g.PendingTxs(); len(pending) != 0 {
+		t.Errorf("pending txs after min time = %v, want none", pending)
+	}
+}
+
+func TestGeneratorRequeuesTxsOverBlockLimit(t *testing.T) {
+	ctx := context.Background()
+	c := prottest.NewChain(t)
+	g := New(c, nil, pgtest.NewTx(t))
+	initial := prottest.Initial(t, c).Hash()
+
+	// Together the txs are too big for one block.
+	var txs []*legacy.Tx
+	for size := 0; size <= protocol.MaxBlockSizeBytes; {
+		tx := bctest.NewIssuanceTx(t, initial, func(tx *legacy.Tx) {
+			tx.ReferenceData = make([]byte, legacy.MaxReferenceDataSize)
+			*tx = *legacy.NewTx(tx.TxData)
+		})
+		err := g.Submit(ctx, tx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		txs = append(txs, tx)
+		size += tx.SerializedSize()
+	}
+
+	height := c.Height()
+	err := g.makeBlock(ctx)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	block, err := c.GetBlock(ctx, height+1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := len(block.Transactions)
+	if n == 0 || n == len(txs) {
+		t.Fatalf("block has %d of %d txs, want some but not all", n, len(txs))
+	}
+	pending := g.PendingTxs()
+	if len(pending) != len(txs)-n {
+		t.Fatalf("pending txs = %d, want %d", len(pending), len(txs)-n)
+	}
+	for i, tx := range pending {
+		if tx.ID != txs[n+i].ID {
+			t.Errorf("pending tx %d = %x, want %x", i, tx.ID.Bytes(), txs[n+i].ID.Bytes())
+		}
+	}
+
+	err = g.makeBlock(ctx)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	block, err = c.GetBlock(ctx, height+2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(block.Transactions) != len(txs)-n {
+		t.Errorf("next block has %d txs, want %d", len(block.Transactions), len(txs)-n)
+	}
+}
+
+func TestGeneratorSkipsOversizedTxs(t *testing.T) {
+	ctx := context.Background()
+	c := prottest.NewChain(t)
+	g := New(c, nil, pgtest.NewTx(t))
+	initial := prottest.Initial(t, c).Hash()
+
+	// Each part of big is within the limits,
+	// but the whole doesn't fit in a block.
+	big := bctest.NewIssuanceTx(t, initial, func(tx *legacy.Tx) {
+		assetID := tx.Inputs[0].AssetID()
+		tx.Outputs = nil
+		for i := 0; i < 10; i++ {
+			tx.Outputs = append(tx.Outputs, legacy.NewTxOutput(assetID, 10, []byte{0xbe, 0xef}, make([]byte, legacy.MaxReferenceDataSize)))
+		}
+		*tx = *legacy.NewTx(tx.TxData)
+	})
+	if err := big.CheckLimits(); err != nil {
+		t.Fatal(err)
+	}
+	err := g.Submit(ctx, big)
+	if errors.Root(err) != legacy.ErrTxLimit {
+		t.Errorf("Submit(big tx) = %v want %v", err, legacy.ErrTxLimit)
+	}
+
+	// A big tx already in the pool mustn't hold up the txs after it.
+	tx := bctest.NewIssuanceTx(t, initial)
+	g.pool = append(g.pool, big)
+	g.poolAdded[big.ID] = time.Now()
+	g.poolBytes += big.SerializedSize()
+	err = g.Submit(ctx, tx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	height := c.Height()
+	err = g.makeBlock(ctx)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	block, err := c.GetBlock(ctx, height+1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(block.Transactions) != 1 || block.Transactions[0].ID != tx.ID {
+		t.Errorf("block has %d txs, want only %x", len(block.Transactions), tx.ID.Bytes())
+	}
+	if pending := g.PendingTxs(); len(pending) != 0 {
+		t.Errorf("pending txs = %d, want 0", len(pending))
+	}
+}
+
+func TestSubmitDuplicateIssuance(t *testing.T) {
+	ctx := context.Background()
+	c := prottest.NewChain(t)
+	g := New(c, nil, nil)
+
+	now := time.Now()
+	g.now = func() time.Time { return now }
+
+	tx := bctest.NewIssuanceTx(t, prottest.Initial(t, c).Hash())
+	err := g.Submit(ctx, tx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Same issuance nonce, different tx.
+	dupData := tx.TxData.Copy()
+	dupData.ReferenceData = []byte("dup")
+	dup := legacy.NewTx(dupData)
+
+	err = g.Submit(ctx, dup)
+	if errors.Root(err) != ErrDuplicateIssuance {
+		t.Errorf("Submit(duplicate) = %v want %v", err, ErrDuplicateIssuance)
+	}
+
+	// Resubmitting the same tx is fine.
+	err = g.Submit(ctx, tx)
+	if err != nil {
+		t.Errorf("Submit(resubmitted) = %v want nil", err)
+	}
+
+	// The nonce expires with the tx's time window, and
+	// then it no longer conflicts.
+	now = time.Unix(0, int64(tx.MaxTime+1)*int64(time.Millisecond))
+	err = g.Submit(ctx, dup)
+	if err != nil {
+		t.Errorf("Submit(duplicate) after expiry = %v want nil", err)
+	}
+}
+
+func TestSubmitIssuanceWindow(t *testing.T) {
+	ctx := context.Background()
+	c := prottest.NewChain(t)
+	g := New(c, nil, nil)
+
+	tx := bctest.NewIssuanceTx(t, prottest.Initial(t, c).Hash())
+	tx.MaxTime = tx.MinTime + bc.DurationMillis(c.MaxIssuanceWindow) + 1000
+	tx = legacy.NewTx(tx.TxData)
+	g.now = func() time.Time { return time.Unix(0, int64(tx.MinTime)*int64(time.Millisecond)) }
+
+	err := g.Submit(ctx, tx)
+	if errors.Root(err) != protocol.ErrIssuanceWindow {
+		t.Errorf("Submit() = %v want %v", err, protocol.ErrIssuanceWindow)
+	}
+	if len(g.PendingTxs()) != 0 {
+		t.Error("tx outside the issuance window was added to the pool")
+	}
+}
+
+func TestSubmitPoolLimits(t *testing.T) {
+	ctx := context.Background()
+	c := prottest.NewChain(t)
+	initial := prottest.Initial(t, c).Hash()
+
+	var txs []*legacy.Tx
+	for i := 0; i < 4; i++ {
+		txs = append(txs, bctest.NewIssuanceTx(t, initial))
+	}
+	size := txs[0].SerializedSize()
+	for _, tx := range txs {
+		if tx.SerializedSize() != size {
+			t.Fatalf("test txs have sizes %d and %d, want equal sizes", size, tx.SerializedSize())
+		}
+	}
+
+	full := []error{nil, nil, ErrPoolFull, ErrPoolFull}
+	cases := []struct {
+		name          string
+		limits        PoolLimits
+		wantErrs      []error
+		wantPool      []*legacy.Tx
+		wantEvictions int64
+	}{{
+		name:     "reject by count",
+		limits:   PoolLimits{MaxTxs: 2},
+		wantErrs: full,
+		wantPool: txs[:2],
+	}, {
+		name:     "reject by bytes",
+		limits:   PoolLimits{MaxBytes: 2*size + size/2},
+		wantErrs: full,
+		wantPool: txs[:2],
+	}, {
+		name:          "evict by count",
+		limits:        PoolLimits{MaxTxs: 2, Policy: EvictOldest},
+		wantErrs:      make([]error, 4),
+		wantPool:      txs[2:],
+		wantEvictions: 2,
+	}, {
+		name:          "evict by bytes",
+		limits:        PoolLimits{MaxBytes: 2*size + size/2, Policy: EvictOldest},
+		wantErrs:      make([]error, 4),
+		wantPool:      txs[2:],
+		wantEvictions: 2,
+	}, {
+		name:     "bigger than the pool",
+		limits:   PoolLimits{MaxBytes: size - 1, Policy: EvictOldest},
+		wantErrs: []error{ErrPoolFull, ErrPoolFull, ErrPoolFull, ErrPoolFull},
+	}}
+	for _, tc := range cases {
+		g := New(c, nil, nil)
+		g.SetPoolLimits(tc.limits)
+		before := poolEvictions.Value()
+
+		for i, tx := range txs {
+			err := g.Submit(ctx, tx)
+			if errors.Root(err) != tc.wantErrs[i] {
+				t.Errorf("%s: Submit(tx %d) = %v want %v", tc.name, i, err, tc.wantErrs[i])
+			}
+		}
+
+		var got, want []bc.Hash
+		for _, tx := range g.PendingTxs() {
+			got = append(got, tx.ID)
+		}
+		for _, tx := range tc.wantPool {
+			want = append(want, tx.ID)
+		}
+		if !testutil.DeepEqual(got, want) {
+			t.Errorf("%s: pool = %x want %x", tc.name, got, want)
+		}
+		if g.poolBytes != len(want)*size {
+			t.Errorf("%s: pool bytes = %d want %d", tc.name, g.poolBytes, len(want)*size)
+		}
+		if n := poolEvictions.Value() - before; n != tc.wantEvictions {
+			t.Errorf("%s: evictions = %d want %d", tc.name, n, tc.wantEvictions)
+		}
+
+		// An evicted tx's issuance is released,
+		// so it can be submitted again.
+		if tc.wantEvictions > 0 {
+			err := g.Submit(ctx, txs[0])
+			if err != nil {
+				t.Errorf("%s: Submit(evicted tx) = %v want nil", tc.name, err)
+			}
+		}
+	}
+}
+
+func TestSubmitEvictsDependents(t *testing.T) {
+	ctx := context.Background()
+	c := prottest.NewChain(t)
+	initial := prottest.Initial(t, c).Hash()
+
+	g := New(c, nil, nil)
+	g.SetPoolLimits(PoolLimits{MaxTxs: 2, Policy: EvictOldest})
+	before := poolEvictions.Value()
+
+	iss := bctest.NewIssuanceTx(t, initial)
+	spend := spendOutput(t, iss)
+	for _, tx := range []*legacy.Tx{iss, spend} {
+		err := g.Submit(ctx, tx)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Making room means evicting iss, which takes spend with it,
+	// so a tx spending spend's output can't go in the pool.
+	err := g.Submit(ctx, spendOutput(t, spend))
+	if errors.Root(err) != ErrPoolFull {
+		t.Errorf("Submit(dependent tx) = %v want %v", err, ErrPoolFull)
+	}
+
+	tx := bctest.NewIssuanceTx(t, initial)
+	err = g.Submit(ctx, tx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := g.PendingTxs()
+	if len(got) != 1 || got[0].ID != tx.ID {
+		t.Errorf("pool has %d txs, want only %x", len(got), tx.ID.Bytes())
+	}
+	if g.poolBytes != tx.SerializedSize() {
+		t.Errorf("pool bytes = %d want %d", g.poolBytes, tx.SerializedSize())
+	}
+	if n := poolEvictions.Value() - before; n != 2 {
+		t.Errorf("evictions = %d want 2", n)
+	}
+}
+
+func TestRequeuePoolLimits(t *testing.T) {
+	ctx := context.Background()
+	c := prottest.NewChain(t)
+	initial := prottest.Initial(t, c).Hash()
+
+	g := New(c, nil, nil)
+	g.SetPoolLimits(PoolLimits{MaxTxs: 3})
+
+	iss := bctest.NewIssuanceTx(t, initial)
+	tried := []*legacy.Tx{iss, spendOutput(t, iss), bctest.NewIssuanceTx(t, initial)}
+	added := make(map[bc.Hash]time.Time)
+	for _, tx := range tried {
+		added[tx.ID] = time.Now()
+	}
+
+	var submitted []*legacy.Tx
+	for i := 0; i < 2; i++ {
+		tx := bctest.NewIssuanceTx(t, initial)
+		err := g.Submit(ctx, tx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		submitted = append(submitted, tx)
+	}
+
+	// The tried txs aren't valid until after b's timestamp,
+	// so they all go back in the pool, ahead of the others.
+	// That's two too many, so the oldest is evicted, with
+	// the tx that spends its output.
+	b := &legacy.Block{BlockHeader: legacy.BlockHeader{Height: 2}}
+	g.requeue(b, tried, added)
+
+	want := append([]*legacy.Tx{tried[2]}, submitted...)
+	got := g.PendingTxs()
+	if len(got) != len(want) {
+		t.Fatalf("pool has %d txs, want %d", len(got), len(want))
+	}
+	var wantBytes int
+	for i, tx := range want {
+		if got[i].ID != tx.ID {
+			t.Errorf("pending tx %d = %x, want %x", i, got[i].ID.Bytes(), tx.ID.Bytes())
+		}
+		wantBytes += tx.SerializedSize()
+	}
+	if g.poolBytes != wantBytes {
+		t.Errorf("pool bytes = %d want %d", g.poolBytes, wantBytes)
+	}
+}
+
+// spendOutput returns a tx spending the first output of src.
+func spendOutput(tb testing.TB, src *legacy.Tx) *legacy.Tx {
+	out, err := src.Tx.Output(*src.Tx.ResultIds[0])
+	if err != nil {
+		testutil.FatalErr(tb, err)
+	}
+	v := out.Source.Value
+	return legacy.NewTx(legacy.TxData{
+		Version: 1,
+		MinTime: src.MinTime,
+		MaxTime: src.MaxTime,
+		Inputs: []*legacy.TxInput{
+			legacy.NewSpendInput(nil, *out.Source.Ref, *v.AssetId, v.Amount, out.Source.Position, out.ControlProgram.Code, *out.Data, nil),
+		},
+		Outputs: []*legacy.TxOutput{
+			legacy.NewTxOutput(*v.AssetId, v.Amount, []byte{0xbe, 0xef}, nil),
+		},
+	})
+}
+
+func TestDumpPool(t *testing.T) {
+	ctx := context.Background()
+	c := prottest.NewChain(t)
+	g := New(c, nil, nil)
+	initial := prottest.Initial(t, c).Hash()
+
+	now := time.Now()
+	g.now = func() time.Time { return now }
+	var txs []*legacy.Tx
+	for i := 0; i < 3; i++ {
+		tx := bctest.NewIssuanceTx(t, initial)
+		err := g.Submit(ctx, tx)
+		if err != nil {
+			testutil.FatalErr(t, err)
+		}
+		txs = append(txs, tx)
+		now = now.Add(time.Second)
+	}
+	// Requeued txs go back to the front of the pool,
+	// but keep the times they were added.
+	g.pool = []*legacy.Tx{txs[1], txs[0], txs[2]}
+
+	got, err := g.Dump(ctx)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	if len(got) != len(txs) {
+		t.Fatalf("len(Dump()) = %d want %d", len(got), len(txs))
+	}
+	for i, tx := range txs {
+		want := PoolTxInfo{
+			ID:       tx.ID,
+			Size:     tx.SerializedSize(),
+			AddedAt:  got[0].AddedAt.Add(time.Duration(i) * time.Second),
+			Issuance: true,
+		}
+		if !testutil.DeepEqual(got[i], want) {
+			t.Errorf("Dump()[%d] = %+v want %+v", i, got[i], want)
+		}
+	}
+
+	if !g.Contains(txs[0].ID) {
+		t.Error("Contains(pending tx) = false want true")
+	}
+	if g.Contains(bc.Hash{}) {
+		t.Error("Contains(unknown tx) = true want false")
+	}
+}
+
 func TestGetAndAddBlockSignatures(t *testing.T) {
 	c := prottest.NewChain(t, prottest.WithBlockSigners(1, 1))
 	pubkeys, privkeys := prottest.BlockKeyPairs(c)
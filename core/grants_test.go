@@ -16,7 +16,7 @@ func TestCreatGrantValidation(t *testing.T) {
 	_, db := pgtest.NewDB(t, pgtest.SchemaPath)
 
 	accessTokens := &accesstoken.CredentialStore{db}
-	_, err := accessTokens.Create(ctx, "test-token", "")
+	_, err := accessTokens.Create(ctx, "test-token", "", nil)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -130,7 +130,7 @@ func TestDeleteGrants(t *testing.T) {
 	_, db := pgtest.NewDB(t, pgtest.SchemaPath)
 
 	accessTokens := &accesstoken.CredentialStore{db}
-	_, err := accessTokens.Create(ctx, "test-token", "")
+	_, err := accessTokens.Create(ctx, "test-token", "", nil)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -227,12 +227,12 @@ func TestDeleteGrantsByAccessToken(t *testing.T) {
 	_, db := pgtest.NewDB(t, pgtest.SchemaPath)
 
 	accessTokens := &accesstoken.CredentialStore{db}
-	_, err := accessTokens.Create(ctx, "test-token-0", "")
+	_, err := accessTokens.Create(ctx, "test-token-0", "", nil)
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	_, err = accessTokens.Create(ctx, "test-token-1", "")
+	_, err = accessTokens.Create(ctx, "test-token-1", "", nil)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -3,38 +3,72 @@
 package core
 
 import (
+	"bytes"
 	"context"
+	"encoding/hex"
+	"encoding/json"
 
+	"chain/core/config"
 	"chain/core/mockhsm"
 	"chain/core/txbuilder"
 	"chain/crypto/ed25519/chainkd"
+	"chain/database/pg"
+	chainjson "chain/encoding/json"
+	"chain/errors"
+	"chain/log"
+	"chain/net/http/authn"
 	"chain/net/http/httperror"
 	"chain/net/http/httpjson"
 )
 
+var (
+	// errKeyInUse is returned when deleting a mockhsm key
+	// that the core still depends on.
+	errKeyInUse = errors.New("key in use")
+
+	// errBadDigest is returned by sign-hash when the
+	// digest isn't 32 bytes.
+	errBadDigest = errors.New("bad digest")
+)
+
 func init() {
 	errorFormatter.Errors[mockhsm.ErrDuplicateKeyAlias] = httperror.Info{400, "CH050", "Alias already exists"}
 	errorFormatter.Errors[mockhsm.ErrInvalidAfter] = httperror.Info{400, "CH801", "Invalid `after` in query"}
 	errorFormatter.Errors[mockhsm.ErrTooManyAliasesToList] = httperror.Info{400, "CH802", "Too many aliases to list"}
+	errorFormatter.Errors[mockhsm.ErrBadPassphrase] = httperror.Info{400, "CH803", "Wrong passphrase for exported key"}
+	errorFormatter.Errors[mockhsm.ErrBadExportedKey] = httperror.Info{400, "CH804", "Invalid exported key"}
+	errorFormatter.Errors[mockhsm.ErrDuplicateKey] = httperror.Info{400, "CH805", "Key already exists"}
+	errorFormatter.Errors[mockhsm.ErrReservedKeyAlias] = httperror.Info{400, "CH806", "Key alias is reserved"}
+	errorFormatter.Errors[mockhsm.ErrNoKey] = httperror.Info{400, "CH807", "Key not found"}
+	errorFormatter.Errors[errKeyInUse] = httperror.Info{400, "CH808", "Key is in use"}
+	errorFormatter.Errors[errBadDigest] = httperror.Info{400, "CH809", "Digest must be 32 bytes"}
 }
 
-// MockHSM configures the Core to expose the MockHSM endpoints. It
-// is only included in non-production builds.
+// MockHSM configures the Core to expose the MockHSM endpoints, and
+// to keep the block-signing key of a development Core in hsm. It is
+// only included in non-production builds.
 func MockHSM(hsm *mockhsm.HSM) RunOption {
 	return func(a *API) {
-		h := &mockHSMHandler{MockHSM: hsm}
+		a.devHSM = hsm
+		h := &mockHSMHandler{MockHSM: hsm, db: a.db, config: a.config}
 
 		needConfig := a.needConfig()
 		a.mux.Handle("/mockhsm/create-block-key", jsonHandler(h.mockhsmCreateBlockKey))
 		a.mux.Handle("/mockhsm/create-key", needConfig(h.mockhsmCreateKey))
 		a.mux.Handle("/mockhsm/list-keys", needConfig(h.mockhsmListKeys))
 		a.mux.Handle("/mockhsm/delkey", needConfig(h.mockhsmDelKey))
+		a.mux.Handle("/mockhsm/export-key", needConfig(h.mockhsmExportKey))
+		a.mux.Handle("/mockhsm/import-key", needConfig(h.mockhsmImportKey))
+		a.mux.Handle("/mockhsm/rename-key", needConfig(h.mockhsmRenameKey))
 		a.mux.Handle("/mockhsm/sign-transaction", needConfig(h.mockhsmSignTemplates))
+		a.mux.Handle("/mockhsm/sign-hash", needConfig(h.mockhsmSignHash))
 	}
 }
 
 type mockHSMHandler struct {
 	MockHSM *mockhsm.HSM
+	db      pg.DB
+	config  *config.Config
 }
 
 func (h *mockHSMHandler) mockhsmCreateBlockKey(ctx context.Context) (result *mockhsm.Pub, err error) {
@@ -70,8 +104,99 @@ func (h *mockHSMHandler) mockhsmListKeys(ctx context.Context, query requestQuery
 	}, nil
 }
 
-func (h *mockHSMHandler) mockhsmDelKey(ctx context.Context, xpub chainkd.XPub) error {
-	return h.MockHSM.DeleteChainKDKey(ctx, xpub)
+// delKeyRequest is the body of a delkey request. For
+// compatibility with older clients it may also be a bare xpub.
+type delKeyRequest struct {
+	XPub  chainkd.XPub `json:"xpub"`
+	Force bool         `json:"force"`
+}
+
+func (r *delKeyRequest) UnmarshalJSON(b []byte) error {
+	if len(b) > 0 && b[0] == '"' {
+		*r = delKeyRequest{}
+		return json.Unmarshal(b, &r.XPub)
+	}
+	type plain delKeyRequest
+	return json.Unmarshal(b, (*plain)(r))
+}
+
+// keyReference is something in the core that uses a key.
+type keyReference struct {
+	Type string `json:"type"`
+	ID   string `json:"id"`
+}
+
+// mockhsmDelKey deletes a key, unless the core's block signing
+// configuration or an account or asset still uses it and
+// the request isn't forced.
+func (h *mockHSMHandler) mockhsmDelKey(ctx context.Context, req delKeyRequest) error {
+	if !req.Force {
+		refs, err := h.keyReferences(ctx, req.XPub)
+		if err != nil {
+			return err
+		}
+		if len(refs) > 0 {
+			err = errors.WithDetailf(errKeyInUse, "key is used by %d account(s), asset(s) or block signer", len(refs))
+			return errors.WithData(err, "references", refs)
+		}
+	}
+	return h.MockHSM.DeleteChainKDKey(ctx, req.XPub)
+}
+
+// keyReferences returns the parts of the core's configuration
+// and the accounts and assets that use xpub.
+func (h *mockHSMHandler) keyReferences(ctx context.Context, xpub chainkd.XPub) ([]keyReference, error) {
+	var refs []keyReference
+	if h.config != nil && bytes.Equal(h.config.BlockPub, xpub.PublicKey()) {
+		refs = append(refs, keyReference{Type: "block_pub", ID: hex.EncodeToString(h.config.BlockPub)})
+	}
+	if h.db == nil {
+		return refs, nil
+	}
+
+	const q = `
+		SELECT 'account', a.account_id
+		FROM signers s JOIN accounts a ON a.account_id = s.id
+		WHERE $1 = ANY(s.xpubs)
+		UNION ALL
+		SELECT 'asset', encode(t.id, 'hex')
+		FROM signers s JOIN assets t ON t.signer_id = s.id
+		WHERE $1 = ANY(s.xpubs)
+		ORDER BY 1, 2
+	`
+	err := pg.ForQueryRows(ctx, h.db, q, xpub.Bytes(), func(typ, id string) {
+		refs = append(refs, keyReference{Type: typ, ID: id})
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "finding key references")
+	}
+	return refs, nil
+}
+
+func (h *mockHSMHandler) mockhsmExportKey(ctx context.Context, in struct {
+	XPub       chainkd.XPub `json:"xpub"`
+	Passphrase string       `json:"passphrase"`
+}) (interface{}, error) {
+	key, err := h.MockHSM.ExportKey(ctx, in.XPub, in.Passphrase)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{"exported_key": chainjson.HexBytes(key)}, nil
+}
+
+func (h *mockHSMHandler) mockhsmImportKey(ctx context.Context, in struct {
+	ExportedKey chainjson.HexBytes `json:"exported_key"`
+	Passphrase  string             `json:"passphrase"`
+	Alias       string             `json:"alias"`
+}) (*mockhsm.XPub, error) {
+	return h.MockHSM.ImportKey(ctx, in.ExportedKey, in.Passphrase, in.Alias)
+}
+
+func (h *mockHSMHandler) mockhsmRenameKey(ctx context.Context, in struct {
+	XPub  chainkd.XPub `json:"xpub"`
+	Alias string       `json:"alias"`
+}) (*mockhsm.XPub, error) {
+	return h.MockHSM.RenameAlias(ctx, in.XPub, in.Alias)
 }
 
 func (h *mockHSMHandler) mockhsmSignTemplates(ctx context.Context, x struct {
@@ -91,6 +216,35 @@ func (h *mockHSMHandler) mockhsmSignTemplates(ctx context.Context, x struct {
 	return resp
 }
 
+// mockhsmSignHash signs a digest computed by the caller, such as
+// a block hash, with the key for xpub. Each use is logged with the
+// key's alias and the caller's access token.
+func (h *mockHSMHandler) mockhsmSignHash(ctx context.Context, in struct {
+	XPub   chainkd.XPub       `json:"xpub"`
+	Digest chainjson.HexBytes `json:"digest"`
+}) (interface{}, error) {
+	var digest [32]byte
+	if len(in.Digest) != len(digest) {
+		return nil, errors.WithDetailf(errBadDigest, "got %d bytes", len(in.Digest))
+	}
+	copy(digest[:], in.Digest)
+
+	alias, err := h.MockHSM.Alias(ctx, in.XPub)
+	if err != nil {
+		return nil, err
+	}
+	log.Printkv(ctx, log.KeyMessage, "mockhsm sign-hash", "xpub", in.XPub, "alias", alias, "token", authn.Token(ctx), "digest", hex.EncodeToString(in.Digest))
+	sig, err := h.MockHSM.SignHash(ctx, in.XPub, digest)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{"signature": chainjson.HexBytes(sig)}, nil
+}
+
+// mockhsmSignTemplate signs with the key xpub derived along
+// path, which comes unchanged from the template's signing
+// instructions; the mock HSM knows nothing of accounts or
+// assets, so it can't work out the path itself.
 func (h *mockHSMHandler) mockhsmSignTemplate(ctx context.Context, xpub chainkd.XPub, path [][]byte, data [32]byte) ([]byte, error) {
 	sigBytes, err := h.MockHSM.XSign(ctx, xpub, path, data[:])
 	if err == mockhsm.ErrNoKey {
@@ -2,19 +2,26 @@ package core
 
 import (
 	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
 	"testing"
 	"time"
 
 	"chain/core/account"
 	"chain/core/asset"
+	"chain/core/config"
 	"chain/core/coretest"
 	"chain/core/generator"
 	"chain/core/mockhsm"
 	"chain/core/pin"
 	"chain/core/query"
 	"chain/core/txbuilder"
+	"chain/crypto/ed25519"
 	"chain/crypto/ed25519/chainkd"
 	"chain/database/pg/pgtest"
+	chainjson "chain/encoding/json"
+	"chain/errors"
 	"chain/protocol/bc"
 	"chain/protocol/prottest"
 	"chain/testutil"
@@ -33,11 +40,8 @@ func TestMockHSM(t *testing.T) {
 	go accounts.ProcessBlocks(ctx)
 	mockhsm := mockhsm.New(db)
 
-	xpub1, err := mockhsm.XCreate(ctx, "")
-	if err != nil {
-		t.Fatal(err)
-	}
-	acct1, err := accounts.Create(ctx, []chainkd.XPub{xpub1.XPub}, 1, "", nil, "")
+	xpub1 := coretest.CreateSeededKey(ctx, t, mockhsm, "", "TestMockHSM account key")
+	acct1, err := accounts.Create(ctx, []chainkd.XPub{xpub1}, 1, "", nil, "")
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -88,7 +92,7 @@ func TestMockHSM(t *testing.T) {
 	outTmpls := handler.mockhsmSignTemplates(ctx, struct {
 		Txs   []*txbuilder.Template `json:"transactions"`
 		XPubs []chainkd.XPub        `json:"xpubs"`
-	}{[]*txbuilder.Template{tmpl}, []chainkd.XPub{xpub1.XPub}})
+	}{[]*txbuilder.Template{tmpl}, []chainkd.XPub{xpub1}})
 	if len(outTmpls) != 1 {
 		t.Fatalf("expected 1 output template, got %d", len(outTmpls))
 	}
@@ -104,6 +108,172 @@ func TestMockHSM(t *testing.T) {
 	inspectSigInst(t, outTmpl.SigningInstructions[1], false)
 }
 
+func TestMockHSMListKeysPages(t *testing.T) {
+	_, db := pgtest.NewDB(t, pgtest.SchemaPath)
+	ctx := context.Background()
+	h := &mockHSMHandler{MockHSM: mockhsm.New(db)}
+
+	var created []chainkd.XPub
+	for i := 0; i < 250; i++ {
+		xpub, err := h.MockHSM.XCreate(ctx, fmt.Sprintf("key-%d", i))
+		if err != nil {
+			t.Fatal(err)
+		}
+		created = append(created, xpub.XPub)
+	}
+
+	// Paging through every key returns each once,
+	// newest first.
+	var (
+		got   []chainkd.XPub
+		pages int
+		query = requestQuery{PageSize: 100}
+	)
+	for {
+		p, err := h.mockhsmListKeys(ctx, query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pages++
+		for _, item := range p.Items.([]interface{}) {
+			got = append(got, item.(*mockhsm.XPub).XPub)
+		}
+		if p.LastPage {
+			break
+		}
+		if pages > 3 {
+			t.Fatalf("still paging after %d pages", pages)
+		}
+		query = p.Next
+	}
+	if pages != 3 {
+		t.Errorf("got %d pages, want 3", pages)
+	}
+	var want []chainkd.XPub
+	for i := len(created) - 1; i >= 0; i-- {
+		want = append(want, created[i])
+	}
+	if !testutil.DeepEqual(got, want) {
+		t.Errorf("listed %d keys, want all %d newest first", len(got), len(want))
+	}
+
+	// An alias filter returns only the keys asked for,
+	// still newest first.
+	p, err := h.mockhsmListKeys(ctx, requestQuery{
+		PageSize: 100,
+		Aliases:  []string{"key-3", "key-200", "no-such-key"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got = nil
+	for _, item := range p.Items.([]interface{}) {
+		got = append(got, item.(*mockhsm.XPub).XPub)
+	}
+	want = []chainkd.XPub{created[200], created[3]}
+	if !testutil.DeepEqual(got, want) || !p.LastPage {
+		t.Errorf("listed %x (last page %t) with alias filter, want %x", got, p.LastPage, want)
+	}
+}
+
+func TestMockHSMDelKeyInUse(t *testing.T) {
+	_, db := pgtest.NewDB(t, pgtest.SchemaPath)
+	ctx := context.Background()
+	c := prottest.NewChain(t)
+	pinStore := pin.NewStore(db)
+	assets := asset.NewRegistry(db, c, pinStore)
+	accounts := account.NewManager(db, c, pinStore)
+	hsm := mockhsm.New(db)
+
+	xpub, err := hsm.XCreate(ctx, "treasury")
+	if err != nil {
+		t.Fatal(err)
+	}
+	acct, err := accounts.Create(ctx, []chainkd.XPub{xpub.XPub}, 1, "", nil, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	asset, err := assets.Define(ctx, []chainkd.XPub{xpub.XPub}, 1, nil, "", nil, 0, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	blockPub := xpub.XPub.PublicKey()
+	h := &mockHSMHandler{
+		MockHSM: hsm,
+		db:      db,
+		config:  &config.Config{IsSigner: true, BlockPub: blockPub},
+	}
+
+	err = h.mockhsmDelKey(ctx, delKeyRequest{XPub: xpub.XPub})
+	if errors.Root(err) != errKeyInUse {
+		t.Fatalf("delete error = %v want %v", err, errKeyInUse)
+	}
+	want := []keyReference{
+		{Type: "block_pub", ID: hex.EncodeToString(blockPub)},
+		{Type: "account", ID: acct.ID},
+		{Type: "asset", ID: hex.EncodeToString(asset.AssetID.Bytes())},
+	}
+	got := errors.Data(err)["references"]
+	if !testutil.DeepEqual(got, want) {
+		t.Errorf("references = %+v want %+v", got, want)
+	}
+	_, err = hsm.XSign(ctx, xpub.XPub, nil, []byte("still here"))
+	if err != nil {
+		t.Fatalf("refused delete removed the key: %v", err)
+	}
+
+	// A bare xpub, as older clients send, is never forced.
+	var req delKeyRequest
+	err = json.Unmarshal([]byte(fmt.Sprintf("%q", xpub.XPub.String())), &req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if req.XPub != xpub.XPub || req.Force {
+		t.Errorf("decoded bare xpub as %+v", req)
+	}
+
+	err = h.mockhsmDelKey(ctx, delKeyRequest{XPub: xpub.XPub, Force: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = hsm.XSign(ctx, xpub.XPub, nil, []byte("gone"))
+	if err != mockhsm.ErrNoKey {
+		t.Errorf("sign after forced delete error = %v want %v", err, mockhsm.ErrNoKey)
+	}
+}
+
+func TestMockHSMSignHash(t *testing.T) {
+	ctx := context.Background()
+	h := &mockHSMHandler{MockHSM: mockhsm.NewMem()}
+	xpub, err := h.MockHSM.XCreate(ctx, "federation")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type signHashReq struct {
+		XPub   chainkd.XPub       `json:"xpub"`
+		Digest chainjson.HexBytes `json:"digest"`
+	}
+	digest := bc.NewHash([32]byte{1, 2, 3}).Bytes()
+	resp, err := h.mockhsmSignHash(ctx, signHashReq{xpub.XPub, digest})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sig := resp.(map[string]interface{})["signature"].(chainjson.HexBytes)
+	if !ed25519.Verify(xpub.XPub.PublicKey(), digest, sig) {
+		t.Error("signature doesn't verify with the xpub's public key")
+	}
+
+	_, err = h.mockhsmSignHash(ctx, signHashReq{xpub.XPub, digest[:31]})
+	if errors.Root(err) != errBadDigest {
+		t.Errorf("31-byte digest error = %v want %v", err, errBadDigest)
+	}
+	_, err = h.mockhsmSignHash(ctx, signHashReq{testutil.TestXPub, digest})
+	if errors.Root(err) != mockhsm.ErrNoKey {
+		t.Errorf("unknown key error = %v want %v", err, mockhsm.ErrNoKey)
+	}
+}
+
 func inspectSigInst(t *testing.T, si *txbuilder.SigningInstruction, expectSig bool) {
 	if len(si.SignatureWitnesses) != 1 {
 		t.Fatalf("len(si.SignatureWitnesses) is %d, want 1", len(si.SignatureWitnesses))
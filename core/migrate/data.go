@@ -81,4 +81,133 @@ var migrations = []migration{
 		ALTER TABLE ONLY core_id
 			ADD CONSTRAINT core_id_pkey PRIMARY KEY (singleton);
 	`},
+	{Name: `2017-07-05.0.query.output-spent-height.sql`, SQL: `
+		ALTER TABLE annotated_outputs ADD COLUMN spent_block_height bigint;
+		UPDATE annotated_outputs SET spent_block_height = block_height WHERE type = 'retire';
+		UPDATE annotated_outputs AS out SET spent_block_height = txs.block_height
+			FROM annotated_inputs AS inp, annotated_txs AS txs
+			WHERE inp.spent_output_id = out.output_id AND txs.tx_hash = inp.tx_hash;
+	`},
+	{Name: `2017-07-06.0.core.control-program-used.sql`, SQL: `
+		ALTER TABLE account_control_programs ADD COLUMN used boolean DEFAULT false NOT NULL;
+		UPDATE account_control_programs SET used = true
+			WHERE control_program IN (SELECT control_program FROM account_utxos);
+	`},
+	{Name: `2017-07-07.0.core.access-token-scopes.sql`, SQL: `
+		ALTER TABLE access_tokens ADD COLUMN scopes text[] DEFAULT '{}' NOT NULL;
+	`},
+	{Name: `2017-07-08.0.core.access-token-limits.sql`, SQL: `
+		ALTER TABLE access_tokens ADD COLUMN rate_limit integer;
+		ALTER TABLE access_tokens ADD COLUMN rate_burst integer;
+	`},
+	{Name: `2017-07-09.0.query.glob-to-like.sql`, SQL: `
+		CREATE FUNCTION glob_to_like(pattern text) RETURNS text
+		    LANGUAGE plpgsql IMMUTABLE STRICT
+		    AS $$
+			-- Converts a query filter MATCHES pattern to a LIKE pattern.
+			-- See globToLike in package chain/core/query/filter.
+		DECLARE
+			result text := '';
+			escaped boolean := false;
+			c text;
+		BEGIN
+			FOREACH c IN ARRAY regexp_split_to_array(pattern, '') LOOP
+				IF escaped THEN
+					escaped := false;
+					IF c IN ('%', '_', '\') THEN
+						result := result || '\';
+					END IF;
+					result := result || c;
+				ELSIF c = '\' THEN
+					escaped := true;
+				ELSIF c = '*' THEN
+					result := result || '%';
+				ELSIF c IN ('%', '_') THEN
+					result := result || '\' || c;
+				ELSE
+					result := result || c;
+				END IF;
+			END LOOP;
+			IF escaped THEN
+				result := result || '\\';
+			END IF;
+			RETURN result;
+		END;
+		$$;
+	`},
+	{Name: "2017-07-10.0.query.backfill.sql", SQL: `
+		CREATE UNIQUE INDEX annotated_txs_tx_hash_idx ON annotated_txs (tx_hash);
+		CREATE TABLE query_backfill (
+			singleton boolean DEFAULT true NOT NULL PRIMARY KEY,
+			from_height bigint NOT NULL,
+			to_height bigint NOT NULL,
+			next_height bigint NOT NULL,
+			updated_at timestamp with time zone DEFAULT now() NOT NULL,
+			CONSTRAINT query_backfill_singleton CHECK (singleton)
+		);
+	`},
+	{Name: "2017-07-10.1.query.text-search.sql", SQL: `
+		ALTER TABLE annotated_txs ADD COLUMN search tsvector;
+		ALTER TABLE annotated_assets ADD COLUMN search tsvector;
+		CREATE INDEX annotated_txs_search_idx ON annotated_txs USING gin (search);
+		CREATE INDEX annotated_assets_search_idx ON annotated_assets USING gin (search);
+	`},
+	{Name: "2017-07-11.0.core.asset-alias-lower.sql", SQL: `
+		-- Aliases that differ only in case would keep the index
+		-- from being created. Clients may be using any of them,
+		-- so don't pick new ones here; the operator must.
+		DO $$
+		DECLARE
+			dups text;
+		BEGIN
+			SELECT string_agg(format('%s (asset %s)', alias, encode(id, 'hex')), ', ' ORDER BY lower(alias), sort_id) INTO dups
+			FROM assets
+			WHERE lower(alias) IN (
+				SELECT lower(alias) FROM assets
+				WHERE alias IS NOT NULL
+				GROUP BY lower(alias) HAVING count(*) > 1
+			);
+			IF dups IS NOT NULL THEN
+				RAISE EXCEPTION 'asset aliases must differ by more than case; rename these assets before upgrading: %', dups;
+			END IF;
+		END;
+		$$;
+		CREATE UNIQUE INDEX assets_lower_alias_idx ON assets (lower(alias));
+	`},
+	{Name: "2017-07-12.0.core.asset-max-issuance.sql", SQL: `
+		ALTER TABLE assets ADD COLUMN max_issuance bigint;
+		CREATE TABLE asset_issuances (
+			asset_id bytea NOT NULL,
+			block_height bigint NOT NULL,
+			amount bigint NOT NULL,
+			PRIMARY KEY (asset_id, block_height)
+		);
+		CREATE TABLE pending_issuances (
+			asset_id bytea NOT NULL,
+			nonce bytea NOT NULL,
+			amount bigint NOT NULL,
+			expires_at timestamp with time zone NOT NULL,
+			PRIMARY KEY (asset_id, nonce)
+		);
+	`},
+	{Name: "2017-07-13.0.core.control-program-inactive.sql", SQL: `
+		ALTER TABLE account_control_programs ADD COLUMN created_at timestamp with time zone DEFAULT now() NOT NULL;
+		ALTER TABLE account_control_programs ADD COLUMN inactive boolean DEFAULT false NOT NULL;
+	`},
+	{Name: "2017-07-14.0.core.deleted-accounts.sql", SQL: `
+		CREATE TABLE deleted_accounts (
+			account_id text NOT NULL PRIMARY KEY,
+			alias text,
+			tags jsonb,
+			xpubs bytea[] NOT NULL,
+			quorum integer NOT NULL,
+			key_index bigint NOT NULL,
+			deleted_at timestamp with time zone DEFAULT now() NOT NULL
+		);
+	`},
+	{Name: "2017-07-16.0.core.drop-expire-control-programs-pin.sql", SQL: `
+		-- Nothing advances this pin anymore, and submits
+		-- that wait for every pin would wait forever.
+		DELETE FROM block_processors WHERE name = 'expire-control-programs';
+	`},
 }
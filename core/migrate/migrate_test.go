@@ -1,11 +1,15 @@
 package migrate
 
 import (
+	"context"
 	"crypto/sha256"
 	"encoding/hex"
+	"reflect"
+	"strings"
 	"testing"
 	"time"
 
+	"chain/database/pg"
 	"chain/database/pg/pgtest"
 )
 
@@ -123,3 +127,53 @@ func TestApplyMigrationSQL(t *testing.T) {
 		t.Error(err)
 	}
 }
+
+func TestAssetAliasCaseConflict(t *testing.T) {
+	save := migrations
+	defer func() { migrations = save }()
+
+	_, db := pgtest.NewDB(t, "testdata/empty.sql")
+
+	for i, m := range save {
+		if m.Name == "2017-07-11.0.core.asset-alias-lower.sql" {
+			migrations = save[:i]
+		}
+	}
+	err := Run(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = db.Exec(`
+		INSERT INTO assets (id, issuance_program, initial_block_hash, definition, vm_version, alias)
+		VALUES ('\x01', '', '', '', 1, 'gold'), ('\x02', '', '', '', 1, 'Gold'), ('\x03', '', '', '', 1, 'silver');
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The upgrade stops, naming the assets whose aliases
+	// the operator must change, and changes none itself.
+	migrations = save
+	err = Run(db)
+	if err == nil {
+		t.Fatal("Run() = nil, want error")
+	}
+	for _, want := range []string{"gold (asset 01)", "Gold (asset 02)"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Run() = %q, want it to name %s", err, want)
+		}
+	}
+	if strings.Contains(err.Error(), "silver") {
+		t.Errorf("Run() = %q, want it not to name silver", err)
+	}
+	var aliases []string
+	err = pg.ForQueryRows(context.Background(), db, `SELECT alias FROM assets ORDER BY id`, func(alias string) {
+		aliases = append(aliases, alias)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"gold", "Gold", "silver"}; !reflect.DeepEqual(aliases, want) {
+		t.Errorf("aliases = %v want %v", aliases, want)
+	}
+}
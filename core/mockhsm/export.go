@@ -0,0 +1,140 @@
+package mockhsm
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+
+	"chain/crypto/ed25519/chainkd"
+	"chain/crypto/scrypt"
+	"chain/errors"
+)
+
+var (
+	// ErrBadPassphrase is returned when an exported key can't
+	// be decrypted with the given passphrase.
+	ErrBadPassphrase = errors.New("wrong passphrase")
+
+	// ErrBadExportedKey is returned when importing something
+	// that isn't a key produced by ExportKey.
+	ErrBadExportedKey = errors.New("invalid exported key")
+
+	// ErrDuplicateKey is returned when importing a key
+	// the HSM already has.
+	ErrDuplicateKey = errors.New("duplicate key")
+)
+
+// Exported keys are laid out as
+//
+//	version (1 byte)
+//	scrypt log2(N), r and p (1 byte each)
+//	salt (16 bytes)
+//	nonce (12 bytes)
+//	xprv sealed with AES-256-GCM (80 bytes)
+//
+// The key is derived from the passphrase and salt with scrypt.
+// Everything before the sealed xprv is authenticated with it.
+const (
+	exportVersion = 1
+	exportLogN    = 15
+	exportR       = 8
+	exportP       = 1
+	maxExportLogN = 20
+
+	exportSaltLen   = 16
+	exportHeaderLen = 4 + exportSaltLen + 12
+)
+
+// ExportKey returns the xprv for xpub, encrypted with
+// passphrase, so that it can be restored with ImportKey.
+func (h *HSM) ExportKey(ctx context.Context, xpub chainkd.XPub, passphrase string) ([]byte, error) {
+	if passphrase == "" {
+		return nil, errors.WithDetail(ErrBadPassphrase, "passphrase can't be empty")
+	}
+	xprv, err := h.loadChainKDKey(ctx, xpub)
+	if err != nil {
+		return nil, err
+	}
+
+	b := make([]byte, exportHeaderLen, exportHeaderLen+len(xprv)+16)
+	b[0], b[1], b[2], b[3] = exportVersion, exportLogN, exportR, exportP
+	_, err = rand.Read(b[4:])
+	if err != nil {
+		return nil, err
+	}
+	aead, err := exportCipher(b, passphrase)
+	if err != nil {
+		return nil, err
+	}
+	nonce := b[4+exportSaltLen : exportHeaderLen]
+	return aead.Seal(b, nonce, xprv[:], b), nil
+}
+
+// ImportKey decrypts key, which must have been produced by
+// ExportKey with the same passphrase, and stores it in the HSM
+// under alias.
+func (h *HSM) ImportKey(ctx context.Context, key []byte, passphrase, alias string) (*XPub, error) {
+	if err := checkAlias(alias); err != nil {
+		return nil, err
+	}
+	if len(key) != exportHeaderLen+len(chainkd.XPrv{})+16 || key[0] != exportVersion {
+		return nil, errors.Wrap(ErrBadExportedKey)
+	}
+	if key[1] > maxExportLogN {
+		return nil, errors.WithDetailf(ErrBadExportedKey, "scrypt cost 2^%d is too high", key[1])
+	}
+	// scrypt's memory use grows with r*p*N, and the header comes
+	// from the client, so only accept the r and p ExportKey writes.
+	if key[2] != exportR || key[3] != exportP {
+		return nil, errors.WithDetailf(ErrBadExportedKey, "scrypt parameters r=%d p=%d are not supported", key[2], key[3])
+	}
+	aead, err := exportCipher(key, passphrase)
+	if err != nil {
+		return nil, err
+	}
+	nonce := key[4+exportSaltLen : exportHeaderLen]
+	prv, err := aead.Open(nil, nonce, key[exportHeaderLen:], key[:exportHeaderLen])
+	if err != nil {
+		// The passphrase is wrong, or the key was altered
+		// after it was exported; the AEAD can't tell which.
+		return nil, errors.Wrap(ErrBadPassphrase)
+	}
+	var xprv chainkd.XPrv
+	copy(xprv[:], prv)
+	xpub := xprv.XPub()
+
+	err = h.store.insert(ctx, chainKDKey, xpub.Bytes(), xprv.Bytes(), alias)
+	switch errors.Root(err) {
+	case ErrDuplicateKeyAlias:
+		return nil, errors.WithDetailf(ErrDuplicateKeyAlias, "value: %q", alias)
+	case ErrDuplicateKey:
+		return nil, errors.WithDetailf(ErrDuplicateKey, "xpub: %x", xpub.Bytes())
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "storing imported xpub")
+	}
+
+	result := &XPub{XPub: xpub}
+	if alias != "" {
+		result.Alias = &alias
+	}
+	return result, nil
+}
+
+// exportCipher returns the AEAD for an exported key
+// with the given header.
+func exportCipher(header []byte, passphrase string) (cipher.AEAD, error) {
+	n := 1 << header[1]
+	r, p := int(header[2]), int(header[3])
+	salt := header[4 : 4+exportSaltLen]
+	k, err := scrypt.Key([]byte(passphrase), salt, n, r, p, 32)
+	if err != nil {
+		return nil, errors.WithDetail(ErrBadExportedKey, err.Error())
+	}
+	block, err := aes.NewCipher(k)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
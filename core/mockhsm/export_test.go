@@ -0,0 +1,98 @@
+package mockhsm
+
+import (
+	"context"
+	"testing"
+
+	"chain/errors"
+	"chain/testutil"
+)
+
+func TestExportImportKey(t *testing.T) { forEachBackend(t, testExportImportKey) }
+
+func testExportImportKey(t *testing.T, hsm *HSM) {
+	ctx := context.Background()
+
+	xpub, err := hsm.XCreate(ctx, "payroll")
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg := []byte("In the face of ignorance and resistance I wrote financial systems into existence")
+	path := [][]byte{{1}, {2, 3}}
+	sig, err := hsm.XSign(ctx, xpub.XPub, path, msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = hsm.ExportKey(ctx, xpub.XPub, "")
+	if errors.Root(err) != ErrBadPassphrase {
+		t.Errorf("export with empty passphrase error = %v want %v", err, ErrBadPassphrase)
+	}
+	exported, err := hsm.ExportKey(ctx, xpub.XPub, "correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Importing a key the HSM already has fails.
+	_, err = hsm.ImportKey(ctx, exported, "correct horse", "")
+	if errors.Root(err) != ErrDuplicateKey {
+		t.Errorf("import of existing key error = %v want %v", err, ErrDuplicateKey)
+	}
+
+	err = hsm.DeleteChainKDKey(ctx, xpub.XPub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = hsm.XSign(ctx, xpub.XPub, path, msg)
+	if err != ErrNoKey {
+		t.Fatalf("sign with deleted key error = %v want %v", err, ErrNoKey)
+	}
+
+	_, err = hsm.ImportKey(ctx, exported, "battery staple", "payroll")
+	if errors.Root(err) != ErrBadPassphrase {
+		t.Errorf("import with wrong passphrase error = %v want %v", err, ErrBadPassphrase)
+	}
+	_, err = hsm.ImportKey(ctx, exported[1:], "correct horse", "payroll")
+	if errors.Root(err) != ErrBadExportedKey {
+		t.Errorf("import of truncated key error = %v want %v", err, ErrBadExportedKey)
+	}
+	costly := append([]byte(nil), exported...)
+	costly[2], costly[3] = 255, 255
+	_, err = hsm.ImportKey(ctx, costly, "correct horse", "payroll")
+	if errors.Root(err) != ErrBadExportedKey {
+		t.Errorf("import with r=255 p=255 error = %v want %v", err, ErrBadExportedKey)
+	}
+	tampered := append([]byte(nil), exported...)
+	tampered[len(tampered)-1] ^= 1
+	_, err = hsm.ImportKey(ctx, tampered, "correct horse", "payroll")
+	if errors.Root(err) != ErrBadPassphrase {
+		t.Errorf("import of altered key error = %v want %v", err, ErrBadPassphrase)
+	}
+
+	_, err = hsm.XCreate(ctx, "taken")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = hsm.ImportKey(ctx, exported, "correct horse", "taken")
+	if errors.Root(err) != ErrDuplicateKeyAlias {
+		t.Errorf("import with existing alias error = %v want %v", err, ErrDuplicateKeyAlias)
+	}
+
+	restored, err := hsm.ImportKey(ctx, exported, "correct horse", "payroll")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !testutil.DeepEqual(restored, xpub) {
+		t.Errorf("restored key = %+v want %+v", restored, xpub)
+	}
+	if !restored.XPub.Derive(path).Verify(msg, sig) {
+		t.Error("signature made before export doesn't verify with the restored key")
+	}
+	sig, err = hsm.XSign(ctx, restored.XPub, path, msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !xpub.XPub.Derive(path).Verify(msg, sig) {
+		t.Error("signature made by the restored key doesn't verify")
+	}
+}
@@ -0,0 +1,167 @@
+package mockhsm
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	"chain/errors"
+)
+
+// memStorage keeps keys in memory.
+type memStorage struct {
+	mu      sync.Mutex
+	keys    map[string]*memKey // by pub
+	aliases map[string]*memKey
+	nextID  int64
+}
+
+type memKey struct {
+	keyType string
+	pub     []byte
+	prv     []byte
+	alias   string
+	sortID  int64
+}
+
+func newMemStorage() *memStorage {
+	return &memStorage{
+		keys:    make(map[string]*memKey),
+		aliases: make(map[string]*memKey),
+	}
+}
+
+func (s *memStorage) insert(ctx context.Context, keyType string, pub, prv []byte, alias string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.keys[string(pub)]; ok {
+		return errors.Wrap(ErrDuplicateKey)
+	}
+	if _, ok := s.aliases[alias]; ok && alias != "" {
+		return errors.Wrap(ErrDuplicateKeyAlias)
+	}
+	s.nextID++
+	k := &memKey{
+		keyType: keyType,
+		pub:     append([]byte(nil), pub...),
+		prv:     append([]byte(nil), prv...),
+		alias:   alias,
+		sortID:  s.nextID,
+	}
+	s.keys[string(pub)] = k
+	if alias != "" {
+		s.aliases[alias] = k
+	}
+	return nil
+}
+
+func (s *memStorage) pubByAlias(ctx context.Context, alias string) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	k, ok := s.aliases[alias]
+	if !ok {
+		return nil, ErrNoKey
+	}
+	return k.pub, nil
+}
+
+func (s *memStorage) alias(ctx context.Context, keyType string, pub []byte) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	k, ok := s.keys[string(pub)]
+	if !ok || k.keyType != keyType {
+		return "", ErrNoKey
+	}
+	return k.alias, nil
+}
+
+func (s *memStorage) prv(ctx context.Context, keyType string, pub []byte) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	k, ok := s.keys[string(pub)]
+	if !ok || k.keyType != keyType {
+		return nil, ErrNoKey
+	}
+	return k.prv, nil
+}
+
+func (s *memStorage) rename(ctx context.Context, pub []byte, alias string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	k, ok := s.keys[string(pub)]
+	if !ok || k.keyType != chainKDKey {
+		return ErrNoKey
+	}
+	if k.alias == AutoBlockKeyAlias {
+		return errors.Wrap(ErrReservedKeyAlias)
+	}
+	if other, ok := s.aliases[alias]; ok && other != k {
+		return errors.Wrap(ErrDuplicateKeyAlias)
+	}
+	delete(s.aliases, k.alias)
+	k.alias = alias
+	if alias != "" {
+		s.aliases[alias] = k
+	}
+	return nil
+}
+
+func (s *memStorage) list(ctx context.Context, aliases []string, after int64, limit int) ([]*XPub, int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	want := make(map[string]bool, len(aliases))
+	for _, alias := range aliases {
+		want[alias] = true
+	}
+	var matches []*memKey
+	for _, k := range s.keys {
+		if k.keyType != chainKDKey || (len(aliases) > 0 && (k.alias == "" || !want[k.alias])) {
+			continue
+		}
+		if after != 0 && k.sortID >= after {
+			continue
+		}
+		matches = append(matches, k)
+	}
+	sort.Slice(matches, func(i, j int) bool { return matches[i].sortID > matches[j].sortID })
+
+	var (
+		xpubs []*XPub
+		last  int64
+	)
+	for _, k := range matches {
+		if len(xpubs) == limit {
+			break
+		}
+		xpub := new(XPub)
+		copy(xpub.XPub[:], k.pub)
+		if k.alias != "" {
+			alias := k.alias
+			xpub.Alias = &alias
+		}
+		xpubs = append(xpubs, xpub)
+		last = k.sortID
+	}
+	return xpubs, last, nil
+}
+
+func (s *memStorage) delete(ctx context.Context, keyType string, pub []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	k, ok := s.keys[string(pub)]
+	if !ok || k.keyType != keyType {
+		return nil
+	}
+	delete(s.keys, string(pub))
+	if k.alias != "" {
+		delete(s.aliases, k.alias)
+	}
+	return nil
+}
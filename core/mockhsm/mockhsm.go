@@ -3,16 +3,14 @@
 package mockhsm
 
 import (
+	"bytes"
 	"context"
-	"database/sql"
-	"fmt"
 	"strconv"
 	"sync"
 
-	"github.com/lib/pq"
-
 	"chain/crypto/ed25519"
 	"chain/crypto/ed25519/chainkd"
+	"chain/crypto/sha3pool"
 	"chain/database/pg"
 	"chain/errors"
 	"chain/protocol/bc/legacy"
@@ -21,16 +19,27 @@ import (
 // listKeyMaxAliases limits the alias filter to a sane maximum size.
 const listKeyMaxAliases = 200
 
+// minSeedLen is the length of the shortest seed
+// CreateKeyFromSeed accepts.
+const minSeedLen = 16
+
+// AutoBlockKeyAlias is the alias of the block-signing key
+// Configure creates for a development Core. It can't be
+// given to other keys, or taken from this one.
+const AutoBlockKeyAlias = "_CHAIN_CORE_AUTO_BLOCK_KEY"
+
 var (
 	ErrDuplicateKeyAlias    = errors.New("duplicate key alias")
 	ErrInvalidAfter         = errors.New("invalid after")
 	ErrNoKey                = errors.New("key not found")
 	ErrInvalidKeySize       = errors.New("key invalid size")
 	ErrTooManyAliasesToList = errors.New("requested aliases exceeds limit")
+	ErrReservedKeyAlias     = errors.New("reserved key alias")
+	ErrBadSeed              = errors.New("seed too short")
 )
 
 type HSM struct {
-	db pg.DB
+	store storage
 
 	cacheMu sync.Mutex
 	kdCache map[chainkd.XPub]chainkd.XPrv
@@ -47,16 +56,39 @@ type Pub struct {
 	Pub   ed25519.PublicKey `json:"pub"`
 }
 
+// New returns an HSM that stores its keys in db.
 func New(db pg.DB) *HSM {
+	return newHSM(&pgStorage{db: db})
+}
+
+// NewMem returns an HSM that keeps its keys in memory.
+// They are lost when the process exits.
+func NewMem() *HSM {
+	return newHSM(newMemStorage())
+}
+
+func newHSM(store storage) *HSM {
 	return &HSM{
-		db:      db,
+		store:   store,
 		kdCache: make(map[chainkd.XPub]chainkd.XPrv),
 		edCache: make(map[string]ed25519.PrivateKey),
 	}
 }
 
-// XCreate produces a new random xprv and stores it in the db.
+// checkAlias returns ErrReservedKeyAlias if alias
+// is reserved for the block-signing key.
+func checkAlias(alias string) error {
+	if alias == AutoBlockKeyAlias {
+		return errors.WithDetailf(ErrReservedKeyAlias, "value: %q", alias)
+	}
+	return nil
+}
+
+// XCreate produces a new random xprv and stores it.
 func (h *HSM) XCreate(ctx context.Context, alias string) (*XPub, error) {
+	if err := checkAlias(alias); err != nil {
+		return nil, err
+	}
 	xpub, _, err := h.createChainKDKey(ctx, alias, false)
 	return xpub, err
 }
@@ -66,35 +98,71 @@ func (h *HSM) createChainKDKey(ctx context.Context, alias string, get bool) (*XP
 	if err != nil {
 		return nil, false, err
 	}
-	sqlAlias := sql.NullString{String: alias, Valid: alias != ""}
 	var ptrAlias *string
 	if alias != "" {
 		ptrAlias = &alias
 	}
-	const q = `INSERT INTO mockhsm (pub, prv, alias, key_type) VALUES ($1, $2, $3, 'chain_kd')`
-	_, err = h.db.ExecContext(ctx, q, xpub.Bytes(), xprv.Bytes(), sqlAlias)
-	if err != nil {
-		if pg.IsUniqueViolation(err) {
-			if !get {
-				return nil, false, errors.WithDetailf(ErrDuplicateKeyAlias, "value: %q", alias)
-			}
-
-			var xpubBytes []byte
-			err = h.db.QueryRowContext(ctx, `SELECT pub FROM mockhsm WHERE alias = $1`, alias).Scan(&xpubBytes)
-			if err != nil {
-				return nil, false, errors.Wrapf(err, "reading existing xpub with alias %s", alias)
-			}
-			var existingXPub chainkd.XPub
-			copy(existingXPub[:], xpubBytes)
-			return &XPub{XPub: existingXPub, Alias: ptrAlias}, false, nil
+	err = h.store.insert(ctx, chainKDKey, xpub.Bytes(), xprv.Bytes(), alias)
+	if errors.Root(err) == ErrDuplicateKeyAlias {
+		if !get {
+			return nil, false, errors.WithDetailf(ErrDuplicateKeyAlias, "value: %q", alias)
 		}
+
+		xpubBytes, err := h.store.pubByAlias(ctx, alias)
+		if err != nil {
+			return nil, false, errors.Wrapf(err, "reading existing xpub with alias %s", alias)
+		}
+		var existingXPub chainkd.XPub
+		copy(existingXPub[:], xpubBytes)
+		return &XPub{XPub: existingXPub, Alias: ptrAlias}, false, nil
+	}
+	if err != nil {
 		return nil, false, errors.Wrap(err, "storing new xpub")
 	}
 	return &XPub{XPub: xpub, Alias: ptrAlias}, true, nil
 }
 
-// Create produces a new random prv and stores it in the db.
+// CreateKeyFromSeed derives an xprv from seed and stores it, so
+// that the same seed always yields the same xpub. It is meant for
+// reproducible test fixtures, never for production keys: anyone
+// who knows the seed has the key.
+func (h *HSM) CreateKeyFromSeed(ctx context.Context, alias string, seed []byte) (*XPub, error) {
+	if err := checkAlias(alias); err != nil {
+		return nil, err
+	}
+	if len(seed) < minSeedLen {
+		return nil, errors.WithDetailf(ErrBadSeed, "seed must be at least %d bytes", minSeedLen)
+	}
+	var entropy [32]byte
+	sha3pool.Sum256(entropy[:], seed)
+	xprv, xpub, err := chainkd.NewXKeys(bytes.NewReader(entropy[:]))
+	if err != nil {
+		return nil, err
+	}
+
+	err = h.store.insert(ctx, chainKDKey, xpub.Bytes(), xprv.Bytes(), alias)
+	switch errors.Root(err) {
+	case ErrDuplicateKeyAlias:
+		return nil, errors.WithDetailf(ErrDuplicateKeyAlias, "value: %q", alias)
+	case ErrDuplicateKey:
+		return nil, errors.WithDetailf(ErrDuplicateKey, "xpub: %x", xpub.Bytes())
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "storing seeded xpub")
+	}
+
+	result := &XPub{XPub: xpub}
+	if alias != "" {
+		result.Alias = &alias
+	}
+	return result, nil
+}
+
+// Create produces a new random prv and stores it.
 func (h *HSM) Create(ctx context.Context, alias string) (*Pub, error) {
+	if err := checkAlias(alias); err != nil {
+		return nil, err
+	}
 	pub, _, err := h.createEd25519Key(ctx, alias, false)
 	return pub, err
 }
@@ -102,41 +170,82 @@ func (h *HSM) Create(ctx context.Context, alias string) (*Pub, error) {
 // GetOrCreate looks for the Ed25519 key with the given alias, generating a
 // new one if it's not found.
 func (h *HSM) GetOrCreate(ctx context.Context, alias string) (*Pub, bool, error) {
+	if err := checkAlias(alias); err != nil {
+		return nil, false, err
+	}
 	return h.createEd25519Key(ctx, alias, true)
 }
 
+// GetOrCreateBlockKey returns the block-signing key for a
+// development Core, generating it the first time.
+func (h *HSM) GetOrCreateBlockKey(ctx context.Context) (ed25519.PublicKey, bool, error) {
+	pub, created, err := h.createEd25519Key(ctx, AutoBlockKeyAlias, true)
+	if err != nil {
+		return nil, false, err
+	}
+	return pub.Pub, created, nil
+}
+
 func (h *HSM) createEd25519Key(ctx context.Context, alias string, get bool) (*Pub, bool, error) {
 	pub, prv, err := ed25519.GenerateKey(nil)
 	if err != nil {
 		return nil, false, err
 	}
 
-	sqlAlias := sql.NullString{String: alias, Valid: alias != ""}
 	var ptrAlias *string
 	if alias != "" {
 		ptrAlias = &alias
 	}
-	const q = `INSERT INTO mockhsm (pub, prv, alias, key_type) VALUES ($1, $2, $3, 'ed25519')`
-	_, err = h.db.ExecContext(ctx, q, []byte(pub), []byte(prv), sqlAlias)
-	if err != nil {
-		if pg.IsUniqueViolation(err) {
-			if !get {
-				return nil, false, errors.WithDetailf(ErrDuplicateKeyAlias, "value: %q", alias)
-			}
-
-			var pubBytes []byte
-			err = h.db.QueryRowContext(ctx, `SELECT pub FROM mockhsm WHERE alias = $1`, alias).Scan(&pubBytes)
-			if err != nil {
-				return nil, false, errors.Wrapf(err, "reading existing pub with alias %s", alias)
-			}
-			return &Pub{Pub: ed25519.PublicKey(pubBytes), Alias: ptrAlias}, false, nil
+	err = h.store.insert(ctx, ed25519Key, pub, prv, alias)
+	if errors.Root(err) == ErrDuplicateKeyAlias {
+		if !get {
+			return nil, false, errors.WithDetailf(ErrDuplicateKeyAlias, "value: %q", alias)
 		}
+
+		pubBytes, err := h.store.pubByAlias(ctx, alias)
+		if err != nil {
+			return nil, false, errors.Wrapf(err, "reading existing pub with alias %s", alias)
+		}
+		return &Pub{Pub: ed25519.PublicKey(pubBytes), Alias: ptrAlias}, false, nil
+	}
+	if err != nil {
 		return nil, false, errors.Wrap(err, "storing new pub")
 	}
 	return &Pub{Pub: pub, Alias: ptrAlias}, true, nil
 }
 
-// ListKeys returns a list of all xpubs from the db.
+// RenameAlias changes the alias of the key for xpub to newAlias,
+// or removes its alias if newAlias is empty. The old alias can be
+// given to another key as soon as RenameAlias returns.
+func (h *HSM) RenameAlias(ctx context.Context, xpub chainkd.XPub, newAlias string) (*XPub, error) {
+	if err := checkAlias(newAlias); err != nil {
+		return nil, err
+	}
+
+	err := h.store.rename(ctx, xpub.Bytes(), newAlias)
+	switch errors.Root(err) {
+	case nil:
+	case ErrDuplicateKeyAlias:
+		return nil, errors.WithDetailf(ErrDuplicateKeyAlias, "value: %q", newAlias)
+	case ErrReservedKeyAlias:
+		return nil, errors.WithDetailf(ErrReservedKeyAlias, "value: %q", AutoBlockKeyAlias)
+	case ErrNoKey:
+		return nil, ErrNoKey
+	default:
+		return nil, errors.Wrap(err, "updating alias")
+	}
+
+	result := &XPub{XPub: xpub}
+	if newAlias != "" {
+		result.Alias = &newAlias
+	}
+	return result, nil
+}
+
+// ListKeys returns up to limit xpubs, newest first,
+// optionally only those with the given aliases. It also returns
+// a cursor for the next page: passing it as after continues the
+// list where this page ended.
 func (h *HSM) ListKeys(ctx context.Context, aliases []string, after string, limit int) ([]*XPub, string, error) {
 	if len(aliases) > listKeyMaxAliases {
 		return nil, "", errors.WithDetailf(ErrTooManyAliasesToList, "max: %d", listKeyMaxAliases)
@@ -154,44 +263,13 @@ func (h *HSM) ListKeys(ctx context.Context, aliases []string, after string, limi
 		}
 	}
 
-	var (
-		xpubs  []*XPub
-		params []interface{}
-	)
-	q := `
-		SELECT pub, alias, sort_id FROM mockhsm
-		WHERE key_type = 'chain_kd'
-	`
-
-	if len(aliases) > 0 {
-		params = append(params, pq.StringArray(aliases))
-		q += fmt.Sprintf(" AND alias = ANY($%d)", len(params))
-	}
-
-	if zafter != 0 {
-		params = append(params, zafter)
-		q += fmt.Sprintf(" AND sort_id < $%d", len(params))
-	}
-
-	q += fmt.Sprintf(" ORDER BY sort_id DESC LIMIT %d", limit)
-
-	consumeRow := func(b []byte, alias sql.NullString, sortID int64) {
-		var hdxpub chainkd.XPub
-		copy(hdxpub[:], b)
-		xpub := &XPub{XPub: hdxpub}
-		if alias.Valid {
-			xpub.Alias = &alias.String
-		}
-		xpubs = append(xpubs, xpub)
-		zafter = sortID
-	}
-	params = append(params, consumeRow)
-
-	err = pg.ForQueryRows(ctx, h.db, q, params...)
+	xpubs, last, err := h.store.list(ctx, aliases, zafter, limit)
 	if err != nil {
 		return nil, "", err
 	}
-
+	if len(xpubs) > 0 {
+		zafter = last
+	}
 	return xpubs, strconv.FormatInt(zafter, 10), nil
 }
 
@@ -203,11 +281,7 @@ func (h *HSM) loadChainKDKey(ctx context.Context, xpub chainkd.XPub) (xprv chain
 		return xprv, nil
 	}
 
-	var b []byte
-	err = h.db.QueryRowContext(ctx, "SELECT prv FROM mockhsm WHERE pub = $1 AND key_type='chain_kd'", xpub.Bytes()).Scan(&b)
-	if err == sql.ErrNoRows {
-		return xprv, ErrNoKey
-	}
+	b, err := h.store.prv(ctx, chainKDKey, xpub.Bytes())
 	if err != nil {
 		return xprv, err
 	}
@@ -230,12 +304,24 @@ func (h *HSM) XSign(ctx context.Context, xpub chainkd.XPub, path [][]byte, msg [
 	return xprv.Sign(msg), nil
 }
 
+// SignHash signs digest with the xprv for xpub itself, with no
+// derivation. It is for tools that compute what to sign, such as
+// a block hash, themselves.
+func (h *HSM) SignHash(ctx context.Context, xpub chainkd.XPub, digest [32]byte) ([]byte, error) {
+	return h.XSign(ctx, xpub, nil, digest[:])
+}
+
+// Alias returns the alias of the key for xpub,
+// which is empty if it has none.
+func (h *HSM) Alias(ctx context.Context, xpub chainkd.XPub) (string, error) {
+	return h.store.alias(ctx, chainKDKey, xpub.Bytes())
+}
+
 func (h *HSM) DeleteChainKDKey(ctx context.Context, xpub chainkd.XPub) error {
 	h.cacheMu.Lock()
 	delete(h.kdCache, xpub)
 	h.cacheMu.Unlock()
-	_, err := h.db.ExecContext(ctx, "DELETE FROM mockhsm WHERE pub = $1 AND key_type='chain_kd'", xpub.Bytes())
-	return err
+	return h.store.delete(ctx, chainKDKey, xpub.Bytes())
 }
 
 func (h *HSM) loadEd25519Key(ctx context.Context, pub ed25519.PublicKey) (prv ed25519.PrivateKey, err error) {
@@ -248,10 +334,7 @@ func (h *HSM) loadEd25519Key(ctx context.Context, pub ed25519.PublicKey) (prv ed
 		return prv, nil
 	}
 
-	err = h.db.QueryRowContext(ctx, "SELECT prv FROM mockhsm WHERE pub = $1 AND key_type='ed25519'", []byte(pub)).Scan(&prv)
-	if err == sql.ErrNoRows {
-		return prv, ErrNoKey
-	}
+	prv, err = h.store.prv(ctx, ed25519Key, pub)
 	if err != nil {
 		return prv, err
 	}
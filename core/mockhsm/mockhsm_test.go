@@ -1,22 +1,36 @@
 package mockhsm
 
 import (
+	"bytes"
 	"context"
 	"testing"
 
 	"github.com/davecgh/go-spew/spew"
 
 	"chain/crypto/ed25519"
+	"chain/crypto/ed25519/chainkd"
 	"chain/database/pg/pgtest"
 	"chain/errors"
 	"chain/protocol/bc/legacy"
 	"chain/testutil"
 )
 
-func TestMockHSMChainKDKeys(t *testing.T) {
-	_, db := pgtest.NewDB(t, pgtest.SchemaPath)
+// forEachBackend runs f against an HSM with each kind of
+// storage, so that they behave the same.
+func forEachBackend(t *testing.T, f func(*testing.T, *HSM)) {
+	t.Run("pg", func(t *testing.T) {
+		_, db := pgtest.NewDB(t, pgtest.SchemaPath)
+		f(t, New(db))
+	})
+	t.Run("mem", func(t *testing.T) {
+		f(t, NewMem())
+	})
+}
+
+func TestMockHSMChainKDKeys(t *testing.T) { forEachBackend(t, testMockHSMChainKDKeys) }
+
+func testMockHSMChainKDKeys(t *testing.T, hsm *HSM) {
 	ctx := context.Background()
-	hsm := New(db)
 	xpub, err := hsm.XCreate(ctx, "")
 	if err != nil {
 		t.Fatal(err)
@@ -56,10 +70,10 @@ func TestMockHSMChainKDKeys(t *testing.T) {
 	}
 }
 
-func TestMockHSMEd25519Keys(t *testing.T) {
-	_, db := pgtest.NewDB(t, pgtest.SchemaPath)
+func TestMockHSMEd25519Keys(t *testing.T) { forEachBackend(t, testMockHSMEd25519Keys) }
+
+func testMockHSMEd25519Keys(t *testing.T, hsm *HSM) {
 	ctx := context.Background()
-	hsm := New(db)
 	pub, err := hsm.Create(ctx, "")
 	if err != nil {
 		t.Fatal(err)
@@ -90,10 +104,10 @@ func TestMockHSMEd25519Keys(t *testing.T) {
 	}
 }
 
-func TestKeyWithAlias(t *testing.T) {
-	_, db := pgtest.NewDB(t, pgtest.SchemaPath)
+func TestKeyWithAlias(t *testing.T) { forEachBackend(t, testKeyWithAlias) }
+
+func testKeyWithAlias(t *testing.T, hsm *HSM) {
 	ctx := context.Background()
-	hsm := New(db)
 	xpub, err := hsm.XCreate(ctx, "some-alias")
 	if err != nil {
 		t.Fatal(err)
@@ -143,10 +157,75 @@ func TestKeyWithAlias(t *testing.T) {
 	}
 }
 
-func TestKeyWithEmptyAlias(t *testing.T) {
-	_, db := pgtest.NewDB(t, pgtest.SchemaPath)
+func TestRenameAlias(t *testing.T) { forEachBackend(t, testRenameAlias) }
+
+func testRenameAlias(t *testing.T, hsm *HSM) {
+	ctx := context.Background()
+	xpub, err := hsm.XCreate(ctx, "test-key-final-2-really")
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := hsm.XCreate(ctx, "other")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := hsm.RenameAlias(ctx, xpub.XPub, "test-key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "test-key"
+	if !testutil.DeepEqual(got, &XPub{XPub: xpub.XPub, Alias: &want}) {
+		t.Errorf("renamed key = %v want alias %q", spew.Sdump(got), want)
+	}
+	xpubs, _, err := hsm.ListKeys(ctx, []string{"test-key"}, "", 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(xpubs) != 1 || xpubs[0].XPub != xpub.XPub {
+		t.Errorf("keys with new alias = %v, want only the renamed key", spew.Sdump(xpubs))
+	}
+
+	// The old alias is free again.
+	_, err = hsm.XCreate(ctx, "test-key-final-2-really")
+	if err != nil {
+		t.Errorf("reusing old alias: %s", err)
+	}
+
+	_, err = hsm.RenameAlias(ctx, other.XPub, "test-key")
+	if errors.Root(err) != ErrDuplicateKeyAlias {
+		t.Errorf("rename to taken alias error = %v want %v", err, ErrDuplicateKeyAlias)
+	}
+
+	_, err = hsm.RenameAlias(ctx, other.XPub, AutoBlockKeyAlias)
+	if errors.Root(err) != ErrReservedKeyAlias {
+		t.Errorf("rename to reserved alias error = %v want %v", err, ErrReservedKeyAlias)
+	}
+	// A chain_kd key stored with the reserved alias before
+	// it was reserved keeps it.
+	reservedPrv, reserved, err := chainkd.NewXKeys(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = hsm.store.insert(ctx, chainKDKey, reserved.Bytes(), reservedPrv.Bytes(), AutoBlockKeyAlias)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = hsm.RenameAlias(ctx, reserved, "mine-now")
+	if errors.Root(err) != ErrReservedKeyAlias {
+		t.Errorf("rename of reserved alias error = %v want %v", err, ErrReservedKeyAlias)
+	}
+
+	_, err = hsm.RenameAlias(ctx, testutil.TestXPub, "missing")
+	if err != ErrNoKey {
+		t.Errorf("rename of missing key error = %v want %v", err, ErrNoKey)
+	}
+}
+
+func TestKeyWithEmptyAlias(t *testing.T) { forEachBackend(t, testKeyWithEmptyAlias) }
+
+func testKeyWithEmptyAlias(t *testing.T, hsm *HSM) {
 	ctx := context.Background()
-	hsm := New(db)
 	for i := 0; i < 2; i++ {
 		_, err := hsm.XCreate(ctx, "")
 		if errors.Root(err) != nil {
@@ -155,10 +234,10 @@ func TestKeyWithEmptyAlias(t *testing.T) {
 	}
 }
 
-func TestKeyOrdering(t *testing.T) {
-	_, db := pgtest.NewDB(t, pgtest.SchemaPath)
+func TestKeyOrdering(t *testing.T) { forEachBackend(t, testKeyOrdering) }
+
+func testKeyOrdering(t *testing.T, hsm *HSM) {
 	ctx := context.Background()
-	hsm := New(db)
 
 	xpub1, err := hsm.XCreate(ctx, "first-key")
 	if err != nil {
@@ -196,6 +275,191 @@ func TestKeyOrdering(t *testing.T) {
 	}
 }
 
+func TestGetOrCreateBlockKey(t *testing.T) { forEachBackend(t, testGetOrCreateBlockKey) }
+
+func testGetOrCreateBlockKey(t *testing.T, hsm *HSM) {
+	ctx := context.Background()
+	pub, created, err := hsm.GetOrCreateBlockKey(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !created {
+		t.Error("first call didn't create a key")
+	}
+	again, created, err := hsm.GetOrCreateBlockKey(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if created || !bytes.Equal(again, pub) {
+		t.Errorf("second call = %x, %t want %x, false", again, created, pub)
+	}
+
+	bh := legacy.BlockHeader{}
+	msg := bh.Hash()
+	sig, err := hsm.Sign(ctx, pub, &bh)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ed25519.Verify(pub, msg.Bytes(), sig) {
+		t.Error("block key signature doesn't verify")
+	}
+}
+
+func TestReservedKeyAlias(t *testing.T) { forEachBackend(t, testReservedKeyAlias) }
+
+func testReservedKeyAlias(t *testing.T, hsm *HSM) {
+	ctx := context.Background()
+	_, _, err := hsm.GetOrCreateBlockKey(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	xpub, err := hsm.XCreate(ctx, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	exported, err := hsm.ExportKey(ctx, xpub.XPub, "correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = hsm.DeleteChainKDKey(ctx, xpub.XPub)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		name string
+		f    func() error
+	}{
+		{"XCreate", func() error {
+			_, err := hsm.XCreate(ctx, AutoBlockKeyAlias)
+			return err
+		}},
+		{"Create", func() error {
+			_, err := hsm.Create(ctx, AutoBlockKeyAlias)
+			return err
+		}},
+		{"GetOrCreate", func() error {
+			_, _, err := hsm.GetOrCreate(ctx, AutoBlockKeyAlias)
+			return err
+		}},
+		{"CreateKeyFromSeed", func() error {
+			_, err := hsm.CreateKeyFromSeed(ctx, AutoBlockKeyAlias, []byte("a seed of at least sixteen bytes"))
+			return err
+		}},
+		{"ImportKey", func() error {
+			_, err := hsm.ImportKey(ctx, exported, "correct horse", AutoBlockKeyAlias)
+			return err
+		}},
+		{"RenameAlias", func() error {
+			other, err := hsm.XCreate(ctx, "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, err = hsm.RenameAlias(ctx, other.XPub, AutoBlockKeyAlias)
+			return err
+		}},
+	}
+	for _, c := range cases {
+		if err := c.f(); errors.Root(err) != ErrReservedKeyAlias {
+			t.Errorf("%s with reserved alias error = %v want %v", c.name, err, ErrReservedKeyAlias)
+		}
+	}
+}
+
+func TestCreateKeyFromSeed(t *testing.T) { forEachBackend(t, testCreateKeyFromSeed) }
+
+func testCreateKeyFromSeed(t *testing.T, hsm *HSM) {
+	ctx := context.Background()
+	seed := []byte("reproducible fixture key")
+
+	xpub, err := hsm.CreateKeyFromSeed(ctx, "fixture", seed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The xpub must be the same in every run
+	// so that golden files don't change.
+	const want = "07ee5146a877d4247def52a947bc5637eaae2a742434f81f4f94770d6ae0ffc910d70db4429f7f5982907011fa6df179b22b6c5e3befb22c02bf72c953ab9388"
+	if got := xpub.XPub.String(); got != want {
+		t.Errorf("seeded xpub = %s want %s", got, want)
+	}
+
+	// Another HSM derives the same key.
+	other, err := NewMem().CreateKeyFromSeed(ctx, "", seed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if other.XPub != xpub.XPub {
+		t.Errorf("same seed gave xpubs %s and %s", xpub.XPub, other.XPub)
+	}
+
+	msg := []byte("In the face of ignorance and resistance I wrote financial systems into existence")
+	path := [][]byte{{1}}
+	sig, err := hsm.XSign(ctx, xpub.XPub, path, msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !xpub.XPub.Derive(path).Verify(msg, sig) {
+		t.Error("signature by seeded key doesn't verify")
+	}
+
+	_, err = hsm.CreateKeyFromSeed(ctx, "again", seed)
+	if errors.Root(err) != ErrDuplicateKey {
+		t.Errorf("reusing seed error = %v want %v", err, ErrDuplicateKey)
+	}
+	_, err = hsm.CreateKeyFromSeed(ctx, "short", []byte("too short"))
+	if errors.Root(err) != ErrBadSeed {
+		t.Errorf("short seed error = %v want %v", err, ErrBadSeed)
+	}
+}
+
+func TestSignHash(t *testing.T) { forEachBackend(t, testSignHash) }
+
+func testSignHash(t *testing.T, hsm *HSM) {
+	ctx := context.Background()
+	xpub, err := hsm.XCreate(ctx, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	digest := [32]byte{1, 2, 3}
+	sig, err := hsm.SignHash(ctx, xpub.XPub, digest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ed25519.Verify(xpub.XPub.PublicKey(), digest[:], sig) {
+		t.Error("signature doesn't verify with the xpub's public key")
+	}
+	_, err = hsm.SignHash(ctx, testutil.TestXPub, digest)
+	if err != ErrNoKey {
+		t.Errorf("sign with missing key error = %v want %v", err, ErrNoKey)
+	}
+}
+
+func TestMemConcurrentCreate(t *testing.T) {
+	ctx := context.Background()
+	hsm := NewMem()
+
+	const n = 10
+	errs := make(chan error, n)
+	for i := 0; i < n; i++ {
+		go func() {
+			_, err := hsm.XCreate(ctx, "contested")
+			errs <- err
+		}()
+	}
+	var created int
+	for i := 0; i < n; i++ {
+		err := <-errs
+		if err == nil {
+			created++
+		} else if errors.Root(err) != ErrDuplicateKeyAlias {
+			t.Errorf("create error = %v want %v", err, ErrDuplicateKeyAlias)
+		}
+	}
+	if created != 1 {
+		t.Errorf("created %d keys with the same alias, want 1", created)
+	}
+}
+
 func BenchmarkSign(b *testing.B) {
 	b.StopTimer()
 
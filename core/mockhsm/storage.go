@@ -0,0 +1,176 @@
+package mockhsm
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/lib/pq"
+
+	"chain/database/pg"
+	"chain/errors"
+)
+
+// Key types, as stored in the mockhsm table's key_type column.
+const (
+	chainKDKey = "chain_kd"
+	ed25519Key = "ed25519"
+)
+
+// storage holds an HSM's keys. Aliases are unique
+// across keys of both types.
+type storage interface {
+	// insert stores a new key. It returns ErrDuplicateKeyAlias
+	// if another key has alias, or ErrDuplicateKey if pub is
+	// already stored. An empty alias is no alias.
+	insert(ctx context.Context, keyType string, pub, prv []byte, alias string) error
+
+	// pubByAlias returns the public key with alias.
+	pubByAlias(ctx context.Context, alias string) ([]byte, error)
+
+	// alias returns the alias of the key for pub, which is
+	// empty if it has none, or ErrNoKey if there is no such key.
+	alias(ctx context.Context, keyType string, pub []byte) (string, error)
+
+	// prv returns the private key for pub,
+	// or ErrNoKey if there is none.
+	prv(ctx context.Context, keyType string, pub []byte) ([]byte, error)
+
+	// rename sets the alias of the chain_kd key for pub, or
+	// removes it if alias is empty. It returns ErrNoKey if there
+	// is no such key, ErrReservedKeyAlias if the key has
+	// AutoBlockKeyAlias, or ErrDuplicateKeyAlias if another key
+	// has alias.
+	rename(ctx context.Context, pub []byte, alias string) error
+
+	// list returns up to limit chain_kd keys, newest first,
+	// optionally only those with the given aliases and only
+	// those older than the key with sort ID after if it's
+	// nonzero. It also returns the sort ID of the last key
+	// in the list.
+	list(ctx context.Context, aliases []string, after int64, limit int) ([]*XPub, int64, error)
+
+	// delete removes the key for pub, if there is one.
+	delete(ctx context.Context, keyType string, pub []byte) error
+}
+
+// pgStorage stores keys in the mockhsm table.
+type pgStorage struct {
+	db pg.DB
+}
+
+func (s *pgStorage) insert(ctx context.Context, keyType string, pub, prv []byte, alias string) error {
+	sqlAlias := sql.NullString{String: alias, Valid: alias != ""}
+	const q = `INSERT INTO mockhsm (pub, prv, alias, key_type) VALUES ($1, $2, $3, $4)`
+	_, err := s.db.ExecContext(ctx, q, pub, prv, sqlAlias, keyType)
+	if pqErr, ok := err.(*pq.Error); ok && pqErr.Code.Name() == "unique_violation" {
+		if pqErr.Constraint == "mockhsm_alias_key" {
+			return errors.Wrap(ErrDuplicateKeyAlias)
+		}
+		return errors.Wrap(ErrDuplicateKey)
+	}
+	return errors.Wrap(err)
+}
+
+func (s *pgStorage) pubByAlias(ctx context.Context, alias string) ([]byte, error) {
+	var pub []byte
+	err := s.db.QueryRowContext(ctx, `SELECT pub FROM mockhsm WHERE alias = $1`, alias).Scan(&pub)
+	return pub, errors.Wrap(err)
+}
+
+func (s *pgStorage) alias(ctx context.Context, keyType string, pub []byte) (string, error) {
+	var alias sql.NullString
+	err := s.db.QueryRowContext(ctx, "SELECT alias FROM mockhsm WHERE pub = $1 AND key_type = $2", pub, keyType).Scan(&alias)
+	if err == sql.ErrNoRows {
+		return "", ErrNoKey
+	}
+	return alias.String, err
+}
+
+func (s *pgStorage) prv(ctx context.Context, keyType string, pub []byte) ([]byte, error) {
+	var prv []byte
+	err := s.db.QueryRowContext(ctx, "SELECT prv FROM mockhsm WHERE pub = $1 AND key_type = $2", pub, keyType).Scan(&prv)
+	if err == sql.ErrNoRows {
+		return nil, ErrNoKey
+	}
+	return prv, err
+}
+
+func (s *pgStorage) rename(ctx context.Context, pub []byte, alias string) error {
+	sqlAlias := sql.NullString{String: alias, Valid: alias != ""}
+	const q = `
+		UPDATE mockhsm SET alias = $2
+		WHERE pub = $1 AND key_type = 'chain_kd' AND alias IS DISTINCT FROM $3
+	`
+	res, err := s.db.ExecContext(ctx, q, pub, sqlAlias, AutoBlockKeyAlias)
+	if pg.IsUniqueViolation(err) {
+		return errors.Wrap(ErrDuplicateKeyAlias)
+	}
+	if err != nil {
+		return errors.Wrap(err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return errors.Wrap(err)
+	}
+	if n == 0 {
+		// Either there's no such key, or it has the
+		// reserved alias.
+		var exists bool
+		err = s.db.QueryRowContext(ctx, `SELECT EXISTS(SELECT 1 FROM mockhsm WHERE pub = $1 AND key_type = 'chain_kd')`, pub).Scan(&exists)
+		if err != nil {
+			return errors.Wrap(err, "reading key")
+		}
+		if exists {
+			return errors.Wrap(ErrReservedKeyAlias)
+		}
+		return ErrNoKey
+	}
+	return nil
+}
+
+func (s *pgStorage) list(ctx context.Context, aliases []string, after int64, limit int) ([]*XPub, int64, error) {
+	var (
+		xpubs  []*XPub
+		last   int64
+		params []interface{}
+	)
+	q := `
+		SELECT pub, alias, sort_id FROM mockhsm
+		WHERE key_type = 'chain_kd'
+	`
+
+	if len(aliases) > 0 {
+		params = append(params, pq.StringArray(aliases))
+		q += fmt.Sprintf(" AND alias = ANY($%d)", len(params))
+	}
+
+	if after != 0 {
+		params = append(params, after)
+		q += fmt.Sprintf(" AND sort_id < $%d", len(params))
+	}
+
+	q += fmt.Sprintf(" ORDER BY sort_id DESC LIMIT %d", limit)
+
+	consumeRow := func(b []byte, alias sql.NullString, sortID int64) {
+		xpub := new(XPub)
+		copy(xpub.XPub[:], b)
+		if alias.Valid {
+			xpub.Alias = &alias.String
+		}
+		xpubs = append(xpubs, xpub)
+		last = sortID
+	}
+	params = append(params, consumeRow)
+
+	err := pg.ForQueryRows(ctx, s.db, q, params...)
+	if err != nil {
+		return nil, 0, err
+	}
+	return xpubs, last, nil
+}
+
+func (s *pgStorage) delete(ctx context.Context, keyType string, pub []byte) error {
+	_, err := s.db.ExecContext(ctx, "DELETE FROM mockhsm WHERE pub = $1 AND key_type = $2", pub, keyType)
+	return err
+}
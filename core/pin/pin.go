@@ -34,23 +34,21 @@ func NewStore(db pg.DB) *Store {
 
 func (s *Store) ProcessBlocks(ctx context.Context, c *protocol.Chain, pinName string, cb func(context.Context, *legacy.Block) error) {
 	p := <-s.pin(pinName)
-	height := p.getHeight()
-	for {
+	blocks, err := c.SubscribeBlocks(ctx, p.getHeight()+1)
+	if err != nil {
+		log.Error(ctx, err)
+		return
+	}
+	for block := range blocks {
 		select {
 		case <-ctx.Done(): // leader deposed
 			log.Error(ctx, ctx.Err())
 			return
-		case <-c.BlockWaiter(height + 1):
-			select {
-			case <-ctx.Done():
-				log.Error(ctx, ctx.Err())
-				return
-			case p.sem <- true:
-				go p.processBlock(ctx, c, height+1, cb)
-				height++
-			}
+		case p.sem <- true:
+			go p.processBlock(ctx, block, cb)
 		}
 	}
+	log.Error(ctx, ctx.Err()) // leader deposed
 }
 
 func (s *Store) CreatePin(ctx context.Context, name string, height uint64) error {
@@ -132,6 +130,38 @@ func (s *Store) AllWaiter(height uint64) <-chan struct{} {
 	return ch
 }
 
+// Pause stops the named pins from processing blocks, waiting for
+// the blocks they're already processing to finish. It returns the
+// highest height any of them has processed and a function that
+// resumes them. Pins are paused in the order given, so a pin whose
+// callback waits on another pin must come before it.
+//
+// Pause is for rebuilding a pin's data while it isn't changing.
+// The caller passes resume the height it rebuilt through; the pins
+// skip the blocks up to it.
+func (s *Store) Pause(names ...string) (height uint64, resume func(ctx context.Context, height uint64) error) {
+	var pins []*pin
+	for _, name := range names {
+		p := <-s.pin(name)
+		p.run.Lock()
+		pins = append(pins, p)
+		if h := p.processedHeight(); h > height {
+			height = h
+		}
+	}
+	resume = func(ctx context.Context, height uint64) error {
+		var err error
+		for _, p := range pins {
+			if err == nil {
+				err = p.advance(ctx, height)
+			}
+			p.run.Unlock()
+		}
+		return err
+	}
+	return height, resume
+}
+
 func (s *Store) Listen(ctx context.Context, pinName, dbURL string) {
 	listener, err := pg.NewListener(ctx, dbURL, "pin-"+pinName)
 	if err != nil {
@@ -181,6 +211,8 @@ func (s *Store) Listen(ctx context.Context, pinName, dbURL string) {
 }
 
 type pin struct {
+	run sync.RWMutex // held for reading while processing a block; see Pause
+
 	mu        sync.Mutex
 	cond      sync.Cond
 	height    uint64
@@ -203,15 +235,27 @@ func (p *pin) getHeight() uint64 {
 	return p.height
 }
 
-func (p *pin) processBlock(ctx context.Context, c *protocol.Chain, height uint64, cb func(context.Context, *legacy.Block) error) {
+// processedHeight returns the highest height p has processed,
+// counting blocks processed ahead of its height.
+func (p *pin) processedHeight() uint64 {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if n := len(p.completed); n > 0 && p.completed[n-1] > p.height {
+		return p.completed[n-1]
+	}
+	return p.height
+}
+
+func (p *pin) processBlock(ctx context.Context, block *legacy.Block, cb func(context.Context, *legacy.Block) error) {
 	defer func() { <-p.sem }()
+	p.run.RLock()
+	defer p.run.RUnlock()
+	if block.Height <= p.getHeight() {
+		// Already processed while the pin was paused.
+		return
+	}
 	for {
-		block, err := c.GetBlock(ctx, height)
-		if err != nil {
-			log.Error(ctx, err)
-			continue
-		}
-		err = cb(ctx, block)
+		err := cb(ctx, block)
 		if err != nil {
 			log.Error(ctx, errors.Wrapf(err, "pin %q callback", p.name))
 			continue
@@ -267,6 +311,34 @@ func (p *pin) complete(ctx context.Context, height uint64) error {
 	return nil
 }
 
+// advance records that every block through height has been
+// processed.
+func (p *pin) advance(ctx context.Context, height uint64) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if height <= p.height {
+		return nil
+	}
+	i := sort.Search(len(p.completed), func(i int) bool { return p.completed[i] > height })
+	p.completed = p.completed[i:]
+
+	const q = `UPDATE block_processors SET height=$1 WHERE height<$1 AND name=$2`
+	_, err := p.db.ExecContext(ctx, q, height, p.name)
+	if err != nil {
+		return err
+	}
+	const notifyQ = `SELECT pg_notify($1, $2)`
+	_, err = p.db.ExecContext(ctx, notifyQ, "pin-"+p.name, height)
+	if err != nil {
+		return err
+	}
+
+	p.height = height
+	p.cond.Broadcast()
+	return nil
+}
+
 type uint64s []uint64
 
 func (a uint64s) Len() int           { return len(a) }
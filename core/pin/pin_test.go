@@ -127,3 +127,52 @@ func TestProcessBlocks(t *testing.T) {
 		t.Errorf("processed block heights, got %#v want %#v", blockHeights, want)
 	}
 }
+
+func TestPause(t *testing.T) {
+	db := pgtest.NewTx(t)
+	store := NewStore(db)
+	c := prottest.NewChain(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	err := store.CreatePin(ctx, "example", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	processed := make(chan uint64, 10)
+	go store.ProcessBlocks(ctx, c, "example", func(ctx context.Context, b *legacy.Block) error {
+		processed <- b.Height
+		return nil
+	})
+	<-store.PinWaiter("example", 1)
+	<-processed
+
+	height, resume := store.Pause("example")
+	if height != 1 {
+		t.Errorf("paused at height %d, want 1", height)
+	}
+
+	// Blocks made while the pin is paused aren't processed.
+	prottest.MakeBlock(t, c, nil)
+	prottest.MakeBlock(t, c, nil)
+	select {
+	case h := <-processed:
+		t.Fatalf("processed block %d while paused", h)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	// Resuming after processing block 2 elsewhere
+	// skips it and goes on with block 3.
+	err = resume(ctx, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-store.PinWaiter("example", 3)
+	if h := <-processed; h != 3 {
+		t.Errorf("processed block %d after resuming, want 3", h)
+	}
+	if h := store.Height("example"); h != 3 {
+		t.Errorf("pin height = %d want 3", h)
+	}
+}
@@ -3,11 +3,15 @@ package core
 import (
 	"context"
 	"math"
+	"time"
 
+	"chain/core/leader"
 	"chain/core/query"
 	"chain/core/query/filter"
+	"chain/encoding/json"
 	"chain/errors"
 	"chain/net/http/httpjson"
+	"chain/protocol/bc"
 )
 
 // listAccounts is an http handler for listing accounts matching
@@ -49,7 +53,7 @@ func (a *API) listAssets(ctx context.Context, in requestQuery) (page, error) {
 	after := in.After
 
 	// Use the query engine for querying asset tags.
-	assets, after, err := a.indexer.Assets(ctx, in.Filter, in.FilterParams, after, limit)
+	assets, after, err := a.indexer.Assets(ctx, in.Filter, in.FilterParams, in.Text, after, limit)
 	if err != nil {
 		return page{}, errors.Wrap(err, "running asset query")
 	}
@@ -81,15 +85,21 @@ func (a *API) listBalances(ctx context.Context, in requestQuery) (result page, e
 		sumBy = append(sumBy, f)
 	}
 
-	timestampMS := in.TimestampMS
-	if timestampMS == 0 {
-		timestampMS = math.MaxInt64
-	} else if timestampMS > math.MaxInt64 {
+	// With no timestamp, report balances as of the latest indexed block.
+	// Otherwise, report them as of the block whose timestamp is the
+	// greatest value not exceeding the requested time.
+	height := uint64(math.MaxInt64)
+	if in.TimestampMS > math.MaxInt64 {
 		return result, errors.WithDetail(httpjson.ErrBadRequest, "timestamp is too large")
+	} else if in.TimestampMS != 0 {
+		height, err = a.indexer.BlockHeightAt(ctx, in.TimestampMS)
+		if err != nil {
+			return result, err
+		}
 	}
 
 	// TODO(jackson): paginate this endpoint.
-	balances, err := a.indexer.Balances(ctx, in.Filter, in.FilterParams, sumBy, timestampMS)
+	balances, err := a.indexer.Balances(ctx, in.Filter, in.FilterParams, sumBy, height)
 	if err != nil {
 		return result, err
 	}
@@ -138,7 +148,7 @@ func (a *API) listTransactions(ctx context.Context, in requestQuery) (result pag
 		}
 	}
 
-	txns, nextAfter, err := a.indexer.Transactions(ctx, in.Filter, in.FilterParams, after, limit, in.AscLongPoll)
+	txns, nextAfter, err := a.indexer.Transactions(ctx, in.Filter, in.FilterParams, in.Text, after, limit, in.AscLongPoll)
 	if err != nil {
 		return result, errors.Wrap(err, "running tx query")
 	}
@@ -152,6 +162,27 @@ func (a *API) listTransactions(ctx context.Context, in requestQuery) (result pag
 	}, nil
 }
 
+// aggregateTransactions is an http handler for counting and
+// summing transactions matching a filter over fixed time intervals.
+//
+// POST /aggregate-transactions
+func (a *API) aggregateTransactions(ctx context.Context, in struct {
+	Filter       string        `json:"filter,omitempty"`
+	FilterParams []interface{} `json:"filter_params,omitempty"`
+	SumField     string        `json:"sum_field,omitempty"`
+	StartTimeMS  uint64        `json:"start_time"`
+	EndTimeMS    uint64        `json:"end_time,omitempty"`
+	Interval     json.Duration `json:"interval"`
+}) (result []query.TxBucket, err error) {
+	endTimeMS := in.EndTimeMS
+	if endTimeMS == 0 {
+		endTimeMS = bc.Millis(time.Now())
+	} else if endTimeMS > math.MaxInt64 {
+		return nil, errors.WithDetail(httpjson.ErrBadRequest, "end timestamp is too large")
+	}
+	return a.indexer.AggregateTransactions(ctx, in.Filter, in.FilterParams, in.SumField, in.StartTimeMS, endTimeMS, in.Interval.Duration)
+}
+
 // listTxFeeds is an http handler for listing txfeeds. It does not take a filter.
 //
 // POST /list-transaction-feeds
@@ -211,3 +242,39 @@ func (a *API) listUnspentOutputs(ctx context.Context, in requestQuery) (result p
 		Next:     outQuery,
 	}, nil
 }
+
+// POST /reindex
+//
+// reindex replays stored blocks through the transaction indexer.
+// The reindex runs in the background on the leader; reindex returns
+// its starting progress, and /reindex-progress reports how far it
+// has got. An interrupted reindex of the same range resumes where
+// it stopped.
+func (a *API) reindex(ctx context.Context, in struct {
+	FromHeight uint64 `json:"from_height"`
+	ToHeight   uint64 `json:"to_height"`
+}) (*query.BackfillProgress, error) {
+	if a.leader.State() == leader.Following {
+		var resp *query.BackfillProgress
+		err := a.forwardToLeader(ctx, "/reindex", in, &resp)
+		return resp, err
+	}
+	if !a.indexTxs {
+		return nil, errors.WithDetail(httpjson.ErrBadRequest, "transaction indexing is disabled")
+	}
+	leadCtx := a.leaderContext()
+	if leadCtx == nil {
+		return nil, errors.Wrap(leader.ErrNoLeader)
+	}
+	return a.indexer.StartBackfill(leadCtx, in.FromHeight, in.ToHeight)
+}
+
+// POST /reindex-progress
+func (a *API) reindexProgress(ctx context.Context) (*query.BackfillProgress, error) {
+	if a.leader.State() == leader.Following {
+		var resp *query.BackfillProgress
+		err := a.forwardToLeader(ctx, "/reindex-progress", nil, &resp)
+		return resp, err
+	}
+	return a.indexer.BackfillProgress(ctx)
+}
@@ -21,19 +21,28 @@ func (ind *Indexer) SaveAnnotatedAccount(ctx context.Context, account *Annotated
 	const q = `
 		INSERT INTO annotated_accounts (id, alias, keys, quorum, tags)
 		VALUES($1, $2, $3::jsonb, $4, $5::jsonb)
-		ON CONFLICT (id) DO UPDATE SET tags = $5::jsonb
+		ON CONFLICT (id) DO UPDATE SET alias = $2, tags = $5::jsonb
 	`
 	_, err = ind.db.ExecContext(ctx, q, account.ID, account.Alias, keysJSON,
 		account.Quorum, string(*account.Tags))
 	return errors.Wrap(err, "saving annotated account")
 }
 
+// DeleteAnnotatedAccount removes an account from the query indexes.
+// Transactions annotated with the account keep their annotations.
+func (ind *Indexer) DeleteAnnotatedAccount(ctx context.Context, id string) error {
+	const q = `DELETE FROM annotated_accounts WHERE id = $1`
+	_, err := ind.db.ExecContext(ctx, q, id)
+	return errors.Wrap(err, "deleting annotated account")
+}
+
 // Accounts queries the blockchain for accounts matching the query `q`.
 func (ind *Indexer) Accounts(ctx context.Context, filt string, vals []interface{}, after string, limit int) ([]*AnnotatedAccount, string, error) {
 	p, err := filter.Parse(filt, accountsTable, vals)
 	if err != nil {
 		return nil, "", err
 	}
+	warnUnindexed(ctx, p)
 	if len(vals) != p.Parameters {
 		return nil, "", ErrParameterCountMismatch
 	}
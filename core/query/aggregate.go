@@ -0,0 +1,166 @@
+package query
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+
+	"chain/core/query/filter"
+	"chain/errors"
+)
+
+// MaxAggregateBuckets is the largest number of buckets
+// AggregateTransactions will return.
+const MaxAggregateBuckets = 10000
+
+// ErrBadAggregate is returned by AggregateTransactions when
+// the requested time range, interval, or sum field is invalid.
+var ErrBadAggregate = errors.New("invalid transaction aggregation")
+
+// TxBucket summarizes the transactions in one time interval.
+type TxBucket struct {
+	BucketStart uint64      `json:"bucket_start"`
+	Count       uint64      `json:"count"`
+	Sum         json.Number `json:"sum"`
+}
+
+// AggregateTransactions divides the time range [startMS, endMS)
+// into buckets of the given interval and returns the number of
+// transactions matching filt in each bucket. If sumField is not
+// empty, it also returns the total of that field over those
+// transactions. The final bucket may be shorter than interval.
+//
+// The sum field may be an integer attribute of the transaction,
+// a path into its reference data, or the amount of its inputs
+// or outputs (inputs.amount or outputs.amount).
+//
+// Every bucket in the range is included, even if it contains
+// no transactions.
+func (ind *Indexer) AggregateTransactions(ctx context.Context, filt string, vals []interface{}, sumField string, startMS, endMS uint64, interval time.Duration) ([]TxBucket, error) {
+	intervalMS := uint64(interval / time.Millisecond)
+	if intervalMS == 0 {
+		return nil, errors.WithDetail(ErrBadAggregate, "interval must be at least 1ms")
+	}
+	if endMS <= startMS {
+		return nil, errors.WithDetail(ErrBadAggregate, "end time must be after start time")
+	}
+	n := (endMS - startMS + intervalMS - 1) / intervalMS
+	if n > MaxAggregateBuckets {
+		return nil, errors.WithDetailf(ErrBadAggregate, "range contains %d intervals; the maximum is %d", n, MaxAggregateBuckets)
+	}
+
+	p, err := filter.Parse(filt, transactionsTable, vals)
+	if err != nil {
+		return nil, err
+	}
+	warnUnindexed(ctx, p)
+	if len(vals) != p.Parameters {
+		return nil, ErrParameterCountMismatch
+	}
+	expr, err := filter.AsSQL(p, transactionsTable, vals)
+	if err != nil {
+		return nil, errors.Wrap(err, "converting to SQL")
+	}
+	sumSQL, err := sumFieldAsSQL(sumField)
+	if err != nil {
+		return nil, err
+	}
+
+	queryStr, queryArgs := constructAggregateQuery(expr, sumSQL, vals, startMS, endMS, intervalMS, n)
+	rows, err := ind.db.QueryContext(ctx, queryStr, queryArgs...)
+	if err != nil {
+		return nil, errors.Wrap(err, "executing aggregate query")
+	}
+	defer rows.Close()
+
+	buckets := make([]TxBucket, 0, n)
+	for rows.Next() {
+		var (
+			b   TxBucket
+			i   uint64
+			sum string
+		)
+		err := rows.Scan(&i, &b.Count, &sum)
+		if err != nil {
+			return nil, errors.Wrap(err, "scanning aggregate row")
+		}
+		b.BucketStart = startMS + i*intervalMS
+		b.Sum = json.Number(sum)
+		buckets = append(buckets, b)
+	}
+	return buckets, errors.Wrap(rows.Err())
+}
+
+func constructAggregateQuery(expr, sumSQL string, vals []interface{}, startMS, endMS, intervalMS, n uint64) (string, []interface{}) {
+	vals = append(vals, startMS, endMS, intervalMS, n)
+	start, end, interval, count := len(vals)-3, len(vals)-2, len(vals)-1, len(vals)
+
+	var buf bytes.Buffer
+	buf.WriteString("SELECT s.i, COALESCE(a.count, 0), COALESCE(a.sum, 0)::text ")
+	buf.WriteString(fmt.Sprintf("FROM generate_series(0, $%d::bigint - 1) AS s(i) LEFT JOIN (", count))
+
+	// Timestamps are stored with millisecond precision;
+	// round away any floating point error before dividing.
+	buf.WriteString(fmt.Sprintf("SELECT floor((round(extract(epoch FROM txs.timestamp) * 1000) - $%d::bigint) / $%d::bigint)::bigint AS i, ", start, interval))
+	buf.WriteString("COUNT(*) AS count, ")
+	if sumSQL == "" {
+		buf.WriteString("0 AS sum ")
+	} else {
+		buf.WriteString("SUM(" + sumSQL + ") AS sum ")
+	}
+	buf.WriteString("FROM annotated_txs AS txs WHERE ")
+	if len(expr) > 0 {
+		buf.WriteString("(")
+		buf.WriteString(expr)
+		buf.WriteString(") AND ")
+	}
+	buf.WriteString(fmt.Sprintf("txs.timestamp >= 'epoch'::timestamptz + $%d::bigint * interval '1 millisecond' ", start))
+	buf.WriteString(fmt.Sprintf("AND txs.timestamp < 'epoch'::timestamptz + $%d::bigint * interval '1 millisecond' ", end))
+	buf.WriteString("GROUP BY 1) AS a ON a.i = s.i ORDER BY s.i")
+	return buf.String(), vals
+}
+
+// sumFieldAsSQL returns the SQL expression to sum
+// over transactions for field, or "" if field is empty.
+func sumFieldAsSQL(field string) (string, error) {
+	if field == "" {
+		return "", nil
+	}
+	f, err := filter.ParseField(field)
+	if err != nil {
+		return "", err
+	}
+	path := strings.SplitN(f.String(), ".", 2)
+
+	if fk, ok := transactionsTable.ForeignKeys[path[0]]; ok && len(path) == 2 {
+		col, ok := fk.Table.Columns[path[1]]
+		if !ok || col.Type != filter.Integer {
+			return "", errors.WithDetailf(ErrBadAggregate, "cannot sum non-integer attribute: %s", field)
+		}
+		alias := fk.Table.Alias
+		return fmt.Sprintf("(SELECT SUM(%s.%s) FROM %s AS %s WHERE %s.%s = txs.%s)",
+			alias, pq.QuoteIdentifier(col.Name), pq.QuoteIdentifier(fk.Table.Name), alias,
+			alias, pq.QuoteIdentifier(fk.ForeignColumn), pq.QuoteIdentifier(fk.LocalColumn)), nil
+	}
+
+	col, ok := transactionsTable.Columns[path[0]]
+	switch {
+	case !ok:
+		return "", errors.WithDetailf(ErrBadAggregate, "invalid attribute: %s", path[0])
+	case col.Type == filter.Integer && len(path) == 1:
+		return "txs." + pq.QuoteIdentifier(col.Name), nil
+	case col.SQLType == filter.SQLJSONB && len(path) == 2:
+		s, err := filter.FieldAsSQL(transactionsTable, f)
+		if err != nil {
+			return "", err
+		}
+		// Values that aren't numbers are left out of the sum.
+		return fmt.Sprintf(`CASE WHEN (%s) ~ '^-?[0-9]+(\.[0-9]+)?([eE][-+]?[0-9]+)?$' THEN (%s)::numeric END`, s, s), nil
+	}
+	return "", errors.WithDetailf(ErrBadAggregate, "cannot sum non-numeric attribute: %s", field)
+}
@@ -0,0 +1,134 @@
+package query
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"chain/database/pg/pgtest"
+	"chain/errors"
+	"chain/protocol/bc"
+	"chain/testutil"
+)
+
+func TestAggregateTransactions(t *testing.T) {
+	ctx := context.Background()
+	db := pgtest.NewTx(t)
+	ind := NewIndexer(db, nil, nil)
+
+	base := time.Date(2017, 7, 1, 0, 0, 0, 0, time.UTC)
+	fixtures := []struct {
+		at      time.Duration
+		refData string
+	}{
+		{1 * time.Hour, `{"kind": "a", "amount": 5}`},
+		{1*time.Hour + 59*time.Minute, `{"kind": "b", "amount": 7}`},
+		{3*time.Hour + 30*time.Minute, `{"kind": "a", "amount": 10}`},
+		{26 * time.Hour, `{"kind": "a", "amount": "lots"}`},
+		{72 * time.Hour, `{"kind": "a", "amount": 1000}`}, // after the range
+	}
+	for i, f := range fixtures {
+		const q = `
+			INSERT INTO annotated_txs (block_height, tx_pos, tx_hash, data,
+				timestamp, block_id, local, reference_data, block_tx_count)
+			VALUES ($1, 0, $2, '{}', $3, '\x00', true, $4, 1)
+		`
+		_, err := db.ExecContext(ctx, q, i+1, []byte{byte(i)}, base.Add(f.at), f.refData)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ms := func(d time.Duration) uint64 { return bc.Millis(base.Add(d)) }
+	cases := []struct {
+		filter   string
+		vals     []interface{}
+		sumField string
+		end      time.Duration
+		interval time.Duration
+		want     []TxBucket
+	}{{
+		sumField: "reference_data.amount",
+		end:      72 * time.Hour,
+		interval: 24 * time.Hour,
+		want: []TxBucket{
+			{BucketStart: ms(0), Count: 3, Sum: "22"},
+			{BucketStart: ms(24 * time.Hour), Count: 1, Sum: "0"},
+			{BucketStart: ms(48 * time.Hour), Count: 0, Sum: "0"},
+		},
+	}, {
+		sumField: "reference_data.amount",
+		end:      6 * time.Hour,
+		interval: time.Hour,
+		want: []TxBucket{
+			{BucketStart: ms(0), Count: 0, Sum: "0"},
+			{BucketStart: ms(1 * time.Hour), Count: 2, Sum: "12"},
+			{BucketStart: ms(2 * time.Hour), Count: 0, Sum: "0"},
+			{BucketStart: ms(3 * time.Hour), Count: 1, Sum: "10"},
+			{BucketStart: ms(4 * time.Hour), Count: 0, Sum: "0"},
+			{BucketStart: ms(5 * time.Hour), Count: 0, Sum: "0"},
+		},
+	}, {
+		filter:   "reference_data.kind = $1",
+		vals:     []interface{}{"a"},
+		end:      4 * time.Hour,
+		interval: 3 * time.Hour,
+		want: []TxBucket{
+			{BucketStart: ms(0), Count: 1, Sum: "0"},
+			{BucketStart: ms(3 * time.Hour), Count: 1, Sum: "0"},
+		},
+	}}
+
+	for i, c := range cases {
+		got, err := ind.AggregateTransactions(ctx, c.filter, c.vals, c.sumField, ms(0), ms(c.end), c.interval)
+		if err != nil {
+			t.Errorf("case %d: %s", i, err)
+			continue
+		}
+		if !testutil.DeepEqual(got, c.want) {
+			t.Errorf("case %d: got %+v, want %+v", i, got, c.want)
+		}
+	}
+}
+
+func TestAggregateTransactionsValidation(t *testing.T) {
+	ctx := context.Background()
+	ind := NewIndexer(pgtest.NewTx(t), nil, nil)
+
+	cases := []struct {
+		sumField   string
+		start, end uint64
+		interval   time.Duration
+	}{
+		{start: 0, end: 1000, interval: 0},
+		{start: 1000, end: 1000, interval: time.Millisecond},
+		{start: 0, end: MaxAggregateBuckets + 1, interval: time.Millisecond},
+		{sumField: "block_id", start: 0, end: 1000, interval: time.Second},
+		{sumField: "outputs.asset_id", start: 0, end: 1000, interval: time.Second},
+	}
+	for _, c := range cases {
+		_, err := ind.AggregateTransactions(ctx, "", nil, c.sumField, c.start, c.end, c.interval)
+		if errors.Root(err) != ErrBadAggregate {
+			t.Errorf("AggregateTransactions(%q, %d, %d, %s) error = %v, want %v", c.sumField, c.start, c.end, c.interval, err, ErrBadAggregate)
+		}
+	}
+}
+
+func TestSumFieldAsSQL(t *testing.T) {
+	cases := map[string]string{
+		"":                      "",
+		"position":              `txs."tx_pos"`,
+		"outputs.amount":        `(SELECT SUM(out."amount") FROM "annotated_outputs" AS out WHERE out."tx_hash" = txs."tx_hash")`,
+		"reference_data.amount": `CASE WHEN (txs."reference_data"->>'amount') ~ '^-?[0-9]+(\.[0-9]+)?([eE][-+]?[0-9]+)?$' THEN (txs."reference_data"->>'amount')::numeric END`,
+	}
+	for field, want := range cases {
+		got, err := sumFieldAsSQL(field)
+		if err != nil {
+			t.Errorf("sumFieldAsSQL(%q) error: %s", field, err)
+			continue
+		}
+		if got != want {
+			t.Errorf("sumFieldAsSQL(%q) = %s want %s", field, got, want)
+		}
+	}
+}
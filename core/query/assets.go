@@ -20,21 +20,26 @@ func (ind *Indexer) SaveAnnotatedAsset(ctx context.Context, asset *AnnotatedAsse
 
 	const q = `
 		INSERT INTO annotated_assets
-			(id, sort_id, alias, issuance_program, keys, quorum, definition, tags, local)
-		VALUES($1, $2, $3, $4, $5, $6, $7::jsonb, $8::jsonb, $9)
-		ON CONFLICT (id) DO UPDATE SET sort_id = $2, tags = $8::jsonb
+			(id, sort_id, alias, issuance_program, keys, quorum, definition, tags, local, search)
+		VALUES($1, $2, $3, $4, $5, $6, $7::jsonb, $8::jsonb, $9, to_tsvector('simple', $10::text))
+		ON CONFLICT (id) DO UPDATE SET sort_id = $2, tags = $8::jsonb,
+			search = COALESCE(EXCLUDED.search, annotated_assets.search)
 	`
 	_, err = ind.db.ExecContext(ctx, q, asset.ID, sortID, asset.Alias, []byte(asset.IssuanceProgram),
-		keysJSON, asset.Quorum, string(*asset.Definition), string(*asset.Tags), bool(asset.IsLocal))
+		keysJSON, asset.Quorum, string(*asset.Definition), string(*asset.Tags), bool(asset.IsLocal),
+		ind.searchText(asset.Definition))
 	return errors.Wrap(err, "saving annotated asset")
 }
 
 // Assets queries the blockchain for annotated assets matching the query.
-func (ind *Indexer) Assets(ctx context.Context, filt string, vals []interface{}, after string, limit int) ([]*AnnotatedAsset, string, error) {
+// If text is not empty, only assets whose definitions contain every
+// word in text are included.
+func (ind *Indexer) Assets(ctx context.Context, filt string, vals []interface{}, text string, after string, limit int) ([]*AnnotatedAsset, string, error) {
 	p, err := filter.Parse(filt, assetsTable, vals)
 	if err != nil {
 		return nil, "", err
 	}
+	warnUnindexed(ctx, p)
 	if len(vals) != p.Parameters {
 		return nil, "", ErrParameterCountMismatch
 	}
@@ -42,6 +47,10 @@ func (ind *Indexer) Assets(ctx context.Context, filt string, vals []interface{},
 	if err != nil {
 		return nil, "", errors.Wrap(err, "converting to SQL")
 	}
+	expr, vals, err = ind.textSearchExpr(expr, assetsTable.Alias, vals, text)
+	if err != nil {
+		return nil, "", err
+	}
 
 	queryStr, queryArgs := constructAssetsQuery(expr, vals, after, limit)
 	rows, err := ind.db.QueryContext(ctx, queryStr, queryArgs...)
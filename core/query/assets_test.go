@@ -2,6 +2,7 @@ package query
 
 import (
 	"context"
+	"fmt"
 	"testing"
 
 	"github.com/davecgh/go-spew/spew"
@@ -105,7 +106,7 @@ func TestQueryAssets(t *testing.T) {
 		},
 	}
 	for _, tc := range testCases {
-		accs, _, err := indexer.Assets(ctx, tc.filt, tc.vals, "", 100)
+		accs, _, err := indexer.Assets(ctx, tc.filt, tc.vals, "", "", 100)
 		if !testutil.DeepEqual(err, tc.wantErr) {
 			t.Errorf("%q got error %#v, want error %#v", tc.filt, err, tc.wantErr)
 		}
@@ -114,3 +115,58 @@ func TestQueryAssets(t *testing.T) {
 		}
 	}
 }
+
+func TestQueryAssetsPagination(t *testing.T) {
+	const limit = 2
+	for _, n := range []int{0, 1, limit, limit + 1} {
+		ctx := context.Background()
+		indexer := NewIndexer(pgtest.NewTx(t), prottest.NewChain(t), nil)
+
+		var want []bc.AssetID
+		for i := 0; i < n; i++ {
+			asset := &AnnotatedAsset{
+				ID:         bc.NewAssetID([32]byte{byte(i)}),
+				Keys:       []*AssetKey{},
+				Definition: raw(`{}`),
+				Tags:       raw(`{}`),
+			}
+			err := indexer.SaveAnnotatedAsset(ctx, asset, fmt.Sprintf("asset%d", i))
+			if err != nil {
+				testutil.FatalErr(t, err)
+			}
+			// Assets are listed in descending sort ID order.
+			want = append([]bc.AssetID{asset.ID}, want...)
+		}
+
+		// Page the way the list-assets handler does, stopping at
+		// the first short page.
+		var (
+			got   []bc.AssetID
+			after string
+			pages int
+		)
+		for {
+			assets, last, err := indexer.Assets(ctx, "", nil, "", after, limit)
+			if err != nil {
+				testutil.FatalErr(t, err)
+			}
+			pages++
+			for _, a := range assets {
+				got = append(got, a.ID)
+			}
+			if len(assets) < limit {
+				break
+			}
+			if pages > n {
+				t.Fatalf("%d assets: pagination didn't terminate after %d pages", n, pages)
+			}
+			after = last
+		}
+		if wantPages := n/limit + 1; pages != wantPages {
+			t.Errorf("%d assets: got %d pages, want %d", n, pages, wantPages)
+		}
+		if !testutil.DeepEqual(got, want) {
+			t.Errorf("%d assets: got %x, want %x", n, got, want)
+		}
+	}
+}
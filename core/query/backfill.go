@@ -0,0 +1,221 @@
+package query
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"chain/errors"
+	"chain/log"
+)
+
+var (
+	// ErrBadBackfillRange is returned by Backfill when the
+	// requested range of blocks is empty.
+	ErrBadBackfillRange = errors.New("invalid backfill range")
+
+	// ErrBackfillRunning is returned by StartBackfill
+	// when a backfill it started is still running.
+	ErrBackfillRunning = errors.New("a backfill is already running")
+)
+
+// BackfillProgress describes the state of the most recent backfill.
+type BackfillProgress struct {
+	FromHeight uint64    `json:"from_height"`
+	ToHeight   uint64    `json:"to_height"`
+	NextHeight uint64    `json:"next_height"`
+	UpdatedAt  time.Time `json:"updated_at"`
+
+	// Error is the error that stopped the most recent
+	// backfill started by StartBackfill, if any.
+	Error string `json:"error,omitempty"`
+}
+
+// Done reports whether every block in the range has been indexed.
+func (p *BackfillProgress) Done() bool {
+	return p.NextHeight > p.ToHeight
+}
+
+// Backfill replays the stored blocks from fromHeight through
+// toHeight, inclusive, through the annotation pipeline and saves
+// the results. It is meant for recovering transactions that were
+// never indexed, for instance because indexing was enabled after
+// the core had already synced.
+//
+// A fromHeight of 0 means the initial block, and a toHeight of 0
+// means the current height of the chain. If the previous backfill
+// covered the same range and did not finish, Backfill resumes where
+// it left off.
+//
+// Backfill may run while the indexer processes new blocks; blocks
+// indexed by both are only written once.
+func (ind *Indexer) Backfill(ctx context.Context, fromHeight, toHeight uint64) error {
+	ind.backfillMu.Lock()
+	defer ind.backfillMu.Unlock()
+
+	fromHeight, toHeight, next, err := ind.backfillRange(ctx, fromHeight, toHeight)
+	if err != nil {
+		return err
+	}
+	return ind.backfill(ctx, fromHeight, toHeight, next)
+}
+
+// StartBackfill is like Backfill, but it returns once it has
+// checked the range and saved the backfill's starting progress,
+// which it returns, and does the rest in the background until
+// it's done or ctx is canceled. It returns ErrBackfillRunning if
+// the last backfill it started hasn't finished. An error that
+// stops the backfill is logged and reported by BackfillProgress.
+func (ind *Indexer) StartBackfill(ctx context.Context, fromHeight, toHeight uint64) (*BackfillProgress, error) {
+	ind.bgMu.Lock()
+	if ind.bgRunning {
+		ind.bgMu.Unlock()
+		return nil, errors.Wrap(ErrBackfillRunning)
+	}
+	ind.bgRunning = true
+	ind.bgErr = nil
+	ind.bgMu.Unlock()
+
+	ind.backfillMu.Lock()
+	var p *BackfillProgress
+	fromHeight, toHeight, next, err := ind.backfillRange(ctx, fromHeight, toHeight)
+	if err == nil {
+		err = ind.saveBackfillProgress(ctx, fromHeight, toHeight, next)
+	}
+	if err == nil {
+		p, err = ind.BackfillProgress(ctx)
+	}
+	if err != nil {
+		ind.backfillMu.Unlock()
+		ind.bgMu.Lock()
+		ind.bgRunning = false
+		ind.bgMu.Unlock()
+		return nil, err
+	}
+
+	go func() {
+		err := ind.backfill(ctx, fromHeight, toHeight, next)
+		ind.backfillMu.Unlock()
+		if err != nil {
+			log.Error(ctx, err, "at", "backfilling query index")
+		}
+		ind.bgMu.Lock()
+		ind.bgRunning = false
+		ind.bgErr = err
+		ind.bgMu.Unlock()
+	}()
+	return p, nil
+}
+
+// backfillRange returns the range of blocks to backfill for the
+// given heights and the next height to index, which is past the
+// start if an unfinished backfill of the same range is resumed.
+// ind.backfillMu must be held.
+func (ind *Indexer) backfillRange(ctx context.Context, fromHeight, toHeight uint64) (from, to, next uint64, err error) {
+	if fromHeight == 0 {
+		fromHeight = 1
+	}
+	if h := ind.c.Height(); toHeight == 0 || toHeight > h {
+		toHeight = h
+	}
+	if fromHeight > toHeight {
+		return 0, 0, 0, errors.WithDetailf(ErrBadBackfillRange, "from height %d is above to height %d", fromHeight, toHeight)
+	}
+
+	next = fromHeight
+	prev, err := ind.BackfillProgress(ctx)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	if prev != nil && prev.FromHeight == fromHeight && prev.ToHeight == toHeight && !prev.Done() {
+		next = prev.NextHeight
+	}
+	return fromHeight, toHeight, next, nil
+}
+
+// backfill indexes the blocks from next through toHeight and
+// finishes the backfill of fromHeight through toHeight.
+// ind.backfillMu must be held.
+func (ind *Indexer) backfill(ctx context.Context, fromHeight, toHeight, next uint64) error {
+	for h := next; h <= toHeight; h++ {
+		err := ind.saveBackfillProgress(ctx, fromHeight, toHeight, h)
+		if err != nil {
+			return err
+		}
+		b, err := ind.c.GetBlock(ctx, h)
+		if err != nil {
+			return errors.Wrapf(err, "getting block %d", h)
+		}
+		err = ind.indexBlock(ctx, b)
+		if err != nil {
+			return errors.Wrapf(err, "indexing block %d", h)
+		}
+	}
+
+	// Outputs in the range may have been spent in blocks that
+	// were indexed before this backfill reached them. Those
+	// spends found nothing to update, so apply them now.
+	const q = `
+		UPDATE annotated_outputs AS o
+		SET timespan = INT8RANGE(LOWER(o.timespan), qb.timestamp), spent_block_height = t.block_height
+		FROM annotated_inputs AS i
+		JOIN annotated_txs AS t ON t.tx_hash = i.tx_hash
+		JOIN query_blocks AS qb ON qb.height = t.block_height
+		WHERE i.spent_output_id = o.output_id AND o.spent_block_height IS NULL
+			AND o.block_height BETWEEN $1 AND $2
+	`
+	_, err := ind.db.ExecContext(ctx, q, fromHeight, toHeight)
+	if err != nil {
+		return errors.Wrap(err, "updating spent annotated outputs")
+	}
+	return ind.saveBackfillProgress(ctx, fromHeight, toHeight, toHeight+1)
+}
+
+// BackfillProgress returns the progress of the most recent
+// backfill, or nil if there has never been one.
+func (ind *Indexer) BackfillProgress(ctx context.Context) (*BackfillProgress, error) {
+	const q = `
+		SELECT from_height, to_height, next_height, updated_at FROM query_backfill
+	`
+	var p BackfillProgress
+	err := ind.db.QueryRowContext(ctx, q).Scan(&p.FromHeight, &p.ToHeight, &p.NextHeight, &p.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "querying backfill progress")
+	}
+	ind.bgMu.Lock()
+	if ind.bgErr != nil {
+		p.Error = ind.bgErr.Error()
+	}
+	ind.bgMu.Unlock()
+	return &p, nil
+}
+
+func (ind *Indexer) saveBackfillProgress(ctx context.Context, fromHeight, toHeight, nextHeight uint64) error {
+	const q = `
+		INSERT INTO query_backfill (from_height, to_height, next_height) VALUES ($1, $2, $3)
+		ON CONFLICT (singleton) DO UPDATE
+		SET from_height = $1, to_height = $2, next_height = $3, updated_at = now()
+	`
+	_, err := ind.db.ExecContext(ctx, q, fromHeight, toHeight, nextHeight)
+	return errors.Wrap(err, "saving backfill progress")
+}
+
+// Reset deletes every block and transaction the indexer has
+// saved, along with the progress of any backfill, so that a
+// backfill from the initial block can rebuild the index from
+// scratch. It is meant for recovering from corrupt index tables.
+// The indexer's pin must be paused while the index is rebuilt; see
+// pin.Store.Pause.
+func (ind *Indexer) Reset(ctx context.Context) error {
+	ind.backfillMu.Lock()
+	defer ind.backfillMu.Unlock()
+
+	const q = `
+		TRUNCATE query_blocks, annotated_txs, annotated_inputs, annotated_outputs, query_backfill
+	`
+	_, err := ind.db.ExecContext(ctx, q)
+	return errors.Wrap(err, "truncating query index")
+}
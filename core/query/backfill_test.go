@@ -0,0 +1,174 @@
+package query_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"chain/core/account"
+	"chain/core/asset"
+	"chain/core/coretest"
+	"chain/core/generator"
+	"chain/core/pin"
+	"chain/core/query"
+	"chain/core/txbuilder"
+	"chain/database/pg"
+	"chain/database/pg/pgtest"
+	"chain/errors"
+	"chain/protocol/bc"
+	"chain/protocol/prottest"
+)
+
+var annotationTables = []struct {
+	name, order string
+}{
+	{"query_blocks", "height"},
+	{"annotated_txs", "block_height, tx_pos"},
+	{"annotated_inputs", "tx_hash, index"},
+	{"annotated_outputs", "output_id"},
+}
+
+func TestBackfill(t *testing.T) {
+	_, db := pgtest.NewDB(t, pgtest.SchemaPath)
+	ctx := context.Background()
+	c := prottest.NewChain(t)
+	pinStore := pin.NewStore(db)
+	coretest.CreatePins(ctx, t, pinStore)
+	indexer := query.NewIndexer(db, c, pinStore)
+	accounts := account.NewManager(db, c, pinStore)
+	assets := asset.NewRegistry(db, c, pinStore)
+	indexer.RegisterAnnotator(accounts.AnnotateTxs)
+	indexer.RegisterAnnotator(assets.AnnotateTxs)
+	go assets.ProcessBlocks(ctx)
+	go accounts.ProcessBlocks(ctx)
+	go indexer.ProcessBlocks(ctx)
+
+	acct1 := coretest.CreateAccount(ctx, t, accounts, "", nil)
+	acct2 := coretest.CreateAccount(ctx, t, accounts, "", nil)
+	asset1 := coretest.CreateAsset(ctx, t, assets, nil, "", nil)
+	g := generator.New(c, nil, db)
+
+	// Block 2 issues to acct1, and block 3 spends
+	// some of that issuance.
+	coretest.IssueAssets(ctx, t, c, g, assets, accounts, asset1, 100, acct1)
+	<-pinStore.AllWaiter(prottest.MakeBlock(t, c, g.PendingTxs()).Height)
+	coretest.Transfer(ctx, t, c, g, []txbuilder.Action{
+		accounts.NewSpendAction(bc.AssetAmount{AssetId: &asset1, Amount: 30}, acct1, nil, nil),
+		accounts.NewControlAction(bc.AssetAmount{AssetId: &asset1, Amount: 30}, acct2, nil),
+	})
+	transferBlock := prottest.MakeBlock(t, c, g.PendingTxs())
+	<-pinStore.AllWaiter(transferBlock.Height)
+
+	want := dumpAnnotations(ctx, t, db)
+	_, err := db.ExecContext(ctx, `
+		TRUNCATE query_blocks, annotated_txs, annotated_inputs, annotated_outputs
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Index the spending block ahead of the backfill, as the
+	// indexer would if it were processing new blocks.
+	err = indexer.IndexTransactions(ctx, transferBlock)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Pretend that an earlier backfill of the same range was
+	// interrupted after block 1.
+	_, err = db.ExecContext(ctx, `
+		INSERT INTO query_backfill (from_height, to_height, next_height) VALUES (1, $1, 2)
+	`, c.Height())
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = indexer.Backfill(ctx, 1, c.Height())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var n int
+	err = db.QueryRowContext(ctx, `SELECT count(*) FROM query_blocks WHERE height = 1`).Scan(&n)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 0 {
+		t.Error("backfill did not resume from saved progress")
+	}
+	progress, err := indexer.BackfillProgress(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !progress.Done() || progress.NextHeight != c.Height()+1 {
+		t.Errorf("progress = %+v, want done at next height %d", progress, c.Height()+1)
+	}
+
+	// A finished backfill starts over, and writes
+	// nothing twice.
+	err = indexer.Backfill(ctx, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := dumpAnnotations(ctx, t, db)
+	for i, tbl := range annotationTables {
+		if got[i] != want[i] {
+			t.Errorf("%s after backfill:\n%s\nwant:\n%s", tbl.name, got[i], want[i])
+		}
+	}
+}
+
+func dumpAnnotations(ctx context.Context, t testing.TB, db pg.DB) []string {
+	var dump []string
+	for _, tbl := range annotationTables {
+		var s string
+		q := `SELECT COALESCE(json_agg(t ORDER BY ` + tbl.order + `), '[]')::text FROM ` + tbl.name + ` t`
+		err := db.QueryRowContext(ctx, q).Scan(&s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		dump = append(dump, s)
+	}
+	return dump
+}
+
+func TestStartBackfill(t *testing.T) {
+	_, db := pgtest.NewDB(t, pgtest.SchemaPath)
+	ctx := context.Background()
+	c := prottest.NewChain(t)
+	pinStore := pin.NewStore(db)
+	coretest.CreatePins(ctx, t, pinStore)
+	indexer := query.NewIndexer(db, c, pinStore)
+	prottest.MakeBlock(t, c, nil)
+	prottest.MakeBlock(t, c, nil)
+
+	_, err := indexer.StartBackfill(ctx, c.Height()+1, 0)
+	if errors.Root(err) != query.ErrBadBackfillRange {
+		t.Fatalf("StartBackfill(past the tip) = %v want %v", err, query.ErrBadBackfillRange)
+	}
+
+	p, err := indexer.StartBackfill(ctx, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.FromHeight != 1 || p.ToHeight != c.Height() {
+		t.Errorf("started backfill of %d-%d, want 1-%d", p.FromHeight, p.ToHeight, c.Height())
+	}
+	for !p.Done() {
+		if p.Error != "" {
+			t.Fatal(p.Error)
+		}
+		time.Sleep(10 * time.Millisecond)
+		p, err = indexer.BackfillProgress(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	var n int
+	err = db.QueryRowContext(ctx, `SELECT count(*) FROM query_blocks`).Scan(&n)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if uint64(n) != c.Height() {
+		t.Errorf("indexed %d blocks, want %d", n, c.Height())
+	}
+}
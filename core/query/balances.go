@@ -3,21 +3,53 @@ package query
 import (
 	"bytes"
 	"context"
+	"database/sql"
 	"fmt"
 	"strconv"
+	"time"
 
 	"github.com/lib/pq"
 
 	"chain/core/query/filter"
 	"chain/errors"
+	"chain/protocol/bc"
 )
 
+var (
+	ErrTimestampBeforeInitialBlock = errors.New("timestamp is before the initial block")
+	ErrTimestampInFuture           = errors.New("timestamp is in the future")
+)
+
+// BlockHeightAt returns the height of the indexed block with the
+// greatest timestamp less than or equal to timestampMS. It is used to
+// evaluate point-in-time queries against the state of the blockchain
+// as of that block.
+func (ind *Indexer) BlockHeightAt(ctx context.Context, timestampMS uint64) (uint64, error) {
+	if timestampMS > bc.Millis(time.Now()) {
+		return 0, errors.WithDetailf(ErrTimestampInFuture, "timestamp %d", timestampMS)
+	}
+
+	const q = `SELECT MAX(height) FROM query_blocks WHERE timestamp <= $1`
+	var height sql.NullInt64
+	err := ind.db.QueryRowContext(ctx, q, timestampMS).Scan(&height)
+	if err != nil {
+		return 0, errors.Wrap(err, "querying `query_blocks`")
+	}
+	if !height.Valid {
+		return 0, errors.WithDetailf(ErrTimestampBeforeInitialBlock, "timestamp %d", timestampMS)
+	}
+	return uint64(height.Int64), nil
+}
+
 // Balances performs a balances query against the annotated_outputs.
-func (ind *Indexer) Balances(ctx context.Context, filt string, vals []interface{}, sumBy []filter.Field, timestampMS uint64) ([]interface{}, error) {
+// Only outputs that were unspent as of the block at the provided
+// height are included.
+func (ind *Indexer) Balances(ctx context.Context, filt string, vals []interface{}, sumBy []filter.Field, height uint64) ([]interface{}, error) {
 	p, err := filter.Parse(filt, outputsTable, vals)
 	if err != nil {
 		return nil, err
 	}
+	warnUnindexed(ctx, p)
 	if len(vals) != p.Parameters {
 		return nil, ErrParameterCountMismatch
 	}
@@ -25,7 +57,7 @@ func (ind *Indexer) Balances(ctx context.Context, filt string, vals []interface{
 	if err != nil {
 		return nil, err
 	}
-	queryStr, queryArgs, err := constructBalancesQuery(expr, vals, sumBy, timestampMS)
+	queryStr, queryArgs, err := constructBalancesQuery(expr, vals, sumBy, height)
 	if err != nil {
 		return nil, err
 	}
@@ -69,14 +101,46 @@ func (ind *Indexer) Balances(ctx context.Context, filt string, vals []interface{
 	return balances, errors.Wrap(rows.Err())
 }
 
-func constructBalancesQuery(expr string, vals []interface{}, sumBy []filter.Field, timestampMS uint64) (string, []interface{}, error) {
-	var buf bytes.Buffer
+// registryAlias describes a sum_by dimension that is resolved
+// through one of the local registry tables rather than the alias
+// recorded in annotated_outputs at indexing time. Outputs with
+// no registered alias group under a NULL key.
+type registryAlias struct {
+	join string // LEFT JOIN clause
+	col  string // selected column
+}
+
+var registryAliases = map[string]registryAlias{
+	"asset_alias": {
+		join: `LEFT JOIN "assets" AS ast ON ast.id = out.asset_id`,
+		col:  `ast."alias"`,
+	},
+	"account_alias": {
+		join: `LEFT JOIN "accounts" AS acc ON acc.account_id = out.account_id`,
+		col:  `acc."alias"`,
+	},
+}
+
+func constructBalancesQuery(expr string, vals []interface{}, sumBy []filter.Field, height uint64) (string, []interface{}, error) {
+	var (
+		buf   bytes.Buffer
+		joins []string
+	)
 
 	buf.WriteString("SELECT COALESCE(SUM(amount), 0)")
 	for _, field := range sumBy {
-		fieldSQL, err := filter.FieldAsSQL(outputsTable, field)
-		if err != nil {
-			return "", nil, err
+		var fieldSQL string
+		if ra, ok := registryAliases[field.String()]; ok {
+			fieldSQL = ra.col
+			if !containsString(joins, ra.join) {
+				joins = append(joins, ra.join)
+			}
+		} else {
+			var err error
+			fieldSQL, err = filter.FieldAsSQL(outputsTable, field)
+			if err != nil {
+				return "", nil, err
+			}
 		}
 
 		buf.WriteString(", ")
@@ -84,16 +148,21 @@ func constructBalancesQuery(expr string, vals []interface{}, sumBy []filter.Fiel
 	}
 	buf.WriteString(" FROM ")
 	buf.WriteString(pq.QuoteIdentifier("annotated_outputs"))
-	buf.WriteString(" AS out WHERE ")
+	buf.WriteString(" AS out ")
+	for _, j := range joins {
+		buf.WriteString(j)
+		buf.WriteString(" ")
+	}
+	buf.WriteString("WHERE ")
 	if len(expr) > 0 {
 		buf.WriteString("(")
 		buf.WriteString(expr)
 		buf.WriteString(") AND ")
 	}
 
-	vals = append(vals, timestampMS)
-	timestampValIndex := len(vals)
-	buf.WriteString(fmt.Sprintf("timespan @> $%d::int8", timestampValIndex))
+	vals = append(vals, height)
+	heightValIndex := len(vals)
+	buf.WriteString(fmt.Sprintf("block_height <= $%d::int8 AND (spent_block_height IS NULL OR spent_block_height > $%d::int8)", heightValIndex, heightValIndex))
 
 	if len(sumBy) > 0 {
 		buf.WriteString(" GROUP BY ")
@@ -107,3 +176,12 @@ func constructBalancesQuery(expr string, vals []interface{}, sumBy []filter.Fiel
 	// TODO(jackson): Support pagination.
 	return buf.String(), vals, nil
 }
+
+func containsString(a []string, s string) bool {
+	for _, x := range a {
+		if x == s {
+			return true
+		}
+	}
+	return false
+}
@@ -8,7 +8,7 @@ import (
 )
 
 func TestConstructBalancesQuery(t *testing.T) {
-	now := uint64(123456)
+	height := uint64(123456)
 	testCases := []struct {
 		predicate  string
 		sumBy      []string
@@ -19,28 +19,40 @@ func TestConstructBalancesQuery(t *testing.T) {
 		{
 			predicate:  "account_id = 'abc'",
 			sumBy:      []string{"asset_id"},
-			wantQuery:  `SELECT COALESCE(SUM(amount), 0), encode(out."asset_id", 'hex') FROM "annotated_outputs" AS out WHERE (out."account_id" = 'abc') AND timespan @> $1::int8 GROUP BY 2`,
-			wantValues: []interface{}{now},
+			wantQuery:  `SELECT COALESCE(SUM(amount), 0), encode(out."asset_id", 'hex') FROM "annotated_outputs" AS out WHERE (out."account_id" = 'abc') AND block_height <= $1::int8 AND (spent_block_height IS NULL OR spent_block_height > $1::int8) GROUP BY 2`,
+			wantValues: []interface{}{height},
 		},
 		{
 			predicate:  "account_id = $1",
 			sumBy:      []string{"asset_id"},
 			values:     []interface{}{"abc"},
-			wantQuery:  `SELECT COALESCE(SUM(amount), 0), encode(out."asset_id", 'hex') FROM "annotated_outputs" AS out WHERE (out."account_id" = $1) AND timespan @> $2::int8 GROUP BY 2`,
-			wantValues: []interface{}{`abc`, now},
+			wantQuery:  `SELECT COALESCE(SUM(amount), 0), encode(out."asset_id", 'hex') FROM "annotated_outputs" AS out WHERE (out."account_id" = $1) AND block_height <= $2::int8 AND (spent_block_height IS NULL OR spent_block_height > $2::int8) GROUP BY 2`,
+			wantValues: []interface{}{`abc`, height},
 		},
 		{
 			predicate:  "asset_id = $1 AND account_id = $2",
 			values:     []interface{}{"foo", "bar"},
-			wantQuery:  `SELECT COALESCE(SUM(amount), 0) FROM "annotated_outputs" AS out WHERE (encode(out."asset_id", 'hex') = $1 AND out."account_id" = $2) AND timespan @> $3::int8`,
-			wantValues: []interface{}{`foo`, `bar`, now},
+			wantQuery:  `SELECT COALESCE(SUM(amount), 0) FROM "annotated_outputs" AS out WHERE (encode(out."asset_id", 'hex') = $1 AND out."account_id" = $2) AND block_height <= $3::int8 AND (spent_block_height IS NULL OR spent_block_height > $3::int8)`,
+			wantValues: []interface{}{`foo`, `bar`, height},
 		},
 		{
 			predicate:  "account_id = $1",
 			sumBy:      []string{"asset_tags.currency"},
 			values:     []interface{}{"foo"},
-			wantQuery:  `SELECT COALESCE(SUM(amount), 0), out."asset_tags"->>'currency' FROM "annotated_outputs" AS out WHERE (out."account_id" = $1) AND timespan @> $2::int8 GROUP BY 2`,
-			wantValues: []interface{}{`foo`, now},
+			wantQuery:  `SELECT COALESCE(SUM(amount), 0), out."asset_tags"->>'currency' FROM "annotated_outputs" AS out WHERE (out."account_id" = $1) AND block_height <= $2::int8 AND (spent_block_height IS NULL OR spent_block_height > $2::int8) GROUP BY 2`,
+			wantValues: []interface{}{`foo`, height},
+		},
+		{
+			predicate:  "account_id = $1",
+			sumBy:      []string{"asset_alias"},
+			values:     []interface{}{"foo"},
+			wantQuery:  `SELECT COALESCE(SUM(amount), 0), ast."alias" FROM "annotated_outputs" AS out LEFT JOIN "assets" AS ast ON ast.id = out.asset_id WHERE (out."account_id" = $1) AND block_height <= $2::int8 AND (spent_block_height IS NULL OR spent_block_height > $2::int8) GROUP BY 2`,
+			wantValues: []interface{}{`foo`, height},
+		},
+		{
+			sumBy:      []string{"account_alias", "asset_alias", "asset_id"},
+			wantQuery:  `SELECT COALESCE(SUM(amount), 0), acc."alias", ast."alias", encode(out."asset_id", 'hex') FROM "annotated_outputs" AS out LEFT JOIN "accounts" AS acc ON acc.account_id = out.account_id LEFT JOIN "assets" AS ast ON ast.id = out.asset_id WHERE block_height <= $1::int8 AND (spent_block_height IS NULL OR spent_block_height > $1::int8) GROUP BY 2, 3, 4`,
+			wantValues: []interface{}{height},
 		},
 	}
 
@@ -62,7 +74,7 @@ func TestConstructBalancesQuery(t *testing.T) {
 			fields = append(fields, f)
 		}
 
-		query, values, err := constructBalancesQuery(expr, tc.values, fields, now)
+		query, values, err := constructBalancesQuery(expr, tc.values, fields, height)
 		if err != nil {
 			t.Fatal(err)
 		}
@@ -12,8 +12,14 @@ Expressions in a filter expression have the following forms:
   Form                     Type     Subexpression types
   expr1 "OR" expr2         bool     bool, bool
   expr1 "AND" expr2        bool     bool, bool
+  "NOT" expr               bool     bool
   ident "(" expr ")"       bool     list, bool
   expr1 "=" expr2          bool     any (must match)
+  expr1 "<" expr2          bool     int, int
+  expr1 "<=" expr2         bool     int, int
+  expr1 ">" expr2          bool     int, int
+  expr1 ">=" expr2         bool     int, int
+  expr1 "MATCHES" pattern  bool     string, string
   expr "." ident           any      object
   "(" expr ")"             any      any
   ident                    any      n/a
@@ -25,9 +31,17 @@ Expressions in a filter expression have the following forms:
   placeholder is a decimal int with prefix "$"
   scalar means int or string
   string is single-quoted, and cannot contain backslash
+  pattern is a placeholder or a string, which may also contain
+    backslash before * or another backslash
   int is decimal or hexadecimal (with prefix "0x")
   list is a slice of environments
 
+Operators bind with the following precedence, from loosest
+to tightest: OR, AND, NOT, then the comparisons (=, <, <=, >, >=,
+MATCHES). The expression 'NOT expr' is true unless expr is true;
+in particular, it is true if expr refers to a field that is
+missing from the environment.
+
 The environment is a map from names to values. Identifier
 expressions get their values from the environment map.
 
@@ -37,6 +51,14 @@ subexpression 'expr' is evaluated in each subenvironment, and if
 there exists one subenvironment for which 'expr' is true, the
 expression as a whole is true.
 
+The range comparisons also accept a timestamp attribute compared
+with a string, which is interpreted as a timestamp.
+
+In a MATCHES pattern, * matches any sequence of characters, and
+a backslash matches the character that follows it literally. For
+example, 'ord-*' matches any string beginning with "ord-", and
+'\*' matches a single asterisk.
+
 Filters are statically type-checked: if a subexpression doesn't have
 the appropriate type, Parse will return an error.
 
@@ -15,6 +15,14 @@ func (e binaryExpr) String() string {
 	return e.l.String() + " " + e.op.name + " " + e.r.String()
 }
 
+type notExpr struct {
+	inner expr
+}
+
+func (e notExpr) String() string {
+	return "NOT " + e.inner.String()
+}
+
 type attrExpr struct {
 	attr string
 }
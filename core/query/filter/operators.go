@@ -6,8 +6,28 @@ type binaryOp struct {
 	sqlOp      string
 }
 
+// notPrecedence is the precedence of the unary NOT operator.
+// NOT binds more tightly than AND, but less tightly than =.
+const notPrecedence = 3
+
 var binaryOps = map[string]*binaryOp{
 	"OR":  {1, "OR", "OR"},
 	"AND": {2, "AND", "AND"},
-	"=":   {3, "=", "="},
+	"=":   {4, "=", "="},
+	"<":   {4, "<", "<"},
+	"<=":  {4, "<=", "<="},
+	">":   {4, ">", ">"},
+	">=":  {4, ">=", ">="},
+
+	"MATCHES": {4, "MATCHES", "LIKE"},
+}
+
+// isRangeOp returns whether op compares the order
+// of its operands.
+func isRangeOp(op *binaryOp) bool {
+	switch op.name {
+	case "<", "<=", ">", ">=":
+		return true
+	}
+	return false
 }
@@ -3,6 +3,7 @@ package filter
 import (
 	"fmt"
 	"strconv"
+	"strings"
 
 	"chain/errors"
 )
@@ -52,6 +53,27 @@ func Parse(predicate string, tbl *SQLTable, vals []interface{}) (p Predicate, er
 	}, nil
 }
 
+// HasPatternMatch returns whether the predicate uses MATCHES.
+// Pattern matches can't use an index, so they are slow on
+// large data sets.
+func (p Predicate) HasPatternMatch() bool {
+	return hasPatternMatch(p.expr)
+}
+
+func hasPatternMatch(e expr) bool {
+	switch e := e.(type) {
+	case binaryExpr:
+		return e.op.name == "MATCHES" || hasPatternMatch(e.l) || hasPatternMatch(e.r)
+	case notExpr:
+		return hasPatternMatch(e.inner)
+	case parenExpr:
+		return hasPatternMatch(e.inner)
+	case envExpr:
+		return hasPatternMatch(e.expr)
+	}
+	return false
+}
+
 // Field is a type for simple expressions that simply access an attribute of
 // the queried object. They're used for GROUP BYs.
 type Field struct {
@@ -114,6 +136,10 @@ type parser struct {
 
 	maxPlaceholder int
 
+	// pattern is set while parsing the pattern of a MATCHES
+	// expression, the only place backslash escapes are allowed.
+	pattern bool
+
 	// Current token
 	pos int    // token position
 	tok token  // one token look-ahead
@@ -147,10 +173,21 @@ func (p *parser) parseTok(tok token) {
 func parseExpr(p *parser) expr {
 	// Uses the precedence-climbing algorithm:
 	// https://en.wikipedia.org/wiki/Operator-precedence_parser#Precedence_climbing_method
-	expr := parsePrimaryExpr(p)
+	expr := parseUnaryExpr(p)
 	return parseExprCont(p, expr, 0)
 }
 
+func parseUnaryExpr(p *parser) expr {
+	if p.tok != tokKeyword || p.lit != "NOT" {
+		return parsePrimaryExpr(p)
+	}
+	p.next()
+	// NOT applies to the following operand and any
+	// operators that bind more tightly than it does.
+	inner := parseExprCont(p, parseUnaryExpr(p), notPrecedence+1)
+	return notExpr{inner: inner}
+}
+
 func parseExprCont(p *parser, lhs expr, minPrecedence int) expr {
 	for {
 		op, ok := determineBinaryOp(p, minPrecedence)
@@ -159,7 +196,9 @@ func parseExprCont(p *parser, lhs expr, minPrecedence int) expr {
 		}
 		p.next()
 
-		rhs := parsePrimaryExpr(p)
+		p.pattern = op.name == "MATCHES"
+		rhs := parseUnaryExpr(p)
+		p.pattern = false
 
 		for {
 			op2, ok := determineBinaryOp(p, op.precedence+1)
@@ -189,6 +228,9 @@ func parseOperand(p *parser) expr {
 		p.parseLit(")")
 		return parenExpr{inner: expr}
 	case p.tok == tokString:
+		if !p.pattern && strings.ContainsRune(p.lit, '\\') {
+			p.errorf("illegal backslash in string literal")
+		}
 		v := valueExpr{typ: p.tok, value: p.lit}
 		p.next()
 		return v
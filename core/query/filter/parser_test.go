@@ -118,6 +118,111 @@ func TestParseValid(t *testing.T) {
 				},
 			},
 		},
+		{
+			p: "amount >= $1 AND amount < 100",
+			expr: binaryExpr{
+				op: binaryOps["AND"],
+				l: binaryExpr{
+					op: binaryOps[">="],
+					l:  attrExpr{attr: "amount"},
+					r:  placeholderExpr{num: 1},
+				},
+				r: binaryExpr{
+					op: binaryOps["<"],
+					l:  attrExpr{attr: "amount"},
+					r:  valueExpr{typ: tokInteger, value: "100"},
+				},
+			},
+		},
+		{
+			p: "reference_data.order.id MATCHES 'ord-*' AND amount = 1",
+			expr: binaryExpr{
+				op: binaryOps["AND"],
+				l: binaryExpr{
+					op: binaryOps["MATCHES"],
+					l: selectorExpr{
+						ident: "id",
+						objExpr: selectorExpr{
+							ident:   "order",
+							objExpr: attrExpr{attr: "reference_data"},
+						},
+					},
+					r: valueExpr{typ: tokString, value: "'ord-*'"},
+				},
+				r: binaryExpr{
+					op: binaryOps["="],
+					l:  attrExpr{attr: "amount"},
+					r:  valueExpr{typ: tokInteger, value: "1"},
+				},
+			},
+		},
+		{
+			p: "NOT is_local",
+			expr: notExpr{
+				inner: attrExpr{attr: "is_local"},
+			},
+		},
+		{
+			// NOT binds more tightly than AND and OR,
+			// but less tightly than =.
+			p: "NOT asset_id = $1 AND is_local OR NOT NOT is_local",
+			expr: binaryExpr{
+				op: binaryOps["OR"],
+				l: binaryExpr{
+					op: binaryOps["AND"],
+					l: notExpr{
+						inner: binaryExpr{
+							op: binaryOps["="],
+							l:  attrExpr{attr: "asset_id"},
+							r:  placeholderExpr{num: 1},
+						},
+					},
+					r: attrExpr{attr: "is_local"},
+				},
+				r: notExpr{
+					inner: notExpr{
+						inner: attrExpr{attr: "is_local"},
+					},
+				},
+			},
+		},
+		{
+			p: "NOT (asset_id = $1 OR asset_id = $2)",
+			expr: notExpr{
+				inner: parenExpr{
+					inner: binaryExpr{
+						op: binaryOps["OR"],
+						l: binaryExpr{
+							op: binaryOps["="],
+							l:  attrExpr{attr: "asset_id"},
+							r:  placeholderExpr{num: 1},
+						},
+						r: binaryExpr{
+							op: binaryOps["="],
+							l:  attrExpr{attr: "asset_id"},
+							r:  placeholderExpr{num: 2},
+						},
+					},
+				},
+			},
+		},
+		{
+			p: "is_local AND NOT outputs(asset_id = $1)",
+			expr: binaryExpr{
+				op: binaryOps["AND"],
+				l:  attrExpr{attr: "is_local"},
+				r: notExpr{
+					inner: envExpr{
+						ident: "outputs",
+						expr: binaryExpr{
+							op: binaryOps["="],
+							l:  attrExpr{attr: "asset_id"},
+							r:  placeholderExpr{num: 1},
+						},
+					},
+				},
+			},
+		},
 	}
 
 	for i, tc := range testCases {
@@ -142,10 +247,17 @@ func TestParseInvalid(t *testing.T) {
 		"5 = $",                                       // $ without number
 		"'unterminated string",                        // unterminated string
 		`'strings do not allow \ backslash'`,          // illegal backslash
+		`ref.id = 'a\\b'`,                             // backslash outside a MATCHES pattern
+		`'a\*' MATCHES ref.id`,                        // backslash outside a MATCHES pattern
 		"0x = 420",                                    // 0x without number
 		"an_identifier another_identifier",            // two identifiers w/o an operator (trailing garbage)
 		"inputs(account_tags.level = $1) or (1 == 1)", // lowercase 'or' (trailing garbage)
 		"reference.(recipient.email_address)`",        // expected ident, got paren expr
+		"NOT",                                         // missing operand
+		"amount => 5",                                 // no => operator
+		"amount < < 5",                                // missing operand
+		"is_local NOT is_local",                       // NOT is not a binary operator
+		"(is_local OR is_local",                       // unclosed paren
 	}
 	for _, tc := range testCases {
 		expr, _, err := parse(tc)
@@ -154,3 +266,28 @@ func TestParseInvalid(t *testing.T) {
 		}
 	}
 }
+
+func TestParseErrorOffset(t *testing.T) {
+	testCases := []struct {
+		p   string
+		col int
+	}{
+		{"is_local AND", 12},
+		{"is_local AND AND is_local", 13},
+		{"(is_local OR NOT)", 16},
+		{"is_local NOT is_local", 9},
+		{"(asset_id = $1 OR asset_id = $2", 31},
+		{`asset_alias = 'a\\b'`, 14},
+	}
+	for _, tc := range testCases {
+		_, _, err := parse(tc.p)
+		perr, ok := err.(parseError)
+		if !ok {
+			t.Errorf("parse(%q) error = %v, want parse error", tc.p, err)
+			continue
+		}
+		if perr.pos != tc.col {
+			t.Errorf("parse(%q) error at col %d, want col %d (%s)", tc.p, perr.pos, tc.col, perr)
+		}
+	}
+}
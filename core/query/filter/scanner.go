@@ -164,7 +164,13 @@ func (s *scanner) scanString() {
 			break
 		}
 		if ch == '\\' {
-			s.error(offs, "illegal backslash in string literal")
+			// Backslash may only escape a wildcard or another
+			// backslash. The parser allows even that only in
+			// MATCHES patterns.
+			if s.ch != '*' && s.ch != '\\' {
+				s.error(offs, "illegal backslash in string literal")
+			}
+			s.next()
 		}
 	}
 }
@@ -186,7 +192,7 @@ func (s *scanner) Scan() (pos int, tok token, lit string) {
 	case isLetter(ch):
 		lit = s.scanIdentifier()
 		switch lit {
-		case "AND", "OR":
+		case "AND", "OR", "NOT", "MATCHES":
 			tok = tokKeyword
 		default:
 			tok = tokIdent
@@ -205,6 +211,11 @@ func (s *scanner) Scan() (pos int, tok token, lit string) {
 			s.scanString()
 		case '.', '(', ')', '=':
 			tok = tokPunct
+		case '<', '>':
+			if s.ch == '=' {
+				s.next()
+			}
+			tok = tokPunct
 		case '$':
 			s.scanMantissa(10)
 			if s.offset-pos <= 1 {
@@ -54,6 +54,30 @@ func TestScannerValid(t *testing.T) {
 				{pos: 25, lit: "", tok: tokEOF},
 			},
 		},
+		{
+			input: []byte("amount>=5 AND amount<10"),
+			toks: []scannedTok{
+				{pos: 0, lit: "amount", tok: tokIdent},
+				{pos: 6, lit: ">=", tok: tokPunct},
+				{pos: 8, lit: "5", tok: tokInteger},
+				{pos: 10, lit: "AND", tok: tokKeyword},
+				{pos: 14, lit: "amount", tok: tokIdent},
+				{pos: 20, lit: "<", tok: tokPunct},
+				{pos: 21, lit: "10", tok: tokInteger},
+				{pos: 23, lit: "", tok: tokEOF},
+			},
+		},
+		{
+			input: []byte(`ref.id MATCHES 'a\*b\\*'`),
+			toks: []scannedTok{
+				{pos: 0, lit: "ref", tok: tokIdent},
+				{pos: 3, lit: ".", tok: tokPunct},
+				{pos: 4, lit: "id", tok: tokIdent},
+				{pos: 7, lit: "MATCHES", tok: tokKeyword},
+				{pos: 15, lit: `'a\*b\\*'`, tok: tokString},
+				{pos: 24, lit: "", tok: tokEOF},
+			},
+		},
 		{
 			input: []byte(`comme ci comme ça`),
 			toks: []scannedTok{
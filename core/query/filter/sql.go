@@ -145,6 +145,15 @@ func asSQL(c *sqlContext, filterExpr expr) error {
 			return err
 		}
 		c.buf.WriteRune(')')
+	case notExpr:
+		// Use IS NOT TRUE rather than NOT, so that missing
+		// JSON fields (which are NULL in SQL) negate to true.
+		c.buf.WriteRune('(')
+		err := asSQL(c, e.inner)
+		if err != nil {
+			return err
+		}
+		c.buf.WriteString(") IS NOT TRUE")
 	case valueExpr:
 		switch e.typ {
 		case tokString:
@@ -228,6 +237,13 @@ func asSQL(c *sqlContext, filterExpr expr) error {
 			}
 		}
 	case binaryExpr:
+		if isRangeOp(e.op) && (isTimestampAttr(e.l, c.tbl) || isTimestampAttr(e.r, c.tbl)) {
+			return timestampRangeAsSQL(c, e)
+		}
+		if e.op.name == "MATCHES" {
+			return matchAsSQL(c, e)
+		}
+
 		err := asSQL(c, e.l)
 		if err != nil {
 			return err
@@ -284,3 +300,91 @@ func asSQL(c *sqlContext, filterExpr expr) error {
 	}
 	return nil
 }
+
+// timestampRangeAsSQL writes a range comparison involving a
+// timestamp column. The column is compared as a timestamp,
+// not as text, so the comparison can use an index on it.
+func timestampRangeAsSQL(c *sqlContext, e binaryExpr) error {
+	for i, operand := range []expr{e.l, e.r} {
+		if i == 1 {
+			c.buf.WriteRune(' ')
+			c.buf.WriteString(e.op.sqlOp)
+			c.buf.WriteRune(' ')
+		}
+		operand = unparen(operand)
+		if attr, ok := operand.(attrExpr); ok && isTimestampAttr(attr, c.tbl) {
+			c.writeCol(c.tbl.Columns[attr.attr].Name)
+			continue
+		}
+		c.buf.WriteRune('(')
+		err := asSQL(c, operand)
+		if err != nil {
+			return err
+		}
+		c.buf.WriteString(")::timestamptz")
+	}
+	return nil
+}
+
+// matchAsSQL writes a MATCHES expression as a LIKE
+// expression. A literal pattern is converted to LIKE syntax
+// here; a placeholder is converted by the database function
+// glob_to_like.
+func matchAsSQL(c *sqlContext, e binaryExpr) error {
+	err := asSQL(c, e.l)
+	if err != nil {
+		return err
+	}
+	c.buf.WriteString(" LIKE ")
+
+	switch r := unparen(e.r).(type) {
+	case valueExpr:
+		// Trim the quotes. The string can't contain
+		// quotes itself, so there's no need to re-escape them.
+		c.buf.WriteRune('\'')
+		c.buf.WriteString(globToLike(r.value[1 : len(r.value)-1]))
+		c.buf.WriteRune('\'')
+	case placeholderExpr:
+		c.buf.WriteString("glob_to_like(")
+		err = asSQL(c, r)
+		if err != nil {
+			return err
+		}
+		c.buf.WriteRune(')')
+	default:
+		return errors.WithDetailf(ErrBadFilter, "invalid MATCHES pattern: %s", e.r)
+	}
+	return nil
+}
+
+// globToLike converts a MATCHES pattern to a LIKE pattern.
+// In a MATCHES pattern, * matches any sequence of characters
+// and a backslash escapes the following character.
+// It must match the glob_to_like function in the schema.
+func globToLike(glob string) string {
+	var buf bytes.Buffer
+	escaped := false
+	for _, r := range glob {
+		switch {
+		case escaped:
+			escaped = false
+			if r == '%' || r == '_' || r == '\\' {
+				buf.WriteRune('\\')
+			}
+			buf.WriteRune(r)
+		case r == '\\':
+			escaped = true
+		case r == '*':
+			buf.WriteRune('%')
+		case r == '%' || r == '_':
+			buf.WriteRune('\\')
+			buf.WriteRune(r)
+		default:
+			buf.WriteRune(r)
+		}
+	}
+	if escaped {
+		buf.WriteString(`\\`)
+	}
+	return buf.String()
+}
@@ -33,10 +33,11 @@ var (
 		Name:  "annotated_txs",
 		Alias: "txs",
 		Columns: map[string]*SQLColumn{
-			"id":       {Name: "tx_hash", Type: String, SQLType: SQLBytea},
-			"ref":      {Name: "ref", Type: Object, SQLType: SQLJSONB},
-			"position": {Name: "position", Type: Integer, SQLType: SQLInteger},
-			"is_local": {Name: "local", Type: Bool, SQLType: SQLBool},
+			"id":        {Name: "tx_hash", Type: String, SQLType: SQLBytea},
+			"ref":       {Name: "ref", Type: Object, SQLType: SQLJSONB},
+			"position":  {Name: "position", Type: Integer, SQLType: SQLInteger},
+			"is_local":  {Name: "local", Type: Bool, SQLType: SQLBool},
+			"timestamp": {Name: "timestamp", Type: String, SQLType: SQLTimestamp},
 		},
 		ForeignKeys: map[string]*SQLForeignKey{
 			"inputs":  {Table: inputsSQLTable, LocalColumn: "tx_hash", ForeignColumn: "tx_hash"},
@@ -165,6 +166,69 @@ EXISTS(SELECT 1 FROM annotated_outputs AS out WHERE out."tx_hash" = txs."tx_hash
 EXISTS(SELECT 1 FROM annotated_inputs AS inp WHERE inp."tx_hash" = txs."tx_hash" AND (inp."a" = 'a'))
  AND (txs."ref"->>'txbankref') = '1ab'`,
 		},
+		{ // range comparisons
+			q:   `inputs(amount >= 1000000 AND amount < 2000000 AND type = 'spend')`,
+			tbl: transactionsSQLTable,
+			sql: `
+EXISTS(SELECT 1 FROM annotated_inputs AS inp WHERE inp."tx_hash" = txs."tx_hash" AND (inp."amount" >= 1000000::bigint AND inp."amount" < 2000000::bigint AND inp."type" = 'spend'))
+`,
+		},
+		{ // range comparison on an integer column
+			q:   `position > 2`,
+			tbl: transactionsSQLTable,
+			sql: `txs."position"::bigint > 2::bigint`,
+		},
+		{ // range comparison on json
+			q:   `ref.count <= 10`,
+			tbl: transactionsSQLTable,
+			sql: `(txs."ref"->>'count')::bigint <= 10::bigint`,
+		},
+		{ // timestamp range comparisons
+			q:   `timestamp >= '2017-01-01T00:00:00Z' AND $1 > timestamp`,
+			tbl: transactionsSQLTable,
+			sql: `txs."timestamp" >= ('2017-01-01T00:00:00Z')::timestamptz AND ($1)::timestamptz > txs."timestamp"`,
+		},
+		{ // error - range comparison on a string
+			q:   `id < 'abc'`,
+			tbl: transactionsSQLTable,
+			err: errors.WithDetail(ErrBadFilter, "< expects integer operands"),
+		},
+		{ // prefix match
+			q:   `ref.order.id MATCHES 'ord-*'`,
+			tbl: transactionsSQLTable,
+			sql: `(txs."ref"->'order'->>'id') LIKE 'ord-%'`,
+		},
+		{ // suffix and middle matches, with LIKE characters escaped
+			q:   `ref.a MATCHES '*_x' OR ref.b MATCHES 'a*100%'`,
+			tbl: transactionsSQLTable,
+			sql: `(txs."ref"->>'a') LIKE '%\_x' OR (txs."ref"->>'b') LIKE 'a%100\%'`,
+		},
+		{ // escaped wildcard
+			q:   `ref.a MATCHES 'a\**'`,
+			tbl: transactionsSQLTable,
+			sql: `(txs."ref"->>'a') LIKE 'a*%'`,
+		},
+		{ // placeholder pattern
+			q:   `inputs(type MATCHES $1)`,
+			tbl: transactionsSQLTable,
+			sql: `
+EXISTS(SELECT 1 FROM annotated_inputs AS inp WHERE inp."tx_hash" = txs."tx_hash" AND (inp."type" LIKE glob_to_like($1)))
+`,
+		},
+		{ // negation
+			q:   `NOT is_local AND NOT (position = 2 OR ref.a = 'b')`,
+			tbl: transactionsSQLTable,
+			sql: `(txs."local") IS NOT TRUE AND ((txs."position"::bigint = 2::bigint OR (txs."ref"->>'a') = 'b')) IS NOT TRUE`,
+		},
+		{ // negated environment expression
+			q:   `NOT inputs(a = 'a') OR outputs(b = 'b')`,
+			tbl: transactionsSQLTable,
+			sql: `(
+EXISTS(SELECT 1 FROM annotated_inputs AS inp WHERE inp."tx_hash" = txs."tx_hash" AND (inp."a" = 'a'))
+) IS NOT TRUE OR 
+EXISTS(SELECT 1 FROM annotated_outputs AS out WHERE out."tx_hash" = txs."tx_hash" AND (out."b" = 'b'))
+`,
+		},
 	}
 
 	values := []interface{}{"hey"}
@@ -192,3 +256,49 @@ EXISTS(SELECT 1 FROM annotated_inputs AS inp WHERE inp."tx_hash" = txs."tx_hash"
 		}
 	}
 }
+
+func TestGlobToLike(t *testing.T) {
+	cases := []struct {
+		glob, like string
+	}{
+		{``, ``},
+		{`abc`, `abc`},
+		{`ord-*`, `ord-%`},
+		{`*-2017`, `%-2017`},
+		{`a*b*c`, `a%b%c`},
+		{`100%`, `100\%`},
+		{`a_b`, `a\_b`},
+		{`\*`, `*`},
+		{`\**`, `*%`},
+		{`\\*`, `\\%`},
+		{`a\b`, `ab`},
+		{`a\`, `a\\`},
+	}
+	for _, c := range cases {
+		got := globToLike(c.glob)
+		if got != c.like {
+			t.Errorf("globToLike(%q) = %q want %q", c.glob, got, c.like)
+		}
+	}
+}
+
+func TestHasPatternMatch(t *testing.T) {
+	cases := []struct {
+		q    string
+		want bool
+	}{
+		{``, false},
+		{`ref.a = 'b'`, false},
+		{`ref.a MATCHES 'b*'`, true},
+		{`is_local AND NOT (inputs(type MATCHES 'x*'))`, true},
+	}
+	for _, c := range cases {
+		p, err := Parse(c.q, transactionsSQLTable, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := p.HasPatternMatch(); got != c.want {
+			t.Errorf("HasPatternMatch(%q) = %t want %t", c.q, got, c.want)
+		}
+	}
+}
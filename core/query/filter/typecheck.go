@@ -1,6 +1,7 @@
 package filter
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
 	"strings"
@@ -20,6 +21,12 @@ func valueTypes(vals []interface{}) ([]Type, error) {
 		switch val.(type) {
 		case int, uint, int32, uint32, int64, uint64:
 			valTypes[i] = Integer
+		case json.Number:
+			// Request bodies are decoded with UseNumber.
+			if _, err := val.(json.Number).Int64(); err != nil {
+				return nil, fmt.Errorf("unsupported number %s", val)
+			}
+			valTypes[i] = Integer
 		case string:
 			valTypes[i] = String
 		case bool:
@@ -62,6 +69,19 @@ func typeCheckExpr(expr expr, tbl *SQLTable, valTypes []Type, selectorTypes map[
 	switch e := expr.(type) {
 	case parenExpr:
 		return typeCheckExpr(e.inner, tbl, valTypes, selectorTypes)
+	case notExpr:
+		innerTyp, err := typeCheckExpr(e.inner, tbl, valTypes, selectorTypes)
+		if err != nil {
+			return innerTyp, err
+		}
+		ok, err := assertType(e.inner, innerTyp, Bool, selectorTypes)
+		if err != nil {
+			return typ, err
+		}
+		if !ok {
+			return typ, errors.New("NOT expects a bool operand")
+		}
+		return Bool, nil
 	case binaryExpr:
 		leftTyp, err := typeCheckExpr(e.l, tbl, valTypes, selectorTypes)
 		if err != nil {
@@ -118,6 +138,56 @@ func typeCheckExpr(expr expr, tbl *SQLTable, valTypes []Type, selectorTypes map[
 				return typ, fmt.Errorf("%s expects operands of matching types", e.op.name)
 			}
 			return Bool, nil
+		case "MATCHES":
+			ok, err := assertType(e.l, leftTyp, String, selectorTypes)
+			if err != nil {
+				return typ, err
+			}
+			if !ok {
+				return typ, errors.New("MATCHES expects a string operand")
+			}
+			switch unparen(e.r).(type) {
+			case valueExpr, placeholderExpr:
+			default:
+				return typ, errors.New("MATCHES pattern must be a string or placeholder")
+			}
+			ok, err = assertType(e.r, rightTyp, String, selectorTypes)
+			if err != nil {
+				return typ, err
+			}
+			if !ok {
+				return typ, errors.New("MATCHES pattern must be a string or placeholder")
+			}
+			return Bool, nil
+		case "<", "<=", ">", ">=":
+			// Timestamps are strings, but are compared as
+			// timestamps rather than lexically.
+			if isTimestampAttr(e.l, tbl) || isTimestampAttr(e.r, tbl) {
+				lok, err := assertType(e.l, leftTyp, String, selectorTypes)
+				if err != nil {
+					return typ, err
+				}
+				rok, err := assertType(e.r, rightTyp, String, selectorTypes)
+				if err != nil {
+					return typ, err
+				}
+				if !lok || !rok {
+					return typ, fmt.Errorf("%s on a timestamp expects a string operand", e.op.name)
+				}
+				return Bool, nil
+			}
+			lok, err := assertType(e.l, leftTyp, Integer, selectorTypes)
+			if err != nil {
+				return typ, err
+			}
+			rok, err := assertType(e.r, rightTyp, Integer, selectorTypes)
+			if err != nil {
+				return typ, err
+			}
+			if !lok || !rok {
+				return typ, fmt.Errorf("%s expects integer operands", e.op.name)
+			}
+			return Bool, nil
 		default:
 			panic(fmt.Errorf("unsupported operator: %s", e.op.name))
 		}
@@ -218,3 +288,25 @@ func setType(expr expr, typ Type, selectorTypes map[string]Type) error {
 		panic(fmt.Errorf("unexpected setType on %T", expr))
 	}
 }
+
+// isTimestampAttr returns whether expr refers
+// directly to a timestamp column of tbl.
+func isTimestampAttr(expr expr, tbl *SQLTable) bool {
+	attr, ok := unparen(expr).(attrExpr)
+	if !ok {
+		return false
+	}
+	col, ok := tbl.Columns[attr.attr]
+	return ok && col.SQLType == SQLTimestamp
+}
+
+// unparen returns expr with any enclosing parentheses removed.
+func unparen(expr expr) expr {
+	for {
+		p, ok := expr.(parenExpr)
+		if !ok {
+			return expr
+		}
+		expr = p.inner
+	}
+}
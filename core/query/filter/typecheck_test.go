@@ -1,6 +1,7 @@
 package filter
 
 import (
+	"encoding/json"
 	"errors"
 	"testing"
 
@@ -23,6 +24,18 @@ func TestTypeCheckInvalid(t *testing.T) {
 		{p: `position(asset_id = 'a')`, err: errors.New("invalid environment `position`")},
 		{p: `('a' = 'a') = (1 = 1)`, err: errors.New("= expects integer or string operands")},
 		{p: `1 OR 2`, err: errors.New("OR expects bool operands")},
+		{p: `NOT 1`, err: errors.New("NOT expects a bool operand")},
+		{p: `id > 'abc'`, err: errors.New("> expects integer operands")},
+		{p: `'a' <= 'b'`, err: errors.New("<= expects integer operands")},
+		{p: `inputs(asset_id < 5)`, err: errors.New("< expects integer operands")},
+		{p: `position >= is_local`, err: errors.New(">= expects integer operands")},
+		{p: `ref.code = 'x' AND ref.code > 1`, err: errors.New("\"ref.code\" used as both string and integer")},
+		{p: `timestamp > 5`, err: errors.New("> on a timestamp expects a string operand")},
+		{p: `position MATCHES '1*'`, err: errors.New("MATCHES expects a string operand")},
+		{p: `ref.a MATCHES 5`, err: errors.New("MATCHES pattern must be a string or placeholder")},
+		{p: `ref.a MATCHES ref.b`, err: errors.New("MATCHES pattern must be a string or placeholder")},
+		{p: `ref.n > 1 AND ref.n MATCHES '1*'`, err: errors.New("\"ref.n\" used as both integer and string")},
+		{p: `NOT 'hello' = NOT 'world'`, err: errors.New("NOT expects a bool operand")},
 		{p: `position.huh`, err: errors.New("selector `.` can only be used on objects")},
 		{p: `ref.something = 'abc' OR ref.something = 123`, err: errors.New("\"ref.something\" used as both string and integer")},
 		{p: `ref.buyer.id = 'abc' OR ref.buyer = 'hello'`, err: errors.New("\"ref.buyer\" used as both object and string")},
@@ -56,6 +69,13 @@ func TestTypeCheckValid(t *testing.T) {
 		{p: `id = id`, typ: Bool},
 		{p: `$1 = 'hello' OR ref.something = $1`, valTypes: []Type{String}, typ: Bool},
 		{p: `($1 = 'hello') OR (ref.something = $1)`, valTypes: []Type{String}, typ: Bool},
+		{p: `NOT ref.is_paid AND NOT (ref.something = $1)`, valTypes: []Type{String}, typ: Bool},
+		{p: `position > 1 AND position <= $1`, valTypes: []Type{Integer}, typ: Bool},
+		{p: `ref.count >= 10`, typ: Bool},
+		{p: `timestamp < $1`, valTypes: []Type{String}, typ: Bool},
+		{p: `ref.order.id MATCHES 'ord-*' OR ref.legacy.order_id MATCHES $1`, valTypes: []Type{String}, typ: Bool},
+		{p: `id MATCHES ('abc*')`, typ: Bool},
+		{p: `inputs(amount > 1000000 AND asset_id = $1)`, valTypes: []Type{String}, typ: Bool},
 		{p: `inputs(account_tags.domestic AND account_tags.type = 'revolving')`, typ: Bool},
 		{p: `inputs(account_tags.state = account_tags.shipping_address.state)`, typ: Bool},
 		{p: `inputs(account_tags.a_boolean_field)`, typ: Bool},
@@ -109,3 +129,19 @@ func TestTypeCheckSelector(t *testing.T) {
 		t.Errorf("Type checking %q, selector types got:\n%#v\nwant:\n%#v\n", predicate, m, want)
 	}
 }
+
+func TestValueTypes(t *testing.T) {
+	got, err := valueTypes([]interface{}{"a", 1, uint64(2), json.Number("1000000"), true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Type{String, Integer, Integer, Integer, Bool}
+	if !testutil.DeepEqual(got, want) {
+		t.Errorf("valueTypes = %v want %v", got, want)
+	}
+
+	_, err = valueTypes([]interface{}{json.Number("1.5")})
+	if err == nil {
+		t.Error("valueTypes(1.5) = nil error, want error")
+	}
+}
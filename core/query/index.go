@@ -4,6 +4,7 @@ import (
 	"context"
 	"database/sql"
 	"encoding/json"
+	"sync"
 
 	"github.com/lib/pq"
 
@@ -36,6 +37,13 @@ type Indexer struct {
 	c          *protocol.Chain
 	pinStore   *pin.Store
 	annotators []Annotator
+	textSearch bool
+
+	backfillMu sync.Mutex // serializes calls to Backfill
+
+	bgMu      sync.Mutex
+	bgRunning bool  // a StartBackfill is running
+	bgErr     error // from the last StartBackfill
 }
 
 // Annotator describes a function capable of adding annotations
@@ -61,7 +69,14 @@ func (ind *Indexer) IndexTransactions(ctx context.Context, b *legacy.Block) erro
 	<-ind.pinStore.PinWaiter("asset", b.Height)
 	<-ind.pinStore.PinWaiter("account", b.Height)
 	<-ind.pinStore.PinWaiter(TxPinName, b.Height-1)
+	return ind.indexBlock(ctx, b)
+}
 
+// indexBlock annotates and saves the transactions in b.
+// Every write does nothing on conflict with a row saved for
+// the same block position, so it is safe to index the same
+// block more than once, concurrently or otherwise.
+func (ind *Indexer) indexBlock(ctx context.Context, b *legacy.Block) error {
 	err := ind.insertBlock(ctx, b)
 	if err != nil {
 		return err
@@ -95,6 +110,7 @@ func (ind *Indexer) insertAnnotatedTxs(ctx context.Context, b *legacy.Block) ([]
 		annotatedTxs     = make([]*AnnotatedTx, 0, len(b.Transactions))
 		locals           = pq.BoolArray(make([]bool, 0, len(b.Transactions)))
 		referenceDatas   = pq.StringArray(make([]string, 0, len(b.Transactions)))
+		searchTexts      = make([]sql.NullString, 0, len(b.Transactions))
 	)
 
 	// Build the fully annotated transactions.
@@ -120,19 +136,21 @@ func (ind *Indexer) insertAnnotatedTxs(ctx context.Context, b *legacy.Block) ([]
 		positions = append(positions, uint32(pos))
 		locals = append(locals, bool(tx.IsLocal))
 		referenceDatas = append(referenceDatas, string(*tx.ReferenceData))
+		searchTexts = append(searchTexts, ind.txSearchText(tx))
 	}
 
 	// Save the annotated txs to the database.
 	const insertQ = `
 		INSERT INTO annotated_txs(block_height, block_id, timestamp,
-			tx_pos, tx_hash, data, local, reference_data, block_tx_count)
+			tx_pos, tx_hash, data, local, reference_data, block_tx_count, search)
 		SELECT $1, $2, $3, unnest($4::integer[]), unnest($5::bytea[]),
-			unnest($6::jsonb[]), unnest($7::boolean[]), unnest($8::jsonb[]), $9
+			unnest($6::jsonb[]), unnest($7::boolean[]), unnest($8::jsonb[]), $9,
+			to_tsvector('simple', unnest($10::text[]))
 		ON CONFLICT (block_height, tx_pos) DO NOTHING;
 	`
 	_, err := ind.db.ExecContext(ctx, insertQ, b.Height, b.Hash(), b.Time(),
 		pq.Array(positions), hashes, annotatedTxBlobs, locals,
-		referenceDatas, len(b.Transactions))
+		referenceDatas, len(b.Transactions), pq.Array(searchTexts))
 	if err != nil {
 		return nil, errors.Wrap(err, "inserting annotated_txs to db")
 	}
@@ -271,11 +289,12 @@ func (ind *Indexer) insertAnnotatedOutputs(ctx context.Context, b *legacy.Block,
 				account_id, account_alias, account_tags, control_program, reference_data, local)
 		)
 		INSERT INTO annotated_outputs (block_height, tx_pos, output_index, tx_hash,
-			timespan, output_id, type, purpose, asset_id, asset_alias, asset_definition,
-			asset_tags, asset_local, amount, account_id, account_alias, account_tags,
-			control_program, reference_data, local)
+			timespan, spent_block_height, output_id, type, purpose, asset_id, asset_alias,
+			asset_definition, asset_tags, asset_local, amount, account_id, account_alias,
+			account_tags, control_program, reference_data, local)
 		SELECT $1, tx_pos, output_index, tx_hash,
 		CASE WHEN type='retire' THEN int8range($5, $5) ELSE int8range($5, NULL) END,
+		CASE WHEN type='retire' THEN $1::bigint ELSE NULL END,
 		output_id, type, purpose, asset_id, asset_alias, asset_definition, asset_tags,
 		asset_local, amount, account_id, account_alias, account_tags, control_program,
 		reference_data, local
@@ -294,9 +313,10 @@ func (ind *Indexer) insertAnnotatedOutputs(ctx context.Context, b *legacy.Block,
 	}
 
 	const updateQ = `
-		UPDATE annotated_outputs SET timespan = INT8RANGE(LOWER(timespan), $1)
+		UPDATE annotated_outputs
+		SET timespan = INT8RANGE(LOWER(timespan), $1), spent_block_height = $3
 		WHERE (output_id) IN (SELECT unnest($2::bytea[]))
 	`
-	_, err = ind.db.ExecContext(ctx, updateQ, b.TimestampMS, prevoutIDs)
+	_, err = ind.db.ExecContext(ctx, updateQ, b.TimestampMS, prevoutIDs, b.Height)
 	return errors.Wrap(err, "updating spent annotated outputs")
 }
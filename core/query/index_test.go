@@ -57,6 +57,7 @@ func TestAnnotatedTxsReferenceData(t *testing.T) {
 			db := pgtest.NewTx(t)
 			c := prottest.NewChain(t)
 			indexer := NewIndexer(db, c, nil)
+			indexer.EnableTextSearch()
 
 			setRefData := func(tx *legacy.Tx) { tx.ReferenceData = []byte(refData) }
 			b := &legacy.Block{
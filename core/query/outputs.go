@@ -52,6 +52,7 @@ func (ind *Indexer) Outputs(ctx context.Context, filt string, vals []interface{}
 	if err != nil {
 		return nil, nil, err
 	}
+	warnUnindexed(ctx, p)
 	if len(vals) != p.Parameters {
 		return nil, nil, ErrParameterCountMismatch
 	}
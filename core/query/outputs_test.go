@@ -113,6 +113,13 @@ func TestConstructOutputsQuery(t *testing.T) {
 			wantQuery:  `SELECT block_height, tx_pos, output_index, tx_hash, output_id, type, purpose, asset_id, asset_alias, asset_definition, asset_tags, asset_local, amount, account_id, account_alias, account_tags, control_program, reference_data, local FROM "annotated_outputs" AS out WHERE (encode(out."asset_id", 'hex') = $1 AND out."account_id" = 'abc') AND timespan @> $2::int8 AND (block_height, tx_pos, output_index) < ($3, $4, $5) ORDER BY block_height DESC, tx_pos DESC, output_index DESC LIMIT 10`,
 			wantValues: []interface{}{`foo`, nowMillis, uint64(15), uint32(17), 19},
 		},
+		{
+			// amount is compared directly, so an index on it can be used
+			filter:     "asset_id = $1 AND amount > $2",
+			values:     []interface{}{"foo", 1000000},
+			wantQuery:  `SELECT block_height, tx_pos, output_index, tx_hash, output_id, type, purpose, asset_id, asset_alias, asset_definition, asset_tags, asset_local, amount, account_id, account_alias, account_tags, control_program, reference_data, local FROM "annotated_outputs" AS out WHERE (encode(out."asset_id", 'hex') = $1 AND out."amount" > $2) AND timespan @> $3::int8 ORDER BY block_height DESC, tx_pos DESC, output_index DESC LIMIT 10`,
+			wantValues: []interface{}{`foo`, 1000000, nowMillis},
+		},
 	}
 
 	for i, tc := range testCases {
@@ -3,6 +3,7 @@ package query_test
 import (
 	"context"
 	"encoding/json"
+	"math"
 	"testing"
 	"time"
 
@@ -15,18 +16,22 @@ import (
 	"chain/core/pin"
 	"chain/core/query"
 	"chain/core/query/filter"
+	"chain/core/txbuilder"
 	"chain/database/pg/pgtest"
+	"chain/errors"
 	"chain/protocol/bc"
 	"chain/protocol/prottest"
 	"chain/testutil"
 )
 
 func setupQueryTest(t *testing.T) (context.Context, *query.Indexer, time.Time, time.Time, string, string, bc.AssetID, bc.AssetID) {
-	time1 := time.Now()
-
 	_, db := pgtest.NewDB(t, pgtest.SchemaPath)
 	ctx := context.Background()
 	c := prottest.NewChain(t)
+
+	// time1 must be at or after the initial block's timestamp
+	// so that point-in-time queries have a block to refer to.
+	time1 := time.Now()
 	pinStore := pin.NewStore(db)
 	coretest.CreatePins(ctx, t, pinStore)
 	indexer := query.NewIndexer(db, c, pinStore)
@@ -173,6 +178,102 @@ func TestQueryOutputs(t *testing.T) {
 			when:   time2,
 			want:   []assetAccountAmount{},
 		},
+		{
+			filter: "amount > $1",
+			values: []interface{}{100},
+			when:   time2,
+			want: []assetAccountAmount{
+				{bc.AssetAmount{AssetId: &asset1, Amount: 867}, acct1},
+			},
+		},
+		{
+			filter: "amount >= 100 AND amount <= 867",
+			when:   time2,
+			want: []assetAccountAmount{
+				{bc.AssetAmount{AssetId: &asset2, Amount: 100}, acct1},
+				{bc.AssetAmount{AssetId: &asset1, Amount: 867}, acct1},
+			},
+		},
+		{
+			filter: "amount < 100",
+			when:   time2,
+			want:   []assetAccountAmount{},
+		},
+		{
+			filter: "asset_id = $1 AND amount < $2",
+			values: []interface{}{asset1.String(), 868},
+			when:   time2,
+			want: []assetAccountAmount{
+				{bc.AssetAmount{AssetId: &asset1, Amount: 867}, acct1},
+			},
+		},
+		{
+			filter: "asset_tags.currency MATCHES 'U*'",
+			when:   time2,
+			want: []assetAccountAmount{
+				{bc.AssetAmount{AssetId: &asset1, Amount: 867}, acct1},
+			},
+		},
+		{
+			filter: "asset_tags.message MATCHES '*ชาว*'",
+			when:   time2,
+			want: []assetAccountAmount{
+				{bc.AssetAmount{AssetId: &asset1, Amount: 867}, acct1},
+			},
+		},
+		{
+			filter: "asset_tags.currency MATCHES $1",
+			values: []interface{}{"*D"},
+			when:   time2,
+			want: []assetAccountAmount{
+				{bc.AssetAmount{AssetId: &asset1, Amount: 867}, acct1},
+			},
+		},
+		{
+			filter: "asset_tags.currency MATCHES $1",
+			values: []interface{}{`U\*`},
+			when:   time2,
+			want:   []assetAccountAmount{},
+		},
+		{
+			filter: "asset_id = $1 OR asset_id = $2",
+			values: []interface{}{asset1.String(), asset2.String()},
+			when:   time2,
+			want: []assetAccountAmount{
+				{bc.AssetAmount{AssetId: &asset2, Amount: 100}, acct1},
+				{bc.AssetAmount{AssetId: &asset1, Amount: 867}, acct1},
+			},
+		},
+		{
+			filter: "NOT asset_id = $1",
+			values: []interface{}{asset1.String()},
+			when:   time2,
+			want: []assetAccountAmount{
+				{bc.AssetAmount{AssetId: &asset2, Amount: 100}, acct1},
+			},
+		},
+		{
+			// asset2 has no currency tag
+			filter: "NOT asset_tags.currency = 'USD'",
+			when:   time2,
+			want: []assetAccountAmount{
+				{bc.AssetAmount{AssetId: &asset2, Amount: 100}, acct1},
+			},
+		},
+		{
+			filter: "account_id = $1 AND NOT (asset_id = $2 OR asset_tags.currency = 'USD')",
+			values: []interface{}{acct1, asset2.String()},
+			when:   time2,
+			want:   []assetAccountAmount{},
+		},
+		{
+			filter: "(account_id = $1 OR account_id = $2) AND NOT asset_id = $3",
+			values: []interface{}{acct1, acct2, asset2.String()},
+			when:   time2,
+			want: []assetAccountAmount{
+				{bc.AssetAmount{AssetId: &asset1, Amount: 867}, acct1},
+			},
+		},
 	}
 
 	for i, tc := range cases {
@@ -314,7 +415,11 @@ func TestQueryBalances(t *testing.T) {
 			fields = append(fields, f)
 		}
 
-		balances, err := indexer.Balances(ctx, tc.predicate, tc.values, fields, bc.Millis(tc.when))
+		height, err := indexer.BlockHeightAt(ctx, bc.Millis(tc.when))
+		if err != nil {
+			t.Fatal(err)
+		}
+		balances, err := indexer.Balances(ctx, tc.predicate, tc.values, fields, height)
 		if err != nil {
 			t.Fatal(err)
 		}
@@ -330,6 +435,180 @@ func TestQueryBalances(t *testing.T) {
 	}
 }
 
+func TestQueryBalancesByAlias(t *testing.T) {
+	_, db := pgtest.NewDB(t, pgtest.SchemaPath)
+	ctx := context.Background()
+	c := prottest.NewChain(t)
+	pinStore := pin.NewStore(db)
+	coretest.CreatePins(ctx, t, pinStore)
+	indexer := query.NewIndexer(db, c, pinStore)
+	accounts := account.NewManager(db, c, pinStore)
+	assets := asset.NewRegistry(db, c, pinStore)
+	assets.IndexAssets(indexer)
+	indexer.RegisterAnnotator(accounts.AnnotateTxs)
+	indexer.RegisterAnnotator(assets.AnnotateTxs)
+	go assets.ProcessBlocks(ctx)
+	go accounts.ProcessBlocks(ctx)
+	go indexer.ProcessBlocks(ctx)
+
+	alice := coretest.CreateAccount(ctx, t, accounts, "alice", nil)
+	anon := coretest.CreateAccount(ctx, t, accounts, "", nil)
+	gold := coretest.CreateAsset(ctx, t, assets, nil, "gold", nil)
+	silver := coretest.CreateAsset(ctx, t, assets, nil, "silver", nil)
+	plain1 := coretest.CreateAsset(ctx, t, assets, nil, "", nil)
+	plain2 := coretest.CreateAsset(ctx, t, assets, nil, "", nil)
+
+	g := generator.New(c, nil, db)
+	coretest.IssueAssets(ctx, t, c, g, assets, accounts, gold, 1, alice)
+	coretest.IssueAssets(ctx, t, c, g, assets, accounts, gold, 2, anon)
+	coretest.IssueAssets(ctx, t, c, g, assets, accounts, silver, 10, alice)
+	coretest.IssueAssets(ctx, t, c, g, assets, accounts, plain1, 100, alice)
+	coretest.IssueAssets(ctx, t, c, g, assets, accounts, plain2, 1000, anon)
+	prottest.MakeBlock(t, c, g.PendingTxs())
+	<-pinStore.PinWaiter(query.TxPinName, c.Height())
+
+	cases := []struct {
+		sumBy []string
+		want  string
+	}{{
+		sumBy: []string{"asset_alias"},
+		want: `[
+			{"sum_by": {"asset_alias": "gold"}, "amount": 3},
+			{"sum_by": {"asset_alias": "silver"}, "amount": 10},
+			{"sum_by": {"asset_alias": null}, "amount": 1100}
+		]`,
+	}, {
+		sumBy: []string{"account_alias"},
+		want: `[
+			{"sum_by": {"account_alias": "alice"}, "amount": 111},
+			{"sum_by": {"account_alias": null}, "amount": 1002}
+		]`,
+	}, {
+		sumBy: []string{"account_alias", "asset_alias"},
+		want: `[
+			{"sum_by": {"account_alias": "alice", "asset_alias": "gold"}, "amount": 1},
+			{"sum_by": {"account_alias": "alice", "asset_alias": "silver"}, "amount": 10},
+			{"sum_by": {"account_alias": "alice", "asset_alias": null}, "amount": 100},
+			{"sum_by": {"account_alias": null, "asset_alias": "gold"}, "amount": 2},
+			{"sum_by": {"account_alias": null, "asset_alias": null}, "amount": 1000}
+		]`,
+	}}
+
+	for _, tc := range cases {
+		var want []interface{}
+		err := json.Unmarshal([]byte(tc.want), &want)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var fields []filter.Field
+		for _, s := range tc.sumBy {
+			f, err := filter.ParseField(s)
+			if err != nil {
+				t.Fatal(err)
+			}
+			fields = append(fields, f)
+		}
+		balances, err := indexer.Balances(ctx, "", nil, fields, c.Height())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// Groups come back in no particular order.
+		got := jsonRT(t, balances).([]interface{})
+		if len(got) != len(want) {
+			t.Errorf("sum_by %v: got %d groups, want %d:\n%s", tc.sumBy, len(got), len(want), spew.Sdump(got))
+			continue
+		}
+		for _, w := range want {
+			var found bool
+			for _, g := range got {
+				if testutil.DeepEqual(g, w) {
+					found = true
+					break
+				}
+			}
+			if !found {
+				t.Errorf("sum_by %v: missing group %v in:\n%s", tc.sumBy, w, spew.Sdump(got))
+			}
+		}
+	}
+}
+
+func TestQueryHistoricalBalances(t *testing.T) {
+	_, db := pgtest.NewDB(t, pgtest.SchemaPath)
+	ctx := context.Background()
+	c := prottest.NewChain(t)
+	pinStore := pin.NewStore(db)
+	coretest.CreatePins(ctx, t, pinStore)
+	indexer := query.NewIndexer(db, c, pinStore)
+	accounts := account.NewManager(db, c, pinStore)
+	assets := asset.NewRegistry(db, c, pinStore)
+	indexer.RegisterAnnotator(accounts.AnnotateTxs)
+	indexer.RegisterAnnotator(assets.AnnotateTxs)
+	go assets.ProcessBlocks(ctx)
+	go accounts.ProcessBlocks(ctx)
+	go indexer.ProcessBlocks(ctx)
+
+	acct1 := coretest.CreateAccount(ctx, t, accounts, "", nil)
+	acct2 := coretest.CreateAccount(ctx, t, accounts, "", nil)
+	asset1 := coretest.CreateAsset(ctx, t, assets, nil, "", nil)
+	g := generator.New(c, nil, db)
+
+	// Block 2 issues 100 units to acct1.
+	coretest.IssueAssets(ctx, t, c, g, assets, accounts, asset1, 100, acct1)
+	issueBlock := prottest.MakeBlock(t, c, g.PendingTxs())
+	<-pinStore.AllWaiter(issueBlock.Height)
+
+	// Block 3 transfers 30 units from acct1 to acct2.
+	coretest.Transfer(ctx, t, c, g, []txbuilder.Action{
+		accounts.NewSpendAction(bc.AssetAmount{AssetId: &asset1, Amount: 30}, acct1, nil, nil),
+		accounts.NewControlAction(bc.AssetAmount{AssetId: &asset1, Amount: 30}, acct2, nil),
+	})
+	transferBlock := prottest.MakeBlock(t, c, g.PendingTxs())
+	<-pinStore.AllWaiter(transferBlock.Height)
+
+	initialBlock := prottest.Initial(t, c)
+	cases := []struct {
+		timestampMS uint64
+		acct1       uint64
+		acct2       uint64
+	}{
+		{timestampMS: initialBlock.TimestampMS, acct1: 0, acct2: 0},
+		{timestampMS: issueBlock.TimestampMS, acct1: 100, acct2: 0},
+		{timestampMS: transferBlock.TimestampMS, acct1: 70, acct2: 30},
+	}
+	for i, tc := range cases {
+		height, err := indexer.BlockHeightAt(ctx, tc.timestampMS)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, want := range []struct {
+			accountID string
+			amount    uint64
+		}{{acct1, tc.acct1}, {acct2, tc.acct2}} {
+			balances, err := indexer.Balances(ctx, "account_id = $1", []interface{}{want.accountID}, nil, height)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := jsonRT(t, balances)
+			wantJSON := jsonRT(t, []interface{}{map[string]interface{}{"amount": want.amount}})
+			if !testutil.DeepEqual(got, wantJSON) {
+				t.Errorf("case %d: account %s got balances %v, want %v", i, want.accountID, got, wantJSON)
+			}
+		}
+	}
+
+	_, err := indexer.BlockHeightAt(ctx, initialBlock.TimestampMS-1)
+	if errors.Root(err) != query.ErrTimestampBeforeInitialBlock {
+		t.Errorf("before initial block: got error %v, want %v", err, query.ErrTimestampBeforeInitialBlock)
+	}
+	_, err = indexer.BlockHeightAt(ctx, bc.Millis(time.Now().Add(time.Hour)))
+	if errors.Root(err) != query.ErrTimestampInFuture {
+		t.Errorf("future timestamp: got error %v, want %v", err, query.ErrTimestampInFuture)
+	}
+}
+
 // jsonRT does a JSON round trip -- it marshals v
 // then unmarshals the resutling JSON into an interface{}.
 // This normalizes the types so it can be more easily compared
@@ -346,3 +625,75 @@ func jsonRT(tb testing.TB, v interface{}) interface{} {
 	}
 	return x
 }
+
+func TestTransactionsStablePaging(t *testing.T) {
+	_, db := pgtest.NewDB(t, pgtest.SchemaPath)
+	ctx := context.Background()
+	c := prottest.NewChain(t)
+	pinStore := pin.NewStore(db)
+	coretest.CreatePins(ctx, t, pinStore)
+	indexer := query.NewIndexer(db, c, pinStore)
+	accounts := account.NewManager(db, c, pinStore)
+	assets := asset.NewRegistry(db, c, pinStore)
+	indexer.RegisterAnnotator(accounts.AnnotateTxs)
+	indexer.RegisterAnnotator(assets.AnnotateTxs)
+	go assets.ProcessBlocks(ctx)
+	go accounts.ProcessBlocks(ctx)
+	go indexer.ProcessBlocks(ctx)
+
+	acct := coretest.CreateAccount(ctx, t, accounts, "", nil)
+	asset1 := coretest.CreateAsset(ctx, t, assets, nil, "", nil)
+	g := generator.New(c, nil, db)
+
+	issueBlock := func(n int) map[bc.Hash]bool {
+		for i := 0; i < n; i++ {
+			coretest.IssueAssets(ctx, t, c, g, assets, accounts, asset1, 1, acct)
+		}
+		ids := make(map[bc.Hash]bool)
+		txs := g.PendingTxs()
+		for _, tx := range txs {
+			ids[tx.ID] = true
+		}
+		<-pinStore.PinWaiter(query.TxPinName, prottest.MakeBlock(t, c, txs).Height)
+		return ids
+	}
+
+	want := issueBlock(3)
+	for id := range issueBlock(2) {
+		want[id] = true
+	}
+
+	after, err := indexer.LookupTxAfter(ctx, 0, math.MaxInt64)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := make(map[bc.Hash]bool)
+	for page := 0; ; page++ {
+		txs, next, err := indexer.Transactions(ctx, "", nil, "", after, 2, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(txs) == 0 {
+			break
+		}
+		for _, tx := range txs {
+			if got[tx.ID] {
+				t.Errorf("page %d: transaction %x repeated", page, tx.ID.Bytes())
+			}
+			got[tx.ID] = true
+		}
+
+		// Index more transactions between pages; they
+		// shouldn't show up in this list.
+		issueBlock(1)
+
+		// Pages are requested with an encoded cursor.
+		after, err = query.DecodeTxAfter(next.String())
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if !testutil.DeepEqual(got, want) {
+		t.Errorf("paged transactions = %v, want %v", got, want)
+	}
+}
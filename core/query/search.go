@@ -0,0 +1,106 @@
+package query
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"chain/errors"
+)
+
+// ErrTextSearchDisabled is returned by queries with a text
+// search when the indexer was not configured to support them.
+var ErrTextSearchDisabled = errors.New("text search is disabled")
+
+// EnableTextSearch configures the indexer to build a text
+// search index over reference data and asset definitions.
+// Transactions and assets indexed before it is enabled are
+// not searchable.
+func (ind *Indexer) EnableTextSearch() {
+	ind.textSearch = true
+}
+
+// textSearchExpr adds a condition to the SQL expression expr
+// that matches rows in the table with alias tableAlias
+// containing every word in text.
+func (ind *Indexer) textSearchExpr(expr, tableAlias string, vals []interface{}, text string) (string, []interface{}, error) {
+	if text == "" {
+		return expr, vals, nil
+	}
+	if !ind.textSearch {
+		return "", nil, errors.WithDetail(ErrTextSearchDisabled, "the core was not configured to index text")
+	}
+	vals = append(vals, text)
+	cond := fmt.Sprintf("%s.search @@ plainto_tsquery('simple', $%d)", tableAlias, len(vals))
+	if expr == "" {
+		return cond, vals, nil
+	}
+	return "(" + expr + ") AND " + cond, vals, nil
+}
+
+// searchText returns the text to index for the given JSON
+// documents, or null if text search is disabled.
+func (ind *Indexer) searchText(docs ...*json.RawMessage) sql.NullString {
+	if !ind.textSearch {
+		return sql.NullString{}
+	}
+	var words []string
+	for _, doc := range docs {
+		if doc == nil {
+			continue
+		}
+		dec := json.NewDecoder(bytes.NewReader(*doc))
+		dec.UseNumber()
+		var v interface{}
+		if dec.Decode(&v) == nil {
+			words = appendJSONWords(words, v)
+		}
+	}
+	return sql.NullString{String: strings.Join(words, " "), Valid: true}
+}
+
+// appendJSONWords appends the string and number values
+// in v to words. Object keys are not included.
+func appendJSONWords(words []string, v interface{}) []string {
+	switch v := v.(type) {
+	case string:
+		words = append(words, strings.Map(textRune, v))
+	case json.Number:
+		words = append(words, v.String())
+	case []interface{}:
+		for _, elem := range v {
+			words = appendJSONWords(words, elem)
+		}
+	case map[string]interface{}:
+		for _, elem := range v {
+			words = appendJSONWords(words, elem)
+		}
+	}
+	return words
+}
+
+// textRune replaces characters Postgres can't store
+// in a text value with spaces.
+func textRune(r rune) rune {
+	if r == 0 || r == utf8.RuneError {
+		return ' '
+	}
+	return r
+}
+
+// txSearchText returns the text to index for tx: its
+// reference data, and the reference data and asset
+// definitions of its inputs and outputs.
+func (ind *Indexer) txSearchText(tx *AnnotatedTx) sql.NullString {
+	docs := []*json.RawMessage{tx.ReferenceData}
+	for _, in := range tx.Inputs {
+		docs = append(docs, in.ReferenceData, in.AssetDefinition)
+	}
+	for _, out := range tx.Outputs {
+		docs = append(docs, out.ReferenceData, out.AssetDefinition)
+	}
+	return ind.searchText(docs...)
+}
@@ -0,0 +1,138 @@
+package query
+
+import (
+	"context"
+	"math"
+	"sort"
+	"testing"
+
+	"chain/database/pg/pgtest"
+	"chain/errors"
+	"chain/protocol/bc"
+	"chain/protocol/bc/bctest"
+	"chain/protocol/bc/legacy"
+	"chain/protocol/prottest"
+	"chain/testutil"
+)
+
+func TestTextSearchTransactions(t *testing.T) {
+	ctx := context.Background()
+	c := prottest.NewChain(t)
+	indexer := NewIndexer(pgtest.NewTx(t), c, nil)
+	indexer.EnableTextSearch()
+
+	refData := []string{
+		`{"invoice": "INV-1001", "note": "paid in full"}`,
+		`{"invoice": "INV-1002", "note": "partial payment"}`,
+		`{"memo": {"lines": ["paid", "INV-1003"]}, "count": 7}`,
+	}
+	b := &legacy.Block{BlockHeader: legacy.BlockHeader{Height: 1}}
+	for _, rd := range refData {
+		rd := rd
+		b.Transactions = append(b.Transactions, bctest.NewIssuanceTx(t, prottest.Initial(t, c).Hash(), func(tx *legacy.Tx) {
+			tx.ReferenceData = []byte(rd)
+		}))
+	}
+	_, err := indexer.insertAnnotatedTxs(ctx, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	all := TxAfter{FromBlockHeight: math.MaxInt64, FromPosition: math.MaxInt32}
+	cases := []struct {
+		text   string
+		filter string
+		want   []int // positions in b
+	}{
+		{text: "INV-1002", want: []int{1}},
+		{text: "inv-1003", want: []int{2}},
+		{text: "paid", want: []int{0, 2}},
+		{text: "7", want: []int{2}},
+		{text: "paid full", want: []int{0}},
+		{text: "paid INV-1002", want: nil},
+		{text: "invoice", want: nil}, // keys aren't indexed
+		{text: "paid", filter: "reference_data.invoice = 'INV-1001'", want: []int{0}},
+	}
+	for _, tc := range cases {
+		txs, _, err := indexer.Transactions(ctx, tc.filter, nil, tc.text, all, 100, false)
+		if err != nil {
+			t.Errorf("Transactions(%q, %q) error: %s", tc.filter, tc.text, err)
+			continue
+		}
+		var got []int
+		for _, tx := range txs {
+			got = append(got, int(tx.Position))
+		}
+		sort.Ints(got)
+		if !testutil.DeepEqual(got, tc.want) {
+			t.Errorf("Transactions(%q, %q) positions = %v want %v", tc.filter, tc.text, got, tc.want)
+		}
+	}
+}
+
+func TestTextSearchAssets(t *testing.T) {
+	ctx := context.Background()
+	indexer := NewIndexer(pgtest.NewTx(t), prottest.NewChain(t), nil)
+	indexer.EnableTextSearch()
+
+	defs := []string{
+		`{"name": "Gold bullion", "issuer": "Acme"}`,
+		`{"name": "Silver bullion", "issuer": "Acme"}`,
+		`{}`,
+	}
+	for i, def := range defs {
+		asset := &AnnotatedAsset{
+			ID:         bc.NewAssetID([32]byte{byte(i)}),
+			Definition: raw(def),
+			Tags:       raw(`{}`),
+		}
+		err := indexer.SaveAnnotatedAsset(ctx, asset, string(rune('a'+i)))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cases := []struct {
+		text string
+		want []bc.AssetID
+	}{
+		{"bullion", []bc.AssetID{bc.NewAssetID([32]byte{1}), bc.NewAssetID([32]byte{0})}},
+		{"gold bullion", []bc.AssetID{bc.NewAssetID([32]byte{0})}},
+		{"gold silver", nil},
+	}
+	for _, tc := range cases {
+		assets, _, err := indexer.Assets(ctx, "", nil, tc.text, "", 100)
+		if err != nil {
+			t.Errorf("Assets(%q) error: %s", tc.text, err)
+			continue
+		}
+		var got []bc.AssetID
+		for _, a := range assets {
+			got = append(got, a.ID)
+		}
+		if !testutil.DeepEqual(got, tc.want) {
+			t.Errorf("Assets(%q) = %v want %v", tc.text, got, tc.want)
+		}
+	}
+}
+
+func TestTextSearchDisabled(t *testing.T) {
+	ctx := context.Background()
+	indexer := NewIndexer(pgtest.NewTx(t), prottest.NewChain(t), nil)
+
+	all := TxAfter{FromBlockHeight: math.MaxInt64, FromPosition: math.MaxInt32}
+	_, _, err := indexer.Transactions(ctx, "", nil, "paid", all, 100, false)
+	if errors.Root(err) != ErrTextSearchDisabled {
+		t.Errorf("Transactions error = %v want %v", err, ErrTextSearchDisabled)
+	}
+	_, _, err = indexer.Assets(ctx, "", nil, "gold", "", 100)
+	if errors.Root(err) != ErrTextSearchDisabled {
+		t.Errorf("Assets error = %v want %v", err, ErrTextSearchDisabled)
+	}
+
+	// Queries without text still work.
+	_, _, err = indexer.Transactions(ctx, "", nil, "", all, 100, false)
+	if err != nil {
+		t.Error(err)
+	}
+}
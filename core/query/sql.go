@@ -1,7 +1,10 @@
 package query
 
 import (
+	"context"
+
 	"chain/core/query/filter"
+	"chain/log"
 )
 
 var (
@@ -91,3 +94,11 @@ var (
 		},
 	}
 )
+
+// warnUnindexed logs a warning if the database
+// can't use an index to evaluate p.
+func warnUnindexed(ctx context.Context, p filter.Predicate) {
+	if p.HasPatternMatch() {
+		log.Printkv(ctx, "warning", "filter uses MATCHES, which cannot use an index", "filter", p.String())
+	}
+}
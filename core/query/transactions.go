@@ -56,14 +56,18 @@ func ValidateTransactionFilter(filt string) error {
 }
 
 // LookupTxAfter looks up the transaction `after` for the provided time range.
+// Only blocks the indexer has finished with are included. Later
+// pages of a descending list only go below the cursor, so the list
+// doesn't change as it's paged through.
 func (ind *Indexer) LookupTxAfter(ctx context.Context, begin, end uint64) (TxAfter, error) {
 	const q = `
 		SELECT COALESCE(MAX(height), 0), COALESCE(MIN(height), 0) FROM query_blocks
 		WHERE timestamp >= $1 AND timestamp <= $2
+		AND height <= COALESCE((SELECT height FROM block_processors WHERE name = $3), $4)
 	`
 
 	var from, stop uint64
-	err := ind.db.QueryRowContext(ctx, q, begin, end).Scan(&from, &stop)
+	err := ind.db.QueryRowContext(ctx, q, begin, end, TxPinName, int64(math.MaxInt64)).Scan(&from, &stop)
 	if err != nil {
 		return TxAfter{}, errors.Wrap(err, "querying `query_blocks`")
 	}
@@ -75,12 +79,15 @@ func (ind *Indexer) LookupTxAfter(ctx context.Context, begin, end uint64) (TxAft
 }
 
 // Transactions queries the blockchain for transactions matching the
-// filter predicate `filt`.
-func (ind *Indexer) Transactions(ctx context.Context, filt string, vals []interface{}, after TxAfter, limit int, asc bool) ([]*AnnotatedTx, *TxAfter, error) {
+// filter predicate `filt`. If text is not empty, only transactions
+// whose reference data or asset definitions contain every word in
+// text are included.
+func (ind *Indexer) Transactions(ctx context.Context, filt string, vals []interface{}, text string, after TxAfter, limit int, asc bool) ([]*AnnotatedTx, *TxAfter, error) {
 	p, err := filter.Parse(filt, transactionsTable, vals)
 	if err != nil {
 		return nil, nil, err
 	}
+	warnUnindexed(ctx, p)
 	if len(vals) != p.Parameters {
 		return nil, nil, ErrParameterCountMismatch
 	}
@@ -88,6 +95,10 @@ func (ind *Indexer) Transactions(ctx context.Context, filt string, vals []interf
 	if err != nil {
 		return nil, nil, errors.Wrap(err, "converting to SQL")
 	}
+	expr, vals, err = ind.textSearchExpr(expr, transactionsTable.Alias, vals, text)
+	if err != nil {
+		return nil, nil, err
+	}
 
 	queryStr, queryArgs := constructTransactionsQuery(expr, vals, after, asc, limit)
 
@@ -108,8 +119,9 @@ func constructTransactionsQuery(expr string, vals []interface{}, after TxAfter,
 
 	// add filter conditions
 	if len(expr) > 0 {
+		buf.WriteString("(")
 		buf.WriteString(expr)
-		buf.WriteString(" AND ")
+		buf.WriteString(") AND ")
 	}
 
 	if asc {
@@ -79,9 +79,9 @@ func TestConstructTransactionsQuery(t *testing.T) {
 			values: []interface{}{"abc"},
 			after:  TxAfter{FromBlockHeight: 205, FromPosition: 35, StopBlockHeight: 100},
 			asc:    false,
-			wantQuery: `SELECT block_height, tx_pos, data FROM annotated_txs AS txs WHERE 
+			wantQuery: `SELECT block_height, tx_pos, data FROM annotated_txs AS txs WHERE (
 EXISTS(SELECT 1 FROM annotated_inputs AS inp WHERE inp."tx_hash" = txs."tx_hash" AND (inp."type" = 'issue' AND encode(inp."asset_id", 'hex') = $1))
- AND (txs.block_height, txs.tx_pos) < ($2, $3) AND txs.block_height >= $4 ORDER BY txs.block_height DESC, txs.tx_pos DESC LIMIT 100`,
+) AND (txs.block_height, txs.tx_pos) < ($2, $3) AND txs.block_height >= $4 ORDER BY txs.block_height DESC, txs.tx_pos DESC LIMIT 100`,
 			wantValues: []interface{}{
 				`abc`, uint64(205), uint32(35), uint64(100),
 			},
@@ -91,9 +91,9 @@ EXISTS(SELECT 1 FROM annotated_inputs AS inp WHERE inp."tx_hash" = txs."tx_hash"
 			values: []interface{}{"acc123", "corp"},
 			after:  TxAfter{FromBlockHeight: 2, FromPosition: 20, StopBlockHeight: 1},
 			asc:    false,
-			wantQuery: `SELECT block_height, tx_pos, data FROM annotated_txs AS txs WHERE 
+			wantQuery: `SELECT block_height, tx_pos, data FROM annotated_txs AS txs WHERE (
 EXISTS(SELECT 1 FROM annotated_outputs AS out WHERE out."tx_hash" = txs."tx_hash" AND (out."account_id" = $1 OR (out."reference_data"->>'corporate') = $2))
- AND (txs.block_height, txs.tx_pos) < ($3, $4) AND txs.block_height >= $5 ORDER BY txs.block_height DESC, txs.tx_pos DESC LIMIT 100`,
+) AND (txs.block_height, txs.tx_pos) < ($3, $4) AND txs.block_height >= $5 ORDER BY txs.block_height DESC, txs.tx_pos DESC LIMIT 100`,
 			wantValues: []interface{}{
 				`acc123`, `corp`, uint64(2), uint32(20), uint64(1),
 			},
@@ -103,9 +103,9 @@ EXISTS(SELECT 1 FROM annotated_outputs AS out WHERE out."tx_hash" = txs."tx_hash
 			values: []interface{}{"acc123", "corp"},
 			after:  TxAfter{FromBlockHeight: 2, FromPosition: 20, StopBlockHeight: 1},
 			asc:    true,
-			wantQuery: `SELECT block_height, tx_pos, data FROM annotated_txs AS txs WHERE 
+			wantQuery: `SELECT block_height, tx_pos, data FROM annotated_txs AS txs WHERE (
 EXISTS(SELECT 1 FROM annotated_outputs AS out WHERE out."tx_hash" = txs."tx_hash" AND (out."account_id" = $1 OR (out."reference_data"->>'corporate') = $2))
- AND (txs.block_height, txs.tx_pos) > ($3, $4) AND txs.block_height <= $5 ORDER BY txs.block_height ASC, txs.tx_pos ASC LIMIT 100`,
+) AND (txs.block_height, txs.tx_pos) > ($3, $4) AND txs.block_height <= $5 ORDER BY txs.block_height ASC, txs.tx_pos ASC LIMIT 100`,
 			wantValues: []interface{}{
 				`acc123`, `corp`, uint64(2), uint32(20), uint64(1),
 			},
@@ -5,6 +5,7 @@ import (
 	"sync"
 	"time"
 
+	"chain/core/txbuilder"
 	"chain/net/http/reqid"
 )
 
@@ -36,3 +37,11 @@ func (a *API) createAccountReceiver(ctx context.Context, ins []struct {
 	wg.Wait()
 	return responses
 }
+
+// POST /list-account-receivers
+func (a *API) listAccountReceivers(ctx context.Context, in struct {
+	AccountID    string `json:"account_id"`
+	AccountAlias string `json:"account_alias"`
+}) ([]*txbuilder.Receiver, error) {
+	return a.accounts.ListReceivers(ctx, in.AccountID, in.AccountAlias)
+}
@@ -0,0 +1,168 @@
+package core
+
+import (
+	"context"
+
+	"chain/core/account"
+	"chain/core/leader"
+	"chain/core/query"
+	"chain/errors"
+	"chain/log"
+	"chain/protocol"
+)
+
+var errRecoveryRunning = errors.New("a recovery is already running")
+
+// recoveryProgress describes the most recent run of /recover.
+type recoveryProgress struct {
+	// Stage is "snapshot", "account_utxos", "query_index" or
+	// "done".
+	Stage string `json:"stage"`
+
+	// BlockHeight is the height the account UTXOs and query index
+	// are being rebuilt through, once it's known.
+	BlockHeight uint64 `json:"block_height,omitempty"`
+
+	Done  bool   `json:"done"`
+	Error string `json:"error,omitempty"`
+}
+
+// POST /recover
+//
+// recoverState rebuilds the data derived from the blockchain from
+// the stored blocks alone, for recovering from corrupt tables. It
+// replays every block through validation to rebuild the state
+// snapshot, then truncates and rebuilds the account UTXOs and, if
+// transaction indexing is enabled, the query index.
+//
+// The recovery runs in the background on the leader. recoverState
+// returns once it has started; its progress is reported by
+// /recover-progress.
+//
+// If resume is set, it picks up the snapshot and the query index
+// where an interrupted recovery left them instead of starting over.
+// Account UTXOs are always rebuilt in full.
+func (a *API) recoverState(ctx context.Context, in struct {
+	Resume bool `json:"resume"`
+}) (*recoveryProgress, error) {
+	if a.leader.State() == leader.Following {
+		var resp *recoveryProgress
+		err := a.forwardToLeader(ctx, "/recover", in, &resp)
+		return resp, err
+	}
+
+	leadCtx := a.leaderContext()
+	if leadCtx == nil {
+		return nil, errors.Wrap(leader.ErrNoLeader)
+	}
+
+	a.recoveryMu.Lock()
+	defer a.recoveryMu.Unlock()
+	if a.recovery != nil && !a.recovery.Done {
+		return nil, errors.Wrap(errRecoveryRunning)
+	}
+	a.recovery = &recoveryProgress{Stage: "snapshot"}
+	p := *a.recovery
+
+	go func() {
+		err := a.runRecovery(leadCtx, in.Resume)
+		if err != nil {
+			log.Error(leadCtx, err, "at", "recovering")
+		}
+		a.recoveryMu.Lock()
+		defer a.recoveryMu.Unlock()
+		a.recovery.Done = true
+		if err != nil {
+			a.recovery.Error = err.Error()
+		} else {
+			a.recovery.Stage = "done"
+		}
+	}()
+	return &p, nil
+}
+
+// POST /recover-progress
+func (a *API) recoverProgress(ctx context.Context) (*recoveryProgress, error) {
+	if a.leader.State() == leader.Following {
+		var resp *recoveryProgress
+		err := a.forwardToLeader(ctx, "/recover-progress", nil, &resp)
+		return resp, err
+	}
+	a.recoveryMu.Lock()
+	defer a.recoveryMu.Unlock()
+	if a.recovery == nil {
+		return nil, nil
+	}
+	p := *a.recovery
+	return &p, nil
+}
+
+func (a *API) setRecoveryStage(stage string, height uint64) {
+	a.recoveryMu.Lock()
+	defer a.recoveryMu.Unlock()
+	a.recovery.Stage = stage
+	a.recovery.BlockHeight = height
+}
+
+func (a *API) runRecovery(ctx context.Context, resume bool) error {
+	_, _, err := protocol.RebuildSnapshot(ctx, a.store, resume)
+	if err != nil {
+		return errors.Wrap(err, "rebuilding snapshot")
+	}
+
+	// Stop the block processors that write the tables being
+	// rebuilt, and rebuild them through the heights they reached.
+	// The delete-spents processor waits on the other two, so it
+	// goes first.
+	pins := []string{account.DeleteSpentsPinName, account.PinName}
+	if a.indexTxs {
+		pins = append(pins, query.TxPinName)
+	}
+	height, resumePins := a.pinStore.Pause(pins...)
+
+	err = a.rebuild(ctx, height, resume)
+	if err != nil {
+		// Leave the pins where they were, to process again
+		// whatever the rebuild didn't finish.
+		height = 0
+	}
+	perr := resumePins(ctx, height)
+	if err != nil {
+		return err
+	}
+	return errors.Wrap(perr, "resuming block processors")
+}
+
+func (a *API) rebuild(ctx context.Context, height uint64, resume bool) error {
+	a.setRecoveryStage("account_utxos", height)
+	err := a.accounts.RebuildUTXOs(ctx, height)
+	if err != nil {
+		return errors.Wrap(err, "rebuilding account utxos")
+	}
+	if !a.indexTxs {
+		return nil
+	}
+	a.setRecoveryStage("query_index", height)
+	return errors.Wrap(a.rebuildIndex(ctx, height, resume), "rebuilding query index")
+}
+
+func (a *API) rebuildIndex(ctx context.Context, height uint64, resume bool) error {
+	if resume {
+		p, err := a.indexer.BackfillProgress(ctx)
+		if err != nil {
+			return err
+		}
+		if p != nil && p.FromHeight == 1 && !p.Done() {
+			err = a.indexer.Backfill(ctx, 1, p.ToHeight)
+			if err != nil || p.ToHeight >= height {
+				return err
+			}
+			return a.indexer.Backfill(ctx, p.ToHeight+1, height)
+		}
+	}
+	err := a.indexer.Reset(ctx)
+	if err != nil || height == 0 {
+		return err
+	}
+	return a.indexer.Backfill(ctx, 1, height)
+}
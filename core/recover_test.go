@@ -0,0 +1,124 @@
+package core
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"chain/core/account"
+	"chain/core/asset"
+	"chain/core/coretest"
+	"chain/core/generator"
+	"chain/core/pin"
+	"chain/core/query"
+	"chain/core/txbuilder"
+	"chain/core/txdb"
+	"chain/database/pg/pgtest"
+	"chain/protocol/bc"
+	"chain/protocol/prottest"
+	"chain/testutil"
+)
+
+func TestRecoverState(t *testing.T) {
+	ctx := context.Background()
+	_, db := pgtest.NewDB(t, pgtest.SchemaPath)
+	store := txdb.NewStore(db)
+	c := prottest.NewChain(t, prottest.WithStore(store))
+	g := generator.New(c, nil, db)
+	pinStore := pin.NewStore(db)
+	coretest.CreatePins(ctx, t, pinStore)
+	indexer := query.NewIndexer(db, c, pinStore)
+	accounts := account.NewManager(db, c, pinStore)
+	assets := asset.NewRegistry(db, c, pinStore)
+	indexer.RegisterAnnotator(accounts.AnnotateTxs)
+	indexer.RegisterAnnotator(assets.AnnotateTxs)
+	go assets.ProcessBlocks(ctx)
+	go accounts.ProcessBlocks(ctx)
+	go indexer.ProcessBlocks(ctx)
+
+	acct1 := coretest.CreateAccount(ctx, t, accounts, "", nil)
+	acct2 := coretest.CreateAccount(ctx, t, accounts, "", nil)
+	asset1 := coretest.CreateAsset(ctx, t, assets, nil, "", nil)
+	coretest.IssueAssets(ctx, t, c, g, assets, accounts, asset1, 100, acct1)
+	<-pinStore.AllWaiter(prottest.MakeBlock(t, c, g.PendingTxs()).Height)
+	coretest.Transfer(ctx, t, c, g, []txbuilder.Action{
+		accounts.NewSpendAction(bc.AssetAmount{AssetId: &asset1, Amount: 30}, acct1, nil, nil),
+		accounts.NewControlAction(bc.AssetAmount{AssetId: &asset1, Amount: 30}, acct2, nil),
+	})
+	<-pinStore.AllWaiter(prottest.MakeBlock(t, c, g.PendingTxs()).Height)
+
+	api := &API{
+		db:       db,
+		chain:    c,
+		store:    store,
+		pinStore: pinStore,
+		accounts: accounts,
+		indexer:  indexer,
+		indexTxs: true,
+		leader:   alwaysLeader{},
+		leadCtx:  ctx,
+	}
+	balances := func() interface{} {
+		p, err := api.listBalances(ctx, requestQuery{SumBy: []string{"account_id"}})
+		if err != nil {
+			testutil.FatalErr(t, err)
+		}
+		return p.Items
+	}
+	utxos := func() (n int) {
+		err := db.QueryRowContext(ctx, `SELECT count(*) FROM account_utxos`).Scan(&n)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return n
+	}
+	wantBalances, wantUTXOs := balances(), utxos()
+
+	_, err := db.ExecContext(ctx, `
+		UPDATE annotated_outputs SET amount = amount * 2;
+		DELETE FROM account_utxos;
+		DELETE FROM snapshots;
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = api.recoverState(ctx, struct {
+		Resume bool `json:"resume"`
+	}{})
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	// Wait for the recovery to finish.
+	var p *recoveryProgress
+	for {
+		p, err = api.recoverProgress(ctx)
+		if err != nil {
+			testutil.FatalErr(t, err)
+		}
+		if p.Done {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if p.Error != "" || p.Stage != "done" {
+		t.Fatalf("recovery finished in stage %q with error %q", p.Stage, p.Error)
+	}
+	if p.BlockHeight != c.Height() {
+		t.Errorf("recovered height = %d want %d", p.BlockHeight, c.Height())
+	}
+	if got := balances(); !testutil.DeepEqual(got, wantBalances) {
+		t.Errorf("balances after recover = %v want %v", got, wantBalances)
+	}
+	if got := utxos(); got != wantUTXOs {
+		t.Errorf("count(account_utxos) after recover = %d want %d", got, wantUTXOs)
+	}
+	snapshot, height, err := store.LatestSnapshot(ctx)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	_, want := c.State()
+	if height != c.Height() || snapshot.Tree.RootHash() != want.Tree.RootHash() {
+		t.Errorf("saved snapshot at height %d, want height %d with the chain's state root", height, c.Height())
+	}
+}
@@ -67,10 +67,6 @@ func TestRecovery(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
-	err = pinStore.CreatePin(ctx, account.ExpirePinName, pinHeight)
-	if err != nil {
-		t.Fatal(err)
-	}
 	err = pinStore.CreatePin(ctx, account.DeleteSpentsPinName, pinHeight)
 	if err != nil {
 		t.Fatal(err)
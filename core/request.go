@@ -2,6 +2,7 @@ package core
 
 import (
 	"context"
+	"time"
 
 	"chain/encoding/json"
 	"chain/errors"
@@ -12,17 +13,24 @@ var (
 	errBadActionType = errors.New("bad action type")
 	errBadAlias      = errors.New("bad alias")
 	errBadAction     = errors.New("bad action object")
+	errBadTTL        = errors.New("bad ttl")
+	errBadTimeRange  = errors.New("bad time range")
 )
 
 type buildRequest struct {
 	Tx      *legacy.TxData           `json:"base_transaction"`
 	Actions []map[string]interface{} `json:"actions"`
 	TTL     json.Duration            `json:"ttl"`
+
+	// MinTime and MaxTime, if set, bound the time range
+	// in which the transaction can be included in a block.
+	MinTime time.Time `json:"min_time"`
+	MaxTime time.Time `json:"max_time"`
 }
 
 func (a *API) filterAliases(ctx context.Context, br *buildRequest) error {
 	for i, m := range br.Actions {
-		id, _ := m["assset_id"].(string)
+		id, _ := m["asset_id"].(string)
 		alias, _ := m["asset_alias"].(string)
 		if id == "" && alias != "" {
 			asset, err := a.assets.FindByAlias(ctx, alias)
@@ -2,6 +2,7 @@ package core
 
 import (
 	"context"
+	"database/sql"
 	"encoding/json"
 	"net/http"
 
@@ -11,22 +12,66 @@ import (
 	"chain/protocol/bc"
 )
 
-// getBlockRPC returns the block at the requested height.
-// If successful, it always returns at least one block,
+var errNoBlock = errors.New("block not found")
+
+// getBlockRequest is the argument to getBlockRPC. It holds
+// either a block height or a block hash. For compatibility,
+// it may also be a bare block height, which makes the request
+// wait for the block to be created.
+type getBlockRequest struct {
+	Height *uint64  `json:"height"`
+	Hash   *bc.Hash `json:"hash"`
+
+	wait bool
+}
+
+func (r *getBlockRequest) UnmarshalJSON(b []byte) error {
+	var height uint64
+	if json.Unmarshal(b, &height) == nil {
+		*r = getBlockRequest{Height: &height, wait: true}
+		return nil
+	}
+	type plain getBlockRequest
+	return json.Unmarshal(b, (*plain)(r))
+}
+
+// getBlockRPC returns the block at the requested height or
+// with the requested hash. If the block doesn't exist,
+// it returns errNoBlock.
+//
+// If the request is a bare height, it always returns a block,
 // waiting if necessary until one is created.
 // It is an error to request blocks very far in the future.
-func (a *API) getBlockRPC(ctx context.Context, height uint64) (chainjson.HexBytes, error) {
-	err := <-a.chain.BlockSoonWaiter(ctx, height)
-	if err != nil {
-		return nil, errors.Wrapf(err, "waiting for block at height %d", height)
+func (a *API) getBlockRPC(ctx context.Context, req getBlockRequest) (chainjson.HexBytes, error) {
+	if (req.Height == nil) == (req.Hash == nil) {
+		return nil, errors.WithDetail(httpjson.ErrBadRequest, "exactly one of height or hash is required")
 	}
 
-	rawBlock, err := a.store.GetRawBlock(ctx, height)
-	if err != nil {
-		return nil, err
+	var (
+		rawBlock []byte
+		err      error
+	)
+	if req.Hash != nil {
+		rawBlock, err = a.store.GetRawBlockByHash(ctx, *req.Hash)
+		if errors.Root(err) == sql.ErrNoRows {
+			return nil, errors.WithDetailf(errNoBlock, "no block with hash %s", req.Hash)
+		}
+		return rawBlock, err
 	}
 
-	return rawBlock, nil
+	height := *req.Height
+	if req.wait {
+		err = <-a.chain.BlockSoonWaiter(ctx, height)
+		if err != nil {
+			return nil, errors.Wrapf(err, "waiting for block at height %d", height)
+		}
+	}
+
+	rawBlock, err = a.store.GetRawBlock(ctx, height)
+	if errors.Root(err) == sql.ErrNoRows {
+		return nil, errors.WithDetailf(errNoBlock, "no block at height %d", height)
+	}
+	return rawBlock, err
 }
 
 type snapshotInfoResp struct {
@@ -3,10 +3,15 @@ package core
 import (
 	"bytes"
 	"context"
+	"encoding/json"
+	"fmt"
 	"testing"
 
 	"chain/core/txdb"
 	"chain/database/pg/pgtest"
+	"chain/errors"
+	"chain/net/http/httpjson"
+	"chain/protocol/bc"
 	"chain/protocol/prottest"
 	"chain/testutil"
 )
@@ -18,7 +23,7 @@ func TestGetBlock(t *testing.T) {
 	chain := prottest.NewChain(t, prottest.WithStore(store))
 	api := &API{chain: chain, store: store}
 
-	block, err := api.getBlockRPC(ctx, 1)
+	block, err := api.getBlockRPC(ctx, heightReq(1))
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -33,7 +38,7 @@ func TestGetBlock(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	block, err = api.getBlockRPC(ctx, 2)
+	block, err = api.getBlockRPC(ctx, heightReq(2))
 	if err != nil {
 		testutil.FatalErr(t, err)
 	}
@@ -45,3 +50,54 @@ func TestGetBlock(t *testing.T) {
 		t.Errorf("got=%x, want=%s", block, buf.Bytes())
 	}
 }
+
+func TestGetBlockByHeightOrHash(t *testing.T) {
+	_, db := pgtest.NewDB(t, pgtest.SchemaPath)
+	ctx := context.Background()
+	store := txdb.NewStore(db)
+	chain := prottest.NewChain(t, prottest.WithStore(store))
+	api := &API{chain: chain, store: store}
+
+	newBlock := prottest.MakeBlock(t, chain, nil)
+	buf := new(bytes.Buffer)
+	_, err := newBlock.WriteTo(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hash := newBlock.Hash()
+	height := newBlock.Height
+	missingHeight := height + 1
+	missingHash := bc.NewHash([32]byte{1})
+
+	cases := []struct {
+		req     string
+		want    []byte
+		wantErr error
+	}{
+		{req: fmt.Sprintf(`%d`, height), want: buf.Bytes()},
+		{req: fmt.Sprintf(`{"height": %d}`, height), want: buf.Bytes()},
+		{req: fmt.Sprintf(`{"hash": "%x"}`, hash.Bytes()), want: buf.Bytes()},
+		{req: fmt.Sprintf(`{"height": %d}`, missingHeight), wantErr: errNoBlock},
+		{req: fmt.Sprintf(`{"hash": "%x"}`, missingHash.Bytes()), wantErr: errNoBlock},
+		{req: `{}`, wantErr: httpjson.ErrBadRequest},
+	}
+	for _, c := range cases {
+		var req getBlockRequest
+		err := json.Unmarshal([]byte(c.req), &req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := api.getBlockRPC(ctx, req)
+		if errors.Root(err) != c.wantErr {
+			t.Errorf("getBlockRPC(%s) error = %v want %v", c.req, err, c.wantErr)
+			continue
+		}
+		if !bytes.Equal(got, c.want) {
+			t.Errorf("getBlockRPC(%s) = %x want %x", c.req, got, c.want)
+		}
+	}
+}
+
+func heightReq(height uint64) getBlockRequest {
+	return getBlockRequest{Height: &height, wait: true}
+}
@@ -26,6 +26,7 @@ import (
 	"chain/database/sinkdb"
 	"chain/log"
 	"chain/net/http/authz"
+	"chain/net/http/limit"
 	"chain/protocol"
 	"chain/protocol/bc/legacy"
 )
@@ -33,6 +34,7 @@ import (
 const (
 	blockPeriod              = time.Second
 	expireReservationsPeriod = time.Second
+	sweepControlProgsPeriod  = time.Minute
 )
 
 // RunOption describes a runtime configuration option.
@@ -51,12 +53,14 @@ func UseTLS(c *tls.Config) RunOption {
 				DualStack: true,
 			}).DialContext,
 			MaxIdleConns:          100,
+			MaxIdleConnsPerHost:   10, // for forwarding to the leader
 			IdleConnTimeout:       90 * time.Second,
 			TLSClientConfig:       c,
 			TLSHandshakeTimeout:   10 * time.Second,
 			ExpectContinueTimeout: 1 * time.Second,
 		}
 
+		a.useTLS = c != nil
 		if c != nil {
 			// TODO(kr): set Leaf in TLSConfig and use that here.
 			x509Cert, err := x509.ParseCertificate(c.Certificates[0].Certificate[0])
@@ -105,15 +109,77 @@ func IndexTransactions(b bool) RunOption {
 	return func(a *API) { a.indexTxs = b }
 }
 
+// TxTTLBounds sets the shortest and longest reservation lifetimes
+// a build-transaction request may ask for in its ttl field. A zero
+// bound leaves the default in place.
+func TxTTLBounds(min, max time.Duration) RunOption {
+	return func(a *API) {
+		a.minTxTTL = min
+		a.maxTxTTL = max
+	}
+}
+
+// TextSearch configures whether or not reference data and asset
+// definitions should be indexed for text search.
+func TextSearch(b bool) RunOption {
+	return func(a *API) {
+		if b {
+			a.indexer.EnableTextSearch()
+		}
+	}
+}
+
+// AssetCacheTTL sets how long cached asset records last before
+// they're read from the database again. Zero means they last until
+// evicted.
+func AssetCacheTTL(d time.Duration) RunOption {
+	return func(a *API) { a.assets.SetCacheTTL(d) }
+}
+
+// MaxUnusedControlProgramAge sets how long an account control
+// program without an expiration may go unused before it's marked
+// inactive and left out of receiver listings. Zero, the default,
+// means never.
+func MaxUnusedControlProgramAge(d time.Duration) RunOption {
+	return func(a *API) { a.accounts.SetMaxUnusedAge(d) }
+}
+
 // RateLimit adds a rate-limiting restriction, using keyFn to extract the
 // key to rate limit on. It will allow up to burst requests in the bucket
 // and will refill the bucket at perSecond tokens per second.
 func RateLimit(keyFn func(*http.Request) string, burst, perSecond int) RunOption {
 	return func(a *API) {
 		a.requestLimits = append(a.requestLimits, requestLimit{
-			key:       keyFn,
-			burst:     burst,
-			perSecond: perSecond,
+			key:     keyFn,
+			limiter: limit.NewBucketLimiter(perSecond, burst),
+		})
+	}
+}
+
+// TokenRateLimit adds a rate-limiting restriction keyed on the access
+// token used to authenticate each request. Tokens with a limit set
+// through /update-access-token-limit use that limit; all other tokens
+// use burst and perSecond. A perSecond of 0 leaves them unlimited.
+// Without this option, limits set on tokens are saved but not
+// applied.
+func TokenRateLimit(burst, perSecond int) RunOption {
+	return func(a *API) {
+		l := limit.NewBucketLimiter(perSecond, burst)
+		l.Override = func(ctx context.Context, id string) (int, int, bool) {
+			tokLimit, err := a.accessTokens.GetLimit(ctx, id)
+			if err != nil {
+				log.Printkv(ctx, log.KeyError, err, "at", "looking up access token rate limit", "token", id)
+				return 0, 0, false
+			}
+			if tokLimit == nil {
+				return 0, 0, false
+			}
+			return tokLimit.PerSecond, tokLimit.Burst, true
+		}
+		a.tokenLimiter = l
+		a.requestLimits = append(a.requestLimits, requestLimit{
+			key:     limit.AuthUserID,
+			limiter: l,
 		})
 	}
 }
@@ -169,7 +235,6 @@ func Run(
 	}
 	// Start listeners
 	go pinStore.Listen(ctx, account.PinName, dbURL)
-	go pinStore.Listen(ctx, account.ExpirePinName, dbURL)
 	go pinStore.Listen(ctx, account.DeleteSpentsPinName, dbURL)
 	go pinStore.Listen(ctx, asset.PinName, dbURL)
 
@@ -229,9 +294,21 @@ func Run(
 	return a, nil
 }
 
+// leaderContext returns the context of this process's current
+// term as leader, or nil if it hasn't led yet.
+func (a *API) leaderContext() context.Context {
+	a.leadCtxMu.Lock()
+	defer a.leadCtxMu.Unlock()
+	return a.leadCtx
+}
+
 // lead is called by the core/leader package when this cored instance
 // becomes leader of the Core.
 func (a *API) lead(ctx context.Context) {
+	a.leadCtxMu.Lock()
+	a.leadCtx = ctx
+	a.leadCtxMu.Unlock()
+
 	if !a.config.IsGenerator {
 		// If don't have any blocks, bootstrap from the generator's
 		// latest snapshot.
@@ -260,7 +337,7 @@ func (a *API) lead(ctx context.Context) {
 	if pinHeight > 0 {
 		pinHeight = pinHeight - 1
 	}
-	pins := []string{account.PinName, account.ExpirePinName, account.DeleteSpentsPinName, asset.PinName, query.TxPinName}
+	pins := []string{account.PinName, account.DeleteSpentsPinName, asset.PinName, query.TxPinName}
 	for _, p := range pins {
 		err = a.pinStore.CreatePin(ctx, p, pinHeight)
 		if err != nil {
@@ -269,7 +346,7 @@ func (a *API) lead(ctx context.Context) {
 	}
 
 	if a.config.IsGenerator {
-		go a.generator.Generate(ctx, blockPeriod, a.healthSetter("generator"))
+		go a.generator.Generate(ctx, a.blockInterval(), a.healthSetter("generator"))
 	} else {
 		// Remove the downloading snapshot if there was one. The core
 		// has recovered and will now start syncing blocks.
@@ -280,6 +357,7 @@ func (a *API) lead(ctx context.Context) {
 		go a.replicator.Fetch(ctx, a.chain, a.healthSetter("fetch"))
 	}
 	go a.accounts.ProcessBlocks(ctx)
+	go a.accounts.SweepControlPrograms(ctx, sweepControlProgsPeriod)
 	go a.assets.ProcessBlocks(ctx)
 	if a.indexTxs {
 		go a.indexer.ProcessBlocks(ctx)
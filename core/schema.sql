@@ -100,6 +100,42 @@ $$;
 
 
 
+CREATE FUNCTION glob_to_like(pattern text) RETURNS text
+    LANGUAGE plpgsql IMMUTABLE STRICT
+    AS $$
+	-- Converts a query filter MATCHES pattern to a LIKE pattern.
+	-- See globToLike in package chain/core/query/filter.
+DECLARE
+	result text := '';
+	escaped boolean := false;
+	c text;
+BEGIN
+	FOREACH c IN ARRAY regexp_split_to_array(pattern, '') LOOP
+		IF escaped THEN
+			escaped := false;
+			IF c IN ('%', '_', '\') THEN
+				result := result || '\';
+			END IF;
+			result := result || c;
+		ELSIF c = '\' THEN
+			escaped := true;
+		ELSIF c = '*' THEN
+			result := result || '%';
+		ELSIF c IN ('%', '_') THEN
+			result := result || '\' || c;
+		ELSE
+			result := result || c;
+		END IF;
+	END LOOP;
+	IF escaped THEN
+		result := result || '\\';
+	END IF;
+	RETURN result;
+END;
+$$;
+
+
+
 CREATE FUNCTION next_chain_id(prefix text) RETURNS text
     LANGUAGE plpgsql
     AS $$
@@ -132,7 +168,10 @@ CREATE TABLE access_tokens (
     sort_id text DEFAULT next_chain_id('at'::text),
     type access_token_type,
     hashed_secret bytea NOT NULL,
-    created timestamp with time zone DEFAULT now() NOT NULL
+    created timestamp with time zone DEFAULT now() NOT NULL,
+    scopes text[] DEFAULT '{}'::text[] NOT NULL,
+    rate_limit integer,
+    rate_burst integer
 );
 
 
@@ -151,7 +190,10 @@ CREATE TABLE account_control_programs (
     key_index bigint NOT NULL,
     control_program bytea NOT NULL,
     change boolean NOT NULL,
-    expires_at timestamp with time zone
+    expires_at timestamp with time zone,
+    used boolean DEFAULT false NOT NULL,
+    created_at timestamp with time zone DEFAULT now() NOT NULL,
+    inactive boolean DEFAULT false NOT NULL
 );
 
 
@@ -199,7 +241,8 @@ CREATE TABLE annotated_assets (
     quorum integer NOT NULL,
     definition jsonb NOT NULL,
     tags jsonb NOT NULL,
-    local boolean NOT NULL
+    local boolean NOT NULL,
+    search tsvector
 );
 
 
@@ -245,7 +288,8 @@ CREATE TABLE annotated_outputs (
     account_tags jsonb,
     control_program bytea NOT NULL,
     reference_data jsonb NOT NULL,
-    local boolean NOT NULL
+    local boolean NOT NULL,
+    spent_block_height bigint
 );
 
 
@@ -259,7 +303,16 @@ CREATE TABLE annotated_txs (
     block_id bytea NOT NULL,
     local boolean NOT NULL,
     reference_data jsonb NOT NULL,
-    block_tx_count integer
+    block_tx_count integer,
+    search tsvector
+);
+
+
+
+CREATE TABLE asset_issuances (
+    asset_id bytea NOT NULL,
+    block_height bigint NOT NULL,
+    amount bigint NOT NULL
 );
 
 
@@ -282,7 +335,8 @@ CREATE TABLE assets (
     definition bytea NOT NULL,
     alias text,
     first_block_height bigint,
-    vm_version bigint NOT NULL
+    vm_version bigint NOT NULL,
+    max_issuance bigint
 );
 
 
@@ -348,6 +402,18 @@ CREATE TABLE core_id (
 
 
 
+CREATE TABLE deleted_accounts (
+    account_id text NOT NULL,
+    alias text,
+    tags jsonb,
+    xpubs bytea[] NOT NULL,
+    quorum integer NOT NULL,
+    key_index bigint NOT NULL,
+    deleted_at timestamp with time zone DEFAULT now() NOT NULL
+);
+
+
+
 CREATE TABLE generator_pending_block (
     singleton boolean DEFAULT true NOT NULL,
     data bytea NOT NULL,
@@ -394,6 +460,26 @@ CREATE TABLE mockhsm (
 
 
 
+CREATE TABLE pending_issuances (
+    asset_id bytea NOT NULL,
+    nonce bytea NOT NULL,
+    amount bigint NOT NULL,
+    expires_at timestamp with time zone NOT NULL
+);
+
+
+
+CREATE TABLE query_backfill (
+    singleton boolean DEFAULT true NOT NULL,
+    from_height bigint NOT NULL,
+    to_height bigint NOT NULL,
+    next_height bigint NOT NULL,
+    updated_at timestamp with time zone DEFAULT now() NOT NULL,
+    CONSTRAINT query_backfill_singleton CHECK (singleton)
+);
+
+
+
 CREATE TABLE query_blocks (
     height bigint NOT NULL,
     "timestamp" bigint NOT NULL
@@ -517,6 +603,11 @@ ALTER TABLE ONLY annotated_txs
 
 
 
+ALTER TABLE ONLY asset_issuances
+    ADD CONSTRAINT asset_issuances_pkey PRIMARY KEY (asset_id, block_height);
+
+
+
 ALTER TABLE ONLY asset_tags
     ADD CONSTRAINT asset_tags_asset_id_key UNIQUE (asset_id);
 
@@ -562,6 +653,11 @@ ALTER TABLE ONLY core_id
 
 
 
+ALTER TABLE ONLY deleted_accounts
+    ADD CONSTRAINT deleted_accounts_pkey PRIMARY KEY (account_id);
+
+
+
 ALTER TABLE ONLY generator_pending_block
     ADD CONSTRAINT generator_pending_block_pkey PRIMARY KEY (singleton);
 
@@ -587,6 +683,16 @@ ALTER TABLE ONLY mockhsm
 
 
 
+ALTER TABLE ONLY pending_issuances
+    ADD CONSTRAINT pending_issuances_pkey PRIMARY KEY (asset_id, nonce);
+
+
+
+ALTER TABLE ONLY query_backfill
+    ADD CONSTRAINT query_backfill_pkey PRIMARY KEY (singleton);
+
+
+
 ALTER TABLE ONLY query_blocks
     ADD CONSTRAINT query_blocks_pkey PRIMARY KEY (height);
 
@@ -636,6 +742,10 @@ CREATE INDEX account_utxos_asset_id_account_id_confirmed_in_idx ON account_utxos
 
 
 
+CREATE INDEX annotated_assets_search_idx ON annotated_assets USING gin (search);
+
+
+
 CREATE INDEX annotated_assets_sort_id ON annotated_assets USING btree (sort_id);
 
 
@@ -648,6 +758,18 @@ CREATE INDEX annotated_txs_data_idx ON annotated_txs USING gin (data jsonb_path_
 
 
 
+CREATE INDEX annotated_txs_search_idx ON annotated_txs USING gin (search);
+
+
+
+CREATE UNIQUE INDEX annotated_txs_tx_hash_idx ON annotated_txs USING btree (tx_hash);
+
+
+
+CREATE UNIQUE INDEX assets_lower_alias_idx ON assets USING btree (lower(alias));
+
+
+
 CREATE INDEX query_blocks_timestamp_idx ON query_blocks USING btree ("timestamp");
 
 
@@ -668,3 +790,15 @@ insert into migrations (filename, hash) values ('2017-04-17.0.core.null-token-ty
 insert into migrations (filename, hash) values ('2017-04-27.0.generator.pending-block-height.sql', 'bfe4fe5eec143e4367a91fd952cb5e3879f1c311f649ec13bfe95b202e94d4ec');
 insert into migrations (filename, hash) values ('2017-05-08.0.core.drop-redundant-indexes.sql', '5140e53b287b058c57ddf361d61cff3d3d1cbc3259a9de413b11574a71d09bec');
 insert into migrations (filename, hash) values ('2017-06-28.0.core.coreid.sql', 'a147b93ba1bf404265efedde066532c937070a87e15123b1d9277daba431ee01');
+insert into migrations (filename, hash) values ('2017-07-05.0.query.output-spent-height.sql', 'eedbfa611278b885fda90de5455320f94d3c9900bc247e9ab8a81c80c60b160a');
+insert into migrations (filename, hash) values ('2017-07-06.0.core.control-program-used.sql', '040a6e7f84e93308a01c898d1d35f3a6400ee572d30f8c1a9b824e6c50cf32e2');
+insert into migrations (filename, hash) values ('2017-07-07.0.core.access-token-scopes.sql', 'c7841d884fb1cd7186b73b43cdfeb3d8cc54d176ab58e3a6ead3c212b4349e75');
+insert into migrations (filename, hash) values ('2017-07-08.0.core.access-token-limits.sql', 'c9d9ca435f19a5ac0fe37ba5a79decb5d96d554b4ca9c38206f96d2e41b24ea1');
+insert into migrations (filename, hash) values ('2017-07-09.0.query.glob-to-like.sql', 'e457e5c469ab4a541b833cf278a975cab9e543bb9278bf60353c8ee70d94b6f5');
+insert into migrations (filename, hash) values ('2017-07-10.0.query.backfill.sql', '8469ebae7688bf42bad2ef419de605a2095f9a15edcd5236673a1d78d8d5fff2');
+insert into migrations (filename, hash) values ('2017-07-10.1.query.text-search.sql', 'adde3b6cbf68db15037f7f6a09d4ecd88b9788e7be18cb4feeb174be753eda0a');
+insert into migrations (filename, hash) values ('2017-07-11.0.core.asset-alias-lower.sql', '9161085b0a950b775813679c81b01aaee9de1d11129312c7c2b21ca5454641f3');
+insert into migrations (filename, hash) values ('2017-07-12.0.core.asset-max-issuance.sql', '7b582e392e98e4b9a12c2d2f44ac01446bbe146905699c9f74a8be24f2dcb517');
+insert into migrations (filename, hash) values ('2017-07-13.0.core.control-program-inactive.sql', '1df29d78ff473e0b29d2127e99a1cae4a7c4e8f4ac37076056582f3c4bb6ab43');
+insert into migrations (filename, hash) values ('2017-07-14.0.core.deleted-accounts.sql', '4656b932c4e9e03415d26c6c2f92293e95cc3e5a4c6f9dada1483a7467e7ce2a');
+insert into migrations (filename, hash) values ('2017-07-16.0.core.drop-expire-control-programs-pin.sql', '55d8ee4fcb423f0e4ddcbac8db051baf1bc0327ebacf5fe4a25d4e3fd5147545');
@@ -6,6 +6,8 @@ import (
 	"sync"
 	"time"
 
+	"chain/core/account"
+	"chain/core/generator"
 	"chain/core/leader"
 	"chain/core/txbuilder"
 	"chain/database/pg"
@@ -13,16 +15,23 @@ import (
 	"chain/errors"
 	"chain/log"
 	"chain/net/http/httperror"
+	"chain/net/http/httpjson"
 	"chain/net/http/reqid"
 	"chain/protocol/bc"
 	"chain/protocol/bc/legacy"
 )
 
-const defaultTxTTL = 5 * time.Minute
+const (
+	defaultTxTTL    = 5 * time.Minute
+	defaultMinTxTTL = time.Second
+	defaultMaxTxTTL = 24 * time.Hour
+)
 
 func (a *API) actionDecoder(action string) (func([]byte) (txbuilder.Action, error), bool) {
 	var decoder func([]byte) (txbuilder.Action, error)
 	switch action {
+	case "consolidate_account":
+		decoder = a.accounts.DecodeConsolidateAction
 	case "control_account":
 		decoder = a.accounts.DecodeControlAction
 	case "control_program":
@@ -31,7 +40,7 @@ func (a *API) actionDecoder(action string) (func([]byte) (txbuilder.Action, erro
 		decoder = txbuilder.DecodeControlReceiverAction
 	case "issue":
 		decoder = a.assets.DecodeIssueAction
-	case "retire":
+	case "retire_asset", "retire": // "retire" is the deprecated name
 		decoder = txbuilder.DecodeRetireAction
 	case "spend_account":
 		decoder = a.accounts.DecodeSpendAction
@@ -51,11 +60,13 @@ func (a *API) buildSingle(ctx context.Context, req *buildRequest) (*txbuilder.Te
 		return nil, err
 	}
 	actions := make([]txbuilder.Action, 0, len(req.Actions))
+	var issuing bool
 	for i, act := range req.Actions {
 		typ, ok := act["type"].(string)
 		if !ok {
 			return nil, errors.WithDetailf(errBadActionType, "no action type provided on action %d", i)
 		}
+		issuing = issuing || typ == "issue"
 		decoder, ok := a.actionDecoder(typ)
 		if !ok {
 			return nil, errors.WithDetailf(errBadActionType, "unknown action type %q on action %d", typ, i)
@@ -74,11 +85,13 @@ func (a *API) buildSingle(ctx context.Context, req *buildRequest) (*txbuilder.Te
 		actions = append(actions, a)
 	}
 
-	ttl := req.TTL.Duration
-	if ttl == 0 {
-		ttl = defaultTxTTL
+	minTime, maxTime, err := a.txTimeRange(req, time.Now(), issuing)
+	if err != nil {
+		return nil, err
+	}
+	if !minTime.IsZero() {
+		actions = append(actions, txbuilder.NewMinTimeAction(minTime))
 	}
-	maxTime := time.Now().Add(ttl)
 	tpl, err := txbuilder.Build(ctx, req.Tx, actions, maxTime)
 	if errors.Root(err) == txbuilder.ErrAction {
 		// Format each of the inner errors contained in the data.
@@ -100,6 +113,85 @@ func (a *API) buildSingle(ctx context.Context, req *buildRequest) (*txbuilder.Te
 	return tpl, nil
 }
 
+// txTTL returns how long the reservations made while building
+// req should last. It is an error for req to ask for a ttl
+// outside the configured bounds.
+func (a *API) txTTL(req *buildRequest) (time.Duration, error) {
+	min, max := a.ttlBounds()
+	ttl := req.TTL.Duration
+	if ttl == 0 {
+		ttl = defaultTxTTL
+		if ttl < min {
+			ttl = min
+		}
+		if ttl > max {
+			ttl = max
+		}
+		return ttl, nil
+	}
+	if ttl < min || ttl > max {
+		return 0, errors.WithDetailf(errBadTTL, "ttl %s is not between %s and %s", ttl, min, max)
+	}
+	return ttl, nil
+}
+
+// ttlBounds returns the shortest and longest
+// allowed transaction ttls.
+func (a *API) ttlBounds() (min, max time.Duration) {
+	min, max = a.minTxTTL, a.maxTxTTL
+	if min == 0 {
+		min = defaultMinTxTTL
+	}
+	if max == 0 {
+		max = defaultMaxTxTTL
+	}
+	return min, max
+}
+
+// txTimeRange returns the times between which the transaction
+// built for req can be included in a block, starting from now.
+// Its reservations expire at the end of the range. Without a
+// max_time, the range ends when req's ttl runs out.
+//
+// It is an error for req to set both a ttl and a max_time, for
+// max_time to be outside the ttl bounds, for min_time to be
+// after the end of the range, or, if the transaction issues
+// assets, for the range to be longer than the chain's maximum
+// issuance window.
+func (a *API) txTimeRange(req *buildRequest, now time.Time, issuing bool) (minTime, maxTime time.Time, err error) {
+	if req.MaxTime.IsZero() {
+		ttl, err := a.txTTL(req)
+		if err != nil {
+			return minTime, maxTime, err
+		}
+		maxTime = now.Add(ttl)
+	} else {
+		if req.TTL.Duration != 0 {
+			return minTime, maxTime, errors.WithDetail(errBadTimeRange, "ttl and max_time can't both be set")
+		}
+		min, max := a.ttlBounds()
+		if d := req.MaxTime.Sub(now); d < min || d > max {
+			return minTime, maxTime, errors.WithDetailf(errBadTimeRange, "max_time must be between %s and %s from now", min, max)
+		}
+		maxTime = req.MaxTime
+	}
+
+	minTime = req.MinTime
+	if minTime.After(maxTime) {
+		return minTime, maxTime, errors.WithDetailf(errBadTimeRange, "min_time %s is after the transaction's max time %s", minTime.Format(time.RFC3339), maxTime.Format(time.RFC3339))
+	}
+
+	// Issuances are never valid before they're built.
+	start := minTime
+	if start.Before(now) {
+		start = now
+	}
+	if window := a.chain.MaxIssuanceWindow; issuing && window > 0 && maxTime.Sub(start) > window {
+		return minTime, maxTime, errors.WithDetailf(errBadTimeRange, "transactions that issue assets can be valid for at most %s", window)
+	}
+	return minTime, maxTime, nil
+}
+
 // POST /build-transaction
 func (a *API) build(ctx context.Context, buildReqs []*buildRequest) (interface{}, error) {
 	// If we're not the leader, we don't have access to the current
@@ -134,17 +226,110 @@ func (a *API) build(ctx context.Context, buildReqs []*buildRequest) (interface{}
 	return responses, nil
 }
 
+// POST /cancel-reservation
+//
+// cancelReservation releases the outputs reserved while building
+// a transaction, either those of every reservation in the given
+// template or those of a single reservation ID, so they can be
+// spent before the reservations expire.
+func (a *API) cancelReservation(ctx context.Context, x struct {
+	Template      *txbuilder.Template `json:"transaction"`
+	ReservationID uint64              `json:"reservation_id"`
+}) (interface{}, error) {
+	// Reservations are held in memory by the leader.
+	if a.leader.State() != leader.Leading {
+		var resp interface{}
+		err := a.forwardToLeader(ctx, "/cancel-reservation", x, &resp)
+		return resp, err
+	}
+	if x.Template == nil && x.ReservationID == 0 {
+		return nil, txbuilder.MissingFieldsError("transaction", "reservation_id")
+	}
+
+	var canceled int
+	if x.Template != nil {
+		canceled += a.accounts.CancelTemplateReservations(ctx, x.Template)
+	}
+	if x.ReservationID != 0 && a.accounts.CancelReservation(ctx, x.ReservationID) {
+		canceled++
+	}
+	return map[string]int{"canceled": canceled}, nil
+}
+
+// POST /list-reservations
+//
+// listReservations describes the leader's current reservations,
+// for telling funds that are tied up apart from funds that are gone.
+func (a *API) listReservations(ctx context.Context) ([]*account.Reservation, error) {
+	if a.leader.State() != leader.Leading {
+		var resp []*account.Reservation
+		err := a.forwardToLeader(ctx, "/list-reservations", nil, &resp)
+		return resp, err
+	}
+	list := a.accounts.ListReservations()
+	if list == nil {
+		list = []*account.Reservation{}
+	}
+	return list, nil
+}
+
+// POST /merge-transaction-templates
+//
+// mergeTemplates combines copies of a template signed by
+// different parties, and reports which witness components
+// still need signatures.
+func (a *API) mergeTemplates(ctx context.Context, x struct {
+	Templates []*txbuilder.Template `json:"templates"`
+}) (interface{}, error) {
+	if len(x.Templates) == 0 {
+		return nil, txbuilder.MissingFieldsError("templates")
+	}
+	tpl, err := txbuilder.MergeTemplates(x.Templates[0], x.Templates[1:]...)
+	if err != nil {
+		return nil, err
+	}
+	missing := tpl.Missing()
+	if missing == nil {
+		missing = []txbuilder.MissingSignatures{}
+	}
+	return map[string]interface{}{
+		"template":           tpl,
+		"missing_signatures": missing,
+	}, nil
+}
+
+// POST /validate-transaction-template
+//
+// validateTemplate reports the reasons the transaction in
+// tpl would be rejected, without submitting it.
+func (a *API) validateTemplate(ctx context.Context, tpl *txbuilder.Template) (interface{}, error) {
+	findings := txbuilder.ValidateTemplate(a.chain, tpl)
+	if findings == nil {
+		findings = []txbuilder.Finding{}
+	}
+	return map[string]interface{}{"findings": findings}, nil
+}
+
 func (a *API) submitSingle(ctx context.Context, tpl *txbuilder.Template, waitUntil string) (interface{}, error) {
 	if tpl.Transaction == nil {
 		return nil, errors.Wrap(txbuilder.ErrMissingRawTx)
 	}
+	if exp := tpl.ReservationExpiresAt; !exp.IsZero() && time.Now().After(exp) {
+		return nil, errors.WithDetailf(txbuilder.ErrReservationExpired, "reservations expired at %s", exp.Format(time.RFC3339))
+	}
 
-	err := a.finalizeTxWait(ctx, tpl, waitUntil)
+	// A tx already in the pool needn't be finalized again.
+	pending := a.generator != nil && a.generator.Contains(tpl.Transaction.ID)
+	err := a.finalizeTxWait(ctx, tpl, waitUntil, pending)
 	if err != nil {
 		return nil, errors.Wrapf(err, "tx %s", tpl.Transaction.ID.String())
 	}
 
-	return map[string]string{"id": tpl.Transaction.ID.String()}, nil
+	resp := map[string]string{"id": tpl.Transaction.ID.String()}
+	if pending {
+		resp["status"] = "already pending"
+	}
+	return resp, nil
 }
 
 // recordSubmittedTx records a lower bound height at which the tx
@@ -209,8 +394,9 @@ func cleanUpSubmittedTxs(ctx context.Context, db pg.DB) {
 // the transaction.  A nil error return means the transaction is
 // confirmed on the blockchain.  ErrRejected means a conflicting tx is
 // on the blockchain.  context.DeadlineExceeded means ctx is an
-// expiring context that timed out.
-func (a *API) finalizeTxWait(ctx context.Context, txTemplate *txbuilder.Template, waitUntil string) error {
+// expiring context that timed out. If pending is true, the
+// transaction is already in the pool, and it only waits.
+func (a *API) finalizeTxWait(ctx context.Context, txTemplate *txbuilder.Template, waitUntil string, pending bool) error {
 	// Use the current generator height as the lower bound of the block height
 	// that the transaction may appear in.
 	var generatorHeight uint64
@@ -228,9 +414,15 @@ func (a *API) finalizeTxWait(ctx context.Context, txTemplate *txbuilder.Template
 		return errors.Wrap(err, "saving tx submitted height")
 	}
 
-	err = txbuilder.FinalizeTx(ctx, a.chain, a.submitter, txTemplate.Transaction)
-	if err != nil {
-		return err
+	if !pending {
+		err = a.assets.AdmitIssuances(ctx, txTemplate.Transaction)
+		if err != nil {
+			return err
+		}
+		err = txbuilder.FinalizeTx(ctx, a.chain, a.submitter, txTemplate.Transaction)
+		if err != nil {
+			return err
+		}
 	}
 	if waitUntil == "none" {
 		return nil
@@ -256,38 +448,37 @@ func (a *API) finalizeTxWait(ctx context.Context, txTemplate *txbuilder.Template
 func (a *API) waitForTxInBlock(ctx context.Context, tx *legacy.Tx, height uint64) (uint64, error) {
 	for {
 		height++
-		select {
-		case <-ctx.Done():
-			return 0, ctx.Err()
+		err := a.chain.WaitForBlockHeight(ctx, height)
+		if err != nil {
+			return 0, err
+		}
 
-		case <-a.chain.BlockWaiter(height):
-			b, err := a.chain.GetBlock(ctx, height)
-			if err != nil {
-				return 0, errors.Wrap(err, "getting block that just landed")
-			}
-			for _, confirmed := range b.Transactions {
-				if confirmed.ID == tx.ID {
-					// confirmed
-					return height, nil
-				}
+		b, err := a.chain.GetBlock(ctx, height)
+		if err != nil {
+			return 0, errors.Wrap(err, "getting block that just landed")
+		}
+		for _, confirmed := range b.Transactions {
+			if confirmed.ID == tx.ID {
+				// confirmed
+				return height, nil
 			}
+		}
 
-			if tx.MaxTime > 0 && tx.MaxTime < b.TimestampMS {
-				return 0, errors.Wrap(txbuilder.ErrRejected, "transaction max time exceeded")
-			}
+		if tx.MaxTime > 0 && tx.MaxTime < b.TimestampMS {
+			return 0, errors.Wrap(txbuilder.ErrRejected, "transaction max time exceeded")
+		}
 
-			// might still be in pool or might be rejected; we can't
-			// tell definitively until its max time elapses.
+		// might still be in pool or might be rejected; we can't
+		// tell definitively until its max time elapses.
 
-			// Re-insert into the pool in case it was dropped.
-			err = txbuilder.FinalizeTx(ctx, a.chain, a.submitter, tx)
-			if err != nil {
-				return 0, err
-			}
-
-			// TODO(jackson): Do simple rejection checks like checking if
-			// the tx's blockchain prevouts still exist in the state tree.
+		// Re-insert into the pool in case it was dropped.
+		err = txbuilder.FinalizeTx(ctx, a.chain, a.submitter, tx)
+		if err != nil {
+			return 0, err
 		}
+
+		// TODO(jackson): Do simple rejection checks like checking if
+		// the tx's blockchain prevouts still exist in the state tree.
 	}
 }
 
@@ -334,3 +525,19 @@ func (a *API) submit(ctx context.Context, x submitArg) (interface{}, error) {
 	wg.Wait()
 	return responses, nil
 }
+
+// POST /list-pool-transactions
+//
+// listPoolTxs describes the transactions in the generator's
+// pending tx pool, oldest first.
+func (a *API) listPoolTxs(ctx context.Context) ([]generator.PoolTxInfo, error) {
+	if a.leader.State() != leader.Leading {
+		var resp []generator.PoolTxInfo
+		err := a.forwardToLeader(ctx, "/list-pool-transactions", nil, &resp)
+		return resp, err
+	}
+	if a.generator == nil {
+		return nil, errors.WithDetail(httpjson.ErrBadRequest, "this core is not the generator; its pending transactions are held by the generator")
+	}
+	return a.generator.Dump(ctx)
+}
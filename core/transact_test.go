@@ -1,7 +1,10 @@
 package core
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
+	"math"
 	"sync"
 	"testing"
 	"time"
@@ -10,14 +13,20 @@ import (
 	"chain/core/asset"
 	"chain/core/coretest"
 	"chain/core/generator"
+	"chain/core/migrate"
 	"chain/core/pin"
 	"chain/core/query"
 	"chain/core/txbuilder"
 	"chain/database/pg/pgtest"
+	chainjson "chain/encoding/json"
+	"chain/errors"
+	"chain/net/http/httpjson"
+	"chain/protocol"
 	"chain/protocol/bc"
 	"chain/protocol/bc/bctest"
 	"chain/protocol/bc/legacy"
 	"chain/protocol/prottest"
+	"chain/protocol/vm"
 	"chain/testutil"
 )
 
@@ -76,6 +85,87 @@ func TestAccountTransferSpendChange(t *testing.T) {
 	}
 }
 
+func TestRetireAsset(t *testing.T) {
+	_, db := pgtest.NewDB(t, pgtest.SchemaPath)
+	ctx := context.Background()
+	c := prottest.NewChain(t)
+	g := generator.New(c, nil, db)
+	pinStore := pin.NewStore(db)
+	assets := asset.NewRegistry(db, c, pinStore)
+	accounts := account.NewManager(db, c, pinStore)
+	indexer := query.NewIndexer(db, c, pinStore)
+	coretest.CreatePins(ctx, t, pinStore)
+	accounts.IndexAccounts(indexer)
+	indexer.RegisterAnnotator(accounts.AnnotateTxs)
+	indexer.RegisterAnnotator(assets.AnnotateTxs)
+	go accounts.ProcessBlocks(ctx)
+	go assets.ProcessBlocks(ctx)
+	go indexer.ProcessBlocks(ctx)
+	a := &API{chain: c, accounts: accounts, assets: assets}
+
+	acc := coretest.CreateAccount(ctx, t, accounts, "", nil)
+	assetID := coretest.CreateAsset(ctx, t, assets, nil, "gold", nil)
+	coretest.IssueAssets(ctx, t, c, g, assets, accounts, assetID, 100, acc)
+	prottest.MakeBlock(t, c, g.PendingTxs())
+	<-pinStore.PinWaiter(account.PinName, c.Height())
+
+	tmpl, err := a.buildSingle(ctx, &buildRequest{
+		Actions: []map[string]interface{}{{
+			"type":       "spend_account",
+			"account_id": acc,
+			"asset_id":   assetID.String(),
+			"amount":     30,
+		}, {
+			"type":           "retire_asset",
+			"asset_alias":    "gold",
+			"amount":         30,
+			"reference_data": map[string]interface{}{"reason": "burn"},
+		}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	coretest.SignTxTemplate(t, ctx, tmpl, &testutil.TestXPrv)
+	err = txbuilder.FinalizeTx(ctx, c, g, tmpl.Transaction)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := prottest.MakeBlock(t, c, g.PendingTxs())
+	<-pinStore.PinWaiter(query.TxPinName, b.Height)
+
+	var retired *legacy.TxOutput
+	for _, out := range b.Transactions[0].Outputs {
+		if out.AssetAmount.Amount == 30 {
+			retired = out
+		}
+	}
+	if retired == nil {
+		t.Fatal("no retirement output in block")
+	}
+	wantProg := []byte{byte(vm.OP_FAIL)}
+	if !bytes.Equal(retired.ControlProgram, wantProg) {
+		t.Errorf("retirement control program = %x want %x", retired.ControlProgram, wantProg)
+	}
+
+	after := query.TxAfter{FromBlockHeight: b.Height, FromPosition: math.MaxInt32, StopBlockHeight: b.Height}
+	txs, _, err := indexer.Transactions(ctx, "", nil, "", after, 10, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(txs) != 1 {
+		t.Fatalf("got %d annotated transactions, want 1", len(txs))
+	}
+	var types []string
+	for _, out := range txs[0].Outputs {
+		if out.Amount == 30 {
+			types = append(types, out.Type)
+		}
+	}
+	if !testutil.DeepEqual(types, []string{"retire"}) {
+		t.Errorf("annotated output types = %v want [retire]", types)
+	}
+}
+
 func TestRecordSubmittedTxs(t *testing.T) {
 	ctx := context.Background()
 	dbtx := pgtest.NewTx(t)
@@ -194,3 +284,523 @@ func TestWaitForTxInBlockResubmits(t *testing.T) {
 		return
 	}
 }
+
+func TestSubmitWaitAfterUpgrade(t *testing.T) {
+	ctx := context.Background()
+	_, db := pgtest.NewDB(t, pgtest.SchemaPath)
+
+	// Cores from before the expire-control-programs pin was
+	// removed still have its row. Put it back and upgrade.
+	_, err := db.ExecContext(ctx, `
+		INSERT INTO block_processors (name, height) VALUES ('expire-control-programs', 0);
+		DELETE FROM migrations WHERE filename = '2017-07-16.0.core.drop-expire-control-programs-pin.sql';
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = migrate.Run(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := prottest.NewChain(t)
+	pinStore := pin.NewStore(db)
+	coretest.CreatePins(ctx, t, pinStore)
+	err = pinStore.LoadAll(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	for _, name := range []string{account.PinName, account.DeleteSpentsPinName, asset.PinName, query.TxPinName} {
+		go pinStore.ProcessBlocks(ctx, c, name, func(context.Context, *legacy.Block) error {
+			return nil
+		})
+	}
+
+	a := &API{
+		chain:    c,
+		db:       db,
+		pinStore: pinStore,
+		assets:   asset.NewRegistry(db, c, pinStore),
+		submitter: submitterFunc(func(_ context.Context, tx *legacy.Tx) error {
+			prottest.MakeBlock(t, c, []*legacy.Tx{tx})
+			return nil
+		}),
+	}
+	tx := bctest.NewIssuanceTx(t, prottest.Initial(t, c).Hash())
+	err = a.finalizeTxWait(ctx, &txbuilder.Template{Transaction: tx}, "processed", false)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+}
+
+func TestTxTTL(t *testing.T) {
+	cases := []struct {
+		min, max time.Duration
+		ttl      time.Duration
+		want     time.Duration
+		wantErr  error
+	}{
+		{ttl: 0, want: defaultTxTTL},
+		{ttl: time.Hour, want: time.Hour},
+		{ttl: time.Millisecond, wantErr: errBadTTL},
+		{ttl: 48 * time.Hour, wantErr: errBadTTL},
+		{min: time.Millisecond, ttl: time.Millisecond, want: time.Millisecond},
+		{max: 72 * time.Hour, ttl: 48 * time.Hour, want: 48 * time.Hour},
+		{max: time.Minute, ttl: 0, want: time.Minute},
+		{min: time.Hour, ttl: 0, want: time.Hour},
+		{min: time.Hour, ttl: time.Minute, wantErr: errBadTTL},
+	}
+	for _, c := range cases {
+		a := &API{minTxTTL: c.min, maxTxTTL: c.max}
+		req := &buildRequest{TTL: chainjson.Duration{Duration: c.ttl}}
+		got, err := a.txTTL(req)
+		if errors.Root(err) != c.wantErr {
+			t.Errorf("txTTL(%s) with bounds [%s, %s] error = %v want %v", c.ttl, c.min, c.max, err, c.wantErr)
+			continue
+		}
+		if got != c.want {
+			t.Errorf("txTTL(%s) with bounds [%s, %s] = %s want %s", c.ttl, c.min, c.max, got, c.want)
+		}
+	}
+}
+
+func TestTxTimeRange(t *testing.T) {
+	now := time.Now()
+	hour := func(n int) time.Time { return now.Add(time.Duration(n) * time.Hour) }
+	cases := []struct {
+		req     buildRequest
+		issuing bool
+		wantMin time.Time
+		wantMax time.Time
+		wantErr error
+	}{
+		{req: buildRequest{}, wantMax: now.Add(defaultTxTTL)},
+		{req: buildRequest{MinTime: hour(-1)}, wantMin: hour(-1), wantMax: now.Add(defaultTxTTL)},
+		{req: buildRequest{MaxTime: hour(2)}, wantMax: hour(2)},
+		{req: buildRequest{MinTime: hour(1), MaxTime: hour(2)}, wantMin: hour(1), wantMax: hour(2)},
+		{req: buildRequest{MinTime: hour(1)}, wantErr: errBadTimeRange},
+		{req: buildRequest{MinTime: hour(2), MaxTime: hour(1)}, wantErr: errBadTimeRange},
+		{req: buildRequest{MaxTime: hour(-1)}, wantErr: errBadTimeRange},
+		{req: buildRequest{MaxTime: hour(48)}, wantErr: errBadTimeRange},
+		{req: buildRequest{MaxTime: hour(1), TTL: chainjson.Duration{Duration: time.Hour}}, wantErr: errBadTimeRange},
+		{req: buildRequest{TTL: chainjson.Duration{Duration: time.Millisecond}}, wantErr: errBadTTL},
+
+		// Issuances can't be valid for longer than the
+		// issuance window, counted from when they're built.
+		{req: buildRequest{MaxTime: hour(3)}, issuing: true, wantErr: errBadTimeRange},
+		{req: buildRequest{MinTime: hour(-1), MaxTime: hour(2)}, issuing: true, wantMin: hour(-1), wantMax: hour(2)},
+		{req: buildRequest{MinTime: hour(1), MaxTime: hour(3)}, issuing: true, wantMin: hour(1), wantMax: hour(3)},
+	}
+	a := &API{chain: &protocol.Chain{MaxIssuanceWindow: 2 * time.Hour}}
+	for _, c := range cases {
+		gotMin, gotMax, err := a.txTimeRange(&c.req, now, c.issuing)
+		if errors.Root(err) != c.wantErr {
+			t.Errorf("txTimeRange(%+v, issuing=%t) error = %v want %v", c.req, c.issuing, err, c.wantErr)
+			continue
+		}
+		if !gotMin.Equal(c.wantMin) || !gotMax.Equal(c.wantMax) {
+			t.Errorf("txTimeRange(%+v, issuing=%t) = %s, %s want %s, %s", c.req, c.issuing, gotMin, gotMax, c.wantMin, c.wantMax)
+		}
+	}
+}
+
+func TestBuildMinTime(t *testing.T) {
+	_, db := pgtest.NewDB(t, pgtest.SchemaPath)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	c := prottest.NewChain(t)
+	g := generator.New(c, nil, db)
+	pinStore := pin.NewStore(db)
+	assets := asset.NewRegistry(db, c, pinStore)
+	accounts := account.NewManager(db, c, pinStore)
+	coretest.CreatePins(ctx, t, pinStore)
+	accounts.IndexAccounts(query.NewIndexer(db, c, pinStore))
+	go accounts.ProcessBlocks(ctx)
+	a := &API{
+		chain:     c,
+		db:        db,
+		submitter: g,
+		accounts:  accounts,
+		assets:    assets,
+	}
+
+	acc := coretest.CreateAccount(ctx, t, accounts, "", nil)
+	assetID := coretest.CreateAsset(ctx, t, assets, nil, "", nil)
+
+	// A scheduled issuance, valid only in an hour.
+	minTime := time.Now().Add(time.Hour).Truncate(time.Millisecond)
+	maxTime := minTime.Add(time.Hour)
+	tmpl, err := a.buildSingle(ctx, &buildRequest{
+		MinTime: minTime,
+		MaxTime: maxTime,
+		Actions: []map[string]interface{}{{
+			"type":     "issue",
+			"asset_id": assetID.String(),
+			"amount":   10,
+		}, {
+			"type":       "control_account",
+			"account_id": acc,
+			"asset_id":   assetID.String(),
+			"amount":     10,
+		}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tx := tmpl.Transaction; tx.MinTime != bc.Millis(minTime) || tx.MaxTime != bc.Millis(maxTime) {
+		t.Errorf("tx time range = [%d, %d] want [%d, %d]", tx.MinTime, tx.MaxTime, bc.Millis(minTime), bc.Millis(maxTime))
+	}
+
+	// The template echoes the time range.
+	b, err := json.Marshal(tmpl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var echo struct {
+		MinTime time.Time `json:"min_time"`
+		MaxTime time.Time `json:"max_time"`
+	}
+	err = json.Unmarshal(b, &echo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !echo.MinTime.Equal(minTime) || !echo.MaxTime.Equal(maxTime) {
+		t.Errorf("template time range = [%s, %s] want [%s, %s]", echo.MinTime, echo.MaxTime, minTime, maxTime)
+	}
+
+	// The transaction is accepted, but isn't
+	// included in blocks made before its min time.
+	coretest.SignTxTemplate(t, ctx, tmpl, &testutil.TestXPrv)
+	_, err = a.submitSingle(ctx, tmpl, "none")
+	if err != nil {
+		t.Fatal(err)
+	}
+	blk := prottest.MakeBlock(t, c, g.PendingTxs())
+	if len(blk.Transactions) != 0 {
+		t.Errorf("block before min time has transactions %v, want none", blk.Transactions)
+	}
+}
+
+func TestSubmitReservationTTL(t *testing.T) {
+	_, db := pgtest.NewDB(t, pgtest.SchemaPath)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	c := prottest.NewChain(t)
+	g := generator.New(c, nil, db)
+	pinStore := pin.NewStore(db)
+	assets := asset.NewRegistry(db, c, pinStore)
+	accounts := account.NewManager(db, c, pinStore)
+	coretest.CreatePins(ctx, t, pinStore)
+	accounts.IndexAccounts(query.NewIndexer(db, c, pinStore))
+	go accounts.ProcessBlocks(ctx)
+	go accounts.ExpireReservations(ctx, time.Millisecond)
+	a := &API{
+		chain:     c,
+		db:        db,
+		submitter: g,
+		accounts:  accounts,
+		assets:    assets,
+		minTxTTL:  time.Millisecond,
+	}
+
+	acc := coretest.CreateAccount(ctx, t, accounts, "", nil)
+	assetID := coretest.CreateAsset(ctx, t, assets, nil, "", nil)
+	coretest.IssueAssets(ctx, t, c, g, assets, accounts, assetID, 100, acc)
+	prottest.MakeBlock(t, c, g.PendingTxs())
+	<-pinStore.PinWaiter(account.PinName, c.Height())
+
+	build := func(ttl time.Duration, amount uint64) *txbuilder.Template {
+		tmpl, err := a.buildSingle(ctx, &buildRequest{
+			TTL: chainjson.Duration{Duration: ttl},
+			Actions: []map[string]interface{}{{
+				"type":       "spend_account",
+				"account_id": acc,
+				"asset_id":   assetID.String(),
+				"amount":     amount,
+			}, {
+				"type":       "control_account",
+				"account_id": acc,
+				"asset_id":   assetID.String(),
+				"amount":     amount,
+			}},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		coretest.SignTxTemplate(t, ctx, tmpl, &testutil.TestXPrv)
+		return tmpl
+	}
+	delay := 50 * time.Millisecond
+
+	// A template whose reservations expire before it's
+	// submitted is refused.
+	tmpl := build(time.Millisecond, 40)
+	if !tmpl.ReservationExpiresAt.Before(time.Now().Add(delay)) {
+		t.Fatalf("reservation expiry = %s, want before %s", tmpl.ReservationExpiresAt, time.Now().Add(delay))
+	}
+	time.Sleep(delay)
+	_, err := a.submitSingle(ctx, tmpl, "none")
+	if errors.Root(err) != txbuilder.ErrReservationExpired {
+		t.Errorf("submit after ttl error = %v want %v", err, txbuilder.ErrReservationExpired)
+	}
+
+	// One with a long TTL survives the same delay, and can
+	// spend the outputs the expired reservation released.
+	tmpl = build(time.Hour, 60)
+	if tmpl.ReservationExpiresAt.Before(time.Now().Add(59 * time.Minute)) {
+		t.Errorf("reservation expiry = %s, want about an hour from now", tmpl.ReservationExpiresAt)
+	}
+	time.Sleep(delay)
+	_, err = a.submitSingle(ctx, tmpl, "none")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := prottest.MakeBlock(t, c, g.PendingTxs())
+	if len(b.Transactions) != 1 || b.Transactions[0].ID != tmpl.Transaction.ID {
+		t.Errorf("block transactions = %v, want only %x", b.Transactions, tmpl.Transaction.ID.Bytes())
+	}
+}
+
+func TestCancelReservation(t *testing.T) {
+	_, db := pgtest.NewDB(t, pgtest.SchemaPath)
+	ctx := context.Background()
+	c := prottest.NewChain(t)
+	g := generator.New(c, nil, db)
+	pinStore := pin.NewStore(db)
+	assets := asset.NewRegistry(db, c, pinStore)
+	accounts := account.NewManager(db, c, pinStore)
+	coretest.CreatePins(ctx, t, pinStore)
+	accounts.IndexAccounts(query.NewIndexer(db, c, pinStore))
+	go accounts.ProcessBlocks(ctx)
+	a := &API{chain: c, accounts: accounts, assets: assets, leader: alwaysLeader{}}
+
+	acc := coretest.CreateAccount(ctx, t, accounts, "", nil)
+	assetID := coretest.CreateAsset(ctx, t, assets, nil, "", nil)
+	coretest.IssueAssets(ctx, t, c, g, assets, accounts, assetID, 100, acc)
+	prottest.MakeBlock(t, c, g.PendingTxs())
+	<-pinStore.PinWaiter(account.PinName, c.Height())
+
+	build := func() *txbuilder.Template {
+		tmpl, err := a.buildSingle(ctx, &buildRequest{
+			Actions: []map[string]interface{}{{
+				"type":       "spend_account",
+				"account_id": acc,
+				"asset_id":   assetID.String(),
+				"amount":     100,
+			}, {
+				"type":       "control_account",
+				"account_id": acc,
+				"asset_id":   assetID.String(),
+				"amount":     100,
+			}},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return tmpl
+	}
+	cancel := func(x interface{}, want int) {
+		b, err := json.Marshal(x)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var req struct {
+			Template      *txbuilder.Template `json:"transaction"`
+			ReservationID uint64              `json:"reservation_id"`
+		}
+		err = json.Unmarshal(b, &req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := a.cancelReservation(ctx, req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !testutil.DeepEqual(got, map[string]int{"canceled": want}) {
+			t.Errorf("cancel-reservation(%s) = %v, want %d canceled", b, got, want)
+		}
+	}
+
+	// Every output in the account is reserved, so building
+	// again fails until the reservation is canceled.
+	tmpl := build()
+	_, err := a.buildSingle(ctx, &buildRequest{
+		Actions: []map[string]interface{}{{
+			"type":       "spend_account",
+			"account_id": acc,
+			"asset_id":   assetID.String(),
+			"amount":     100,
+		}},
+	})
+	if errors.Root(err) != txbuilder.ErrAction {
+		t.Fatalf("build with reserved outputs error = %v want %v", err, txbuilder.ErrAction)
+	}
+	cancel(map[string]interface{}{"transaction": tmpl}, 1)
+
+	tmpl = build()
+	rid := tmpl.SigningInstructions[0].ReservationID
+	if rid == 0 {
+		t.Fatal("signing instruction has no reservation id")
+	}
+	cancel(map[string]interface{}{"reservation_id": rid}, 1)
+
+	// Canceling again, or canceling a template whose
+	// reservations are gone, does nothing.
+	cancel(map[string]interface{}{"reservation_id": rid}, 0)
+	cancel(map[string]interface{}{"transaction": tmpl}, 0)
+	cancel(map[string]interface{}{"reservation_id": 12345}, 0)
+
+	build()
+}
+
+func TestActionReferenceData(t *testing.T) {
+	_, db := pgtest.NewDB(t, pgtest.SchemaPath)
+	ctx := context.Background()
+	c := prottest.NewChain(t)
+	g := generator.New(c, nil, db)
+	pinStore := pin.NewStore(db)
+	assets := asset.NewRegistry(db, c, pinStore)
+	accounts := account.NewManager(db, c, pinStore)
+	indexer := query.NewIndexer(db, c, pinStore)
+	coretest.CreatePins(ctx, t, pinStore)
+	accounts.IndexAccounts(indexer)
+	indexer.RegisterAnnotator(accounts.AnnotateTxs)
+	indexer.RegisterAnnotator(assets.AnnotateTxs)
+	go accounts.ProcessBlocks(ctx)
+	go assets.ProcessBlocks(ctx)
+	go indexer.ProcessBlocks(ctx)
+	a := &API{chain: c, db: db, submitter: g, accounts: accounts, assets: assets}
+
+	acc1 := coretest.CreateAccount(ctx, t, accounts, "", nil)
+	acc2 := coretest.CreateAccount(ctx, t, accounts, "", nil)
+	assetID := coretest.CreateAsset(ctx, t, assets, nil, "", nil)
+
+	// Each action carries its own reference data, which
+	// ends up on the input or output it creates.
+	build := func(actions ...map[string]interface{}) *query.AnnotatedTx {
+		tmpl, err := a.buildSingle(ctx, &buildRequest{Actions: actions})
+		if err != nil {
+			t.Fatal(err)
+		}
+		coretest.SignTxTemplate(t, ctx, tmpl, &testutil.TestXPrv)
+		_, err = a.submitSingle(ctx, tmpl, "none")
+		if err != nil {
+			t.Fatal(err)
+		}
+		b := prottest.MakeBlock(t, c, g.PendingTxs())
+		<-pinStore.AllWaiter(b.Height)
+
+		after := query.TxAfter{FromBlockHeight: b.Height, FromPosition: math.MaxInt32, StopBlockHeight: b.Height}
+		txs, _, err := indexer.Transactions(ctx, "", nil, "", after, 10, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(txs) != 1 {
+			t.Fatalf("got %d annotated transactions, want 1", len(txs))
+		}
+		return txs[0]
+	}
+	refData := func(raw *json.RawMessage) map[string]interface{} {
+		var m map[string]interface{}
+		err := json.Unmarshal(*raw, &m)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return m
+	}
+
+	tx := build(map[string]interface{}{
+		"type":           "issue",
+		"asset_id":       assetID.String(),
+		"amount":         100,
+		"reference_data": map[string]interface{}{"source": "mint"},
+	}, map[string]interface{}{
+		"type":           "control_account",
+		"account_id":     acc1,
+		"asset_id":       assetID.String(),
+		"amount":         100,
+		"reference_data": map[string]interface{}{"deposit": "d-1"},
+	})
+	if got := refData(tx.Inputs[0].ReferenceData); !testutil.DeepEqual(got, map[string]interface{}{"source": "mint"}) {
+		t.Errorf("issuance input reference data = %v", got)
+	}
+	if got := refData(tx.Outputs[0].ReferenceData); !testutil.DeepEqual(got, map[string]interface{}{"deposit": "d-1"}) {
+		t.Errorf("control output reference data = %v", got)
+	}
+
+	tx = build(map[string]interface{}{
+		"type":           "spend_account",
+		"account_id":     acc1,
+		"asset_id":       assetID.String(),
+		"amount":         100,
+		"reference_data": map[string]interface{}{"approved_by": "audit"},
+	}, map[string]interface{}{
+		"type":       "control_account",
+		"account_id": acc2,
+		"asset_id":   assetID.String(),
+		"amount":     100,
+	})
+	if got := refData(tx.Inputs[0].ReferenceData); !testutil.DeepEqual(got, map[string]interface{}{"approved_by": "audit"}) {
+		t.Errorf("spend input reference data = %v", got)
+	}
+
+	tx = build(map[string]interface{}{
+		"type":           "spend_account_unspent_output",
+		"output_id":      tx.Outputs[0].OutputID.String(),
+		"reference_data": map[string]interface{}{"approved_by": "ops"},
+	}, map[string]interface{}{
+		"type":       "control_account",
+		"account_id": acc1,
+		"asset_id":   assetID.String(),
+		"amount":     100,
+	})
+	if got := refData(tx.Inputs[0].ReferenceData); !testutil.DeepEqual(got, map[string]interface{}{"approved_by": "ops"}) {
+		t.Errorf("spend output input reference data = %v", got)
+	}
+}
+
+func TestListPoolTxs(t *testing.T) {
+	ctx := context.Background()
+	c := prottest.NewChain(t)
+	g := generator.New(c, nil, nil)
+	tx := bctest.NewIssuanceTx(t, prottest.Initial(t, c).Hash())
+	err := g.Submit(ctx, tx)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+
+	a := &API{chain: c, generator: g, leader: alwaysLeader{}}
+	infos, err := a.listPoolTxs(ctx)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	b, err := json.Marshal(infos)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []map[string]interface{}
+	err = json.Unmarshal(b, &got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 {
+		t.Fatalf("got %d txs want 1: %s", len(got), b)
+	}
+	want := map[string]interface{}{
+		"id":       tx.ID.String(),
+		"size":     float64(tx.SerializedSize()),
+		"added_at": infos[0].AddedAt.Format(time.RFC3339Nano),
+		"issuance": true,
+	}
+	if !testutil.DeepEqual(got[0], want) {
+		t.Errorf("list-pool-transactions item = %v want %v", got[0], want)
+	}
+
+	a.generator = nil
+	_, err = a.listPoolTxs(ctx)
+	if errors.Root(err) != httpjson.ErrBadRequest {
+		t.Errorf("listPoolTxs() on a non-generator = %v want %v", err, httpjson.ErrBadRequest)
+	}
+}
@@ -3,6 +3,7 @@ package txbuilder
 import (
 	"context"
 	stdjson "encoding/json"
+	"time"
 
 	"chain/encoding/json"
 	"chain/protocol/bc"
@@ -119,3 +120,16 @@ func (a *retireAction) Build(ctx context.Context, b *TemplateBuilder) error {
 	out := legacy.NewTxOutput(*a.AssetId, a.Amount, retirementProgram, a.ReferenceData)
 	return b.AddOutput(out)
 }
+
+// NewMinTimeAction returns an action that makes the
+// transaction invalid before t.
+func NewMinTimeAction(t time.Time) Action {
+	return minTimeAction(t)
+}
+
+type minTimeAction time.Time
+
+func (a minTimeAction) Build(ctx context.Context, b *TemplateBuilder) error {
+	b.RestrictMinTime(time.Time(a))
+	return nil
+}
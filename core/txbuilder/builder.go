@@ -102,9 +102,14 @@ func (b *TemplateBuilder) Build() (*Template, *legacy.TxData, error) {
 		}
 	}
 
-	tpl := &Template{}
-	tx := b.base
-	if tx == nil {
+	tpl := &Template{Version: TemplateVersion, ReservationExpiresAt: b.maxTime}
+	var tx *legacy.TxData
+	if b.base != nil {
+		// Work on a copy, so the template doesn't share
+		// slices with the caller's transaction.
+		base := b.base.Copy()
+		tx = &base
+	} else {
 		tx = &legacy.TxData{
 			Version: 1,
 		}
@@ -15,6 +15,10 @@ var (
 	// ErrRejected means the network rejected a tx (as a double-spend)
 	ErrRejected = errors.New("transaction rejected")
 
+	// ErrReservationExpired means a template was submitted after
+	// the reservations made while building it expired.
+	ErrReservationExpired = errors.New("reservation expired")
+
 	ErrMissingRawTx        = errors.New("missing raw tx")
 	ErrBadInstructionCount = errors.New("too many signing instructions in template")
 )
@@ -34,10 +38,27 @@ func FinalizeTx(ctx context.Context, c *protocol.Chain, s Submitter, tx *legacy.
 		return err
 	}
 
+	// Keep legacy.ErrTxLimit as the root, so clients
+	// can tell which limit was exceeded.
+	err = tx.CheckLimits()
+	if err != nil {
+		return errors.Wrap(err)
+	}
+	err = protocol.CheckTxSize(tx)
+	if err != nil {
+		return errors.Wrap(err)
+	}
+
 	// Make sure there is at least one block in case client is trying to
 	// finalize a tx before the initial block has landed
-	<-c.BlockWaiter(1)
+	err = c.WaitForBlockHeight(ctx, 1)
+	if err != nil {
+		return errors.Wrap(err, "waiting for initial block")
+	}
 
+	// Errors exported by package validation, such as a failed
+	// control program, keep their roots so clients can tell
+	// them apart; other validation failures are ErrRejected.
 	err = c.ValidateTx(tx.Tx)
 	if errors.Root(err) == protocol.ErrBadTx {
 		return errors.Sub(ErrRejected, err)
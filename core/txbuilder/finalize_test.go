@@ -206,6 +206,45 @@ func TestInvalidTx(t *testing.T) {
 	}
 }
 
+func TestTxLimit(t *testing.T) {
+	c := prottest.NewChain(t)
+	ctx := context.Background()
+
+	bigOutputs := make([]*legacy.TxOutput, 11)
+	for i := range bigOutputs {
+		bigOutputs[i] = legacy.NewTxOutput(bc.AssetID{}, 0, nil, make([]byte, legacy.MaxReferenceDataSize))
+	}
+	cases := []struct {
+		name    string
+		refData []byte
+		outputs []*legacy.TxOutput
+	}{
+		{"big reference data", make([]byte, legacy.MaxReferenceDataSize+1), nil},
+		{"too big for a block", nil, bigOutputs},
+	}
+	for _, tc := range cases {
+		tx := legacy.NewTx(legacy.TxData{
+			Version: 1,
+			Inputs: []*legacy.TxInput{
+				legacy.NewSpendInput(nil, bc.Hash{}, bc.AssetID{}, 1, 0, []byte{byte(vm.OP_TRUE)}, bc.Hash{}, nil),
+			},
+			Outputs: append([]*legacy.TxOutput{
+				legacy.NewTxOutput(bc.AssetID{}, 1, nil, nil),
+			}, tc.outputs...),
+			ReferenceData: tc.refData,
+		})
+		h := tx.SigHash(0)
+		prog := append([]byte{byte(vm.OP_DATA_32)}, h.Bytes()...)
+		prog = append(prog, byte(vm.OP_TXSIGHASH), byte(vm.OP_EQUAL))
+		tx.SetInputArguments(0, [][]byte{{}, {}, prog})
+
+		err := FinalizeTx(ctx, c, nil, tx)
+		if errors.Root(err) != legacy.ErrTxLimit {
+			t.Errorf("%s: got error %s, want %s", tc.name, err, legacy.ErrTxLimit)
+		}
+	}
+}
+
 func TestTransferConfirmed(t *testing.T) {
 	_, db := pgtest.NewDB(t, pgtest.SchemaPath)
 	ctx := context.Background()
@@ -0,0 +1,174 @@
+package txbuilder
+
+import (
+	"bytes"
+
+	chainjson "chain/encoding/json"
+	"chain/errors"
+)
+
+var (
+	// ErrTemplateMismatch is returned by MergeTemplates when
+	// the templates aren't copies of the same transaction.
+	ErrTemplateMismatch = errors.New("templates do not match")
+
+	// ErrSignatureConflict is returned by MergeTemplates when
+	// two templates hold different signatures for the same key.
+	ErrSignatureConflict = errors.New("conflicting signatures")
+)
+
+// MissingSignatures describes a signature witness component
+// that has fewer signatures than its quorum requires.
+type MissingSignatures struct {
+	Position  uint32 `json:"position"`
+	Component int    `json:"witness_component"`
+	Quorum    int    `json:"quorum"`
+	Signed    int    `json:"signed"`
+}
+
+// MergeTemplates adds the signatures in others to a copy of
+// base, which must all be copies of the same template signed by
+// different parties, and returns the copy. The raw transaction's
+// witnesses are rebuilt from the combined signatures. Neither
+// base nor others are changed.
+//
+// It is an error for the templates to describe different
+// transactions or different signing instructions, or to hold
+// different signatures for the same key.
+func MergeTemplates(base *Template, others ...*Template) (*Template, error) {
+	if base.Transaction == nil {
+		return nil, errors.Wrap(ErrMissingRawTx)
+	}
+	base = copyTemplate(base)
+	for i, other := range others {
+		err := mergeTemplate(base, other)
+		if err != nil {
+			return nil, errors.WithDetailf(err, "merging template %d", i+1)
+		}
+	}
+
+	// Signature programs aren't part of the encoded template.
+	// They're computed from the transaction, so every party
+	// signed the same one.
+	for _, sigInst := range base.SigningInstructions {
+		for _, sw := range sigInst.SignatureWitnesses {
+			if len(sw.Program) == 0 {
+				sw.Program = buildSigProgram(base, sigInst.Position)
+			}
+		}
+	}
+	err := materializeWitnesses(base)
+	if err != nil {
+		return nil, err
+	}
+	return base, nil
+}
+
+// copyTemplate returns a copy of tpl that shares nothing
+// MergeTemplates changes: the transaction, the signing
+// instructions and their signature witnesses.
+func copyTemplate(tpl *Template) *Template {
+	c := *tpl
+	c.Transaction = tpl.Transaction.Copy()
+	c.SigningInstructions = make([]*SigningInstruction, 0, len(tpl.SigningInstructions))
+	for _, sigInst := range tpl.SigningInstructions {
+		si := *sigInst
+		si.SignatureWitnesses = make([]*signatureWitness, 0, len(sigInst.SignatureWitnesses))
+		for _, sw := range sigInst.SignatureWitnesses {
+			w := *sw
+			w.Sigs = append([]chainjson.HexBytes(nil), sw.Sigs...)
+			si.SignatureWitnesses = append(si.SignatureWitnesses, &w)
+		}
+		c.SigningInstructions = append(c.SigningInstructions, &si)
+	}
+	return &c
+}
+
+func mergeTemplate(base, other *Template) error {
+	if other.Transaction == nil {
+		return errors.Wrap(ErrMissingRawTx)
+	}
+	// Transaction IDs don't cover witnesses, so copies of a
+	// transaction signed by different parties have the same ID.
+	if other.Transaction.ID != base.Transaction.ID {
+		return errors.WithDetail(ErrTemplateMismatch, "transactions differ")
+	}
+	if other.AllowAdditional != base.AllowAdditional {
+		return errors.WithDetail(ErrTemplateMismatch, "allow_additional_actions differs")
+	}
+	if len(other.SigningInstructions) != len(base.SigningInstructions) {
+		return errors.WithDetail(ErrTemplateMismatch, "signing instructions differ")
+	}
+
+	for i, sigInst := range base.SigningInstructions {
+		otherInst := other.SigningInstructions[i]
+		if otherInst.Position != sigInst.Position || len(otherInst.SignatureWitnesses) != len(sigInst.SignatureWitnesses) {
+			return errors.WithDetailf(ErrTemplateMismatch, "signing instruction %d differs", i)
+		}
+		for j, sw := range sigInst.SignatureWitnesses {
+			err := mergeSignatures(sw, otherInst.SignatureWitnesses[j])
+			if err != nil {
+				return errors.WithDetailf(err, "witness component %d of input %d", j, sigInst.Position)
+			}
+		}
+	}
+	return nil
+}
+
+func mergeSignatures(sw, other *signatureWitness) error {
+	if other.Quorum != sw.Quorum || len(other.Keys) != len(sw.Keys) {
+		return errors.Wrap(ErrTemplateMismatch)
+	}
+	for i, k := range sw.Keys {
+		if other.Keys[i].XPub != k.XPub {
+			return errors.WithDetailf(ErrTemplateMismatch, "key %d differs", i)
+		}
+	}
+
+	if len(sw.Sigs) < len(sw.Keys) {
+		newSigs := make([]chainjson.HexBytes, len(sw.Keys))
+		copy(newSigs, sw.Sigs)
+		sw.Sigs = newSigs
+	}
+	for i, sig := range other.Sigs {
+		if i >= len(sw.Sigs) || len(sig) == 0 {
+			continue
+		}
+		if len(sw.Sigs[i]) == 0 {
+			sw.Sigs[i] = sig
+		} else if !bytes.Equal(sw.Sigs[i], sig) {
+			return errors.WithDetailf(ErrSignatureConflict, "key %d has two different signatures", i)
+		}
+	}
+	return nil
+}
+
+// Missing returns the signature witness components in tpl
+// that don't yet have enough signatures.
+func (tpl *Template) Missing() []MissingSignatures {
+	var missing []MissingSignatures
+	for _, sigInst := range tpl.SigningInstructions {
+		for j, sw := range sigInst.SignatureWitnesses {
+			if n := sw.signatures(); n < sw.Quorum {
+				missing = append(missing, MissingSignatures{
+					Position:  sigInst.Position,
+					Component: j,
+					Quorum:    sw.Quorum,
+					Signed:    n,
+				})
+			}
+		}
+	}
+	return missing
+}
+
+// signatures returns the number of signatures in sw.
+func (sw *signatureWitness) signatures() int {
+	var n int
+	for _, sig := range sw.Sigs {
+		if len(sig) > 0 {
+			n++
+		}
+	}
+	return n
+}
@@ -0,0 +1,140 @@
+package txbuilder_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"chain/core/account"
+	"chain/core/coretest"
+	"chain/core/generator"
+	. "chain/core/txbuilder"
+	"chain/crypto/ed25519/chainkd"
+	"chain/database/pg/pgtest"
+	"chain/errors"
+	"chain/protocol/bc"
+	"chain/protocol/prottest"
+	"chain/testutil"
+)
+
+func TestMergeTemplates(t *testing.T) {
+	_, db := pgtest.NewDB(t, pgtest.SchemaPath)
+	ctx := context.Background()
+	info, err := bootdb(ctx, db, t)
+	if err != nil {
+		t.Fatal(err)
+	}
+	g := generator.New(info.Chain, nil, db)
+
+	// A 2-of-3 escrow account, with each key held
+	// by a different party.
+	xprvs := []chainkd.XPrv{testutil.TestXPrv}
+	for i := 0; i < 2; i++ {
+		xprv, _, err := chainkd.NewXKeys(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		xprvs = append(xprvs, xprv)
+	}
+	var xpubs []chainkd.XPub
+	for _, xprv := range xprvs {
+		xpubs = append(xpubs, xprv.XPub())
+	}
+	escrow, err := info.Manager.Create(ctx, xpubs, 2, "", nil, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = issue(ctx, t, info, g, escrow.ID, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	prottest.MakeBlock(t, info.Chain, g.PendingTxs())
+	<-info.pinStore.PinWaiter(account.PinName, info.Chain.Height())
+
+	assetAmount := bc.AssetAmount{AssetId: &info.asset, Amount: 10}
+	tpl, err := Build(ctx, nil, []Action{
+		info.NewSpendAction(assetAmount, escrow.ID, nil, nil),
+		info.NewControlAction(assetAmount, info.acctA, nil),
+	}, time.Now().Add(time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Each party signs its own copy of the template,
+	// received over the wire.
+	var copies []*Template
+	for i := range xprvs {
+		c := copyTemplate(t, tpl)
+		if i < 2 {
+			coretest.SignTxTemplate(t, ctx, c, &xprvs[i])
+		}
+		copies = append(copies, c)
+	}
+	if missing := copies[0].Missing(); len(missing) != 1 || missing[0].Signed != 1 || missing[0].Quorum != 2 {
+		t.Errorf("missing signatures after one party signed = %+v, want 1 of 2", missing)
+	}
+
+	// Two templates, each holding the other's signature,
+	// conflict if the signatures for a key differ.
+	conflicting := copyTemplate(t, copies[1])
+	coretest.SignTxTemplate(t, ctx, conflicting, &xprvs[0])
+	conflicting.SigningInstructions[0].SignatureWitnesses[0].Sigs[0][0] ^= 0xff
+	_, err = MergeTemplates(copyTemplate(t, copies[0]), conflicting)
+	if errors.Root(err) != ErrSignatureConflict {
+		t.Errorf("merging conflicting signatures error = %v want %v", err, ErrSignatureConflict)
+	}
+
+	// Templates for different transactions don't merge.
+	other, err := Build(ctx, nil, []Action{
+		info.NewIssueAction(assetAmount, nil),
+		info.NewControlAction(assetAmount, info.acctB, nil),
+	}, time.Now().Add(time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = MergeTemplates(copyTemplate(t, copies[0]), other)
+	if errors.Root(err) != ErrTemplateMismatch {
+		t.Errorf("merging different transactions error = %v want %v", err, ErrTemplateMismatch)
+	}
+
+	before, err := json.Marshal(copies[2])
+	if err != nil {
+		t.Fatal(err)
+	}
+	merged, err := MergeTemplates(copies[2], copies[0], copies[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if missing := merged.Missing(); len(missing) != 0 {
+		t.Errorf("missing signatures after merge = %+v, want none", missing)
+	}
+	after, err := json.Marshal(copies[2])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(after) != string(before) {
+		t.Errorf("merge changed the base template:\ngot:  %s\nwant: %s", after, before)
+	}
+	err = FinalizeTx(ctx, info.Chain, g, merged.Transaction)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := prottest.MakeBlock(t, info.Chain, g.PendingTxs())
+	if len(b.Transactions) != 1 || b.Transactions[0].ID != tpl.Transaction.ID {
+		t.Errorf("block transactions = %v, want only the merged transaction", b.Transactions)
+	}
+}
+
+func copyTemplate(t testing.TB, tpl *Template) *Template {
+	b, err := json.Marshal(tpl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := new(Template)
+	err = json.Unmarshal(b, c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
@@ -86,6 +86,10 @@ func TestBuild(t *testing.T) {
 		t.Errorf("got signing instructions:\n\t%#v\nwant signing instructions:\n\t%#v", got.SigningInstructions, want.SigningInstructions)
 	}
 
+	if !got.ReservationExpiresAt.Equal(expiryTime) {
+		t.Errorf("got reservation expiry %s, want %s", got.ReservationExpiresAt, expiryTime)
+	}
+
 	// setting tx refdata twice should fail
 	actions = append(actions, &setTxRefDataAction{Data: []byte("lmnop")})
 	_, err = Build(ctx, nil, actions, expiryTime)
@@ -101,6 +105,39 @@ func TestBuild(t *testing.T) {
 	}
 }
 
+func TestBuildCopiesBase(t *testing.T) {
+	ctx := context.Background()
+
+	assetID := bc.NewAssetID([32]byte{1})
+	base := &legacy.TxData{
+		Version: 1,
+		Inputs: []*legacy.TxInput{
+			legacy.NewSpendInput([][]byte{{1}}, bc.NewHash([32]byte{0xee}), assetID, 5, 0, []byte("prog"), bc.Hash{}, nil),
+		},
+		Outputs: []*legacy.TxOutput{
+			legacy.NewTxOutput(assetID, 5, []byte("dest"), nil),
+		},
+	}
+	want := base.Copy()
+
+	actions := []Action{testAction(bc.AssetAmount{AssetId: &assetID, Amount: 5})}
+	tpl, err := Build(ctx, base, actions, time.Now().Add(time.Minute))
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	if len(tpl.Transaction.Inputs) != 2 || len(tpl.Transaction.Outputs) != 2 {
+		t.Fatalf("built tx has %d inputs and %d outputs, want 2 and 2", len(tpl.Transaction.Inputs), len(tpl.Transaction.Outputs))
+	}
+
+	// Signing the template changes its input arguments.
+	tpl.Transaction.Inputs[0].SetArguments([][]byte{{2}})
+	tpl.Transaction.Inputs[0].ControlProgram()[0] = 'P'
+
+	if !testutil.DeepEqual(*base, want) {
+		t.Errorf("base tx changed to:\n%s\nwant:\n%s", spew.Sdump(*base), spew.Sdump(want))
+	}
+}
+
 func TestMaterializeWitnesses(t *testing.T) {
 	var initialBlockHash bc.Hash
 	privkey, pubkey, err := chainkd.NewXKeys(nil)
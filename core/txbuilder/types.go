@@ -11,8 +11,25 @@ import (
 	"chain/protocol/bc/legacy"
 )
 
+// TemplateVersion is the version of the template format
+// this package produces. Version 2 added the reservation
+// expiry and reservation IDs, version 3 added key
+// fingerprints, and version 4 added the transaction's time
+// range. Templates without a version are version 1; they
+// are still accepted, with the fields added since filled in
+// where they can be computed and left empty otherwise.
+const TemplateVersion = 4
+
+// ErrTemplateVersion is returned when decoding a template
+// whose format is newer than TemplateVersion.
+var ErrTemplateVersion = errors.New("unsupported template version")
+
 // Template represents a partially- or fully-signed transaction.
 type Template struct {
+	// Version is the template format version. Templates
+	// are always encoded with the current version.
+	Version int `json:"version"`
+
 	Transaction         *legacy.Tx            `json:"raw_transaction"`
 	SigningInstructions []*SigningInstruction `json:"signing_instructions"`
 
@@ -28,6 +45,83 @@ type Template struct {
 	// ones cannot be changed. When false, signatures commit to the tx
 	// as a whole, and any change to the tx invalidates the signature.
 	AllowAdditional bool `json:"allow_additional_actions"`
+
+	// ReservationExpiresAt is when the outputs reserved while
+	// building the transaction are released. The transaction
+	// must be submitted before then.
+	ReservationExpiresAt time.Time `json:"reservation_expires_at"`
+}
+
+// MarshalJSON implements json.Marshaler. It always
+// encodes t in the current template format.
+//
+// The encoding includes the transaction's min and max
+// times, if it has them, for clients that can't decode
+// the raw transaction. They're ignored when decoding.
+func (t Template) MarshalJSON() ([]byte, error) {
+	type template Template
+	t.Version = TemplateVersion
+	v := struct {
+		template
+		MinTime *time.Time `json:"min_time,omitempty"`
+		MaxTime *time.Time `json:"max_time,omitempty"`
+	}{template: template(t)}
+	if t.Transaction != nil {
+		v.MinTime = millisTime(t.Transaction.MinTime)
+		v.MaxTime = millisTime(t.Transaction.MaxTime)
+	}
+	return json.Marshal(v)
+}
+
+func millisTime(ms uint64) *time.Time {
+	if ms == 0 {
+		return nil
+	}
+	t := time.Unix(0, int64(ms)*int64(time.Millisecond)).UTC()
+	return &t
+}
+
+// UnmarshalJSON implements json.Unmarshaler. It accepts
+// the current template format and every earlier one,
+// upgrading them to the current version.
+func (t *Template) UnmarshalJSON(b []byte) error {
+	var v struct {
+		Version *int `json:"version"`
+	}
+	err := json.Unmarshal(b, &v)
+	if err != nil {
+		return err
+	}
+	version := 1
+	if v.Version != nil {
+		version = *v.Version
+	}
+	if version < 1 {
+		return errors.WithDetailf(ErrTemplateVersion, "invalid template version %d", version)
+	}
+	if version > TemplateVersion {
+		return errors.WithDetailf(ErrTemplateVersion, "template version %d is newer than the latest version this core supports (%d); upgrade Chain Core to use it", version, TemplateVersion)
+	}
+
+	// Fields added since version 1 are optional,
+	// so decoding leaves them empty for older
+	// templates.
+	type template Template
+	err = json.Unmarshal(b, (*template)(t))
+	if err != nil {
+		return err
+	}
+	if version < 3 {
+		for _, sigInst := range t.SigningInstructions {
+			for _, sw := range sigInst.SignatureWitnesses {
+				for i, k := range sw.Keys {
+					sw.Keys[i] = newKeyID(k.XPub, k.DerivationPath)
+				}
+			}
+		}
+	}
+	t.Version = TemplateVersion
+	return nil
 }
 
 func (t *Template) Hash(idx uint32) bc.Hash {
@@ -38,11 +132,17 @@ func (t *Template) Hash(idx uint32) bc.Hash {
 type SigningInstruction struct {
 	Position           uint32              `json:"position"`
 	SignatureWitnesses []*signatureWitness `json:"witness_components,omitempty"`
+
+	// ReservationID identifies the reservation holding the
+	// output spent by this input, if there is one. It can be
+	// used to cancel the reservation before it expires.
+	ReservationID uint64 `json:"reservation_id,omitempty"`
 }
 
 func (si *SigningInstruction) UnmarshalJSON(b []byte) error {
 	var pre struct {
 		Position           uint32 `json:"position"`
+		ReservationID      uint64 `json:"reservation_id"`
 		SignatureWitnesses []struct {
 			Type string
 			signatureWitness
@@ -54,6 +154,7 @@ func (si *SigningInstruction) UnmarshalJSON(b []byte) error {
 	}
 
 	si.Position = pre.Position
+	si.ReservationID = pre.ReservationID
 	si.SignatureWitnesses = make([]*signatureWitness, 0, len(pre.SignatureWitnesses))
 	for i, w := range pre.SignatureWitnesses {
 		if w.Type != "signature" {
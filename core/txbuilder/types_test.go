@@ -1,6 +1,15 @@
 package txbuilder
 
-import "testing"
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"chain/errors"
+	"chain/testutil"
+)
 
 func BenchmarkUnmarshalSigningInstruction(b *testing.B) {
 	const exampleJSON = `{"asset_id":"580b3aeee65682854e0b9e68a8ed2061ee39b63c0ce99ad27b4e5255e8e08069","amount":200,"position":6,"witness_components":[{"type":"signature","quorum":1,"keys":[{"xpub":"55dbba57aa1b5b861ff943ee7b99bed180fbf65fb14adb380297a5a359afa2fe686b49ff2fad14bb058a50eb5fae66983e8940a00d27641741ca74bb12faed40","derivation_path":["01d700000000000000","0900000000000000"]},{"xpub":"e372b90d7725bf9cc7b78297cf8ba0b6d1a1378f8b336d0ee3ffd81f777b67f1bb77acd04210a90a1f32817cc4019860ed5da13aee7153e10d86954774bf024c","derivation_path":["01d700000000000000","0900000000000000"]},{"xpub":"5aece801cf5b891f099ddab8e6c17c2f5bbcaf8cd9416b239748d7183a0fd5068f30787b4fc3a8a2514a4f4385d00eb55479e71e6d73964e63bb59340235ef78","derivation_path":["01d700000000000000","0900000000000000"]}],"signatures":["f1536ac79a27d5429fee18a6457baaabecc7ac0cdde728eb80304ce72211f6b709b9d4e397076b71a7cbb7f11e5d0a86e1cf3cfae5e67df811a77e8ff84ed60b","c9dcb0fae61bdbb524118b03b46273253ad347acc9a538ce41ece49b4ad8ffba7f4161a1421c714fbdb749d208d992d4332938c1ca8fdecf2b4493ab21cf2600","e53152ae79ec1a8c77c096250047aeb3d8dff7f6b61c70888fe42375e602a34516ba761302b7299dd5ecb5250d7efa25fe5d20fc305a3c726cec6089468e470b"]},{"type":"signature","quorum":1,"keys":[{"xpub":"55dbba57aa1b5b861ff943ee7b99bed180fbf65fb14adb380297a5a359afa2fe686b49ff2fad14bb058a50eb5fae66983e8940a00d27641741ca74bb12faed40","derivation_path":["01d700000000000000","0900000000000000"]},{"xpub":"e372b90d7725bf9cc7b78297cf8ba0b6d1a1378f8b336d0ee3ffd81f777b67f1bb77acd04210a90a1f32817cc4019860ed5da13aee7153e10d86954774bf024c","derivation_path":["01d700000000000000","0900000000000000"]},{"xpub":"5aece801cf5b891f099ddab8e6c17c2f5bbcaf8cd9416b239748d7183a0fd5068f30787b4fc3a8a2514a4f4385d00eb55479e71e6d73964e63bb59340235ef78","derivation_path":["01d700000000000000","0900000000000000"]}],"signatures":["f1536ac79a27d5429fee18a6457baaabecc7ac0cdde728eb80304ce72211f6b709b9d4e397076b71a7cbb7f11e5d0a86e1cf3cfae5e67df811a77e8ff84ed60b","c9dcb0fae61bdbb524118b03b46273253ad347acc9a538ce41ece49b4ad8ffba7f4161a1421c714fbdb749d208d992d4332938c1ca8fdecf2b4493ab21cf2600","e53152ae79ec1a8c77c096250047aeb3d8dff7f6b61c70888fe42375e602a34516ba761302b7299dd5ecb5250d7efa25fe5d20fc305a3c726cec6089468e470b"]}]}`
@@ -12,3 +21,102 @@ func BenchmarkUnmarshalSigningInstruction(b *testing.B) {
 		}
 	}
 }
+
+// The files in testdata hold a template in each released
+// version of the format. They must never change: old clients
+// and cores depend on these encodings.
+func TestTemplateVersions(t *testing.T) {
+	v1 := readTemplateFixture(t, "template-v1.json")
+	v4 := readTemplateFixture(t, "template-v4.json")
+
+	// Encoding a template in the current format
+	// reproduces its fixture exactly.
+	var tpl Template
+	err := json.Unmarshal(v4, &tpl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := json.Marshal(tpl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, v4) {
+		t.Errorf("re-encoded version 4 template:\n%s\nwant:\n%s", got, v4)
+	}
+
+	// Templates in versions 2 and 3 decode to the same
+	// template. The fields added since can be computed:
+	// key fingerprints from the keys, and the time range
+	// from the transaction.
+	var old Template
+	for _, name := range []string{"template-v2.json", "template-v3.json"} {
+		old = Template{}
+		err = json.Unmarshal(readTemplateFixture(t, name), &old)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !testutil.DeepEqual(old, tpl) {
+			t.Errorf("decoded %s = %+v want %+v", name, old, tpl)
+		}
+	}
+
+	// A version 1 template decodes to the same template,
+	// without the fields added in version 2.
+	old = Template{}
+	err = json.Unmarshal(v1, &old)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if old.Version != TemplateVersion {
+		t.Errorf("decoded version 1 template has version %d, want %d", old.Version, TemplateVersion)
+	}
+	want := tpl
+	want.ReservationExpiresAt = time.Time{}
+	want.SigningInstructions = []*SigningInstruction{{
+		Position:           tpl.SigningInstructions[0].Position,
+		SignatureWitnesses: tpl.SigningInstructions[0].SignatureWitnesses,
+	}}
+	if !testutil.DeepEqual(old, want) {
+		t.Errorf("decoded version 1 template = %+v want %+v", old, want)
+	}
+	if old.Transaction.ID != tpl.Transaction.ID {
+		t.Errorf("version 1 transaction id = %x want %x", old.Transaction.ID.Bytes(), tpl.Transaction.ID.Bytes())
+	}
+
+	// Upgraded templates round-trip in the current format.
+	b, err := json.Marshal(old)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var again Template
+	err = json.Unmarshal(b, &again)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !testutil.DeepEqual(again, old) {
+		t.Errorf("round-tripped version 1 template = %+v want %+v", again, old)
+	}
+}
+
+func TestTemplateUnsupportedVersion(t *testing.T) {
+	for _, v := range []string{"0", "-1", "5"} {
+		var tpl Template
+		err := json.Unmarshal([]byte(`{"version": `+v+`}`), &tpl)
+		if errors.Root(err) != ErrTemplateVersion {
+			t.Errorf("decoding version %s error = %v want %v", v, err, ErrTemplateVersion)
+		}
+	}
+}
+
+func readTemplateFixture(t *testing.T, name string) []byte {
+	b, err := ioutil.ReadFile("testdata/" + name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	err = json.Compact(&buf, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
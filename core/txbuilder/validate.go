@@ -0,0 +1,150 @@
+package txbuilder
+
+import (
+	"fmt"
+
+	"chain/math/checked"
+	"chain/protocol"
+	"chain/protocol/bc"
+	"chain/protocol/validation"
+	"chain/protocol/vm"
+)
+
+// The kinds of problem ValidateTemplate finds.
+const (
+	CheckStructure = "structure"
+	CheckBalance   = "balance"
+	CheckTime      = "time"
+	CheckUnspent   = "unspent"
+	CheckProgram   = "program"
+)
+
+// A Finding is a reason the transaction in a template
+// would fail validation.
+type Finding struct {
+	Check   string `json:"check"`
+	Message string `json:"message"`
+}
+
+// ValidateTemplate checks the transaction in tpl against the
+// rules it must satisfy to be accepted by c, and returns
+// everything it finds wrong. It checks the control or issuance
+// program of each input whose signatures are complete; other
+// inputs are expected to be signed later.
+//
+// It doesn't change tpl, and doesn't submit or reserve anything.
+func ValidateTemplate(c *protocol.Chain, tpl *Template) []Finding {
+	var findings []Finding
+	report := func(check, format string, args ...interface{}) {
+		findings = append(findings, Finding{Check: check, Message: fmt.Sprintf(format, args...)})
+	}
+
+	tx := tpl.Transaction
+	if tx == nil {
+		report(CheckStructure, "template has no raw transaction")
+		return findings
+	}
+	if len(tx.Inputs) == 0 {
+		report(CheckStructure, "transaction has no inputs")
+	}
+	signing := make(map[uint32]*SigningInstruction)
+	for i, sigInst := range tpl.SigningInstructions {
+		if int(sigInst.Position) >= len(tx.Inputs) {
+			report(CheckStructure, "signing instruction %d is for input %d, which doesn't exist", i, sigInst.Position)
+			continue
+		}
+		if signing[sigInst.Position] != nil {
+			report(CheckStructure, "input %d has more than one signing instruction", sigInst.Position)
+			continue
+		}
+		signing[sigInst.Position] = sigInst
+	}
+
+	// Every asset's inputs and outputs must have the same total.
+	var (
+		assets  []bc.AssetID
+		inputs  = make(map[bc.AssetID]uint64)
+		outputs = make(map[bc.AssetID]uint64)
+		ok      bool
+	)
+	for _, in := range tx.Inputs {
+		assetID := in.AssetID()
+		if _, seen := inputs[assetID]; !seen {
+			assets = append(assets, assetID)
+		}
+		inputs[assetID], ok = checked.AddUint64(inputs[assetID], in.Amount())
+		if !ok {
+			report(CheckBalance, "input amounts of asset %x overflow", assetID.Bytes())
+		}
+	}
+	for _, out := range tx.Outputs {
+		assetID := *out.AssetId
+		_, seenIn := inputs[assetID]
+		_, seenOut := outputs[assetID]
+		if !seenIn && !seenOut {
+			assets = append(assets, assetID)
+		}
+		outputs[assetID], ok = checked.AddUint64(outputs[assetID], out.Amount)
+		if !ok {
+			report(CheckBalance, "output amounts of asset %x overflow", assetID.Bytes())
+		}
+	}
+	for _, assetID := range assets {
+		if inputs[assetID] != outputs[assetID] {
+			report(CheckBalance, "asset %x has %d in inputs but %d in outputs", assetID.Bytes(), inputs[assetID], outputs[assetID])
+		}
+	}
+
+	if tx.MinTime > 0 && tx.MaxTime > 0 && tx.MinTime > tx.MaxTime {
+		report(CheckTime, "min time %d is after max time %d", tx.MinTime, tx.MaxTime)
+	}
+	if now := c.TimestampMS(); tx.MaxTime > 0 && tx.MaxTime < now {
+		report(CheckTime, "max time %d has passed; the latest block is at %d", tx.MaxTime, now)
+	}
+
+	_, snapshot := c.State()
+	for i := range tx.Inputs {
+		var (
+			entry bc.Entry
+			prog  *bc.Program
+			args  [][]byte
+		)
+		id := tx.Tx.InputIDs[i]
+		if sp, err := tx.Tx.Spend(id); err == nil {
+			if !snapshot.Tree.Contains(sp.SpentOutputId.Bytes()) {
+				report(CheckUnspent, "input %d spends output %x, which is spent or doesn't exist", i, sp.SpentOutputId.Bytes())
+			}
+			spent, err := tx.Tx.Output(*sp.SpentOutputId)
+			if err != nil {
+				report(CheckStructure, "input %d: %s", i, err)
+				continue
+			}
+			entry, prog, args = sp, spent.ControlProgram, sp.WitnessArguments
+		} else if iss, err := tx.Tx.Issuance(id); err == nil {
+			entry, prog, args = iss, iss.WitnessAssetDefinition.IssuanceProgram, iss.WitnessArguments
+		} else {
+			continue
+		}
+
+		sigInst := signing[uint32(i)]
+		if sigInst == nil && len(args) == 0 || sigInst != nil && !signed(sigInst) {
+			continue
+		}
+		err := vm.Verify(validation.NewTxVMContext(tx.Tx, entry, prog, args))
+		if err != nil {
+			report(CheckProgram, "input %d: %s", i, err)
+		}
+	}
+	return findings
+}
+
+// signed reports whether every witness component
+// in sigInst has as many signatures as it needs.
+func signed(sigInst *SigningInstruction) bool {
+	for _, sw := range sigInst.SignatureWitnesses {
+		if sw.signatures() < sw.Quorum {
+			return false
+		}
+	}
+	return true
+}
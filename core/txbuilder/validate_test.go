@@ -0,0 +1,79 @@
+package txbuilder_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"chain/core/account"
+	"chain/core/coretest"
+	"chain/core/generator"
+	. "chain/core/txbuilder"
+	"chain/database/pg/pgtest"
+	"chain/protocol/bc"
+	"chain/protocol/prottest"
+	"chain/testutil"
+)
+
+func TestValidateTemplate(t *testing.T) {
+	_, db := pgtest.NewDB(t, pgtest.SchemaPath)
+	ctx := context.Background()
+	info, err := bootdb(ctx, db, t)
+	if err != nil {
+		t.Fatal(err)
+	}
+	g := generator.New(info.Chain, nil, db)
+	_, err = issue(ctx, t, info, g, info.acctA, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	prottest.MakeBlock(t, info.Chain, g.PendingTxs())
+	<-info.pinStore.PinWaiter(account.PinName, info.Chain.Height())
+
+	amount := func(n uint64) bc.AssetAmount {
+		return bc.AssetAmount{AssetId: &info.asset, Amount: n}
+	}
+	build := func(spend, control uint64, maxTime time.Time) *Template {
+		tpl, err := Build(ctx, nil, []Action{
+			info.NewSpendAction(amount(spend), info.acctA, nil, nil),
+			info.NewControlAction(amount(control), info.acctB, nil),
+		}, maxTime)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return tpl
+	}
+	later := time.Now().Add(time.Minute)
+
+	// A balanced transaction has nothing wrong with it,
+	// before or after it's signed.
+	tpl := build(10, 10, later)
+	if got := ValidateTemplate(info.Chain, tpl); len(got) != 0 {
+		t.Errorf("unsigned balanced template findings = %+v, want none", got)
+	}
+	coretest.SignTxTemplate(t, ctx, tpl, nil)
+	if got := ValidateTemplate(info.Chain, tpl); len(got) != 0 {
+		t.Errorf("signed balanced template findings = %+v, want none", got)
+	}
+	info.Manager.CancelTemplateReservations(ctx, tpl)
+
+	tpl = build(10, 4, later)
+	want := []Finding{{
+		Check:   CheckBalance,
+		Message: fmt.Sprintf("asset %x has 10 in inputs but 4 in outputs", info.asset.Bytes()),
+	}}
+	if got := ValidateTemplate(info.Chain, tpl); !testutil.DeepEqual(got, want) {
+		t.Errorf("unbalanced template findings = %+v, want %+v", got, want)
+	}
+	info.Manager.CancelTemplateReservations(ctx, tpl)
+
+	tpl = build(10, 10, time.Now().Add(-time.Hour))
+	want = []Finding{{
+		Check:   CheckTime,
+		Message: fmt.Sprintf("max time %d has passed; the latest block is at %d", tpl.Transaction.MaxTime, info.Chain.TimestampMS()),
+	}}
+	if got := ValidateTemplate(info.Chain, tpl); !testutil.DeepEqual(got, want) {
+		t.Errorf("expired template findings = %+v, want %+v", got, want)
+	}
+}
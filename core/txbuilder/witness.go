@@ -70,7 +70,14 @@ type (
 	}
 
 	keyID struct {
-		XPub           chainkd.XPub         `json:"xpub"`
+		XPub chainkd.XPub `json:"xpub"`
+
+		// Fingerprint identifies the root key XPub, so a
+		// signer can find it without comparing whole keys.
+		Fingerprint chainjson.HexBytes `json:"fingerprint"`
+
+		// DerivationPath is the complete path from XPub to
+		// the key that signs, as produced by signers.Path.
 		DerivationPath []chainjson.HexBytes `json:"derivation_path"`
 	}
 )
@@ -116,6 +123,9 @@ func (sw *signatureWitness) sign(ctx context.Context, tpl *Template, index uint3
 		if !contains(xpubs, keyID.XPub) {
 			continue
 		}
+		if fp := keyID.XPub.Fingerprint(); len(keyID.Fingerprint) > 0 && !bytes.Equal(keyID.Fingerprint, fp[:]) {
+			return errors.WithDetailf(ErrBadWitnessComponent, "key %d has fingerprint %x, but its xpub's is %x", i, keyID.Fingerprint, fp[:])
+		}
 		path := make([]([]byte), len(keyID.DerivationPath))
 		for i, p := range keyID.DerivationPath {
 			path[i] = p
@@ -224,6 +234,11 @@ func (sw signatureWitness) MarshalJSON() ([]byte, error) {
 	return json.Marshal(obj)
 }
 
+func newKeyID(xpub chainkd.XPub, path []chainjson.HexBytes) keyID {
+	fp := xpub.Fingerprint()
+	return keyID{XPub: xpub, Fingerprint: fp[:], DerivationPath: path}
+}
+
 // AddWitnessKeys adds a signatureWitness with the given quorum and
 // list of keys derived by applying the derivation path to each of the
 // xpubs.
@@ -235,7 +250,7 @@ func (si *SigningInstruction) AddWitnessKeys(xpubs []chainkd.XPub, path [][]byte
 
 	keyIDs := make([]keyID, 0, len(xpubs))
 	for _, xpub := range xpubs {
-		keyIDs = append(keyIDs, keyID{xpub, hexPath})
+		keyIDs = append(keyIDs, newKeyID(xpub, hexPath))
 	}
 
 	sw := &signatureWitness{
@@ -2,13 +2,16 @@ package txbuilder
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"testing"
 
 	"github.com/davecgh/go-spew/spew"
 
+	"chain/crypto/ed25519/chainkd"
 	chainjson "chain/encoding/json"
+	"chain/errors"
 	"chain/protocol/bc"
 	"chain/protocol/bc/legacy"
 	"chain/protocol/vm"
@@ -52,10 +55,9 @@ func TestWitnessJSON(t *testing.T) {
 		SignatureWitnesses: []*signatureWitness{
 			&signatureWitness{
 				Quorum: 4,
-				Keys: []keyID{{
-					XPub:           testutil.TestXPub,
-					DerivationPath: []chainjson.HexBytes{{5, 6, 7}},
-				}},
+				Keys: []keyID{
+					newKeyID(testutil.TestXPub, []chainjson.HexBytes{{5, 6, 7}}),
+				},
 				Sigs: []chainjson.HexBytes{{8, 9, 10}},
 			},
 		},
@@ -76,3 +78,42 @@ func TestWitnessJSON(t *testing.T) {
 		t.Errorf("got:\n%s\nwant:\n%s\nJSON was: %s", spew.Sdump(&got), spew.Sdump(si), string(b))
 	}
 }
+
+func TestSignFingerprint(t *testing.T) {
+	tpl := &Template{
+		Transaction: legacy.NewTx(legacy.TxData{
+			Inputs: []*legacy.TxInput{
+				legacy.NewSpendInput(nil, bc.Hash{}, bc.AssetID{}, 123, 0, nil, bc.Hash{}, nil),
+			},
+			Outputs: []*legacy.TxOutput{
+				legacy.NewTxOutput(bc.AssetID{}, 123, []byte{10, 11, 12}, nil),
+			},
+		}),
+		SigningInstructions: []*SigningInstruction{{}},
+	}
+	path := [][]byte{{1, 2}, {3}}
+	tpl.SigningInstructions[0].AddWitnessKeys([]chainkd.XPub{testutil.TestXPub}, path, 1)
+
+	// The signer gets the template's derivation path as is.
+	var gotPath [][]byte
+	signFn := func(_ context.Context, _ chainkd.XPub, p [][]byte, h [32]byte) ([]byte, error) {
+		gotPath = p
+		return testutil.TestXPrv.Derive(p).Sign(h[:]), nil
+	}
+	err := Sign(context.Background(), tpl, []chainkd.XPub{testutil.TestXPub}, signFn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !testutil.DeepEqual(gotPath, path) {
+		t.Errorf("signed with path %x, want %x", gotPath, path)
+	}
+
+	// A key whose fingerprint doesn't match its xpub isn't signed.
+	sw := tpl.SigningInstructions[0].SignatureWitnesses[0]
+	sw.Sigs = nil
+	sw.Keys[0].Fingerprint = chainjson.HexBytes{0, 0, 0, 0}
+	err = Sign(context.Background(), tpl, []chainkd.XPub{testutil.TestXPub}, signFn)
+	if errors.Root(err) != ErrBadWitnessComponent {
+		t.Errorf("signing with a bad fingerprint error = %v want %v", err, ErrBadWitnessComponent)
+	}
+}
@@ -37,6 +37,9 @@ type Snapshot struct {
 	// Nonces contains the record of recent nonces for ensuring
 	// uniqueness of issuances.
 	Nonces []*Snapshot_Nonce `protobuf:"bytes,2,rep,name=nonces" json:"nonces,omitempty"`
+	// Version is the version of this encoding. Snapshots
+	// written before versioning have version 0.
+	Version uint32 `protobuf:"varint,3,opt,name=version" json:"version,omitempty"`
 }
 
 func (m *Snapshot) Reset()                    { *m = Snapshot{} }
@@ -58,6 +61,13 @@ func (m *Snapshot) GetNonces() []*Snapshot_Nonce {
 	return nil
 }
 
+func (m *Snapshot) GetVersion() uint32 {
+	if m != nil {
+		return m.Version
+	}
+	return 0
+}
+
 type Snapshot_Nonce struct {
 	Hash     []byte `protobuf:"bytes,1,opt,name=hash,proto3" json:"hash,omitempty"`
 	ExpiryMs uint64 `protobuf:"varint,2,opt,name=expiry_ms,json=expiryMs" json:"expiry_ms,omitempty"`
@@ -86,19 +96,20 @@ func init() {
 func init() { proto.RegisterFile("snapshot.proto", fileDescriptor0) }
 
 var fileDescriptor0 = []byte{
-	// 218 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x09, 0x6e, 0x88, 0x02, 0xff, 0x94, 0x90, 0x3b, 0x4f, 0xc4, 0x30,
-	0x10, 0x84, 0xe5, 0x7b, 0x71, 0xb7, 0x3c, 0x84, 0x5c, 0x45, 0x47, 0x13, 0xa8, 0x52, 0xad, 0x10,
-	0x34, 0xa9, 0xa9, 0x68, 0x48, 0xe1, 0x50, 0xd1, 0x20, 0x27, 0x59, 0xe1, 0x08, 0xf0, 0x46, 0xb6,
-	0x8b, 0xa4, 0xe4, 0x9f, 0xa3, 0x38, 0xa6, 0xa0, 0x42, 0x74, 0xa3, 0x91, 0xbe, 0x6f, 0x56, 0x0b,
-	0x17, 0xde, 0xea, 0xc1, 0x1b, 0x0e, 0x38, 0x38, 0x0e, 0x2c, 0xf3, 0xd6, 0xe8, 0xde, 0x62, 0xcb,
-	0x8e, 0x30, 0x8c, 0x5d, 0x83, 0xbd, 0x0d, 0xe4, 0xac, 0xfe, 0x40, 0x1f, 0xd8, 0xe9, 0x37, 0xba,
-	0xf9, 0x5a, 0xc1, 0xbe, 0x4e, 0x90, 0xac, 0x60, 0x6b, 0xb9, 0x23, 0x9f, 0x89, 0x7c, 0x5d, 0x9c,
-	0xde, 0x95, 0xf8, 0x17, 0x8e, 0x3f, 0x28, 0xd6, 0x41, 0x07, 0x7a, 0x76, 0x44, 0x15, 0x77, 0xa4,
-	0x16, 0x8d, 0x7c, 0x84, 0x9d, 0x65, 0xdb, 0x92, 0xcf, 0x56, 0x51, 0x78, 0xfb, 0x0f, 0x61, 0x35,
-	0x83, 0x2a, 0xf1, 0xc7, 0x12, 0xb6, 0xb1, 0x90, 0x12, 0x36, 0x46, 0x7b, 0x93, 0x89, 0x5c, 0x14,
-	0x67, 0x2a, 0x66, 0x79, 0x05, 0x07, 0x1a, 0x87, 0xde, 0x4d, 0xaf, 0x9f, 0xf3, 0x92, 0x28, 0x36,
-	0x6a, 0xbf, 0x14, 0x4f, 0xfe, 0x78, 0x0d, 0xe7, 0xbf, 0x6e, 0x93, 0x97, 0xb0, 0x7e, 0xa7, 0x29,
-	0x09, 0xe6, 0xf8, 0x70, 0x78, 0x39, 0x49, 0xf3, 0xcd, 0x2e, 0xfe, 0xed, 0xfe, 0x3b, 0x00, 0x00,
-	0xff, 0xff, 0x24, 0x3f, 0x72, 0xb5, 0x49, 0x01, 0x00, 0x00,
+	// 231 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x94, 0x90, 0x3d, 0x4f, 0xc3, 0x30,
+	0x10, 0x86, 0xe5, 0xa6, 0x9f, 0x07, 0x45, 0xc8, 0x93, 0x55, 0x96, 0xc0, 0x94, 0xc9, 0x42, 0xb0,
+	0x74, 0x66, 0x62, 0x21, 0x83, 0xcb, 0xc4, 0x82, 0xdc, 0xe4, 0x44, 0x22, 0xe0, 0x2e, 0xf2, 0x59,
+	0xa8, 0xfd, 0x2d, 0xfc, 0x59, 0x94, 0xd4, 0x19, 0x98, 0x10, 0xdb, 0x7b, 0xa7, 0x7b, 0x1e, 0xbd,
+	0x3a, 0xb8, 0x10, 0xf2, 0x9d, 0x34, 0x1c, 0x6d, 0x17, 0x38, 0xb2, 0xce, 0xab, 0xc6, 0xb7, 0x64,
+	0x2b, 0x0e, 0x68, 0xe3, 0xa1, 0xde, 0xdb, 0x96, 0x22, 0x06, 0xf2, 0x1f, 0x56, 0x22, 0x07, 0xff,
+	0x86, 0x37, 0xdf, 0x13, 0x58, 0xee, 0x12, 0xa4, 0x4b, 0x98, 0x11, 0xd7, 0x28, 0x46, 0xe5, 0x59,
+	0x71, 0x76, 0xb7, 0xb5, 0x7f, 0xe1, 0x76, 0x44, 0xed, 0x2e, 0xfa, 0x88, 0xcf, 0x01, 0xb1, 0xe4,
+	0x1a, 0xdd, 0x49, 0xa3, 0x1f, 0x61, 0x4e, 0x4c, 0x15, 0x8a, 0x99, 0x0c, 0xc2, 0xdb, 0x7f, 0x08,
+	0xcb, 0x1e, 0x74, 0x89, 0xd7, 0x06, 0x16, 0x5f, 0x18, 0xa4, 0x65, 0x32, 0x59, 0xae, 0x8a, 0xb5,
+	0x1b, 0xc7, 0xcd, 0x16, 0x66, 0xc3, 0xa9, 0xd6, 0x30, 0x6d, 0xbc, 0x34, 0x46, 0xe5, 0xaa, 0x38,
+	0x77, 0x43, 0xd6, 0x57, 0xb0, 0xc2, 0x43, 0xd7, 0x86, 0xe3, 0xeb, 0x67, 0xdf, 0x41, 0x15, 0x53,
+	0xb7, 0x3c, 0x2d, 0x9e, 0x64, 0x73, 0x0d, 0xeb, 0x5f, 0xad, 0xf5, 0x25, 0x64, 0xef, 0x78, 0x4c,
+	0x82, 0x3e, 0x3e, 0xac, 0x5e, 0x16, 0xa9, 0xd8, 0x7e, 0x3e, 0x7c, 0xf4, 0xfe, 0x67, 0x00, 0x77,
+	0x7e, 0xe8, 0x3e, 0x63, 0x01, 0x00, 0x00,
 }
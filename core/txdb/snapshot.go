@@ -9,25 +9,36 @@ import (
 	"chain/core/txdb/internal/storage"
 	"chain/database/pg"
 	"chain/errors"
+	"chain/protocol"
 	"chain/protocol/bc"
 	"chain/protocol/patricia"
 	"chain/protocol/state"
 )
 
+// snapshotVersion is the version of the snapshot encoding
+// written by EncodeSnapshot. DecodeSnapshot reads it and
+// version 0, from before versioning, which is the same.
+const snapshotVersion = 1
+
 // DecodeSnapshot decodes a snapshot from the Chain Core's binary,
-// protobuf representation of the snapshot.
+// protobuf representation of the snapshot. It returns an error
+// with root protocol.ErrBadSnapshot if data is malformed or has
+// an unknown version.
 func DecodeSnapshot(data []byte) (*state.Snapshot, error) {
 	var storedSnapshot storage.Snapshot
 	err := proto.Unmarshal(data, &storedSnapshot)
 	if err != nil {
-		return nil, errors.Wrap(err, "unmarshaling state snapshot proto")
+		return nil, errors.Wrap(errors.WithDetail(protocol.ErrBadSnapshot, err.Error()), "unmarshaling state snapshot proto")
+	}
+	if v := storedSnapshot.Version; v > snapshotVersion {
+		return nil, errors.WithDetailf(protocol.ErrBadSnapshot, "snapshot version %d, want at most %d", v, snapshotVersion)
 	}
 
 	tree := new(patricia.Tree)
 	for _, node := range storedSnapshot.Nodes {
 		err = tree.Insert(node.Key)
 		if err != nil {
-			return nil, errors.Wrap(err, "reconstructing state tree")
+			return nil, errors.Wrap(errors.WithDetail(protocol.ErrBadSnapshot, err.Error()), "reconstructing state tree")
 		}
 	}
 
@@ -45,15 +56,17 @@ func DecodeSnapshot(data []byte) (*state.Snapshot, error) {
 	}, nil
 }
 
-func storeStateSnapshot(ctx context.Context, db pg.DB, snapshot *state.Snapshot, blockHeight uint64) error {
-	var storedSnapshot storage.Snapshot
+// EncodeSnapshot encodes snapshot in the Chain Core's binary,
+// protobuf representation, for DecodeSnapshot.
+func EncodeSnapshot(snapshot *state.Snapshot) ([]byte, error) {
+	storedSnapshot := storage.Snapshot{Version: snapshotVersion}
 	err := patricia.Walk(snapshot.Tree, func(key []byte) error {
 		n := &storage.Snapshot_StateTreeNode{Key: key}
 		storedSnapshot.Nodes = append(storedSnapshot.Nodes, n)
 		return nil
 	})
 	if err != nil {
-		return errors.Wrap(err, "walking patricia tree")
+		return nil, errors.Wrap(err, "walking patricia tree")
 	}
 
 	storedSnapshot.Nonces = make([]*storage.Snapshot_Nonce, 0, len(snapshot.Nonces))
@@ -66,8 +79,13 @@ func storeStateSnapshot(ctx context.Context, db pg.DB, snapshot *state.Snapshot,
 	}
 
 	b, err := proto.Marshal(&storedSnapshot)
+	return b, errors.Wrap(err, "marshaling state snapshot")
+}
+
+func storeStateSnapshot(ctx context.Context, db pg.DB, snapshot *state.Snapshot, blockHeight uint64) error {
+	b, err := EncodeSnapshot(snapshot)
 	if err != nil {
-		return errors.Wrap(err, "marshaling state snapshot")
+		return err
 	}
 
 	const insertQ = `
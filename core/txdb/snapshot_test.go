@@ -5,7 +5,12 @@ import (
 	"math/rand"
 	"testing"
 
+	"github.com/golang/protobuf/proto"
+
+	"chain/core/txdb/internal/storage"
 	"chain/database/pg/pgtest"
+	"chain/errors"
+	"chain/protocol"
 	"chain/protocol/bc"
 	"chain/protocol/state"
 	"chain/testutil"
@@ -122,6 +127,65 @@ func TestReadWriteStateSnapshot(t *testing.T) {
 	}
 }
 
+func TestEncodeDecodeSnapshot(t *testing.T) {
+	snapshot := state.Empty()
+	for i := byte(1); i <= 3; i++ {
+		err := snapshot.Tree.Insert(bc.NewHash([32]byte{i}).Bytes())
+		if err != nil {
+			t.Fatal(err)
+		}
+		snapshot.Nonces[bc.NewHash([32]byte{i})] = uint64(i) * 10
+	}
+
+	data, err := EncodeSnapshot(snapshot)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := DecodeSnapshot(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Tree.RootHash() != snapshot.Tree.RootHash() {
+		t.Errorf("decoded root = %x want %x", got.Tree.RootHash().Bytes(), snapshot.Tree.RootHash().Bytes())
+	}
+	if !testutil.DeepEqual(got.Nonces, snapshot.Nonces) {
+		t.Errorf("decoded nonces = %v want %v", got.Nonces, snapshot.Nonces)
+	}
+}
+
+func TestDecodeSnapshotVersions(t *testing.T) {
+	node := &storage.Snapshot_StateTreeNode{Key: bc.NewHash([32]byte{1}).Bytes()}
+	cases := []struct {
+		version uint32
+		ok      bool
+	}{
+		{0, true}, // from before versioning
+		{snapshotVersion, true},
+		{snapshotVersion + 1, false},
+	}
+	for _, c := range cases {
+		data, err := proto.Marshal(&storage.Snapshot{
+			Nodes:   []*storage.Snapshot_StateTreeNode{node},
+			Version: c.version,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = DecodeSnapshot(data)
+		if c.ok && err != nil {
+			t.Errorf("DecodeSnapshot(version %d) = %v want nil", c.version, err)
+		}
+		if !c.ok && errors.Root(err) != protocol.ErrBadSnapshot {
+			t.Errorf("DecodeSnapshot(version %d) = %v want %v", c.version, err, protocol.ErrBadSnapshot)
+		}
+	}
+
+	_, err := DecodeSnapshot([]byte{0xff, 0xff})
+	if errors.Root(err) != protocol.ErrBadSnapshot {
+		t.Errorf("DecodeSnapshot(garbage) = %v want %v", err, protocol.ErrBadSnapshot)
+	}
+}
+
 func BenchmarkStoreSnapshot100(b *testing.B) {
 	benchmarkStoreSnapshot(100, 100, b)
 }
@@ -9,6 +9,7 @@ import (
 	"chain/database/pg"
 	"chain/errors"
 	"chain/log"
+	"chain/protocol/bc"
 )
 
 func ListenBlocks(ctx context.Context, dbURL string) (<-chan uint64, error) {
@@ -51,3 +52,12 @@ func (s *Store) GetRawBlock(ctx context.Context, height uint64) ([]byte, error)
 	err := s.db.QueryRowContext(ctx, q, height).Scan(&block)
 	return block, errors.Wrap(err, "querying blocks from the db")
 }
+
+// GetRawBlockByHash queries the database for the block with the
+// provided hash. The block is returned as raw bytes.
+func (s *Store) GetRawBlockByHash(ctx context.Context, hash bc.Hash) ([]byte, error) {
+	const q = `SELECT data FROM blocks WHERE block_hash = $1`
+	var block []byte
+	err := s.db.QueryRowContext(ctx, q, hash).Scan(&block)
+	return block, errors.Wrap(err, "querying blocks from the db")
+}
@@ -9,6 +9,7 @@ import (
 
 	"chain/crypto/ed25519"
 	"chain/crypto/ed25519/internal/edwards25519"
+	"chain/crypto/sha3pool"
 )
 
 type (
@@ -183,6 +184,16 @@ func (xpub XPub) PublicKey() ed25519.PublicKey {
 	return ed25519.PublicKey(xpub[:32])
 }
 
+// Fingerprint returns a short identifier for xpub: the first
+// four bytes of the SHA3-256 hash of its serialization. It
+// names a root key without revealing it, but isn't unique.
+func (xpub XPub) Fingerprint() (fp [4]byte) {
+	var h [32]byte
+	sha3pool.Sum256(h[:], xpub[:])
+	copy(fp[:], h[:])
+	return fp
+}
+
 func hashKeySaltSelector(out []byte, version byte, key, salt, sel []byte) {
 	hasher := hashKeySaltHelper(version, key, salt)
 	var l [10]byte
@@ -79,6 +79,23 @@ func TestChildKeys(t *testing.T) {
 	}
 }
 
+func TestFingerprint(t *testing.T) {
+	xprv, xpub, err := NewXKeys(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fp := xpub.Fingerprint()
+	if xprv.XPub().Fingerprint() != fp {
+		t.Errorf("fingerprint of %x changed between calls", xpub.Bytes())
+	}
+
+	// Fingerprints name a key, not the keys derived from it.
+	child := xpub.Derive([][]byte{{1}})
+	if child.Fingerprint() == fp {
+		t.Errorf("child key has its parent's fingerprint %x", fp)
+	}
+}
+
 func doverify(t *testing.T, xpub XPub, msg, sig []byte, xpubdesc, xprvdesc string) {
 	if !xpub.Verify(msg, sig) {
 		t.Errorf("%s cannot verify signature from %s", xpubdesc, xprvdesc)
@@ -0,0 +1,146 @@
+// Package scrypt implements the scrypt key derivation function
+// as defined in RFC 7914.
+//
+// Its API matches golang.org/x/crypto/scrypt.
+package scrypt
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+)
+
+const maxInt = int(^uint(0) >> 1)
+
+// Key derives a key of length keyLen from password and salt.
+// N is the CPU/memory cost and must be a power of two greater
+// than 1; r is the block size and p the parallelization
+// parameter. They must satisfy r*p < 2^30.
+//
+// The recommended parameters for interactive logins as of
+// 2017 are N=32768, r=8 and p=1.
+func Key(password, salt []byte, N, r, p, keyLen int) ([]byte, error) {
+	if N <= 1 || N&(N-1) != 0 {
+		return nil, errors.New("scrypt: N must be > 1 and a power of 2")
+	}
+	if r <= 0 || p <= 0 || uint64(r)*uint64(p) >= 1<<30 || r > maxInt/128/p || r > maxInt/256 || N > maxInt/128/r {
+		return nil, errors.New("scrypt: parameters are too large")
+	}
+
+	b := pbkdf2SHA256(password, salt, p*128*r)
+	x := make([]uint32, 32*r)
+	v := make([]uint32, 32*r*N)
+	for i := 0; i < p; i++ {
+		romix(b[i*128*r:(i+1)*128*r], r, N, x, v)
+	}
+	return pbkdf2SHA256(password, b, keyLen), nil
+}
+
+// romix applies scryptROMix from RFC 7914 section 5 to b,
+// using x and v as scratch space.
+func romix(b []byte, r, N int, x, v []uint32) {
+	for i := range x {
+		x[i] = binary.LittleEndian.Uint32(b[4*i:])
+	}
+	for i := 0; i < N; i++ {
+		copy(v[i*32*r:], x)
+		blockMix(x, r)
+	}
+	for i := 0; i < N; i++ {
+		// Integerify: the first word of the last 64-byte block.
+		j := int(x[(2*r-1)*16] & uint32(N-1))
+		for k, w := range v[j*32*r : (j+1)*32*r] {
+			x[k] ^= w
+		}
+		blockMix(x, r)
+	}
+	for i, w := range x {
+		binary.LittleEndian.PutUint32(b[4*i:], w)
+	}
+}
+
+// blockMix applies scryptBlockMix from RFC 7914 section 4
+// to the 2*r 64-byte blocks in b.
+func blockMix(b []uint32, r int) {
+	var t [16]uint32
+	copy(t[:], b[(2*r-1)*16:])
+	y := make([]uint32, len(b))
+	for i := 0; i < 2*r; i++ {
+		for k := range t {
+			t[k] ^= b[i*16+k]
+		}
+		salsa208(&t)
+
+		// Even blocks go to the first half of the
+		// output, odd blocks to the second.
+		copy(y[(i/2+(i%2)*r)*16:], t[:])
+	}
+	copy(b, y)
+}
+
+// salsa208 applies the Salsa20/8 core from
+// RFC 7914 section 3 to b.
+func salsa208(b *[16]uint32) {
+	x := *b
+	for i := 0; i < 8; i += 2 {
+		// Column round.
+		x[4] ^= rotl(x[0]+x[12], 7)
+		x[8] ^= rotl(x[4]+x[0], 9)
+		x[12] ^= rotl(x[8]+x[4], 13)
+		x[0] ^= rotl(x[12]+x[8], 18)
+		x[9] ^= rotl(x[5]+x[1], 7)
+		x[13] ^= rotl(x[9]+x[5], 9)
+		x[1] ^= rotl(x[13]+x[9], 13)
+		x[5] ^= rotl(x[1]+x[13], 18)
+		x[14] ^= rotl(x[10]+x[6], 7)
+		x[2] ^= rotl(x[14]+x[10], 9)
+		x[6] ^= rotl(x[2]+x[14], 13)
+		x[10] ^= rotl(x[6]+x[2], 18)
+		x[3] ^= rotl(x[15]+x[11], 7)
+		x[7] ^= rotl(x[3]+x[15], 9)
+		x[11] ^= rotl(x[7]+x[3], 13)
+		x[15] ^= rotl(x[11]+x[7], 18)
+
+		// Row round.
+		x[1] ^= rotl(x[0]+x[3], 7)
+		x[2] ^= rotl(x[1]+x[0], 9)
+		x[3] ^= rotl(x[2]+x[1], 13)
+		x[0] ^= rotl(x[3]+x[2], 18)
+		x[6] ^= rotl(x[5]+x[4], 7)
+		x[7] ^= rotl(x[6]+x[5], 9)
+		x[4] ^= rotl(x[7]+x[6], 13)
+		x[5] ^= rotl(x[4]+x[7], 18)
+		x[11] ^= rotl(x[10]+x[9], 7)
+		x[8] ^= rotl(x[11]+x[10], 9)
+		x[9] ^= rotl(x[8]+x[11], 13)
+		x[10] ^= rotl(x[9]+x[8], 18)
+		x[12] ^= rotl(x[15]+x[14], 7)
+		x[13] ^= rotl(x[12]+x[15], 9)
+		x[14] ^= rotl(x[13]+x[12], 13)
+		x[15] ^= rotl(x[14]+x[13], 18)
+	}
+	for i := range b {
+		b[i] += x[i]
+	}
+}
+
+func rotl(x uint32, n uint) uint32 {
+	return x<<n | x>>(32-n)
+}
+
+// pbkdf2SHA256 is PBKDF2 from RFC 8018 with HMAC-SHA256
+// and a single iteration, which is all scrypt uses.
+func pbkdf2SHA256(password, salt []byte, keyLen int) []byte {
+	prf := hmac.New(sha256.New, password)
+	var dk []byte
+	var ctr [4]byte
+	for block := uint32(1); len(dk) < keyLen; block++ {
+		prf.Reset()
+		prf.Write(salt)
+		binary.BigEndian.PutUint32(ctr[:], block)
+		prf.Write(ctr[:])
+		dk = prf.Sum(dk)
+	}
+	return dk[:keyLen]
+}
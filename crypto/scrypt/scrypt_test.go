@@ -0,0 +1,54 @@
+package scrypt
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+)
+
+// Test vectors from RFC 7914 section 12.
+var vectors = []struct {
+	password, salt string
+	N, r, p        int
+	want           string
+}{
+	{
+		"", "", 16, 1, 1,
+		"77d6576238657b203b19ca42c18a0497f16b4844e3074ae8dfdffa3fede21442fcd0069ded0948f8326a753a0fc81f17e8d3e0fb2e0d3628cf35e20c38d18906",
+	},
+	{
+		"password", "NaCl", 1024, 8, 16,
+		"fdbabe1c9d3472007856e7190d01e9fe7c6ad7cbc8237830e77376634b3731622eaf30d92e22a3886ff109279d9830dac727afb94a83ee6d8360cbdfa2cc0640",
+	},
+}
+
+func TestKey(t *testing.T) {
+	for _, v := range vectors {
+		got, err := Key([]byte(v.password), []byte(v.salt), v.N, v.r, v.p, 64)
+		if err != nil {
+			t.Errorf("Key(%q, %q, %d, %d, %d) error: %s", v.password, v.salt, v.N, v.r, v.p, err)
+			continue
+		}
+		want, _ := hex.DecodeString(v.want)
+		if !bytes.Equal(got, want) {
+			t.Errorf("Key(%q, %q, %d, %d, %d) = %x want %x", v.password, v.salt, v.N, v.r, v.p, got, want)
+		}
+	}
+}
+
+func TestKeyBadParams(t *testing.T) {
+	cases := []struct{ N, r, p int }{
+		{N: 0, r: 1, p: 1},
+		{N: 1, r: 1, p: 1},
+		{N: 7, r: 1, p: 1},
+		{N: 16, r: 0, p: 1},
+		{N: 16, r: 1, p: 0},
+		{N: 16, r: 1 << 15, p: 1 << 15},
+	}
+	for _, c := range cases {
+		_, err := Key([]byte("password"), []byte("salt"), c.N, c.r, c.p, 32)
+		if err == nil {
+			t.Errorf("Key with N=%d r=%d p=%d succeeded, want error", c.N, c.r, c.p)
+		}
+	}
+}
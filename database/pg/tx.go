@@ -0,0 +1,40 @@
+package pg
+
+import (
+	"context"
+	"database/sql"
+
+	"chain/errors"
+)
+
+// Atomically calls f with a transaction on db, committing it if f
+// succeeds and rolling it back if not. If db is already a
+// transaction, it uses a savepoint instead.
+func Atomically(ctx context.Context, db DB, f func(DB) error) error {
+	switch db := db.(type) {
+	case *sql.DB:
+		tx, err := db.BeginTx(ctx, nil)
+		if err != nil {
+			return errors.Wrap(err, "begin transaction")
+		}
+		err = f(tx)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+		return errors.Wrap(tx.Commit(), "commit transaction")
+	case *sql.Tx:
+		_, err := db.ExecContext(ctx, `SAVEPOINT atomically`)
+		if err != nil {
+			return errors.Wrap(err, "savepoint")
+		}
+		err = f(db)
+		if err != nil {
+			db.ExecContext(ctx, `ROLLBACK TO SAVEPOINT atomically`)
+			return err
+		}
+		_, err = db.ExecContext(ctx, `RELEASE SAVEPOINT atomically`)
+		return errors.Wrap(err, "release savepoint")
+	}
+	return errors.New("database does not support transactions")
+}
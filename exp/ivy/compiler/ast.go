@@ -20,9 +20,17 @@ type Contract struct {
 	// Clauses is the list of contract clauses.
 	Clauses []*Clause `json:"clauses"`
 
-	// Value is the name of the value locked by the contract.
+	// Value is the name of the value locked by the contract. For a
+	// contract that locks more than one value, it's the first.
 	Value string `json:"value"`
 
+	// LockedValues, for a contract that locks more than one value,
+	// has an entry for each, in order. Each of the values is held in
+	// an output of its own, whose program instantiates the entry's
+	// Body rather than the contract's. The contract's Body is the
+	// first entry's.
+	LockedValues []*LockedValue `json:"locked_values,omitempty"`
+
 	// Body is the optimized bytecode of the contract body. This is not
 	// a complete program!  Use instantiate to turn this (plus some
 	// arguments) into a program.
@@ -36,8 +44,66 @@ type Contract struct {
 	// used to select between two possible instantiation options.)
 	Recursive bool `json:"recursive"`
 
+	// Warnings lists likely mistakes in the contract that didn't
+	// stop it from compiling.
+	Warnings []Warning `json:"warnings,omitempty"`
+
 	// Pre-optimized list of instruction steps, with stack snapshots.
 	Steps []Step `json:"-"`
+
+	// SourceMap, if requested with Options.SourceMap, maps ranges of
+	// Body back to the statements they were compiled from.
+	SourceMap SourceMap `json:"source_map,omitempty"`
+
+	// values is the names of all the values locked by the contract.
+	values []string
+
+	pos position
+}
+
+// LockedValue is one of the values locked by a contract that locks
+// more than one.
+type LockedValue struct {
+	// Name is the name of the value.
+	Name string `json:"name"`
+
+	// Body, Opcodes and SourceMap are like those of Contract, for
+	// the program that holds this value.
+	Body      chainjson.HexBytes `json:"body_bytecode"`
+	Opcodes   string             `json:"body_opcodes,omitempty"`
+	SourceMap SourceMap          `json:"source_map,omitempty"`
+}
+
+// locks tells whether name is one of the values locked by contract.
+func (contract *Contract) locks(name string) bool {
+	for _, v := range contract.values {
+		if v == name {
+			return true
+		}
+	}
+	return false
+}
+
+// sourceFile is a parsed Ivy source file.
+type sourceFile struct {
+	imports   []*importDecl
+	consts    []*constDecl
+	contracts []*Contract
+}
+
+// importDecl is a declaration that the source file uses contracts
+// from another file.
+type importDecl struct {
+	name string
+	pos  position
+}
+
+// constDecl is a named constant, usable in any contract in its file.
+type constDecl struct {
+	name  string
+	typ   typeDesc
+	value expression
+	pos   position
 }
 
 // Param is a contract or clause parameter.
@@ -51,6 +117,18 @@ type Param struct {
 	// InferredType, if available, is a more-specific type than Type,
 	// inferred from the logic of the contract.
 	InferredType typeDesc `json:"inferred_type,omitempty"`
+
+	// Encoding, for a clause parameter, is how its argument is
+	// written in JSON: "hex" for a hex string of bytes, "integer",
+	// "time" for an integer number of milliseconds since the Unix
+	// epoch, or "boolean".
+	Encoding string `json:"encoding,omitempty"`
+
+	// MinLen and MaxLen, for a clause parameter with "hex" encoding,
+	// bound the length of its argument in bytes. MaxLen is zero when
+	// there's no bound.
+	MinLen int `json:"min_len,omitempty"`
+	MaxLen int `json:"max_len,omitempty"`
 }
 
 // Clause is a compiled contract clause.
@@ -84,6 +162,8 @@ type Clause struct {
 
 	// Contracts is the list of contracts called by this clause.
 	Contracts []string `json:"contracts,omitempty"`
+
+	pos position
 }
 
 // HashCall describes a call to a hash function.
@@ -110,14 +190,33 @@ type ClauseReq struct {
 
 	// Amount is the expression describing the required amount.
 	Amount string `json:"amount"`
+
+	// AssetParams and AmountParams are the contract and clause
+	// parameters that Asset and Amount refer to.
+	AssetParams  []string `json:"asset_params,omitempty"`
+	AmountParams []string `json:"amount_params,omitempty"`
 }
 
 type statement interface {
 	countVarRefs(map[string]int)
+	position() position
+
+	// span returns the positions where the statement starts and
+	// where its last token ends.
+	span() (start, end position)
 }
 
 type verifyStatement struct {
-	expr expression
+	expr     expression
+	pos, end position
+}
+
+func (s verifyStatement) position() position {
+	return s.pos
+}
+
+func (s verifyStatement) span() (start, end position) {
+	return s.pos, s.end
 }
 
 func (s verifyStatement) countVarRefs(counts map[string]int) {
@@ -130,6 +229,16 @@ type lockStatement struct {
 
 	// Added as a decoration, used by CHECKOUTPUT
 	index int64
+
+	pos, end position
+}
+
+func (s lockStatement) position() position {
+	return s.pos
+}
+
+func (s lockStatement) span() (start, end position) {
+	return s.pos, s.end
 }
 
 func (s lockStatement) countVarRefs(counts map[string]int) {
@@ -137,8 +246,42 @@ func (s lockStatement) countVarRefs(counts map[string]int) {
 	s.program.countVarRefs(counts)
 }
 
+type ifStatement struct {
+	cond     expression
+	body     []statement
+	elseBody []statement
+	pos, end position
+}
+
+func (s ifStatement) countVarRefs(counts map[string]int) {
+	s.cond.countVarRefs(counts)
+	for _, stmt := range s.body {
+		stmt.countVarRefs(counts)
+	}
+	for _, stmt := range s.elseBody {
+		stmt.countVarRefs(counts)
+	}
+}
+
+func (s ifStatement) position() position {
+	return s.pos
+}
+
+func (s ifStatement) span() (start, end position) {
+	return s.pos, s.end
+}
+
 type unlockStatement struct {
-	expr expression
+	expr     expression
+	pos, end position
+}
+
+func (s unlockStatement) position() position {
+	return s.pos
+}
+
+func (s unlockStatement) span() (start, end position) {
+	return s.pos, s.end
 }
 
 func (s unlockStatement) countVarRefs(counts map[string]int) {
@@ -154,13 +297,17 @@ type expression interface {
 type binaryExpr struct {
 	left, right expression
 	op          *binaryOp
+	pos         position // of the operator
 }
 
 func (e binaryExpr) String() string {
 	return fmt.Sprintf("(%s %s %s)", e.left, e.op.op, e.right)
 }
 
-func (e binaryExpr) typ(*environ) typeDesc {
+func (e binaryExpr) typ(env *environ) typeDesc {
+	if e.op.left == intType {
+		return numericResult(e.op.op, e.left.typ(env), e.right.typ(env))
+	}
 	return e.op.result
 }
 
@@ -172,6 +319,7 @@ func (e binaryExpr) countVarRefs(counts map[string]int) {
 type unaryExpr struct {
 	op   *unaryOp
 	expr expression
+	pos  position
 }
 
 func (e unaryExpr) String() string {
@@ -189,6 +337,7 @@ func (e unaryExpr) countVarRefs(counts map[string]int) {
 type callExpr struct {
 	fn   expression
 	args []expression
+	pos  position
 }
 
 func (e callExpr) String() string {
@@ -223,6 +372,10 @@ func (e callExpr) typ(env *environ) typeDesc {
 			}
 		}
 
+		if sameNumericArgs(b) && len(e.args) > 0 {
+			return e.args[0].typ(env)
+		}
+
 		return b.result
 	}
 	if e.fn.typ(env) == predType {
@@ -9,11 +9,28 @@ import (
 type builder struct {
 	items         []*builderItem
 	pendingVerify *builderItem
+	ifs           int
+
+	// src is the statement being compiled, if any.
+	src *sourceRef
+
+	// value, if set, is the one contract value whose program is
+	// being built. Statements disposing of the contract's other
+	// values are left out.
+	value string
 }
 
 type builderItem struct {
 	opcodes string
 	stk     stack
+	src     *sourceRef
+}
+
+// sourceRef identifies the clause statement that some opcodes were
+// compiled from.
+type sourceRef struct {
+	clause *Clause
+	stmt   statement
 }
 
 func (b *builder) add(opcodes string, newstack stack) stack {
@@ -21,7 +38,7 @@ func (b *builder) add(opcodes string, newstack stack) stack {
 		b.items = append(b.items, b.pendingVerify)
 		b.pendingVerify = nil
 	}
-	item := &builderItem{opcodes: opcodes, stk: newstack}
+	item := &builderItem{opcodes: opcodes, stk: newstack, src: b.src}
 	if opcodes == "VERIFY" {
 		b.pendingVerify = item
 	} else {
@@ -56,6 +73,13 @@ func (b *builder) addJumpTarget(stk stack, label string) stack {
 	return b.add("$"+label, stk)
 }
 
+// newIfLabels returns a fresh pair of jump targets for the true
+// branch and the end of an if statement.
+func (b *builder) newIfLabels() (ifLabel, endLabel string) {
+	b.ifs++
+	return fmt.Sprintf("_if%d", b.ifs), fmt.Sprintf("_endif%d", b.ifs)
+}
+
 func (b *builder) addDrop(stk stack) stack {
 	return b.add("DROP", stk.drop())
 }
@@ -146,12 +170,14 @@ func (b *builder) addCat(stk stack, desc string) stack {
 	return b.add("CAT", stk.dropN(2).add(desc))
 }
 
-func (b *builder) opcodes() string {
-	var ops []string
+func (b *builder) opcodes() []opcode {
+	var ops []opcode
 	for _, item := range b.items {
-		ops = append(ops, item.opcodes)
+		for _, text := range strings.Fields(item.opcodes) {
+			ops = append(ops, opcode{text, item.src})
+		}
 	}
-	return strings.Join(ops, " ")
+	return ops
 }
 
 // This is for producing listings like:
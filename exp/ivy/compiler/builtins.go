@@ -11,9 +11,9 @@ var builtins = []builtin{
 	{"sha3", "SHA3", []typeDesc{nilType}, hashType},
 	{"sha256", "SHA256", []typeDesc{nilType}, hashType},
 	{"size", "SIZE SWAP DROP", []typeDesc{nilType}, intType},
-	{"abs", "ABS", []typeDesc{intType}, intType},
-	{"min", "MIN", []typeDesc{intType, intType}, intType},
-	{"max", "MAX", []typeDesc{intType, intType}, intType},
+	{"abs", "ABS", []typeDesc{nilType}, nilType},          // see sameNumericArgs
+	{"min", "MIN", []typeDesc{nilType, nilType}, nilType}, // see sameNumericArgs
+	{"max", "MAX", []typeDesc{nilType, nilType}, nilType}, // see sameNumericArgs
 	{"checkTxSig", "TXSIGHASH SWAP CHECKSIG", []typeDesc{pubkeyType, sigType}, boolType},
 	{"concat", "CAT", []typeDesc{nilType, nilType}, strType},
 	{"concatpush", "CATPUSHDATA", []typeDesc{nilType, nilType}, strType},
@@ -22,6 +22,16 @@ var builtins = []builtin{
 	{"checkTxMultiSig", "", []typeDesc{listType, listType}, boolType}, // WARNING WARNING WOOP WOOP special case
 }
 
+// sameNumericArgs tells whether builtin b takes Amount or Integer
+// arguments, all of the same type, and returns that type.
+func sameNumericArgs(b *builtin) bool {
+	switch b.name {
+	case "abs", "min", "max":
+		return true
+	}
+	return false
+}
+
 type binaryOp struct {
 	op         string
 	precedence int
@@ -30,6 +40,8 @@ type binaryOp struct {
 	left, right, result typeDesc
 }
 
+// The operators declared here on Integers also apply to Amounts and
+// Times, with result types given by numericResult.
 var binaryOps = []binaryOp{
 	// disjunctions disallowed (for now?)
 	// {"||", 1, "BOOLOR", "Boolean", "Boolean", "Boolean"},
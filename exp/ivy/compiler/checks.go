@@ -3,18 +3,31 @@ package compiler
 import "fmt"
 
 func checkRecursive(contract *Contract) bool {
+	var recursive bool
 	for _, clause := range contract.Clauses {
-		for _, stmt := range clause.statements {
+		walkStatements(clause.statements, func(stmt statement) {
 			if l, ok := stmt.(*lockStatement); ok {
 				if c, ok := l.program.(*callExpr); ok {
 					if references(c.fn, contract.Name) {
-						return true
+						recursive = true
 					}
 				}
 			}
+		})
+	}
+	return recursive
+}
+
+// walkStatements calls f on each statement in stmts, including
+// those nested inside if statements.
+func walkStatements(stmts []statement, f func(statement)) {
+	for _, stmt := range stmts {
+		f(stmt)
+		if s, ok := stmt.(*ifStatement); ok {
+			walkStatements(s.body, f)
+			walkStatements(s.elseBody, f)
 		}
 	}
-	return false
 }
 
 func prohibitSigParams(contract *Contract) error {
@@ -61,35 +74,35 @@ func requireAllParamsUsedInClauses(params []*Param, clauses []*Clause) error {
 
 func requireAllParamsUsedInClause(params []*Param, clause *Clause) error {
 	for _, p := range params {
-		used := false
-		for _, stmt := range clause.statements {
-			switch s := stmt.(type) {
-			case *verifyStatement:
-				used = references(s.expr, p.Name)
-			case *lockStatement:
-				used = references(s.locked, p.Name) || references(s.program, p.Name)
-			case *unlockStatement:
-				used = references(s.expr, p.Name)
-			}
-			if used {
-				break
-			}
-		}
-		if !used {
-			for _, r := range clause.Reqs {
-				if references(r.amountExpr, p.Name) || references(r.assetExpr, p.Name) {
-					used = true
-					break
-				}
-			}
-		}
-		if !used {
+		if !clauseReferences(clause, p.Name) {
 			return fmt.Errorf("parameter \"%s\" is unused in clause \"%s\"", p.Name, clause.Name)
 		}
 	}
 	return nil
 }
 
+// clauseReferences tells whether name appears anywhere in clause's
+// requirements or statements.
+func clauseReferences(clause *Clause, name string) bool {
+	var used bool
+	walkStatements(clause.statements, func(stmt statement) {
+		switch s := stmt.(type) {
+		case *verifyStatement:
+			used = used || references(s.expr, name)
+		case *lockStatement:
+			used = used || references(s.locked, name) || references(s.program, name)
+		case *unlockStatement:
+			used = used || references(s.expr, name)
+		case *ifStatement:
+			used = used || references(s.cond, name)
+		}
+	})
+	for _, r := range clause.Reqs {
+		used = used || references(r.amountExpr, name) || references(r.assetExpr, name)
+	}
+	return used
+}
+
 func references(expr expression, name string) bool {
 	switch e := expr.(type) {
 	case *binaryExpr:
@@ -120,12 +133,14 @@ func references(expr expression, name string) bool {
 }
 
 func requireAllValuesDisposedOnce(contract *Contract, clause *Clause) error {
-	err := valueDisposedOnce(contract.Value, clause)
-	if err != nil {
-		return err
+	for _, v := range contract.values {
+		err := valueDisposedOnce(v, clause)
+		if err != nil {
+			return err
+		}
 	}
 	for _, req := range clause.Reqs {
-		err = valueDisposedOnce(req.Name, clause)
+		err := valueDisposedOnce(req.Name, clause)
 		if err != nil {
 			return err
 		}
@@ -134,8 +149,26 @@ func requireAllValuesDisposedOnce(contract *Contract, clause *Clause) error {
 }
 
 func valueDisposedOnce(name string, clause *Clause) error {
+	count, err := countDisposals(name, clause, clause.statements)
+	if err != nil {
+		return err
+	}
+	switch count {
+	case 0:
+		return fmt.Errorf("value \"%s\" not disposed in clause \"%s\"", name, clause.Name)
+	case 1:
+		return nil
+	default:
+		return fmt.Errorf("value \"%s\" disposed multiple times in clause \"%s\"", name, clause.Name)
+	}
+}
+
+// countDisposals counts the unlock and lock statements for the value
+// name along any path through stmts. Both branches of an if
+// statement must dispose of it the same number of times.
+func countDisposals(name string, clause *Clause, stmts []statement) (int, error) {
 	var count int
-	for _, s := range clause.statements {
+	for _, s := range stmts {
 		switch stmt := s.(type) {
 		case *unlockStatement:
 			if references(stmt.expr, name) {
@@ -145,16 +178,22 @@ func valueDisposedOnce(name string, clause *Clause) error {
 			if references(stmt.locked, name) {
 				count++
 			}
+		case *ifStatement:
+			n, err := countDisposals(name, clause, stmt.body)
+			if err != nil {
+				return 0, err
+			}
+			elseN, err := countDisposals(name, clause, stmt.elseBody)
+			if err != nil {
+				return 0, err
+			}
+			if n != elseN {
+				return 0, errorAt(stmt.pos, "value \"%s\" disposed in only one branch of if statement in clause \"%s\"", name, clause.Name)
+			}
+			count += n
 		}
 	}
-	switch count {
-	case 0:
-		return fmt.Errorf("value \"%s\" not disposed in clause \"%s\"", name, clause.Name)
-	case 1:
-		return nil
-	default:
-		return fmt.Errorf("value \"%s\" disposed multiple times in clause \"%s\"", name, clause.Name)
-	}
+	return count, nil
 }
 
 func referencedBuiltin(expr expression) *builtin {
@@ -169,8 +208,11 @@ func referencedBuiltin(expr expression) *builtin {
 }
 
 func assignIndexes(clause *Clause) {
-	var nextIndex int64
-	for _, s := range clause.statements {
+	assignStatementIndexes(clause.statements, 0)
+}
+
+func assignStatementIndexes(stmts []statement, nextIndex int64) int64 {
+	for _, s := range stmts {
 		switch stmt := s.(type) {
 		case *lockStatement:
 			stmt.index = nextIndex
@@ -178,32 +220,56 @@ func assignIndexes(clause *Clause) {
 
 		case *unlockStatement:
 			nextIndex++
+
+		case *ifStatement:
+			// Both branches dispose of the same values (see
+			// countDisposals), so they use the same number of indexes.
+			assignStatementIndexes(stmt.elseBody, nextIndex)
+			nextIndex = assignStatementIndexes(stmt.body, nextIndex)
 		}
 	}
+	return nextIndex
 }
 
 func typeCheckClause(contract *Contract, clause *Clause, env *environ) error {
-	for _, s := range clause.statements {
+	return typeCheckStatements(contract, clause, env, clause.statements)
+}
+
+func typeCheckStatements(contract *Contract, clause *Clause, env *environ, stmts []statement) error {
+	for _, s := range stmts {
 		switch stmt := s.(type) {
 		case *verifyStatement:
 			if t := stmt.expr.typ(env); t != boolType {
-				return fmt.Errorf("expression in verify statement in clause \"%s\" has type \"%s\", must be Boolean", clause.Name, t)
+				return errorAt(stmt.pos, "expression in verify statement in clause \"%s\" has type \"%s\", must be Boolean", clause.Name, t)
 			}
 
 		case *lockStatement:
 			if t := stmt.locked.typ(env); t != valueType {
-				return fmt.Errorf("expression in lock statement in clause \"%s\" has type \"%s\", must be Value", clause.Name, t)
+				return errorAt(stmt.pos, "expression in lock statement in clause \"%s\" has type \"%s\", must be Value", clause.Name, t)
 			}
 			if t := stmt.program.typ(env); t != progType {
-				return fmt.Errorf("program in lock statement in clause \"%s\" has type \"%s\", must be Program", clause.Name, t)
+				return errorAt(stmt.pos, "program in lock statement in clause \"%s\" has type \"%s\", must be Program", clause.Name, t)
 			}
 
 		case *unlockStatement:
 			if t := stmt.expr.typ(env); t != valueType {
-				return fmt.Errorf("expression \"%s\" in unlock statement of clause \"%s\" has type \"%s\", must be Value", stmt.expr, clause.Name, t)
+				return errorAt(stmt.pos, "expression \"%s\" in unlock statement of clause \"%s\" has type \"%s\", must be Value", stmt.expr, clause.Name, t)
+			}
+			if !contract.locks(stmt.expr.String()) {
+				return errorAt(stmt.pos, "expression in unlock statement of clause \"%s\" must be a value locked by the contract", clause.Name)
+			}
+
+		case *ifStatement:
+			if t := stmt.cond.typ(env); t != boolType {
+				return errorAt(stmt.pos, "condition in if statement in clause \"%s\" has type \"%s\", must be Boolean", clause.Name, t)
+			}
+			err := typeCheckStatements(contract, clause, env, stmt.body)
+			if err != nil {
+				return err
 			}
-			if stmt.expr.String() != contract.Value {
-				return fmt.Errorf("expression in unlock statement of clause \"%s\" must be the contract value", clause.Name)
+			err = typeCheckStatements(contract, clause, env, stmt.elseBody)
+			if err != nil {
+				return err
 			}
 		}
 	}
@@ -13,12 +13,18 @@ import (
 
 func main() {
 	packageName := flag.String("package", "main", "Go package name for generated file")
+	importDir := flag.String("dir", ".", "directory for resolving imported Ivy files")
 	flag.Parse()
 
-	contracts, err := compiler.Compile(os.Stdin)
+	contracts, err := compiler.CompileWithImports(os.Stdin, compiler.DirImporter(*importDir))
 	if err != nil {
 		log.Fatal(err)
 	}
+	for _, contract := range contracts {
+		for _, w := range contract.Warnings {
+			log.Printf("%s: %s", contract.Name, w)
+		}
+	}
 
 	fmt.Printf("package %s\n\n", *packageName)
 
@@ -32,6 +32,12 @@ type ValueInfo struct {
 	// have, as it appears in a clause's "requires" section. If this is
 	// the contract value instead, this is empty.
 	Amount string `json:"amount,omitempty"`
+
+	// Condition, if the value is locked or unlocked inside an if
+	// statement, is the condition under which that happens, such as
+	// "before(deadline)" or "!before(deadline)" for the else branch.
+	// Nested conditions are joined with " && ".
+	Condition string `json:"condition,omitempty"`
 }
 
 // ContractArg is an argument with which to instantiate a contract as
@@ -48,15 +54,60 @@ type ContractArg struct {
 // lists of arguments with which to instantiate them as programs, with
 // the results placed in the contract's Program field. A contract
 // named in argMap but not found in the input is silently ignored.
+//
+// Errors in the source are reported as an ErrorList. A parse error
+// stops compilation; otherwise each contract and clause is compiled
+// even if an earlier one failed, so that all of them are reported.
 func Compile(r io.Reader) ([]*Contract, error) {
+	return CompileWithImports(r, nil)
+}
+
+// CompileWithImports is like Compile, but it also allows the source
+// to begin with import declarations, such as
+//
+//	import 'escrow.ivy'
+//
+// which imp resolves. Contracts in imported files can be called (to
+// produce a program for a lock statement) but are not themselves
+// included in the result.
+func CompileWithImports(r io.Reader, imp Importer) ([]*Contract, error) {
+	return CompileWithOptions(r, Options{Importer: imp})
+}
+
+// Options are the optional settings for CompileWithOptions.
+type Options struct {
+	// Importer resolves import declarations. If it is nil, imports
+	// are an error.
+	Importer Importer
+
+	// SourceMap tells whether to give each compiled contract a
+	// SourceMap.
+	SourceMap bool
+}
+
+// CompileWithOptions is like Compile, with the optional behavior
+// described by opts.
+func CompileWithOptions(r io.Reader, opts Options) ([]*Contract, error) {
 	inp, err := ioutil.ReadAll(r)
 	if err != nil {
 		return nil, errors.Wrap(err, "reading input")
 	}
-	contracts, err := parse(inp)
+	ic := &importContext{opts: opts, files: make(map[string]*importedFile)}
+	contracts, errs := ic.compile(inp)
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	return contracts, nil
+}
+
+// compile compiles the source file inp, first compiling the files it
+// imports.
+func (ic *importContext) compile(inp []byte) ([]*Contract, ErrorList) {
+	f, err := parse(inp)
 	if err != nil {
-		return nil, errors.Wrap(err, "parse error")
+		return nil, ErrorList{err.(*Error)}
 	}
+	contracts := f.contracts
 
 	globalEnv := newEnviron(nil)
 	for _, k := range keywords {
@@ -72,45 +123,131 @@ func Compile(r io.Reader) ([]*Contract, error) {
 		contract.Recursive = checkRecursive(contract)
 	}
 
+	var errs ErrorList
+	for _, decl := range f.consts {
+		value, err := constValue(decl)
+		if err == nil {
+			err = globalEnv.addConst(decl.name, decl.typ, value)
+		}
+		if err != nil {
+			errs = append(errs, at(decl.pos, err))
+		}
+	}
+	for _, decl := range f.imports {
+		imported, err := ic.load(decl)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		for _, contract := range imported {
+			err := globalEnv.addContract(contract)
+			if err != nil {
+				errs = append(errs, at(decl.pos, err))
+			}
+		}
+	}
 	for _, contract := range contracts {
 		err = globalEnv.addContract(contract)
 		if err != nil {
-			return nil, err
+			errs = append(errs, at(contract.pos, err))
 		}
 	}
+	if len(errs) > 0 {
+		return nil, errs
+	}
 
-	for _, contract := range contracts {
-		err = compileContract(contract, globalEnv)
-		if err != nil {
-			return nil, errors.Wrap(err, "compiling contract")
+	// A contract must be compiled before the contracts that call it.
+	order, err := callOrder(contracts)
+	if err != nil {
+		return nil, ErrorList{err.(*Error)}
+	}
+	failed := make(map[*Contract]bool)
+	for _, contract := range order {
+		var calleeFailed bool
+		for _, callee := range callees(contract, contracts) {
+			calleeFailed = calleeFailed || failed[callee]
+		}
+		if calleeFailed {
+			// Its callee's errors are already reported.
+			failed[contract] = true
+			continue
+		}
+		contractErrs := compileContract(contract, globalEnv, ic.opts.SourceMap)
+		if len(contractErrs) > 0 {
+			errs = append(errs, contractErrs...)
+			failed[contract] = true
+			continue
 		}
 		for _, clause := range contract.Clauses {
-			for _, stmt := range clause.statements {
-				switch s := stmt.(type) {
-				case *lockStatement:
-					valueInfo := ValueInfo{
-						Name:    s.locked.String(),
-						Program: s.program.String(),
-					}
-					if s.locked.String() != contract.Value {
-						for _, r := range clause.Reqs {
-							if s.locked.String() == r.Name {
-								valueInfo.Asset = r.assetExpr.String()
-								valueInfo.Amount = r.amountExpr.String()
-								break
-							}
-						}
+			clause.Values = valueInfos(contract, clause, clause.statements, "")
+		}
+		contract.Warnings = contractWarnings(contract)
+	}
+	if len(errs) > 0 {
+		return nil, errs
+	}
+
+	return contracts, nil
+}
+
+// constValue is the literal value of the constant decl. Integer
+// arithmetic in the declaration is computed here.
+func constValue(decl *constDecl) (expression, error) {
+	value := decl.value
+	n, ok, err := foldConst(value)
+	if err != nil {
+		return nil, err
+	}
+	if ok {
+		value = integerLiteral(n)
+	}
+	var valueOK bool
+	switch value.(type) {
+	case integerLiteral:
+		valueOK = isNumeric(decl.typ)
+	case bytesLiteral:
+		switch decl.typ {
+		case assetType, hashType, progType, pubkeyType, strType:
+			valueOK = true
+		}
+	default:
+		return nil, fmt.Errorf("value of constant \"%s\" must be a literal", decl.name)
+	}
+	if !valueOK {
+		return nil, fmt.Errorf("constant \"%s\" of type %s cannot have value %s", decl.name, decl.typ, decl.value)
+	}
+	return value, nil
+}
+
+func valueInfos(contract *Contract, clause *Clause, stmts []statement, cond string) []ValueInfo {
+	var result []ValueInfo
+	for _, stmt := range stmts {
+		switch s := stmt.(type) {
+		case *lockStatement:
+			valueInfo := ValueInfo{
+				Name:      s.locked.String(),
+				Program:   s.program.String(),
+				Condition: cond,
+			}
+			if !contract.locks(s.locked.String()) {
+				for _, r := range clause.Reqs {
+					if s.locked.String() == r.Name {
+						valueInfo.Asset = r.assetExpr.String()
+						valueInfo.Amount = r.amountExpr.String()
+						break
 					}
-					clause.Values = append(clause.Values, valueInfo)
-				case *unlockStatement:
-					valueInfo := ValueInfo{Name: contract.Value}
-					clause.Values = append(clause.Values, valueInfo)
 				}
 			}
+			result = append(result, valueInfo)
+		case *unlockStatement:
+			valueInfo := ValueInfo{Name: s.expr.String(), Condition: cond}
+			result = append(result, valueInfo)
+		case *ifStatement:
+			result = append(result, valueInfos(contract, clause, s.body, joinCond(cond, s.cond.String()))...)
+			result = append(result, valueInfos(contract, clause, s.elseBody, joinCond(cond, "!"+s.cond.String()))...)
 		}
 	}
-
-	return contracts, nil
+	return result
 }
 
 func Instantiate(body []byte, params []*Param, recursive bool, args []ContractArg) ([]byte, error) {
@@ -169,44 +306,100 @@ func Instantiate(body []byte, params []*Param, recursive bool, args []ContractAr
 	return b.Build()
 }
 
-func compileContract(contract *Contract, globalEnv *environ) error {
+// compileContract compiles each clause of contract, returning the
+// errors from all of them. It also sets contract.SourceMap if
+// sourceMap is true.
+func compileContract(contract *Contract, globalEnv *environ, sourceMap bool) ErrorList {
 	var err error
 
 	if len(contract.Clauses) == 0 {
-		return fmt.Errorf("empty contract")
+		return ErrorList{errorAt(contract.pos, "empty contract")}
 	}
 	env := newEnviron(globalEnv)
 	for _, p := range contract.Params {
 		err = env.add(p.Name, p.Type, roleContractParam)
 		if err != nil {
-			return err
+			return ErrorList{at(contract.pos, err)}
 		}
 	}
-	err = env.add(contract.Value, valueType, roleContractValue)
-	if err != nil {
-		return err
+	for _, v := range contract.values {
+		err = env.add(v, valueType, roleContractValue)
+		if err != nil {
+			return ErrorList{at(contract.pos, err)}
+		}
 	}
 	for _, c := range contract.Clauses {
 		err = env.add(c.Name, nilType, roleClause)
 		if err != nil {
-			return err
+			return ErrorList{at(c.pos, err)}
 		}
 	}
 
 	err = prohibitValueParams(contract)
 	if err != nil {
-		return err
+		return ErrorList{at(contract.pos, err)}
 	}
 	err = prohibitSigParams(contract)
 	if err != nil {
-		return err
+		return ErrorList{at(contract.pos, err)}
 	}
 	err = requireAllParamsUsedInClauses(contract.Params, contract.Clauses)
 	if err != nil {
-		return err
+		return ErrorList{at(contract.pos, err)}
 	}
 
-	var stk stack
+	// This compiles every statement, checking them all and recording
+	// what the clauses do.
+	b, errs := compileBody(contract, env, "")
+	if len(errs) > 0 {
+		return errs
+	}
+	if len(contract.values) == 1 {
+		err = assemble(b, &contract.Body, &contract.Opcodes, &contract.SourceMap, sourceMap)
+		if err != nil {
+			return ErrorList{at(contract.pos, err)}
+		}
+		contract.Steps = b.steps()
+		return nil
+	}
+
+	// Each value gets a program of its own, from copies of the
+	// clauses so that what they do isn't recorded again.
+	contract.LockedValues = nil
+	for _, v := range contract.values {
+		cp := *contract
+		cp.Clauses = nil
+		for _, clause := range contract.Clauses {
+			clauseCopy := *clause
+			cp.Clauses = append(cp.Clauses, &clauseCopy)
+		}
+		b, errs := compileBody(&cp, env, v)
+		if len(errs) > 0 {
+			return errs
+		}
+		lv := &LockedValue{Name: v}
+		err = assemble(b, &lv.Body, &lv.Opcodes, &lv.SourceMap, sourceMap)
+		if err != nil {
+			return ErrorList{at(contract.pos, err)}
+		}
+		if len(contract.LockedValues) == 0 {
+			contract.Steps = b.steps()
+		}
+		contract.LockedValues = append(contract.LockedValues, lv)
+	}
+	first := contract.LockedValues[0]
+	contract.Body, contract.Opcodes, contract.SourceMap = first.Body, first.Opcodes, first.SourceMap
+	return nil
+}
+
+// compileBody compiles the clause selection and the clauses of
+// contract. If value is set, it compiles the program for just that
+// one of the contract's values.
+func compileBody(contract *Contract, env *environ, value string) (*builder, ErrorList) {
+	var (
+		err error
+		stk stack
+	)
 
 	if len(contract.Clauses) > 1 {
 		stk = stk.add("<clause selector>")
@@ -221,12 +414,13 @@ func compileContract(contract *Contract, globalEnv *environ) error {
 		stk = stk.add(contract.Name)
 	}
 
-	b := &builder{}
+	b := &builder{value: value}
+	var errs ErrorList
 
 	if len(contract.Clauses) == 1 {
 		err = compileClause(b, stk, contract, env, contract.Clauses[0])
 		if err != nil {
-			return err
+			errs = append(errs, at(contract.Clauses[0].pos, err))
 		}
 	} else {
 		if len(contract.Params) > 0 {
@@ -270,7 +464,7 @@ func compileContract(contract *Contract, globalEnv *environ) error {
 
 			err = compileClause(b, stk, contract, env, clause)
 			if err != nil {
-				return errors.Wrapf(err, "compiling clause \"%s\"", clause.Name)
+				errs = append(errs, at(clause.pos, errors.Wrapf(err, "compiling clause \"%s\"", clause.Name)))
 			}
 			b.forgetPendingVerify()
 			if i < len(contract.Clauses)-1 {
@@ -279,18 +473,26 @@ func compileContract(contract *Contract, globalEnv *environ) error {
 		}
 		b.addJumpTarget(stk, "_end")
 	}
+	return b, errs
+}
 
-	opcodes := optimize(b.opcodes())
-	prog, err := vm.Assemble(opcodes)
+// assemble optimizes and assembles the opcodes from b, setting
+// *body and *opcodes, and *sm too if sourceMap is true.
+func assemble(b *builder, body *chainjson.HexBytes, opcodes *string, sm *SourceMap, sourceMap bool) error {
+	ops := optimize(b.opcodes())
+	*opcodes = opcodesString(ops)
+	prog, err := vm.Assemble(*opcodes)
 	if err != nil {
 		return err
 	}
+	*body = prog
 
-	contract.Body = prog
-	contract.Opcodes = opcodes
-
-	contract.Steps = b.steps()
-
+	if sourceMap {
+		*sm, err = newSourceMap(ops, prog)
+		if err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
@@ -304,6 +506,7 @@ func compileClause(b *builder, contractStk stack, contract *Contract, env *envir
 		if err != nil {
 			return err
 		}
+		p.Encoding, p.MinLen, p.MaxLen = argEncoding(p.Type)
 	}
 	for _, req := range clause.Reqs {
 		err = env.add(req.Name, valueType, roleClauseValue)
@@ -312,6 +515,8 @@ func compileClause(b *builder, contractStk stack, contract *Contract, env *envir
 		}
 		req.Asset = req.assetExpr.String()
 		req.Amount = req.amountExpr.String()
+		req.AssetParams = referencedParams(req.assetExpr, contract, clause)
+		req.AmountParams = referencedParams(req.amountExpr, contract, clause)
 	}
 
 	assignIndexes(clause)
@@ -331,21 +536,90 @@ func compileClause(b *builder, contractStk stack, contract *Contract, env *envir
 		req.assetExpr.countVarRefs(counts)
 		req.amountExpr.countVarRefs(counts)
 	}
-	for _, s := range clause.statements {
-		s.countVarRefs(counts)
+	countCompiledRefs(b, contract, clause.statements, counts)
+
+	stk, err = compileStatements(b, stk, contract, clause, env, counts, clause.statements, "")
+	if err != nil {
+		return err
+	}
+	if b.value != "" && b.pendingVerify == nil {
+		// Nothing compiled for this value leaves a result.
+		stk = b.addBoolean(stk, true)
+	}
+
+	err = requireAllValuesDisposedOnce(contract, clause)
+	if err != nil {
+		return err
+	}
+	err = typeCheckClause(contract, clause, env)
+	if err != nil {
+		return err
+	}
+	err = requireAllParamsUsedInClause(clause.Params, clause)
+	if err != nil {
+		return err
 	}
 
-	for _, s := range clause.statements {
+	return nil
+}
+
+// skipped tells whether s is left out of the program b is building,
+// because it disposes of another of the contract's values.
+func skipped(b *builder, contract *Contract, s statement) bool {
+	if b.value == "" {
+		return false
+	}
+	var name string
+	switch stmt := s.(type) {
+	case *lockStatement:
+		name = stmt.locked.String()
+	case *unlockStatement:
+		name = stmt.expr.String()
+	default:
+		return false
+	}
+	return contract.locks(name) && name != b.value
+}
+
+// countCompiledRefs adds to counts the variable references in the
+// statements that aren't skipped.
+func countCompiledRefs(b *builder, contract *Contract, stmts []statement, counts map[string]int) {
+	for _, s := range stmts {
+		switch stmt := s.(type) {
+		case *ifStatement:
+			stmt.cond.countVarRefs(counts)
+			countCompiledRefs(b, contract, stmt.body, counts)
+			countCompiledRefs(b, contract, stmt.elseBody, counts)
+		default:
+			if !skipped(b, contract, s) {
+				s.countVarRefs(counts)
+			}
+		}
+	}
+}
+
+// compileStatements compiles stmts, which are inside an if statement
+// when cond (describing the path to them) is non-empty.
+func compileStatements(b *builder, stk stack, contract *Contract, clause *Clause, env *environ, counts map[string]int, stmts []statement, cond string) (stack, error) {
+	var err error
+
+	outerSrc := b.src
+	defer func() { b.src = outerSrc }()
+
+	for _, s := range stmts {
+		b.src = &sourceRef{clause: clause, stmt: s}
+
 		switch stmt := s.(type) {
 		case *verifyStatement:
 			stk, err = compileExpr(b, stk, contract, clause, env, counts, stmt.expr)
 			if err != nil {
-				return errors.Wrapf(err, "in verify statement in clause \"%s\"", clause.Name)
+				return stk, at(stmt.pos, errors.Wrapf(err, "in verify statement in clause \"%s\"", clause.Name))
 			}
 			stk = b.addVerify(stk)
 
-			// special-case reporting of certain function calls
-			if c, ok := stmt.expr.(*callExpr); ok && len(c.args) == 1 {
+			// special-case reporting of certain function calls, when
+			// they apply to the whole clause
+			if c, ok := stmt.expr.(*callExpr); ok && len(c.args) == 1 && cond == "" {
 				if b := referencedBuiltin(c.fn); b != nil {
 					switch b.name {
 					case "before":
@@ -357,6 +631,11 @@ func compileClause(b *builder, contractStk stack, contract *Contract, env *envir
 			}
 
 		case *lockStatement:
+			if skipped(b, contract, stmt) {
+				// Another value's program checks this.
+				continue
+			}
+
 			// index
 			stk = b.addInt64(stk, stmt.index)
 
@@ -366,7 +645,7 @@ func compileClause(b *builder, contractStk stack, contract *Contract, env *envir
 			// TODO: permit more complex expressions for locked,
 			// like "lock x+y with foo" (?)
 
-			if stmt.locked.String() == contract.Value {
+			if contract.locks(stmt.locked.String()) {
 				stk = b.addAmount(stk)
 				stk = b.addAsset(stk)
 			} else {
@@ -378,19 +657,19 @@ func compileClause(b *builder, contractStk stack, contract *Contract, env *envir
 					}
 				}
 				if req == nil {
-					return fmt.Errorf("unknown value \"%s\" in lock statement in clause \"%s\"", stmt.locked, clause.Name)
+					return stk, errorAt(stmt.pos, "unknown value \"%s\" in lock statement in clause \"%s\"", stmt.locked, clause.Name)
 				}
 
 				// amount
 				stk, err = compileExpr(b, stk, contract, clause, env, counts, req.amountExpr)
 				if err != nil {
-					return errors.Wrapf(err, "in lock statement in clause \"%s\"", clause.Name)
+					return stk, at(stmt.pos, errors.Wrapf(err, "in lock statement in clause \"%s\"", clause.Name))
 				}
 
 				// asset
 				stk, err = compileExpr(b, stk, contract, clause, env, counts, req.assetExpr)
 				if err != nil {
-					return errors.Wrapf(err, "in lock statement in clause \"%s\"", clause.Name)
+					return stk, at(stmt.pos, errors.Wrapf(err, "in lock statement in clause \"%s\"", clause.Name))
 				}
 			}
 
@@ -400,35 +679,78 @@ func compileClause(b *builder, contractStk stack, contract *Contract, env *envir
 			// prog
 			stk, err = compileExpr(b, stk, contract, clause, env, counts, stmt.program)
 			if err != nil {
-				return errors.Wrapf(err, "in lock statement in clause \"%s\"", clause.Name)
+				return stk, at(stmt.pos, errors.Wrapf(err, "in lock statement in clause \"%s\"", clause.Name))
 			}
 
 			stk = b.addCheckOutput(stk, fmt.Sprintf("checkOutput(%s, %s)", stmt.locked, stmt.program))
 			stk = b.addVerify(stk)
 
+		case *ifStatement:
+			stk, err = compileIf(b, stk, contract, clause, env, counts, stmt, cond)
+			if err != nil {
+				return stk, err
+			}
+
 		case *unlockStatement:
-			if len(clause.statements) == 1 {
+			if cond == "" && len(clause.statements) == 1 {
 				// This is the only statement in the clause, make sure TRUE is
 				// on the stack.
 				stk = b.addBoolean(stk, true)
 			}
 		}
 	}
+	return stk, nil
+}
 
-	err = requireAllValuesDisposedOnce(contract, clause)
+// compileIf compiles
+//
+//	<cond> JUMPIF:$_ifN <else body> JUMP:$_endifN $_ifN <body> $_endifN
+//
+// or, with no else,
+//
+//	<cond> NOT JUMPIF:$_endifN <body> $_endifN
+//
+// Both branches must leave the stack as they found it, so references
+// inside them never consume the referenced stack item.
+func compileIf(b *builder, stk stack, contract *Contract, clause *Clause, env *environ, counts map[string]int, stmt *ifStatement, cond string) (stack, error) {
+	var err error
+
+	stk, err = compileExpr(b, stk, contract, clause, env, counts, stmt.cond)
 	if err != nil {
-		return err
+		return stk, at(stmt.pos, errors.Wrapf(err, "in if statement in clause \"%s\"", clause.Name))
 	}
-	err = typeCheckClause(contract, clause, env)
-	if err != nil {
-		return err
+
+	ifLabel, endLabel := b.newIfLabels()
+	bodyCond, elseCond := joinCond(cond, stmt.cond.String()), joinCond(cond, "!"+stmt.cond.String())
+	if len(stmt.elseBody) > 0 {
+		stk = b.addJumpIf(stk, ifLabel)
+		_, err = compileStatements(b, stk, contract, clause, env, nil, stmt.elseBody, elseCond)
+		if err != nil {
+			return stk, err
+		}
+		b.addJump(stk, endLabel)
+		b.addJumpTarget(stk, ifLabel)
+	} else {
+		stk = b.addOps(stk.drop(), "NOT", "!"+stmt.cond.String())
+		stk = b.addJumpIf(stk, endLabel)
 	}
-	err = requireAllParamsUsedInClause(clause.Params, clause)
+	_, err = compileStatements(b, stk, contract, clause, env, nil, stmt.body, bodyCond)
 	if err != nil {
-		return err
+		return stk, err
 	}
+	stk = b.addJumpTarget(stk, endLabel)
 
-	return nil
+	// Leave a true result in case this is the clause's last statement.
+	// (If it isn't, the optimizer removes it.)
+	stk = b.addBoolean(stk, true)
+	return b.addVerify(stk), nil
+}
+
+func joinCond(outer, inner string) string {
+	if outer == "" {
+		return inner
+	}
+	return outer + " && " + inner
 }
 
 func compileExpr(b *builder, stk stack, contract *Contract, clause *Clause, env *environ, counts map[string]int, expr expression) (stack, error) {
@@ -436,27 +758,40 @@ func compileExpr(b *builder, stk stack, contract *Contract, clause *Clause, env
 
 	switch e := expr.(type) {
 	case *binaryExpr:
+		n, ok, err := foldConst(e)
+		if err != nil {
+			return stk, errorAt(e.pos, "in \"%s\": %s", e, err)
+		}
+		if ok {
+			return b.addInt64(stk, n), nil
+		}
+
 		// Do typechecking after compiling subexpressions (because other
 		// compilation errors are more interesting than type mismatch
 		// errors).
 
 		stk, err = compileExpr(b, stk, contract, clause, env, counts, e.left)
 		if err != nil {
-			return stk, errors.Wrapf(err, "in left operand of \"%s\" expression", e.op.op)
+			return stk, at(e.pos, errors.Wrapf(err, "in left operand of \"%s\" expression", e.op.op))
 		}
 		stk, err = compileExpr(b, stk, contract, clause, env, counts, e.right)
 		if err != nil {
-			return stk, errors.Wrapf(err, "in right operand of \"%s\" expression", e.op.op)
+			return stk, at(e.pos, errors.Wrapf(err, "in right operand of \"%s\" expression", e.op.op))
 		}
 
 		lType := e.left.typ(env)
-		if e.op.left != "" && lType != e.op.left {
-			return stk, fmt.Errorf("in \"%s\", left operand has type \"%s\", must be \"%s\"", e, lType, e.op.left)
-		}
-
 		rType := e.right.typ(env)
-		if e.op.right != "" && rType != e.op.right {
-			return stk, fmt.Errorf("in \"%s\", right operand has type \"%s\", must be \"%s\"", e, rType, e.op.right)
+		if e.op.left == intType {
+			if numericResult(e.op.op, lType, rType) == nilType {
+				return stk, errorAt(e.pos, "in \"%s\", cannot apply \"%s\" to operands of type \"%s\" and \"%s\"", e, e.op.op, lType, rType)
+			}
+		} else {
+			if e.op.left != "" && lType != e.op.left {
+				return stk, errorAt(e.pos, "in \"%s\", left operand has type \"%s\", must be \"%s\"", e, lType, e.op.left)
+			}
+			if e.op.right != "" && rType != e.op.right {
+				return stk, errorAt(e.pos, "in \"%s\", right operand has type \"%s\", must be \"%s\"", e, rType, e.op.right)
+			}
 		}
 
 		switch e.op.op {
@@ -469,29 +804,36 @@ func compileExpr(b *builder, stk stack, contract *Contract, clause *Clause, env
 				} else if rType == hashType && isHashSubtype(lType) {
 					propagateType(contract, clause, env, lType, e.right)
 				} else {
-					return stk, fmt.Errorf("type mismatch in \"%s\": left operand has type \"%s\", right operand has type \"%s\"", e, lType, rType)
+					return stk, errorAt(e.pos, "type mismatch in \"%s\": left operand has type \"%s\", right operand has type \"%s\"", e, lType, rType)
 				}
 			}
 			if lType == "Boolean" {
-				return stk, fmt.Errorf("in \"%s\": using \"%s\" on Boolean values not allowed", e, e.op.op)
+				return stk, errorAt(e.pos, "in \"%s\": using \"%s\" on Boolean values not allowed", e, e.op.op)
 			}
 		}
 
 		stk = b.addOps(stk.dropN(2), e.op.opcodes, e.String())
 
 	case *unaryExpr:
+		n, ok, err := foldConst(e)
+		if err != nil {
+			return stk, errorAt(e.pos, "in \"%s\": %s", e, err)
+		}
+		if ok {
+			return b.addInt64(stk, n), nil
+		}
+
 		// Do typechecking after compiling subexpression (because other
 		// compilation errors are more interesting than type mismatch
 		// errors).
 
-		var err error
 		stk, err = compileExpr(b, stk, contract, clause, env, counts, e.expr)
 		if err != nil {
-			return stk, errors.Wrapf(err, "in \"%s\" expression", e.op.op)
+			return stk, at(e.pos, errors.Wrapf(err, "in \"%s\" expression", e.op.op))
 		}
 
 		if e.op.operand != "" && e.expr.typ(env) != e.op.operand {
-			return stk, fmt.Errorf("in \"%s\", operand has type \"%s\", must be \"%s\"", e, e.expr.typ(env), e.op.operand)
+			return stk, errorAt(e.pos, "in \"%s\", operand has type \"%s\", must be \"%s\"", e, e.expr.typ(env), e.op.operand)
 		}
 		b.addOps(stk.drop(), e.op.opcodes, e.String())
 
@@ -505,18 +847,21 @@ func compileExpr(b *builder, stk stack, contract *Contract, clause *Clause, env
 					partialName := fmt.Sprintf("%s(...)", v)
 					stk = b.addData(stk, nil)
 
+					if len(entry.c.values) > 1 {
+						return stk, errorAt(e.pos, "contract \"%s\" locks more than one value and cannot be called", entry.c.Name)
+					}
 					if len(e.args) != len(entry.c.Params) {
-						return stk, fmt.Errorf("contract \"%s\" expects %d argument(s), got %d", entry.c.Name, len(entry.c.Params), len(e.args))
+						return stk, errorAt(e.pos, "contract \"%s\" expects %d argument(s), got %d", entry.c.Name, len(entry.c.Params), len(e.args))
 					}
 
 					for i := len(e.args) - 1; i >= 0; i-- {
 						arg := e.args[i]
 						if entry.c.Params[i].Type != "" && arg.typ(env) != entry.c.Params[i].Type {
-							return stk, fmt.Errorf("argument %d to contract \"%s\" has type \"%s\", must be \"%s\"", i, entry.c.Name, arg.typ(env), entry.c.Params[i].Type)
+							return stk, errorAt(e.pos, "argument %d to contract \"%s\" has type \"%s\", must be \"%s\"", i, entry.c.Name, arg.typ(env), entry.c.Params[i].Type)
 						}
 						stk, err = compileExpr(b, stk, contract, clause, env, counts, arg)
 						if err != nil {
-							return stk, err
+							return stk, at(e.pos, err)
 						}
 						stk = b.addCatPushdata(stk, partialName)
 					}
@@ -527,7 +872,7 @@ func compileExpr(b *builder, stk stack, contract *Contract, clause *Clause, env
 						// <argN> <argN-1> ... <arg1> <body> DEPTH OVER 0 CHECKPREDICATE
 						stk, err = compileRef(b, stk, counts, varRef(contract.Name))
 						if err != nil {
-							return stk, errors.Wrap(err, "compiling contract call")
+							return stk, at(e.pos, errors.Wrap(err, "compiling contract call"))
 						}
 						stk = b.addCatPushdata(stk, partialName)
 						stk = b.addData(stk, []byte{byte(vm.OP_DEPTH), byte(vm.OP_OVER)})
@@ -538,7 +883,7 @@ func compileExpr(b *builder, stk stack, contract *Contract, clause *Clause, env
 						// <argN> <argN-1> ... <arg1> <body> DEPTH OVER 0 CHECKPREDICATE
 						if len(entry.c.Body) == 0 {
 							// TODO(bobg): sort input contracts topologically to permit forward calling
-							return stk, fmt.Errorf("contract \"%s\" not defined", entry.c.Name)
+							return stk, errorAt(e.pos, "contract \"%s\" not defined", entry.c.Name)
 						}
 						stk = b.addData(stk, entry.c.Body)
 						stk = b.addCatPushdata(stk, partialName)
@@ -552,7 +897,7 @@ func compileExpr(b *builder, stk stack, contract *Contract, clause *Clause, env
 						stk = b.addCat(stk, partialName)
 						if len(entry.c.Body) == 0 {
 							// TODO(bobg): sort input contracts topologically to permit forward calling
-							return stk, fmt.Errorf("contract \"%s\" not defined", entry.c.Name)
+							return stk, errorAt(e.pos, "contract \"%s\" not defined", entry.c.Name)
 						}
 						stk = b.addData(stk, entry.c.Body)
 						stk = b.addCatPushdata(stk, partialName)
@@ -565,11 +910,11 @@ func compileExpr(b *builder, stk stack, contract *Contract, clause *Clause, env
 					return stk, nil
 				}
 			}
-			return stk, fmt.Errorf("unknown function \"%s\"", e.fn)
+			return stk, errorAt(e.pos, "unknown function \"%s\"", e.fn)
 		}
 
 		if len(e.args) != len(bi.args) {
-			return stk, fmt.Errorf("wrong number of args for \"%s\": have %d, want %d", bi.name, len(e.args), len(bi.args))
+			return stk, errorAt(e.pos, "wrong number of args for \"%s\": have %d, want %d", bi.name, len(e.args), len(bi.args))
 		}
 
 		// WARNING WARNING WOOP WOOP
@@ -577,17 +922,17 @@ func compileExpr(b *builder, stk stack, contract *Contract, clause *Clause, env
 		// WARNING WARNING WOOP WOOP
 		if bi.name == "checkTxMultiSig" {
 			if _, ok := e.args[0].(listExpr); !ok {
-				return stk, fmt.Errorf("checkTxMultiSig expects list literals, got %T for argument 0", e.args[0])
+				return stk, errorAt(e.pos, "checkTxMultiSig expects list literals, got %T for argument 0", e.args[0])
 			}
 			if _, ok := e.args[1].(listExpr); !ok {
-				return stk, fmt.Errorf("checkTxMultiSig expects list literals, got %T for argument 1", e.args[1])
+				return stk, errorAt(e.pos, "checkTxMultiSig expects list literals, got %T for argument 1", e.args[1])
 			}
 
 			var k1, k2 int
 
 			stk, k1, err = compileArg(b, stk, contract, clause, env, counts, e.args[1])
 			if err != nil {
-				return stk, err
+				return stk, at(e.pos, err)
 			}
 
 			// stack: [... sigM ... sig1 M]
@@ -598,7 +943,7 @@ func compileExpr(b *builder, stk stack, contract *Contract, clause *Clause, env
 
 			stk, k2, err = compileArg(b, stk, contract, clause, env, counts, e.args[0])
 			if err != nil {
-				return stk, err
+				return stk, at(e.pos, err)
 			}
 
 			// stack: [... sigM ... sig1 txsighash pubkeyN ... pubkey1 N]
@@ -618,7 +963,7 @@ func compileExpr(b *builder, stk stack, contract *Contract, clause *Clause, env
 			var err error
 			stk, k2, err = compileArg(b, stk, contract, clause, env, counts, a)
 			if err != nil {
-				return stk, errors.Wrapf(err, "compiling argument %d in call expression", i)
+				return stk, at(e.pos, errors.Wrapf(err, "compiling argument %d in call expression", i))
 			}
 			k += k2
 		}
@@ -628,7 +973,18 @@ func compileExpr(b *builder, stk stack, contract *Contract, clause *Clause, env
 		// errors).
 		for i, actual := range e.args {
 			if bi.args[i] != "" && actual.typ(env) != bi.args[i] {
-				return stk, fmt.Errorf("argument %d to \"%s\" has type \"%s\", must be \"%s\"", i, bi.name, actual.typ(env), bi.args[i])
+				return stk, errorAt(e.pos, "argument %d to \"%s\" has type \"%s\", must be \"%s\"", i, bi.name, actual.typ(env), bi.args[i])
+			}
+		}
+		if sameNumericArgs(bi) {
+			t := e.args[0].typ(env)
+			if t != amountType && t != intType {
+				return stk, errorAt(e.pos, "argument 0 to \"%s\" has type \"%s\", must be \"Amount\" or \"Integer\"", bi.name, t)
+			}
+			for i, actual := range e.args[1:] {
+				if actual.typ(env) != t {
+					return stk, errorAt(e.pos, "argument %d to \"%s\" has type \"%s\", must match argument 0 (\"%s\")", i+1, bi.name, actual.typ(env), t)
+				}
 			}
 		}
 
@@ -641,6 +997,9 @@ func compileExpr(b *builder, stk stack, contract *Contract, clause *Clause, env
 		}
 
 	case varRef:
+		if entry := env.lookup(string(e)); entry != nil && entry.r == roleConst {
+			return compileExpr(b, stk, contract, clause, env, counts, entry.value)
+		}
 		return compileRef(b, stk, counts, e)
 
 	case integerLiteral:
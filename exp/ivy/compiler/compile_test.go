@@ -1,12 +1,21 @@
 package compiler
 
 import (
+	"bytes"
 	"encoding/hex"
 	"encoding/json"
+	"fmt"
+	"math"
 	"strings"
 	"testing"
 
+	"golang.org/x/crypto/sha3"
+
+	"chain/crypto/ed25519"
+	chainjson "chain/encoding/json"
 	"chain/exp/ivy/compiler/ivytest"
+	"chain/protocol/vm"
+	"chain/testutil"
 )
 
 func TestCompile(t *testing.T) {
@@ -23,17 +32,17 @@ func TestCompile(t *testing.T) {
 		{
 			"LockWithPublicKey",
 			ivytest.LockWithPublicKey,
-			`[{"name":"LockWithPublicKey","params":[{"name":"publicKey","declared_type":"PublicKey"}],"clauses":[{"name":"unlockWithSig","params":[{"name":"sig","declared_type":"Signature"}],"values":[{"name":"locked"}]}],"value":"locked","body_bytecode":"ae7cac","body_opcodes":"TXSIGHASH SWAP CHECKSIG","recursive":false}]`,
+			`[{"name":"LockWithPublicKey","params":[{"name":"publicKey","declared_type":"PublicKey"}],"clauses":[{"name":"unlockWithSig","params":[{"name":"sig","declared_type":"Signature","encoding":"hex","min_len":64,"max_len":64}],"values":[{"name":"locked"}]}],"value":"locked","body_bytecode":"ae7cac","body_opcodes":"TXSIGHASH SWAP CHECKSIG","recursive":false}]`,
 		},
 		{
 			"LockWithPublicKeyHash",
 			ivytest.LockWithPKHash,
-			`[{"name":"LockWithPublicKeyHash","params":[{"name":"pubKeyHash","declared_type":"Hash","inferred_type":"Sha3(PublicKey)"}],"clauses":[{"name":"spend","params":[{"name":"pubKey","declared_type":"PublicKey"},{"name":"sig","declared_type":"Signature"}],"hash_calls":[{"hash_type":"sha3","arg":"pubKey","arg_type":"PublicKey"}],"values":[{"name":"value"}]}],"value":"value","body_bytecode":"5279aa887cae7cac","body_opcodes":"2 PICK SHA3 EQUALVERIFY SWAP TXSIGHASH SWAP CHECKSIG","recursive":false}]`,
+			`[{"name":"LockWithPublicKeyHash","params":[{"name":"pubKeyHash","declared_type":"Hash","inferred_type":"Sha3(PublicKey)"}],"clauses":[{"name":"spend","params":[{"name":"pubKey","declared_type":"PublicKey","encoding":"hex","min_len":32,"max_len":32},{"name":"sig","declared_type":"Signature","encoding":"hex","min_len":64,"max_len":64}],"hash_calls":[{"hash_type":"sha3","arg":"pubKey","arg_type":"PublicKey"}],"values":[{"name":"value"}]}],"value":"value","body_bytecode":"5279aa887cae7cac","body_opcodes":"2 PICK SHA3 EQUALVERIFY SWAP TXSIGHASH SWAP CHECKSIG","recursive":false}]`,
 		},
 		{
 			"LockWith2of3Keys",
 			ivytest.LockWith2of3Keys,
-			`[{"name":"LockWith3Keys","params":[{"name":"pubkey1","declared_type":"PublicKey"},{"name":"pubkey2","declared_type":"PublicKey"},{"name":"pubkey3","declared_type":"PublicKey"}],"clauses":[{"name":"unlockWith2Sigs","params":[{"name":"sig1","declared_type":"Signature"},{"name":"sig2","declared_type":"Signature"}],"values":[{"name":"locked"}]}],"value":"locked","body_bytecode":"537a547a526bae71557a536c7cad","body_opcodes":"3 ROLL 4 ROLL 2 TOALTSTACK TXSIGHASH 2ROT 5 ROLL 3 FROMALTSTACK SWAP CHECKMULTISIG","recursive":false}]`,
+			`[{"name":"LockWith3Keys","params":[{"name":"pubkey1","declared_type":"PublicKey"},{"name":"pubkey2","declared_type":"PublicKey"},{"name":"pubkey3","declared_type":"PublicKey"}],"clauses":[{"name":"unlockWith2Sigs","params":[{"name":"sig1","declared_type":"Signature","encoding":"hex","min_len":64,"max_len":64},{"name":"sig2","declared_type":"Signature","encoding":"hex","min_len":64,"max_len":64}],"values":[{"name":"locked"}]}],"value":"locked","body_bytecode":"537a547a526bae71557a536c7cad","body_opcodes":"3 ROLL 4 ROLL 2 TOALTSTACK TXSIGHASH 2ROT 5 ROLL 3 FROMALTSTACK SWAP CHECKMULTISIG","recursive":false}]`,
 		},
 		{
 			"LockToOutput",
@@ -43,38 +52,68 @@ func TestCompile(t *testing.T) {
 		{
 			"TradeOffer",
 			ivytest.TradeOffer,
-			`[{"name":"TradeOffer","params":[{"name":"requestedAsset","declared_type":"Asset"},{"name":"requestedAmount","declared_type":"Amount"},{"name":"sellerProgram","declared_type":"Program"},{"name":"sellerKey","declared_type":"PublicKey"}],"clauses":[{"name":"trade","reqs":[{"name":"payment","asset":"requestedAsset","amount":"requestedAmount"}],"values":[{"name":"payment","program":"sellerProgram","asset":"requestedAsset","amount":"requestedAmount"},{"name":"offered"}]},{"name":"cancel","params":[{"name":"sellerSig","declared_type":"Signature"}],"values":[{"name":"offered","program":"sellerProgram"}]}],"value":"offered","body_bytecode":"547a641300000000007251557ac16323000000547a547aae7cac690000c3c251577ac1","body_opcodes":"4 ROLL JUMPIF:$cancel $trade 0 0 2SWAP 1 5 ROLL CHECKOUTPUT JUMP:$_end $cancel 4 ROLL 4 ROLL TXSIGHASH SWAP CHECKSIG VERIFY 0 0 AMOUNT ASSET 1 7 ROLL CHECKOUTPUT $_end","recursive":false}]`,
+			`[{"name":"TradeOffer","params":[{"name":"requestedAsset","declared_type":"Asset"},{"name":"requestedAmount","declared_type":"Amount"},{"name":"sellerProgram","declared_type":"Program"},{"name":"sellerKey","declared_type":"PublicKey"}],"clauses":[{"name":"trade","reqs":[{"name":"payment","asset":"requestedAsset","amount":"requestedAmount","asset_params":["requestedAsset"],"amount_params":["requestedAmount"]}],"values":[{"name":"payment","program":"sellerProgram","asset":"requestedAsset","amount":"requestedAmount"},{"name":"offered"}]},{"name":"cancel","params":[{"name":"sellerSig","declared_type":"Signature","encoding":"hex","min_len":64,"max_len":64}],"values":[{"name":"offered","program":"sellerProgram"}]}],"value":"offered","body_bytecode":"547a641300000000007251557ac16323000000547a547aae7cac690000c3c251577ac1","body_opcodes":"4 ROLL JUMPIF:$cancel $trade 0 0 2SWAP 1 5 ROLL CHECKOUTPUT JUMP:$_end $cancel 4 ROLL 4 ROLL TXSIGHASH SWAP CHECKSIG VERIFY 0 0 AMOUNT ASSET 1 7 ROLL CHECKOUTPUT $_end","recursive":false}]`,
 		},
 		{
 			"EscrowedTransfer",
 			ivytest.EscrowedTransfer,
-			`[{"name":"EscrowedTransfer","params":[{"name":"agent","declared_type":"PublicKey"},{"name":"sender","declared_type":"Program"},{"name":"recipient","declared_type":"Program"}],"clauses":[{"name":"approve","params":[{"name":"sig","declared_type":"Signature"}],"values":[{"name":"value","program":"recipient"}]},{"name":"reject","params":[{"name":"sig","declared_type":"Signature"}],"values":[{"name":"value","program":"sender"}]}],"value":"value","body_bytecode":"537a641b000000537a7cae7cac690000c3c251567ac1632a000000537a7cae7cac690000c3c251557ac1","body_opcodes":"3 ROLL JUMPIF:$reject $approve 3 ROLL SWAP TXSIGHASH SWAP CHECKSIG VERIFY 0 0 AMOUNT ASSET 1 6 ROLL CHECKOUTPUT JUMP:$_end $reject 3 ROLL SWAP TXSIGHASH SWAP CHECKSIG VERIFY 0 0 AMOUNT ASSET 1 5 ROLL CHECKOUTPUT $_end","recursive":false}]`,
+			`[{"name":"EscrowedTransfer","params":[{"name":"agent","declared_type":"PublicKey"},{"name":"sender","declared_type":"Program"},{"name":"recipient","declared_type":"Program"}],"clauses":[{"name":"approve","params":[{"name":"sig","declared_type":"Signature","encoding":"hex","min_len":64,"max_len":64}],"values":[{"name":"value","program":"recipient"}]},{"name":"reject","params":[{"name":"sig","declared_type":"Signature","encoding":"hex","min_len":64,"max_len":64}],"values":[{"name":"value","program":"sender"}]}],"value":"value","body_bytecode":"537a641b000000537a7cae7cac690000c3c251567ac1632a000000537a7cae7cac690000c3c251557ac1","body_opcodes":"3 ROLL JUMPIF:$reject $approve 3 ROLL SWAP TXSIGHASH SWAP CHECKSIG VERIFY 0 0 AMOUNT ASSET 1 6 ROLL CHECKOUTPUT JUMP:$_end $reject 3 ROLL SWAP TXSIGHASH SWAP CHECKSIG VERIFY 0 0 AMOUNT ASSET 1 5 ROLL CHECKOUTPUT $_end","recursive":false}]`,
 		},
 		{
 			"CollateralizedLoan",
 			ivytest.CollateralizedLoan,
-			`[{"name":"CollateralizedLoan","params":[{"name":"balanceAsset","declared_type":"Asset"},{"name":"balanceAmount","declared_type":"Amount"},{"name":"deadline","declared_type":"Time"},{"name":"lender","declared_type":"Program"},{"name":"borrower","declared_type":"Program"}],"clauses":[{"name":"repay","reqs":[{"name":"payment","asset":"balanceAsset","amount":"balanceAmount"}],"values":[{"name":"payment","program":"lender","asset":"balanceAsset","amount":"balanceAmount"},{"name":"collateral","program":"borrower"}]},{"name":"default","mintimes":["deadline"],"values":[{"name":"collateral","program":"lender"}]}],"value":"collateral","body_bytecode":"557a641c00000000007251567ac1695100c3c251567ac163280000007bc59f690000c3c251577ac1","body_opcodes":"5 ROLL JUMPIF:$default $repay 0 0 2SWAP 1 6 ROLL CHECKOUTPUT VERIFY 1 0 AMOUNT ASSET 1 6 ROLL CHECKOUTPUT JUMP:$_end $default ROT MINTIME LESSTHAN VERIFY 0 0 AMOUNT ASSET 1 7 ROLL CHECKOUTPUT $_end","recursive":false}]`,
+			`[{"name":"CollateralizedLoan","params":[{"name":"balanceAsset","declared_type":"Asset"},{"name":"balanceAmount","declared_type":"Amount"},{"name":"deadline","declared_type":"Time"},{"name":"lender","declared_type":"Program"},{"name":"borrower","declared_type":"Program"}],"clauses":[{"name":"repay","reqs":[{"name":"payment","asset":"balanceAsset","amount":"balanceAmount","asset_params":["balanceAsset"],"amount_params":["balanceAmount"]}],"values":[{"name":"payment","program":"lender","asset":"balanceAsset","amount":"balanceAmount"},{"name":"collateral","program":"borrower"}]},{"name":"default","mintimes":["deadline"],"values":[{"name":"collateral","program":"lender"}]}],"value":"collateral","body_bytecode":"557a641c00000000007251567ac1695100c3c251567ac163280000007bc59f690000c3c251577ac1","body_opcodes":"5 ROLL JUMPIF:$default $repay 0 0 2SWAP 1 6 ROLL CHECKOUTPUT VERIFY 1 0 AMOUNT ASSET 1 6 ROLL CHECKOUTPUT JUMP:$_end $default ROT MINTIME LESSTHAN VERIFY 0 0 AMOUNT ASSET 1 7 ROLL CHECKOUTPUT $_end","recursive":false}]`,
 		},
 		{
 			"RevealPreimage",
 			ivytest.RevealPreimage,
-			`[{"name":"RevealPreimage","params":[{"name":"hash","declared_type":"Hash","inferred_type":"Sha3(String)"}],"clauses":[{"name":"reveal","params":[{"name":"string","declared_type":"String"}],"hash_calls":[{"hash_type":"sha3","arg":"string","arg_type":"String"}],"values":[{"name":"value"}]}],"value":"value","body_bytecode":"7caa87","body_opcodes":"SWAP SHA3 EQUAL","recursive":false}]`,
+			`[{"name":"RevealPreimage","params":[{"name":"hash","declared_type":"Hash","inferred_type":"Sha3(String)"}],"clauses":[{"name":"reveal","params":[{"name":"string","declared_type":"String","encoding":"hex"}],"hash_calls":[{"hash_type":"sha3","arg":"string","arg_type":"String"}],"values":[{"name":"value"}]}],"value":"value","body_bytecode":"7caa87","body_opcodes":"SWAP SHA3 EQUAL","recursive":false}]`,
 		},
 		{
 			"CallOptionWithSettlement",
 			ivytest.CallOptionWithSettlement,
-			`[{"name":"CallOptionWithSettlement","params":[{"name":"strikePrice","declared_type":"Amount"},{"name":"strikeCurrency","declared_type":"Asset"},{"name":"sellerProgram","declared_type":"Program"},{"name":"sellerKey","declared_type":"PublicKey"},{"name":"buyerKey","declared_type":"PublicKey"},{"name":"deadline","declared_type":"Time"}],"clauses":[{"name":"exercise","params":[{"name":"buyerSig","declared_type":"Signature"}],"reqs":[{"name":"payment","asset":"strikeCurrency","amount":"strikePrice"}],"maxtimes":["deadline"],"values":[{"name":"payment","program":"sellerProgram","asset":"strikeCurrency","amount":"strikePrice"},{"name":"underlying"}]},{"name":"expire","mintimes":["deadline"],"values":[{"name":"underlying","program":"sellerProgram"}]},{"name":"settle","params":[{"name":"sellerSig","declared_type":"Signature"},{"name":"buyerSig","declared_type":"Signature"}],"values":[{"name":"underlying"}]}],"value":"underlying","body_bytecode":"567a76529c64390000006427000000557ac6a06971ae7cac6900007b537a51557ac16349000000557ac59f690000c3c251577ac1634900000075577a547aae7cac69557a547aae7cac","body_opcodes":"6 ROLL DUP 2 NUMEQUAL JUMPIF:$settle JUMPIF:$expire $exercise 5 ROLL MAXTIME GREATERTHAN VERIFY 2ROT TXSIGHASH SWAP CHECKSIG VERIFY 0 0 ROT 3 ROLL 1 5 ROLL CHECKOUTPUT JUMP:$_end $expire 5 ROLL MINTIME LESSTHAN VERIFY 0 0 AMOUNT ASSET 1 7 ROLL CHECKOUTPUT JUMP:$_end $settle DROP 7 ROLL 4 ROLL TXSIGHASH SWAP CHECKSIG VERIFY 5 ROLL 4 ROLL TXSIGHASH SWAP CHECKSIG $_end","recursive":false}]`,
+			`[{"name":"CallOptionWithSettlement","params":[{"name":"strikePrice","declared_type":"Amount"},{"name":"strikeCurrency","declared_type":"Asset"},{"name":"sellerProgram","declared_type":"Program"},{"name":"sellerKey","declared_type":"PublicKey"},{"name":"buyerKey","declared_type":"PublicKey"},{"name":"deadline","declared_type":"Time"}],"clauses":[{"name":"exercise","params":[{"name":"buyerSig","declared_type":"Signature","encoding":"hex","min_len":64,"max_len":64}],"reqs":[{"name":"payment","asset":"strikeCurrency","amount":"strikePrice","asset_params":["strikeCurrency"],"amount_params":["strikePrice"]}],"maxtimes":["deadline"],"values":[{"name":"payment","program":"sellerProgram","asset":"strikeCurrency","amount":"strikePrice"},{"name":"underlying"}]},{"name":"expire","mintimes":["deadline"],"values":[{"name":"underlying","program":"sellerProgram"}]},{"name":"settle","params":[{"name":"sellerSig","declared_type":"Signature","encoding":"hex","min_len":64,"max_len":64},{"name":"buyerSig","declared_type":"Signature","encoding":"hex","min_len":64,"max_len":64}],"values":[{"name":"underlying"}]}],"value":"underlying","body_bytecode":"567a76529c64390000006427000000557ac6a06971ae7cac6900007b537a51557ac16349000000557ac59f690000c3c251577ac1634900000075577a547aae7cac69557a547aae7cac","body_opcodes":"6 ROLL DUP 2 NUMEQUAL JUMPIF:$settle JUMPIF:$expire $exercise 5 ROLL MAXTIME GREATERTHAN VERIFY 2ROT TXSIGHASH SWAP CHECKSIG VERIFY 0 0 ROT 3 ROLL 1 5 ROLL CHECKOUTPUT JUMP:$_end $expire 5 ROLL MINTIME LESSTHAN VERIFY 0 0 AMOUNT ASSET 1 7 ROLL CHECKOUTPUT JUMP:$_end $settle DROP 7 ROLL 4 ROLL TXSIGHASH SWAP CHECKSIG VERIFY 5 ROLL 4 ROLL TXSIGHASH SWAP CHECKSIG $_end","recursive":false}]`,
 		},
 		{
 			"PriceChanger",
 			ivytest.PriceChanger,
-			`[{"name":"PriceChanger","params":[{"name":"askAmount","declared_type":"Amount"},{"name":"askAsset","declared_type":"Asset"},{"name":"sellerKey","declared_type":"PublicKey"},{"name":"sellerProg","declared_type":"Program"}],"clauses":[{"name":"changePrice","params":[{"name":"newAmount","declared_type":"Amount"},{"name":"newAsset","declared_type":"Asset"},{"name":"sig","declared_type":"Signature"}],"values":[{"name":"offered","program":"PriceChanger(newAmount, newAsset, sellerKey, sellerProg)"}],"contracts":["PriceChanger"]},{"name":"redeem","reqs":[{"name":"payment","asset":"askAsset","amount":"askAmount"}],"values":[{"name":"payment","program":"sellerProg","asset":"askAsset","amount":"askAmount"},{"name":"offered"}]}],"value":"offered","body_bytecode":"557a6433000000557a5479ae7cac690000c3c251005a7a89597a89597a89597a89567a890274787e008901c07ec1633d0000000000537a547a51577ac1","body_opcodes":"5 ROLL JUMPIF:$redeem $changePrice 5 ROLL 4 PICK TXSIGHASH SWAP CHECKSIG VERIFY 0 0 AMOUNT ASSET 1 0 10 ROLL CATPUSHDATA 9 ROLL CATPUSHDATA 9 ROLL CATPUSHDATA 9 ROLL CATPUSHDATA 6 ROLL CATPUSHDATA 0x7478 CAT 0 CATPUSHDATA 192 CAT CHECKOUTPUT JUMP:$_end $redeem 0 0 3 ROLL 4 ROLL 1 7 ROLL CHECKOUTPUT $_end","recursive":true}]`,
+			`[{"name":"PriceChanger","params":[{"name":"askAmount","declared_type":"Amount"},{"name":"askAsset","declared_type":"Asset"},{"name":"sellerKey","declared_type":"PublicKey"},{"name":"sellerProg","declared_type":"Program"}],"clauses":[{"name":"changePrice","params":[{"name":"newAmount","declared_type":"Amount","encoding":"integer"},{"name":"newAsset","declared_type":"Asset","encoding":"hex","min_len":32,"max_len":32},{"name":"sig","declared_type":"Signature","encoding":"hex","min_len":64,"max_len":64}],"values":[{"name":"offered","program":"PriceChanger(newAmount, newAsset, sellerKey, sellerProg)"}],"contracts":["PriceChanger"]},{"name":"redeem","reqs":[{"name":"payment","asset":"askAsset","amount":"askAmount","asset_params":["askAsset"],"amount_params":["askAmount"]}],"values":[{"name":"payment","program":"sellerProg","asset":"askAsset","amount":"askAmount"},{"name":"offered"}]}],"value":"offered","body_bytecode":"557a6433000000557a5479ae7cac690000c3c251005a7a89597a89597a89597a89567a890274787e008901c07ec1633d0000000000537a547a51577ac1","body_opcodes":"5 ROLL JUMPIF:$redeem $changePrice 5 ROLL 4 PICK TXSIGHASH SWAP CHECKSIG VERIFY 0 0 AMOUNT ASSET 1 0 10 ROLL CATPUSHDATA 9 ROLL CATPUSHDATA 9 ROLL CATPUSHDATA 9 ROLL CATPUSHDATA 6 ROLL CATPUSHDATA 0x7478 CAT 0 CATPUSHDATA 192 CAT CHECKOUTPUT JUMP:$_end $redeem 0 0 3 ROLL 4 ROLL 1 7 ROLL CHECKOUTPUT $_end","recursive":true}]`,
 		},
 		{
 			"OneTwo",
 			ivytest.OneTwo,
 			`[{"name":"Two","params":[{"name":"b","declared_type":"Program"},{"name":"c","declared_type":"Program"},{"name":"expirationTime","declared_type":"Time"}],"clauses":[{"name":"redeem","maxtimes":["expirationTime"],"values":[{"name":"value","program":"b"}]},{"name":"default","mintimes":["expirationTime"],"values":[{"name":"value","program":"c"}]}],"value":"value","body_bytecode":"537a64180000007bc6a0690000c3c251557ac163240000007bc59f690000c3c251567ac1","body_opcodes":"3 ROLL JUMPIF:$default $redeem ROT MAXTIME GREATERTHAN VERIFY 0 0 AMOUNT ASSET 1 5 ROLL CHECKOUTPUT JUMP:$_end $default ROT MINTIME LESSTHAN VERIFY 0 0 AMOUNT ASSET 1 6 ROLL CHECKOUTPUT $_end","recursive":false},{"name":"One","params":[{"name":"a","declared_type":"Program"},{"name":"b","declared_type":"Program"},{"name":"c","declared_type":"Program"},{"name":"switchTime","declared_type":"Time"},{"name":"expirationTime","declared_type":"Time"}],"clauses":[{"name":"redeem","maxtimes":["switchTime"],"values":[{"name":"value","program":"a"}]},{"name":"switch","mintimes":["switchTime"],"values":[{"name":"value","program":"Two(b, c, expirationTime)"}],"contracts":["Two"]}],"value":"value","body_bytecode":"557a6419000000537ac6a0690000c3c251557ac1635c000000537ac59f690000c3c25100597a89587a89577a8901747e24537a64180000007bc6a0690000c3c251557ac163240000007bc59f690000c3c251567ac189008901c07ec1","body_opcodes":"5 ROLL JUMPIF:$switch $redeem 3 ROLL MAXTIME GREATERTHAN VERIFY 0 0 AMOUNT ASSET 1 5 ROLL CHECKOUTPUT JUMP:$_end $switch 3 ROLL MINTIME LESSTHAN VERIFY 0 0 AMOUNT ASSET 1 0 9 ROLL CATPUSHDATA 8 ROLL CATPUSHDATA 7 ROLL CATPUSHDATA 116 CAT 0x537a64180000007bc6a0690000c3c251557ac163240000007bc59f690000c3c251567ac1 CATPUSHDATA 0 CATPUSHDATA 192 CAT CHECKOUTPUT $_end","recursive":false}]`,
 		},
+		{
+			"DeadlineTransfer",
+			ivytest.DeadlineTransfer,
+			`[{"name":"DeadlineTransfer","params":[{"name":"early","declared_type":"Program"},{"name":"late","declared_type":"Program"},{"name":"deadline","declared_type":"Time"}],"clauses":[{"name":"transfer","values":[{"name":"value","program":"early","condition":"before(deadline)"},{"name":"value","program":"late","condition":"!before(deadline)"}]}],"value":"value","body_bytecode":"7bc6a064160000000000c3c2515679c169631f0000000000c3c2515579c16951","body_opcodes":"ROT MAXTIME GREATERTHAN JUMPIF:$_if1 0 0 AMOUNT ASSET 1 6 PICK CHECKOUTPUT VERIFY JUMP:$_endif1 $_if1 0 0 AMOUNT ASSET 1 5 PICK CHECKOUTPUT VERIFY $_endif1 TRUE","recursive":false}]`,
+		},
+		{
+			"SignAfterDeadline",
+			ivytest.SignAfterDeadline,
+			`[{"name":"SignAfterDeadline","params":[{"name":"owner","declared_type":"Program"},{"name":"ownerKey","declared_type":"PublicKey"},{"name":"deadline","declared_type":"Time"}],"clauses":[{"name":"redeem","params":[{"name":"sig","declared_type":"Signature","encoding":"hex","min_len":64,"max_len":64}],"values":[{"name":"value","program":"owner"}]}],"value":"value","body_bytecode":"7bc59f91641100000052795279ae7cac690000c3c251557ac1","body_opcodes":"ROT MINTIME LESSTHAN NOT JUMPIF:$_endif1 2 PICK 2 PICK TXSIGHASH SWAP CHECKSIG VERIFY $_endif1 0 0 AMOUNT ASSET 1 5 ROLL CHECKOUTPUT","recursive":false}]`,
+		},
+		{
+			"DoublePrice",
+			ivytest.DoublePrice,
+			`[{"name":"DoublePrice","params":[{"name":"requestedAsset","declared_type":"Asset"},{"name":"requestedAmount","declared_type":"Amount"},{"name":"sellerProgram","declared_type":"Program"},{"name":"deadline","declared_type":"Time"}],"clauses":[{"name":"trade","reqs":[{"name":"payment","asset":"requestedAsset","amount":"(requestedAmount * 2)","asset_params":["requestedAsset"],"amount_params":["requestedAmount"]}],"maxtimes":["(deadline + (((24 * 60) * 60) * 1000))"],"values":[{"name":"payment","program":"sellerProgram","asset":"requestedAsset","amount":"(requestedAmount * 2)"},{"name":"offered"}]}],"value":"offered","body_bytecode":"537a04005c260593c6a0690000537a5295537a51557ac1","body_opcodes":"3 ROLL 86400000 ADD MAXTIME GREATERTHAN VERIFY 0 0 3 ROLL 2 MUL 3 ROLL 1 5 ROLL CHECKOUTPUT","recursive":false}]`,
+		},
+		{
+			"CappedPayment",
+			ivytest.CappedPayment,
+			`[{"name":"CappedPayment","params":[{"name":"cap","declared_type":"Amount"},{"name":"paymentAsset","declared_type":"Asset"},{"name":"seller","declared_type":"Program"}],"clauses":[{"name":"pay","params":[{"name":"requested","declared_type":"Amount","encoding":"integer"}],"reqs":[{"name":"payment","asset":"paymentAsset","amount":"min(requested, cap)","asset_params":["paymentAsset"],"amount_params":["cap","requested"]}],"values":[{"name":"payment","program":"seller","asset":"paymentAsset","amount":"min(requested, cap)"},{"name":"value"}]}],"value":"value","body_bytecode":"00007b557aa3537a51557ac1","body_opcodes":"0 0 ROT 5 ROLL MIN 3 ROLL 1 5 ROLL CHECKOUTPUT","recursive":false}]`,
+		},
+		{
+			"HandoffVault",
+			ivytest.HandoffVault,
+			`[{"name":"Handoff","params":[{"name":"hash","declared_type":"Hash"}],"clauses":[{"name":"handoff","values":[{"name":"value","program":"Vault(hash)"}],"contracts":["Vault"]}],"value":"value","body_bytecode":"0000c3c25100567a8901747e037caa8789008901c07ec1","body_opcodes":"0 0 AMOUNT ASSET 1 0 6 ROLL CATPUSHDATA 116 CAT 0x7caa87 CATPUSHDATA 0 CATPUSHDATA 192 CAT CHECKOUTPUT","recursive":false},{"name":"Vault","params":[{"name":"hash","declared_type":"Hash","inferred_type":"Sha3(String)"}],"clauses":[{"name":"reveal","params":[{"name":"string","declared_type":"String","encoding":"hex"}],"hash_calls":[{"hash_type":"sha3","arg":"string","arg_type":"String"}],"values":[{"name":"value"}]}],"value":"value","body_bytecode":"7caa87","body_opcodes":"SWAP SHA3 EQUAL","recursive":false}]`,
+		},
+		{
+			"TwoValueEscrow",
+			ivytest.TwoValueEscrow,
+			`[{"name":"TwoValueEscrow","params":[{"name":"agent","declared_type":"PublicKey"},{"name":"sender","declared_type":"Program"},{"name":"recipient","declared_type":"Program"}],"clauses":[{"name":"settle","params":[{"name":"sig","declared_type":"Signature","encoding":"hex","min_len":64,"max_len":64}],"values":[{"name":"bond","program":"sender"},{"name":"collateral","program":"recipient"}]},{"name":"refund","params":[{"name":"sig","declared_type":"Signature","encoding":"hex","min_len":64,"max_len":64}],"values":[{"name":"bond","program":"sender"},{"name":"collateral","program":"sender"}]}],"value":"bond","locked_values":[{"name":"bond","body_bytecode":"537a641b000000537a7cae7cac690000c3c251557ac1632a000000537a7cae7cac690000c3c251557ac1","body_opcodes":"3 ROLL JUMPIF:$refund $settle 3 ROLL SWAP TXSIGHASH SWAP CHECKSIG VERIFY 0 0 AMOUNT ASSET 1 5 ROLL CHECKOUTPUT JUMP:$_end $refund 3 ROLL SWAP TXSIGHASH SWAP CHECKSIG VERIFY 0 0 AMOUNT ASSET 1 5 ROLL CHECKOUTPUT $_end"},{"name":"collateral","body_bytecode":"537a641b000000537a7cae7cac695100c3c251567ac1632a000000537a7cae7cac695100c3c251557ac1","body_opcodes":"3 ROLL JUMPIF:$refund $settle 3 ROLL SWAP TXSIGHASH SWAP CHECKSIG VERIFY 1 0 AMOUNT ASSET 1 6 ROLL CHECKOUTPUT JUMP:$_end $refund 3 ROLL SWAP TXSIGHASH SWAP CHECKSIG VERIFY 1 0 AMOUNT ASSET 1 5 ROLL CHECKOUTPUT $_end"}],"body_bytecode":"537a641b000000537a7cae7cac690000c3c251557ac1632a000000537a7cae7cac690000c3c251557ac1","body_opcodes":"3 ROLL JUMPIF:$refund $settle 3 ROLL SWAP TXSIGHASH SWAP CHECKSIG VERIFY 0 0 AMOUNT ASSET 1 5 ROLL CHECKOUTPUT JUMP:$_end $refund 3 ROLL SWAP TXSIGHASH SWAP CHECKSIG VERIFY 0 0 AMOUNT ASSET 1 5 ROLL CHECKOUTPUT $_end","recursive":false}]`,
+		},
 	}
 	for _, c := range cases {
 		t.Run(c.name, func(t *testing.T) {
@@ -102,3 +141,594 @@ func mustDecodeHex(h string) []byte {
 	}
 	return bits
 }
+
+func TestCompileErrors(t *testing.T) {
+	cases := []struct {
+		name     string
+		contract string
+		want     ErrorList
+	}{
+		{
+			"MissingParen",
+			`
+contract LockWithPublicKey(publicKey: PublicKey locks locked {
+  clause unlockWithSig(sig: Signature) {
+    verify checkTxSig(publicKey, sig)
+    unlock locked
+  }
+}`,
+			ErrorList{{2, 48, `expected , or ) token, got "locks"`}},
+		},
+		{
+			"UnknownBuiltin",
+			`
+contract LockWithPublicKey(publicKey: PublicKey) locks locked {
+  clause unlockWithSig(sig: Signature) {
+    verify checkTxSg(publicKey, sig)
+    unlock locked
+  }
+}`,
+			ErrorList{{4, 11, `unknown function "checkTxSg"`}},
+		},
+		{
+			"TypeMismatch",
+			`
+contract RevealPreimage(hash: Hash) locks value {
+  clause reveal(string: String) {
+    verify size(string) == hash
+    unlock value
+  }
+}`,
+			ErrorList{{4, 24, `type mismatch in "(size(string) == hash)": left operand has type "Integer", right operand has type "Hash"`}},
+		},
+		{
+			"MultipleErrors",
+			`
+contract EscrowedTransfer(agent: PublicKey, sender: Program, recipient: Program) locks value {
+  clause approve(sig: Signature) {
+    verify checkTxSig(sig, agent)
+    lock value with recipient
+  }
+  clause reject(sig: Signature) {
+    verify checkTxSig(agent, sig)
+    lock value with sender
+  }
+}
+contract TrivialLock() locks locked {
+  clause trivialUnlock() {
+    verify after(1)
+    unlock locked
+  }
+}`,
+			ErrorList{
+				{4, 11, `argument 0 to "checkTxSig" has type "Signature", must be "PublicKey"`},
+				{14, 11, `argument 0 to "after" has type "Integer", must be "Time"`},
+			},
+		},
+		{
+			"UnbalancedIf",
+			`
+contract DeadlineTransfer(early: Program, late: PublicKey, deadline: Time) locks value {
+  clause transfer(sig: Signature) {
+    if before(deadline) {
+      lock value with early
+    } else {
+      verify checkTxSig(late, sig)
+    }
+  }
+}`,
+			ErrorList{{4, 4, `value "value" disposed in only one branch of if statement in clause "transfer"`}},
+		},
+		{
+			"IfWithoutElseDisposes",
+			`
+contract DeadlineTransfer(early, late: Program, deadline: Time) locks value {
+  clause transfer() {
+    if before(deadline) {
+      lock value with early
+    }
+    lock value with late
+  }
+}`,
+			ErrorList{{4, 4, `value "value" disposed in only one branch of if statement in clause "transfer"`}},
+		},
+		{
+			"IfNotBoolean",
+			`
+contract DeadlineTransfer(early: Program, deadline: Time) locks value {
+  clause transfer() {
+    if deadline {
+      lock value with early
+    } else {
+      lock value with early
+    }
+  }
+}`,
+			ErrorList{{4, 4, `condition in if statement in clause "transfer" has type "Time", must be Boolean`}},
+		},
+		{
+			"AmountPlusTime",
+			`
+contract DoublePrice(requestedAsset: Asset, requestedAmount: Amount, sellerProgram: Program, deadline: Time) locks offered {
+  clause trade() requires payment: requestedAmount + deadline of requestedAsset {
+    lock payment with sellerProgram
+    unlock offered
+  }
+}`,
+			ErrorList{{3, 51, `in "(requestedAmount + deadline)", cannot apply "+" to operands of type "Amount" and "Time"`}},
+		},
+		{
+			"ConstantOverflow",
+			`
+contract Overflow(deadline: Time) locks value {
+  clause spend() {
+    verify before(deadline + 9223372036854775807 * 2)
+    unlock value
+  }
+}`,
+			ErrorList{{4, 49, `in "(9223372036854775807 * 2)": integer overflow`}},
+		},
+		{
+			"MinMixedTypes",
+			`
+contract CappedPayment(cap: Integer, paymentAsset: Asset, seller: Program) locks value {
+  clause pay(requested: Amount) requires payment: min(requested, cap) of paymentAsset {
+    lock payment with seller
+    unlock value
+  }
+}`,
+			ErrorList{{3, 50, `argument 1 to "min" has type "Integer", must match argument 0 ("Amount")`}},
+		},
+		{
+			"AbsOfTime",
+			`
+contract AbsTime(deadline: Time) locks value {
+  clause spend() {
+    verify abs(deadline) > 0
+    unlock value
+  }
+}`,
+			ErrorList{{4, 11, `argument 0 to "abs" has type "Time", must be "Amount" or "Integer"`}},
+		},
+		{
+			"DuplicateContract",
+			ivytest.TrivialLock + ivytest.TrivialLock,
+			ErrorList{{8, 0, `contract "TrivialLock" conflicts with contract`}},
+		},
+		{
+			"ConstCollisions",
+			`
+const fee: Amount = 1
+const fee: Integer = 2
+const sha3: String = 'x'
+contract fee() locks value {
+  clause unlock() {
+    unlock value
+  }
+}`,
+			ErrorList{
+				{3, 0, `constant "fee" conflicts with constant`},
+				{4, 0, `constant "sha3" conflicts with built-in function`},
+				{5, 0, `contract "fee" conflicts with constant`},
+			},
+		},
+		{
+			"ParamShadowsConst",
+			`
+const limit: Amount = 100
+contract Limited(limit: Amount) locks value {
+  clause spend(amount: Amount) {
+    verify amount < limit
+    unlock value
+  }
+}`,
+			ErrorList{{3, 0, `contract parameter "limit" conflicts with constant`}},
+		},
+		{
+			"BadConsts",
+			`
+const a: Amount = 0xabcd
+const b: Integer = 1 / 0
+const c: Boolean = 1 < 2
+`,
+			ErrorList{
+				{2, 0, `constant "a" of type Amount cannot have value 0xabcd`},
+				{3, 0, `division by zero`},
+				{4, 0, `value of constant "c" must be a literal`},
+			},
+		},
+		{
+			"ValueNotDisposed",
+			`
+contract Escrow(recipient: Program) locks bond, collateral {
+  clause settle() {
+    lock bond with recipient
+  }
+}`,
+			ErrorList{{3, 2, `value "collateral" not disposed in clause "settle"`}},
+		},
+		{
+			"CallMultiValue",
+			`
+contract Pair(p: Program) locks a, b {
+  clause go() {
+    lock a with p
+    unlock b
+  }
+}
+contract Caller(p: Program) locks value {
+  clause call() {
+    lock value with Pair(p)
+  }
+}`,
+			ErrorList{{10, 20, `contract "Pair" locks more than one value and cannot be called`}},
+		},
+		{
+			"TrailingGarbage",
+			ivytest.TrivialLock + "\nclause extra() {}",
+			ErrorList{{8, 0, `expected const or contract keyword, got "clause"`}},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			_, err := Compile(strings.NewReader(c.contract))
+			if !testutil.DeepEqual(err, c.want) {
+				t.Errorf("got  %v\nwant %v", err, c.want)
+			}
+		})
+	}
+}
+
+func TestArithmeticRuntime(t *testing.T) {
+	const src = `
+contract CappedProduct(factor: Integer, limit: Integer) locks value {
+  clause check(x: Integer) {
+    verify x * factor < limit
+    unlock value
+  }
+}`
+	contracts, err := Compile(strings.NewReader(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := contracts[0]
+	factor, limit := int64(3), int64(100)
+	prog, err := Instantiate(c.Body, c.Params, c.Recursive, []ContractArg{{I: &factor}, {I: &limit}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		x       int64
+		wantErr error
+	}{
+		{10, nil},
+		{40, vm.ErrFalseVMResult},
+		{math.MaxInt64 / 2, vm.ErrFalseVMResult}, // x * factor overflows
+	}
+	for _, tc := range cases {
+		err := vm.Verify(&vm.Context{VMVersion: 1, Code: prog, Arguments: [][]byte{vm.Int64Bytes(tc.x)}})
+		if e, ok := err.(vm.Error); ok {
+			err = e.Err
+		}
+		if err != tc.wantErr {
+			t.Errorf("check(%d) err = %v want %v", tc.x, err, tc.wantErr)
+		}
+	}
+}
+
+func TestMinRuntime(t *testing.T) {
+	const src = `
+contract CappedAmount(cap: Amount) locks value {
+  clause pay(requested, paid: Amount) {
+    verify paid == min(requested, cap)
+    unlock value
+  }
+}`
+	contracts, err := Compile(strings.NewReader(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := contracts[0]
+	capAmount := int64(100)
+	prog, err := Instantiate(c.Body, c.Params, c.Recursive, []ContractArg{{I: &capAmount}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		requested, paid int64
+		wantErr         error
+	}{
+		{50, 50, nil},
+		{150, 100, nil},
+		{150, 150, vm.ErrFalseVMResult},
+	}
+	for _, tc := range cases {
+		args := [][]byte{vm.Int64Bytes(tc.requested), vm.Int64Bytes(tc.paid)}
+		err := vm.Verify(&vm.Context{VMVersion: 1, Code: prog, Arguments: args})
+		if e, ok := err.(vm.Error); ok {
+			err = e.Err
+		}
+		if err != tc.wantErr {
+			t.Errorf("pay(%d, %d) err = %v want %v", tc.requested, tc.paid, err, tc.wantErr)
+		}
+	}
+}
+
+func TestCompileMultiple(t *testing.T) {
+	src := ivytest.TradeOffer + ivytest.EscrowedTransfer
+	got, err := Compile(strings.NewReader(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 {
+		t.Fatalf("got %d contracts, want 2", len(got))
+	}
+	for i, single := range []string{ivytest.TradeOffer, ivytest.EscrowedTransfer} {
+		want, err := Compile(strings.NewReader(single))
+		if err != nil {
+			t.Fatal(err)
+		}
+		gotJSON, _ := json.Marshal(got[i])
+		wantJSON, _ := json.Marshal(want[0])
+		if !bytes.Equal(gotJSON, wantJSON) {
+			t.Errorf("contract %d:\ngot  %s\nwant %s", i, gotJSON, wantJSON)
+		}
+	}
+}
+
+func TestConsts(t *testing.T) {
+	const src = `
+const fee: Amount = 2 * 5
+const feeProgram: Program = 0xae7cac
+
+contract SendFee() locks value {
+  clause send() {
+    lock value with feeProgram
+  }
+}
+contract TakeFee(asset: Asset) locks value {
+  clause take() requires payment: fee of asset {
+    lock payment with feeProgram
+    unlock value
+  }
+}`
+	got, err := Compile(strings.NewReader(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"0 0 AMOUNT ASSET 1 0xae7cac CHECKOUTPUT",
+		"0 0 10 3 ROLL 1 0xae7cac CHECKOUTPUT",
+	}
+	for i, c := range got {
+		if c.Opcodes != want[i] {
+			t.Errorf("%s: got %s want %s", c.Name, c.Opcodes, want[i])
+		}
+	}
+}
+
+func TestCrossContractLock(t *testing.T) {
+	contracts, err := Compile(strings.NewReader(ivytest.HandoffVault))
+	if err != nil {
+		t.Fatal(err)
+	}
+	handoff, vault := contracts[0], contracts[1]
+
+	h := sha3.Sum256([]byte("open sesame"))
+	hash := chainjson.HexBytes(h[:])
+	args := []ContractArg{{S: &hash}}
+	handoffProg, err := Instantiate(handoff.Body, handoff.Params, handoff.Recursive, args)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantVaultProg, err := Instantiate(vault.Body, vault.Params, vault.Recursive, args)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var gotVaultProg []byte
+	amount, assetID := uint64(10), make([]byte, 32)
+	err = vm.Verify(&vm.Context{
+		VMVersion: 1,
+		Code:      handoffProg,
+		Amount:    &amount,
+		AssetID:   &assetID,
+		CheckOutput: func(index uint64, data []byte, amt uint64, asset []byte, vmVersion uint64, code []byte, expansion bool) (bool, error) {
+			gotVaultProg = code
+			return index == 0 && amt == amount && bytes.Equal(asset, assetID), nil
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(gotVaultProg, wantVaultProg) {
+		t.Fatalf("locked with %x, want %x", gotVaultProg, wantVaultProg)
+	}
+
+	cases := []struct {
+		preimage string
+		wantErr  error
+	}{
+		{"open sesame", nil},
+		{"open barley", vm.ErrFalseVMResult},
+	}
+	for _, tc := range cases {
+		err := vm.Verify(&vm.Context{VMVersion: 1, Code: gotVaultProg, Arguments: [][]byte{[]byte(tc.preimage)}})
+		if e, ok := err.(vm.Error); ok {
+			err = e.Err
+		}
+		if err != tc.wantErr {
+			t.Errorf("reveal(%q) err = %v want %v", tc.preimage, err, tc.wantErr)
+		}
+	}
+}
+
+func TestTwoValueEscrow(t *testing.T) {
+	contracts, err := Compile(strings.NewReader(ivytest.TwoValueEscrow))
+	if err != nil {
+		t.Fatal(err)
+	}
+	escrow := contracts[0]
+
+	pub, priv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	agent := chainjson.HexBytes(pub)
+	sender, recipient := chainjson.HexBytes{0x51}, chainjson.HexBytes{0x52}
+	args := []ContractArg{{S: &agent}, {S: &sender}, {S: &recipient}}
+
+	sigHash := make([]byte, 32)
+	sig := ed25519.Sign(priv, sigHash)
+	badSig := ed25519.Sign(priv, []byte("something else"))
+
+	type output struct {
+		index  uint64
+		amount uint64
+		code   string
+	}
+	cases := []struct {
+		clause   int64
+		sig      []byte
+		want     []output // by value
+		wantFail bool
+	}{
+		{0, sig, []output{{0, 10, "51"}, {1, 20, "52"}}, false},
+		{1, sig, []output{{0, 10, "51"}, {1, 20, "51"}}, false},
+		{0, badSig, nil, true},
+	}
+	for _, tc := range cases {
+		for i, lv := range escrow.LockedValues {
+			prog, err := Instantiate(lv.Body, escrow.Params, escrow.Recursive, args)
+			if err != nil {
+				t.Fatal(err)
+			}
+			amount, assetID := uint64(10*(i+1)), make([]byte, 32)
+			var got []output
+			err = vm.Verify(&vm.Context{
+				VMVersion: 1,
+				Code:      prog,
+				Arguments: [][]byte{tc.sig, vm.Int64Bytes(tc.clause)},
+				Amount:    &amount,
+				AssetID:   &assetID,
+				TxSigHash: func() []byte { return sigHash },
+				CheckOutput: func(index uint64, data []byte, amt uint64, asset []byte, vmVersion uint64, code []byte, expansion bool) (bool, error) {
+					got = append(got, output{index, amt, hex.EncodeToString(code)})
+					return true, nil
+				},
+			})
+			if tc.wantFail {
+				if err == nil {
+					t.Errorf("clause %d, value %s: got success, want failure", tc.clause, lv.Name)
+				}
+				continue
+			}
+			if err != nil {
+				t.Fatalf("clause %d, value %s: %s", tc.clause, lv.Name, err)
+			}
+			if len(got) != 1 || got[0] != tc.want[i] {
+				t.Errorf("clause %d, value %s: got outputs %v, want [%v]", tc.clause, lv.Name, got, tc.want[i])
+			}
+		}
+	}
+}
+
+func mapImporter(files map[string]string) Importer {
+	return func(name string) ([]byte, error) {
+		src, ok := files[name]
+		if !ok {
+			return nil, fmt.Errorf("no such file")
+		}
+		return []byte(src), nil
+	}
+}
+
+func TestImports(t *testing.T) {
+	imp := mapImporter(map[string]string{"vault.ivy": ivytest.Vault})
+	const src = `import 'vault.ivy'
+
+contract Handoff(hash: Hash) locks value {
+  clause handoff() {
+    lock value with Vault(hash)
+  }
+}`
+	got, err := CompileWithImports(strings.NewReader(src), imp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := Compile(strings.NewReader(ivytest.HandoffVault))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 {
+		t.Fatalf("got %d contracts, want only Handoff", len(got))
+	}
+	if !bytes.Equal(got[0].Body, want[0].Body) {
+		t.Errorf("got body %x, want %x", got[0].Body, want[0].Body)
+	}
+}
+
+func TestImportErrors(t *testing.T) {
+	files := map[string]string{
+		"a.ivy":   "import 'b.ivy'\n" + ivytest.TrivialLock,
+		"b.ivy":   "import 'a.ivy'\n" + ivytest.Vault,
+		"bad.ivy": "contract Bad() locks value {",
+	}
+	cases := []struct {
+		name string
+		src  string
+		imp  Importer
+		want ErrorList
+	}{
+		{
+			"NoImporter",
+			"import 'vault.ivy'",
+			nil,
+			ErrorList{{1, 0, "cannot import 'vault.ivy': imports are not enabled"}},
+		},
+		{
+			"Missing",
+			"import 'nope.ivy'",
+			mapImporter(files),
+			ErrorList{{1, 0, "cannot import 'nope.ivy': no such file"}},
+		},
+		{
+			"Cycle",
+			"import 'a.ivy'",
+			mapImporter(files),
+			ErrorList{{1, 0, "in 'a.ivy': line 1, col 0: in 'b.ivy': line 1, col 0: import cycle through 'a.ivy'"}},
+		},
+		{
+			"BadFile",
+			"import 'bad.ivy'",
+			mapImporter(files),
+			ErrorList{{1, 0, "in 'bad.ivy': line 1, col 28: expected keyword clause, got end of input"}},
+		},
+		{
+			"CallCycle",
+			`
+contract Ping(p: Program) locks value {
+  clause ping() {
+    lock value with Pong(p)
+  }
+}
+contract Pong(p: Program) locks value {
+  clause pong() {
+    lock value with Ping(p)
+  }
+}`,
+			nil,
+			ErrorList{{2, 0, "contracts call each other in a cycle: Ping -> Pong -> Ping"}},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			_, err := CompileWithImports(strings.NewReader(c.src), c.imp)
+			if !testutil.DeepEqual(err, c.want) {
+				t.Errorf("got  %v\nwant %v", err, c.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,786 @@
+package compiler
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"chain/errors"
+	"chain/protocol/vm"
+)
+
+// Decompile produces a skeletal, Ivy-like rendering of prog, a
+// contract program as produced by Instantiate. It recovers the
+// clauses, how many arguments each takes, and the statements in
+// them, as far as they follow the compiler's usual patterns. The
+// names of parameters, clauses and values are placeholders. Code it
+// doesn't recognize appears as disassembly in comments.
+func Decompile(prog []byte) (string, error) {
+	insts, err := vm.ParseProgram(prog)
+	if err != nil {
+		return "", errors.Wrap(err, "parsing program")
+	}
+
+	args, body, recursive, ok := parseInstantiation(insts)
+	if !ok {
+		dis, _ := vm.Disassemble(prog)
+		return fmt.Sprintf("// not an Ivy contract program\n// %s\n", dis), nil
+	}
+	d, err := newDecompiler(body, len(args), recursive)
+	if err != nil {
+		return "", errors.Wrap(err, "parsing contract body")
+	}
+
+	buf := new(bytes.Buffer)
+	var params []string
+	for i := range args {
+		// The last argument is pushed first.
+		arg := args[len(args)-1-i]
+		params = append(params, fmt.Sprintf("param%d", i))
+		if arg.isNum && arg.expr != arg.number() {
+			fmt.Fprintf(buf, "// param%d = %s (%s)\n", i, arg.expr, arg.number())
+		} else {
+			fmt.Fprintf(buf, "// param%d = %s\n", i, arg.expr)
+		}
+	}
+	fmt.Fprintf(buf, "contract Contract(%s) locks value {\n", strings.Join(params, ", "))
+	for i, r := range d.clauseRanges() {
+		d.writeClause(buf, i, r[0], r[1])
+	}
+	fmt.Fprintf(buf, "}\n")
+	return buf.String(), nil
+}
+
+// parseInstantiation recognizes the program that Instantiate builds
+// around a contract body and its arguments.
+func parseInstantiation(insts []vm.Instruction) (args []dItem, body []byte, recursive, ok bool) {
+	n := len(insts)
+	if n < 4 || insts[n-1].Op != vm.OP_CHECKPREDICATE || insts[n-2].Op != vm.OP_0 {
+		return nil, nil, false, false
+	}
+	var argInsts []vm.Instruction
+	switch {
+	case insts[n-3].Op == vm.OP_OVER && n >= 5 && insts[n-4].Op == vm.OP_DEPTH && isPush(insts[n-5]):
+		// <argN> ... <arg1> <body> DEPTH OVER 0 CHECKPREDICATE
+		body, recursive, argInsts = insts[n-5].Data, true, insts[:n-5]
+	case isPush(insts[n-3]) && insts[n-4].Op == vm.OP_DEPTH:
+		// <argN> ... <arg1> DEPTH <body> 0 CHECKPREDICATE
+		body, argInsts = insts[n-3].Data, insts[:n-4]
+	default:
+		return nil, nil, false, false
+	}
+	for _, inst := range argInsts {
+		if !isPush(inst) {
+			return nil, nil, false, false
+		}
+		args = append(args, pushItem(inst))
+	}
+	return args, body, recursive, true
+}
+
+func isPush(inst vm.Instruction) bool {
+	return inst.Op <= vm.OP_PUSHDATA4 || (inst.Op >= vm.OP_1 && inst.Op <= vm.OP_16)
+}
+
+// dItem is a decompiled stack item.
+type dItem struct {
+	expr string
+
+	// For an integer pushed onto the stack.
+	num   int64
+	isNum bool
+
+	// For the result of a binary operator, the operator.
+	op string
+
+	// For the result of NOT, the negated expression.
+	negated string
+
+	// For the result of CHECKOUTPUT, the lock statement.
+	lock string
+
+	// For a string built with CAT and CATPUSHDATA from an empty
+	// one, the pieces.
+	parts []dPart
+}
+
+type dPart struct {
+	item dItem
+	push bool // added with CATPUSHDATA rather than CAT
+}
+
+// Placeholders for values the VM gets from the transaction.
+const (
+	dAmount    = "<amount>"
+	dAsset     = "<asset>"
+	dTxSigHash = "<txsighash>"
+	dMinTime   = "<mintime>"
+	dMaxTime   = "<maxtime>"
+)
+
+func pushItem(inst vm.Instruction) dItem {
+	if inst.Op == vm.OP_0 || (inst.Op >= vm.OP_1 && inst.Op <= vm.OP_16) {
+		n, _ := vm.AsInt64(inst.Data)
+		return dItem{expr: strconv.FormatInt(n, 10), num: n, isNum: true}
+	}
+	item := dItem{expr: "0x" + hex.EncodeToString(inst.Data)}
+	if n, err := vm.AsInt64(inst.Data); err == nil {
+		item.num, item.isNum = n, true
+	}
+	return item
+}
+
+// operand is its expression as the operand of a binary operator.
+func (it dItem) operand() string {
+	if it.op != "" {
+		return "(" + it.expr + ")"
+	}
+	return it.expr
+}
+
+// number is its expression, in decimal if it's a number.
+func (it dItem) number() string {
+	if it.isNum {
+		return strconv.FormatInt(it.num, 10)
+	}
+	return it.operand()
+}
+
+// arg is its expression as the argument of a function, in decimal
+// if it's a number.
+func (it dItem) arg() string {
+	if it.isNum {
+		return strconv.FormatInt(it.num, 10)
+	}
+	return it.expr
+}
+
+// numericOps are the operators whose operands are numbers.
+var numericOps = map[string]bool{
+	"+": true, "-": true, "*": true, "/": true, "%": true, "<<": true, ">>": true,
+	"<": true, ">": true, "<=": true, ">=": true,
+}
+
+// cat is the result of appending it to the accumulated string acc.
+// When that completes a program like the ones Instantiate builds,
+// it's rendered as a contract call.
+func cat(acc, it dItem, push bool, fn string) dItem {
+	result := dItem{expr: fmt.Sprintf("%s(%s, %s)", fn, it.expr, acc.expr)}
+	if len(acc.parts) == 0 && !(acc.isNum && acc.num == 0) {
+		return result
+	}
+	result.parts = append(append([]dPart(nil), acc.parts...), dPart{it, push})
+	if call, ok := contractCall(result.parts); ok {
+		return dItem{expr: call}
+	}
+	return result
+}
+
+// contractCall recognizes
+//
+//	<argN> ... <arg1> DEPTH <body> 0 CHECKPREDICATE
+//	<argN> ... <arg1> <body> DEPTH OVER 0 CHECKPREDICATE
+//
+// built up in parts, and renders it as a call.
+func contractCall(parts []dPart) (string, bool) {
+	raw := func(p dPart, code ...vm.Op) bool {
+		if p.push || !strings.HasPrefix(p.item.expr, "0x") {
+			return false
+		}
+		b, err := hex.DecodeString(p.item.expr[2:])
+		if err != nil || len(b) != len(code) {
+			return false
+		}
+		for i, op := range code {
+			if b[i] != byte(op) {
+				return false
+			}
+		}
+		return true
+	}
+	n := len(parts)
+	if n < 4 || !raw(parts[n-1], vm.OP_CHECKPREDICATE) || !parts[n-2].push || parts[n-2].item.expr != "0" {
+		return "", false
+	}
+	var body dPart
+	var args []dPart
+	switch {
+	case raw(parts[n-3], vm.OP_DEPTH, vm.OP_OVER) && parts[n-4].push:
+		body, args = parts[n-4], parts[:n-4]
+	case parts[n-3].push && raw(parts[n-4], vm.OP_DEPTH):
+		body, args = parts[n-3], parts[:n-4]
+	default:
+		return "", false
+	}
+	var exprs []string
+	for i := len(args) - 1; i >= 0; i-- {
+		if !args[i].push {
+			return "", false
+		}
+		exprs = append(exprs, args[i].item.expr)
+	}
+	callee := body.item.expr
+	if callee != "Contract" {
+		callee = fmt.Sprintf("contract(%s)", callee)
+	}
+	return fmt.Sprintf("%s(%s)", callee, strings.Join(exprs, ", ")), true
+}
+
+type dInst struct {
+	vm.Instruction
+	pc uint32
+}
+
+func (inst dInst) jumpTarget() uint32 {
+	return binary.LittleEndian.Uint32(inst.Data)
+}
+
+type decompiler struct {
+	body      []byte
+	insts     []dInst
+	index     map[uint32]int // pc -> index in insts
+	nparams   int
+	recursive bool
+}
+
+func newDecompiler(body []byte, nparams int, recursive bool) (*decompiler, error) {
+	d := &decompiler{
+		body:      body,
+		index:     make(map[uint32]int),
+		nparams:   nparams,
+		recursive: recursive,
+	}
+	for pc := uint32(0); pc < uint32(len(body)); {
+		inst, err := vm.ParseOp(body, pc)
+		if err != nil {
+			return nil, err
+		}
+		d.index[pc] = len(d.insts)
+		d.insts = append(d.insts, dInst{inst, pc})
+		pc += inst.Len
+	}
+	d.index[uint32(len(body))] = len(d.insts)
+	return d, nil
+}
+
+// clauseRanges finds the clause selection at the start of the body,
+// and returns the start and end of each clause's code.
+func (d *decompiler) clauseRanges() [][2]uint32 {
+	single := [][2]uint32{{0, uint32(len(d.body))}}
+	insts := d.insts
+
+	// The clause selector is rolled up from below the contract
+	// arguments.
+	if d.nparams > 0 {
+		n := d.nparams
+		if d.recursive {
+			n++
+		}
+		switch {
+		case n == 1 && len(insts) > 0 && insts[0].Op == vm.OP_SWAP:
+			insts = insts[1:]
+		case n == 2 && len(insts) > 0 && insts[0].Op == vm.OP_ROT:
+			insts = insts[1:]
+		case len(insts) > 1 && isPush(insts[0].Instruction) && pushItem(insts[0].Instruction).num == int64(n) && insts[1].Op == vm.OP_ROLL:
+			insts = insts[2:]
+		default:
+			return single
+		}
+	}
+
+	// DUP <i> NUMEQUAL JUMPIF:$clause_i, for the clauses after the
+	// second, from last to first, then JUMPIF:$clause_1.
+	var targets []uint32
+	for len(insts) >= 4 && insts[0].Op == vm.OP_DUP {
+		if !isPush(insts[1].Instruction) || insts[2].Op != vm.OP_NUMEQUAL || insts[3].Op != vm.OP_JUMPIF {
+			return single
+		}
+		targets = append([]uint32{insts[3].jumpTarget()}, targets...)
+		insts = insts[4:]
+	}
+	if len(insts) < 2 || insts[0].Op != vm.OP_JUMPIF {
+		return single
+	}
+	targets = append([]uint32{insts[0].jumpTarget()}, targets...)
+	starts := append([]uint32{insts[1].pc}, targets...)
+
+	// Every clause but the last ends with JUMP:$_end.
+	var result [][2]uint32
+	for i, start := range starts {
+		end := uint32(len(d.body))
+		if i < len(starts)-1 {
+			j, ok := d.index[starts[i+1]]
+			if !ok || j == 0 {
+				return single
+			}
+			jump := d.insts[j-1]
+			if jump.Op != vm.OP_JUMP || jump.jumpTarget() != uint32(len(d.body)) || jump.pc < start {
+				return single
+			}
+			end = jump.pc
+		}
+		if i >= 2 {
+			// The later clauses still have the selector on the stack.
+			j, ok := d.index[start]
+			if !ok || d.insts[j].Op != vm.OP_DROP {
+				return single
+			}
+			start += d.insts[j].Len
+		}
+		result = append(result, [2]uint32{start, end})
+	}
+	return result
+}
+
+// dClause is the state of decompiling a single clause.
+type dClause struct {
+	d *decompiler
+
+	// argName names the ith item below the contract arguments on
+	// the stack, i.e., a clause argument. maxPulled is how many such
+	// items the clause refers to.
+	argName   func(i int) string
+	maxPulled int
+
+	reqs        []string
+	lockedValue bool
+}
+
+type dStack struct {
+	items  []dItem
+	alt    []dItem
+	pulled int
+}
+
+func (s *dStack) copy() *dStack {
+	return &dStack{
+		items:  append([]dItem(nil), s.items...),
+		alt:    append([]dItem(nil), s.alt...),
+		pulled: s.pulled,
+	}
+}
+
+// need makes sure the stack has at least n items, taking clause
+// arguments from below it as necessary.
+func (c *dClause) need(s *dStack, n int) {
+	missing := n - len(s.items)
+	if missing <= 0 {
+		return
+	}
+	pulled := make([]dItem, missing)
+	for i := missing - 1; i >= 0; i-- {
+		pulled[i] = dItem{expr: c.argName(s.pulled)}
+		s.pulled++
+	}
+	s.items = append(pulled, s.items...)
+	if s.pulled > c.maxPulled {
+		c.maxPulled = s.pulled
+	}
+}
+
+// count returns it as a number of stack items for inst to use.
+// A count past the length of the body can't refer to anything the
+// compiler put there, and would make the stack grow without bound.
+func (c *dClause) count(it dItem, inst dInst) (int, error) {
+	if !it.isNum || it.num < 0 || it.num > int64(len(c.d.body)) {
+		return 0, fmt.Errorf("%s of %s at pc %d", inst.Op, it.expr, inst.pc)
+	}
+	return int(it.num), nil
+}
+
+func (c *dClause) pop(s *dStack) dItem {
+	c.need(s, 1)
+	it := s.items[len(s.items)-1]
+	s.items = s.items[:len(s.items)-1]
+	return it
+}
+
+// popN pops n items and returns them from deepest to top.
+func (c *dClause) popN(s *dStack, n int) []dItem {
+	c.need(s, n)
+	items := append([]dItem(nil), s.items[len(s.items)-n:]...)
+	s.items = s.items[:len(s.items)-n]
+	return items
+}
+
+func (c *dClause) pushAll(s *dStack, items ...dItem) {
+	s.items = append(s.items, items...)
+}
+
+func (d *decompiler) initialStack() *dStack {
+	s := new(dStack)
+	for i := d.nparams - 1; i >= 0; i-- {
+		s.items = append(s.items, dItem{expr: fmt.Sprintf("param%d", i)})
+	}
+	if d.recursive {
+		s.items = append(s.items, dItem{expr: "Contract"})
+	}
+	return s
+}
+
+func (d *decompiler) writeClause(buf *bytes.Buffer, i int, start, end uint32) {
+	// The first pass finds how many clause arguments there are, the
+	// second names them.
+	c := &dClause{d: d, argName: func(int) string { return "?" }}
+	_, err := c.statements(d.initialStack(), start, end)
+	var stmts []string
+	if err == nil {
+		nargs := c.maxPulled
+		c = &dClause{d: d, argName: func(j int) string { return fmt.Sprintf("arg%d", nargs-1-j) }}
+		stmts, err = c.statements(d.initialStack(), start, end)
+	}
+	if err != nil {
+		dis, _ := vm.Disassemble(d.body[start:end])
+		fmt.Fprintf(buf, "  clause clause%d(?) {\n", i)
+		fmt.Fprintf(buf, "    // cannot decompile: %s\n", err)
+		fmt.Fprintf(buf, "    // %s\n", dis)
+		fmt.Fprintf(buf, "  }\n")
+		return
+	}
+
+	var args []string
+	for j := 0; j < c.maxPulled; j++ {
+		args = append(args, fmt.Sprintf("arg%d", j))
+	}
+	fmt.Fprintf(buf, "  clause clause%d(%s)", i, strings.Join(args, ", "))
+	if len(c.reqs) > 0 {
+		fmt.Fprintf(buf, " requires %s", strings.Join(c.reqs, ", "))
+	}
+	fmt.Fprintf(buf, " {\n")
+	for _, stmt := range stmts {
+		fmt.Fprintf(buf, "    %s\n", stmt)
+	}
+	fmt.Fprintf(buf, "  }\n")
+}
+
+// statements decompiles a whole clause.
+func (c *dClause) statements(s *dStack, start, end uint32) ([]string, error) {
+	stmts, err := c.region(s, start, end)
+	if err != nil {
+		return nil, err
+	}
+	// The clause's last statement leaves its result on the stack.
+	switch top := c.pop(s); {
+	case top.lock != "":
+		stmts = append(stmts, top.lock)
+	case top.isNum && top.num == 1:
+		// nothing more
+	default:
+		stmts = append(stmts, "verify "+top.expr)
+	}
+	if !c.lockedValue {
+		stmts = append(stmts, "unlock value")
+	}
+	return stmts, nil
+}
+
+var dBinaryOps = map[vm.Op]string{
+	vm.OP_EQUAL:              "==",
+	vm.OP_NUMEQUAL:           "==",
+	vm.OP_NUMNOTEQUAL:        "!=",
+	vm.OP_ADD:                "+",
+	vm.OP_SUB:                "-",
+	vm.OP_MUL:                "*",
+	vm.OP_DIV:                "/",
+	vm.OP_MOD:                "%",
+	vm.OP_LSHIFT:             "<<",
+	vm.OP_RSHIFT:             ">>",
+	vm.OP_AND:                "&",
+	vm.OP_OR:                 "|",
+	vm.OP_XOR:                "^",
+	vm.OP_LESSTHAN:           "<",
+	vm.OP_GREATERTHAN:        ">",
+	vm.OP_LESSTHANOREQUAL:    "<=",
+	vm.OP_GREATERTHANOREQUAL: ">=",
+	vm.OP_BOOLAND:            "&&",
+	vm.OP_BOOLOR:             "||",
+}
+
+// Builtins taking two arguments. The compiler pushes arguments last
+// to first, so the top of the stack is the first argument.
+var dBinaryFuncs = map[vm.Op]string{
+	vm.OP_MIN:         "min",
+	vm.OP_MAX:         "max",
+	vm.OP_CAT:         "concat",
+	vm.OP_CATPUSHDATA: "concatpush",
+}
+
+var dUnaryFuncs = map[vm.Op]string{
+	vm.OP_SHA3:   "sha3",
+	vm.OP_SHA256: "sha256",
+	vm.OP_ABS:    "abs",
+}
+
+var dUnaryOps = map[vm.Op]string{
+	vm.OP_NEGATE: "-",
+	vm.OP_INVERT: "~",
+}
+
+// Stack rearrangements, as the positions (counting from the top)
+// of the items to push after popping a given number.
+var dStackOps = map[vm.Op]struct {
+	pop  int
+	push []int
+}{
+	vm.OP_DUP:   {1, []int{0, 0}},
+	vm.OP_DROP:  {1, nil},
+	vm.OP_SWAP:  {2, []int{0, 1}},
+	vm.OP_OVER:  {2, []int{1, 0, 1}},
+	vm.OP_ROT:   {3, []int{1, 0, 2}},
+	vm.OP_NIP:   {2, []int{0}},
+	vm.OP_TUCK:  {2, []int{0, 1, 0}},
+	vm.OP_2DUP:  {2, []int{1, 0, 1, 0}},
+	vm.OP_3DUP:  {3, []int{2, 1, 0, 2, 1, 0}},
+	vm.OP_2DROP: {2, nil},
+	vm.OP_2OVER: {4, []int{3, 2, 1, 0, 3, 2}},
+	vm.OP_2ROT:  {6, []int{3, 2, 1, 0, 5, 4}},
+	vm.OP_2SWAP: {4, []int{1, 0, 3, 2}},
+}
+
+var dContextOps = map[vm.Op]string{
+	vm.OP_AMOUNT:    dAmount,
+	vm.OP_ASSET:     dAsset,
+	vm.OP_TXSIGHASH: dTxSigHash,
+	vm.OP_MINTIME:   dMinTime,
+	vm.OP_MAXTIME:   dMaxTime,
+}
+
+// region decompiles the code from start to end, leaving its effect
+// on s.
+func (c *dClause) region(s *dStack, start, end uint32) ([]string, error) {
+	var stmts []string
+	i, ok := c.d.index[start]
+	if !ok {
+		return nil, fmt.Errorf("no instruction at pc %d", start)
+	}
+	for i < len(c.d.insts) && c.d.insts[i].pc < end {
+		inst := c.d.insts[i]
+		i++
+
+		if isPush(inst.Instruction) {
+			c.pushAll(s, pushItem(inst.Instruction))
+			continue
+		}
+		if op, ok := dBinaryOps[inst.Op]; ok {
+			items := c.popN(s, 2)
+			l, r := items[0], items[1]
+			switch {
+			case op == "<" && r.expr == dMinTime:
+				c.pushAll(s, dItem{expr: fmt.Sprintf("after(%s)", l.arg())})
+			case op == ">" && r.expr == dMaxTime:
+				c.pushAll(s, dItem{expr: fmt.Sprintf("before(%s)", l.arg())})
+			case numericOps[op]:
+				c.pushAll(s, dItem{expr: fmt.Sprintf("%s %s %s", l.number(), op, r.number()), op: op})
+			default:
+				c.pushAll(s, dItem{expr: fmt.Sprintf("%s %s %s", l.operand(), op, r.operand()), op: op})
+			}
+			continue
+		}
+		if fn, ok := dBinaryFuncs[inst.Op]; ok {
+			items := c.popN(s, 2)
+			switch inst.Op {
+			case vm.OP_CAT, vm.OP_CATPUSHDATA:
+				c.pushAll(s, cat(items[0], items[1], inst.Op == vm.OP_CATPUSHDATA, fn))
+			default:
+				c.pushAll(s, dItem{expr: fmt.Sprintf("%s(%s, %s)", fn, items[1].expr, items[0].expr)})
+			}
+			continue
+		}
+		if fn, ok := dUnaryFuncs[inst.Op]; ok {
+			c.pushAll(s, dItem{expr: fmt.Sprintf("%s(%s)", fn, c.pop(s).expr)})
+			continue
+		}
+		if op, ok := dUnaryOps[inst.Op]; ok {
+			c.pushAll(s, dItem{expr: op + c.pop(s).operand()})
+			continue
+		}
+		if stackOp, ok := dStackOps[inst.Op]; ok {
+			items := c.popN(s, stackOp.pop)
+			for _, pos := range stackOp.push {
+				c.pushAll(s, items[len(items)-1-pos])
+			}
+			continue
+		}
+		if placeholder, ok := dContextOps[inst.Op]; ok {
+			c.pushAll(s, dItem{expr: placeholder})
+			continue
+		}
+
+		switch inst.Op {
+		case vm.OP_PICK, vm.OP_ROLL:
+			n, err := c.count(c.pop(s), inst)
+			if err != nil {
+				return nil, err
+			}
+			c.need(s, n+1)
+			k := len(s.items) - 1 - n
+			item := s.items[k]
+			if inst.Op == vm.OP_ROLL {
+				s.items = append(s.items[:k], s.items[k+1:]...)
+			}
+			c.pushAll(s, item)
+
+		case vm.OP_TOALTSTACK:
+			s.alt = append(s.alt, c.pop(s))
+
+		case vm.OP_FROMALTSTACK:
+			if len(s.alt) == 0 {
+				return nil, fmt.Errorf("empty alt stack at pc %d", inst.pc)
+			}
+			c.pushAll(s, s.alt[len(s.alt)-1])
+			s.alt = s.alt[:len(s.alt)-1]
+
+		case vm.OP_SIZE:
+			c.need(s, 1)
+			c.pushAll(s, dItem{expr: fmt.Sprintf("size(%s)", s.items[len(s.items)-1].expr)})
+
+		case vm.OP_NOT:
+			x := c.pop(s)
+			if x.op == "==" {
+				c.pushAll(s, dItem{expr: strings.Replace(x.expr, " == ", " != ", 1), op: "!=", negated: x.expr})
+			} else {
+				c.pushAll(s, dItem{expr: "!" + x.operand(), negated: x.expr})
+			}
+
+		case vm.OP_VERIFY:
+			if x := c.pop(s); x.lock != "" {
+				stmts = append(stmts, x.lock)
+			} else {
+				stmts = append(stmts, "verify "+x.expr)
+			}
+
+		case vm.OP_EQUALVERIFY, vm.OP_NUMEQUALVERIFY:
+			items := c.popN(s, 2)
+			stmts = append(stmts, fmt.Sprintf("verify %s == %s", items[0].operand(), items[1].operand()))
+
+		case vm.OP_CHECKSIG:
+			// sig msg pubkey
+			items := c.popN(s, 3)
+			if items[1].expr == dTxSigHash {
+				c.pushAll(s, dItem{expr: fmt.Sprintf("checkTxSig(%s, %s)", items[2].expr, items[0].expr)})
+			} else {
+				c.pushAll(s, dItem{expr: fmt.Sprintf("checkSig(%s, %s, %s)", items[2].expr, items[1].expr, items[0].expr)})
+			}
+
+		case vm.OP_CHECKMULTISIG:
+			// sig... msg pubkey... nsigs npubkeys
+			counts := c.popN(s, 2)
+			nsigs, err := c.count(counts[0], inst)
+			if err != nil {
+				return nil, err
+			}
+			npubkeys, err := c.count(counts[1], inst)
+			if err != nil {
+				return nil, err
+			}
+			pubkeys := c.popN(s, npubkeys)
+			msg := c.pop(s)
+			sigs := c.popN(s, nsigs)
+			if msg.expr != dTxSigHash {
+				return nil, fmt.Errorf("CHECKMULTISIG of %s at pc %d", msg.expr, inst.pc)
+			}
+			c.pushAll(s, dItem{expr: fmt.Sprintf("checkTxMultiSig(%s, %s)", exprList(pubkeys), exprList(sigs))})
+
+		case vm.OP_CHECKOUTPUT:
+			// index refdatahash amount asset version program
+			items := c.popN(s, 6)
+			amount, asset, prog := items[2], items[3], items[5]
+			var lock string
+			if amount.expr == dAmount && asset.expr == dAsset {
+				c.lockedValue = true
+				lock = fmt.Sprintf("lock value with %s", prog.expr)
+			} else {
+				name := fmt.Sprintf("payment%d", len(c.reqs))
+				c.reqs = append(c.reqs, fmt.Sprintf("%s: %s of %s", name, amount.expr, asset.expr))
+				lock = fmt.Sprintf("lock %s with %s", name, prog.expr)
+			}
+			c.pushAll(s, dItem{expr: "checkOutput()", lock: lock})
+
+		case vm.OP_JUMPIF:
+			ifStmts, next, err := c.ifStatement(s, inst, end)
+			if err != nil {
+				return nil, err
+			}
+			stmts = append(stmts, ifStmts...)
+			i = c.d.index[next]
+
+		default:
+			return nil, fmt.Errorf("unexpected %s at pc %d", inst.Op, inst.pc)
+		}
+	}
+	return stmts, nil
+}
+
+// ifStatement decompiles one of
+//
+//	<cond> JUMPIF:$if <else body> JUMP:$endif $if <body> $endif
+//	<cond> NOT JUMPIF:$endif <body> $endif
+//
+// starting from the JUMPIF, and returns where the code after it
+// begins.
+func (c *dClause) ifStatement(s *dStack, jumpIf dInst, end uint32) ([]string, uint32, error) {
+	cond := c.pop(s)
+	target := jumpIf.jumpTarget()
+	j, ok := c.d.index[target]
+	if !ok || target <= jumpIf.pc || target > end {
+		return nil, 0, fmt.Errorf("unexpected JUMPIF at pc %d", jumpIf.pc)
+	}
+	bodyStart := jumpIf.pc + jumpIf.Len
+
+	// Each branch leaves the stack as it found it.
+	var lines []string
+	if jump := c.d.insts[j-1]; jump.Op == vm.OP_JUMP && jump.pc >= bodyStart {
+		endif := jump.jumpTarget()
+		if _, ok := c.d.index[endif]; !ok || endif <= target || endif > end {
+			return nil, 0, fmt.Errorf("unexpected JUMP at pc %d", jump.pc)
+		}
+		elseStmts, err := c.region(s.copy(), bodyStart, jump.pc)
+		if err != nil {
+			return nil, 0, err
+		}
+		bodyStmts, err := c.region(s.copy(), target, endif)
+		if err != nil {
+			return nil, 0, err
+		}
+		lines = append(lines, fmt.Sprintf("if %s {", cond.expr))
+		lines = append(lines, indent(bodyStmts)...)
+		lines = append(lines, "} else {")
+		lines = append(lines, indent(elseStmts)...)
+		lines = append(lines, "}")
+		return lines, endif, nil
+	}
+
+	bodyStmts, err := c.region(s.copy(), bodyStart, target)
+	if err != nil {
+		return nil, 0, err
+	}
+	if cond.negated != "" {
+		lines = append(lines, fmt.Sprintf("if %s {", cond.negated))
+	} else {
+		lines = append(lines, fmt.Sprintf("if !%s {", cond.operand()))
+	}
+	lines = append(lines, indent(bodyStmts)...)
+	lines = append(lines, "}")
+	return lines, target, nil
+}
+
+func indent(lines []string) []string {
+	var result []string
+	for _, l := range lines {
+		result = append(result, "  "+l)
+	}
+	return result
+}
+
+// exprList renders items, which the compiler pushed last to first,
+// as an Ivy list.
+func exprList(items []dItem) string {
+	var exprs []string
+	for i := len(items) - 1; i >= 0; i-- {
+		exprs = append(exprs, items[i].expr)
+	}
+	return "[" + strings.Join(exprs, ", ") + "]"
+}
@@ -0,0 +1,328 @@
+package compiler
+
+import (
+	"encoding/hex"
+	"math/rand"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	chainjson "chain/encoding/json"
+	"chain/exp/ivy/compiler/ivytest"
+	"chain/protocol/vm"
+	"chain/protocol/vm/vmutil"
+)
+
+func TestDecompile(t *testing.T) {
+	cases := []struct {
+		name     string
+		contract string
+		want     string
+	}{
+		{
+			"TradeOffer",
+			ivytest.TradeOffer,
+			`// param0 = 0x00ab (43776)
+// param1 = 0x65 (101)
+// param2 = 0x02ab (43778)
+// param3 = 0x03ab (43779)
+contract Contract(param0, param1, param2, param3) locks value {
+  clause clause0() requires payment0: param1 of param0 {
+    lock payment0 with param2
+    unlock value
+  }
+  clause clause1(arg0) {
+    verify checkTxSig(param3, arg0)
+    lock value with param2
+  }
+}
+`,
+		},
+		{
+			"LockWith2of3Keys",
+			ivytest.LockWith2of3Keys,
+			`// param0 = 0x00ab (43776)
+// param1 = 0x01ab (43777)
+// param2 = 0x02ab (43778)
+contract Contract(param0, param1, param2) locks value {
+  clause clause0(arg0, arg1) {
+    verify checkTxMultiSig([param0, param1, param2], [arg0, arg1])
+    unlock value
+  }
+}
+`,
+		},
+		{
+			"DeadlineTransfer",
+			ivytest.DeadlineTransfer,
+			`// param0 = 0x00ab (43776)
+// param1 = 0x01ab (43777)
+// param2 = 0x66 (102)
+contract Contract(param0, param1, param2) locks value {
+  clause clause0() {
+    if before(param2) {
+      lock value with param0
+    } else {
+      lock value with param1
+    }
+  }
+}
+`,
+		},
+		{
+			"PriceChanger",
+			ivytest.PriceChanger,
+			`// param0 = 0x64 (100)
+// param1 = 0x01ab (43777)
+// param2 = 0x02ab (43778)
+// param3 = 0x03ab (43779)
+contract Contract(param0, param1, param2, param3) locks value {
+  clause clause0(arg0, arg1, arg2) {
+    verify checkTxSig(param2, arg2)
+    lock value with Contract(arg0, arg1, param2, param3)
+  }
+  clause clause1() requires payment0: param0 of param1 {
+    lock payment0 with param3
+    unlock value
+  }
+}
+`,
+		},
+		{
+			"DoublePrice",
+			ivytest.DoublePrice,
+			`// param0 = 0x00ab (43776)
+// param1 = 0x65 (101)
+// param2 = 0x02ab (43778)
+// param3 = 0x67 (103)
+contract Contract(param0, param1, param2, param3) locks value {
+  clause clause0() requires payment0: param1 * 2 of param0 {
+    verify before(param3 + 86400000)
+    lock payment0 with param2
+    unlock value
+  }
+}
+`,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got, err := Decompile(instantiateForTest(t, c.contract))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != c.want {
+				t.Errorf("got:\n%s\nwant:\n%s", got, c.want)
+			}
+		})
+	}
+}
+
+// TestDecompileStructure checks that every fixture decompiles with
+// the right clauses, clause arguments and builtins.
+func TestDecompileStructure(t *testing.T) {
+	srcs := []string{
+		ivytest.TrivialLock,
+		ivytest.LockWithPublicKey,
+		ivytest.LockWithPKHash,
+		ivytest.LockWith2of3Keys,
+		ivytest.LockToOutput,
+		ivytest.TradeOffer,
+		ivytest.EscrowedTransfer,
+		ivytest.CollateralizedLoan,
+		ivytest.RevealPreimage,
+		ivytest.CallOptionWithSettlement,
+		ivytest.PriceChanger,
+		ivytest.OneTwo,
+		ivytest.DeadlineTransfer,
+		ivytest.SignAfterDeadline,
+		ivytest.DoublePrice,
+		ivytest.CappedPayment,
+	}
+	clauseRE := regexp.MustCompile(`(?m)^  clause clause\d+\(([^)]*)\)`)
+	for _, src := range srcs {
+		contracts, err := Compile(strings.NewReader(src))
+		if err != nil {
+			t.Fatal(err)
+		}
+		contract := contracts[len(contracts)-1]
+		got, err := Decompile(instantiate(t, contract))
+		if err != nil {
+			t.Fatalf("%s: %s", contract.Name, err)
+		}
+		if strings.Contains(got, "cannot decompile") {
+			t.Errorf("%s: not fully decompiled:\n%s", contract.Name, got)
+		}
+		clauses := clauseRE.FindAllStringSubmatch(got, -1)
+		if len(clauses) != len(contract.Clauses) {
+			t.Errorf("%s: got %d clauses, want %d:\n%s", contract.Name, len(clauses), len(contract.Clauses), got)
+			continue
+		}
+		bodies := clauseRE.Split(got, -1)[1:]
+		for i, m := range clauses {
+			clause := contract.Clauses[i]
+			var nargs int
+			if m[1] != "" {
+				nargs = len(strings.Split(m[1], ", "))
+			}
+			if want := len(clause.Params); nargs != want {
+				t.Errorf("%s: clause %d has %d args, want %d", contract.Name, i, nargs, want)
+			}
+			want := builtinsUsed(clause)
+			for _, b := range builtins {
+				used := regexp.MustCompile(`\b` + b.name + `\(`).MatchString(bodies[i])
+				if used != want[b.name] {
+					t.Errorf("%s: clause %d uses %s: got %v, want %v", contract.Name, i, b.name, used, want[b.name])
+				}
+			}
+		}
+	}
+}
+
+func TestDecompileNonContract(t *testing.T) {
+	got, err := Decompile([]byte{0x51, 0x52, 0x93})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "// not an Ivy contract program\n// 0x01 0x02 ADD\n"
+	if got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestDecompileCrafted(t *testing.T) {
+	progs := []string{
+		// A clause that picks from far below the stack. It used to
+		// make Decompile grow the stack until it ran out of memory.
+		"0201ab0201ab0201ab553d557a6433000000557a5479ae7cac690000c3c251005a7a89597a07597a89597a89567a890274787e008901c07ec1633d0000000000537a547a51577ac1747800c0",
+		// A body holding only a JUMPIF.
+		"7405641c00000000c0",
+		// A CHECKMULTISIG of a huge number of pubkeys.
+		"5511a3086f52747cc0641c00000075ad7e7e60747800c0",
+	}
+	for _, s := range progs {
+		prog, err := hex.DecodeString(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		decompileWithin(t, prog, 5*time.Second)
+	}
+}
+
+func TestDecompileRandom(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		// Random bytes are almost never a contract instantiation,
+		// so wrap a random body in one.
+		body := make([]byte, r.Intn(200))
+		r.Read(body)
+		b := vmutil.NewBuilder()
+		for i := r.Intn(3); i > 0; i-- {
+			b.AddInt64(r.Int63n(20))
+		}
+		if r.Intn(2) == 0 {
+			b.AddOp(vm.OP_DEPTH).AddData(body)
+		} else {
+			b.AddData(body).AddOp(vm.OP_DEPTH).AddOp(vm.OP_OVER)
+		}
+		b.AddOp(vm.OP_0).AddOp(vm.OP_CHECKPREDICATE)
+		prog, err := b.Build()
+		if err != nil {
+			t.Fatal(err)
+		}
+		decompileWithin(t, prog, time.Second)
+	}
+}
+
+// decompileWithin fails t if Decompile panics or takes
+// longer than d on prog.
+func decompileWithin(t *testing.T, prog []byte, d time.Duration) {
+	done := make(chan interface{}, 1)
+	go func() {
+		defer func() { done <- recover() }()
+		Decompile(prog)
+	}()
+	select {
+	case p := <-done:
+		if p != nil {
+			t.Fatalf("Decompile(%x) panicked: %v", prog, p)
+		}
+	case <-time.After(d):
+		t.Fatalf("Decompile(%x) took longer than %s", prog, d)
+	}
+}
+
+// builtinsUsed returns the names of the builtins that clause calls.
+func builtinsUsed(clause *Clause) map[string]bool {
+	result := make(map[string]bool)
+	var walk func(expression)
+	walk = func(expr expression) {
+		switch e := expr.(type) {
+		case *binaryExpr:
+			walk(e.left)
+			walk(e.right)
+		case *unaryExpr:
+			walk(e.expr)
+		case *callExpr:
+			if b := referencedBuiltin(e.fn); b != nil {
+				result[b.name] = true
+			}
+			for _, a := range e.args {
+				walk(a)
+			}
+		case listExpr:
+			for _, elt := range e {
+				walk(elt)
+			}
+		}
+	}
+	for _, req := range clause.Reqs {
+		walk(req.amountExpr)
+	}
+	walkStatements(clause.statements, func(stmt statement) {
+		switch s := stmt.(type) {
+		case *verifyStatement:
+			walk(s.expr)
+		case *lockStatement:
+			walk(s.program)
+		case *ifStatement:
+			walk(s.cond)
+		}
+	})
+	return result
+}
+
+func instantiateForTest(t *testing.T, src string) []byte {
+	contracts, err := Compile(strings.NewReader(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return instantiate(t, contracts[len(contracts)-1])
+}
+
+// instantiate instantiates contract with made-up arguments: 100 plus
+// the param's index for numbers, and a string ending in 0xab
+// otherwise.
+func instantiate(t *testing.T, contract *Contract) []byte {
+	var args []ContractArg
+	for i, p := range contract.Params {
+		switch p.Type {
+		case amountType, intType, timeType:
+			n := int64(100 + i)
+			args = append(args, ContractArg{I: &n})
+		case boolType:
+			b := true
+			args = append(args, ContractArg{B: &b})
+		default:
+			s := chainjson.HexBytes{byte(i), 0xab}
+			args = append(args, ContractArg{S: &s})
+		}
+	}
+	prog, err := Instantiate(contract.Body, contract.Params, contract.Recursive, args)
+	if err != nil {
+		t.Fatalf("%s: %s", contract.Name, err)
+	}
+	return prog
+}
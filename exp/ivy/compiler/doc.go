@@ -12,13 +12,30 @@ valid.
 The language definition is in flux, but here's what's implemented as
 of late May 2017.
 
-  program = contract*
+  program = import* (const | contract)*
 
-  contract = "contract" identifier "(" [params] ")" "locks" identifier "{" clause+ "}"
+  import = "import" str_literal
 
-    The identifier after "locks" is a name for the value locked by
-    the contract. It must be unlocked or re-locked (with "unlock"
-    or "lock") in every clause.
+    Makes the contracts in the named file callable from this one.
+    Imports are resolved by the Importer passed to
+    CompileWithImports; Compile rejects them.
+
+  const = "const" identifier ":" identifier "=" expr
+
+    A named constant for use in any contract in the file. The
+    expr must be a literal, or integer arithmetic on literals, of
+    the named type.
+
+  contract = "contract" identifier "(" [params] ")" "locks" idlist "{" clause+ "}"
+
+    The identifiers after "locks" name the values locked by the
+    contract. Each must be unlocked or re-locked (with "unlock" or
+    "lock") exactly once in every clause. A contract that locks
+    more than one value holds each in an output of its own, with a
+    program made from the value's entry in LockedValues; each such
+    program checks only what happens to its own value, and to any
+    required payments. Such a contract cannot be called from
+    another.
 
   clause = "clause" identifier "(" [params] ")" ["requires" requirements] "{" statement+ "}"
 
@@ -27,7 +44,7 @@ of late May 2017.
     the earlier transaction. Each such value must be re-locked
     (with "lock") in its clause.
 
-  statement = verify | unlock | lock
+  statement = verify | unlock | lock | if
 
   verify = "verify" expr
 
@@ -45,6 +62,12 @@ of late May 2017.
     program. This unlocks expr and re-locks it with the new
     program.
 
+  if = "if" expr "{" statement+ "}" ["else" "{" statement+ "}"]
+
+    Expr must be boolean. The first block of statements runs if it
+    is true, the second (if any) if it is false. Each value must be
+    unlocked or re-locked in both blocks or in neither.
+
   requirements = requirement | requirements "," requirement
 
   requirement = identifier ":" expr "of" expr
@@ -71,11 +94,19 @@ of late May 2017.
 
   binary_expr = expr binary_op expr
 
+    The arithmetic and comparison operators work on Amounts,
+    Integers and Times. An Integer combines with either of the
+    others, but Amounts and Times can't be mixed. Arithmetic is
+    checked: overflow makes the clause fail. Subexpressions made
+    only of integer literals are computed at compile time.
+
   call_expr = expr "(" [args] ")"
 
     If expr is the name of an Ivy contract, then calling it (with
     the appropriate arguments) produces a program suitable for use
-    in "lock" statements.
+    in "lock" statements. The contract may be defined anywhere in
+    the same file or in an imported one, but two contracts may not
+    call each other.
 
     Otherwise, expr should be one of these builtin functions:
 
@@ -91,6 +122,11 @@ of late May 2017.
         The lesser of x and y.
       max(x, y)
         The greater of x and y.
+
+        The arguments to abs, min and max must be Amounts or
+        Integers, all of the same type. That is also the type of
+        the result.
+
       checkTxSig(pubkey, signature)
         Whether signature matches both the spending
         transaction and pubkey.
@@ -12,6 +12,8 @@ type envEntry struct {
 	t typeDesc
 	r role
 	c *Contract // if t == contractType
+
+	value expression // if r == roleConst
 }
 
 type role int
@@ -20,6 +22,7 @@ const (
 	roleKeyword role = 1 + iota
 	roleBuiltin
 	roleContract
+	roleConst
 	roleContractParam
 	roleContractValue
 	roleClause
@@ -31,6 +34,7 @@ var roleDesc = map[role]string{
 	roleKeyword:       "keyword",
 	roleBuiltin:       "built-in function",
 	roleContract:      "contract",
+	roleConst:         "constant",
 	roleContractParam: "contract parameter",
 	roleContractValue: "contract value",
 	roleClause:        "clause",
@@ -61,6 +65,14 @@ func (e *environ) addContract(contract *Contract) error {
 	return nil
 }
 
+func (e *environ) addConst(name string, t typeDesc, value expression) error {
+	if entry := e.lookup(name); entry != nil {
+		return fmt.Errorf("%s \"%s\" conflicts with %s", roleDesc[roleConst], name, roleDesc[entry.r])
+	}
+	e.entries[name] = &envEntry{t: t, r: roleConst, value: value}
+	return nil
+}
+
 func (e environ) lookup(name string) *envEntry {
 	if res, ok := e.entries[name]; ok {
 		return res
@@ -0,0 +1,97 @@
+package compiler
+
+import (
+	"fmt"
+	"strings"
+
+	"chain/errors"
+)
+
+// Error is a parse or compile error at a known place in the Ivy
+// source. Lines start at 1, columns start at 0, like nature
+// intended.
+type Error struct {
+	Line int    `json:"line"`
+	Col  int    `json:"col"`
+	Msg  string `json:"msg"`
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("line %d, col %d: %s", e.Line, e.Col, e.Msg)
+}
+
+// ErrorList is the error returned by Compile. It holds every error
+// found, in the order they were found.
+type ErrorList []*Error
+
+func (l ErrorList) Error() string {
+	var msgs []string
+	for _, e := range l {
+		msgs = append(msgs, e.Error())
+	}
+	return strings.Join(msgs, "\n")
+}
+
+// Warning is a likely mistake in an Ivy contract that doesn't stop
+// it from compiling.
+type Warning struct {
+	Line int    `json:"line"`
+	Col  int    `json:"col"`
+	Msg  string `json:"msg"`
+}
+
+func (w Warning) String() string {
+	return fmt.Sprintf("line %d, col %d: warning: %s", w.Line, w.Col, w.Msg)
+}
+
+func warningAt(pos position, format string, args ...interface{}) Warning {
+	return Warning{Line: pos.line, Col: pos.col, Msg: fmt.Sprintf(format, args...)}
+}
+
+type position struct {
+	line, col int
+}
+
+func posAt(buf []byte, offset int) position {
+	pos := position{line: 1}
+	for i := 0; i < offset && i < len(buf); i++ {
+		if buf[i] == '\n' {
+			pos.line++
+			pos.col = 0
+		} else {
+			pos.col++
+		}
+	}
+	return pos
+}
+
+// offsetAt is the inverse of posAt.
+func offsetAt(buf []byte, pos position) int {
+	p := position{line: 1}
+	for i, c := range buf {
+		if p == pos {
+			return i
+		}
+		if c == '\n' {
+			p.line++
+			p.col = 0
+		} else {
+			p.col++
+		}
+	}
+	return len(buf)
+}
+
+func errorAt(pos position, format string, args ...interface{}) *Error {
+	return &Error{Line: pos.line, Col: pos.col, Msg: fmt.Sprintf(format, args...)}
+}
+
+// at places the non-nil err at pos. If err already has a more
+// precise position, at returns that instead, dropping any context
+// wrapped around it.
+func at(pos position, err error) *Error {
+	if e, ok := errors.Root(err).(*Error); ok {
+		return e
+	}
+	return errorAt(pos, "%s", err)
+}
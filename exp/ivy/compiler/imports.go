@@ -0,0 +1,119 @@
+package compiler
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+)
+
+// Importer returns the source of the file named in an Ivy import
+// declaration.
+type Importer func(name string) ([]byte, error)
+
+type importContext struct {
+	opts  Options
+	files map[string]*importedFile
+}
+
+type importedFile struct {
+	loading   bool // set while compiling the file, to detect cycles
+	contracts []*Contract
+}
+
+// DirImporter returns an Importer that reads imported files
+// relative to dir.
+func DirImporter(dir string) Importer {
+	return func(name string) ([]byte, error) {
+		return ioutil.ReadFile(filepath.Join(dir, name))
+	}
+}
+
+// load compiles the file named by decl, once, and returns its
+// contracts.
+func (ic *importContext) load(decl *importDecl) ([]*Contract, *Error) {
+	if ic.opts.Importer == nil {
+		return nil, errorAt(decl.pos, "cannot import '%s': imports are not enabled", decl.name)
+	}
+	if f, ok := ic.files[decl.name]; ok {
+		if f.loading {
+			return nil, errorAt(decl.pos, "import cycle through '%s'", decl.name)
+		}
+		return f.contracts, nil
+	}
+
+	f := &importedFile{loading: true}
+	ic.files[decl.name] = f
+	src, err := ic.opts.Importer(decl.name)
+	if err != nil {
+		return nil, errorAt(decl.pos, "cannot import '%s': %s", decl.name, err)
+	}
+	contracts, errs := ic.compile(src)
+	if len(errs) > 0 {
+		return nil, errorAt(decl.pos, "in '%s': %s", decl.name, errs)
+	}
+	f.loading = false
+	f.contracts = contracts
+	return contracts, nil
+}
+
+// callees returns the contracts in contracts, other than contract
+// itself, that contract calls.
+func callees(contract *Contract, contracts []*Contract) []*Contract {
+	var result []*Contract
+	for _, other := range contracts {
+		if other == contract {
+			continue
+		}
+		for _, clause := range contract.Clauses {
+			if clauseReferences(clause, other.Name) {
+				result = append(result, other)
+				break
+			}
+		}
+	}
+	return result
+}
+
+// callOrder sorts contracts so that each comes after the contracts it
+// calls. Otherwise it keeps them in source order. A contract may call
+// itself, but mutually recursive contracts are an error, since
+// neither's program could contain the other's.
+func callOrder(contracts []*Contract) ([]*Contract, error) {
+	const (
+		visiting = 1 + iota
+		done
+	)
+	var (
+		order []*Contract
+		state = make(map[*Contract]int)
+		path  []string
+		visit func(*Contract) error
+	)
+	visit = func(c *Contract) error {
+		switch state[c] {
+		case done:
+			return nil
+		case visiting:
+			return errorAt(c.pos, "contracts call each other in a cycle: %s -> %s", strings.Join(path, " -> "), c.Name)
+		}
+		state[c] = visiting
+		path = append(path, c.Name)
+		for _, callee := range callees(c, contracts) {
+			err := visit(callee)
+			if err != nil {
+				return err
+			}
+		}
+		path = path[:len(path)-1]
+		state[c] = done
+		order = append(order, c)
+		return nil
+	}
+	for _, c := range contracts {
+		err := visit(c)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return order, nil
+}
@@ -153,3 +153,77 @@ contract One(a, b, c: Program, switchTime, expirationTime: Time) locks value {
   }
 }
 `
+
+const DeadlineTransfer = `
+contract DeadlineTransfer(early, late: Program, deadline: Time) locks value {
+  clause transfer() {
+    if before(deadline) {
+      lock value with early
+    } else {
+      lock value with late
+    }
+  }
+}
+`
+
+const SignAfterDeadline = `
+contract SignAfterDeadline(owner: Program, ownerKey: PublicKey, deadline: Time) locks value {
+  clause redeem(sig: Signature) {
+    if after(deadline) {
+      verify checkTxSig(ownerKey, sig)
+    }
+    lock value with owner
+  }
+}
+`
+
+const DoublePrice = `
+contract DoublePrice(requestedAsset: Asset, requestedAmount: Amount, sellerProgram: Program, deadline: Time) locks offered {
+  clause trade() requires payment: requestedAmount * 2 of requestedAsset {
+    verify before(deadline + 24 * 60 * 60 * 1000)
+    lock payment with sellerProgram
+    unlock offered
+  }
+}
+`
+
+const CappedPayment = `
+contract CappedPayment(cap: Amount, paymentAsset: Asset, seller: Program) locks value {
+  clause pay(requested: Amount) requires payment: min(requested, cap) of paymentAsset {
+    lock payment with seller
+    unlock value
+  }
+}
+`
+
+const Vault = `
+contract Vault(hash: Hash) locks value {
+  clause reveal(string: String) {
+    verify sha3(string) == hash
+    unlock value
+  }
+}
+`
+
+const HandoffVault = `
+contract Handoff(hash: Hash) locks value {
+  clause handoff() {
+    lock value with Vault(hash)
+  }
+}
+` + Vault
+
+const TwoValueEscrow = `
+contract TwoValueEscrow(agent: PublicKey, sender: Program, recipient: Program) locks bond, collateral {
+  clause settle(sig: Signature) {
+    verify checkTxSig(agent, sig)
+    lock bond with sender
+    lock collateral with recipient
+  }
+  clause refund(sig: Signature) {
+    verify checkTxSig(agent, sig)
+    lock bond with sender
+    lock collateral with sender
+  }
+}
+`
@@ -1,6 +1,11 @@
 package compiler
 
-import "strings"
+import (
+	"strings"
+
+	"chain/errors"
+	"chain/math/checked"
+)
 
 var optimizations = []struct {
 	before, after string
@@ -40,25 +45,170 @@ var optimizations = []struct {
 	{"DUP 2 PICK MAX", "2DUP MAX"},
 }
 
-func optimize(opcodes string) string {
-	opcodes = " " + opcodes + " "
+// opcode is a single assembler token, such as "ROLL", "5", or
+// "$_end", and the statement it was compiled from (nil for the
+// clause-selection code around the statements).
+type opcode struct {
+	text string
+	src  *sourceRef
+}
+
+func optimize(ops []opcode) []opcode {
 	looping := true
 	for looping {
 		looping = false
 		for _, o := range optimizations {
-			before := " " + o.before + " "
-			var after string
-			if o.after == "" {
-				after = " "
-			} else {
-				after = " " + o.after + " "
-			}
-			newOpcodes := strings.Replace(opcodes, before, after, -1)
-			if newOpcodes != opcodes {
+			before, after := strings.Fields(o.before), strings.Fields(o.after)
+			var newOps []opcode
+			for i := 0; i < len(ops); {
+				if !hasOpcodes(ops[i:], before) {
+					newOps = append(newOps, ops[i])
+					i++
+					continue
+				}
+				// The replacement belongs to whatever statement
+				// the first opcode replaced did.
+				for _, text := range after {
+					newOps = append(newOps, opcode{text, ops[i].src})
+				}
+				i += len(before)
 				looping = true
-				opcodes = newOpcodes
+
+				// The opcode just after a match begins no new
+				// match until the next pass.
+				if i < len(ops) {
+					newOps = append(newOps, ops[i])
+					i++
+				}
 			}
+			ops = newOps
+		}
+	}
+	return ops
+}
+
+func hasOpcodes(ops []opcode, texts []string) bool {
+	if len(ops) < len(texts) {
+		return false
+	}
+	for i, text := range texts {
+		if ops[i].text != text {
+			return false
+		}
+	}
+	return true
+}
+
+func opcodesString(ops []opcode) string {
+	var texts []string
+	for _, op := range ops {
+		texts = append(texts, op.text)
+	}
+	return strings.Join(texts, " ")
+}
+
+// foldBool evaluates the comparison expr at compile time if its
+// operands are integers foldConst can evaluate, or are the same
+// variable.
+func foldBool(expr expression) (val, ok bool) {
+	e, isBinary := expr.(*binaryExpr)
+	if !isBinary {
+		return false, false
+	}
+	var cmp int
+	if l, r := e.left, e.right; isVarRef(l) && isVarRef(r) && l == r {
+		cmp = 0
+	} else {
+		x, ok, err := foldConst(l)
+		if !ok || err != nil {
+			return false, false
+		}
+		y, ok, err := foldConst(r)
+		if !ok || err != nil {
+			return false, false
+		}
+		switch {
+		case x < y:
+			cmp = -1
+		case x > y:
+			cmp = 1
+		}
+	}
+	switch e.op.op {
+	case "==":
+		return cmp == 0, true
+	case "!=":
+		return cmp != 0, true
+	case "<":
+		return cmp < 0, true
+	case "<=":
+		return cmp <= 0, true
+	case ">":
+		return cmp > 0, true
+	case ">=":
+		return cmp >= 0, true
+	}
+	return false, false
+}
+
+func isVarRef(expr expression) bool {
+	_, ok := expr.(varRef)
+	return ok
+}
+
+// foldConst evaluates expr at compile time if it is built only from
+// integer literals and the operators +, -, *, / and unary -. It fails
+// where the VM would fail at run time, on overflow or division by
+// zero.
+func foldConst(expr expression) (n int64, ok bool, err error) {
+	switch e := expr.(type) {
+	case integerLiteral:
+		return int64(e), true, nil
+
+	case *unaryExpr:
+		if e.op.op != "-" {
+			return 0, false, nil
+		}
+		x, ok, err := foldConst(e.expr)
+		if !ok || err != nil {
+			return 0, false, err
+		}
+		n, ok = checked.NegateInt64(x)
+		if !ok {
+			return 0, false, errors.New("integer overflow")
+		}
+		return n, true, nil
+
+	case *binaryExpr:
+		var f func(x, y int64) (int64, bool)
+		switch e.op.op {
+		case "+":
+			f = checked.AddInt64
+		case "-":
+			f = checked.SubInt64
+		case "*":
+			f = checked.MulInt64
+		case "/":
+			f = checked.DivInt64
+		default:
+			return 0, false, nil
+		}
+		x, ok, err := foldConst(e.left)
+		if !ok || err != nil {
+			return 0, false, err
+		}
+		y, ok, err := foldConst(e.right)
+		if !ok || err != nil {
+			return 0, false, err
+		}
+		if e.op.op == "/" && y == 0 {
+			return 0, false, errors.New("division by zero")
+		}
+		n, ok = f(x, y)
+		if !ok {
+			return 0, false, errors.New("integer overflow")
 		}
+		return n, true, nil
 	}
-	return strings.TrimSpace(opcodes)
+	return 0, false, nil
 }
@@ -3,7 +3,6 @@ package compiler
 import (
 	"bytes"
 	"encoding/hex"
-	"fmt"
 	"strconv"
 	"unicode"
 )
@@ -25,14 +24,24 @@ type parser struct {
 }
 
 func (p *parser) errorf(format string, args ...interface{}) {
-	panic(parserErr{buf: p.buf, offset: p.pos, format: format, args: args})
+	panic(errorAt(p.here(), format, args...))
+}
+
+// here is the position of the next token.
+func (p *parser) here() position {
+	return posAt(p.buf, skipWsAndComments(p.buf, p.pos))
+}
+
+// end is the position just past the last token consumed.
+func (p *parser) end() position {
+	return posAt(p.buf, p.pos)
 }
 
 // parse is the main entry point to the parser
-func parse(buf []byte) (contracts []*Contract, err error) {
+func parse(buf []byte) (f *sourceFile, err error) {
 	defer func() {
 		if val := recover(); val != nil {
-			if e, ok := val.(parserErr); ok {
+			if e, ok := val.(*Error); ok {
 				err = e
 			} else {
 				panic(val)
@@ -40,32 +49,81 @@ func parse(buf []byte) (contracts []*Contract, err error) {
 		}
 	}()
 	p := &parser{buf: buf}
-	contracts = parseContracts(p)
-	return
+	f = &sourceFile{imports: parseImports(p)}
+	parseDecls(p, f)
+	return f, nil
 }
 
 // parse functions
 
-func parseContracts(p *parser) []*Contract {
-	var result []*Contract
-	for peekKeyword(p) == "contract" {
-		contract := parseContract(p)
-		result = append(result, contract)
+// import 'name'
+func parseImports(p *parser) []*importDecl {
+	var result []*importDecl
+	for peekKeyword(p) == "import" {
+		pos := p.here()
+		consumeKeyword(p, "import")
+		lit, newPos := scanStrLiteral(p.buf, p.pos)
+		if newPos < 0 {
+			p.errorf("expected string literal, got %s", peekText(p))
+		}
+		p.pos = newPos
+		name := string(lit[1 : len(lit)-1])
+		result = append(result, &importDecl{name: name, pos: pos})
 	}
 	return result
 }
 
-// contract name(p1, p2: t1, p3: t2) locks value { ... }
+// parseDecls parses the constants and contracts following the
+// imports, in any order.
+func parseDecls(p *parser, f *sourceFile) {
+	for {
+		switch peekKeyword(p) {
+		case "const":
+			f.consts = append(f.consts, parseConst(p))
+		case "contract":
+			f.contracts = append(f.contracts, parseContract(p))
+		default:
+			if skipWsAndComments(p.buf, p.pos) < len(p.buf) {
+				p.errorf("expected const or contract keyword, got %s", peekText(p))
+			}
+			return
+		}
+	}
+}
+
+// const name: type = expr
+func parseConst(p *parser) *constDecl {
+	pos := p.here()
+	consumeKeyword(p, "const")
+	name := consumeIdentifier(p)
+	consumeTok(p, ":")
+	typPos := p.here()
+	typ := consumeIdentifier(p)
+	tdesc, ok := types[typ]
+	if !ok {
+		panic(errorAt(typPos, "unknown type %s", typ))
+	}
+	consumeTok(p, "=")
+	value := parseExpr(p)
+	return &constDecl{name: name, typ: tdesc, value: value, pos: pos}
+}
+
+// contract name(p1, p2: t1, p3: t2) locks value1, value2 { ... }
 func parseContract(p *parser) *Contract {
+	pos := p.here()
 	consumeKeyword(p, "contract")
 	name := consumeIdentifier(p)
 	params := parseParams(p)
 	consumeKeyword(p, "locks")
-	value := consumeIdentifier(p)
+	values := []string{consumeIdentifier(p)}
+	for peekTok(p, ",") {
+		consumeTok(p, ",")
+		values = append(values, consumeIdentifier(p))
+	}
 	consumeTok(p, "{")
 	clauses := parseClauses(p)
 	consumeTok(p, "}")
-	return &Contract{Name: name, Params: params, Clauses: clauses, Value: value}
+	return &Contract{Name: name, Params: params, Clauses: clauses, Value: values[0], values: values, pos: pos}
 }
 
 // (p1, p2: t1, p3: t2)
@@ -77,7 +135,7 @@ func parseParams(p *parser) []*Param {
 		if first {
 			first = false
 		} else {
-			consumeTok(p, ",")
+			consumeSep(p, ")")
 		}
 		pt := parseParamsType(p)
 		params = append(params, pt...)
@@ -104,12 +162,13 @@ func parseParamsType(p *parser) []*Param {
 		params = append(params, &Param{Name: name})
 	}
 	consumeTok(p, ":")
+	typPos := p.here()
 	typ := consumeIdentifier(p)
 	for _, parm := range params {
 		if tdesc, ok := types[typ]; ok {
 			parm.Type = tdesc
 		} else {
-			p.errorf("unknown type %s", typ)
+			panic(errorAt(typPos, "unknown type %s", typ))
 		}
 	}
 	return params
@@ -117,6 +176,7 @@ func parseParamsType(p *parser) []*Param {
 
 func parseClause(p *parser) *Clause {
 	var c Clause
+	c.pos = p.here()
 	consumeKeyword(p, "clause")
 	c.Name = consumeIdentifier(p)
 	c.Params = parseParams(p)
@@ -169,28 +229,52 @@ func parseStatement(p *parser) statement {
 		return parseLockStmt(p)
 	case "unlock":
 		return parseUnlockStmt(p)
+	case "if":
+		return parseIfStmt(p)
 	}
-	panic(parseErr(p.buf, p.pos, "unknown keyword \"%s\"", peekKeyword(p)))
+	p.errorf("unknown keyword \"%s\"", peekKeyword(p))
+	return nil
 }
 
 func parseVerifyStmt(p *parser) *verifyStatement {
+	pos := p.here()
 	consumeKeyword(p, "verify")
 	expr := parseExpr(p)
-	return &verifyStatement{expr: expr}
+	return &verifyStatement{expr: expr, pos: pos, end: p.end()}
 }
 
 func parseLockStmt(p *parser) *lockStatement {
+	pos := p.here()
 	consumeKeyword(p, "lock")
 	locked := parseExpr(p)
 	consumeKeyword(p, "with")
 	program := parseExpr(p)
-	return &lockStatement{locked: locked, program: program}
+	return &lockStatement{locked: locked, program: program, pos: pos, end: p.end()}
+}
+
+func parseIfStmt(p *parser) *ifStatement {
+	var s ifStatement
+	s.pos = p.here()
+	consumeKeyword(p, "if")
+	s.cond = parseExpr(p)
+	consumeTok(p, "{")
+	s.body = parseStatements(p)
+	consumeTok(p, "}")
+	if peekKeyword(p) == "else" {
+		consumeKeyword(p, "else")
+		consumeTok(p, "{")
+		s.elseBody = parseStatements(p)
+		consumeTok(p, "}")
+	}
+	s.end = p.end()
+	return &s
 }
 
 func parseUnlockStmt(p *parser) *unlockStatement {
+	pos := p.here()
 	consumeKeyword(p, "unlock")
 	expr := parseExpr(p)
-	return &unlockStatement{expr}
+	return &unlockStatement{expr: expr, pos: pos, end: p.end()}
 }
 
 func parseExpr(p *parser) expression {
@@ -199,20 +283,21 @@ func parseExpr(p *parser) expression {
 	expr := parseUnaryExpr(p)
 	expr2, pos := parseExprCont(p, expr, 0)
 	if pos < 0 {
-		p.errorf("expected expression")
+		p.errorf("expected expression, got %s", peekText(p))
 	}
 	p.pos = pos
 	return expr2
 }
 
 func parseUnaryExpr(p *parser) expression {
+	opPos := p.here()
 	op, pos := scanUnaryOp(p.buf, p.pos)
 	if pos < 0 {
 		return parseExpr2(p)
 	}
 	p.pos = pos
 	expr := parseUnaryExpr(p)
-	return &unaryExpr{op: op, expr: expr}
+	return &unaryExpr{op: op, expr: expr, pos: opPos}
 }
 
 func parseExprCont(p *parser, lhs expression, minPrecedence int) (expression, int) {
@@ -221,6 +306,7 @@ func parseExprCont(p *parser, lhs expression, minPrecedence int) (expression, in
 		if pos < 0 || op.precedence < minPrecedence {
 			break
 		}
+		opPos := p.here()
 		p.pos = pos
 
 		rhs := parseUnaryExpr(p)
@@ -235,7 +321,7 @@ func parseExprCont(p *parser, lhs expression, minPrecedence int) (expression, in
 				return nil, -1 // or is this an error?
 			}
 		}
-		lhs = &binaryExpr{left: lhs, right: rhs, op: op}
+		lhs = &binaryExpr{left: lhs, right: rhs, op: op, pos: opPos}
 	}
 	return lhs, p.pos
 }
@@ -249,10 +335,11 @@ func parseExpr2(p *parser) expression {
 }
 
 func parseExpr3(p *parser) expression {
+	pos := p.here()
 	e := parseExpr4(p)
 	if peekTok(p, "(") {
 		args := parseArgs(p)
-		return &callExpr{fn: e, args: args}
+		return &callExpr{fn: e, args: args, pos: pos}
 	}
 	return e
 }
@@ -292,7 +379,7 @@ func parseArgs(p *parser) []expression {
 		if first {
 			first = false
 		} else {
-			consumeTok(p, ",")
+			consumeSep(p, ")")
 		}
 		e := parseExpr(p)
 		exprs = append(exprs, e)
@@ -313,17 +400,30 @@ func peekTok(p *parser, token string) bool {
 	return pos >= 0
 }
 
+// peekText describes the next token for error messages.
+func peekText(p *parser) string {
+	offset := skipWsAndComments(p.buf, p.pos)
+	if offset >= len(p.buf) {
+		return "end of input"
+	}
+	if name, pos := scanIdentifier(p.buf, offset); pos >= 0 {
+		return strconv.Quote(name)
+	}
+	return strconv.Quote(string(p.buf[offset]))
+}
+
 // consume functions
 
 var keywords = []string{
 	"contract", "clause", "verify", "output", "return",
 	"locks", "requires", "of", "lock", "with", "unlock",
+	"if", "else", "import", "const",
 }
 
 func consumeKeyword(p *parser, keyword string) {
 	pos := scanKeyword(p.buf, p.pos, keyword)
 	if pos < 0 {
-		p.errorf("expected keyword %s", keyword)
+		p.errorf("expected keyword %s, got %s", keyword, peekText(p))
 	}
 	p.pos = pos
 }
@@ -331,7 +431,7 @@ func consumeKeyword(p *parser, keyword string) {
 func consumeIdentifier(p *parser) string {
 	name, pos := scanIdentifier(p.buf, p.pos)
 	if pos < 0 {
-		p.errorf("expected identifier")
+		p.errorf("expected identifier, got %s", peekText(p))
 	}
 	p.pos = pos
 	return name
@@ -340,7 +440,17 @@ func consumeIdentifier(p *parser) string {
 func consumeTok(p *parser, token string) {
 	pos := scanTok(p.buf, p.pos, token)
 	if pos < 0 {
-		p.errorf("expected %s token", token)
+		p.errorf("expected %s token, got %s", token, peekText(p))
+	}
+	p.pos = pos
+}
+
+// consumeSep consumes the comma between list items, where close is
+// the token that would end the list instead.
+func consumeSep(p *parser, close string) {
+	pos := scanTok(p.buf, p.pos, ",")
+	if pos < 0 {
+		p.errorf("expected , or %s token, got %s", close, peekText(p))
 	}
 	p.pos = pos
 }
@@ -382,6 +492,11 @@ func scanBinaryOp(buf []byte, offset int) (*binaryOp, int) {
 // TODO(bobg): boolean literals?
 func scanLiteralExpr(buf []byte, offset int) (expression, int) {
 	offset = skipWsAndComments(buf, offset)
+	bytesliteral, newOffset := scanBytesLiteral(buf, offset) // 0x6c249a...
+	if newOffset >= 0 {
+		// before integers, which would otherwise take the leading 0
+		return bytesliteral, newOffset
+	}
 	intliteral, newOffset := scanIntLiteral(buf, offset)
 	if newOffset >= 0 {
 		return intliteral, newOffset
@@ -390,10 +505,6 @@ func scanLiteralExpr(buf []byte, offset int) (expression, int) {
 	if newOffset >= 0 {
 		return strliteral, newOffset
 	}
-	bytesliteral, newOffset := scanBytesLiteral(buf, offset) // 0x6c249a...
-	if newOffset >= 0 {
-		return bytesliteral, newOffset
-	}
 	return nil, -1
 }
 
@@ -460,7 +571,7 @@ func scanStrLiteral(buf []byte, offset int) (bytesLiteral, int) {
 			i++
 		}
 	}
-	panic(parseErr(buf, offset, "unterminated string literal"))
+	panic(errorAt(posAt(buf, offset), "unterminated string literal"))
 }
 
 func scanBytesLiteral(buf []byte, offset int) (bytesLiteral, int) {
@@ -477,13 +588,13 @@ func scanBytesLiteral(buf []byte, offset int) (bytesLiteral, int) {
 	i := offset + 4
 	for ; i < len(buf); i += 2 {
 		if i == len(buf)-1 {
-			panic(parseErr(buf, offset, "odd number of digits in hex literal"))
+			panic(errorAt(posAt(buf, offset), "odd number of digits in hex literal"))
 		}
 		if !isHexDigit(buf[i]) {
 			break
 		}
 		if !isHexDigit(buf[i+1]) {
-			panic(parseErr(buf, offset, "odd number of digits in hex literal"))
+			panic(errorAt(posAt(buf, offset), "odd number of digits in hex literal"))
 		}
 	}
 	decoded := make([]byte, hex.DecodedLen(i-(offset+2)))
@@ -533,31 +644,3 @@ func isIDChar(c byte, initial bool) bool {
 	}
 	return unicode.IsDigit(rune(c))
 }
-
-type parserErr struct {
-	buf    []byte
-	offset int
-	format string
-	args   []interface{}
-}
-
-func parseErr(buf []byte, offset int, format string, args ...interface{}) error {
-	return parserErr{buf: buf, offset: offset, format: format, args: args}
-}
-
-func (p parserErr) Error() string {
-	// Lines start at 1, columns start at 0, like nature intended.
-	line := 1
-	col := 0
-	for i := 0; i < p.offset; i++ {
-		if p.buf[i] == '\n' {
-			line++
-			col = 0
-		} else {
-			col++
-		}
-	}
-	args := []interface{}{line, col}
-	args = append(args, p.args...)
-	return fmt.Sprintf("line %d, col %d: "+p.format, args...)
-}
@@ -0,0 +1,99 @@
+package compiler
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"chain/crypto/ed25519"
+)
+
+// argEncoding returns how an argument of type t is written in JSON,
+// and for hex strings the bounds on its length in bytes. See
+// Param.Encoding.
+func argEncoding(t typeDesc) (encoding string, minLen, maxLen int) {
+	switch t {
+	case amountType, intType:
+		return "integer", 0, 0
+	case timeType:
+		return "time", 0, 0
+	case boolType:
+		return "boolean", 0, 0
+	case pubkeyType:
+		return "hex", ed25519.PublicKeySize, ed25519.PublicKeySize
+	case sigType:
+		return "hex", ed25519.SignatureSize, ed25519.SignatureSize
+	case assetType, hashType, sha3StrType, sha3PubkeyType, sha256StrType, sha256PubkeyType:
+		// Asset IDs and the results of sha3 and sha256 are 32 bytes.
+		return "hex", 32, 32
+	}
+	return "hex", 0, 0
+}
+
+// referencedParams returns the names of the parameters of contract
+// and clause that expr refers to.
+func referencedParams(expr expression, contract *Contract, clause *Clause) []string {
+	var result []string
+	for _, params := range [][]*Param{contract.Params, clause.Params} {
+		for _, p := range params {
+			if references(expr, p.Name) {
+				result = append(result, p.Name)
+			}
+		}
+	}
+	return result
+}
+
+// MarshalJSONSchema returns a JSON Schema document describing the
+// arguments for spending with clause: an object with a property for
+// each clause parameter, encoded as described by its Encoding. The
+// clause's payment requirements, which aren't arguments but which a
+// spender must satisfy, are listed under "x-ivy-reqs".
+func (clause *Clause) MarshalJSONSchema() ([]byte, error) {
+	props := make(map[string]interface{})
+	required := []string{}
+	for _, p := range clause.Params {
+		encoding, minLen, maxLen := p.Encoding, p.MinLen, p.MaxLen
+		if encoding == "" {
+			encoding, minLen, maxLen = argEncoding(p.Type)
+		}
+		prop := map[string]interface{}{
+			"description": string(p.Type),
+		}
+		switch encoding {
+		case "integer":
+			prop["type"] = "integer"
+			if p.Type == amountType {
+				prop["minimum"] = 0
+			}
+		case "time":
+			prop["type"] = "integer"
+			prop["minimum"] = 0
+			prop["description"] = fmt.Sprintf("%s, in milliseconds since the Unix epoch", p.Type)
+		case "boolean":
+			prop["type"] = "boolean"
+		default:
+			prop["type"] = "string"
+			prop["pattern"] = "^([0-9a-fA-F]{2})*$"
+			if minLen > 0 {
+				prop["minLength"] = 2 * minLen
+			}
+			if maxLen > 0 {
+				prop["maxLength"] = 2 * maxLen
+			}
+		}
+		props[p.Name] = prop
+		required = append(required, p.Name)
+	}
+	schema := map[string]interface{}{
+		"$schema":              "http://json-schema.org/draft-04/schema#",
+		"title":                clause.Name,
+		"type":                 "object",
+		"properties":           props,
+		"required":             required,
+		"additionalProperties": false,
+	}
+	if len(clause.Reqs) > 0 {
+		schema["x-ivy-reqs"] = clause.Reqs
+	}
+	return json.Marshal(schema)
+}
@@ -0,0 +1,160 @@
+package compiler
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"regexp"
+	"strings"
+	"testing"
+
+	"chain/exp/ivy/compiler/ivytest"
+)
+
+func TestMarshalJSONSchema(t *testing.T) {
+	sig := strings.Repeat("ab", 64)
+	cases := []struct {
+		contract string
+		clause   string
+		witness  string
+		valid    bool
+	}{
+		{ivytest.TradeOffer, "trade", `{}`, true},
+		{ivytest.TradeOffer, "trade", `{"sellerSig": "` + sig + `"}`, false},
+		{ivytest.TradeOffer, "cancel", `{"sellerSig": "` + sig + `"}`, true},
+		{ivytest.TradeOffer, "cancel", `{"sellerSig": "` + strings.ToUpper(sig) + `"}`, true},
+		{ivytest.TradeOffer, "cancel", `{}`, false},
+		{ivytest.TradeOffer, "cancel", `{"sellerSig": "abab"}`, false},
+		{ivytest.TradeOffer, "cancel", `{"sellerSig": "` + sig + `ab"}`, false},
+		{ivytest.TradeOffer, "cancel", `{"sellerSig": "` + strings.Repeat("zz", 64) + `"}`, false},
+		{ivytest.TradeOffer, "cancel", `{"sellerSig": 17}`, false},
+		{ivytest.CollateralizedLoan, "repay", `{}`, true},
+		{ivytest.CollateralizedLoan, "repay", `{"deadline": 1500000000000}`, false},
+		{ivytest.CollateralizedLoan, "default", `{}`, true},
+		{ivytest.PriceChanger, "changePrice", `{"newAmount": 5, "newAsset": "` + strings.Repeat("00", 32) + `", "sig": "` + sig + `"}`, true},
+		{ivytest.PriceChanger, "changePrice", `{"newAmount": -5, "newAsset": "` + strings.Repeat("00", 32) + `", "sig": "` + sig + `"}`, false},
+		{ivytest.PriceChanger, "changePrice", `{"newAmount": 5.5, "newAsset": "` + strings.Repeat("00", 32) + `", "sig": "` + sig + `"}`, false},
+	}
+	for _, c := range cases {
+		contracts, err := Compile(strings.NewReader(c.contract))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var clause *Clause
+		for _, cl := range contracts[0].Clauses {
+			if cl.Name == c.clause {
+				clause = cl
+			}
+		}
+		b, err := clause.MarshalJSONSchema()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var schema, witness interface{}
+		err = json.Unmarshal(b, &schema)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = json.Unmarshal([]byte(c.witness), &witness)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = validateJSONSchema(schema, witness)
+		if (err == nil) != c.valid {
+			t.Errorf("%s.%s: validating %s got error %v, want valid %v", contracts[0].Name, c.clause, c.witness, err, c.valid)
+		}
+	}
+}
+
+func TestMarshalJSONSchemaReqs(t *testing.T) {
+	contracts, err := Compile(strings.NewReader(ivytest.DoublePrice))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := contracts[0].Clauses[0].MarshalJSONSchema()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var schema struct {
+		Reqs []*ClauseReq `json:"x-ivy-reqs"`
+	}
+	err = json.Unmarshal(b, &schema)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(schema.Reqs) != 1 {
+		t.Fatalf("got %d reqs, want 1", len(schema.Reqs))
+	}
+	req := schema.Reqs[0]
+	if req.Amount != "(requestedAmount * 2)" || fmt.Sprint(req.AmountParams) != "[requestedAmount]" {
+		t.Errorf("got amount %s with params %v, want (requestedAmount * 2) with params [requestedAmount]", req.Amount, req.AmountParams)
+	}
+	if req.Asset != "requestedAsset" || fmt.Sprint(req.AssetParams) != "[requestedAsset]" {
+		t.Errorf("got asset %s with params %v, want requestedAsset with params [requestedAsset]", req.Asset, req.AssetParams)
+	}
+}
+
+// validateJSONSchema checks v against schema. It understands the
+// JSON Schema keywords that MarshalJSONSchema produces, and ignores
+// the rest.
+func validateJSONSchema(schema, v interface{}) error {
+	s, ok := schema.(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("schema %v is not an object", schema)
+	}
+	switch s["type"] {
+	case "object":
+		obj, ok := v.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("%v is not an object", v)
+		}
+		props, _ := s["properties"].(map[string]interface{})
+		if req, ok := s["required"].([]interface{}); ok {
+			for _, name := range req {
+				if _, ok := obj[name.(string)]; !ok {
+					return fmt.Errorf("missing property %s", name)
+				}
+			}
+		}
+		for name, val := range obj {
+			prop, ok := props[name]
+			if !ok {
+				if s["additionalProperties"] == false {
+					return fmt.Errorf("unexpected property %s", name)
+				}
+				continue
+			}
+			err := validateJSONSchema(prop, val)
+			if err != nil {
+				return fmt.Errorf("property %s: %s", name, err)
+			}
+		}
+	case "string":
+		str, ok := v.(string)
+		if !ok {
+			return fmt.Errorf("%v is not a string", v)
+		}
+		if pattern, ok := s["pattern"].(string); ok && !regexp.MustCompile(pattern).MatchString(str) {
+			return fmt.Errorf("%q does not match %s", str, pattern)
+		}
+		if min, ok := s["minLength"].(float64); ok && float64(len(str)) < min {
+			return fmt.Errorf("%q is shorter than %v", str, min)
+		}
+		if max, ok := s["maxLength"].(float64); ok && float64(len(str)) > max {
+			return fmt.Errorf("%q is longer than %v", str, max)
+		}
+	case "integer":
+		n, ok := v.(float64)
+		if !ok || n != math.Trunc(n) {
+			return fmt.Errorf("%v is not an integer", v)
+		}
+		if min, ok := s["minimum"].(float64); ok && n < min {
+			return fmt.Errorf("%v is less than %v", n, min)
+		}
+	case "boolean":
+		if _, ok := v.(bool); !ok {
+			return fmt.Errorf("%v is not a boolean", v)
+		}
+	}
+	return nil
+}
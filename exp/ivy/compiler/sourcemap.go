@@ -0,0 +1,118 @@
+package compiler
+
+import (
+	"fmt"
+	"strings"
+
+	"chain/errors"
+	"chain/protocol/vm"
+)
+
+// SourceMap maps a contract's Body back to its source, in order of
+// Body offset. Code that comes from no single statement, such as the
+// selection of a clause, is not mapped.
+type SourceMap []SourceMapEntry
+
+// SourceMapEntry describes a range of Body compiled from one
+// statement. A statement may have several entries, since the code
+// of an if statement surrounds the code of the statements inside it.
+type SourceMapEntry struct {
+	// Start and End are the offsets in Body of the first byte of
+	// the range and of the byte just past it.
+	Start uint32 `json:"start"`
+	End   uint32 `json:"end"`
+
+	// Clause is the name of the clause containing the statement.
+	Clause string `json:"clause"`
+
+	// Statement is the index of the statement in its clause,
+	// counting the statements inside if statements, in source
+	// order.
+	Statement int `json:"statement"`
+
+	// Line and Col are where the statement starts in the source,
+	// and EndLine and EndCol where it ends.
+	Line    int `json:"line"`
+	Col     int `json:"col"`
+	EndLine int `json:"end_line"`
+	EndCol  int `json:"end_col"`
+}
+
+func newSourceMap(ops []opcode, body []byte) (SourceMap, error) {
+	var (
+		result SourceMap
+		pc     uint32
+	)
+	for _, op := range ops {
+		if strings.HasPrefix(op.text, "$") {
+			// A jump target takes no space in the program.
+			continue
+		}
+		inst, err := vm.ParseOp(body, pc)
+		if err != nil {
+			return nil, errors.Wrapf(err, "mapping opcode %s at pc %d", op.text, pc)
+		}
+		start := pc
+		pc += inst.Len
+		if op.src == nil {
+			continue
+		}
+		entry := op.src.entry(start, pc)
+		if n := len(result); n > 0 && result[n-1].End == start && result[n-1].Clause == entry.Clause && result[n-1].Statement == entry.Statement {
+			result[n-1].End = pc
+			continue
+		}
+		result = append(result, entry)
+	}
+	return result, nil
+}
+
+func (src *sourceRef) entry(start, end uint32) SourceMapEntry {
+	var index, i int
+	walkStatements(src.clause.statements, func(s statement) {
+		if s == src.stmt {
+			index = i
+		}
+		i++
+	})
+	pos, endPos := src.stmt.span()
+	return SourceMapEntry{
+		Start:     start,
+		End:       end,
+		Clause:    src.clause.Name,
+		Statement: index,
+		Line:      pos.line,
+		Col:       pos.col,
+		EndLine:   endPos.line,
+		EndCol:    endPos.col,
+	}
+}
+
+// Lookup returns the entry for the instruction at offset pc in Body.
+func (m SourceMap) Lookup(pc uint32) (SourceMapEntry, bool) {
+	for _, e := range m {
+		if e.Start <= pc && pc < e.End {
+			return e, true
+		}
+	}
+	return SourceMapEntry{}, false
+}
+
+// DescribePC returns the text of the statement that the instruction
+// at offset pc in contract.Body was compiled from, with its position
+// and clause. The contract must have been compiled from src with a
+// source map. The PC of a vm.Error has the right offset when Body
+// was run by itself, rather than as part of a program from
+// Instantiate.
+func DescribePC(src []byte, contract *Contract, pc uint32) (string, error) {
+	if contract.SourceMap == nil {
+		return "", fmt.Errorf("contract %s has no source map", contract.Name)
+	}
+	e, ok := contract.SourceMap.Lookup(pc)
+	if !ok {
+		return "", fmt.Errorf("no statement of contract %s at pc %d", contract.Name, pc)
+	}
+	start := offsetAt(src, position{e.Line, e.Col})
+	end := offsetAt(src, position{e.EndLine, e.EndCol})
+	return fmt.Sprintf("line %d, col %d, clause \"%s\": %s", e.Line, e.Col, e.Clause, src[start:end]), nil
+}
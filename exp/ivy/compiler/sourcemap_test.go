@@ -0,0 +1,93 @@
+package compiler
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"chain/exp/ivy/compiler/ivytest"
+	"chain/protocol/vm"
+)
+
+func TestSourceMap(t *testing.T) {
+	contracts, err := CompileWithOptions(strings.NewReader(ivytest.EscrowedTransfer), Options{SourceMap: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := contracts[0]
+
+	// 3 ROLL JUMPIF:$reject $approve 3 ROLL SWAP TXSIGHASH SWAP CHECKSIG VERIFY 0 0 AMOUNT ASSET 1 6 ROLL CHECKOUTPUT JUMP:$_end ...
+	cases := []struct {
+		pc        uint32
+		clause    string
+		statement int
+		line      int
+	}{
+		{pc: 0},               // the clause selector's ROLL
+		{7, "approve", 0, 4},  // 3 ROLL
+		{13, "approve", 0, 4}, // VERIFY
+		{14, "approve", 1, 5}, // 0
+		{21, "approve", 1, 5}, // CHECKOUTPUT
+		{pc: 22},              // JUMP:$_end
+		{29, "reject", 0, 8},  // SWAP
+		{41, "reject", 1, 9},  // CHECKOUTPUT
+	}
+	for _, tc := range cases {
+		e, ok := c.SourceMap.Lookup(tc.pc)
+		if ok != (tc.clause != "") {
+			t.Errorf("Lookup(%d) ok = %t", tc.pc, ok)
+			continue
+		}
+		if e.Clause != tc.clause || e.Statement != tc.statement || e.Line != tc.line {
+			t.Errorf("Lookup(%d) = clause %s statement %d line %d, want clause %s statement %d line %d", tc.pc, e.Clause, e.Statement, e.Line, tc.clause, tc.statement, tc.line)
+		}
+	}
+}
+
+func TestSourceMapJSON(t *testing.T) {
+	for _, sourceMap := range []bool{false, true} {
+		contracts, err := CompileWithOptions(strings.NewReader(ivytest.TrivialLock), Options{SourceMap: sourceMap})
+		if err != nil {
+			t.Fatal(err)
+		}
+		j, err := json.Marshal(contracts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := strings.Contains(string(j), `"source_map":`); got != sourceMap {
+			t.Errorf("with SourceMap %t, got JSON %s", sourceMap, j)
+		}
+	}
+}
+
+func TestDescribePC(t *testing.T) {
+	const src = `
+contract Between(low, high: Integer) locks value {
+  clause check(x: Integer) {
+    verify x > low
+    verify x < high
+    unlock value
+  }
+}`
+	contracts, err := CompileWithOptions(strings.NewReader(src), Options{SourceMap: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := contracts[0]
+
+	// Run the body by itself, with the clause argument x below the
+	// contract arguments.
+	err = vm.Verify(&vm.Context{VMVersion: 1, Code: c.Body, Arguments: [][]byte{vm.Int64Bytes(1), vm.Int64Bytes(100), vm.Int64Bytes(10)}})
+	vmErr, ok := err.(vm.Error)
+	if !ok || vmErr.Err != vm.ErrVerifyFailed {
+		t.Fatalf("got error %v, want %s", err, vm.ErrVerifyFailed)
+	}
+	got, err := DescribePC([]byte(src), c, vmErr.PC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const want = `line 4, col 4, clause "check": verify x > low`
+	if got != want {
+		t.Errorf("DescribePC(%d) = %q want %q", vmErr.PC, got, want)
+	}
+}
@@ -47,6 +47,61 @@ var types = map[string]typeDesc{
 	string(sha256PubkeyType): sha256PubkeyType,
 }
 
+func isNumeric(t typeDesc) bool {
+	return t == amountType || t == intType || t == timeType
+}
+
+// numericResult is the type of "l op r" for a binary operator
+// declared on Integers (see binaryOps), or nilType if the operand
+// types don't combine under op. Plain Integers combine with anything
+// numeric, but Amounts and Times never mix, and only Amounts and
+// Integers can be scaled.
+func numericResult(op string, l, r typeDesc) typeDesc {
+	if !isNumeric(l) || !isNumeric(r) {
+		return nilType
+	}
+	switch op {
+	case ">", "<", ">=", "<=":
+		if l == r || l == intType || r == intType {
+			return boolType
+		}
+	case "+":
+		switch {
+		case l == intType:
+			return r
+		case r == intType:
+			return l
+		case l == amountType && r == amountType:
+			return amountType
+		}
+	case "-":
+		switch {
+		case r == intType:
+			return l
+		case l == amountType && r == amountType:
+			return amountType
+		case l == timeType && r == timeType:
+			return intType
+		}
+	case "*":
+		switch {
+		case l == intType && r != timeType:
+			return r
+		case r == intType && l != timeType:
+			return l
+		}
+	case "/", "%":
+		if r == intType && l != timeType {
+			return l
+		}
+	case "<<", ">>":
+		if l == intType && r == intType {
+			return intType
+		}
+	}
+	return nilType
+}
+
 func isHashSubtype(t typeDesc) bool {
 	switch t {
 	case sha3StrType, sha3PubkeyType, sha256StrType, sha256PubkeyType:
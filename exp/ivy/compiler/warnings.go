@@ -0,0 +1,26 @@
+package compiler
+
+// contractWarnings looks for likely mistakes in contract, which has
+// compiled without errors.
+func contractWarnings(contract *Contract) []Warning {
+	var warnings []Warning
+	for _, clause := range contract.Clauses {
+		// A false verify outside any if statement rules out the whole
+		// clause.
+		for _, s := range clause.statements {
+			if stmt, ok := s.(*verifyStatement); ok {
+				if val, ok := foldBool(stmt.expr); ok && !val {
+					warnings = append(warnings, warningAt(stmt.pos, "clause \"%s\" can never be used: %s is always false", clause.Name, stmt.expr))
+				}
+			}
+		}
+		walkStatements(clause.statements, func(s statement) {
+			if stmt, ok := s.(*verifyStatement); ok {
+				if val, ok := foldBool(stmt.expr); ok && val {
+					warnings = append(warnings, warningAt(stmt.pos, "verify statement in clause \"%s\" has no effect: %s is always true", clause.Name, stmt.expr))
+				}
+			}
+		})
+	}
+	return warnings
+}
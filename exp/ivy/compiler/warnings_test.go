@@ -0,0 +1,100 @@
+package compiler
+
+import (
+	"strings"
+	"testing"
+
+	"chain/exp/ivy/compiler/ivytest"
+	"chain/testutil"
+)
+
+func TestWarnings(t *testing.T) {
+	cases := []struct {
+		name     string
+		contract string
+		want     []Warning
+	}{
+		{
+			"AlwaysTrue",
+			`
+contract Pointless(key: PublicKey) locks value {
+  clause spend(sig: Signature, n: Integer) {
+    verify n == n
+    verify 2 * 3 >= 6
+    if n > 0 {
+      verify 1 < 2
+    }
+    verify checkTxSig(key, sig)
+    unlock value
+  }
+}`,
+			[]Warning{
+				{4, 4, `verify statement in clause "spend" has no effect: (n == n) is always true`},
+				{5, 4, `verify statement in clause "spend" has no effect: ((2 * 3) >= 6) is always true`},
+				{7, 6, `verify statement in clause "spend" has no effect: (1 < 2) is always true`},
+			},
+		},
+		{
+			"Unreachable",
+			`
+contract Stuck(key: PublicKey) locks value {
+  clause spend(sig: Signature) {
+    verify checkTxSig(key, sig)
+    unlock value
+  }
+  clause never(n: Integer) {
+    verify n != n
+    unlock value
+  }
+}`,
+			[]Warning{
+				{8, 4, `clause "never" can never be used: (n != n) is always false`},
+			},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			contracts, err := Compile(strings.NewReader(c.contract))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := contracts[0].Warnings; !testutil.DeepEqual(got, c.want) {
+				t.Errorf("got  %v\nwant %v", got, c.want)
+			}
+		})
+	}
+}
+
+func TestNoWarnings(t *testing.T) {
+	srcs := []string{
+		ivytest.TrivialLock,
+		ivytest.LockWithPublicKey,
+		ivytest.LockWithPKHash,
+		ivytest.LockWith2of3Keys,
+		ivytest.LockToOutput,
+		ivytest.TradeOffer,
+		ivytest.EscrowedTransfer,
+		ivytest.CollateralizedLoan,
+		ivytest.RevealPreimage,
+		ivytest.CallOptionWithSettlement,
+		ivytest.PriceChanger,
+		ivytest.OneTwo,
+		ivytest.DeadlineTransfer,
+		ivytest.SignAfterDeadline,
+		ivytest.DoublePrice,
+		ivytest.CappedPayment,
+		ivytest.HandoffVault,
+		ivytest.TwoValueEscrow,
+	}
+	for _, src := range srcs {
+		contracts, err := Compile(strings.NewReader(src))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, c := range contracts {
+			if len(c.Warnings) > 0 {
+				t.Errorf("%s: unexpected warnings %v", c.Name, c.Warnings)
+			}
+		}
+	}
+}
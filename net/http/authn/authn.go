@@ -17,6 +17,13 @@ import (
 
 const tokenExpiry = time.Minute * 5
 
+// ErrTokenType is returned when a request is authenticated
+// with an access token whose type does not permit the
+// requested route: client tokens cannot be used for
+// cross-core RPC, and network tokens can only be used
+// for cross-core RPC.
+var ErrTokenType = errors.New("access token type not permitted for route")
+
 // TODO(kr): This a hack. Please revisit this soon.
 // When compiled without localhost_auth, we want to avoid
 // running the loopback authenticator at all, except for
@@ -34,6 +41,8 @@ type API struct {
 
 type tokenResult struct {
 	valid      bool
+	typ        string
+	scopes     []string
 	lastLookup time.Time
 }
 
@@ -56,12 +65,17 @@ func (a *API) Authenticate(req *http.Request) (*http.Request, error) {
 		authnErrors = append(authnErrors, err.Error())
 	}
 
-	token, err := a.tokenAuthn(req)
+	token, res, err := a.tokenAuthn(req)
 	if err != nil {
 		authnErrors = append(authnErrors, err.Error())
 	} else if token != "" {
+		err = a.checkTokenType(req, res.typ)
+		if err != nil {
+			return req, err
+		}
 		// if this request was successfully authenticated with a token, pass the token along
 		ctx = newContextWithToken(ctx, token)
+		ctx = newContextWithTokenScopes(ctx, res.scopes)
 	}
 
 	local := a.localhostAuthn(req)
@@ -141,38 +155,57 @@ func (a *API) localhostAuthn(req *http.Request) bool {
 	return true
 }
 
-func (a *API) tokenAuthn(req *http.Request) (string, error) {
+// checkTokenType enforces the separation between client
+// and network access tokens. Tokens without a type are
+// governed by authorization grants alone.
+func (a *API) checkTokenType(req *http.Request, typ string) error {
+	rpc := strings.HasPrefix(req.URL.Path, a.crosscoreRPCPrefix)
+	switch {
+	case typ == "client" && rpc:
+		return errors.WithDetailf(ErrTokenType, "client access tokens cannot be used for %s", req.URL.Path)
+	case typ == "network" && !rpc:
+		return errors.WithDetailf(ErrTokenType, "network access tokens cannot be used for %s", req.URL.Path)
+	}
+	return nil
+}
+
+func (a *API) tokenAuthn(req *http.Request) (string, tokenResult, error) {
 	user, pw, ok := req.BasicAuth()
 	if !ok {
-		return "", nil
+		return "", tokenResult{}, nil
 	}
-	return user, a.cachedTokenAuthnCheck(req.Context(), user, pw)
+	res, err := a.cachedTokenAuthnCheck(req.Context(), user, pw)
+	return user, res, err
 }
 
-func (a *API) tokenAuthnCheck(ctx context.Context, user, pw string) (bool, error) {
+func (a *API) tokenAuthnCheck(ctx context.Context, user, pw string) (*accesstoken.Token, error) {
 	pwBytes, err := hex.DecodeString(pw)
 	if err != nil {
-		return false, nil
+		return nil, nil
 	}
-	return a.tokens.Check(ctx, user, pwBytes)
+	return a.tokens.Lookup(ctx, user, pwBytes)
 }
 
-func (a *API) cachedTokenAuthnCheck(ctx context.Context, user, pw string) error {
+func (a *API) cachedTokenAuthnCheck(ctx context.Context, user, pw string) (tokenResult, error) {
 	a.tokenMu.Lock()
 	res, ok := a.tokenMap[user+pw]
 	a.tokenMu.Unlock()
 	if !ok || time.Now().After(res.lastLookup.Add(tokenExpiry)) {
-		valid, err := a.tokenAuthnCheck(ctx, user, pw)
+		token, err := a.tokenAuthnCheck(ctx, user, pw)
 		if err != nil {
-			return errors.Wrap(err)
+			return tokenResult{}, errors.Wrap(err)
+		}
+		res = tokenResult{valid: token != nil, lastLookup: time.Now()}
+		if token != nil {
+			res.typ = token.Type
+			res.scopes = token.Scopes
 		}
-		res = tokenResult{valid: valid, lastLookup: time.Now()}
 		a.tokenMu.Lock()
 		a.tokenMap[user+pw] = res
 		a.tokenMu.Unlock()
 	}
 	if !res.valid {
-		return fmt.Errorf("invalid token: %q", user)
+		return tokenResult{}, fmt.Errorf("invalid token: %q", user)
 	}
-	return nil
+	return res, nil
 }
@@ -9,6 +9,7 @@ type key int
 
 const (
 	tokenKey key = iota
+	tokenScopesKey
 	localhostKey
 	x509CertsKey
 )
@@ -33,6 +34,19 @@ func Token(ctx context.Context) string {
 	return t
 }
 
+// newContextWithTokenScopes sets the scopes of the request's
+// access token in a new context and returns the context.
+func newContextWithTokenScopes(ctx context.Context, scopes []string) context.Context {
+	return context.WithValue(ctx, tokenScopesKey, scopes)
+}
+
+// TokenScopes returns the scopes of the access token stored
+// in the context, if there are any.
+func TokenScopes(ctx context.Context) []string {
+	s, _ := ctx.Value(tokenScopesKey).([]string)
+	return s
+}
+
 // newContextWithLocalhost sets the localhost flag to `true` in a new context
 // and returns that context.
 func newContextWithLocalhost(ctx context.Context) context.Context {
@@ -20,12 +20,19 @@ type Loader interface {
 type Authorizer struct {
 	loader   Loader
 	policies map[string][]string // by route
+	scopes   map[string][]string // by access token scope
 }
 
-func NewAuthorizer(l Loader, policyMap map[string][]string) *Authorizer {
+// NewAuthorizer returns an Authorizer that grants access to each
+// route in policyMap according to the grants for its policies.
+// Requests authenticated with an access token carrying a scope in
+// scopeMap are further restricted to routes that permit at least
+// one of the scope's policies.
+func NewAuthorizer(l Loader, policyMap, scopeMap map[string][]string) *Authorizer {
 	return &Authorizer{
 		loader:   l,
 		policies: policyMap,
+		scopes:   scopeMap,
 	}
 }
 
@@ -35,6 +42,12 @@ func (a *Authorizer) Authorize(req *http.Request) error {
 		return errors.Wrap(err)
 	}
 
+	for _, scope := range authn.TokenScopes(req.Context()) {
+		if !intersects(a.scopes[scope], policies) {
+			return errors.WithDetailf(ErrNotAuthorized, "access token scope %q does not permit %s", scope, req.URL.Path)
+		}
+	}
+
 	grants, err := a.loader.Load(req.Context(), policies)
 	if err != nil {
 		return errors.Wrap(err)
@@ -71,6 +84,17 @@ func authorized(ctx context.Context, grants []*Grant) bool {
 	return false
 }
 
+func intersects(a, b []string) bool {
+	for _, x := range a {
+		for _, y := range b {
+			if x == y {
+				return true
+			}
+		}
+	}
+	return false
+}
+
 func accessTokenGuardData(grant *Grant) string {
 	var v struct{ ID string }
 	json.Unmarshal(grant.GuardData, &v) // ignore error, returns "" on failure
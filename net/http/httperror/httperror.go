@@ -31,6 +31,22 @@ type Response struct {
 	Detail    string                 `json:"detail,omitempty"`
 	Data      map[string]interface{} `json:"data,omitempty"`
 	Temporary bool                   `json:"temporary"`
+
+	// Retriable reports whether the request failed only because
+	// the server couldn't handle it at the time: it timed out,
+	// was rate limited, or the server was unavailable. The same
+	// request may succeed if made again later.
+	Retriable bool `json:"retriable"`
+}
+
+// retriable reports whether an error with the
+// given HTTP status is Retriable.
+func retriable(status int) bool {
+	switch status {
+	case http.StatusRequestTimeout, http.StatusTooManyRequests, http.StatusServiceUnavailable:
+		return true
+	}
+	return false
 }
 
 // Parse reads an error Response from the provided reader.
@@ -52,7 +68,10 @@ type Formatter struct {
 }
 
 // Format builds an error Response body describing err by consulting
-// the f.Errors lookup table. If no entry is found, it returns f.Default.
+// the f.Errors lookup table. If no entry is found, it returns f.Default
+// without any detail or data from err, so that internal error
+// information is not exposed to clients. Such errors are still
+// described in full by Log.
 func (f Formatter) Format(err error) (body Response) {
 	root := errors.Root(err)
 	// Some types cannot be used as map keys, for example slices.
@@ -60,12 +79,13 @@ func (f Formatter) Format(err error) (body Response) {
 	// Just treat it like any other missing entry.
 	defer func() {
 		if err := recover(); err != nil {
-			body = Response{f.Default, "", nil, true}
+			body = Response{Info: f.Default, Temporary: true, Retriable: retriable(f.Default.HTTPStatus)}
 		}
 	}()
 	info, ok := f.Errors[root]
 	if !ok {
-		info = f.Default
+		temp := f.IsTemporary(f.Default, err)
+		return Response{Info: f.Default, Temporary: temp, Retriable: retriable(f.Default.HTTPStatus)}
 	}
 
 	body = Response{
@@ -73,6 +93,7 @@ func (f Formatter) Format(err error) (body Response) {
 		Detail:    errors.Detail(err),
 		Data:      errors.Data(err),
 		Temporary: f.IsTemporary(info, err),
+		Retriable: retriable(info.HTTPStatus),
 	}
 	return body
 }
@@ -102,6 +123,9 @@ func (f Formatter) Log(ctx context.Context, err error) {
 		"chaincode", resp.ChainCode,
 		log.KeyError, errorMessage,
 	}
+	if detail := errors.Detail(err); detail != "" {
+		keyvals = append(keyvals, "detail", detail)
+	}
 	if resp.HTTPStatus == 500 {
 		keyvals = append(keyvals, log.KeyStack, errors.Stack(err))
 	}
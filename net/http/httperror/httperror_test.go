@@ -50,6 +50,23 @@ func TestInfo(t *testing.T) {
 	}
 }
 
+func TestFormatDetail(t *testing.T) {
+	cases := []struct {
+		err        error
+		wantDetail string
+	}{
+		{errors.WithDetail(errNotFound, "no such thing"), "no such thing"},
+		{errors.WithDetail(errors.New("internal"), "connection string"), ""},
+	}
+
+	for _, test := range cases {
+		resp := testFormatter.Format(test.err)
+		if resp.Detail != test.wantDetail {
+			t.Errorf("Format(%#v).Detail = %q want %q", test.err, resp.Detail, test.wantDetail)
+		}
+	}
+}
+
 func TestLogSkip(t *testing.T) {
 	var buf bytes.Buffer
 	log.SetOutput(&buf)
@@ -73,3 +90,32 @@ func TestLogSkip(t *testing.T) {
 	}
 	t.Log(logStr)
 }
+
+func TestFormatRetriable(t *testing.T) {
+	errUnavailable := errors.New("unavailable")
+	f := Formatter{
+		Default:     Info{500, "CH000", "Internal server error"},
+		IsTemporary: func(Info, error) bool { return true },
+		Errors: map[error]Info{
+			errNotFound:    {400, "CH002", "Not found"},
+			errUnavailable: {503, "CH008", "Unavailable"},
+		},
+	}
+	cases := []struct {
+		err  error
+		want bool
+	}{
+		{nil, false},
+		{errNotFound, false},
+		{errors.Wrap(errUnavailable), true},
+	}
+	for _, test := range cases {
+		resp := f.Format(test.err)
+		if resp.Retriable != test.want {
+			t.Errorf("Format(%#v).Retriable = %t want %t", test.err, resp.Retriable, test.want)
+		}
+		if !resp.Temporary {
+			t.Errorf("Format(%#v).Temporary = false want true", test.err)
+		}
+	}
+}
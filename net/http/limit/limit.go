@@ -1,41 +1,125 @@
 package limit
 
 import (
+	"context"
+	"math"
 	"net/http"
+	"strconv"
 	"sync"
+	"time"
 
 	"golang.org/x/time/rate"
 )
 
+// overrideTTL is how long a bucket created from an
+// OverrideFunc is used before the override is looked up again.
+const overrideTTL = time.Minute
+
+// An OverrideFunc returns the request rate and burst to use
+// for id in place of the BucketLimiter's defaults. It returns
+// false if id has no override.
+type OverrideFunc func(ctx context.Context, id string) (freq, burst int, ok bool)
+
 type BucketLimiter struct {
 	freq  rate.Limit
 	burst int
 
+	// Override, if set, is consulted before a new bucket is
+	// created and periodically after that.
+	// It must be set before the first call to Allow or Reserve.
+	Override OverrideFunc
+
 	bucketMu sync.Mutex // protects the following
-	buckets  map[string]*rate.Limiter
+	buckets  map[string]*bucket
 }
 
+type bucket struct {
+	lim     *rate.Limiter
+	expires time.Time // zero if never
+}
+
+// NewBucketLimiter returns a BucketLimiter that allows freq
+// requests per second per id, with bursts of up to burst
+// requests. A freq of 0 means no limit.
 func NewBucketLimiter(freq, burst int) *BucketLimiter {
 	return &BucketLimiter{
-		freq:    rate.Limit(freq),
+		freq:    limitOf(freq),
 		burst:   burst,
-		buckets: make(map[string]*rate.Limiter),
+		buckets: make(map[string]*bucket),
 	}
 }
 
 func (b *BucketLimiter) Allow(id string) bool {
-	return b.bucket(id).Allow()
+	ok, _ := b.Reserve(context.Background(), id)
+	return ok
+}
+
+// Reserve reports whether a request for id may proceed now.
+// If not, it also returns how long the caller should wait
+// before trying again.
+func (b *BucketLimiter) Reserve(ctx context.Context, id string) (bool, time.Duration) {
+	r := b.bucket(ctx, id).Reserve()
+	if !r.OK() {
+		return false, rate.InfDuration
+	}
+	d := r.Delay()
+	if d > 0 {
+		r.Cancel()
+		return false, d
+	}
+	return true, 0
 }
 
-func (b *BucketLimiter) bucket(id string) *rate.Limiter {
+// Forget discards the bucket for id, so that the next request
+// for id starts with a full bucket and a fresh lookup
+// of its override.
+func (b *BucketLimiter) Forget(id string) {
 	b.bucketMu.Lock()
-	bucket, ok := b.buckets[id]
-	if !ok {
-		bucket = rate.NewLimiter(b.freq, b.burst)
-		b.buckets[id] = bucket
+	delete(b.buckets, id)
+	b.bucketMu.Unlock()
+}
+
+func (b *BucketLimiter) bucket(ctx context.Context, id string) *rate.Limiter {
+	b.bucketMu.Lock()
+	bk, ok := b.buckets[id]
+	if ok && (bk.expires.IsZero() || time.Now().Before(bk.expires)) {
+		b.bucketMu.Unlock()
+		return bk.lim
 	}
 	b.bucketMu.Unlock()
-	return bucket
+
+	freq, burst := b.freq, b.burst
+	var expires time.Time
+	if b.Override != nil {
+		// Don't hold bucketMu during the lookup;
+		// it may be slow.
+		if f, bu, ok := b.Override(ctx, id); ok {
+			freq, burst = limitOf(f), bu
+		}
+		expires = time.Now().Add(overrideTTL)
+	}
+
+	b.bucketMu.Lock()
+	defer b.bucketMu.Unlock()
+	if cur := b.buckets[id]; cur != nil && cur != bk {
+		// Someone else refreshed it first.
+		return cur.lim
+	}
+	if bk != nil && bk.lim.Limit() == freq && bk.lim.Burst() == burst {
+		// Keep the tokens accumulated so far.
+		bk.expires = expires
+		return bk.lim
+	}
+	bk = &bucket{lim: rate.NewLimiter(freq, burst), expires: expires}
+	b.buckets[id] = bk
+	return bk.lim
+}
+
+func limitOf(freq int) rate.Limit {
+	if freq == 0 {
+		return rate.Inf
+	}
+	return rate.Limit(freq)
 }
 
 type handler struct {
@@ -47,23 +131,44 @@ type handler struct {
 }
 
 func Handler(next, limited http.Handler, freq, burst int, f func(*http.Request) string) http.Handler {
+	return LimiterHandler(next, limited, NewBucketLimiter(freq, burst), f)
+}
+
+// LimiterHandler is like Handler, but uses the provided
+// BucketLimiter, which may have an Override set.
+func LimiterHandler(next, limited http.Handler, l *BucketLimiter, f func(*http.Request) string) http.Handler {
 	return &handler{
 		next:    next,
 		limited: limited,
 		f:       f,
-		limiter: NewBucketLimiter(freq, burst),
+		limiter: l,
 	}
 }
 
 func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	id := h.f(r)
-	if !h.limiter.Allow(id) {
-		h.limited.ServeHTTP(w, r)
+	if ok, wait := h.limiter.Reserve(r.Context(), id); !ok {
+		secs := int64(math.Ceil(wait.Seconds()))
+		w.Header().Set("Retry-After", strconv.FormatInt(secs, 10))
+		ctx := context.WithValue(r.Context(), retryAfterKey, wait)
+		h.limited.ServeHTTP(w, r.WithContext(ctx))
 		return
 	}
 	h.next.ServeHTTP(w, r)
 }
 
+type key int
+
+const retryAfterKey key = 0
+
+// RetryAfter returns how long a rate-limited request should
+// wait before retrying. It is only set in the context of
+// requests passed to the limited handler.
+func RetryAfter(ctx context.Context) (time.Duration, bool) {
+	d, ok := ctx.Value(retryAfterKey).(time.Duration)
+	return d, ok
+}
+
 func RemoteAddrID(r *http.Request) string {
 	return r.RemoteAddr
 }
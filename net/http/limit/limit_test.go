@@ -0,0 +1,91 @@
+package limit
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestOverrideConcurrent(t *testing.T) {
+	l := NewBucketLimiter(1000, 1000)
+	l.Override = func(ctx context.Context, id string) (int, int, bool) {
+		if id == "low" {
+			return 1, 5, true
+		}
+		return 0, 0, false
+	}
+
+	var served, limited [2]int64
+	ids := []string{"low", "high"}
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(&served[index(r)], 1)
+	})
+	throttled := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := RetryAfter(r.Context()); !ok {
+			t.Error("missing retry-after in limited request context")
+		}
+		if w.Header().Get("Retry-After") == "" {
+			t.Error("missing Retry-After header")
+		}
+		atomic.AddInt64(&limited[index(r)], 1)
+	})
+	h := LimiterHandler(next, throttled, l, func(r *http.Request) string {
+		return r.Header.Get("X-ID")
+	})
+
+	const perToken = 50
+	var wg sync.WaitGroup
+	for _, id := range ids {
+		for i := 0; i < perToken; i++ {
+			wg.Add(1)
+			go func(id string) {
+				defer wg.Done()
+				req := httptest.NewRequest("POST", "/info", nil)
+				req.Header.Set("X-ID", id)
+				h.ServeHTTP(httptest.NewRecorder(), req)
+			}(id)
+		}
+	}
+	wg.Wait()
+
+	if limited[1] != 0 {
+		t.Errorf("high-limit token throttled %d times, want 0", limited[1])
+	}
+	if served[1] != perToken {
+		t.Errorf("high-limit token served %d times, want %d", served[1], perToken)
+	}
+	if served[0] > 6 || limited[0] < perToken-6 {
+		t.Errorf("low-limit token served %d, throttled %d; want at most 6 served", served[0], limited[0])
+	}
+}
+
+func TestForget(t *testing.T) {
+	lim := 1
+	l := NewBucketLimiter(0, 0)
+	l.Override = func(ctx context.Context, id string) (int, int, bool) {
+		return lim, 1, true
+	}
+	if !l.Allow("a") {
+		t.Fatal("first request not allowed")
+	}
+	if l.Allow("a") {
+		t.Fatal("second request allowed, want limited")
+	}
+	lim = 0 // unlimited
+	l.Forget("a")
+	for i := 0; i < 10; i++ {
+		if !l.Allow("a") {
+			t.Fatalf("request %d limited after raising limit", i)
+		}
+	}
+}
+
+func index(r *http.Request) int {
+	if r.Header.Get("X-ID") == "low" {
+		return 0
+	}
+	return 1
+}
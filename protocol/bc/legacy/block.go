@@ -6,6 +6,7 @@ import (
 	"encoding/hex"
 	"fmt"
 	"io"
+	"io/ioutil"
 
 	"chain/encoding/blockchain"
 	"chain/encoding/bufpool"
@@ -123,6 +124,16 @@ func (b *Block) WriteTo(w io.Writer) (int64, error) {
 	return ew.Written(), ew.Err()
 }
 
+// SerializedSize returns the number of bytes in the serialized
+// block, leaving out the block witness. Signers add the witness
+// after the block is generated, and its size depends on the
+// consensus program, not on the block's contents.
+func (b *Block) SerializedSize() int {
+	ew := errors.NewWriter(ioutil.Discard)
+	b.writeTo(ew, SerBlockTransactions)
+	return int(ew.Written())
+}
+
 // assumes w has sticky errors
 func (b *Block) writeTo(w io.Writer, serflags uint8) {
 	b.BlockHeader.writeTo(w, serflags)
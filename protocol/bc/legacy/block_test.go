@@ -169,3 +169,105 @@ func TestSmallBlock(t *testing.T) {
 		t.Errorf("small block bytes = %x want %x", got, want)
 	}
 }
+
+func TestBlockSerializedSize(t *testing.T) {
+	block := Block{
+		BlockHeader: BlockHeader{
+			Version: 1,
+			Height:  1,
+		},
+		Transactions: []*Tx{NewTx(TxData{Version: CurrentTransactionVersion})},
+	}
+
+	full := serialize(t, &block)
+	const witnessLen = 2 // extensible string length, num witness args
+	want := len(full) - witnessLen
+	if got := block.SerializedSize(); got != want {
+		t.Errorf("SerializedSize() = %d want %d", got, want)
+	}
+
+	block.Witness = [][]byte{{1, 2, 3}}
+	if got := block.SerializedSize(); got != want {
+		t.Errorf("SerializedSize() with witness = %d want %d", got, want)
+	}
+}
+
+func TestJSONBlockRoundTrip(t *testing.T) {
+	header := BlockHeader{
+		Version:           1,
+		Height:            432234,
+		PreviousBlockHash: mustDecodeHash("c34048bd60c4c13144fd34f408627d1be68f6cb4fdd34e879d6d791060ea73a0"),
+		TimestampMS:       1492590000123,
+		BlockCommitment: BlockCommitment{
+			TransactionsMerkleRoot: mustDecodeHash("ad9ac003d08ff305181a345d64fe0b02311cc1a6ec04ab73f3318d90139bfe03"),
+			AssetsMerkleRoot:       mustDecodeHash("a4a9d0ad1e6bb4d5ed7e9e2a33e8fd6271c5a2a0a2cb4c89e7ffbaf1b6e4d43f"),
+			ConsensusProgram:       []byte{0xae, 0x51},
+		},
+		BlockWitness: BlockWitness{Witness: [][]byte{{0x01, 0x02}, {}}},
+	}
+	cases := []struct {
+		name  string
+		block Block
+	}{
+		{"empty", Block{BlockHeader: header}},
+		{"with transactions", Block{
+			BlockHeader: header,
+			Transactions: []*Tx{
+				NewTx(TxData{Version: CurrentTransactionVersion}),
+				NewTx(TxData{
+					Version: 1,
+					MaxTime: 1492590060000,
+					Outputs: []*TxOutput{
+						NewTxOutput(bc.AssetID{}, 1, []byte{0x51}, nil),
+					},
+				}),
+			},
+		}},
+	}
+	for _, c := range cases {
+		data, err := json.Marshal((*JSONBlock)(&c.block))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var fields map[string]interface{}
+		err = json.Unmarshal(data, &fields)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := fields["hash"], hex.EncodeToString(c.block.Hash().Bytes()); got != want {
+			t.Errorf("%s: hash = %v want %s", c.name, got, want)
+		}
+		if got, want := fields["timestamp"], "2017-04-19T08:20:00.123Z"; got != want {
+			t.Errorf("%s: timestamp = %v want %s", c.name, got, want)
+		}
+		if got, want := fields["consensus_program"], "ae51"; got != want {
+			t.Errorf("%s: consensus_program = %v want %s", c.name, got, want)
+		}
+
+		var got JSONBlock
+		err = json.Unmarshal(data, &got)
+		if err != nil {
+			t.Fatalf("%s: %s in %s", c.name, err, data)
+		}
+		if h := (*Block)(&got).Hash(); h != c.block.Hash() {
+			t.Errorf("%s: decoded block hash = %x want %x", c.name, h.Bytes(), c.block.Hash().Bytes())
+		}
+		if !bytes.Equal(serialize(t, (*Block)(&got)), serialize(t, &c.block)) {
+			t.Errorf("%s: decoded block = %s want %s", c.name, spew.Sdump(got), spew.Sdump(c.block))
+		}
+		for i, tx := range got.Transactions {
+			if tx.ID != c.block.Transactions[i].ID {
+				t.Errorf("%s: tx %d ID = %x want %x", c.name, i, tx.ID.Bytes(), c.block.Transactions[i].ID.Bytes())
+			}
+		}
+
+		var gotHeader JSONBlockHeader
+		err = json.Unmarshal(data, &gotHeader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if h := (*BlockHeader)(&gotHeader).Hash(); h != c.block.Hash() {
+			t.Errorf("%s: decoded header hash = %x want %x", c.name, h.Bytes(), c.block.Hash().Bytes())
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package legacy
+
+import "chain/protocol/bc"
+
+// Copy returns a deep copy of tx. The copy shares no byte
+// slices or pointers with tx, so either can be changed
+// without affecting the other.
+func (tx *TxData) Copy() TxData {
+	c := *tx
+	c.CommonFieldsSuffix = copyBytes(tx.CommonFieldsSuffix)
+	c.CommonWitnessSuffix = copyBytes(tx.CommonWitnessSuffix)
+	c.ReferenceData = copyBytes(tx.ReferenceData)
+	if tx.Inputs != nil {
+		c.Inputs = make([]*TxInput, 0, len(tx.Inputs))
+		for _, in := range tx.Inputs {
+			c.Inputs = append(c.Inputs, in.copy())
+		}
+	}
+	if tx.Outputs != nil {
+		c.Outputs = make([]*TxOutput, 0, len(tx.Outputs))
+		for _, out := range tx.Outputs {
+			c.Outputs = append(c.Outputs, out.copy())
+		}
+	}
+	return c
+}
+
+// Copy returns a deep copy of tx. See TxData.Copy.
+func (tx *Tx) Copy() *Tx {
+	return NewTx(tx.TxData.Copy())
+}
+
+func (t *TxInput) copy() *TxInput {
+	c := *t
+	c.ReferenceData = copyBytes(t.ReferenceData)
+	c.CommitmentSuffix = copyBytes(t.CommitmentSuffix)
+	c.WitnessSuffix = copyBytes(t.WitnessSuffix)
+	switch inp := t.TypedInput.(type) {
+	case *IssuanceInput:
+		ii := *inp
+		ii.Nonce = copyBytes(inp.Nonce)
+		if inp.strippedAssetID != nil {
+			assetID := *inp.strippedAssetID
+			ii.strippedAssetID = &assetID
+		}
+		ii.AssetDefinition = copyBytes(inp.AssetDefinition)
+		ii.IssuanceProgram = copyBytes(inp.IssuanceProgram)
+		ii.Arguments = copyByteSlices(inp.Arguments)
+		c.TypedInput = &ii
+	case *SpendInput:
+		si := *inp
+		si.AssetAmount = copyAssetAmount(inp.AssetAmount)
+		si.ControlProgram = copyBytes(inp.ControlProgram)
+		si.SpendCommitmentSuffix = copyBytes(inp.SpendCommitmentSuffix)
+		si.Arguments = copyByteSlices(inp.Arguments)
+		c.TypedInput = &si
+	}
+	return &c
+}
+
+func (to *TxOutput) copy() *TxOutput {
+	c := *to
+	c.AssetAmount = copyAssetAmount(to.AssetAmount)
+	c.ControlProgram = copyBytes(to.ControlProgram)
+	c.CommitmentSuffix = copyBytes(to.CommitmentSuffix)
+	c.WitnessSuffix = copyBytes(to.WitnessSuffix)
+	c.ReferenceData = copyBytes(to.ReferenceData)
+	return &c
+}
+
+func copyAssetAmount(a bc.AssetAmount) bc.AssetAmount {
+	if a.AssetId != nil {
+		assetID := *a.AssetId
+		a.AssetId = &assetID
+	}
+	return a
+}
+
+func copyBytes(b []byte) []byte {
+	if b == nil {
+		return nil
+	}
+	return append([]byte{}, b...)
+}
+
+func copyByteSlices(bs [][]byte) [][]byte {
+	if bs == nil {
+		return nil
+	}
+	c := make([][]byte, 0, len(bs))
+	for _, b := range bs {
+		c = append(c, copyBytes(b))
+	}
+	return c
+}
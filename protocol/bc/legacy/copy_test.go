@@ -0,0 +1,95 @@
+package legacy
+
+import (
+	"bytes"
+	"testing"
+
+	"chain/protocol/bc"
+	"chain/testutil"
+)
+
+func TestTxCopy(t *testing.T) {
+	orig := NewTx(TxData{
+		Version: 1,
+		Inputs: []*TxInput{
+			NewSpendInput([][]byte{{1}, {2, 3}}, bc.Hash{V0: 1}, bc.AssetID{V0: 2}, 5, 0, []byte{0x51}, bc.Hash{}, []byte("spend")),
+			NewIssuanceInput([]byte{4}, 6, []byte("issue"), bc.Hash{V0: 3}, []byte{0x51}, [][]byte{{5}}, []byte("{}")),
+		},
+		Outputs: []*TxOutput{
+			NewTxOutput(bc.AssetID{V0: 2}, 5, []byte{0x51}, []byte("output")),
+		},
+		ReferenceData:       []byte("tx"),
+		CommonFieldsSuffix:  []byte{6},
+		CommonWitnessSuffix: []byte{7},
+	})
+	orig.Inputs[0].CommitmentSuffix = []byte{8}
+	orig.Inputs[0].WitnessSuffix = []byte{9}
+	orig.Inputs[0].TypedInput.(*SpendInput).SpendCommitmentSuffix = []byte{10}
+	orig.Outputs[0].CommitmentSuffix = []byte{11}
+	orig.Outputs[0].WitnessSuffix = []byte{12}
+
+	var before bytes.Buffer
+	_, err := orig.WriteTo(&before)
+	if err != nil {
+		t.Fatal(err)
+	}
+	origID := orig.ID
+
+	c := orig.Copy()
+	if !testutil.DeepEqual(c.TxData, orig.TxData) {
+		t.Fatalf("copy = %+v want %+v", c.TxData, orig.TxData)
+	}
+	if c.ID != origID {
+		t.Errorf("copy ID = %x want %x", c.ID.Bytes(), origID.Bytes())
+	}
+
+	// Change every byte and pointer the copy holds.
+	flip := func(b []byte) {
+		for i := range b {
+			b[i] ^= 0xff
+		}
+	}
+	flip(c.ReferenceData)
+	flip(c.CommonFieldsSuffix)
+	flip(c.CommonWitnessSuffix)
+	for _, in := range c.Inputs {
+		flip(in.ReferenceData)
+		flip(in.CommitmentSuffix)
+		flip(in.WitnessSuffix)
+		switch inp := in.TypedInput.(type) {
+		case *IssuanceInput:
+			flip(inp.Nonce)
+			flip(inp.AssetDefinition)
+			flip(inp.IssuanceProgram)
+			for _, arg := range inp.Arguments {
+				flip(arg)
+			}
+		case *SpendInput:
+			inp.AssetId.V0++
+			flip(inp.ControlProgram)
+			flip(inp.SpendCommitmentSuffix)
+			for _, arg := range inp.Arguments {
+				flip(arg)
+			}
+		}
+	}
+	for _, out := range c.Outputs {
+		out.AssetId.V0++
+		flip(out.ControlProgram)
+		flip(out.CommitmentSuffix)
+		flip(out.WitnessSuffix)
+		flip(out.ReferenceData)
+	}
+
+	var after bytes.Buffer
+	_, err = orig.WriteTo(&after)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(after.Bytes(), before.Bytes()) {
+		t.Error("changing the copy changed the original")
+	}
+	if got := NewTx(orig.TxData).ID; got != origID {
+		t.Errorf("original ID = %x want %x", got.Bytes(), origID.Bytes())
+	}
+}
@@ -1,6 +1,10 @@
 package legacy
 
-import "testing"
+import (
+	"encoding/hex"
+	"strings"
+	"testing"
+)
 
 func TestFuzzUnknownAssetVersion(t *testing.T) {
 	const rawTx = `07010700f785c1f1b72b0001f1b72b0001012b00089def834ab929327f3f479177e2d8c293f2f7fc4f251db8547896c0eeafb984261a73767178584c246400b50150935a092ffad7ec9fbac4f4486db6c3b8cd5b9f51cf697248584dde286a722000012b766baa20627e83fdad13dd98436fa7cbdd1412d50ef65528edb7e2ed8f2675b2a0b209235151ad696c00c0030040b984261ad6e71876ec4c2464012b766baa209d44ee5b6ebf6c408772ead7713f1a66b9de7655ff452513487be1fb10de7d985151ad696c00c02a7b2274657374223a225175657279546573742e7465737442616c616e636551756572792e74657374227d`
@@ -26,3 +30,78 @@ func TestFuzzUnknownAssetVersion(t *testing.T) {
 		t.Errorf("tx id changed to %s", got.ID.String())
 	}
 }
+
+func TestFuzzTruncatedTx(t *testing.T) {
+	const rawTx = `07010700f785c1f1b72b0001f1b72b0001012b00089def834ab929327f3f479177e2d8c293f2f7fc4f251db8547896c0eeafb984261a73767178584c246400b50150935a092ffad7ec9fbac4f4486db6c3b8cd5b9f51cf697248584dde286a722000012b766baa20627e83fdad13dd98436fa7cbdd1412d50ef65528edb7e2ed8f2675b2a0b209235151ad696c00c0030040b984261ad6e71876ec4c2464012b766baa209d44ee5b6ebf6c408772ead7713f1a66b9de7655ff452513487be1fb10de7d985151ad696c00c02a7b2274657374223a225175657279546573742e7465737442616c616e636551756572792e74657374227d`
+
+	for n := 0; n < len(rawTx); n += 2 {
+		var tx Tx
+		err := tx.UnmarshalText([]byte(rawTx[:n]))
+		if err == nil {
+			t.Errorf("UnmarshalText(first %d bytes) succeeded, want error", n/2)
+			continue
+		}
+		if n > 0 && !strings.Contains(err.Error(), "at byte") {
+			t.Errorf("UnmarshalText(first %d bytes) error = %q, want byte offset", n/2, err)
+		}
+	}
+
+	var tx Tx
+	err := tx.UnmarshalText([]byte(rawTx + "00"))
+	if err == nil || !strings.Contains(err.Error(), "trailing garbage") {
+		t.Errorf("UnmarshalText(tx with trailing byte) error = %v, want trailing garbage", err)
+	}
+}
+
+func TestFuzzMutatedTx(t *testing.T) {
+	const rawTx = `07010700f785c1f1b72b0001f1b72b0001012b00089def834ab929327f3f479177e2d8c293f2f7fc4f251db8547896c0eeafb984261a73767178584c246400b50150935a092ffad7ec9fbac4f4486db6c3b8cd5b9f51cf697248584dde286a722000012b766baa20627e83fdad13dd98436fa7cbdd1412d50ef65528edb7e2ed8f2675b2a0b209235151ad696c00c0030040b984261ad6e71876ec4c2464012b766baa209d44ee5b6ebf6c408772ead7713f1a66b9de7655ff452513487be1fb10de7d985151ad696c00c02a7b2274657374223a225175657279546573742e7465737442616c616e636551756572792e74657374227d`
+
+	b, err := hex.DecodeString(rawTx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Altered serializations must either fail cleanly or
+	// decode to something that reserializes the same way.
+	for i := range b {
+		for _, mask := range []byte{0x01, 0x80, 0xff} {
+			mutated := append([]byte(nil), b...)
+			mutated[i] ^= mask
+			text := []byte(hex.EncodeToString(mutated))
+
+			var tx Tx
+			err := tx.UnmarshalText(text)
+			if err != nil {
+				continue
+			}
+			got, err := tx.MarshalText()
+			if err != nil {
+				t.Fatal(err)
+			}
+			var tx2 Tx
+			err = tx2.UnmarshalText(got)
+			if err != nil {
+				t.Errorf("byte %d ^ %#x: reserialized tx doesn't decode: %v", i, mask, err)
+			}
+		}
+	}
+}
+
+func TestFuzzHugeCounts(t *testing.T) {
+	cases := []struct {
+		raw  string
+		want string
+	}{
+		// serflags, version, common fields, common witness,
+		// then 2^31-1 inputs
+		{"070102000000ffffffff07", "transaction inputs at byte 6"},
+		// as above, but no inputs and 2^31-1 outputs
+		{"07010200000000ffffffff07", "transaction outputs at byte 7"},
+	}
+	for _, c := range cases {
+		var tx Tx
+		err := tx.UnmarshalText([]byte(c.raw))
+		if err == nil || !strings.Contains(err.Error(), c.want) {
+			t.Errorf("UnmarshalText(%s) error = %v, want %q", c.raw, err, c.want)
+		}
+	}
+}
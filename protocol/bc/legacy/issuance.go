@@ -9,11 +9,11 @@ type IssuanceInput struct {
 	// Commitment
 	Nonce  []byte
 	Amount uint64
-	// Note: as long as we require serflags=0x7, we don't need to
-	// explicitly store the asset ID here even though it's technically
-	// part of the input commitment. We can compute it instead from
-	// values in the witness (which, with serflags other than 0x7,
-	// might not be present).
+	// Note: the asset ID is technically part of the input
+	// commitment, but we compute it instead from values in the
+	// witness. When the input was read without its witness
+	// (see SerNoWitness), it's kept in strippedAssetID.
+	strippedAssetID *bc.AssetID
 
 	// Witness
 	IssuanceWitness
@@ -22,6 +22,9 @@ type IssuanceInput struct {
 func (ii *IssuanceInput) IsIssuance() bool { return true }
 
 func (ii *IssuanceInput) AssetID() bc.AssetID {
+	if ii.strippedAssetID != nil {
+		return *ii.strippedAssetID
+	}
 	defhash := ii.AssetDefinitionHash()
 	return bc.ComputeAssetID(ii.IssuanceProgram, &ii.InitialBlock, ii.VMVersion, &defhash)
 }
@@ -0,0 +1,135 @@
+package legacy
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	chainjson "chain/encoding/json"
+	"chain/protocol/bc"
+)
+
+// JSONBlock is a Block with a structured JSON encoding, for tools
+// that inspect blocks. A Block itself encodes as a hex string of
+// its serialized form, which is what Cores exchange.
+//
+// Hashes and programs appear as hex, and transactions in their
+// usual hex encoding. Fields derived from others, such as the
+// block hash and the timestamp as RFC3339, are ignored when
+// decoding.
+type JSONBlock Block
+
+// JSONBlockHeader is a BlockHeader with a structured JSON
+// encoding. See JSONBlock.
+type JSONBlockHeader BlockHeader
+
+type jsonBlockHeader struct {
+	Hash                   *bc.Hash             `json:"hash,omitempty"`
+	Version                uint64               `json:"version"`
+	Height                 uint64               `json:"height"`
+	PreviousBlockHash      bc.Hash              `json:"previous_block_hash"`
+	Timestamp              *time.Time           `json:"timestamp,omitempty"`
+	TimestampMS            uint64               `json:"timestamp_ms"`
+	TransactionsMerkleRoot bc.Hash              `json:"transactions_merkle_root"`
+	AssetsMerkleRoot       bc.Hash              `json:"assets_merkle_root"`
+	ConsensusProgram       chainjson.HexBytes   `json:"consensus_program"`
+	CommitmentSuffix       chainjson.HexBytes   `json:"commitment_suffix,omitempty"`
+	Witness                []chainjson.HexBytes `json:"witness"`
+	WitnessSuffix          chainjson.HexBytes   `json:"witness_suffix,omitempty"`
+}
+
+type jsonBlockTx struct {
+	ID             *bc.Hash `json:"id,omitempty"`
+	RawTransaction *Tx      `json:"raw_transaction"`
+}
+
+type jsonBlock struct {
+	jsonBlockHeader
+	Transactions []jsonBlockTx `json:"transactions"`
+}
+
+func (bh *JSONBlockHeader) toJSON() jsonBlockHeader {
+	h := (*BlockHeader)(bh).Hash()
+	t := (*BlockHeader)(bh).Time()
+	witness := make([]chainjson.HexBytes, 0, len(bh.Witness))
+	for _, w := range bh.Witness {
+		witness = append(witness, w)
+	}
+	return jsonBlockHeader{
+		Hash:                   &h,
+		Version:                bh.Version,
+		Height:                 bh.Height,
+		PreviousBlockHash:      bh.PreviousBlockHash,
+		Timestamp:              &t,
+		TimestampMS:            bh.TimestampMS,
+		TransactionsMerkleRoot: bh.TransactionsMerkleRoot,
+		AssetsMerkleRoot:       bh.AssetsMerkleRoot,
+		ConsensusProgram:       bh.ConsensusProgram,
+		CommitmentSuffix:       bh.CommitmentSuffix,
+		Witness:                witness,
+		WitnessSuffix:          bh.WitnessSuffix,
+	}
+}
+
+func (j *jsonBlockHeader) blockHeader() BlockHeader {
+	var witness [][]byte
+	for _, w := range j.Witness {
+		witness = append(witness, w)
+	}
+	return BlockHeader{
+		Version:           j.Version,
+		Height:            j.Height,
+		PreviousBlockHash: j.PreviousBlockHash,
+		TimestampMS:       j.TimestampMS,
+		BlockCommitment: BlockCommitment{
+			TransactionsMerkleRoot: j.TransactionsMerkleRoot,
+			AssetsMerkleRoot:       j.AssetsMerkleRoot,
+			ConsensusProgram:       j.ConsensusProgram,
+		},
+		CommitmentSuffix: j.CommitmentSuffix,
+		BlockWitness:     BlockWitness{Witness: witness},
+		WitnessSuffix:    j.WitnessSuffix,
+	}
+}
+
+func (bh *JSONBlockHeader) MarshalJSON() ([]byte, error) {
+	return json.Marshal(bh.toJSON())
+}
+
+func (bh *JSONBlockHeader) UnmarshalJSON(b []byte) error {
+	var j jsonBlockHeader
+	err := json.Unmarshal(b, &j)
+	if err != nil {
+		return err
+	}
+	*bh = JSONBlockHeader(j.blockHeader())
+	return nil
+}
+
+func (b *JSONBlock) MarshalJSON() ([]byte, error) {
+	j := jsonBlock{
+		jsonBlockHeader: (*JSONBlockHeader)(&b.BlockHeader).toJSON(),
+		Transactions:    make([]jsonBlockTx, 0, len(b.Transactions)),
+	}
+	for _, tx := range b.Transactions {
+		j.Transactions = append(j.Transactions, jsonBlockTx{ID: &tx.ID, RawTransaction: tx})
+	}
+	return json.Marshal(j)
+}
+
+func (b *JSONBlock) UnmarshalJSON(data []byte) error {
+	var j jsonBlock
+	err := json.Unmarshal(data, &j)
+	if err != nil {
+		return err
+	}
+	block := JSONBlock{BlockHeader: j.blockHeader()}
+	for i, tx := range j.Transactions {
+		if tx.RawTransaction == nil {
+			return fmt.Errorf("transaction %d has no raw_transaction", i)
+		}
+		block.Transactions = append(block.Transactions, tx.RawTransaction)
+	}
+	*b = block
+	return nil
+}
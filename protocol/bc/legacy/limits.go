@@ -0,0 +1,71 @@
+package legacy
+
+import (
+	"io/ioutil"
+
+	"chain/errors"
+)
+
+// Limits on the parts of a transaction that Core accepts for
+// submission and includes in blocks. They are policy, not
+// consensus rules; blocks from elsewhere may exceed them.
+const (
+	// MaxReferenceDataSize bounds the reference data of the
+	// transaction and of each of its inputs and outputs.
+	MaxReferenceDataSize = 1 << 20
+
+	// MaxWitnessSize bounds the total size of each
+	// input's arguments.
+	MaxWitnessSize = 1 << 20
+
+	// MaxProgramSize bounds each input's control or issuance
+	// program and each output's control program.
+	MaxProgramSize = 1 << 16
+)
+
+// ErrTxLimit is returned by CheckLimits for
+// transactions exceeding a size limit.
+var ErrTxLimit = errors.New("transaction exceeds size limits")
+
+// SerializedSize returns the number of bytes in the
+// serialized transaction.
+func (tx *TxData) SerializedSize() int {
+	n, _ := tx.WriteTo(ioutil.Discard) // error is impossible
+	return int(n)
+}
+
+// CheckLimits returns ErrTxLimit, with a detail message
+// naming the offending part, if tx has reference data,
+// witness or programs bigger than the limits above.
+func (tx *TxData) CheckLimits() error {
+	if len(tx.ReferenceData) > MaxReferenceDataSize {
+		return errors.WithDetailf(ErrTxLimit, "reference data is %d bytes, limit is %d", len(tx.ReferenceData), MaxReferenceDataSize)
+	}
+	for i, in := range tx.Inputs {
+		if len(in.ReferenceData) > MaxReferenceDataSize {
+			return errors.WithDetailf(ErrTxLimit, "input %d reference data is %d bytes, limit is %d", i, len(in.ReferenceData), MaxReferenceDataSize)
+		}
+		var witness int
+		for _, arg := range in.Arguments() {
+			witness += len(arg)
+		}
+		if witness > MaxWitnessSize {
+			return errors.WithDetailf(ErrTxLimit, "input %d arguments are %d bytes, limit is %d", i, witness, MaxWitnessSize)
+		}
+		if n := len(in.ControlProgram()); n > MaxProgramSize {
+			return errors.WithDetailf(ErrTxLimit, "input %d control program is %d bytes, limit is %d", i, n, MaxProgramSize)
+		}
+		if n := len(in.IssuanceProgram()); n > MaxProgramSize {
+			return errors.WithDetailf(ErrTxLimit, "input %d issuance program is %d bytes, limit is %d", i, n, MaxProgramSize)
+		}
+	}
+	for i, out := range tx.Outputs {
+		if len(out.ReferenceData) > MaxReferenceDataSize {
+			return errors.WithDetailf(ErrTxLimit, "output %d reference data is %d bytes, limit is %d", i, len(out.ReferenceData), MaxReferenceDataSize)
+		}
+		if n := len(out.ControlProgram); n > MaxProgramSize {
+			return errors.WithDetailf(ErrTxLimit, "output %d control program is %d bytes, limit is %d", i, n, MaxProgramSize)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,105 @@
+package legacy
+
+import (
+	"bytes"
+	"testing"
+
+	"chain/errors"
+	"chain/protocol/bc"
+)
+
+func TestSerializedSize(t *testing.T) {
+	tx := NewTx(TxData{
+		Version: 1,
+		Inputs: []*TxInput{
+			NewSpendInput([][]byte{{1}, {2}}, bc.Hash{}, bc.AssetID{}, 5, 0, []byte{0x51}, bc.Hash{}, []byte("input")),
+		},
+		Outputs: []*TxOutput{
+			NewTxOutput(bc.AssetID{}, 5, []byte{0x51}, []byte("output")),
+		},
+		ReferenceData: []byte("tx"),
+	})
+	var buf bytes.Buffer
+	_, err := tx.WriteTo(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := tx.SerializedSize(); got != buf.Len() {
+		t.Errorf("SerializedSize() = %d want %d", got, buf.Len())
+	}
+}
+
+func TestCheckLimits(t *testing.T) {
+	spend := func(args [][]byte, prog, refdata []byte) *TxInput {
+		return NewSpendInput(args, bc.Hash{}, bc.AssetID{}, 5, 0, prog, bc.Hash{}, refdata)
+	}
+	big := func(n int) []byte { return make([]byte, n) }
+
+	cases := []struct {
+		name string
+		tx   TxData
+		ok   bool
+	}{
+		{
+			name: "small",
+			tx: TxData{
+				Inputs:        []*TxInput{spend([][]byte{{1}}, []byte{0x51}, nil)},
+				Outputs:       []*TxOutput{NewTxOutput(bc.AssetID{}, 5, []byte{0x51}, nil)},
+				ReferenceData: []byte("hello"),
+			},
+			ok: true,
+		},
+		{
+			name: "reference data at limit",
+			tx:   TxData{ReferenceData: big(MaxReferenceDataSize)},
+			ok:   true,
+		},
+		{
+			name: "reference data over limit",
+			tx:   TxData{ReferenceData: big(MaxReferenceDataSize + 1)},
+		},
+		{
+			name: "input reference data over limit",
+			tx:   TxData{Inputs: []*TxInput{spend(nil, nil, big(MaxReferenceDataSize+1))}},
+		},
+		{
+			name: "output reference data over limit",
+			tx:   TxData{Outputs: []*TxOutput{NewTxOutput(bc.AssetID{}, 5, nil, big(MaxReferenceDataSize+1))}},
+		},
+		{
+			name: "witness at limit",
+			tx:   TxData{Inputs: []*TxInput{spend([][]byte{big(MaxWitnessSize / 2), big(MaxWitnessSize / 2)}, nil, nil)}},
+			ok:   true,
+		},
+		{
+			name: "witness over limit",
+			tx:   TxData{Inputs: []*TxInput{spend([][]byte{big(MaxWitnessSize / 2), big(MaxWitnessSize/2 + 1)}, nil, nil)}},
+		},
+		{
+			name: "control program at limit",
+			tx:   TxData{Inputs: []*TxInput{spend(nil, big(MaxProgramSize), nil)}},
+			ok:   true,
+		},
+		{
+			name: "control program over limit",
+			tx:   TxData{Inputs: []*TxInput{spend(nil, big(MaxProgramSize+1), nil)}},
+		},
+		{
+			name: "issuance program over limit",
+			tx:   TxData{Inputs: []*TxInput{NewIssuanceInput(nil, 5, nil, bc.Hash{}, big(MaxProgramSize+1), nil, nil)}},
+		},
+		{
+			name: "output program over limit",
+			tx:   TxData{Outputs: []*TxOutput{NewTxOutput(bc.AssetID{}, 5, big(MaxProgramSize+1), nil)}},
+		},
+	}
+	for _, c := range cases {
+		err := c.tx.CheckLimits()
+		if c.ok && err != nil {
+			t.Errorf("%s: CheckLimits() = %v want nil", c.name, err)
+		}
+		if !c.ok && errors.Root(err) != ErrTxLimit {
+			t.Errorf("%s: CheckLimits() = %v want %v", c.name, err, ErrTxLimit)
+		}
+	}
+}
@@ -73,9 +73,30 @@ const (
 	// All other flag bits must be 0.
 	SerTxHash   = 0x0 // this is used only for computing transaction hash - prevout and refdata are replaced with their hashes
 	SerValid    = 0x7
-	serRequired = 0x7 // we support only this combination of flags
+	serRequired = 0x7 // the full form, written by WriteTo
+
+	// SerNoWitness is the witness-stripped form: the full form
+	// without input witnesses. Transaction IDs don't cover
+	// witnesses, so a tx read in this form has the same ID as
+	// when read in full.
+	SerNoWitness = SerPrevout | SerMetadata
 )
 
+// checkSerflags returns an error unless serflags is one of the
+// combinations that can be both written and read back: the full
+// form and the witness-stripped form. Without SerPrevout or
+// SerMetadata, parts of the transaction are replaced with their
+// hashes, and its ID can't be computed from what remains.
+func checkSerflags(serflags byte) error {
+	switch {
+	case serflags&^SerValid != 0:
+		return fmt.Errorf("invalid serflags %#x", serflags)
+	case serflags != serRequired && serflags != SerNoWitness:
+		return fmt.Errorf("unsupported serflags %#x", serflags)
+	}
+	return nil
+}
+
 // TxData encodes a transaction in the blockchain.
 // Most users will want to use Tx instead;
 // it includes the hash.
@@ -120,24 +141,30 @@ func (tx *TxData) UnmarshalText(p []byte) error {
 		return err
 	}
 	if trailing := r.Len(); trailing > 0 {
-		return fmt.Errorf("trailing garbage (%d bytes)", trailing)
+		return fmt.Errorf("trailing garbage (%d bytes) after reference data at byte %d", trailing, len(b)-trailing)
 	}
 	return nil
 }
 
 func (tx *TxData) readFrom(r *blockchain.Reader) error {
+	// Errors report the offset, from the start of the
+	// transaction, at which reading failed.
+	start := r.Len()
+	offset := func() int { return start - r.Len() }
+
 	var serflags [1]byte
 	_, err := io.ReadFull(r, serflags[:])
 	if err != nil {
-		return errors.Wrap(err, "reading serialization flags")
+		return errors.Wrapf(err, "reading serialization flags at byte %d", offset())
 	}
-	if err == nil && serflags[0] != serRequired {
-		return fmt.Errorf("unsupported serflags %#x", serflags[0])
+	err = checkSerflags(serflags[0])
+	if err != nil {
+		return err
 	}
 
 	tx.Version, err = blockchain.ReadVarint63(r)
 	if err != nil {
-		return errors.Wrap(err, "reading transaction version")
+		return errors.Wrapf(err, "reading transaction version at byte %d", offset())
 	}
 
 	// Common fields
@@ -150,43 +177,59 @@ func (tx *TxData) readFrom(r *blockchain.Reader) error {
 		return errors.Wrap(err, "reading transaction maxtime")
 	})
 	if err != nil {
-		return errors.Wrap(err, "reading transaction common fields")
+		return errors.Wrapf(err, "reading transaction common fields at byte %d", offset())
 	}
 
 	// Common witness
 	tx.CommonWitnessSuffix, err = blockchain.ReadExtensibleString(r, tx.readCommonWitness)
 	if err != nil {
-		return errors.Wrap(err, "reading transaction common witness")
+		return errors.Wrapf(err, "reading transaction common witness at byte %d", offset())
 	}
 
+	at := offset()
 	n, err := blockchain.ReadVarint31(r)
 	if err != nil {
-		return errors.Wrap(err, "reading number of transaction inputs")
+		return errors.Wrapf(err, "reading number of transaction inputs at byte %d", offset())
+	}
+	// Each input takes at least one byte, so a count larger
+	// than what's left is bogus. Check it before allocating.
+	if int(n) > r.Len() {
+		return fmt.Errorf("%d transaction inputs at byte %d but only %d bytes remain", n, at, r.Len())
+	}
+	if n > 0 {
+		tx.Inputs = make([]*TxInput, 0, n)
 	}
 	for ; n > 0; n-- {
 		ti := new(TxInput)
-		err = ti.readFrom(r)
+		err = ti.readFrom(r, serflags[0])
 		if err != nil {
-			return errors.Wrapf(err, "reading input %d", len(tx.Inputs))
+			return errors.Wrapf(err, "reading input %d at byte %d", len(tx.Inputs), offset())
 		}
 		tx.Inputs = append(tx.Inputs, ti)
 	}
 
+	at = offset()
 	n, err = blockchain.ReadVarint31(r)
 	if err != nil {
-		return errors.Wrap(err, "reading number of transaction outputs")
+		return errors.Wrapf(err, "reading number of transaction outputs at byte %d", offset())
+	}
+	if int(n) > r.Len() {
+		return fmt.Errorf("%d transaction outputs at byte %d but only %d bytes remain", n, at, r.Len())
+	}
+	if n > 0 {
+		tx.Outputs = make([]*TxOutput, 0, n)
 	}
 	for ; n > 0; n-- {
 		to := new(TxOutput)
 		err = to.readFrom(r, tx.Version)
 		if err != nil {
-			return errors.Wrapf(err, "reading output %d", len(tx.Outputs))
+			return errors.Wrapf(err, "reading output %d at byte %d", len(tx.Outputs), offset())
 		}
 		tx.Outputs = append(tx.Outputs, to)
 	}
 
 	tx.ReferenceData, err = blockchain.ReadVarstr31(r)
-	return errors.Wrap(err, "reading transaction reference data")
+	return errors.Wrapf(err, "reading transaction reference data at byte %d", offset())
 }
 
 // does not read the enclosing extensible string
@@ -212,6 +255,19 @@ func (tx *TxData) WriteTo(w io.Writer) (int64, error) {
 	return ew.Written(), ew.Err()
 }
 
+// WriteWithFlags writes tx to w in the form given by serflags,
+// which must be SerValid, the full form written by WriteTo,
+// or SerNoWitness.
+func (tx *TxData) WriteWithFlags(w io.Writer, serflags uint8) (int64, error) {
+	err := checkSerflags(serflags)
+	if err != nil {
+		return 0, err
+	}
+	ew := errors.NewWriter(w)
+	tx.writeTo(ew, serflags)
+	return ew.Written(), ew.Err()
+}
+
 func (tx *TxData) writeTo(w io.Writer, serflags byte) error {
 	_, err := w.Write([]byte{serflags})
 	if err != nil {
@@ -320,6 +320,71 @@ func TestInvalidIssuance(t *testing.T) {
 	}
 }
 
+func TestSerflags(t *testing.T) {
+	issuanceProg := []byte{1}
+	initialBlock := bc.NewHash([32]byte{0x03, 0xde})
+	assetID := bc.ComputeAssetID(issuanceProg, &initialBlock, 1, &bc.EmptyStringHash)
+	tx := NewTx(TxData{
+		Version: 1,
+		MinTime: 1,
+		MaxTime: 2,
+		Inputs: []*TxInput{
+			NewIssuanceInput([]byte{10, 9, 8}, 1000, []byte("input"), initialBlock, issuanceProg, [][]byte{{1, 2, 3}}, nil),
+			NewSpendInput([][]byte{{4, 5}}, bc.NewHash([32]byte{0xdd}), assetID, 500, 1, []byte{1}, bc.NewHash([32]byte{0xff}), []byte("spend")),
+		},
+		Outputs: []*TxOutput{
+			NewTxOutput(assetID, 1500, []byte{1}, []byte("output")),
+		},
+		ReferenceData: []byte("issuance"),
+	})
+
+	for _, serflags := range []uint8{SerValid, SerNoWitness} {
+		var buf bytes.Buffer
+		_, err := tx.WriteWithFlags(&buf, serflags)
+		if err != nil {
+			t.Fatalf("serflags %#x: %v", serflags, err)
+		}
+		var got Tx
+		err = got.UnmarshalText([]byte(hex.EncodeToString(buf.Bytes())))
+		if err != nil {
+			t.Fatalf("serflags %#x: %v", serflags, err)
+		}
+		if got.ID != tx.ID {
+			t.Errorf("serflags %#x: tx ID = %x want %x", serflags, got.ID.Bytes(), tx.ID.Bytes())
+		}
+		if got.Inputs[0].AssetID() != assetID {
+			t.Errorf("serflags %#x: issuance asset ID = %x want %x", serflags, got.Inputs[0].AssetID().Bytes(), assetID.Bytes())
+		}
+
+		// Writing it again in the same form gives the same bytes.
+		var again bytes.Buffer
+		_, err = got.WriteWithFlags(&again, serflags)
+		if err != nil {
+			t.Fatalf("serflags %#x: %v", serflags, err)
+		}
+		if !bytes.Equal(again.Bytes(), buf.Bytes()) {
+			t.Errorf("serflags %#x: rewritten tx = %x want %x", serflags, again.Bytes(), buf.Bytes())
+		}
+	}
+
+	var full bytes.Buffer
+	_, err := tx.WriteTo(&full)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, serflags := range []uint8{0, SerWitness, SerPrevout, SerMetadata, SerWitness | SerPrevout, SerWitness | SerMetadata, 0x08, 0xff} {
+		_, err := tx.WriteWithFlags(ioutil.Discard, serflags)
+		if err == nil {
+			t.Errorf("WriteWithFlags(%#x) succeeded, want error", serflags)
+		}
+		b := append([]byte{serflags}, full.Bytes()[1:]...)
+		err = new(Tx).UnmarshalText([]byte(hex.EncodeToString(b)))
+		if err == nil || !strings.Contains(err.Error(), "serflags") {
+			t.Errorf("UnmarshalText with serflags %#x error = %v, want serflags error", serflags, err)
+		}
+	}
+}
+
 func BenchmarkTxWriteToTrue(b *testing.B) {
 	tx := &Tx{}
 	for i := 0; i < b.N; i++ {
@@ -89,7 +89,7 @@ func (t *TxInput) SetArguments(args [][]byte) {
 	}
 }
 
-func (t *TxInput) readFrom(r *blockchain.Reader) (err error) {
+func (t *TxInput) readFrom(r *blockchain.Reader, serflags uint8) (err error) {
 	t.AssetVersion, err = blockchain.ReadVarint63(r)
 	if err != nil {
 		return err
@@ -148,52 +148,56 @@ func (t *TxInput) readFrom(r *blockchain.Reader) (err error) {
 		return err
 	}
 
-	t.WitnessSuffix, err = blockchain.ReadExtensibleString(r, func(r *blockchain.Reader) error {
-		// TODO(bobg): test that serialization flags include SerWitness, when we relax the serflags-must-be-0x7 rule
-		if t.AssetVersion != 1 {
-			return nil
-		}
-
-		if ii != nil {
-			// read IssuanceInput witness
-			_, err = ii.InitialBlock.ReadFrom(r)
-			if err != nil {
-				return err
+	if serflags&SerWitness != 0 {
+		t.WitnessSuffix, err = blockchain.ReadExtensibleString(r, func(r *blockchain.Reader) error {
+			if t.AssetVersion != 1 {
+				return nil
 			}
 
-			ii.AssetDefinition, err = blockchain.ReadVarstr31(r)
-			if err != nil {
-				return err
+			if ii != nil {
+				// read IssuanceInput witness
+				_, err = ii.InitialBlock.ReadFrom(r)
+				if err != nil {
+					return err
+				}
+
+				ii.AssetDefinition, err = blockchain.ReadVarstr31(r)
+				if err != nil {
+					return err
+				}
+
+				ii.VMVersion, err = blockchain.ReadVarint63(r)
+				if err != nil {
+					return err
+				}
+
+				ii.IssuanceProgram, err = blockchain.ReadVarstr31(r)
+				if err != nil {
+					return err
+				}
+
+				if ii.AssetID() != assetID {
+					return errBadAssetID
+				}
 			}
-
-			ii.VMVersion, err = blockchain.ReadVarint63(r)
+			args, err := blockchain.ReadVarstrList(r)
 			if err != nil {
 				return err
 			}
-
-			ii.IssuanceProgram, err = blockchain.ReadVarstr31(r)
-			if err != nil {
-				return err
-			}
-
-			if ii.AssetID() != assetID {
-				return errBadAssetID
+			if ii != nil {
+				ii.Arguments = args
+			} else if si != nil {
+				si.Arguments = args
 			}
-		}
-		args, err := blockchain.ReadVarstrList(r)
+			return nil
+		})
 		if err != nil {
 			return err
 		}
-		if ii != nil {
-			ii.Arguments = args
-		} else if si != nil {
-			si.Arguments = args
-		}
-		return nil
-	})
-	if err != nil {
-		return err
+	} else if ii != nil {
+		ii.strippedAssetID = &assetID
 	}
+
 	if ii != nil {
 		t.TypedInput = ii
 	} else if si != nil {
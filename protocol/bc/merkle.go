@@ -1,9 +1,13 @@
 package bc
 
 import (
+	"bytes"
+	"fmt"
 	"math"
 
 	"chain/crypto/sha3pool"
+	"chain/encoding/blockchain"
+	"chain/errors"
 )
 
 var (
@@ -11,6 +15,10 @@ var (
 	interiorPrefix = []byte{0x01}
 )
 
+// ErrBadTxIndex is returned by NewTxMerkleProof when the
+// block has no transaction at the given index.
+var ErrBadTxIndex = errors.New("transaction index out of range")
+
 // MerkleRoot creates a merkle tree from a slice of transactions
 // and returns the root hash of the tree.
 func MerkleRoot(transactions []*Tx) (root Hash, err error) {
@@ -19,13 +27,7 @@ func MerkleRoot(transactions []*Tx) (root Hash, err error) {
 		return EmptyStringHash, nil
 
 	case len(transactions) == 1:
-		h := sha3pool.Get256()
-		defer sha3pool.Put256(h)
-
-		h.Write(leafPrefix)
-		transactions[0].ID.WriteTo(h)
-		root.ReadFrom(h)
-		return root, nil
+		return leafHash(transactions[0].ID), nil
 
 	default:
 		k := prevPowerOfTwo(len(transactions))
@@ -39,14 +41,168 @@ func MerkleRoot(transactions []*Tx) (root Hash, err error) {
 			return root, err
 		}
 
-		h := sha3pool.Get256()
-		defer sha3pool.Put256(h)
-		h.Write(interiorPrefix)
-		left.WriteTo(h)
-		right.WriteTo(h)
-		root.ReadFrom(h)
-		return root, nil
+		return interiorHash(left, right), nil
+	}
+}
+
+func leafHash(id Hash) (h Hash) {
+	hasher := sha3pool.Get256()
+	defer sha3pool.Put256(hasher)
+	hasher.Write(leafPrefix)
+	id.WriteTo(hasher)
+	h.ReadFrom(hasher)
+	return h
+}
+
+func interiorHash(left, right Hash) (h Hash) {
+	hasher := sha3pool.Get256()
+	defer sha3pool.Put256(hasher)
+	hasher.Write(interiorPrefix)
+	left.WriteTo(hasher)
+	right.WriteTo(hasher)
+	h.ReadFrom(hasher)
+	return h
+}
+
+// TxMerkleProof proves that a transaction is one of the
+// transactions whose merkle root is a block's transactions
+// root, without the other transactions.
+type TxMerkleProof struct {
+	// Index is the position of the transaction in the block,
+	// and Count the number of transactions in the block.
+	Index, Count uint64
+
+	// Path holds the roots of the sibling subtrees along the
+	// way from the transaction to the root, lowest first.
+	Path []Hash
+}
+
+// NewTxMerkleProof returns a proof that the transaction at
+// txIndex is in block.
+func NewTxMerkleProof(block *Block, txIndex int) (*TxMerkleProof, error) {
+	if txIndex < 0 || txIndex >= len(block.Transactions) {
+		return nil, errors.WithDetailf(ErrBadTxIndex, "index %d, %d transactions", txIndex, len(block.Transactions))
+	}
+	path, err := merklePath(block.Transactions, txIndex)
+	if err != nil {
+		return nil, err
+	}
+	return &TxMerkleProof{
+		Index: uint64(txIndex),
+		Count: uint64(len(block.Transactions)),
+		Path:  path,
+	}, nil
+}
+
+// merklePath returns the roots of the subtrees of the merkle
+// tree of txs that are siblings of the nodes on the way from
+// the leaf for txs[i] to the root, lowest first. It splits the
+// tree the same way MerkleRoot does.
+func merklePath(txs []*Tx, i int) ([]Hash, error) {
+	if len(txs) == 1 {
+		return nil, nil
+	}
+	k := prevPowerOfTwo(len(txs))
+	var (
+		path    []Hash
+		sibling Hash
+		err     error
+	)
+	if i < k {
+		path, err = merklePath(txs[:k], i)
+		if err == nil {
+			sibling, err = MerkleRoot(txs[k:])
+		}
+	} else {
+		path, err = merklePath(txs[k:], i-k)
+		if err == nil {
+			sibling, err = MerkleRoot(txs[:k])
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return append(path, sibling), nil
+}
+
+// Verify reports whether p proves that the transaction
+// with ID txID is in a block with transactions root root.
+func (p *TxMerkleProof) Verify(txID, root Hash) bool {
+	if p.Index >= p.Count {
+		return false
+	}
+	got, ok := proofRoot(leafHash(txID), p.Index, p.Count, p.Path)
+	return ok && got == root
+}
+
+// proofRoot computes the root of a merkle tree of count leaves
+// from leaf, at position index, and path, the roots of the
+// sibling subtrees, lowest first. It reports false if path has
+// the wrong length for the tree.
+func proofRoot(leaf Hash, index, count uint64, path []Hash) (Hash, bool) {
+	if count == 1 {
+		return leaf, len(path) == 0
+	}
+	if len(path) == 0 {
+		return Hash{}, false
+	}
+	k := uint64(prevPowerOfTwo(int(count)))
+	sibling, rest := path[len(path)-1], path[:len(path)-1]
+	if index < k {
+		left, ok := proofRoot(leaf, index, k, rest)
+		return interiorHash(left, sibling), ok
+	}
+	right, ok := proofRoot(leaf, index-k, count-k, rest)
+	return interiorHash(sibling, right), ok
+}
+
+// MarshalBinary encodes p as the varint63 index and count,
+// followed by the varint31 number of hashes in the path and
+// the hashes themselves.
+func (p *TxMerkleProof) MarshalBinary() ([]byte, error) {
+	var buf bytes.Buffer
+	blockchain.WriteVarint63(&buf, p.Index)
+	blockchain.WriteVarint63(&buf, p.Count)
+	_, err := blockchain.WriteVarint31(&buf, uint64(len(p.Path)))
+	if err != nil {
+		return nil, err
+	}
+	for _, h := range p.Path {
+		h.WriteTo(&buf)
+	}
+	return buf.Bytes(), nil
+}
+
+// UnmarshalBinary decodes a proof encoded by MarshalBinary.
+func (p *TxMerkleProof) UnmarshalBinary(data []byte) error {
+	r := blockchain.NewReader(data)
+	index, err := blockchain.ReadVarint63(r)
+	if err != nil {
+		return errors.Wrap(err, "reading index")
+	}
+	count, err := blockchain.ReadVarint63(r)
+	if err != nil {
+		return errors.Wrap(err, "reading count")
+	}
+	n, err := blockchain.ReadVarint31(r)
+	if err != nil {
+		return errors.Wrap(err, "reading path length")
+	}
+	if int(n)*32 > r.Len() {
+		return fmt.Errorf("path of %d hashes is longer than the remaining %d bytes", n, r.Len())
+	}
+	path := make([]Hash, n)
+	for i := range path {
+		_, err = path[i].ReadFrom(r)
+		if err != nil {
+			return errors.Wrapf(err, "reading path hash %d", i)
+		}
+	}
+	if trailing := r.Len(); trailing > 0 {
+		return fmt.Errorf("trailing garbage (%d bytes)", trailing)
 	}
+	*p = TxMerkleProof{Index: index, Count: count, Path: path}
+	return nil
 }
 
 // prevPowerOfTwo returns the largest power of two that is smaller than a given number.
@@ -4,6 +4,7 @@ import (
 	"testing"
 	"time"
 
+	"chain/errors"
 	. "chain/protocol/bc"
 	"chain/protocol/bc/legacy"
 	"chain/protocol/vm"
@@ -139,6 +140,90 @@ func TestAllDuplicateLeaves(t *testing.T) {
 	}
 }
 
+func TestTxMerkleProof(t *testing.T) {
+	var initialBlockHash Hash
+	trueProg := []byte{byte(vm.OP_TRUE)}
+	assetID := ComputeAssetID(trueProg, &initialBlockHash, 1, &EmptyStringHash)
+	makeTxs := func(n int) []*Tx {
+		var txs []*Tx
+		for i := 0; i < n; i++ {
+			txs = append(txs, legacy.NewTx(legacy.TxData{
+				Version: 1,
+				Inputs:  []*legacy.TxInput{legacy.NewIssuanceInput([]byte{byte(i)}, 1, nil, initialBlockHash, trueProg, nil, nil)},
+				Outputs: []*legacy.TxOutput{legacy.NewTxOutput(assetID, 1, trueProg, nil)},
+			}).Tx)
+		}
+		return txs
+	}
+
+	for _, n := range []int{1, 2, 3, 17} {
+		block := &Block{Transactions: makeTxs(n)}
+		root, err := MerkleRoot(block.Transactions)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, i := range []int{0, n / 2, n - 1} {
+			proof, err := NewTxMerkleProof(block, i)
+			if err != nil {
+				t.Fatal(err)
+			}
+			txID := block.Transactions[i].ID
+			if !proof.Verify(txID, root) {
+				t.Errorf("%d txs: proof for tx %d doesn't verify", n, i)
+			}
+
+			b, err := proof.MarshalBinary()
+			if err != nil {
+				t.Fatal(err)
+			}
+			var decoded TxMerkleProof
+			err = decoded.UnmarshalBinary(b)
+			if err != nil {
+				t.Fatalf("%d txs: decoding proof for tx %d: %s", n, i, err)
+			}
+			if !decoded.Verify(txID, root) {
+				t.Errorf("%d txs: decoded proof for tx %d doesn't verify", n, i)
+			}
+
+			other := block.Transactions[(i+1)%n].ID
+			if n > 1 && proof.Verify(other, root) {
+				t.Errorf("%d txs: proof for tx %d verifies tx %d", n, i, (i+1)%n)
+			}
+			if n > 1 {
+				moved := *proof
+				moved.Index = uint64((i + 1) % n)
+				if moved.Verify(txID, root) {
+					t.Errorf("%d txs: proof for tx %d verifies at index %d", n, i, moved.Index)
+				}
+			}
+			if len(proof.Path) > 0 {
+				tampered := *proof
+				tampered.Path = append([]Hash(nil), proof.Path...)
+				tampered.Path[0] = EmptyStringHash
+				if tampered.Verify(txID, root) {
+					t.Errorf("%d txs: tampered proof for tx %d verifies", n, i)
+				}
+				tampered.Path = proof.Path[:len(proof.Path)-1]
+				if tampered.Verify(txID, root) {
+					t.Errorf("%d txs: truncated proof for tx %d verifies", n, i)
+				}
+			}
+		}
+
+		_, err = NewTxMerkleProof(block, n)
+		if errors.Root(err) != ErrBadTxIndex {
+			t.Errorf("%d txs: proof for tx %d error = %v want %v", n, n, err, ErrBadTxIndex)
+		}
+	}
+
+	var p TxMerkleProof
+	for _, b := range [][]byte{nil, {0}, {0, 1, 1}, {0, 1, 0, 0}} {
+		if p.UnmarshalBinary(b) == nil {
+			t.Errorf("UnmarshalBinary(%x) succeeded, want error", b)
+		}
+	}
+}
+
 func mustDecodeHash(s string) (h Hash) {
 	err := h.UnmarshalText([]byte(s))
 	if err != nil {
@@ -1,7 +1,12 @@
 package bc
 
 import (
+	"sort"
+
+	"github.com/golang/protobuf/proto"
+
 	"chain/crypto/sha3pool"
+	"chain/encoding/blockchain"
 	"chain/errors"
 )
 
@@ -27,6 +32,51 @@ func (tx *Tx) SigHash(n uint32) (hash Hash) {
 	return hash
 }
 
+// WitnessHash returns a hash of the whole transaction, witness
+// data included. Unlike the ID, it differs between copies of a
+// transaction with different arguments or other witness fields,
+// so it is safe to key validation results by it.
+func (tx *Tx) WitnessHash() (hash Hash) {
+	ids := make([]Hash, 0, len(tx.Entries))
+	for id := range tx.Entries {
+		ids = append(ids, id)
+	}
+	sort.Sort(byHash(ids))
+
+	hasher := sha3pool.Get256()
+	defer sha3pool.Put256(hasher)
+
+	hasher.Write([]byte("witness:"))
+	tx.ID.WriteTo(hasher)
+	for _, id := range ids {
+		b, err := proto.Marshal(tx.Entries[id])
+		if err != nil {
+			panic(err) // entries are plain generated messages; marshaling can't fail
+		}
+		id.WriteTo(hasher)
+		blockchain.WriteVarstr31(hasher, b)
+	}
+	hash.ReadFrom(hasher)
+	return hash
+}
+
+type byHash []Hash
+
+func (h byHash) Len() int      { return len(h) }
+func (h byHash) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+func (h byHash) Less(i, j int) bool {
+	a, b := h[i], h[j]
+	switch {
+	case a.V0 != b.V0:
+		return a.V0 < b.V0
+	case a.V1 != b.V1:
+		return a.V1 < b.V1
+	case a.V2 != b.V2:
+		return a.V2 < b.V2
+	}
+	return a.V3 < b.V3
+}
+
 // Convenience routines for accessing entries of specific types by ID.
 
 var (
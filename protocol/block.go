@@ -2,6 +2,8 @@ package protocol
 
 import (
 	"context"
+	"encoding/binary"
+	"expvar"
 	"fmt"
 	"time"
 
@@ -19,19 +21,89 @@ import (
 // included in each block.
 const maxBlockTxs = 10000
 
+// MaxBlockSizeBytes limits the size of each block, as given by
+// legacy.Block.SerializedSize. GenerateBlock keeps every block
+// it makes within it. Validation rejects bigger blocks only from
+// block version blockSizeLimitVersion on, so blocks already on
+// existing chains stay valid.
+const MaxBlockSizeBytes = 10 << 20
+
+// MaxTxSizeBytes limits the size of each transaction, as given by
+// legacy.Tx.SerializedSize, that GenerateBlock includes. It leaves
+// room for the block header and signatures, so a transaction within
+// it always fits in a block by itself.
+const MaxTxSizeBytes = MaxBlockSizeBytes - 64<<10
+
+// blockSizeLimitVersion is the first block
+// version subject to MaxBlockSizeBytes.
+// GenerateBlock makes blocks of this version
+// if Chain.BlockSizeLimit is set.
+const blockSizeLimitVersion = 2
+
 // saveSnapshotFrequency stores how often to save a state
 // snapshot to the Store.
 const saveSnapshotFrequency = time.Hour
 
+// saveSnapshotBlocks is the most blocks to commit between
+// saving state snapshots, however little time they take.
+// It bounds the blocks Recover must replay on restart.
+const saveSnapshotBlocks = 1000
+
 var (
-	// ErrBadBlock is returned when a block is invalid.
+	// ErrBadBlock is returned when a block is invalid, unless
+	// validation gives one of its exported errors.
 	ErrBadBlock = errors.New("invalid block")
 
 	// ErrBadStateRoot is returned when the computed assets merkle root
 	// disagrees with the one declared in a block header.
 	ErrBadStateRoot = errors.New("invalid state merkle root")
+
+	// ErrBlockTooBig is returned when a block's
+	// size exceeds MaxBlockSizeBytes.
+	ErrBlockTooBig = errors.New("block exceeds size limit")
 )
 
+// forksDetected counts the blocks that conflicted
+// with one already in the blockchain.
+var forksDetected = expvar.NewInt("forks_detected")
+
+// ForkError is returned when a block conflicts with the stored
+// blockchain, meaning the generator has produced two different
+// blocks at Height. HaveHash is the hash of the block we have at
+// Height; GotHash is the hash of the conflicting block, or the
+// previous block hash of a block built on it.
+type ForkError struct {
+	Height   uint64
+	HaveHash bc.Hash
+	GotHash  bc.Hash
+}
+
+func (e *ForkError) Error() string {
+	return fmt.Sprintf("fork detected at height %d: have block %x, got %x",
+		e.Height, e.HaveHash.Bytes(), e.GotHash.Bytes())
+}
+
+func forkDetected(height uint64, have, got bc.Hash) error {
+	forksDetected.Add(1)
+	return &ForkError{Height: height, HaveHash: have, GotHash: got}
+}
+
+// checkFork returns a ForkError if c already
+// has a different block at block's height.
+func (c *Chain) checkFork(ctx context.Context, block *legacy.Block) error {
+	if block.Height > c.Height() {
+		return nil
+	}
+	have, err := c.store.GetBlock(ctx, block.Height)
+	if err != nil {
+		return errors.Wrap(err, "getting stored block")
+	}
+	if h := block.Hash(); h != have.Hash() {
+		return forkDetected(block.Height, have.Hash(), h)
+	}
+	return nil
+}
+
 // GetBlock returns the block at the given height, if there is one,
 // otherwise it returns an error.
 func (c *Chain) GetBlock(ctx context.Context, height uint64) (*legacy.Block, error) {
@@ -44,6 +116,17 @@ func (c *Chain) GetBlock(ctx context.Context, height uint64) (*legacy.Block, err
 //
 // After generating the block, the pending transaction pool will be
 // empty.
+//
+// GenerateBlock stops at the first transaction that doesn't fit
+// within the block limits (see HasRoom), leaving it and the rest
+// of txs for later blocks. It skips transactions bigger than
+// MaxTxSizeBytes, which no block has room for.
+//
+// The block is version 1 unless c.BlockSizeLimit is set, in which
+// case it's blockSizeLimitVersion, so that the network's signers
+// enforce the size limit. A block is never of a lower version than
+// prev, since validation rejects that. Every signer must be able
+// to validate the later version before BlockSizeLimit is set.
 func (c *Chain) GenerateBlock(ctx context.Context, prev *legacy.Block, snapshot *state.Snapshot, now time.Time, txs []*legacy.Tx) (*legacy.Block, *state.Snapshot, error) {
 	// TODO(kr): move this into a lower-level package (e.g. chain/protocol/bc)
 	// so that other packages (e.g. chain/protocol/validation) unit tests can
@@ -70,13 +153,32 @@ func (c *Chain) GenerateBlock(ctx context.Context, prev *legacy.Block, snapshot
 		},
 	}
 
+	if c.BlockSizeLimit {
+		b.Version = blockSizeLimitVersion
+	}
+	if prev.Version > b.Version {
+		b.Version = prev.Version
+	}
+
 	var txEntries []*bc.Tx
 
+	size := b.SerializedSize()
 	for _, tx := range txs {
-		if len(b.Transactions) >= maxBlockTxs {
+		txSize := tx.SerializedSize()
+		if txSize > MaxTxSizeBytes {
+			continue
+		}
+		if !HasRoom(size, len(b.Transactions), txSize) {
 			break
 		}
 
+		// Blocks after version 1 may hold transactions of later
+		// versions, whose rules this code doesn't know. Leave
+		// them out.
+		if tx.Version != 1 {
+			continue
+		}
+
 		// Filter out transactions that are not well-formed.
 		err := c.ValidateTx(tx.Tx)
 		if err != nil {
@@ -102,8 +204,10 @@ func (c *Chain) GenerateBlock(ctx context.Context, prev *legacy.Block, snapshot
 			continue
 		}
 
+		n := len(b.Transactions)
 		b.Transactions = append(b.Transactions, tx)
 		txEntries = append(txEntries, tx.Tx)
+		size += varintLen(n+1) - varintLen(n) + txSize
 	}
 
 	var err error
@@ -119,25 +223,38 @@ func (c *Chain) GenerateBlock(ctx context.Context, prev *legacy.Block, snapshot
 }
 
 // ValidateBlock validates an incoming block in advance of committing
-// it to the blockchain (with CommitBlock).
+// it to the blockchain (with CommitBlock). If block doesn't build on
+// prev, which is at the height below it, it returns a ForkError.
 func (c *Chain) ValidateBlock(block, prev *legacy.Block) error {
+	if prev != nil && block.Height == prev.Height+1 && block.PreviousBlockHash != prev.Hash() {
+		return forkDetected(prev.Height, prev.Hash(), block.PreviousBlockHash)
+	}
+	err := checkBlockSize(block)
+	if err != nil {
+		return err
+	}
 	blockEnts := legacy.MapBlock(block)
 	prevEnts := legacy.MapBlock(prev)
-	err := validation.ValidateBlock(blockEnts, prevEnts, c.InitialBlockHash, c.ValidateTx)
+	err = validation.ValidateBlock(blockEnts, prevEnts, c.InitialBlockHash, c.ValidateTx)
 	if err != nil {
-		return errors.Sub(ErrBadBlock, err)
+		return subValidationErr(ErrBadBlock, err)
 	}
 	if block.Height > 1 {
 		err = validation.ValidateBlockSig(blockEnts, prevEnts.NextConsensusProgram)
 	}
-	return errors.Sub(ErrBadBlock, err)
+	return subValidationErr(ErrBadBlock, err)
 }
 
 // CommitAppliedBlock takes a block, commits it to persistent storage and
 // sets c's state. Unlike CommitBlock, it accepts an already applied
-// snapshot. CommitAppliedBlock is idempotent.
+// snapshot. CommitAppliedBlock is idempotent. It returns a ForkError
+// if c already has a different block at the same height.
 func (c *Chain) CommitAppliedBlock(ctx context.Context, block *legacy.Block, snapshot *state.Snapshot) error {
-	err := c.store.SaveBlock(ctx, block)
+	err := c.checkFork(ctx, block)
+	if err != nil {
+		return err
+	}
+	err = c.store.SaveBlock(ctx, block)
 	if err != nil {
 		return errors.Wrap(err, "storing block")
 	}
@@ -145,8 +262,8 @@ func (c *Chain) CommitAppliedBlock(ctx context.Context, block *legacy.Block, sna
 
 	// CommitAppliedBlock needs to be idempotent. If block's height is less than or
 	// equal to c's current block, then it was already applied. Because
-	// SaveBlock didn't error with a conflict, we know it's not a different
-	// block at the same height.
+	// checkFork passed, we know it's not a different block at the same
+	// height.
 	if curBlock != nil && block.Height <= curBlock.Height {
 		return nil
 	}
@@ -155,9 +272,14 @@ func (c *Chain) CommitAppliedBlock(ctx context.Context, block *legacy.Block, sna
 
 // CommitBlock takes a block, commits it to persistent storage and applies
 // it to c. CommitBlock is idempotent. A duplicate call with a previously
-// committed block will succeed.
+// committed block will succeed; a different block at the height of one
+// already committed gives a ForkError.
 func (c *Chain) CommitBlock(ctx context.Context, block *legacy.Block) error {
-	err := c.store.SaveBlock(ctx, block)
+	err := c.checkFork(ctx, block)
+	if err != nil {
+		return err
+	}
+	err = c.store.SaveBlock(ctx, block)
 	if err != nil {
 		return errors.Wrap(err, "storing block")
 	}
@@ -165,8 +287,8 @@ func (c *Chain) CommitBlock(ctx context.Context, block *legacy.Block) error {
 
 	// CommitBlock needs to be idempotent. If block's height is less than or
 	// equal to c's current block, then it was already applied. Because
-	// SaveBlock didn't error with a conflict, we know it's not a different
-	// block at the same height.
+	// checkFork passed, we know it's not a different block at the same
+	// height.
 	if curBlock != nil && block.Height <= curBlock.Height {
 		return nil
 	}
@@ -185,7 +307,8 @@ func (c *Chain) CommitBlock(ctx context.Context, block *legacy.Block) error {
 func (c *Chain) finalizeCommitBlock(ctx context.Context, block *legacy.Block, snapshot *state.Snapshot) error {
 	// Save the blockchain state tree snapshot to persistent storage
 	// if we haven't done it recently.
-	if block.Time().After(c.lastQueuedSnapshot.Add(saveSnapshotFrequency)) {
+	if block.Time().After(c.lastQueuedSnapshot.Add(saveSnapshotFrequency)) ||
+		block.Height >= c.lastQueuedSnapshotHeight+saveSnapshotBlocks {
 		c.queueSnapshot(ctx, block.Height, block.Time(), snapshot)
 	}
 
@@ -207,6 +330,7 @@ func (c *Chain) queueSnapshot(ctx context.Context, height uint64, timestamp time
 	select {
 	case c.pendingSnapshots <- ps:
 		c.lastQueuedSnapshot = timestamp
+		c.lastQueuedSnapshotHeight = height
 	default:
 		// Skip it; saving snapshots is taking longer than the snapshotting period.
 		log.Printf(ctx, "snapshot storage is taking too long; last queued at %s",
@@ -228,8 +352,44 @@ func (c *Chain) ValidateBlockForSig(ctx context.Context, block *legacy.Block) er
 		}
 	}
 
-	err := validation.ValidateBlock(legacy.MapBlock(block), legacy.MapBlock(prev), c.InitialBlockHash, c.ValidateTx)
-	return errors.Sub(ErrBadBlock, err)
+	err := checkBlockSize(block)
+	if err != nil {
+		return err
+	}
+	err = validation.ValidateBlock(legacy.MapBlock(block), legacy.MapBlock(prev), c.InitialBlockHash, c.ValidateTx)
+	return subValidationErr(ErrBadBlock, err)
+}
+
+// checkBlockSize returns ErrBlockTooBig if block is of a
+// version subject to MaxBlockSizeBytes and exceeds it.
+func checkBlockSize(block *legacy.Block) error {
+	if block.Version < blockSizeLimitVersion {
+		return nil
+	}
+	if n := block.SerializedSize(); n > MaxBlockSizeBytes {
+		return errors.WithDetailf(ErrBlockTooBig, "block is %d bytes, limit is %d", n, MaxBlockSizeBytes)
+	}
+	return nil
+}
+
+// CheckTxSize returns legacy.ErrTxLimit if tx
+// is bigger than MaxTxSizeBytes.
+func CheckTxSize(tx *legacy.Tx) error {
+	if n := tx.SerializedSize(); n > MaxTxSizeBytes {
+		return errors.WithDetailf(legacy.ErrTxLimit, "transaction is %d bytes, limit is %d", n, MaxTxSizeBytes)
+	}
+	return nil
+}
+
+// HasRoom reports whether a block of size bytes, as given by
+// legacy.Block.SerializedSize, holding n transactions has room
+// for another transaction of txSize bytes within the limits
+// GenerateBlock keeps blocks within.
+func HasRoom(size, n, txSize int) bool {
+	if n >= maxBlockTxs {
+		return false
+	}
+	return size-varintLen(n)+varintLen(n+1)+txSize <= MaxBlockSizeBytes
 }
 
 func NewInitialBlock(pubkeys []ed25519.PublicKey, nSigs int, timestamp time.Time) (*legacy.Block, error) {
@@ -260,3 +420,10 @@ func NewInitialBlock(pubkeys []ed25519.PublicKey, nSigs int, timestamp time.Time
 	}
 	return b, nil
 }
+
+// varintLen returns the number of bytes in
+// the varint encoding of n.
+func varintLen(n int) int {
+	var buf [binary.MaxVarintLen64]byte
+	return binary.PutUvarint(buf[:], uint64(n))
+}
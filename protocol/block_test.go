@@ -8,6 +8,9 @@ import (
 	"testing"
 	"time"
 
+	"github.com/golang/groupcache/lru"
+
+	"chain/errors"
 	"chain/protocol/bc"
 	"chain/protocol/bc/legacy"
 	"chain/protocol/prottest/memstore"
@@ -126,6 +129,133 @@ func TestWaitForBlockSoonTimesout(t *testing.T) {
 	}
 }
 
+func TestWaitForBlockHeightConcurrent(t *testing.T) {
+	ctx := context.Background()
+	c, _ := newTestChain(t, time.Now())
+
+	const waitersPerHeight = 3
+	heights := []uint64{1, 2, 3, 4}
+	done := make(map[uint64]chan error)
+	for _, h := range heights {
+		ch := make(chan error, waitersPerHeight)
+		done[h] = ch
+		for i := 0; i < waitersPerHeight; i++ {
+			go func(h uint64) {
+				ch <- c.WaitForBlockHeight(ctx, h)
+			}(h)
+		}
+	}
+
+	// checkDone makes sure the waiters for height have
+	// returned, and those for greater heights haven't.
+	checkDone := func(height uint64) {
+		for i := 0; i < waitersPerHeight; i++ {
+			err := <-done[height]
+			if err != nil {
+				t.Errorf("WaitForBlockHeight(%d) = %v want nil", height, err)
+			}
+		}
+		time.Sleep(10 * time.Millisecond) // give waiters a chance to return early
+		for _, h := range heights {
+			if h > height && len(done[h]) > 0 {
+				t.Errorf("WaitForBlockHeight(%d) returned at height %d", h, height)
+			}
+		}
+	}
+
+	checkDone(1)
+	for h := uint64(2); h <= 4; h++ {
+		makeEmptyBlock(t, c)
+		checkDone(h)
+	}
+}
+
+func TestWaitForBlockHeightCanceled(t *testing.T) {
+	c, _ := newTestChain(t, time.Now())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errch := make(chan error, 1)
+	go func() {
+		errch <- c.WaitForBlockHeight(ctx, 3)
+	}()
+	cancel()
+	err := <-errch
+	if err != context.Canceled {
+		t.Errorf("WaitForBlockHeight after cancel = %v want %v", err, context.Canceled)
+	}
+
+	ctx, cancel = context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	err = c.WaitForBlockHeight(ctx, 3)
+	if err != context.DeadlineExceeded {
+		t.Errorf("WaitForBlockHeight after timeout = %v want %v", err, context.DeadlineExceeded)
+	}
+
+	// A done context doesn't matter
+	// if the height is already reached.
+	err = c.WaitForBlockHeight(ctx, 1)
+	if err != nil {
+		t.Errorf("WaitForBlockHeight(1) = %v want nil", err)
+	}
+}
+
+func TestSubscribeBlocks(t *testing.T) {
+	c, _ := newTestChain(t, time.Now())
+	makeEmptyBlock(t, c) // height=2
+	makeEmptyBlock(t, c) // height=3
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	blocks, err := c.SubscribeBlocks(ctx, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	next := func(want uint64) {
+		select {
+		case b := <-blocks:
+			if b.Height != want {
+				t.Fatalf("got block %d, want %d", b.Height, want)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out waiting for block %d", want)
+		}
+	}
+
+	// Catch up on committed blocks, then
+	// receive new ones as they're committed.
+	next(2)
+	next(3)
+	for h := uint64(4); h <= 5; h++ {
+		makeEmptyBlock(t, c)
+		next(h)
+	}
+
+	// A consumer that doesn't keep up holds up neither
+	// commits nor its later delivery, in order.
+	const n = 3 * subscriptionBuffer
+	for i := 0; i < n; i++ {
+		makeEmptyBlock(t, c)
+	}
+	for h := uint64(6); h < 6+n; h++ {
+		next(h)
+	}
+
+	cancel()
+	for range blocks {
+		// Drain any block read
+		// before cancellation.
+	}
+}
+
+func TestSubscribeBlocksZero(t *testing.T) {
+	c, _ := newTestChain(t, time.Now())
+	_, err := c.SubscribeBlocks(context.Background(), 0)
+	if err == nil {
+		t.Error("SubscribeBlocks(0) succeeded, want error")
+	}
+}
+
 func TestGenerateBlock(t *testing.T) {
 	ctx := context.Background()
 	now := time.Unix(233400000, 0)
@@ -195,6 +325,34 @@ func TestGenerateBlock(t *testing.T) {
 	}
 }
 
+func TestGenerateBlockVersion(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	c, b1 := newTestChain(t, now)
+	b2 := *b1
+	b2.Version = blockSizeLimitVersion
+
+	cases := []struct {
+		limit bool
+		prev  *legacy.Block
+		want  uint64
+	}{
+		{false, b1, 1},
+		{true, b1, blockSizeLimitVersion},
+		{false, &b2, blockSizeLimitVersion}, // never regress
+	}
+	for _, tc := range cases {
+		c.BlockSizeLimit = tc.limit
+		got, _, err := c.GenerateBlock(ctx, tc.prev, state.Empty(), now, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.Version != tc.want {
+			t.Errorf("GenerateBlock(limit %t, prev version %d) version = %d want %d", tc.limit, tc.prev.Version, got.Version, tc.want)
+		}
+	}
+}
+
 func TestValidateBlockForSig(t *testing.T) {
 	initialBlock, err := NewInitialBlock(testutil.TestPubs, 1, time.Now())
 	if err != nil {
@@ -272,6 +430,233 @@ func TestCommitBlockIdempotence(t *testing.T) {
 	}
 }
 
+func TestForkDetected(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	c, b1 := newTestChain(t, now)
+
+	b2, s2, err := c.GenerateBlock(ctx, b1, state.Empty(), now.Add(time.Second), nil)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	fork, forkSnapshot, err := c.GenerateBlock(ctx, b1, state.Empty(), now.Add(2*time.Second), nil)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	err = c.CommitAppliedBlock(ctx, b2, s2)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	onFork := createEmptyBlock(fork, forkSnapshot)
+
+	cases := []struct {
+		name string
+		f    func() error
+		want ForkError
+	}{{
+		name: "commit block at same height",
+		f:    func() error { return c.CommitBlock(ctx, fork) },
+		want: ForkError{Height: 2, HaveHash: b2.Hash(), GotHash: fork.Hash()},
+	}, {
+		name: "commit applied block at same height",
+		f:    func() error { return c.CommitAppliedBlock(ctx, fork, forkSnapshot) },
+		want: ForkError{Height: 2, HaveHash: b2.Hash(), GotHash: fork.Hash()},
+	}, {
+		name: "validate block on fork",
+		f:    func() error { return c.ValidateBlock(onFork, b2) },
+		want: ForkError{Height: 2, HaveHash: b2.Hash(), GotHash: fork.Hash()},
+	}}
+	for _, tc := range cases {
+		before := forksDetected.Value()
+		err := tc.f()
+		got, ok := errors.Root(err).(*ForkError)
+		if !ok {
+			t.Errorf("%s: error = %v, want a ForkError", tc.name, err)
+			continue
+		}
+		if *got != tc.want {
+			t.Errorf("%s: error = %+v want %+v", tc.name, *got, tc.want)
+		}
+		if n := forksDetected.Value() - before; n != 1 {
+			t.Errorf("%s: forks_detected increased by %d, want 1", tc.name, n)
+		}
+	}
+
+	// Committing the same block again is not a fork.
+	before := forksDetected.Value()
+	err = c.CommitBlock(ctx, b2)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	if forksDetected.Value() != before {
+		t.Error("recommitting a block counted as a fork")
+	}
+}
+
+func TestGenerateBlockSizeLimit(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	c, b1 := newTestChain(t, now)
+	ts := now.Add(time.Second)
+
+	empty, _, err := c.GenerateBlock(ctx, b1, state.Empty(), ts, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Two txs add the size of each, and the
+	// tx count grows from 0 to 2, still one byte.
+	tx1 := sizedTx(t, c, MaxBlockSizeBytes/2, ts)
+	room := MaxBlockSizeBytes - empty.SerializedSize() - tx1.SerializedSize()
+	small := issueProgram(t, c, trueProgram, nil, 1)
+
+	fits := sizedTx(t, c, room, ts)
+	got, _, err := c.GenerateBlock(ctx, b1, state.Empty(), ts, []*legacy.Tx{tx1, fits, small})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got.Transactions) != 2 {
+		t.Errorf("just under limit: got %d txs, want 2", len(got.Transactions))
+	}
+	if n := got.SerializedSize(); n != MaxBlockSizeBytes {
+		t.Errorf("just under limit: block size = %d want %d", n, MaxBlockSizeBytes)
+	}
+
+	over := sizedTx(t, c, room+1, ts)
+	got, _, err = c.GenerateBlock(ctx, b1, state.Empty(), ts, []*legacy.Tx{tx1, over})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got.Transactions) != 1 {
+		t.Errorf("just over limit: got %d txs, want 1", len(got.Transactions))
+	}
+}
+
+func TestValidateBlockSizeLimit(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	c, b1 := newTestChain(t, now)
+	ts := now.Add(time.Second)
+
+	empty, _, err := c.GenerateBlock(ctx, b1, state.Empty(), ts, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The tx count grows from 0 to 2, still one byte.
+	room := MaxBlockSizeBytes - empty.SerializedSize()
+	fill := []*legacy.Tx{
+		sizedTx(t, c, MaxTxSizeBytes, ts),
+		sizedTx(t, c, room-MaxTxSizeBytes, ts),
+	}
+
+	under, _, err := c.GenerateBlock(ctx, b1, state.Empty(), ts, fill)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := under.SerializedSize(); n != MaxBlockSizeBytes {
+		t.Fatalf("block size = %d want %d", n, MaxBlockSizeBytes)
+	}
+
+	// GenerateBlock won't make an oversized block,
+	// so make one by hand.
+	tooBig := sizedTx(t, c, room+1, ts)
+	over := &legacy.Block{BlockHeader: under.BlockHeader, Transactions: []*legacy.Tx{tooBig}}
+	over.TransactionsMerkleRoot, err = bc.MerkleRoot([]*bc.Tx{tooBig.Tx})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A tx too big for any block doesn't stop the ones after it.
+	small := sizedTx(t, c, 1000, ts)
+	got, _, err := c.GenerateBlock(ctx, b1, state.Empty(), ts, []*legacy.Tx{tooBig, small})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got.Transactions) != 1 || got.Transactions[0].ID != small.ID {
+		t.Errorf("GenerateBlock(too big, small) has %d txs, want only the small one", len(got.Transactions))
+	}
+
+	cases := []struct {
+		block   *legacy.Block
+		version uint64
+		want    error
+	}{
+		{under, 1, nil},
+		{under, blockSizeLimitVersion, nil},
+		{over, 1, nil}, // older versions aren't limited
+		{over, blockSizeLimitVersion, ErrBlockTooBig},
+	}
+	for _, tc := range cases {
+		b := *tc.block
+		b.Version = tc.version
+		err := c.ValidateBlock(&b, b1)
+		if errors.Root(err) != tc.want {
+			t.Errorf("ValidateBlock(%d bytes, version %d) = %v want %v", b.SerializedSize(), b.Version, err, tc.want)
+		}
+		err = c.ValidateBlockForSig(ctx, &b)
+		if errors.Root(err) != tc.want {
+			t.Errorf("ValidateBlockForSig(%d bytes, version %d) = %v want %v", b.SerializedSize(), b.Version, err, tc.want)
+		}
+	}
+}
+
+// sizedTx returns a valid tx on c's blockchain at time ts,
+// with serialized size n.
+func sizedTx(tb testing.TB, c *Chain, n int, ts time.Time) *legacy.Tx {
+	data := issueProgram(tb, c, trueProgram, nil, 1).TxData
+	data.MinTime = bc.Millis(ts)
+	data.MaxTime = bc.Millis(ts.Add(time.Hour))
+	for i := 0; i < 3; i++ {
+		size := data.SerializedSize()
+		if size == n {
+			return legacy.NewTx(data)
+		}
+		data.ReferenceData = make([]byte, len(data.ReferenceData)+n-size)
+	}
+	tb.Fatalf("can't make a tx of size %d", n)
+	return nil
+}
+
+func BenchmarkValidateBlockPrevalidated(b *testing.B) {
+	const ntxs = 1000
+
+	ctx := context.Background()
+	now := time.Now()
+	c, b1 := newTestChain(b, now)
+
+	var txs []*legacy.Tx
+	for i := 0; i < ntxs; i++ {
+		txs = append(txs, issueProgram(b, c, trueProgram, nil, 1))
+	}
+	// GenerateBlock validates, and so caches, each tx.
+	b2, _, err := c.GenerateBlock(ctx, b1, state.Empty(), now.Add(time.Second), txs)
+	if err != nil {
+		testutil.FatalErr(b, err)
+	}
+	if len(b2.Transactions) != ntxs {
+		b.Fatalf("block has %d txs, want %d", len(b2.Transactions), ntxs)
+	}
+
+	b.Run("cold", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			b.StopTimer()
+			c.prevalidated.lru = lru.New(maxCachedValidatedTxs)
+			b.StartTimer()
+			err := c.ValidateBlock(b2, b1)
+			if err != nil {
+				testutil.FatalErr(b, err)
+			}
+		}
+	})
+	b.Run("prevalidated", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			err := c.ValidateBlock(b2, b1)
+			if err != nil {
+				testutil.FatalErr(b, err)
+			}
+		}
+	})
+}
+
 // newTestChain returns a new Chain using memstore for storage,
 // along with an initial block b1 (with a 0/0 multisig program).
 // It commits b1 before returning.
@@ -297,6 +682,21 @@ func newTestChain(tb testing.TB, ts time.Time) (c *Chain, b1 *legacy.Block) {
 	return c, b1
 }
 
+// generateAndCommit generates a block on prev from txs
+// and commits it to c.
+func generateAndCommit(tb testing.TB, c *Chain, prev *legacy.Block, ts time.Time, txs ...*legacy.Tx) *legacy.Block {
+	ctx := context.Background()
+	block, snapshot, err := c.GenerateBlock(ctx, prev, state.Empty(), ts, txs)
+	if err != nil {
+		testutil.FatalErr(tb, err)
+	}
+	err = c.CommitAppliedBlock(ctx, block, snapshot)
+	if err != nil {
+		testutil.FatalErr(tb, err)
+	}
+	return block
+}
+
 func makeEmptyBlock(tb testing.TB, c *Chain) {
 	ctx := context.Background()
 
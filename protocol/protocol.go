@@ -91,13 +91,25 @@ import (
 	"chain/protocol/state"
 )
 
+// subscriptionBuffer is the most blocks each block
+// subscription reads ahead of its consumer.
+const subscriptionBuffer = 10
+
 // maxCachedValidatedTxs is the max number of validated txs to cache.
-const maxCachedValidatedTxs = 1000
+// It's enough to hold a full block's worth (see maxBlockTxs), so
+// txs validated on submission are still cached when their block
+// is validated.
+const maxCachedValidatedTxs = 10000
 
 var (
 	// ErrTheDistantFuture is returned when waiting for a blockheight
 	// too far in excess of the tip of the blockchain.
 	ErrTheDistantFuture = errors.New("block height too far in future")
+
+	// ErrBadSnapshot is the root of errors from Store.LatestSnapshot
+	// for stored snapshots that are corrupt or in an unknown format.
+	// Recover replays the whole blockchain instead of using them.
+	ErrBadSnapshot = errors.New("bad state snapshot")
 )
 
 // Store provides storage for blockchain data: blocks and state tree
@@ -124,6 +136,8 @@ type Store interface {
 type Chain struct {
 	InitialBlockHash  bc.Hash
 	MaxIssuanceWindow time.Duration // only used by generators
+	MaxIssuanceSkew   time.Duration // only used by generators; 0 means DefaultMaxIssuanceSkew
+	BlockSizeLimit    bool          // only used by generators; see GenerateBlock
 
 	state struct {
 		cond     sync.Cond // protects height, block, snapshot
@@ -133,8 +147,9 @@ type Chain struct {
 	}
 	store Store
 
-	lastQueuedSnapshot time.Time
-	pendingSnapshots   chan pendingSnapshot
+	lastQueuedSnapshot       time.Time
+	lastQueuedSnapshotHeight uint64
+	pendingSnapshots         chan pendingSnapshot
 
 	prevalidated prevalidatedTxsCache
 }
@@ -263,30 +278,109 @@ func (c *Chain) BlockSoonWaiter(ctx context.Context, height uint64) <-chan error
 			ch <- ErrTheDistantFuture
 			return
 		}
+		ch <- c.WaitForBlockHeight(ctx, height)
+	}()
+
+	return ch
+}
+
+// WaitForBlockHeight waits for the block at the given height.
+// It returns nil once the chain reaches that height, or
+// ctx.Err() if ctx is done first.
+func (c *Chain) WaitForBlockHeight(ctx context.Context, height uint64) error {
+	c.state.cond.L.Lock()
+	defer c.state.cond.L.Unlock()
+	if c.state.height >= height {
+		return nil
+	}
 
+	// Wake the loop below when ctx is done, so it can give up.
+	// Broadcast needs the lock, which Wait releases, so the
+	// wakeup can't happen between the ctx check and Wait.
+	waited := make(chan struct{})
+	defer close(waited)
+	go func() {
 		select {
-		case <-c.BlockWaiter(height):
-			ch <- nil
 		case <-ctx.Done():
-			ch <- ctx.Err()
+			c.state.cond.L.Lock()
+			c.state.cond.Broadcast()
+			c.state.cond.L.Unlock()
+		case <-waited:
 		}
 	}()
 
-	return ch
+	for c.state.height < height {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		c.state.cond.Wait()
+	}
+	return nil
 }
 
 // BlockWaiter returns a channel that
 // waits for the block at the given height.
+// Waiters that may give up should use
+// WaitForBlockHeight instead.
 func (c *Chain) BlockWaiter(height uint64) <-chan struct{} {
 	ch := make(chan struct{}, 1)
 	go func() {
-		c.state.cond.L.Lock()
-		defer c.state.cond.L.Unlock()
-		for c.state.height < height {
-			c.state.cond.Wait()
-		}
+		c.WaitForBlockHeight(context.Background(), height)
 		ch <- struct{}{}
 	}()
 
 	return ch
 }
+
+// SubscribeBlocks returns a channel that delivers committed
+// blocks in order, starting at fromHeight: first those already
+// committed, then each new one as it's committed. The channel
+// is closed when ctx is done.
+//
+// A slow consumer doesn't hold up commits. The subscription
+// reads blocks from the store as the consumer takes them,
+// reading at most subscriptionBuffer blocks ahead, so a consumer
+// that falls behind only receives its blocks later.
+func (c *Chain) SubscribeBlocks(ctx context.Context, fromHeight uint64) (<-chan *legacy.Block, error) {
+	if fromHeight == 0 {
+		return nil, errors.New("block heights start at 1")
+	}
+
+	ch := make(chan *legacy.Block, subscriptionBuffer)
+	go func() {
+		defer close(ch)
+		for h := fromHeight; ; h++ {
+			err := c.WaitForBlockHeight(ctx, h)
+			if err != nil {
+				return
+			}
+			b, err := c.getBlockRetry(ctx, h)
+			if err != nil {
+				return
+			}
+			select {
+			case ch <- b:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return ch, nil
+}
+
+// getBlockRetry gets the block at height, which must be
+// committed, retrying after errors until ctx is done.
+func (c *Chain) getBlockRetry(ctx context.Context, height uint64) (*legacy.Block, error) {
+	for {
+		b, err := c.store.GetBlock(ctx, height)
+		if err == nil {
+			return b, nil
+		}
+		log.Error(ctx, err, "at", "getting block for subscription", "height", height)
+		select {
+		case <-time.After(time.Second):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
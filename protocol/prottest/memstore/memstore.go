@@ -2,28 +2,155 @@
 // keeps all blockchain state in memory.
 //
 // It is used in tests to avoid needing a database.
+// A persistent MemStore, from NewPersistent, also keeps
+// its blocks in a file, so a dev core can be restarted.
 package memstore
 
 import (
+	"bufio"
 	"context"
+	"encoding/binary"
 	"fmt"
+	"io"
+	"os"
 	"sync"
 
+	"chain/errors"
+	"chain/log"
+	"chain/protocol/bc"
 	"chain/protocol/bc/legacy"
 	"chain/protocol/state"
 )
 
+// ErrNotFound is returned by GetBlock and GetBlockByHash
+// when there's no such block.
+var ErrNotFound = errors.New("memstore: block not found")
+
+// maxRecordSize bounds the length of a block record in a
+// persistent MemStore's file, well above the size of any block
+// a core makes, so a corrupt length can't make load allocate
+// without limit.
+const maxRecordSize = 64 << 20
+
 // MemStore satisfies the Store interface.
 type MemStore struct {
 	mu          sync.Mutex
 	Blocks      map[uint64]*legacy.Block
 	State       *state.Snapshot
 	StateHeight uint64
+
+	byHash map[bc.Hash]*legacy.Block
+
+	// for a persistent MemStore
+	file    blockFile
+	fileErr error // set if file couldn't be repaired after a failed append
+}
+
+// blockFile is the part of *os.File a persistent MemStore uses.
+type blockFile interface {
+	io.ReadWriteSeeker
+	io.Closer
+	Sync() error
+	Truncate(size int64) error
 }
 
 // New returns a new MemStore
 func New() *MemStore {
-	return &MemStore{Blocks: make(map[uint64]*legacy.Block)}
+	return &MemStore{
+		Blocks: make(map[uint64]*legacy.Block),
+		byHash: make(map[bc.Hash]*legacy.Block),
+	}
+}
+
+// NewPersistent returns a MemStore that also appends each block
+// it saves to the file at path, creating it if necessary, and
+// syncs the file before SaveBlock returns. It first loads the
+// blocks already in the file. If the last one was only partly
+// written, it truncates the file to remove it, with a warning.
+//
+// Snapshots aren't persisted, so on startup the chain
+// is recovered by replaying every block.
+//
+// The file holds one record per block: the length of the
+// serialized block, as a 4-byte big-endian integer, followed
+// by the block. A length over maxRecordSize is an error.
+func NewPersistent(path string) (*MemStore, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, errors.Wrap(err, "opening block file")
+	}
+	m := New()
+	m.file = f
+	good, err := m.load()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, errors.Wrap(err, "reading block file")
+	}
+	if good < fi.Size() {
+		log.Printkv(context.Background(), "warning", "truncating partial block record",
+			"path", path, "offset", good, "size", fi.Size())
+		err = f.Truncate(good)
+		if err != nil {
+			f.Close()
+			return nil, errors.Wrap(err, "truncating block file")
+		}
+	}
+	_, err = f.Seek(good, io.SeekStart)
+	if err != nil {
+		f.Close()
+		return nil, errors.Wrap(err, "seeking block file")
+	}
+	return m, nil
+}
+
+// load reads the blocks in m's file into m.Blocks. It returns the
+// offset just past the last complete record. A complete record
+// that can't be decoded is an error.
+func (m *MemStore) load() (int64, error) {
+	r := bufio.NewReader(m.file)
+	var good int64
+	for {
+		var n uint32
+		err := binary.Read(r, binary.BigEndian, &n)
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return good, nil
+		} else if err != nil {
+			return 0, errors.Wrap(err, "reading block file")
+		}
+		if n > maxRecordSize {
+			return 0, fmt.Errorf("block record at offset %d is %d bytes, limit is %d", good, n, maxRecordSize)
+		}
+		buf := make([]byte, n)
+		_, err = io.ReadFull(r, buf)
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return good, nil
+		} else if err != nil {
+			return 0, errors.Wrap(err, "reading block file")
+		}
+		b := new(legacy.Block)
+		err = b.Scan(buf)
+		if err != nil {
+			return 0, errors.Wrapf(err, "decoding block record at offset %d", good)
+		}
+		m.Blocks[b.Height] = b
+		m.byHash[b.Hash()] = b
+		good += 4 + int64(n)
+	}
+}
+
+// Close closes m's file, if it's persistent.
+func (m *MemStore) Close() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.file == nil {
+		return nil
+	}
+	return m.file.Close()
 }
 
 func (m *MemStore) Height(context.Context) (uint64, error) {
@@ -41,10 +168,66 @@ func (m *MemStore) SaveBlock(ctx context.Context, b *legacy.Block) error {
 	if ok && existing.Hash() != b.Hash() {
 		return fmt.Errorf("already have a block at height %d", b.Height)
 	}
+	if !ok && m.file != nil {
+		err := m.appendBlock(b)
+		if err != nil {
+			return err
+		}
+	}
 	m.Blocks[b.Height] = b
+	m.byHash[b.Hash()] = b
 	return nil
 }
 
+// appendBlock writes a record for b to the end of m's file
+// and syncs it. If that fails, it truncates the file back to
+// the end of the last good record, so a partial record isn't
+// left between good ones. m.mu must be held.
+func (m *MemStore) appendBlock(b *legacy.Block) error {
+	if m.fileErr != nil {
+		return m.fileErr
+	}
+	v, err := b.Value()
+	if err != nil {
+		return errors.Wrap(err, "serializing block")
+	}
+	data := v.([]byte)
+	if len(data) > maxRecordSize {
+		return fmt.Errorf("block is %d bytes, limit is %d", len(data), maxRecordSize)
+	}
+	rec := make([]byte, 4+len(data))
+	binary.BigEndian.PutUint32(rec, uint32(len(data)))
+	copy(rec[4:], data)
+
+	end, err := m.file.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return errors.Wrap(err, "seeking block file")
+	}
+	_, err = m.file.Write(rec)
+	if err != nil {
+		err = errors.Wrap(err, "writing block file")
+	} else {
+		err = errors.Wrap(m.file.Sync(), "syncing block file")
+	}
+	if err != nil {
+		m.truncate(end)
+	}
+	return err
+}
+
+// truncate removes everything in m's file after offset end.
+// If it can't, m's file can't be appended to safely, so
+// appendBlock fails from then on. m.mu must be held.
+func (m *MemStore) truncate(end int64) {
+	err := m.file.Truncate(end)
+	if err == nil {
+		_, err = m.file.Seek(end, io.SeekStart)
+	}
+	if err != nil {
+		m.fileErr = errors.Wrap(err, "removing partial block record")
+	}
+}
+
 func (m *MemStore) SaveSnapshot(ctx context.Context, height uint64, snapshot *state.Snapshot) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
@@ -59,7 +242,18 @@ func (m *MemStore) GetBlock(ctx context.Context, height uint64) (*legacy.Block,
 	defer m.mu.Unlock()
 	b, ok := m.Blocks[height]
 	if !ok {
-		return nil, fmt.Errorf("memstore: no block at height %d", height)
+		return nil, errors.WithDetailf(ErrNotFound, "no block at height %d", height)
+	}
+	return b, nil
+}
+
+// GetBlockByHash returns the block with the given hash.
+func (m *MemStore) GetBlockByHash(ctx context.Context, hash bc.Hash) (*legacy.Block, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	b, ok := m.byHash[hash]
+	if !ok {
+		return nil, errors.WithDetailf(ErrNotFound, "no block with hash %x", hash.Bytes())
 	}
 	return b, nil
 }
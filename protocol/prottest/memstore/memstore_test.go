@@ -0,0 +1,250 @@
+package memstore
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"chain/errors"
+	"chain/protocol/bc"
+	"chain/protocol/bc/legacy"
+	"chain/testutil"
+)
+
+func TestPersistent(t *testing.T) {
+	ctx := context.Background()
+	dir, err := ioutil.TempDir("", "memstore")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "blocks")
+
+	m, err := NewPersistent(path)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	var want []bc.Hash
+	for h := uint64(1); h <= 3; h++ {
+		b := testBlock(h)
+		err = m.SaveBlock(ctx, b)
+		if err != nil {
+			testutil.FatalErr(t, err)
+		}
+		// Saving the same block again doesn't add a record.
+		err = m.SaveBlock(ctx, b)
+		if err != nil {
+			testutil.FatalErr(t, err)
+		}
+		want = append(want, b.Hash())
+	}
+	m.Close()
+
+	m, err = NewPersistent(path)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	checkBlocks(ctx, t, m, want)
+	m.Close()
+
+	// Cut the last record short, as if the
+	// core crashed while writing it.
+	fi, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.Truncate(path, fi.Size()-3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err = NewPersistent(path)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	checkBlocks(ctx, t, m, want[:2])
+
+	// The partial record is gone, so the
+	// block can be saved again.
+	b := testBlock(3)
+	err = m.SaveBlock(ctx, b)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	m.Close()
+	m, err = NewPersistent(path)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	checkBlocks(ctx, t, m, want)
+	m.Close()
+}
+
+func TestPersistentBadLength(t *testing.T) {
+	dir, err := ioutil.TempDir("", "memstore")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "blocks")
+
+	// A length prefix far bigger than any record.
+	err = ioutil.WriteFile(path, []byte{0xff, 0xff, 0xff, 0xff, 0}, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = NewPersistent(path)
+	if err == nil {
+		t.Error("NewPersistent(corrupt length) = nil error, want an error")
+	}
+}
+
+// shortWriter writes only half of each write
+// to the file it wraps, then fails.
+type shortWriter struct {
+	blockFile
+}
+
+func (w shortWriter) Write(p []byte) (int, error) {
+	n, err := w.blockFile.Write(p[:len(p)/2])
+	if err != nil {
+		return n, err
+	}
+	return n, errors.New("disk full")
+}
+
+func TestPersistentFailedAppend(t *testing.T) {
+	ctx := context.Background()
+	dir, err := ioutil.TempDir("", "memstore")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "blocks")
+
+	m, err := NewPersistent(path)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	b1, b2 := testBlock(1), testBlock(2)
+	err = m.SaveBlock(ctx, b1)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+
+	f := m.file
+	m.file = shortWriter{f}
+	err = m.SaveBlock(ctx, b2)
+	if err == nil {
+		t.Fatal("SaveBlock with a failing write = nil error, want an error")
+	}
+	m.file = f
+
+	// The partial record was removed, so the
+	// block can be saved again after it.
+	err = m.SaveBlock(ctx, b2)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	m.Close()
+	m, err = NewPersistent(path)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	checkBlocks(ctx, t, m, []bc.Hash{b1.Hash(), b2.Hash()})
+	m.Close()
+}
+
+func checkBlocks(ctx context.Context, t *testing.T, m *MemStore, want []bc.Hash) {
+	height, err := m.Height(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if height != uint64(len(want)) {
+		t.Fatalf("height = %d want %d", height, len(want))
+	}
+	for i, h := range want {
+		b, err := m.GetBlock(ctx, uint64(i+1))
+		if err != nil {
+			testutil.FatalErr(t, err)
+		}
+		if b.Hash() != h {
+			t.Errorf("block %d hash = %x want %x", i+1, b.Hash().Bytes(), h.Bytes())
+		}
+	}
+}
+
+func testBlock(height uint64) *legacy.Block {
+	return &legacy.Block{
+		BlockHeader: legacy.BlockHeader{
+			Version:     1,
+			Height:      height,
+			TimestampMS: bc.Millis(time.Unix(int64(height), 0)),
+		},
+		Transactions: []*legacy.Tx{
+			legacy.NewTx(legacy.TxData{Version: 1, ReferenceData: []byte{byte(height)}}),
+		},
+	}
+}
+
+func TestGetBlockByHash(t *testing.T) {
+	ctx := context.Background()
+	m := New()
+	b := testBlock(1)
+	err := m.SaveBlock(ctx, b)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+
+	got, err := m.GetBlockByHash(ctx, b.Hash())
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	if got != b {
+		t.Errorf("GetBlockByHash() = %v want %v", got, b)
+	}
+
+	_, err = m.GetBlockByHash(ctx, bc.Hash{})
+	if errors.Root(err) != ErrNotFound {
+		t.Errorf("GetBlockByHash(unknown) = %v want %v", err, ErrNotFound)
+	}
+	_, err = m.GetBlock(ctx, 2)
+	if errors.Root(err) != ErrNotFound {
+		t.Errorf("GetBlock(2) = %v want %v", err, ErrNotFound)
+	}
+}
+
+func BenchmarkGetBlock(b *testing.B) {
+	ctx := context.Background()
+	for _, height := range []uint64{100, 10000} {
+		m := New()
+		var hashes []bc.Hash
+		for h := uint64(1); h <= height; h++ {
+			blk := testBlock(h)
+			err := m.SaveBlock(ctx, blk)
+			if err != nil {
+				b.Fatal(err)
+			}
+			hashes = append(hashes, blk.Hash())
+		}
+		b.Run(fmt.Sprintf("height/%d", height), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				_, err := m.GetBlock(ctx, uint64(i)%height+1)
+				if err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+		b.Run(fmt.Sprintf("hash/%d", height), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				_, err := m.GetBlockByHash(ctx, hashes[uint64(i)%height])
+				if err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
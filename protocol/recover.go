@@ -2,13 +2,19 @@ package protocol
 
 import (
 	"context"
-	"fmt"
 
 	"chain/errors"
+	"chain/log"
+	"chain/protocol/bc"
 	"chain/protocol/bc/legacy"
 	"chain/protocol/state"
+	"chain/protocol/validation"
 )
 
+// rebuildLogBlocks is how often, in blocks, RebuildSnapshot
+// logs its progress and saves the snapshot it has so far.
+const rebuildLogBlocks = 10000
+
 // Recover performs crash recovery, restoring the blockchain
 // to a complete state. It returns the latest confirmed block
 // and the corresponding state snapshot.
@@ -17,7 +23,10 @@ import (
 // returns a nil block and an empty snapshot.
 func (c *Chain) Recover(ctx context.Context) (*legacy.Block, *state.Snapshot, error) {
 	snapshot, snapshotHeight, err := c.store.LatestSnapshot(ctx)
-	if err != nil {
+	if errors.Root(err) == ErrBadSnapshot {
+		log.Error(ctx, err, "replaying blockchain from the initial block")
+		snapshot, snapshotHeight = nil, 0
+	} else if err != nil {
 		return nil, nil, errors.Wrap(err, "getting latest snapshot")
 	}
 	var b *legacy.Block
@@ -26,7 +35,15 @@ func (c *Chain) Recover(ctx context.Context) (*legacy.Block, *state.Snapshot, er
 		if err != nil {
 			return nil, nil, errors.Wrap(err, "getting snapshot block")
 		}
-		c.lastQueuedSnapshot = b.Time()
+		if b.AssetsMerkleRoot != snapshot.Tree.RootHash() {
+			err = errors.WithDetailf(ErrBadSnapshot, "block %d has state root %x; snapshot has root %x",
+				b.Height, b.AssetsMerkleRoot.Bytes(), snapshot.Tree.RootHash().Bytes())
+			log.Error(ctx, err, "replaying blockchain from the initial block")
+			snapshot, snapshotHeight, b = nil, 0, nil
+		} else {
+			c.lastQueuedSnapshot = b.Time()
+			c.lastQueuedSnapshotHeight = b.Height
+		}
 	}
 	if snapshot == nil {
 		snapshot = state.Empty()
@@ -41,19 +58,9 @@ func (c *Chain) Recover(ctx context.Context) (*legacy.Block, *state.Snapshot, er
 	}
 
 	// Bring the snapshot up to date with the latest block
-	for h := snapshotHeight + 1; h <= height; h++ {
-		b, err = c.store.GetBlock(ctx, h)
-		if err != nil {
-			return nil, nil, errors.Wrap(err, "getting block")
-		}
-		err = snapshot.ApplyBlock(legacy.MapBlock(b))
-		if err != nil {
-			return nil, nil, errors.Wrap(err, "applying block")
-		}
-		if b.AssetsMerkleRoot != snapshot.Tree.RootHash() {
-			return nil, nil, fmt.Errorf("block %d has state root %x; snapshot has root %x",
-				b.Height, b.AssetsMerkleRoot.Bytes(), snapshot.Tree.RootHash().Bytes())
-		}
+	b, err = replayBlocks(ctx, c.store, snapshot, b, height, nil, nil)
+	if err != nil {
+		return nil, nil, err
 	}
 	if b != nil {
 		// All blocks before the latest one have been fully processed
@@ -68,3 +75,128 @@ func (c *Chain) Recover(ctx context.Context) (*legacy.Block, *state.Snapshot, er
 	}
 	return b, snapshot, nil
 }
+
+// RebuildSnapshot rebuilds the state snapshot by validating and
+// applying each block in store, for recovering from a corrupt
+// snapshot. It returns the snapshot and the height of the last
+// block, having saved the snapshot to store.
+//
+// Every rebuildLogBlocks blocks, it logs its progress and saves
+// the snapshot so far. If resume is true and store's latest
+// snapshot matches the state root of its block, it starts from
+// there, so a rebuild that was interrupted can pick up where it
+// left off. Otherwise it starts from the initial block.
+func RebuildSnapshot(ctx context.Context, store Store, resume bool) (*state.Snapshot, uint64, error) {
+	height, err := store.Height(ctx)
+	if err != nil {
+		return nil, 0, errors.Wrap(err, "getting blockchain height")
+	}
+	if height == 0 {
+		return state.Empty(), 0, nil
+	}
+	initial, err := store.GetBlock(ctx, 1)
+	if err != nil {
+		return nil, 0, errors.Wrap(err, "getting initial block")
+	}
+	initialHash := initial.Hash()
+
+	snapshot := state.Empty()
+	var prev *legacy.Block
+	if resume {
+		s, h, err := store.LatestSnapshot(ctx)
+		if err != nil && errors.Root(err) != ErrBadSnapshot {
+			return nil, 0, errors.Wrap(err, "getting latest snapshot")
+		}
+		if err == nil && h > 0 && h <= height {
+			b, err := store.GetBlock(ctx, h)
+			if err != nil {
+				return nil, 0, errors.Wrap(err, "getting snapshot block")
+			}
+			if b.AssetsMerkleRoot == s.Tree.RootHash() {
+				snapshot, prev = s, b
+			}
+		}
+	}
+
+	validateTx := func(tx *bc.Tx) error {
+		return validation.ValidateTx(tx, initialHash)
+	}
+	validate := func(b, prev *legacy.Block) error {
+		blockEnts, prevEnts := legacy.MapBlock(b), legacy.MapBlock(prev)
+		err := validation.ValidateBlock(blockEnts, prevEnts, initialHash, validateTx)
+		if err != nil {
+			return err
+		}
+		if prev == nil {
+			return nil
+		}
+		return validation.ValidateBlockSig(blockEnts, prevEnts.NextConsensusProgram)
+	}
+	applied := func(b *legacy.Block) error {
+		if b.Height%rebuildLogBlocks != 0 && b.Height != height {
+			return nil
+		}
+		log.Printkv(ctx, "rebuilding snapshot", b.Height, "height", height)
+		return errors.Wrap(store.SaveSnapshot(ctx, b.Height, snapshot), "saving snapshot")
+	}
+	_, err = replayBlocks(ctx, store, snapshot, prev, height, validate, applied)
+	if err != nil {
+		return nil, 0, err
+	}
+	return snapshot, height, nil
+}
+
+// replayBlocks applies the blocks in store after prev, or from
+// the initial block if prev is nil, through height to to
+// snapshot, checking each block's size and state root.
+//
+// If validate is not nil, each block is validated with it before
+// it's applied. If applied is not nil, it's called after each
+// block is applied. replayBlocks returns the last block applied,
+// or prev if there were none.
+func replayBlocks(
+	ctx context.Context,
+	store Store,
+	snapshot *state.Snapshot,
+	prev *legacy.Block,
+	to uint64,
+	validate func(b, prev *legacy.Block) error,
+	applied func(*legacy.Block) error,
+) (*legacy.Block, error) {
+	from := uint64(1)
+	if prev != nil {
+		from = prev.Height + 1
+	}
+	for h := from; h <= to; h++ {
+		b, err := store.GetBlock(ctx, h)
+		if err != nil {
+			return nil, errors.Wrapf(err, "getting block %d", h)
+		}
+		err = checkBlockSize(b)
+		if err != nil {
+			return nil, errors.Wrapf(err, "validating block %d", h)
+		}
+		if validate != nil {
+			err = validate(b, prev)
+			if err != nil {
+				return nil, errors.Wrapf(err, "validating block %d", h)
+			}
+		}
+		err = snapshot.ApplyBlock(legacy.MapBlock(b))
+		if err != nil {
+			return nil, errors.Wrapf(err, "applying block %d", h)
+		}
+		if b.AssetsMerkleRoot != snapshot.Tree.RootHash() {
+			return nil, errors.WithDetailf(ErrBadStateRoot, "block %d has state root %x; snapshot has root %x",
+				h, b.AssetsMerkleRoot.Bytes(), snapshot.Tree.RootHash().Bytes())
+		}
+		if applied != nil {
+			err = applied(b)
+			if err != nil {
+				return nil, err
+			}
+		}
+		prev = b
+	}
+	return prev, nil
+}
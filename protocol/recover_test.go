@@ -6,6 +6,7 @@ import (
 	"testing"
 	"time"
 
+	"chain/errors"
 	"chain/protocol/bc"
 	"chain/protocol/bc/legacy"
 	"chain/protocol/prottest/memstore"
@@ -57,6 +58,205 @@ func TestRecoverSnapshotNoAdditionalBlocks(t *testing.T) {
 	}
 }
 
+func TestRecoverFromSnapshot(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	store := memstore.New()
+	b1, err := NewInitialBlock(nil, 0, now)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	c1, err := NewChain(ctx, b1.Hash(), store, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = c1.CommitAppliedBlock(ctx, b1, state.Empty())
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	// Wait for the asynchronous snapshot of b1,
+	// so it doesn't overwrite the ones saved below.
+	for {
+		_, height, _ := store.LatestSnapshot(ctx)
+		if height > 0 {
+			break
+		}
+	}
+
+	snapshots := map[uint64]*state.Snapshot{}
+	prev := b1
+	for h := 2; h <= 5; h++ {
+		tx := issueProgram(t, c1, trueProgram, nil, 1)
+		prev = generateAndCommit(t, c1, prev, now.Add(time.Duration(h)*time.Second), tx)
+		_, snapshots[prev.Height] = c1.State()
+	}
+	wantRoot := snapshots[5].Tree.RootHash()
+
+	// A store holding the same blocks and no snapshot,
+	// so recovering replays from the initial block.
+	replayStore := memstore.New()
+	for h := uint64(1); h <= 5; h++ {
+		b, err := store.GetBlock(ctx, h)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = replayStore.SaveBlock(ctx, b)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cases := []struct {
+		name  string
+		store Store
+		save  func()
+	}{{
+		name:  "snapshot",
+		store: store,
+		save: func() {
+			err := store.SaveSnapshot(ctx, 3, snapshots[3])
+			if err != nil {
+				t.Fatal(err)
+			}
+		},
+	}, {
+		name:  "replay",
+		store: replayStore,
+		save:  func() {},
+	}, {
+		name:  "bad snapshot",
+		store: badSnapshotStore{store},
+		save:  func() {},
+	}, {
+		name:  "mismatched snapshot",
+		store: store,
+		save: func() {
+			err := store.SaveSnapshot(ctx, 4, snapshots[3])
+			if err != nil {
+				t.Fatal(err)
+			}
+		},
+	}}
+	for _, tc := range cases {
+		tc.save()
+		c, err := NewChain(ctx, b1.Hash(), tc.store, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		block, snapshot, err := c.Recover(ctx)
+		if err != nil {
+			t.Errorf("%s: Recover() = %v", tc.name, err)
+			continue
+		}
+		if block.Height != 5 {
+			t.Errorf("%s: recovered height = %d want 5", tc.name, block.Height)
+		}
+		if got := snapshot.Tree.RootHash(); got != wantRoot {
+			t.Errorf("%s: recovered state root = %x want %x", tc.name, got.Bytes(), wantRoot.Bytes())
+		}
+	}
+}
+
+func TestRebuildSnapshot(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	c, b1 := newTestChain(t, now)
+	snapshots := map[uint64]*state.Snapshot{}
+	prev := b1
+	for h := 2; h <= 5; h++ {
+		tx := issueProgram(t, c, trueProgram, nil, 1)
+		prev = generateAndCommit(t, c, prev, now.Add(time.Duration(h)*time.Second), tx)
+		_, snapshots[prev.Height] = c.State()
+	}
+	wantRoot := snapshots[5].Tree.RootHash()
+
+	cases := []struct {
+		name     string
+		resume   bool
+		snapshot *state.Snapshot
+		height   uint64
+	}{
+		{name: "no snapshot"},
+		{name: "ignore snapshot", resume: false, snapshot: snapshots[3], height: 3},
+		{name: "resume", resume: true, snapshot: snapshots[3], height: 3},
+		{name: "resume mismatched", resume: true, snapshot: snapshots[3], height: 4},
+		{name: "resume at tip", resume: true, snapshot: snapshots[5], height: 5},
+	}
+	for _, tc := range cases {
+		store := memstore.New()
+		for h := uint64(1); h <= 5; h++ {
+			b, err := c.GetBlock(ctx, h)
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = store.SaveBlock(ctx, b)
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+		if tc.snapshot != nil {
+			err := store.SaveSnapshot(ctx, tc.height, tc.snapshot)
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		snapshot, height, err := RebuildSnapshot(ctx, store, tc.resume)
+		if err != nil {
+			t.Errorf("%s: RebuildSnapshot() = %v", tc.name, err)
+			continue
+		}
+		if height != 5 {
+			t.Errorf("%s: rebuilt height = %d want 5", tc.name, height)
+		}
+		if got := snapshot.Tree.RootHash(); got != wantRoot {
+			t.Errorf("%s: rebuilt state root = %x want %x", tc.name, got.Bytes(), wantRoot.Bytes())
+		}
+		saved, savedHeight, err := store.LatestSnapshot(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if savedHeight != 5 || saved.Tree.RootHash() != wantRoot {
+			t.Errorf("%s: saved snapshot at height %d with root %x, want height 5 with root %x",
+				tc.name, savedHeight, saved.Tree.RootHash().Bytes(), wantRoot.Bytes())
+		}
+	}
+}
+
+func TestRebuildSnapshotBadBlock(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	c, b1 := newTestChain(t, now)
+	b2 := generateAndCommit(t, c, b1, now.Add(time.Second), issueProgram(t, c, trueProgram, nil, 1))
+
+	store := memstore.New()
+	err := store.SaveBlock(ctx, b1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bad := *b2
+	bad.AssetsMerkleRoot = bc.Hash{}
+	err = store.SaveBlock(ctx, &bad)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, _, err = RebuildSnapshot(ctx, store, false)
+	if errors.Root(err) != ErrBadStateRoot {
+		t.Errorf("RebuildSnapshot() = %v want %v", err, ErrBadStateRoot)
+	}
+}
+
+// badSnapshotStore is a Store whose
+// snapshot can't be decoded.
+type badSnapshotStore struct {
+	*memstore.MemStore
+}
+
+func (badSnapshotStore) LatestSnapshot(context.Context) (*state.Snapshot, uint64, error) {
+	return nil, 0, errors.Wrap(ErrBadSnapshot, "decoding snapshot")
+}
+
 func createEmptyBlock(block *legacy.Block, snapshot *state.Snapshot) *legacy.Block {
 	root, err := bc.MerkleRoot(nil)
 	if err != nil {
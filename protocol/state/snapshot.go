@@ -6,6 +6,7 @@ import (
 	"chain/errors"
 	"chain/protocol/bc"
 	"chain/protocol/patricia"
+	"chain/protocol/validation"
 )
 
 // Snapshot encompasses a snapshot of entire blockchain state. It
@@ -93,7 +94,7 @@ func (s *Snapshot) ApplyTx(tx *bc.Tx) error {
 	// Remove spent outputs. Each output must be present.
 	for _, prevout := range tx.SpentOutputIDs {
 		if !s.Tree.Contains(prevout.Bytes()) {
-			return fmt.Errorf("invalid prevout %x", prevout.Bytes())
+			return errors.WithDetailf(validation.ErrDoubleSpend, "prevout %x", prevout.Bytes())
 		}
 		s.Tree.Delete(prevout.Bytes())
 	}
@@ -5,9 +5,11 @@ import (
 	"testing"
 	"time"
 
+	"chain/errors"
 	"chain/protocol/bc"
 	"chain/protocol/bc/bctest"
 	"chain/protocol/bc/legacy"
+	"chain/protocol/validation"
 )
 
 func TestApplyTxSpend(t *testing.T) {
@@ -46,8 +48,8 @@ func TestApplyTxSpend(t *testing.T) {
 		t.Error("snapshot contains spent prevout")
 	}
 	err = snap.ApplyTx(tx)
-	if err == nil {
-		t.Error("expected error applying spend twice, got nil")
+	if errors.Root(err) != validation.ErrDoubleSpend {
+		t.Errorf("applying spend twice: got error %v, want %v", err, validation.ErrDoubleSpend)
 	}
 }
 
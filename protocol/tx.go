@@ -1,7 +1,9 @@
 package protocol
 
 import (
+	"expvar"
 	"sync"
+	"time"
 
 	"github.com/golang/groupcache/lru"
 
@@ -10,24 +12,65 @@ import (
 	"chain/protocol/validation"
 )
 
-// ErrBadTx is returned for transactions failing validation
+// ErrBadTx is returned for transactions failing validation,
+// unless validation gives one of its exported errors.
 var ErrBadTx = errors.New("invalid transaction")
 
+// ErrIssuanceWindow is returned for transactions with an issuance
+// whose time range is longer than the chain's MaxIssuanceWindow,
+// or which starts too far in the future.
+var ErrIssuanceWindow = errors.New("issuance outside the allowed time window")
+
+// validateTxCacheHits and validateTxCacheMisses count the calls
+// to ValidateTx answered from its cache and the ones that ran
+// full validation.
+var (
+	validateTxCacheHits   = expvar.NewInt("validate_tx_cache_hits")
+	validateTxCacheMisses = expvar.NewInt("validate_tx_cache_misses")
+)
+
+// DefaultMaxIssuanceSkew is how far in the future an issuance's
+// min time may be, by default, for CheckIssuanceTime to admit it.
+const DefaultMaxIssuanceSkew = 10 * time.Minute
+
+// subValidationErr returns err, from validation, with root
+// sub, unless its root is one of the errors validation exports
+// for callers to tell apart. Those are kept.
+func subValidationErr(sub, err error) error {
+	switch errors.Root(err) {
+	case validation.ErrBadTimestamp, validation.ErrBadTxRoot, validation.ErrDoubleSpend, validation.ErrProgramFailure:
+		return err
+	}
+	return errors.Sub(sub, err)
+}
+
 // ValidateTx validates the given transaction. A cache holds
 // per-transaction validation results and is consulted before
-// performing full validation.
+// performing full validation. The cache is keyed by the
+// transaction's witness hash, not its ID, so a copy of a valid
+// transaction with bad signatures is never mistaken for it.
+//
+// ValidateTx performs only the checks that depend on the
+// transaction alone, so its results, invalid ones included, never
+// go stale and needn't expire. Whether the transaction's inputs
+// are still unspent is checked against the state each time it's
+// applied, and never cached.
 func (c *Chain) ValidateTx(tx *bc.Tx) error {
 	err := c.checkIssuanceWindow(tx)
 	if err != nil {
 		return err
 	}
 	var ok bool
-	err, ok = c.prevalidated.lookup(tx.ID)
-	if !ok {
+	key := tx.WitnessHash()
+	err, ok = c.prevalidated.lookup(key)
+	if ok {
+		validateTxCacheHits.Add(1)
+	} else {
+		validateTxCacheMisses.Add(1)
 		err = validation.ValidateTx(tx, c.InitialBlockHash)
-		c.prevalidated.cache(tx.ID, err)
+		c.prevalidated.cache(key, err)
 	}
-	return errors.Sub(ErrBadTx, err)
+	return subValidationErr(ErrBadTx, err)
 }
 
 type prevalidatedTxsCache struct {
@@ -35,9 +78,9 @@ type prevalidatedTxsCache struct {
 	lru *lru.Cache
 }
 
-func (c *prevalidatedTxsCache) lookup(txID bc.Hash) (err error, ok bool) {
+func (c *prevalidatedTxsCache) lookup(key bc.Hash) (err error, ok bool) {
 	c.mu.Lock()
-	v, ok := c.lru.Get(txID)
+	v, ok := c.lru.Get(key)
 	c.mu.Unlock()
 	if !ok {
 		return err, ok
@@ -48,9 +91,9 @@ func (c *prevalidatedTxsCache) lookup(txID bc.Hash) (err error, ok bool) {
 	return v.(error), ok
 }
 
-func (c *prevalidatedTxsCache) cache(txID bc.Hash, err error) {
+func (c *prevalidatedTxsCache) cache(key bc.Hash, err error) {
 	c.mu.Lock()
-	c.lru.Add(txID, err)
+	c.lru.Add(key, err)
 	c.mu.Unlock()
 }
 
@@ -61,7 +104,32 @@ func (c *Chain) checkIssuanceWindow(tx *bc.Tx) error {
 	for _, entryID := range tx.InputIDs {
 		if _, err := tx.Issuance(entryID); err == nil {
 			if tx.MinTimeMs+bc.DurationMillis(c.MaxIssuanceWindow) < tx.MaxTimeMs {
-				return errors.WithDetailf(ErrBadTx, "issuance input's time window is larger than the network maximum (%s)", c.MaxIssuanceWindow)
+				return errors.WithDetailf(ErrIssuanceWindow, "issuance input's time window is larger than the network maximum (%s)", c.MaxIssuanceWindow)
+			}
+		}
+	}
+	return nil
+}
+
+// CheckIssuanceTime checks, for admitting tx to a transaction
+// pool at time now, that any issuance in it has a time range no
+// longer than c.MaxIssuanceWindow, and a min time no further than
+// c.MaxIssuanceSkew in the future. Unlike ValidateTx's checks,
+// its result depends on now, so it's neither cached nor applied
+// to blocks.
+func (c *Chain) CheckIssuanceTime(tx *bc.Tx, now time.Time) error {
+	err := c.checkIssuanceWindow(tx)
+	if err != nil {
+		return err
+	}
+	skew := c.MaxIssuanceSkew
+	if skew == 0 {
+		skew = DefaultMaxIssuanceSkew
+	}
+	for _, entryID := range tx.InputIDs {
+		if _, err := tx.Issuance(entryID); err == nil {
+			if tx.MinTimeMs > bc.Millis(now.Add(skew)) {
+				return errors.WithDetailf(ErrIssuanceWindow, "issuance input's min time is more than %s in the future", skew)
 			}
 		}
 	}
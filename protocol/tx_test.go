@@ -2,6 +2,7 @@ package protocol
 
 import (
 	"context"
+	"crypto/rand"
 	"fmt"
 	"testing"
 	"time"
@@ -9,9 +10,11 @@ import (
 	"golang.org/x/crypto/sha3"
 
 	"chain/crypto/ed25519"
+	"chain/errors"
 	"chain/protocol/bc"
 	"chain/protocol/bc/legacy"
 	"chain/protocol/state"
+	"chain/protocol/validation"
 	"chain/protocol/vm"
 	"chain/protocol/vm/vmutil"
 	"chain/testutil"
@@ -33,6 +36,196 @@ func TestBadMaxIssuanceWindow(t *testing.T) {
 	}
 }
 
+func TestCheckIssuanceTime(t *testing.T) {
+	c, _ := newTestChain(t, time.Now())
+	c.MaxIssuanceWindow = time.Hour
+	c.MaxIssuanceSkew = time.Minute
+	now := time.Now()
+
+	cases := []struct {
+		name     string
+		min, max time.Time
+		wantErr  error
+	}{
+		{"within window", now, now.Add(time.Minute), nil},
+		{"exactly the window", now, now.Add(time.Hour), nil},
+		{"one second over the window", now, now.Add(time.Hour + time.Second), ErrIssuanceWindow},
+		{"exactly the skew", now.Add(time.Minute), now.Add(time.Hour), nil},
+		{"one second over the skew", now.Add(time.Minute + time.Second), now.Add(time.Hour), ErrIssuanceWindow},
+	}
+	for _, tc := range cases {
+		tx := issueProgram(t, c, trueProgram, nil, 1)
+		tx.MinTime = bc.Millis(tc.min)
+		tx.MaxTime = bc.Millis(tc.max)
+		tx = legacy.NewTx(tx.TxData)
+
+		err := c.CheckIssuanceTime(tx.Tx, now)
+		if errors.Root(err) != tc.wantErr {
+			t.Errorf("%s: CheckIssuanceTime() = %v want %v", tc.name, err, tc.wantErr)
+		}
+	}
+
+	// Spends aren't subject to the issuance window.
+	src := issueProgram(t, c, trueProgram, nil, 1)
+	spend := spendOutput(t, src, 0)
+	spend.MinTime = bc.Millis(now.Add(48 * time.Hour))
+	spend = legacy.NewTx(spend.TxData)
+	err := c.CheckIssuanceTime(spend.Tx, now)
+	if err != nil {
+		t.Errorf("CheckIssuanceTime(spend) = %v want nil", err)
+	}
+}
+
+func TestValidateTxCacheWitness(t *testing.T) {
+	c, _ := newTestChain(t, time.Now())
+	prog, err := vm.Assemble("7 EQUAL")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx := issueProgram(t, c, prog, [][]byte{{7}}, 1)
+	err = c.ValidateTx(tx.Tx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Same ID, different witness: the cached
+	// result for tx must not apply.
+	bad := tx.Copy()
+	bad.SetInputArguments(0, [][]byte{{8}})
+	if bad.ID != tx.ID {
+		t.Fatalf("mutated tx ID = %x want %x", bad.ID.Bytes(), tx.ID.Bytes())
+	}
+	err = c.ValidateTx(bad.Tx)
+	if errors.Root(err) != validation.ErrProgramFailure {
+		t.Errorf("ValidateTx(mutated) = %v want %v", err, validation.ErrProgramFailure)
+	}
+
+	err = c.ValidateTx(tx.Tx)
+	if err != nil {
+		t.Errorf("ValidateTx(original) after mutated = %v want nil", err)
+	}
+}
+
+func TestValidateTxCacheInvalid(t *testing.T) {
+	c, _ := newTestChain(t, time.Now())
+	prog, err := vm.Assemble("7 EQUAL")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx := issueProgram(t, c, prog, [][]byte{{8}}, 1)
+
+	hits, misses := validateTxCacheHits.Value(), validateTxCacheMisses.Value()
+	for i := 0; i < 2; i++ {
+		err = c.ValidateTx(tx.Tx)
+		if errors.Root(err) != validation.ErrProgramFailure {
+			t.Errorf("ValidateTx() attempt %d = %v want %v", i, err, validation.ErrProgramFailure)
+		}
+	}
+	if n := validateTxCacheMisses.Value() - misses; n != 1 {
+		t.Errorf("full validation ran %d times, want 1", n)
+	}
+	if n := validateTxCacheHits.Value() - hits; n != 1 {
+		t.Errorf("cache hits = %d want 1", n)
+	}
+}
+
+func TestValidateTxCachedDoubleSpend(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	c, b1 := newTestChain(t, now)
+
+	iss := issueProgram(t, c, trueProgram, nil, 1)
+	b2 := generateAndCommit(t, c, b1, now.Add(time.Second), iss)
+	if len(b2.Transactions) != 1 {
+		t.Fatalf("block 2 has %d txs, want 1", len(b2.Transactions))
+	}
+
+	spend := spendOutput(t, iss, 0)
+	err := c.ValidateTx(spend.Tx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b3 := generateAndCommit(t, c, b2, now.Add(2*time.Second), spend)
+	if len(b3.Transactions) != 1 {
+		t.Fatalf("block 3 has %d txs, want 1", len(b3.Transactions))
+	}
+
+	// The cached result only covers the stateless checks,
+	// so it still says the tx is valid.
+	err = c.ValidateTx(spend.Tx)
+	if err != nil {
+		t.Errorf("ValidateTx(spent) = %v want nil", err)
+	}
+
+	got, _, err := c.GenerateBlock(ctx, b3, state.Empty(), now.Add(3*time.Second), []*legacy.Tx{spend})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got.Transactions) != 0 {
+		t.Error("generated block includes a tx spending a spent output")
+	}
+
+	b4 := &legacy.Block{BlockHeader: b3.BlockHeader, Transactions: b3.Transactions}
+	b4.Height = b3.Height + 1
+	b4.PreviousBlockHash = b3.Hash()
+	b4.TimestampMS = b3.TimestampMS + 1
+	err = c.ValidateBlock(b4, b3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = c.CommitBlock(ctx, b4)
+	if errors.Root(err) != validation.ErrDoubleSpend {
+		t.Errorf("CommitBlock(double spend) = %v want %v", err, validation.ErrDoubleSpend)
+	}
+}
+
+// trueProgram is a control or issuance
+// program satisfied by any arguments.
+var trueProgram = []byte{byte(vm.OP_TRUE)}
+
+// issueProgram returns a tx issuing amount units, with a random
+// nonce, of the asset with issuance program prog on c's
+// blockchain. The output's control program is trueProgram.
+func issueProgram(tb testing.TB, c *Chain, prog []byte, args [][]byte, amount uint64) *legacy.Tx {
+	nonce := make([]byte, 8)
+	_, err := rand.Read(nonce)
+	if err != nil {
+		testutil.FatalErr(tb, err)
+	}
+	assetID := bc.ComputeAssetID(prog, &c.InitialBlockHash, 1, &bc.EmptyStringHash)
+	return legacy.NewTx(legacy.TxData{
+		Version: 1,
+		Inputs: []*legacy.TxInput{
+			legacy.NewIssuanceInput(nonce, amount, nil, c.InitialBlockHash, prog, args, nil),
+		},
+		Outputs: []*legacy.TxOutput{
+			legacy.NewTxOutput(assetID, amount, trueProgram, nil),
+		},
+		MinTime: bc.Millis(time.Now()),
+		MaxTime: bc.Millis(time.Now().Add(time.Hour)),
+	})
+}
+
+// spendOutput returns a tx spending output index of src,
+// which must have control program trueProgram, to a new
+// output with trueProgram.
+func spendOutput(tb testing.TB, src *legacy.Tx, index int) *legacy.Tx {
+	out, err := src.Tx.Output(*src.Tx.ResultIds[index])
+	if err != nil {
+		testutil.FatalErr(tb, err)
+	}
+	v := out.Source.Value
+	return legacy.NewTx(legacy.TxData{
+		Version: 1,
+		Inputs: []*legacy.TxInput{
+			legacy.NewSpendInput(nil, *out.Source.Ref, *v.AssetId, v.Amount, out.Source.Position, out.ControlProgram.Code, *out.Data, nil),
+		},
+		Outputs: []*legacy.TxOutput{
+			legacy.NewTxOutput(*v.AssetId, v.Amount, trueProgram, nil),
+		},
+	})
+}
+
 type testDest struct {
 	privKey ed25519.PrivateKey
 }
@@ -1,9 +1,13 @@
 package validation
 
 import (
+	"fmt"
+	"runtime"
+	"strings"
 	"testing"
 	"time"
 
+	"chain/errors"
 	"chain/protocol/bc"
 	"chain/protocol/bc/legacy"
 	"chain/protocol/vm"
@@ -67,6 +71,151 @@ func TestValidateBlockSig2Err(t *testing.T) {
 	}
 }
 
+func TestValidationErrorKinds(t *testing.T) {
+	b1 := newInitialBlock(t)
+
+	b2 := generate(t, b1)
+	b2.TimestampMs = b1.TimestampMs
+	err := ValidateBlock(b2, b1, b1.ID, dummyValidateTx)
+	if errors.Root(err) != ErrBadTimestamp {
+		t.Errorf("block no later than its predecessor: got error %v, want %v", err, ErrBadTimestamp)
+	}
+
+	b2 = generate(t, b1)
+	transactionsRoot := bc.NewHash([32]byte{1})
+	b2.TransactionsRoot = &transactionsRoot
+	err = ValidateBlock(b2, b1, b1.ID, dummyValidateTx)
+	if errors.Root(err) != ErrBadTxRoot {
+		t.Errorf("wrong transactions root: got error %v, want %v", err, ErrBadTxRoot)
+	}
+
+	fixture := sample(t, nil)
+	tx := legacy.NewTx(*fixture.tx).Tx
+	spend := txSpend(t, tx, 1)
+	spend.WitnessArguments[0] = []byte{}
+	err = ValidateTx(tx, fixture.initialBlockID)
+	if errors.Root(err) != ErrProgramFailure {
+		t.Fatalf("failing control program: got error %v, want %v", err, ErrProgramFailure)
+	}
+	if got := errors.Data(err)["input_index"]; got != uint64(1) {
+		t.Errorf("failing control program: input_index = %v want 1", got)
+	}
+}
+
+func TestValidateBlockFirstBadTx(t *testing.T) {
+	b1 := newInitialBlock(t)
+	errBad := errors.New("bad tx")
+
+	cases := []struct {
+		bad      []int // txs validateTx rejects
+		untimely int   // tx outside the block's time range, or -1
+		wantTx   int
+		wantErr  error
+	}{
+		{bad: nil, untimely: -1, wantTx: -1},
+		{bad: []int{37}, untimely: -1, wantTx: 37, wantErr: errBad},
+		{bad: []int{80, 37, 99}, untimely: -1, wantTx: 37, wantErr: errBad},
+		{bad: []int{0, 1, 2, 3}, untimely: -1, wantTx: 0, wantErr: errBad},
+		{bad: []int{60}, untimely: 50, wantTx: 50, wantErr: ErrBadTimestamp},
+		{bad: []int{50}, untimely: 50, wantTx: 50, wantErr: ErrBadTimestamp},
+		{bad: []int{40, 70}, untimely: 50, wantTx: 40, wantErr: errBad},
+	}
+	for _, c := range cases {
+		// Run each case a few times, since which
+		// goroutine gets which tx varies.
+		for j := 0; j < 10; j++ {
+			b := generateWithTxs(t, b1, 100)
+			bad := make(map[*bc.Tx]bool)
+			for _, i := range c.bad {
+				bad[b.Transactions[i]] = true
+			}
+			if c.untimely >= 0 {
+				b.Transactions[c.untimely].MaxTimeMs = b.TimestampMs - 1
+			}
+			validateTx := func(tx *bc.Tx) error {
+				if bad[tx] {
+					return errBad
+				}
+				return nil
+			}
+
+			err := ValidateBlock(b, b1, b1.ID, validateTx)
+			if c.wantTx < 0 {
+				if err != nil {
+					t.Errorf("bad %v untimely %d: ValidateBlock = %v want nil", c.bad, c.untimely, err)
+				}
+				continue
+			}
+			if errors.Root(err) != c.wantErr {
+				t.Errorf("bad %v untimely %d: ValidateBlock = %v want %v", c.bad, c.untimely, err, c.wantErr)
+			}
+			if c.wantErr == errBad && !strings.Contains(err.Error(), fmt.Sprintf("transaction %d of", c.wantTx)) {
+				t.Errorf("bad %v untimely %d: ValidateBlock = %v want tx %d", c.bad, c.untimely, err, c.wantTx)
+			}
+		}
+	}
+}
+
+func TestValidateBlockTxsConcurrently(t *testing.T) {
+	defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(4))
+
+	b1 := newInitialBlock(t)
+	fixture := sample(t, nil)
+	b2 := generate(t, b1)
+	for i := 0; i < 50; i++ {
+		data := *fixture.tx
+		data.ReferenceData = []byte(fmt.Sprint(i))
+		b2.Transactions = append(b2.Transactions, legacy.NewTx(data).Tx)
+	}
+	root, err := bc.MerkleRoot(b2.Transactions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b2.TransactionsRoot = &root
+
+	validateTx := func(tx *bc.Tx) error { return ValidateTx(tx, fixture.initialBlockID) }
+	err = ValidateBlock(b2, b1, b1.ID, validateTx)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func BenchmarkValidateBlock(b *testing.B) {
+	b1 := newInitialBlock(b)
+	fixture := sample(b, nil)
+	var txs []*legacy.Tx
+	for i := 0; i < 5000; i++ {
+		data := *fixture.tx
+		data.ReferenceData = []byte(fmt.Sprint(i))
+		txs = append(txs, legacy.NewTx(data))
+	}
+	blk := generate(b, b1)
+	for _, tx := range txs {
+		blk.Transactions = append(blk.Transactions, tx.Tx)
+	}
+	root, err := bc.MerkleRoot(blk.Transactions)
+	if err != nil {
+		b.Fatal(err)
+	}
+	blk.TransactionsRoot = &root
+	validateTx := func(tx *bc.Tx) error { return ValidateTx(tx, fixture.initialBlockID) }
+
+	for _, c := range []struct {
+		name  string
+		procs int
+	}{{"serial", 1}, {"parallel", runtime.NumCPU()}} {
+		b.Run(c.name, func(b *testing.B) {
+			defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(c.procs))
+			for i := 0; i < b.N; i++ {
+				err := ValidateBlock(blk, b1, b1.ID, validateTx)
+				if err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
 func dummyValidateTx(*bc.Tx) error {
 	return nil
 }
@@ -117,3 +266,19 @@ func generate(tb testing.TB, prev *bc.Block) *bc.Block {
 
 	return legacy.MapBlock(b)
 }
+
+// generateWithTxs is like generate, but the new
+// block has n distinct, trivial transactions.
+func generateWithTxs(tb testing.TB, prev *bc.Block, n int) *bc.Block {
+	b := generate(tb, prev)
+	for i := 0; i < n; i++ {
+		tx := legacy.NewTx(legacy.TxData{Version: 1, ReferenceData: []byte(fmt.Sprint(i))})
+		b.Transactions = append(b.Transactions, tx.Tx)
+	}
+	root, err := bc.MerkleRoot(b.Transactions)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	b.TransactionsRoot = &root
+	return b
+}
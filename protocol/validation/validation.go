@@ -2,6 +2,9 @@ package validation
 
 import (
 	"fmt"
+	"runtime"
+	"sync"
+	"sync/atomic"
 
 	"chain/errors"
 	"chain/math/checked"
@@ -36,12 +39,10 @@ var (
 	errEmptyResults          = errors.New("transaction has no results")
 	errMismatchedAssetID     = errors.New("mismatched asset id")
 	errMismatchedBlock       = errors.New("mismatched block")
-	errMismatchedMerkleRoot  = errors.New("mismatched merkle root")
 	errMismatchedPosition    = errors.New("mismatched value source/dest positions")
 	errMismatchedReference   = errors.New("mismatched reference")
 	errMismatchedValue       = errors.New("mismatched value")
 	errMisorderedBlockHeight = errors.New("misordered block height")
-	errMisorderedBlockTime   = errors.New("misordered block time")
 	errMissingField          = errors.New("missing required field")
 	errNoPrevBlock           = errors.New("no previous block")
 	errNoSource              = errors.New("no source for value")
@@ -50,12 +51,41 @@ var (
 	errPosition              = errors.New("invalid source or destination position")
 	errTxVersion             = errors.New("invalid transaction version")
 	errUnbalanced            = errors.New("unbalanced")
-	errUntimelyTransaction   = errors.New("block timestamp outside transaction time range")
 	errVersionRegression     = errors.New("version regression")
 	errWrongBlockchain       = errors.New("wrong blockchain")
 	errZeroTime              = errors.New("timerange has one or two bounds set to zero")
 )
 
+// Errors callers may need to tell apart. Validation wraps them
+// with context, but they remain the root error (see errors.Root).
+var (
+	// ErrBadTimestamp is returned for a block whose timestamp
+	// isn't after its predecessor's or is outside the time
+	// range of one of its transactions.
+	ErrBadTimestamp = errors.New("bad timestamp")
+
+	// ErrBadTxRoot is returned for a block whose transactions
+	// merkle root doesn't match its transactions.
+	ErrBadTxRoot = errors.New("mismatched transactions merkle root")
+
+	// ErrDoubleSpend is returned when applying a transaction
+	// that spends an output not in the state snapshot, because
+	// it was already spent or never existed.
+	ErrDoubleSpend = errors.New("output already spent or nonexistent")
+
+	// ErrProgramFailure is returned when an input's control or
+	// issuance program fails. The error's data holds the index
+	// of the input as "input_index", and its detail says how
+	// the program failed.
+	ErrProgramFailure = errors.New("program failed")
+)
+
+// programFailure returns ErrProgramFailure for err,
+// from running the program of input i.
+func programFailure(err error, i uint64) error {
+	return errors.WithData(errors.WithDetail(ErrProgramFailure, err.Error()), "input_index", i)
+}
+
 func checkValid(vs *validationState, e bc.Entry) (err error) {
 	entryID := bc.EntryID(e)
 	var ok bool
@@ -228,7 +258,7 @@ func checkValid(vs *validationState, e bc.Entry) (err error) {
 
 		err = vm.Verify(NewTxVMContext(vs.tx, e, e.WitnessAssetDefinition.IssuanceProgram, e.WitnessArguments))
 		if err != nil {
-			return errors.Wrap(err, "checking issuance program")
+			return errors.Wrap(programFailure(err, e.Ordinal), "checking issuance program")
 		}
 
 		var anchored *bc.Hash
@@ -278,7 +308,7 @@ func checkValid(vs *validationState, e bc.Entry) (err error) {
 		}
 		err = vm.Verify(NewTxVMContext(vs.tx, e, spentOutput.ControlProgram, e.WitnessArguments))
 		if err != nil {
-			return errors.Wrap(err, "checking control program")
+			return errors.Wrap(programFailure(err, e.Ordinal), "checking control program")
 		}
 
 		eq, err := spentOutput.Source.Value.Equal(e.WitnessDestination.Value)
@@ -469,21 +499,18 @@ func ValidateBlock(b, prev *bc.Block, initialBlockID bc.Hash, validateTx func(*b
 		return errors.Wrap(err, "checking block header")
 	}
 
-	for i, tx := range b.Transactions {
-		if b.Version == 1 && tx.Version != 1 {
-			return errors.WithDetailf(errTxVersion, "block version %d, transaction version %d", b.Version, tx.Version)
-		}
-		if tx.MaxTimeMs > 0 && b.TimestampMs > tx.MaxTimeMs {
-			return errors.WithDetailf(errUntimelyTransaction, "block timestamp %d, transaction time range %d-%d", b.TimestampMs, tx.MinTimeMs, tx.MaxTimeMs)
-		}
-		if tx.MinTimeMs > 0 && b.TimestampMs > 0 && b.TimestampMs < tx.MinTimeMs {
-			return errors.WithDetailf(errUntimelyTransaction, "block timestamp %d, transaction time range %d-%d", b.TimestampMs, tx.MinTimeMs, tx.MaxTimeMs)
-		}
-
-		err = validateTx(tx)
-		if err != nil {
-			return errors.Wrapf(err, "validity of transaction %d of %d", i, len(b.Transactions))
-		}
+	// The checks of each tx against the block are cheap; do
+	// them first, in order. Then validate the txs before the
+	// first one that failed, concurrently. Either way, the
+	// error reported is for the first bad tx, as if each tx
+	// were checked completely before going on to the next.
+	n, headerErr := checkTxsInBlock(b)
+	bad, err := validateTxs(b.Transactions[:n], validateTx)
+	if err != nil {
+		return errors.Wrapf(err, "validity of transaction %d of %d", bad, len(b.Transactions))
+	}
+	if headerErr != nil {
+		return headerErr
 	}
 
 	txRoot, err := bc.MerkleRoot(b.Transactions)
@@ -492,12 +519,79 @@ func ValidateBlock(b, prev *bc.Block, initialBlockID bc.Hash, validateTx func(*b
 	}
 
 	if txRoot != *b.TransactionsRoot {
-		return errors.WithDetailf(errMismatchedMerkleRoot, "computed %x, current block wants %x", txRoot.Bytes(), b.TransactionsRoot.Bytes())
+		return errors.WithDetailf(ErrBadTxRoot, "computed %x, current block wants %x", txRoot.Bytes(), b.TransactionsRoot.Bytes())
 	}
 
 	return nil
 }
 
+// checkTxsInBlock checks that each tx in b fits b's version and
+// timestamp. It returns the index of the first tx that doesn't
+// and the reason, or the number of txs and nil if all do.
+func checkTxsInBlock(b *bc.Block) (int, error) {
+	for i, tx := range b.Transactions {
+		if b.Version == 1 && tx.Version != 1 {
+			return i, errors.WithDetailf(errTxVersion, "block version %d, transaction version %d", b.Version, tx.Version)
+		}
+		if tx.MaxTimeMs > 0 && b.TimestampMs > tx.MaxTimeMs {
+			return i, errors.WithDetailf(ErrBadTimestamp, "block timestamp %d, transaction time range %d-%d", b.TimestampMs, tx.MinTimeMs, tx.MaxTimeMs)
+		}
+		if tx.MinTimeMs > 0 && b.TimestampMs > 0 && b.TimestampMs < tx.MinTimeMs {
+			return i, errors.WithDetailf(ErrBadTimestamp, "block timestamp %d, transaction time range %d-%d", b.TimestampMs, tx.MinTimeMs, tx.MaxTimeMs)
+		}
+	}
+	return len(b.Transactions), nil
+}
+
+// validateTxs calls validateTx on each of txs, using up to
+// GOMAXPROCS goroutines. It returns the index of the first
+// invalid tx and its error, or len(txs) and nil if all are
+// valid. Once a tx fails, the txs after it aren't validated.
+func validateTxs(txs []*bc.Tx, validateTx func(*bc.Tx) error) (int, error) {
+	workers := runtime.GOMAXPROCS(0)
+	if workers > len(txs) {
+		workers = len(txs)
+	}
+
+	var (
+		next int64 = -1 // atomic; the last index handed out
+
+		mu       sync.Mutex
+		firstBad = len(txs)
+		firstErr error
+
+		wg sync.WaitGroup
+	)
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				// Indexes are handed out in order, so once one
+				// is past a failure, so are all the rest.
+				i := int(atomic.AddInt64(&next, 1))
+				mu.Lock()
+				done := i >= firstBad
+				mu.Unlock()
+				if done {
+					return
+				}
+
+				err := validateTx(txs[i])
+				if err != nil {
+					mu.Lock()
+					if i < firstBad {
+						firstBad, firstErr = i, err
+					}
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	return firstBad, firstErr
+}
+
 func validateBlockAgainstPrev(b, prev *bc.Block) error {
 	if b.Version < prev.Version {
 		return errors.WithDetailf(errVersionRegression, "previous block verson %d, current block version %d", prev.Version, b.Version)
@@ -509,7 +603,7 @@ func validateBlockAgainstPrev(b, prev *bc.Block) error {
 		return errors.WithDetailf(errMismatchedBlock, "previous block ID %x, current block wants %x", prev.ID.Bytes(), b.PreviousBlockId.Bytes())
 	}
 	if b.TimestampMs <= prev.TimestampMs {
-		return errors.WithDetailf(errMisorderedBlockTime, "previous block time %d, current block time %d", prev.TimestampMs, b.TimestampMs)
+		return errors.WithDetailf(ErrBadTimestamp, "previous block time %d, current block time %d", prev.TimestampMs, b.TimestampMs)
 	}
 	return nil
 }
@@ -281,7 +281,7 @@ func TestTxValidation(t *testing.T) {
 				iss := txIssuance(t, tx, 0)
 				iss.WitnessArguments[0] = []byte{}
 			},
-			err: vm.ErrFalseVMResult,
+			err: ErrProgramFailure,
 		},
 		{
 			desc: "issuance exthash nonempty",
@@ -305,7 +305,7 @@ func TestTxValidation(t *testing.T) {
 				spend := txSpend(t, tx, 1)
 				spend.WitnessArguments[0] = []byte{}
 			},
-			err: vm.ErrFalseVMResult,
+			err: ErrProgramFailure,
 		},
 		{
 			desc: "mismatched spent source/witness value",
@@ -41,6 +41,7 @@ var ErrFalseVMResult = errors.New("false VM result")
 var TraceOut io.Writer
 
 func Verify(context *Context) (err error) {
+	var vm *virtualMachine
 	defer func() {
 		if r := recover(); r != nil {
 			if rErr, ok := r.(error); ok {
@@ -48,6 +49,9 @@ func Verify(context *Context) (err error) {
 			} else {
 				err = errors.Wrap(ErrUnexpected, r)
 			}
+			if vm != nil {
+				err = wrapErr(err, vm, context.Arguments)
+			}
 		}
 	}()
 
@@ -55,7 +59,7 @@ func Verify(context *Context) (err error) {
 		return ErrUnsupportedVM
 	}
 
-	vm := &virtualMachine{
+	vm = &virtualMachine{
 		expansionReserved: context.TxVersion != nil && *context.TxVersion == 1,
 		program:           context.Code,
 		runLimit:          initialRunLimit,
@@ -216,10 +220,17 @@ func stackCost(stack [][]byte) int64 {
 	return result
 }
 
+// Error is returned by Verify when a program fails.
+// PC is the offset of the instruction that failed
+// and Op is its opcode. If the program ran to completion
+// (for instance, leaving false on the stack),
+// PC is len(Prog) and Op is meaningless.
 type Error struct {
 	Err  error
 	Prog []byte
 	Args [][]byte
+	PC   uint32
+	Op   Op
 }
 
 func (e Error) Error() string {
@@ -233,16 +244,26 @@ func (e Error) Error() string {
 		args = append(args, hex.EncodeToString(a))
 	}
 
-	return fmt.Sprintf("%s [prog %x = %s; args %s]", e.Err.Error(), e.Prog, dis, strings.Join(args, " "))
+	var at string
+	if e.PC < uint32(len(e.Prog)) {
+		at = fmt.Sprintf(" at pc %d (%s)", e.PC, e.Op)
+	}
+
+	return fmt.Sprintf("%s%s [prog %x = %s; args %s]", e.Err.Error(), at, e.Prog, dis, strings.Join(args, " "))
 }
 
 func wrapErr(err error, vm *virtualMachine, args [][]byte) error {
 	if err == nil {
 		return nil
 	}
-	return Error{
+	e := Error{
 		Err:  err,
 		Prog: vm.program,
 		Args: args,
+		PC:   vm.pc,
+	}
+	if vm.pc < uint32(len(vm.program)) {
+		e.Op = Op(vm.program[vm.pc])
 	}
+	return e
 }
@@ -226,6 +226,43 @@ func TestVerifyBlockHeader(t *testing.T) {
 	}
 }
 
+func TestVerifyErrorPC(t *testing.T) {
+	cases := []struct {
+		prog    string
+		wantErr error
+		wantPC  uint32
+		wantOp  Op
+	}{
+		{"1 2 ADD DROP DROP", ErrDataStackUnderflow, 4, OP_DROP},
+		{"FROMALTSTACK", ErrAltStackUnderflow, 0, OP_FROMALTSTACK},
+		{"1 0 DIV", ErrDivZero, 2, OP_DIV},
+		{"0x010203040506070809 1ADD", ErrBadValue, 10, OP_1ADD},
+		{"0x01 2 LEFT", ErrBadValue, 3, OP_LEFT},
+		{"0 VERIFY", ErrVerifyFailed, 1, OP_VERIFY},
+		{"1 FAIL", ErrReturn, 1, OP_FAIL},
+		{"0xffffffffffffff7f 1ADD", ErrRange, 9, OP_1ADD},
+		{"1 0", ErrFalseVMResult, 2, 0},
+	}
+	for _, c := range cases {
+		prog, err := Assemble(c.prog)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = Verify(&Context{VMVersion: 1, Code: prog})
+		e, ok := err.(Error)
+		if !ok {
+			t.Errorf("Verify(%s) err = %#v want vm.Error", c.prog, err)
+			continue
+		}
+		if errors.Root(e.Err) != c.wantErr {
+			t.Errorf("Verify(%s) err = %v want %v", c.prog, e.Err, c.wantErr)
+		}
+		if e.PC != c.wantPC || e.Op != c.wantOp {
+			t.Errorf("Verify(%s) failed at pc %d (%s) want pc %d (%s)", c.prog, e.PC, e.Op, c.wantPC, c.wantOp)
+		}
+	}
+}
+
 func TestRun(t *testing.T) {
 	cases := []struct {
 		vm      *virtualMachine